@@ -265,7 +265,8 @@ def go_code_for_remote_command(name: str, cmd: RemoteCommand, template: str) ->
     if unhandled:
         raise SystemExit(f'Cant map fields: {", ".join(unhandled)} for cmd: {name}')
     if name != 'send_text':
-        unused_options = set(option_map) - used_options - {'NoResponse', 'ResponseTimeout'}
+        client_only = {''.join(x.capitalize() for x in o.split('_')) for o in cmd.client_only_options}
+        unused_options = set(option_map) - used_options - client_only - {'NoResponse', 'ResponseTimeout'}
         if unused_options:
             raise SystemExit(f'Unused options: {", ".join(unused_options)} for command: {name}')
 
@@ -285,6 +286,7 @@ def go_code_for_remote_command(name: str, cmd: RemoteCommand, template: str) ->
         JSON_INIT_CODE='\n'.join(jc), ARGSPEC=argspec,
         STRING_RESPONSE_IS_ERROR='true' if cmd.string_return_is_error else 'false',
         STREAM_WANTED='true' if cmd.reads_streaming_data else 'false',
+        RESPONSE_HANDLER=cmd.go_response_handler or 'nil',
     )
     return ans
 # }}}
@@ -523,6 +525,8 @@ def generate_readline_actions() -> str:
         ActionHistoryPreviousOrCursorUp
         ActionCursorDown
         ActionHistoryNextOrCursorDown
+        ActionCursorUpLogicalLine
+        ActionCursorDownLogicalLine
         ActionHistoryNext
         ActionHistoryPrevious
         ActionHistoryFirst
@@ -559,6 +563,15 @@ def generate_readline_actions() -> str:
 
         ActionCompleteForward
         ActionCompleteBackward
+        ActionTerminateCompletionMenuAndRestore
+        ActionTerminateCompletionMenuAndApply
+
+        ActionUndo
+        ActionRedo
+        ActionEditInExternalEditor
+        ActionPushLine
+        ActionSetMark
+        ActionCycleWordStyle
     ''')
 
 
@@ -1,6 +1,7 @@
 #!/usr/bin/env python
 # License: GPLv3 Copyright: 2020, Kovid Goyal <kovid at kovidgoyal.net>
 
+import re
 import sys
 from base64 import standard_b64encode
 from gettext import gettext as _
@@ -111,6 +112,7 @@ def write_broadcast_session(self, start: bool = True) -> None:
         self.write(session_command(self.payload, start))
 
 
+# The completers for match expressions are only available to kitten @
 OPTIONS = ('''
 --hide-input-toggle
 default=Ctrl+Alt+Esc
@@ -123,7 +125,7 @@ def write_broadcast_session(self, start: bool = True) -> None:
 Key to press to end the broadcast session.
 
 
-''' + MATCH_WINDOW_OPTION + '\n\n' + MATCH_TAB_OPTION.replace('--match -m', '--match-tab -t')).format
+''' + re.sub(r'^completion=.+\n', '', MATCH_WINDOW_OPTION + '\n\n' + MATCH_TAB_OPTION.replace('--match -m', '--match-tab -t'), flags=re.M)).format
 help_text = 'Broadcast typed text to kitty windows. By default text is sent to all windows, unless one of the matching options is specified'
 usage = '[initial text to send ...]'
 
@@ -26,6 +26,9 @@
 to match MIME types. For example: :code:`--mime 'text/*'` will match any textual MIME type
 available on the clipboard, usually the first matching MIME type is copied. The special MIME
 type :code:`.` will return the list of available MIME types currently on the system clipboard.
+In filter mode, the MIME type of the data on :file:`STDIN` is detected from its contents,
+use this option to override it, or, with :option:`--get-clipboard`, to read data of a type
+other than plain text.
 
 
 --alias -a
@@ -42,6 +45,219 @@
 type=bool-set
 Wait till the copy to clipboard is complete before exiting. Useful if running
 the kitten in a dedicated, ephemeral window. Only needed in filter mode.
+
+
+--detach
+type=bool-set
+Return as soon as the text on :file:`STDIN` has been read, leaving a background
+process to send it to the terminal and, with :option:`--add-to-history`, update
+the clipboard history. Useful in
+shell prompt hooks and key bindings, where any delay is noticeable. Since
+nothing waits for the terminal, errors are not reported. Can only be used when
+copying text piped into :file:`STDIN` in filter mode, text of types other than
+plain text is copied as plain text. Cannot be combined with
+:option:`--sensitive`.
+
+
+--add-to-history
+type=bool-set
+Remember the text copied to the clipboard in filter mode in the clipboard
+history of this kitten. The history is stored in :file:`clipboard-history.json`
+in the kitty cache directory, readable only by you. Text that looks like a
+private key or access token, text copied with :option:`--sensitive` or
+:option:`--clear-after` and text copied to the primary selection is never
+remembered.
+
+
+--history-list
+type=bool-set
+Print the history of text copied to the clipboard using this kitten with
+:option:`--add-to-history` as JSON to :file:`STDOUT`. Every entry has an :code:`index` that can be used with :option:`--history-get`,
+most recent entries come first. Useful to integrate with external launchers such as
+:program:`rofi` or :program:`fzf`.
+
+
+--history-get
+type=int
+default=-1
+Copy the entry with the specified index from the clipboard history back to the clipboard.
+Use :option:`--history-list` to see the available entries, :code:`0` is the most recent
+entry.
+
+
+--get-image
+completion=type:file mime:image/* group:"Images"
+Save the image on the clipboard to the specified file. The image is saved in the
+format specified by the extension of the file name, PNG if it has no extension
+or the file name is :code:`-` for :file:`STDOUT`. If the clipboard has an image
+in some other format, it is converted.
+
+
+--set-image
+completion=type:file mime:image/* group:"Images"
+Copy the image in the specified file to the clipboard. Its type is detected
+from the file name or, failing that, its contents. Use :code:`-` to read the
+image from :file:`STDIN`. For example, to copy a screenshot:
+:code:`grim - | kitty +kitten clipboard --set-image -`
+
+
+--watch
+type=bool-set
+Watch the clipboard for changes, printing its new contents to :file:`STDOUT` every
+time it changes, until interrupted. The contents at startup are not printed. The
+clipboard is checked periodically, see :option:`--watch-interval`. Note that
+kitty will ask for permission to read the clipboard unless
+:opt:`clipboard_control` allows it. Useful for integrating with clipboard
+managers.
+
+
+--watch-interval
+type=float
+default=1
+The number of seconds between checks of the clipboard contents when using
+:option:`--watch`.
+
+
+--watch-command
+Instead of printing the new contents of the clipboard when using :option:`--watch`,
+run the specified command, with the contents as its :file:`STDIN`. The number of
+the change, starting from one, is available to the command in the environment
+variable :envvar:`KITTY_CLIPBOARD_CHANGE_NUMBER`.
+
+
+--watch-null
+type=bool-set
+Separate the contents of the clipboard printed when using :option:`--watch` with
+null bytes instead of newlines, which is safer for text that can contain newlines.
+Only used when :file:`STDOUT` is not a terminal.
+
+
+--list-types
+type=bool-set
+Print the MIME types of the data currently available on the clipboard, one per
+line, to :file:`STDOUT`, and exit. Useful for scripts to decide what type of data
+to request with :option:`--mime`. Requires a terminal that supports the extended
+clipboard protocol, such as kitty.
+
+
+--json
+type=bool-set
+Print the output of :option:`--list-types` as a JSON array.
+
+
+--max-size
+default=0
+validator=cli.ByteSize()
+The maximum size of text to copy to the clipboard in filter mode, for example,
+:code:`100KB` or :code:`8MiB`. Many terminals and terminal multiplexers such as
+:program:`tmux` limit the size of the escape code used to copy text, silently
+dropping or mangling larger copies. With this option, copying more data than the
+limit fails with an error, see :option:`--truncate`. Zero means no limit.
+
+
+--truncate
+type=bool-set
+When copying more data than allowed by :option:`--max-size`, copy only as much as
+allowed and print a warning, instead of failing.
+
+
+--strip-trailing-newline
+type=bool-set
+Remove the newline at the end of the text, if any. For example, to copy the current
+directory without a trailing newline: :code:`pwd | kitty +kitten clipboard --strip-trailing-newline`.
+The text transformations such as this one are applied both when copying to and pasting
+from the clipboard, in filter mode.
+
+
+--trim
+type=bool-set
+Remove whitespace from the start and end of the text.
+
+
+--convert-crlf
+type=bool-set
+Convert Windows style CRLF line endings in the text to LF.
+
+
+--expand-tabs
+type=int
+default=0
+Replace tabs in the text with spaces, using tab stops every the specified number
+of columns. Zero means tabs are not replaced.
+
+
+--sensitive
+type=bool-set
+The text being copied is a secret, such as a password. It is marked so that
+clipboard managers do not remember it and it is not added to the clipboard
+history of this kitten. Without this option, a warning is printed when the
+copied text looks like a private key or access token. Combine with
+:option:`--clear-after` to also remove the secret from the clipboard after a while.
+
+
+--clear
+type=bool-set
+Empty the clipboard, or the primary selection with :option:`--use-primary`, and exit.
+
+
+--sync-to-primary
+type=bool-set
+Copy the text on the clipboard to the primary selection and exit. Only works on
+systems that have a primary selection, such as Linux.
+
+
+--sync-from-primary
+type=bool-set
+Copy the text in the primary selection to the clipboard and exit.
+
+
+--passthrough
+choices=auto,tmux,screen,none
+default=auto
+Wrap the escape codes used to access the clipboard in filter mode so that the
+terminal multiplexer the kitten is running in passes them through to the
+terminal, unchanged. The default of :code:`auto` detects :program:`tmux` and
+:program:`screen` from the environment. Use :code:`none` to let the
+multiplexer handle the clipboard itself, for example, with the tmux
+:code:`set-clipboard` option. Note that tmux only passes through escape codes
+if its :code:`allow-passthrough` option is enabled.
+
+
+--tmux-buffer
+type=bool-set
+When running inside :program:`tmux`, also copy the text into a tmux paste
+buffer, using :code:`tmux load-buffer`, so that it can be pasted with the tmux
+paste commands as well. Ignored when reading from the clipboard, copying to the
+primary selection or copying data of other MIME types.
+
+
+--clear-after
+type=int
+default=0
+validator=cli.IntRange(0, 86400)
+Automatically clear the clipboard the specified number of seconds after copying
+to it, useful when copying passwords and other secrets. The clipboard is cleared
+by a background process, so the kitten does not wait. Text copied with this option
+is not added to the clipboard history. Ignored when reading from the clipboard.
+Note that the clipboard is cleared unconditionally, even if something else has
+been copied to it in the meantime, since checking what it contains would need
+the terminal to allow reading the clipboard.
+
+
+--response-timeout
+type=float
+default=10
+The number of seconds to wait for a response from the terminal, after which the
+kitten fails. Terminals that do not support the clipboard protocol never respond.
+Time spent waiting for the user to answer a permission prompt in the terminal is
+not counted, while it is shown, a message is displayed. Zero means wait forever.
+
+
+--quiet -q
+type=bool-set
+Do not ask for confirmation before aborting when :kbd:`Esc` or :kbd:`Ctrl+C` is
+pressed while waiting for a response from the terminal, simply exit with an
+error. Useful in scripts.
 '''.format
 help_text = '''\
 Read or write to the system clipboard.
@@ -52,6 +268,13 @@
 :file:`STDOUT`. Note that copying from the clipboard will cause a permission
 popup, see :opt:`clipboard_control` for details.
 
+Data other than text, such as images, can also be copied in filter mode, its
+MIME type is detected automatically. For example:
+:code:`kitty +kitten clipboard < picture.png` or
+:code:`kitty +kitten clipboard -g -m image/png > picture.png`.
+If the terminal does not support copying arbitrary data to the clipboard,
+the data is copied as plain text instead.
+
 For more control, specify filename arguments. Then, different MIME types can be copied to/from
 the clipboard. Some examples:
 
@@ -74,6 +297,16 @@
 
     # List the formats available on the system clipboard
     kitty +kitten clipboard -g -m . /dev/stdout
+
+Text copied to the clipboard in filter mode can be remembered in a history,
+stored in the kitty cache directory, with :option:`--add-to-history`. Use
+:option:`--history-list` and :option:`--history-get` to access it.
+
+To copy a secret that is automatically removed from the clipboard after a while:
+
+.. code:: sh
+
+    pass show email | kitty +kitten clipboard --clear-after 30
 '''
 
 usage = '[files to copy to/from]'
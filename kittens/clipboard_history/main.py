@@ -0,0 +1,35 @@
+#!/usr/bin/env python3
+# License: GPL v3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+import sys
+
+OPTIONS = r'''
+--use-primary -p
+type=bool-set
+Copy the selected entry to the primary selection rather than the clipboard on
+systems that support it, such as Linux.
+
+
+--query -q
+The initial search query. Entries are filtered by fuzzy matching the query
+against their text.
+'''.format
+
+
+help_text = '''\
+Browse the history of text copied to the clipboard using the :doc:`clipboard
+kitten </kittens/clipboard>`. The entries are shown most recent first, along
+with when they were copied and a preview of the selected entry. Type to filter
+the entries by fuzzy matching, use the arrow keys to select an entry and press
+:kbd:`Enter` to copy it to the clipboard again.
+'''
+
+usage = ''
+if __name__ == '__main__':
+    raise SystemExit('This should be run as kitten clipboard-history')
+elif __name__ == '__doc__':
+    cd = sys.cli_docs  # type: ignore
+    cd['usage'] = usage
+    cd['options'] = OPTIONS
+    cd['help_text'] = help_text
+    cd['short_desc'] = 'Browse and re-copy the clipboard history'
@@ -208,6 +208,37 @@ def hash_for_path(path: str) -> bytes:
     return md5(raw_data_for_path(path)).digest()
 
 
+def modification_state(paths: Sequence[str], ignore_names: Tuple[str, ...]) -> Tuple[Tuple[str, int, int], ...]:
+    ' Return a value that changes whenever a file in paths is modified, added or removed '
+    ans: List[Tuple[str, int, int]] = []
+
+    def add(path: str) -> None:
+        try:
+            st = os.stat(path)
+        except OSError:
+            ans.append((path, -1, -1))
+        else:
+            ans.append((path, st.st_mtime_ns, st.st_size))
+
+    for path in paths:
+        if os.path.isdir(path):
+            for dirpath, dirnames, filenames in os.walk(path):
+                dirnames[:] = allowed_items(sorted(dirnames), ignore_names)
+                for filename in allowed_items(sorted(filenames), ignore_names):
+                    add(os.path.join(dirpath, filename))
+        else:
+            add(path)
+    return tuple(ans)
+
+
+def clear_caches() -> None:
+    ' Forget the cached contents of files, needed when they have changed '
+    for f in (mime_type_for_path, raw_data_for_path, data_for_path, hash_for_path, LinesForPath.__call__):
+        f.cache_clear()
+    path_name_map.clear()
+    set_highlight_data({})
+
+
 def create_collection(left: str, right: str) -> Collection:
     collection = Collection()
     if os.path.isdir(left):
@@ -42,9 +42,11 @@
 from .collect import (
     Collection,
     add_remote_dir,
+    clear_caches,
     create_collection,
     data_for_path,
     lines_for_path,
+    modification_state,
     sanitize,
     set_highlight_data,
 )
@@ -81,6 +83,9 @@ def get_highlight_processes() -> Iterator[int]:
             yield -1
 
 
+WATCH_INTERVAL = 1  # seconds
+
+
 class State(Enum):
     initializing = auto()
     collected = auto()
@@ -124,12 +129,15 @@ def __init__(self, args: DiffCLIOptions, opts: DiffOptions, left: str, right: st
         self.report_traceback_on_exit: Union[str, Dict[str, Patch], None] = None
         self.args = args
         self.scroll_pos = self.max_scroll_pos = 0
+        # set when the layout is changed with a shortcut, overriding the configured layout
+        self.layout_override: Optional[str] = None
         self.current_context_count = self.original_context_count = self.args.context
         if self.current_context_count < 0:
             self.current_context_count = self.original_context_count = self.opts.num_context_lines
         self.highlighting_done = False
         self.doing_background_work = BackgroundWork.none
         self.restore_position: Optional[Reference] = None
+        self.watched_state = modification_state((left, right), Collection.ignore_names) if args.watch else ()
         for key_def, action in self.opts.key_definitions.items():
             self.add_shortcut(action, key_def)
 
@@ -165,6 +173,8 @@ def perform_action(self, action: KeyAction) -> None:
                 else:
                     new_ctx += int(to or 0)
                 return self.change_context_count(new_ctx)
+            if func == 'change_layout':
+                return self.change_layout(str(args[0]))
             if func == 'start_search':
                 self.start_search(bool(args[0]), bool(args[1]))
                 return
@@ -245,15 +255,23 @@ def calculate_statistics(self) -> None:
             self.added_count += patch.added_count
             self.removed_count += patch.removed_count
 
+    @property
+    def unified(self) -> bool:
+        layout = self.layout_override or self.opts.layout
+        if layout == 'auto':
+            return self.screen_size.cols < self.opts.min_split_width
+        return layout == 'unified'
+
     def render_diff(self) -> None:
-        self.diff_lines: Tuple[Line, ...] = tuple(render_diff(self.collection, self.diff_map, self.args, self.screen_size.cols, self.image_manager))
+        self.diff_lines: Tuple[Line, ...] = tuple(render_diff(
+            self.collection, self.diff_map, self.args, self.screen_size.cols, self.image_manager, self.unified))
         self.margin_size = render_diff.margin_size
         self.ref_path_map: DefaultDict[str, List[Tuple[int, Reference]]] = defaultdict(list)
         for i, dl in enumerate(self.diff_lines):
             self.ref_path_map[dl.ref.path].append((i, dl.ref))
         self.max_scroll_pos = len(self.diff_lines) - self.num_lines
         if self.current_search is not None:
-            self.current_search(self.diff_lines, self.margin_size, self.screen_size.cols)
+            self.current_search(self.diff_lines, self.margin_size, self.screen_size.cols, self.unified)
 
     @property
     def current_position(self) -> Reference:
@@ -351,6 +369,26 @@ def initialize(self) -> None:
         self.set_scrolling_region()
         self.draw_screen()
         self.create_collection()
+        if self.args.watch:
+            self.asyncio_loop.call_later(WATCH_INTERVAL, self.check_for_changes)
+
+    def check_for_changes(self) -> None:
+        self.asyncio_loop.call_later(WATCH_INTERVAL, self.check_for_changes)
+        # dont interrupt background work or the user typing a search query
+        if self.state is not State.diffed or self.doing_background_work is not BackgroundWork.none:
+            return
+        state = modification_state((self.left, self.right), Collection.ignore_names)
+        if state != self.watched_state:
+            self.watched_state = state
+            self.reload()
+
+    def reload(self) -> None:
+        clear_caches()
+        if self.diff_lines:
+            self.restore_position = self.current_position
+        self.highlighting_done = False
+        self.image_manager.delete_all_sent_images()
+        self.create_collection()
 
     def enforce_cursor_state(self) -> None:
         self.cmd.set_cursor_visible(self.state is State.command)
@@ -501,6 +539,18 @@ def change_context_count(self, new_ctx: int) -> None:
             self.restore_position = self.current_position
             self.draw_screen()
 
+    def change_layout(self, layout: str) -> None:
+        was_unified = self.unified
+        if layout == 'toggle':
+            layout = 'split' if was_unified else 'unified'
+        self.layout_override = None if layout == 'auto' else layout
+        if self.state.value >= State.diffed.value and self.unified != was_unified:
+            pos = self.current_position
+            self.image_manager.delete_all_sent_images()
+            self.render_diff()
+            self.current_position = pos
+            self.draw_screen()
+
     def start_search(self, is_regex: bool, is_backward: bool) -> None:
         if self.state is not State.diffed:
             self.cmd.bell()
@@ -523,7 +573,7 @@ def do_search(self) -> None:
             self.message = sanitize(_('Bad regex: {}').format(query[1:]))
             self.cmd.bell()
         else:
-            if self.current_search(self.diff_lines, self.margin_size, self.screen_size.cols):
+            if self.current_search(self.diff_lines, self.margin_size, self.screen_size.cols, self.unified):
                 self.scroll_to_next_match(include_current=True)
             else:
                 self.state = State.message
@@ -606,6 +656,13 @@ def on_eot(self) -> None:
 Override individual configuration options, can be specified multiple times.
 Syntax: :italic:`name=value`. For example: :italic:`-o background=gray`
 
+
+--watch
+type=bool-set
+Watch the files/directories being compared and update the diff whenever any of
+them changes, keeping the current scroll position. Useful for watching
+generated files converge while editing. Has no effect on remote files.
+
 '''.format, config_help=CONFIG_HELP.format(conf_name='diff', appname=appname))
 
 
@@ -47,6 +47,27 @@
     long_text='The string to replace tabs with. Default is to use four spaces.'
     )
 
+opt('layout', 'auto',
+    choices=('auto', 'split', 'unified'),
+    long_text='''
+How to show the changes. :code:`split` shows the old and new versions of the
+files side-by-side, :code:`unified` shows the removed lines followed by the
+added lines in a single column, like :program:`git diff` does. The default
+:code:`auto` uses the split layout unless the window is narrower than
+:opt:`min_split_width <kitten-diff.min_split_width>`. The layout can be changed
+while the kitten is running by pressing :kbd:`L`.
+'''
+    )
+
+opt('min_split_width', '100',
+    option_type='positive_int',
+    long_text='''
+The narrowest window, in columns, in which the :code:`auto` :opt:`layout
+<kitten-diff.layout>` shows the changes side-by-side. Narrower windows use the
+unified layout, as the columns would be too narrow to read.
+'''
+    )
+
 opt('+ignore_name', '',
     option_type='store_multiple',
     add_to_default=False,
@@ -230,6 +251,10 @@
     'decrease_context - change_context -5',
     )
 
+map('Toggle between the split and unified layouts',
+    'toggle_layout l change_layout toggle',
+    )
+
 map('Search forward',
     'search_forward / start_search regex forward',
     )
@@ -42,6 +42,14 @@ def ignore_name(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         for k, v in store_multiple(val, ans["ignore_name"]):
             ans["ignore_name"][k] = v
 
+    def layout(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
+        val = val.lower()
+        if val not in self.choices_for_layout:
+            raise ValueError(f"The value {val} is not a valid choice for layout")
+        ans["layout"] = val
+
+    choices_for_layout = frozenset(('auto', 'split', 'unified'))
+
     def margin_bg(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         ans['margin_bg'] = to_color(val)
 
@@ -51,6 +59,9 @@ def margin_fg(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
     def margin_filler_bg(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         ans['margin_filler_bg'] = to_color_or_none(val)
 
+    def min_split_width(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
+        ans['min_split_width'] = positive_int(val)
+
     def num_context_lines(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         ans['num_context_lines'] = positive_int(val)
 
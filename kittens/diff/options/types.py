@@ -9,6 +9,10 @@
 from kitty.types import ParsedShortcut
 import kitty.types
 
+if typing.TYPE_CHECKING:
+    choices_for_layout = typing.Literal['auto', 'split', 'unified']
+else:
+    choices_for_layout = str
 
 option_names = (  # {{{
  'added_bg',
@@ -22,10 +26,12 @@
  'hunk_bg',
  'hunk_margin_bg',
  'ignore_name',
+ 'layout',
  'map',
  'margin_bg',
  'margin_fg',
  'margin_filler_bg',
+ 'min_split_width',
  'num_context_lines',
  'pygments_style',
  'removed_bg',
@@ -51,9 +57,11 @@ class Options:
     highlight_removed_bg: Color = Color(253, 184, 192)
     hunk_bg: Color = Color(241, 248, 255)
     hunk_margin_bg: Color = Color(219, 237, 255)
+    layout: choices_for_layout = 'auto'
     margin_bg: Color = Color(250, 251, 252)
     margin_fg: Color = Color(170, 170, 170)
     margin_filler_bg: typing.Optional[kitty.fast_data_types.Color] = None
+    min_split_width: int = 100
     num_context_lines: int = 3
     pygments_style: str = 'default'
     removed_bg: Color = Color(255, 238, 240)
@@ -155,6 +163,8 @@ def __getitem__(self, key: typing.Union[int, str]) -> typing.Any:
     (ParsedShortcut(mods=0, key_name='+'), KeyAction('change_context', (5,))), 
     # decrease_context
     (ParsedShortcut(mods=0, key_name='-'), KeyAction('change_context', (-5,))), 
+    # toggle_layout
+    (ParsedShortcut(mods=0, key_name='l'), KeyAction('change_layout', ('toggle',))), 
     # search_forward
     (ParsedShortcut(mods=0, key_name='/'), KeyAction('start_search', (True, False))), 
     # search_backward
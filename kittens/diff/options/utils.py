@@ -39,6 +39,14 @@ def parse_change_context(func: str, rest: str) -> Tuple[str, Union[int, str]]:
     return func, amount
 
 
+@func_with_args('change_layout')
+def parse_change_layout(func: str, rest: str) -> Tuple[str, str]:
+    rest = rest.lower()
+    if rest not in {'auto', 'split', 'unified'}:
+        rest = 'toggle'
+    return func, rest
+
+
 @func_with_args('start_search')
 def parse_start_search(func: str, rest: str) -> Tuple[str, Tuple[bool, bool]]:
     rest_ = rest.lower().split()
@@ -355,17 +355,49 @@ def lines_for_chunk(data: DiffData, hunk_num: int, chunk: Chunk, chunk_num: int)
                 yield Line(left_line + right_line, ref, i == 0 and wli == 0)
 
 
-def lines_for_diff(left_path: str, right_path: str, hunks: Iterable[Hunk], args: DiffCLIOptions, columns: int, margin_size: int) -> Generator[Line, None, None]:
-    available_cols = columns // 2 - margin_size
+def unified_lines_for_chunk(data: DiffData, hunk_num: int, chunk: Chunk, chunk_num: int) -> Generator[Line, None, None]:
+    if chunk.is_context:
+        for i in range(chunk.left_count):
+            ln = chunk.left_start + i
+            for wli, text in enumerate(render_half_line(
+                    ln, data.left_lines[ln], data.left_highlights_for_line(ln), 'context', data.margin_size, data.available_cols)):
+                yield Line(text, Reference(data.left_path, LineRef(ln, wli)))
+        return
+    # all the removed lines followed by all the added lines
+    is_change_start = True
+    for i in range(chunk.left_count):
+        ln = chunk.left_start + i
+        for wli, text in enumerate(render_half_line(
+                ln, data.left_lines[ln], data.left_highlights_for_line(ln), 'remove', data.margin_size, data.available_cols,
+                None if chunk.centers is None else chunk.centers[i])):
+            yield Line(text, Reference(data.left_path, LineRef(ln, wli)), is_change_start)
+            is_change_start = False
+    for i in range(chunk.right_count):
+        ln = chunk.right_start + i
+        for wli, text in enumerate(render_half_line(
+                ln, data.right_lines[ln], data.right_highlights_for_line(ln), 'add', data.margin_size, data.available_cols,
+                None if chunk.centers is None else chunk.centers[i])):
+            yield Line(text, Reference(data.right_path, LineRef(ln, wli)), is_change_start)
+            is_change_start = False
+
+
+def lines_for_diff(
+    left_path: str, right_path: str, hunks: Iterable[Hunk], args: DiffCLIOptions, columns: int, margin_size: int, unified: bool = False
+) -> Generator[Line, None, None]:
+    available_cols = (columns if unified else columns // 2) - margin_size
     data = DiffData(left_path, right_path, available_cols, margin_size)
+    chunk_lines = unified_lines_for_chunk if unified else lines_for_chunk
 
     for hunk_num, hunk in enumerate(hunks):
         yield Line(hunk_title(hunk_num, hunk, margin_size, columns - margin_size), Reference(left_path, LineRef(hunk.left_start)))
         for cnum, chunk in enumerate(hunk.chunks):
-            yield from lines_for_chunk(data, hunk_num, chunk, cnum)
+            yield from chunk_lines(data, hunk_num, chunk, cnum)
 
 
-def all_lines(path: str, args: DiffCLIOptions, columns: int, margin_size: int, is_add: bool = True) -> Generator[Line, None, None]:
+def all_lines(path: str, args: DiffCLIOptions, columns: int, margin_size: int, is_add: bool = True, unified: bool = False) -> Generator[Line, None, None]:
+    if unified:
+        yield from unified_all_lines(path, columns, margin_size, is_add)
+        return
     available_cols = columns // 2 - margin_size
     ltype = 'add' if is_add else 'remove'
     lines = lines_for_path(path)
@@ -390,6 +422,18 @@ def highlights(num: int) -> List[Segment]:
             yield Line(text, ref, line_number == 0 and i == 0)
 
 
+def unified_all_lines(path: str, columns: int, margin_size: int, is_add: bool) -> Generator[Line, None, None]:
+    available_cols = columns - margin_size
+    ltype = 'add' if is_add else 'remove'
+    hdata = highlights_for_path(path)
+    yield Line(render_diff_line(
+        '', _('This file was added') if is_add else _('This file was removed'), 'filler', margin_size, available_cols), Reference(path))
+    for line_number, line in enumerate(lines_for_path(path)):
+        highlights = hdata[line_number] if line_number < len(hdata) else []
+        for i, hl in enumerate(render_half_line(line_number, line, highlights, ltype, margin_size, available_cols)):
+            yield Line(hl, Reference(path, LineRef(line_number, i)), line_number == 0 and i == 0)
+
+
 def rename_lines(path: str, other_path: str, args: DiffCLIOptions, columns: int, margin_size: int) -> Generator[str, None, None]:
     m = ' ' * margin_size
     for line in split_to_size(_('The file {0} was renamed to {1}').format(
@@ -489,7 +533,8 @@ def __call__(
         diff_map: Dict[str, Patch],
         args: DiffCLIOptions,
         columns: int,
-        image_manager: ImageManager
+        image_manager: ImageManager,
+        unified: bool = False
     ) -> Generator[Line, None, None]:
         largest_line_number = 0
         for path, item_type, other_path in collection:
@@ -516,7 +561,7 @@ def __call__(
                         ans = yield_lines_from(binary_lines(path, other_path, columns, margin_size), item_ref)
                 else:
                     assert other_path is not None
-                    ans = lines_for_diff(path, other_path, diff_map[path], args, columns, margin_size)
+                    ans = lines_for_diff(path, other_path, diff_map[path], args, columns, margin_size, unified)
             elif item_type == 'add':
                 if is_binary:
                     if is_img:
@@ -524,7 +569,7 @@ def __call__(
                     else:
                         ans = yield_lines_from(binary_lines(None, path, columns, margin_size), item_ref)
                 else:
-                    ans = all_lines(path, args, columns, margin_size, is_add=True)
+                    ans = all_lines(path, args, columns, margin_size, is_add=True, unified=unified)
             elif item_type == 'removal':
                 if is_binary:
                     if is_img:
@@ -532,7 +577,7 @@ def __call__(
                     else:
                         ans = yield_lines_from(binary_lines(path, None, columns, margin_size), item_ref)
                 else:
-                    ans = all_lines(path, args, columns, margin_size, is_add=False)
+                    ans = all_lines(path, args, columns, margin_size, is_add=False, unified=unified)
             elif item_type == 'rename':
                 assert other_path is not None
                 ans = yield_lines_from(rename_lines(path, other_path, args, columns, margin_size), item_ref)
@@ -31,7 +31,7 @@ def __init__(self, opts: DiffOptions, query: str, is_regex: bool, is_backward: b
         except Exception:
             raise BadRegex(f'Not a valid regex: {query}')
 
-    def __call__(self, diff_lines: Iterable['Line'], margin_size: int, cols: int) -> bool:
+    def __call__(self, diff_lines: Iterable['Line'], margin_size: int, cols: int, unified: bool = False) -> bool:
         self.matches = {}
         self.count = 0
         half_width = cols // 2
@@ -40,7 +40,10 @@ def __call__(self, diff_lines: Iterable['Line'], margin_size: int, cols: int) ->
         find = self.pat.finditer
         for i, line in enumerate(diff_lines):
             text = strip_pat.sub('', line.text)
-            left, right = text[margin_size:half_width + 1], text[right_offset:]
+            if unified:
+                left, right = text[margin_size:], ''
+            else:
+                left, right = text[margin_size:half_width + 1], text[right_offset:]
             matches = []
 
             def add(which: str, offset: int) -> None:
@@ -84,8 +84,8 @@ def decode_hint(x: str, alphabet: str = DEFAULT_HINT_ALPHABET) -> int:
     return i
 
 
-def highlight_mark(m: Mark, text: str, current_input: str, alphabet: str, colors: Dict[str, str]) -> str:
-    hint = encode_hint(m.index, alphabet)
+def highlight_mark(m: Mark, text: str, current_input: str, alphabet: str, colors: Dict[str, str], hint: Optional[str] = None) -> str:
+    hint = encode_hint(m.index, alphabet) if hint is None else hint
     if current_input and not hint.startswith(current_input):
         return faint(text)
     hint = hint[len(current_input):] or ' '
@@ -105,11 +105,31 @@ def debug(*a: Any, **kw: Any) -> None:
     d(*a, **kw)
 
 
-def render(text: str, current_input: str, all_marks: Sequence[Mark], ignore_mark_indices: Set[int], alphabet: str, colors: Dict[str, str]) -> str:
+def filter_marks(all_marks: Sequence[Mark], query: str, ignore_mark_indices: Set[int], alphabet: str, offset: int) -> Dict[int, str]:
+    ' Return a map of mark index to hint for the marks whose text contains query, with shorter hints assigned to fewer marks '
+    if query == query.lower():
+        query = query.lower()
+        matches = [m for m in all_marks if m.index not in ignore_mark_indices and query in m.text.lower()]
+    else:
+        matches = [m for m in all_marks if m.index not in ignore_mark_indices and query in m.text]
+    matches.sort(key=lambda m: m.index)
+    return {m.index: encode_hint(i + offset, alphabet) for i, m in enumerate(matches)}
+
+
+def render(
+    text: str, current_input: str, all_marks: Sequence[Mark], ignore_mark_indices: Set[int], alphabet: str, colors: Dict[str, str],
+    hints: Optional[Dict[int, str]] = None
+) -> str:
     for mark in reversed(all_marks):
         if mark.index in ignore_mark_indices:
             continue
-        mtext = highlight_mark(mark, text[mark.start:mark.end], current_input, alphabet, colors)
+        mtext = text[mark.start:mark.end]
+        if hints is None:
+            mtext = highlight_mark(mark, mtext, current_input, alphabet, colors)
+        elif mark.index in hints:
+            mtext = highlight_mark(mark, mtext, current_input, alphabet, colors, hints[mark.index])
+        else:
+            mtext = faint(mtext)
         text = text[:mark.start] + mtext + text[mark.end:]
 
     text = text.replace('\0', '')
@@ -154,6 +174,43 @@ def get_match_suffix(self, args: HintsCLIOptions) -> str:
     def reset(self) -> None:
         self.current_input = ''
         self.current_text: Optional[str] = None
+        self.filter_text = ''
+        self.filtering = False
+        # map of mark index to hint when filtering, None means use the hint for the index
+        self.filtered_hints: Optional[Dict[int, str]] = None
+
+    @property
+    def marks_by_hint(self) -> Dict[str, Mark]:
+        if self.filtered_hints is None:
+            return {encode_hint(idx, self.alphabet): m for idx, m in self.index_map.items()}
+        return {h: self.index_map[idx] for idx, h in self.filtered_hints.items()}
+
+    def set_filter(self, text: str) -> None:
+        self.filter_text = text
+        self.current_input = ''
+        self.current_text = None
+        if text:
+            self.filtered_hints = filter_marks(self.all_marks, text, self.ignore_mark_indices, self.alphabet, max(0, self.args.hints_offset))
+        else:
+            self.filtered_hints = None
+        self.update_window_title()
+
+    def update_window_title(self) -> None:
+        if self.filtering or self.filter_text:
+            self.cmd.set_window_title(f'{self.window_title} /{self.filter_text}')
+        else:
+            self.cmd.set_window_title(self.window_title)
+
+    def choose(self, m: Mark) -> bool:
+        ' Return True if the kitten is done '
+        self.chosen.append(m)
+        if self.multiple:
+            self.ignore_mark_indices.add(m.index)
+            self.reset()
+            self.update_window_title()
+            return False
+        self.quit_loop(0)
+        return True
 
     def init_terminal_state(self) -> None:
         self.cmd.set_cursor_visible(False)
@@ -165,49 +222,62 @@ def initialize(self) -> None:
         self.draw_screen()
 
     def on_text(self, text: str, in_bracketed_paste: bool = False) -> None:
+        if self.filtering:
+            self.set_filter(self.filter_text + text)
+            if self.filtered_hints is not None and len(self.filtered_hints) == 1:
+                if self.choose(self.index_map[next(iter(self.filtered_hints))]):
+                    return
+            self.draw_screen()
+            return
+        if text == '/' and '/' not in self.alphabet:
+            self.filtering = True
+            self.update_window_title()
+            return
         changed = False
         for c in text:
             if c in self.alphabet:
                 self.current_input += c
                 changed = True
         if changed:
-            matches = [
-                m for idx, m in self.index_map.items()
-                if encode_hint(idx, self.alphabet).startswith(self.current_input)
-            ]
+            matches = [m for h, m in self.marks_by_hint.items() if h.startswith(self.current_input)]
             if len(matches) == 1:
-                self.chosen.append(matches[0])
-                if self.multiple:
-                    self.ignore_mark_indices.add(matches[0].index)
-                    self.reset()
-                else:
-                    self.quit_loop(0)
+                if self.choose(matches[0]):
                     return
             self.current_text = None
             self.draw_screen()
 
     def on_key(self, key_event: KeyEvent) -> None:
+        if self.filtering:
+            if key_event.matches('backspace'):
+                self.set_filter(self.filter_text[:-1])
+                self.draw_screen()
+            elif key_event.matches('enter') or key_event.matches('tab'):
+                # keep the filter and go back to typing hints
+                self.filtering = False
+                self.update_window_title()
+            elif key_event.matches('esc'):
+                self.filtering = False
+                self.set_filter('')
+                self.draw_screen()
+            return
         if key_event.matches('backspace'):
             self.current_input = self.current_input[:-1]
             self.current_text = None
             self.draw_screen()
         elif (key_event.matches('enter') or key_event.matches('space')) and self.current_input:
-            try:
-                idx = decode_hint(self.current_input, self.alphabet)
-                self.chosen.append(self.index_map[idx])
-                self.ignore_mark_indices.add(idx)
-            except Exception:
+            m = self.marks_by_hint.get(self.current_input)
+            if m is None:
                 self.current_input = ''
                 self.current_text = None
                 self.draw_screen()
-            else:
-                if self.multiple:
-                    self.reset()
-                    self.draw_screen()
-                else:
-                    self.quit_loop(0)
+            elif not self.choose(m):
+                self.draw_screen()
         elif key_event.matches('esc'):
-            self.quit_loop(0 if self.multiple else 1)
+            if self.filter_text:
+                self.set_filter('')
+                self.draw_screen()
+            else:
+                self.quit_loop(0 if self.multiple else 1)
 
     def on_interrupt(self) -> None:
         self.quit_loop(1)
@@ -220,7 +290,8 @@ def on_resize(self, new_size: ScreenSize) -> None:
 
     def draw_screen(self) -> None:
         if self.current_text is None:
-            self.current_text = render(self.text, self.current_input, self.all_marks, self.ignore_mark_indices, self.alphabet, self.colors)
+            self.current_text = render(
+                self.text, self.current_input, self.all_marks, self.ignore_mark_indices, self.alphabet, self.colors, self.filtered_hints)
         self.cmd.clear_screen()
         self.write(self.current_text)
 
@@ -47,14 +47,17 @@
 
 --transfer-mode
 type=choices
-choices=detect,file,stream,memory
+choices=detect,file,stream,memory,sixel
 default=detect
 Which mechanism to use to transfer images to the terminal. The default is to
 auto-detect. :italic:`file` means to use a temporary file, :italic:`memory` means
 to use shared memory, :italic:`stream` means to send the data via terminal
 escape codes. Note that if you use the :italic:`file` or :italic:`memory` transfer
 modes and you are connecting over a remote session then image display will not
-work.
+work. :italic:`sixel` means to draw the images using sixel graphics, which is
+used automatically in terminals that do not support the kitty graphics
+protocol, but support sixel graphics. Sixel images are of lower quality and
+are not animated.
 
 
 --detect-support
@@ -102,6 +105,16 @@
 it to be installed on the system.
 
 
+--max-memory
+default=1G
+validator=cli.ByteSize()
+The maximum amount of memory to use for decoding an image, for example:
+:code:`512M` or :code:`2G`. Images too large to be decoded by the builtin
+engine within this limit are decoded by ImageMagick instead, which scales them
+down while decoding and keeps its memory use within this limit, if it is
+installed. A value of zero means no limit.
+
+
 --z-index -z
 default=0
 Z-index of the image. When negative, text will be displayed on top of the image.
@@ -117,6 +130,14 @@
 is looped the specified number of times.
 
 
+--page
+default=1
+type=int
+The page to display when displaying PDF documents. PDF pages are rendered
+using :program:`pdftoppm` from poppler or :program:`mutool` from MuPDF,
+whichever is installed.
+
+
 --hold
 type=bool-set
 Wait for a key press before exiting after displaying the images.
@@ -102,7 +102,13 @@ def a(k: str, val: str = '', prefix: str = 'export') -> None:
     return '\n'.join(lines).encode('utf-8')
 
 
-def make_tarfile(ssh_opts: SSHOptions, base_env: Dict[str, str], compression: str = 'gz', literal_env: Dict[str, str] = {}) -> bytes:
+# Shell integration features turned off for hosts with a high round trip time
+high_latency_ksi_features = ('no-complete', 'no-cwd')
+
+
+def make_tarfile(
+    ssh_opts: SSHOptions, base_env: Dict[str, str], compression: str = 'gz', literal_env: Dict[str, str] = {}, high_latency: bool = False
+) -> bytes:
 
     def normalize_tarinfo(tarinfo: tarfile.TarInfo) -> tarfile.TarInfo:
         tarinfo.uname = tarinfo.gname = ''
@@ -142,6 +148,9 @@ def filter(tarinfo: tarfile.TarInfo) -> Optional[tarfile.TarInfo]:
         from kitty.options.types import Options
         from kitty.options.utils import shell_integration
         ksi = get_effective_ksi_env_var(Options({'shell_integration': shell_integration(ssh_opts.shell_integration)}))
+    if ksi and high_latency:
+        features = ksi.split()
+        ksi = ' '.join(features + [x for x in high_latency_ksi_features if x not in features])
 
     env = {
         'TERM': os.environ.get('TERM') or kitty_opts().term,
@@ -174,10 +183,13 @@ def filter(tarinfo: tarfile.TarInfo) -> Optional[tarfile.TarInfo]:
             tf.add(f'{shell_integration_dir}/ssh/bootstrap-utils.sh', arcname='bootstrap-utils.sh', filter=normalize_tarinfo)
         if ksi:
             arcname = 'home/' + rd + '/shell-integration'
-            tf.add(shell_integration_dir, arcname=arcname, filter=filter_from_globs(
+            excluded = [
                 f'{arcname}/ssh/*',          # bootstrap files are sent as command line args
                 f'{arcname}/zsh/kitty.zsh',  # present for legacy compat not needed by ssh kitten
-            ))
+            ]
+            if 'no-complete' in ksi.split():
+                excluded += [f'{arcname}/*/completions/*', f'{arcname}/*/vendor_completions.d/*']
+            tf.add(shell_integration_dir, arcname=arcname, filter=filter_from_globs(*excluded))
         if ssh_opts.remote_kitty != 'no':
             arcname = 'home/' + rd + '/kitty'
             add_data_as_file(tf, arcname + '/version', str_version.encode('ascii'))
@@ -265,7 +277,7 @@ def prepare_export_home_cmd(ssh_opts: SSHOptions, is_python: bool) -> str:
 def bootstrap_script(
     ssh_opts: SSHOptions, script_type: str = 'sh', remote_args: Sequence[str] = (),
     test_script: str = '', request_id: Optional[str] = None, cli_hostname: str = '', cli_uname: str = '',
-    request_data: bool = False, echo_on: bool = True, literal_env: Dict[str, str] = {}
+    request_data: bool = False, echo_on: bool = True, literal_env: Dict[str, str] = {}, high_latency: bool = False
 ) -> Tuple[str, Dict[str, str], str]:
     if request_id is None:
         request_id = os.environ['KITTY_PID'] + '-' + os.environ['KITTY_WINDOW_ID']
@@ -275,7 +287,8 @@ def bootstrap_script(
     with open(os.path.join(shell_integration_dir, 'ssh', f'bootstrap.{script_type}')) as f:
         ans = f.read()
     pw = secrets.token_hex()
-    tfd = standard_b64encode(make_tarfile(ssh_opts, dict(os.environ), 'gz' if script_type == 'sh' else 'bz2', literal_env=literal_env)).decode('ascii')
+    tfd = standard_b64encode(make_tarfile(
+        ssh_opts, dict(os.environ), 'gz' if script_type == 'sh' else 'bz2', literal_env=literal_env, high_latency=high_latency)).decode('ascii')
     data = {'pw': pw, 'opts': ssh_opts._asdict(), 'hostname': cli_hostname, 'uname': cli_uname, 'tarfile': tfd}
     shm_name = create_shared_memory(data, prefix=f'kssh-{os.getpid()}-')
     sensitive_data = {'REQUEST_ID': request_id, 'DATA_PASSWORD': pw, 'PASSWORD_FILENAME': shm_name}
@@ -495,17 +508,44 @@ def wrap_bootstrap_script(sh_script: str, interpreter: str) -> List[str]:
 
 def get_remote_command(
     remote_args: List[str], ssh_opts: SSHOptions, cli_hostname: str = '', cli_uname: str = '',
-    echo_on: bool = True, request_data: bool = False, literal_env: Dict[str, str] = {}
+    echo_on: bool = True, request_data: bool = False, literal_env: Dict[str, str] = {}, high_latency: bool = False
 ) -> Tuple[List[str], Dict[str, str], str]:
     interpreter = ssh_opts.interpreter
     q = os.path.basename(interpreter).lower()
     is_python = 'python' in q
     sh_script, replacements, shm_name = bootstrap_script(
         ssh_opts, script_type='py' if is_python else 'sh', remote_args=remote_args, literal_env=literal_env,
-        cli_hostname=cli_hostname, cli_uname=cli_uname, echo_on=echo_on, request_data=request_data)
+        cli_hostname=cli_hostname, cli_uname=cli_uname, echo_on=echo_on, request_data=request_data, high_latency=high_latency)
     return wrap_bootstrap_script(sh_script, interpreter), replacements, shm_name
 
 
+container_runtimes = ('docker', 'podman', 'kubectl')
+# The name is used in the command line run by the remote shell, so only allow
+# the characters valid in container and pod names
+container_name_pat = re.compile(r'[a-zA-Z0-9][a-zA-Z0-9_.-]*')
+
+
+def container_exec_command(container: str, interactive: bool = True) -> List[str]:
+    runtime, sep, name = container.partition(':')
+    if not sep:
+        runtime, name = 'docker', container
+    if runtime not in container_runtimes:
+        raise ValueError(f'The container runtime {runtime} is not one of: {", ".join(container_runtimes)}')
+    if not name:
+        raise ValueError(f'No container name specified in: {container}')
+    if container_name_pat.fullmatch(name) is None:
+        raise ValueError(f'The container name {name} is not valid, it must start with a letter or number and contain only letters, numbers, underscores, periods and hyphens')
+    ans = [runtime, 'exec', '-it' if interactive else '-i', name]
+    if runtime == 'kubectl':
+        ans.append('--')
+    return ans
+
+
+def run_in_container(rcmd: List[str], container: str, interactive: bool = True) -> List[str]:
+    # rcmd is of the form: exec interpreter args...
+    return rcmd[:1] + container_exec_command(container, interactive) + rcmd[1:]
+
+
 def connection_sharing_args(kitty_pid: int) -> List[str]:
     rd = runtime_dir()
     # Bloody OpenSSH generates a 40 char hash and in creating the socket
@@ -638,6 +678,36 @@ def change_colors(color_scheme: str) -> bool:
     return True
 
 
+# The time in seconds taken to open a TCP connection to the SSH server, which
+# is one round trip, or None if it cannot be measured
+def measure_round_trip_time(ssh_args: List[str], hostname: str, timeout: float) -> Optional[float]:
+    import socket
+    cp = subprocess.run([ssh_exe()] + ssh_args + ['-G', '--', hostname], stdout=subprocess.PIPE, stderr=subprocess.DEVNULL)
+    if cp.returncode != 0:
+        return None
+    config: Dict[str, str] = {}
+    for line in cp.stdout.decode('utf-8', 'replace').splitlines():
+        key, sep, val = line.partition(' ')
+        if sep:
+            config[key.lower()] = val.strip()
+    if config.get('proxycommand', 'none') != 'none' or config.get('proxyjump', 'none') != 'none':
+        return None
+    try:
+        # resolve the address first so that DNS lookups are not measured
+        addr = socket.getaddrinfo(config.get('hostname', hostname), int(config.get('port', '22')), type=socket.SOCK_STREAM)[0][4]
+    except (OSError, ValueError, IndexError):
+        return None
+    st = time.monotonic()
+    try:
+        with socket.create_connection(addr[:2], timeout=timeout):
+            pass
+    except socket.timeout:
+        return timeout
+    except OSError:
+        return None
+    return time.monotonic() - st
+
+
 def add_cloned_env(shm_name: str) -> Dict[str, str]:
     try:
         return cast(Dict[str, str], read_data_from_shared_memory(shm_name))
@@ -670,6 +740,9 @@ def run_ssh(ssh_args: List[str], server_args: List[str], found_extra_args: Tuple
     pat = re.compile(r'^([a-zA-Z0-9_]+)[ \t]*=')
     for i, a in enumerate(found_extra_args):
         if i % 2 == 1:
+            if found_extra_args[i-1] == '--container':
+                overrides.append(f'container {a}')
+                continue
             aq = pat.sub(r'\1 ', a.lstrip())
             key = aq.split(maxsplit=1)[0]
             if key == 'clone_env':
@@ -693,14 +766,34 @@ def run_ssh(ssh_args: List[str], server_args: List[str], found_extra_args: Tuple
             need_to_request_data = False
             os.environ['SSH_ASKPASS_REQUIRE'] = 'force'
         os.environ['SSH_ASKPASS'] = os.path.join(shell_integration_dir, 'ssh', 'askpass.py')
-    if need_to_request_data and host_opts.share_connections:
-        cp = subprocess.run(cmd[:1] + ['-O', 'check'] + cmd[1:], stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
-        if cp.returncode == 0:
-            # we will use the master connection so SSH does not need to use the tty
-            need_to_request_data = False
+    master_connection_exists: Optional[bool] = None
+
+    def has_master_connection() -> bool:
+        nonlocal master_connection_exists
+        if master_connection_exists is None:
+            cp = subprocess.run(cmd[:1] + ['-O', 'check'] + cmd[1:], stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
+            master_connection_exists = cp.returncode == 0
+        return master_connection_exists
+
+    if need_to_request_data and host_opts.share_connections and has_master_connection():
+        # we will use the master connection so SSH does not need to use the tty
+        need_to_request_data = False
+    high_latency = False
+    # when re-using a master connection there is no connection setup cost, so
+    # dont pay for a probe
+    if host_opts.latency_threshold > 0 and not (host_opts.share_connections and has_master_connection()):
+        threshold = host_opts.latency_threshold / 1000
+        rtt = measure_round_trip_time(ssh_args, hostname, timeout=2 * threshold)
+        high_latency = rtt is not None and rtt > threshold
     with restore_terminal_state() as echo_on:
         rcmd, replacements, shm_name = get_remote_command(
-            remote_args, host_opts, hostname_for_match, uname, echo_on, request_data=need_to_request_data, literal_env=literal_env)
+            remote_args, host_opts, hostname_for_match, uname, echo_on, request_data=need_to_request_data, literal_env=literal_env,
+            high_latency=high_latency)
+        if host_opts.container:
+            try:
+                rcmd = run_in_container(rcmd, host_opts.container, interactive=not remote_args)
+            except ValueError as e:
+                raise SystemExit(str(e))
         cmd += rcmd
         colors_changed = change_colors(host_opts.color_scheme)
         try:
@@ -721,7 +814,7 @@ def main(args: List[str]) -> None:
     if args and args[0] == 'use-python':
         args = args[1:]  # backwards compat from when we had a python implementation
     try:
-        ssh_args, server_args, passthrough, found_extra_args = parse_ssh_args(args, extra_args=('--kitten',))
+        ssh_args, server_args, passthrough, found_extra_args = parse_ssh_args(args, extra_args=('--kitten', '--container'))
     except InvalidSSHArgs as e:
         e.system_exit()
     if passthrough:
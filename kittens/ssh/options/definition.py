@@ -125,10 +125,38 @@
 installed kitty can be updated by running: :code:`kitty +update-kitty` on the
 remote host.
 ''')
+
+opt('container', '', long_text='''
+Run the shell inside a container on the remote host, instead of on the host
+itself, so that shell integration and the other features of this kitten work
+inside the container. Of the form :code:`[runtime:]name` where :code:`runtime`
+is one of :code:`docker` (the default), :code:`podman` or :code:`kubectl` and
+:code:`name` is the name of the container or, for :code:`kubectl`, the pod,
+consisting of letters, numbers, underscores, periods and hyphens. For
+example: :code:`docker:web` or :code:`kubectl:my-pod`. The :opt:`interpreter
+<kitten-ssh.interpreter>` must be available inside the container and the files
+needed by this kitten are installed in the container. Can also be specified on
+the command line with :code:`--container`.
+''')
 egr()  # }}}
 
 agr('ssh', 'SSH configuration')  # {{{
 
+opt('latency_threshold', '0', option_type='positive_float', long_text='''
+When the round trip time to the remote host, in milliseconds, is larger than
+this value, the shell integration features that cause the most traffic, such as
+completions and reporting of the current working directory, are turned off, to
+keep the connection responsive. The round trip time is measured before
+connecting, which adds up to twice this value to the connection time, so it is
+off by default. A value of zero means the round trip time is not measured and
+all features configured in :opt:`shell_integration
+<kitten-ssh.shell_integration>` are used. For example, to turn off the heavy
+features for hosts slower than a quarter of a second, use:
+:code:`latency_threshold 250`. Note that the round trip time is not measured
+when connecting via a proxy or when re-using an existing shared connection, in
+which case all features are used.
+''')
+
 opt('share_connections', 'yes', option_type='to_bool', long_text='''
 Within a single kitty instance, all connections to a particular server can be
 shared. This reduces startup latency for subsequent connections and means that
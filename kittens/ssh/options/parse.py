@@ -3,7 +3,7 @@
 # isort: skip_file
 import typing
 from kittens.ssh.options.utils import copy, env, hostname
-from kitty.conf.utils import merge_dicts, to_bool
+from kitty.conf.utils import merge_dicts, positive_float, to_bool
 
 
 class Parser:
@@ -19,6 +19,9 @@ def askpass(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
     def color_scheme(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         ans['color_scheme'] = str(val)
 
+    def container(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
+        ans['container'] = str(val)
+
     def copy(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         for k, v in copy(val, ans["copy"]):
             ans["copy"][k] = v
@@ -36,6 +39,9 @@ def hostname(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
     def interpreter(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         ans['interpreter'] = str(val)
 
+    def latency_threshold(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
+        ans['latency_threshold'] = positive_float(val)
+
     def login_shell(self, val: str, ans: typing.Dict[str, typing.Any]) -> None:
         ans['login_shell'] = str(val)
 
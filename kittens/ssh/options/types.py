@@ -14,11 +14,13 @@
 option_names = (  # {{{
  'askpass',
  'color_scheme',
+ 'container',
  'copy',
  'cwd',
  'env',
  'hostname',
  'interpreter',
+ 'latency_threshold',
  'login_shell',
  'remote_dir',
  'remote_kitty',
@@ -29,9 +31,11 @@
 class Options:
     askpass: choices_for_askpass = 'unless-set'
     color_scheme: str = ''
+    container: str = ''
     cwd: str = ''
     hostname: str = '*'
     interpreter: str = 'sh'
+    latency_threshold: float = 0
     login_shell: str = ''
     remote_dir: str = '.local/share/kitty-ssh-kitten'
     remote_kitty: choices_for_remote_kitty = 'if-needed'
@@ -3,6 +3,7 @@
 
 
 import os
+import shlex
 import subprocess
 from typing import Any, Dict, List, Sequence
 
@@ -76,6 +77,18 @@ def set_cwd_in_cmdline(cwd: str, argv: List[str]) -> None:
     patch_cmdline('cwd', cwd, argv)
 
 
+def set_remote_command_in_cmdline(cmd: Sequence[str], argv: List[str]) -> None:
+    from .main import parse_ssh_args
+    idx = argv.index('ssh')
+    server_args = parse_ssh_args(argv[idx+1:], extra_args=('--kitten', '--container'))[1]
+    if len(server_args) > 1:
+        del argv[-(len(server_args) - 1):]
+    # the remote command is run by the login shell of the remote user, and
+    # needs a tty, which ssh does not allocate by default when running commands
+    argv.insert(idx + 1, '-t')
+    argv.append(' '.join(map(shlex.quote, cmd)))
+
+
 def create_shared_memory(data: Any, prefix: str) -> str:
     import atexit
     import json
@@ -56,6 +56,51 @@ def option_text() -> str:
 update it to match the file on the sending side, potentially saving lots of
 bandwidth and also automatically resuming partial transfers. Note that this will
 actually degrade performance on fast links with small files, so use with care.
+
+
+--sandbox-root
+When receiving files, refuse to write anything outside the specified directory.
+All paths are resolved, including through symlinks created earlier in the same
+transfer, and the transfer is aborted if any of them would end up outside this
+directory. Useful as protection against a malicious sender.
+
+
+--background
+type=bool-set
+Run the transfer in a new kitty tab that does not take the keyboard focus, so
+that you can continue using the current window. The transfer can be monitored
+and canceled using :option:`--status` and :option:`--cancel`. Needs
+:opt:`allow_remote_control` to be enabled. When running over SSH, the
+:doc:`ssh kitten </kittens/ssh>` must be used, so that the new tab can connect
+to the same computer. Since the confirmation popup is shown in the new tab, you
+might want to use :option:`--permissions-bypass` as well. Cannot be used with
+:option:`--confirm-paths`.
+
+
+--status
+type=bool-set
+Print the status of all transfers currently in progress on this computer and
+exit. The state of running transfers is stored in the kitty runtime directory.
+
+
+--cancel
+Cancel the transfer with the specified id, as shown by :option:`--status`, and
+exit. Useful to stop a transfer running in another window.
+
+
+--log
+type=bool-set
+List previous transfers, with their files, sizes, durations and whether they
+succeeded, and exit. Any command line arguments are used to filter the list, only
+transfers whose direction, status, working directory or files contain all of
+them are shown.
+
+
+--repeat
+Run the transfer with the specified id, as shown by :option:`--log`, again. It is
+run in the same working directory, with the same files and the same
+:option:`--direction`, :option:`--mode`, :option:`--transmit-deltas` and
+:option:`--sandbox-root` options.
 '''
 
 
@@ -82,11 +127,58 @@ def read_bypass(loc: str) -> str:
     return loc
 
 
+def run_in_background(cli_opts: TransferCLIOptions, args: List[str]) -> None:
+    import shlex
+    import subprocess
+
+    from kitty.constants import kitten_exe, kitty_exe
+    if cli_opts.confirm_paths:
+        raise SystemExit('Cannot confirm paths when running the transfer in the background')
+    loc = cli_opts.permissions_bypass
+    if loc == '-' or (loc.isdigit() and int(loc) < 256):
+        raise SystemExit('Cannot read the password from STDIN or a file descriptor when running the transfer in the background')
+    cmd = [kitty_exe(), '+kitten', 'transfer'] + [x for x in args[1:] if x != '--background']
+    # run over the TTY, the ssh kitten takes care of running cmd on the
+    # remote computer if the current window is connected to one
+    rc = [
+        kitten_exe(), '@', 'launch', '--type=tab', '--keep-focus', '--ssh', '--cwd', os.getcwd(),
+        '--tab-title', 'transfer: ' + ' '.join(map(shlex.quote, cmd[3:])), '--'] + cmd
+    cp = subprocess.run(rc, stdout=subprocess.DEVNULL)
+    if cp.returncode != 0:
+        raise SystemExit(cp.returncode)
+    print('Transfer started in a new tab, use --status to check on its progress')
+
+
 def main(args: List[str]) -> None:
     cli_opts, items = parse_transfer_args(args)
+    if cli_opts.background and not cli_opts.status and not cli_opts.cancel and not cli_opts.log:
+        run_in_background(cli_opts, args)
+        return
     if cli_opts.permissions_bypass:
         cli_opts.permissions_bypass = read_bypass(cli_opts.permissions_bypass).strip()
 
+    if cli_opts.status:
+        from .status import print_status
+        print_status()
+        return
+    if cli_opts.cancel:
+        from .status import cancel_transfer
+        cancel_transfer(cli_opts.cancel)
+        return
+    if cli_opts.log:
+        from .status import print_history
+        print_history(items)
+        return
+    if cli_opts.repeat:
+        from .status import transfer_for_repeat
+        t = transfer_for_repeat(cli_opts.repeat)
+        try:
+            os.chdir(t['cwd'])
+        except OSError as err:
+            raise SystemExit(f'Cannot change to the working directory of the transfer: {t["cwd"]} with error: {err}')
+        cli_opts.direction, cli_opts.mode, cli_opts.transmit_deltas = t['direction'], t['mode'], t['transmit_deltas']
+        cli_opts.sandbox_root, items = t['sandbox_root'], t['args']
+
     if not items:
         raise SystemExit('Usage: kitty +kitten transfer file_or_directory ...')
     if cli_opts.direction == 'send':
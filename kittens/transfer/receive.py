@@ -35,12 +35,33 @@
 from ..tui.utils import human_size
 from .librsync import PatchFile, signature_of_file
 from .send import Transfer
+from .status import TransferStatus, record_transfer
 from .utils import expand_home, print_rsync_stats, random_id, render_progress_in_width, safe_divide, should_be_compressed
 
 debug
 file_counter = count(1)
 
 
+class PathOutsideSandbox(ValueError):
+    pass
+
+
+def ensure_inside_sandbox(root: str, path: str, follow_final_symlink: bool = True) -> str:
+    '''
+    Return the resolved path if it is inside the directory root, following symlinks
+    in all its parent directories and, optionally, the final component, so that
+    symlinks created earlier in the same transfer cannot be used to write outside
+    root. root must already be resolved. Raises PathOutsideSandbox otherwise.
+    '''
+    parent, name = os.path.split(os.path.abspath(path))
+    if name in ('', os.curdir, os.pardir):
+        raise PathOutsideSandbox(f'Refusing to write to invalid path: {path}')
+    resolved = os.path.realpath(os.path.join(os.path.realpath(parent), name)) if follow_final_symlink else os.path.join(os.path.realpath(parent), name)
+    if resolved != root and os.path.commonpath((root, resolved)) != root:
+        raise PathOutsideSandbox(f'Refusing to write to {path} as it is outside {root}')
+    return resolved
+
+
 class State(NameReprEnum):
     waiting_for_permission = auto()
     waiting_for_file_metadata = auto()
@@ -72,6 +93,7 @@ def __init__(self, ftc: FileTransmissionCommand):
         self.decompressor: Union[ZlibDecompressor, IdentityDecompressor] = ZlibDecompressor() if compression_capable else IdentityDecompressor()
         self.remote_symlink_value = b''
         self.actual_file: Union[None, PatchFile, IO[bytes]] = None
+        self.sandbox_root = ''
 
     def __repr__(self) -> str:
         return f'File(rpath={self.remote_path!r}, lpath={self.expanded_local_path!r})'
@@ -81,6 +103,10 @@ def close(self) -> None:
             self.actual_file.close()
             self.actual_file = None
 
+    def check_path(self, path: str = '', follow_final_symlink: bool = True) -> None:
+        if self.sandbox_root:
+            ensure_inside_sandbox(self.sandbox_root, path or self.expanded_local_path, follow_final_symlink)
+
     def write_data(self, data: bytes, is_last: bool) -> int:
         self.received_bytes += len(data)
         data = self.decompressor(data, is_last)
@@ -91,7 +117,9 @@ def write_data(self, data: bytes, is_last: bool) -> int:
             if self.actual_file is None:
                 parent = os.path.dirname(self.expanded_local_path)
                 if parent:
+                    self.check_path(parent)
                     os.makedirs(parent, exist_ok=True)
+                self.check_path()
                 self.actual_file = PatchFile(self.expanded_local_path) if self.expect_diff else open(self.expanded_local_path, 'wb')
             base = self.actual_file.tell()
             if data:
@@ -104,6 +132,7 @@ def write_data(self, data: bytes, is_last: bool) -> int:
         return 0
 
     def apply_metadata(self) -> None:
+        self.check_path(follow_final_symlink=self.ftype is not FileType.symlink)
         if self.ftype is FileType.symlink:
             with suppress(NotImplementedError):
                 os.chmod(self.expanded_local_path, self.permissions, follow_symlinks=False)
@@ -231,7 +260,7 @@ class Manager:
 
     def __init__(
         self, request_id: str, spec: List[str], dest: str,
-        bypass: Optional[str] = None, use_rsync: bool = False
+        bypass: Optional[str] = None, use_rsync: bool = False, sandbox_root: str = ''
     ):
         self.request_id = request_id
         self.spec = spec
@@ -247,6 +276,7 @@ def __init__(
         self.progress_tracker = ProgressTracker()
         self.transfer_done = False
         self.use_rsync = use_rsync
+        self.sandbox_root = sandbox_root
 
     @property
     def finish_code(self) -> str:
@@ -262,6 +292,10 @@ def finalize_transfer(self) -> str:
         self.transfer_done = True
         rid_map = {f.remote_id: f for f in self.files}
         for f in self.files:
+            try:
+                f.check_path(follow_final_symlink=f.ftype is FileType.directory)
+            except PathOutsideSandbox as err:
+                return str(err)
             if f.ftype is FileType.directory:
                 try:
                     os.makedirs(f.expanded_local_path, exist_ok=True)
@@ -271,6 +305,10 @@ def finalize_transfer(self) -> str:
                 target = rid_map.get(f.remote_target)
                 if target is None:
                     return f'Hard link with remote id: {f.remote_target} not found'
+                try:
+                    f.check_path(target.expanded_local_path)
+                except PathOutsideSandbox as err:
+                    return str(err)
                 try:
                     os.makedirs(os.path.dirname(f.expanded_local_path), exist_ok=True)
                     with suppress(FileNotFoundError):
@@ -323,11 +361,19 @@ def request_files(self) -> Iterator[str]:
                         yield data.serialize()
                 yield FileTransmissionCommand(file_id=f.file_id, action=Action.end_data).serialize()
 
-    def collect_files(self, cli_opts: TransferCLIOptions) -> None:
+    def collect_files(self, cli_opts: TransferCLIOptions) -> str:
         self.files = list(files_for_receive(cli_opts, self.dest, self.files, self.remote_home, self.spec))
+        if self.sandbox_root:
+            for f in self.files:
+                f.sandbox_root = self.sandbox_root
+                try:
+                    f.check_path(follow_final_symlink=False)
+                except PathOutsideSandbox as err:
+                    return str(err)
         self.files_to_be_transferred = {f.file_id: f for f in self.files if f.ftype not in (FileType.directory, FileType.link)}
         self.progress_tracker.total_size_of_all_files = sum(max(0, f.expected_size) for f in self.files_to_be_transferred.values())
         self.progress_tracker.total_bytes_to_transfer = self.progress_tracker.total_size_of_all_files
+        return ''
 
     def on_file_transfer_response(self, ftc: FileTransmissionCommand) -> str:
         if self.state is State.waiting_for_permission:
@@ -389,7 +435,9 @@ class Receive(Handler):
 
     def __init__(self, cli_opts: TransferCLIOptions, spec: List[str], dest: str = ''):
         self.cli_opts = cli_opts
-        self.manager = Manager(random_id(), spec, dest, bypass=cli_opts.permissions_bypass, use_rsync=cli_opts.transmit_deltas)
+        self.manager = Manager(
+            random_id(), spec, dest, bypass=cli_opts.permissions_bypass, use_rsync=cli_opts.transmit_deltas,
+            sandbox_root=os.path.realpath(expand_home(cli_opts.sandbox_root)) if cli_opts.sandbox_root else '')
         self.quit_after_write_code: Optional[int] = None
         self.check_paths_printed = False
         self.transmit_started = False
@@ -398,6 +446,7 @@ def __init__(self, cli_opts: TransferCLIOptions, spec: List[str], dest: str = ''
         self.progress_update_call: Optional[TimerHandle] = None
         self.progress_drawn = False
         self.transmit_iterator: Optional[Iterator[str]] = None
+        self.status = TransferStatus(self.manager.request_id, 'receive')
 
     def send_payload(self, payload: str) -> None:
         self.write(self.manager.prefix)
@@ -440,7 +489,12 @@ def on_file_transfer_response(self, ftc: FileTransmissionCommand) -> None:
                 self.print_err('No matches found for: ' + ', '.join(self.manager.spec[k] for k, v in self.manager.spec_counts.items() if v == 0))
                 self.quit_loop(1)
                 return
-            self.manager.collect_files(self.cli_opts)
+            err = self.manager.collect_files(self.cli_opts)
+            if err:
+                self.print_err(err)
+                self.print('Waiting to ensure terminal cancels transfer, will quit in a few seconds')
+                self.abort_transfer()
+                return
             if self.cli_opts.confirm_paths:
                 self.confirm_paths()
             else:
@@ -606,6 +660,9 @@ def draw_progress(self) -> None:
             else:
                 sc = self.spinner()
             p = self.manager.progress_tracker
+            self.status.update(
+                bytes_so_far=p.total_transferred, total_bytes=p.total_bytes_to_transfer,
+                files_done=sum(1 for f in self.manager.files if f.done_at), total_files=len(self.manager.files))
             now = monotonic()
             if is_complete:
                 self.cmd.repeat('─', self.screen_size.width)
@@ -652,7 +709,11 @@ def receive_main(cli_opts: TransferCLIOptions, args: List[str]) -> None:
 
     loop = Loop()
     handler = Receive(cli_opts, spec, dest)
-    loop.loop(handler)
+    try:
+        loop.loop(handler)
+    finally:
+        handler.status.remove()
+        record_transfer(cli_opts, args, handler.status, loop.return_code == 0)
     for f in handler.manager.files:
         f.close()
     tsf = dsz = ssz = 0
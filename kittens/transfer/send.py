@@ -23,6 +23,7 @@
 from ..tui.spinners import Spinner
 from ..tui.utils import human_size
 from .librsync import LoadSignature, delta_for_file
+from .status import TransferStatus, record_transfer
 from .utils import (
     IdentityCompressor,
     ZlibCompressor,
@@ -458,6 +459,7 @@ def __init__(self, cli_opts: TransferCLIOptions, files: Tuple[File, ...]):
         self.failed_files: List[File] = []
         self.transmit_ok_checked = False
         self.progress_update_call: Optional[TimerHandle] = None
+        self.status = TransferStatus(self.manager.request_id, 'send', len(files))
 
     def send_payload(self, payload: str) -> None:
         self.write(self.manager.prefix)
@@ -680,6 +682,8 @@ def draw_progress(self) -> None:
             else:
                 sc = self.spinner()
             p = self.manager.progress
+            self.status.update(
+                bytes_so_far=p.total_reported_progress, total_bytes=p.total_bytes_to_transfer, files_done=len(self.done_file_ids))
             now = monotonic()
             if is_complete:
                 self.cmd.repeat('─', self.screen_size.width)
@@ -734,7 +738,11 @@ def send_main(cli_opts: TransferCLIOptions, args: List[str]) -> None:
     print(f'Found {len(files)} files and directories, requesting transfer permission…')
     loop = Loop()
     handler = Send(cli_opts, files)
-    loop.loop(handler)
+    try:
+        loop.loop(handler)
+    finally:
+        handler.status.remove()
+        record_transfer(cli_opts, args, handler.status, loop.return_code == 0 and not handler.failed_files)
     p = handler.manager.progress
     if handler.manager.has_rsync and p.total_transferred + p.signature_bytes:
         tsf = 0
@@ -0,0 +1,171 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+import errno
+import json
+import os
+import signal
+import time
+from contextlib import suppress
+from typing import TYPE_CHECKING, Any, Dict, List, Sequence
+
+from ..tui.operations import styled
+from ..tui.utils import human_size
+from .utils import safe_divide
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import TransferCLIOptions
+
+# the number of completed transfers remembered by --log
+max_history_size = 100
+
+
+def state_dir() -> str:
+    from kitty.constants import runtime_dir
+    ans = os.path.join(runtime_dir(), 'kitty-transfers')
+    os.makedirs(ans, mode=0o700, exist_ok=True)
+    return ans
+
+
+def is_process_alive(pid: int) -> bool:
+    try:
+        os.kill(pid, 0)
+    except OSError as err:
+        return err.errno == errno.EPERM
+    return True
+
+
+class TransferStatus:
+
+    ''' The state of a running transfer, persisted in the runtime directory so
+    that it can be queried and canceled from other terminals. '''
+
+    update_interval = 1.
+
+    def __init__(self, request_id: str, direction: str, total_files: int = 0):
+        self.path = os.path.join(state_dir(), f'{request_id}.json')
+        self.data: Dict[str, Any] = {
+            'id': request_id, 'pid': os.getpid(), 'direction': direction, 'cwd': os.getcwd(),
+            'started_at': time.time(), 'bytes_so_far': 0, 'total_bytes': 0,
+            'files_done': 0, 'total_files': total_files,
+        }
+        self.last_saved_at = 0.
+        self.save()
+
+    def save(self) -> None:
+        from kitty.config import atomic_save
+        self.last_saved_at = time.monotonic()
+        with suppress(OSError):
+            atomic_save(json.dumps(self.data).encode('utf-8'), self.path)
+
+    def update(self, force: bool = False, **kw: Any) -> None:
+        self.data.update(kw)
+        if force or time.monotonic() - self.last_saved_at >= self.update_interval:
+            self.save()
+
+    def remove(self) -> None:
+        with suppress(FileNotFoundError):
+            os.remove(self.path)
+
+
+def active_transfers() -> List[Dict[str, Any]]:
+    ans: List[Dict[str, Any]] = []
+    base = state_dir()
+    for x in os.listdir(base):
+        if not x.endswith('.json'):
+            continue
+        path = os.path.join(base, x)
+        try:
+            with open(path) as f:
+                data = json.load(f)
+        except (OSError, ValueError):
+            continue
+        if is_process_alive(data.get('pid', 0)):
+            ans.append(data)
+        else:
+            # the transfer process died without cleaning up
+            with suppress(OSError):
+                os.remove(path)
+    ans.sort(key=lambda x: x.get('started_at', 0))
+    return ans
+
+
+def print_status() -> None:
+    transfers = active_transfers()
+    if not transfers:
+        print('No transfers are in progress')
+        return
+    now = time.time()
+    for t in transfers:
+        secs = now - t['started_at']
+        percent = safe_divide(t['bytes_so_far'], t['total_bytes']) * 100
+        print(styled(t['id'], fg='green'), styled(t['direction'], bold=True), 'in', t['cwd'])
+        print(f'  {t["files_done"]} of {t["total_files"]} files done', end=' ')
+        if t['total_bytes']:
+            print(f'{human_size(t["bytes_so_far"])} of {human_size(t["total_bytes"])} ({percent:.1f}%)', end=' ')
+        print(f'running for {int(secs)} seconds')
+
+
+def cancel_transfer(request_id: str) -> None:
+    for t in active_transfers():
+        if t['id'] == request_id:
+            os.kill(t['pid'], signal.SIGTERM)
+            print('Cancel requested for transfer:', request_id)
+            return
+    raise SystemExit(f'No transfer with id: {request_id} is in progress, use --status to list transfers')
+
+
+def history_path() -> str:
+    from kitty.constants import cache_dir
+    return os.path.join(cache_dir(), 'transfer-history.json')
+
+
+def load_history() -> List[Dict[str, Any]]:
+    try:
+        with open(history_path()) as f:
+            ans = json.load(f)
+    except (OSError, ValueError):
+        return []
+    return ans if isinstance(ans, list) else []
+
+
+def record_transfer(cli_opts: 'TransferCLIOptions', args: Sequence[str], status: TransferStatus, ok: bool) -> None:
+    from kitty.config import atomic_save
+    history = load_history()
+    d = status.data
+    history.append({
+        'id': max((x.get('id', 0) for x in history), default=0) + 1,
+        'direction': d['direction'], 'mode': cli_opts.mode, 'transmit_deltas': cli_opts.transmit_deltas,
+        'sandbox_root': cli_opts.sandbox_root, 'cwd': d['cwd'], 'args': list(args),
+        'started_at': d['started_at'], 'duration': time.time() - d['started_at'], 'status': 'ok' if ok else 'failed',
+        'files_done': d['files_done'], 'total_files': d['total_files'], 'bytes_so_far': d['bytes_so_far'], 'total_bytes': d['total_bytes'],
+    })
+    with suppress(OSError):
+        atomic_save(json.dumps(history[-max_history_size:], indent=2).encode('utf-8'), history_path())
+
+
+def print_history(filters: Sequence[str]) -> None:
+    import shlex
+    history = load_history()
+    if not history:
+        print('No transfers have been completed yet')
+        return
+    matches = [t for t in history if all(q in ' '.join((t['direction'], t['status'], t['cwd'], *t['args'])) for q in filters)]
+    if not matches:
+        print('No transfers match:', ' '.join(filters))
+    for t in matches:
+        when = time.strftime('%Y-%m-%d %H:%M', time.localtime(t['started_at']))
+        st = styled('✔', fg='green') if t['status'] == 'ok' else styled('✘ failed', fg='red')
+        print(styled(f'{t["id"]:>3}', fg='green'), when, styled(t['direction'], bold=True), st, 'in', t['cwd'])
+        print(f'    {t["files_done"]} of {t["total_files"]} files', end=' ')
+        if t['total_bytes']:
+            print(f'{human_size(t["bytes_so_far"])} of {human_size(t["total_bytes"])}', end=' ')
+        print(f'in {int(t["duration"])} seconds')
+        print('   ', ' '.join(map(shlex.quote, t['args'])))
+
+
+def transfer_for_repeat(transfer_id: str) -> Dict[str, Any]:
+    for t in load_history():
+        if str(t['id']) == transfer_id:
+            return t
+    raise SystemExit(f'No transfer with id: {transfer_id} found, use --log to list previous transfers')
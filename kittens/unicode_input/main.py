@@ -24,7 +24,7 @@
 from ..tui.operations import clear_screen, colored, cursor, faint, set_line_wrapping, set_window_title, sgr, styled
 from ..tui.utils import report_unhandled_error
 
-HEX, NAME, EMOTICONS, FAVORITES = 'HEX', 'NAME', 'EMOTICONS', 'FAVORITES'
+HEX, NAME, EMOTICONS, FAVORITES, SYMBOLS = 'HEX', 'NAME', 'EMOTICONS', 'FAVORITES', 'SYMBOLS'
 favorites_path = os.path.join(config_dir, 'unicode-input-favorites.conf')
 INDEX_CHAR = '.'
 INDEX_BASE = 36
@@ -40,6 +40,7 @@
     (_('Name'), 'F2', NAME),
     (_('Emoji'), 'F3', EMOTICONS),
     (_('Favorites'), 'F4', FAVORITES),
+    (_('Symbols'), 'F5', SYMBOLS),
 )
 
 
@@ -60,6 +61,20 @@ def name(cp: Union[int, str]) -> str:
     return (name_for_codepoint(c) or '').capitalize()
 
 
+def palette_entries_matching_search(palette_idx: int, words: Sequence[str]) -> Tuple[List[int], List[str]]:
+    from .palettes import all_palettes
+    codepoints: List[int] = []
+    mnemonics: List[str] = []
+    words = [w.lower() for w in words]
+    for ch, mnemonic in all_palettes()[palette_idx][1]:
+        q = mnemonic.lower()
+        if words and not all(w in q or w in name(ch).lower() for w in words):
+            continue
+        codepoints.append(ord(ch))
+        mnemonics.append(mnemonic)
+    return codepoints, mnemonics
+
+
 @lru_cache(maxsize=256)
 def codepoints_matching_search(parts: Tuple[str, ...]) -> List[int]:
     ans = []
@@ -147,6 +162,7 @@ def __init__(self, emoji_variation: str) -> None:
         self.num_cols = 0
         self.num_rows = 0
         self.mode = HEX
+        self.descriptions: Sequence[str] = ()
 
     @property
     def current_codepoint(self) -> Optional[int]:
@@ -154,9 +170,10 @@ def current_codepoint(self) -> Optional[int]:
             return self.codepoints[self.current_idx]
         return None
 
-    def set_codepoints(self, codepoints: List[int], mode: str = HEX, current_idx: int = 0) -> None:
+    def set_codepoints(self, codepoints: List[int], mode: str = HEX, current_idx: int = 0, descriptions: Sequence[str] = ()) -> None:
         self.codepoints = codepoints
         self.mode = mode
+        self.descriptions = descriptions
         self.layout_dirty = True
         self.current_idx = current_idx if current_idx < len(codepoints) else 0
         self.scroll_rows = 0
@@ -176,9 +193,10 @@ def safe_chr(codepoint: int) -> str:
                 ans += self.emoji_variation
             return ans
 
-        if self.mode is NAME:
+        if self.mode in (NAME, SYMBOLS):
             def as_parts(i: int, codepoint: int) -> Tuple[str, str, str]:
-                return encode_hint(i).ljust(idx_size), safe_chr(codepoint), name(codepoint)
+                desc = self.descriptions[i] if self.descriptions else name(codepoint)
+                return encode_hint(i).ljust(idx_size), safe_chr(codepoint), desc
 
             def cell(i: int, idx: str, c: str, desc: str) -> Generator[str, None, None]:
                 is_current = i == self.current_idx
@@ -216,7 +234,7 @@ def cell(i: int, idx: str, c: str, desc: str) -> Generator[str, None, None]:
         idx_size = len(encode_hint(num - 1))
 
         parts = [as_parts(i, c) for i, c in enumerate(self.codepoints)]
-        if self.mode is NAME:
+        if self.mode in (NAME, SYMBOLS):
             sizes = [idx_size + 2 + len(p[2]) + 2 for p in parts]
         else:
             sizes = [idx_size + 3]
@@ -292,6 +310,7 @@ def __init__(self, cached_values: Dict[str, Any], emoji_variation: str = 'none')
         self.last_updated_code_point_at: Optional[Tuple[str, Union[Sequence[int], None, str]]] = None
         self.choice_line = ''
         self.mode = globals().get(cached_values.get('mode', 'HEX'), 'HEX')
+        self.palette_idx = int(cached_values.get('palette', 0))
         self.table = Table(self.emoji_variation)
         self.update_prompt()
 
@@ -306,6 +325,7 @@ def resolved_current_char(self) -> Optional[str]:
     def update_codepoints(self) -> None:
         codepoints = None
         iindex_word = 0
+        mnemonics: Sequence[str] = ()
         if self.mode is HEX:
             q: Tuple[str, Optional[Union[str, Sequence[int]]]] = (self.mode, None)
             codepoints = self.recent
@@ -326,9 +346,15 @@ def update_codepoints(self) -> None:
                     words = words[:index_words[0]]
                     iindex_word = int(index_word.lstrip(INDEX_CHAR), INDEX_BASE)
                 codepoints = codepoints_matching_search(tuple(words))
+        elif self.mode is SYMBOLS:
+            from .palettes import all_palettes
+            self.palette_idx %= len(all_palettes())
+            q = self.mode, (self.palette_idx, self.line_edit.current_input)
+            if q != self.last_updated_code_point_at:
+                codepoints, mnemonics = palette_entries_matching_search(self.palette_idx, self.line_edit.current_input.split())
         if q != self.last_updated_code_point_at:
             self.last_updated_code_point_at = q
-            self.table.set_codepoints(codepoints or [], self.mode, iindex_word)
+            self.table.set_codepoints(codepoints or [], self.mode, iindex_word, mnemonics)
 
     def update_current_char(self) -> None:
         self.update_codepoints()
@@ -341,7 +367,7 @@ def update_current_char(self) -> None:
                 elif self.line_edit.current_input:
                     code = int(self.line_edit.current_input, 16)
                     self.current_char = chr(code)
-        elif self.mode is NAME:
+        elif self.mode in (NAME, SYMBOLS):
             cc = self.table.current_codepoint
             if cc:
                 self.current_char = chr(cc)
@@ -401,6 +427,12 @@ def writeln(text: str = '') -> None:
 
         if self.mode is NAME:
             writeln(_('Enter words from the name of the character'))
+        elif self.mode is SYMBOLS:
+            from .palettes import all_palettes
+            tabs = []
+            for i, palette in enumerate(all_palettes()):
+                tabs.append(styled(f' {palette[0]} ', reverse=True, bold=True) if i == self.palette_idx else f' {palette[0]} ')
+            writeln(_('Palette:') + ' ' + ' '.join(tabs))
         elif self.mode is HEX:
             writeln(_('Enter the hex code for the character'))
         else:
@@ -413,6 +445,8 @@ def writeln(text: str = '') -> None:
                 writeln(faint(_('Type {} followed by the index for the recent entries below').format(INDEX_CHAR)))
             elif self.mode is NAME:
                 writeln(faint(_('Use Tab or the arrow keys to choose a character from below')))
+            elif self.mode is SYMBOLS:
+                writeln(faint(_('Type to filter by mnemonic or name, Tab or the arrow keys to choose, Ctrl+Left/Right to switch palettes')))
             elif self.mode is FAVORITES:
                 writeln(faint(_('Press F12 to edit the list of favorites')))
             self.table_at = y
@@ -447,7 +481,12 @@ def on_key(self, key_event: KeyEvent) -> None:
                     self.line_edit.current_input = hex(val - 1)[2:]
                     self.refresh()
                     return
-        if self.mode is NAME:
+        if self.mode is SYMBOLS:
+            for key, delta in (('ctrl+left', -1), ('ctrl+right', 1)):
+                if key_event.matches(key):
+                    self.switch_palette(delta)
+                    return
+        if self.mode in (NAME, SYMBOLS):
             if key_event.matches('shift+tab'):
                 self.table.move_current(cols=-1)
                 self.refresh()
@@ -494,6 +533,9 @@ def on_key(self, key_event: KeyEvent) -> None:
         if key_event.matches_without_mods('f4') or key_event.matches('ctrl+4'):
             self.switch_mode(FAVORITES)
             return
+        if key_event.matches_without_mods('f5') or key_event.matches('ctrl+5'):
+            self.switch_mode(SYMBOLS)
+            return
         if key_event.matches_without_mods('f12') and self.mode is FAVORITES:
             self.edit_favorites()
             return
@@ -525,6 +567,13 @@ def switch_mode(self, mode: str) -> None:
             self.choice_line = ''
             self.refresh()
 
+    def switch_palette(self, delta: int) -> None:
+        from .palettes import all_palettes
+        self.palette_idx = (self.palette_idx + delta) % len(all_palettes())
+        self.cached_values['palette'] = self.palette_idx
+        self.line_edit.clear()
+        self.refresh()
+
     def next_mode(self, delta: int = 1) -> None:
         modes = tuple(x[-1] for x in all_modes)
         idx = (modes.index(self.mode) + delta + len(modes)) % len(modes)
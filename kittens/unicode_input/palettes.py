@@ -0,0 +1,86 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+from functools import lru_cache
+from typing import Tuple
+
+# Each palette is a list of characters and a mnemonic for each, used to find
+# the character by typing. Mnemonics follow LaTeX names where there is one.
+Palette = Tuple[str, Tuple[Tuple[str, str], ...]]
+
+
+def parse_palette(name: str, raw: str) -> Palette:
+    entries = []
+    for line in raw.strip().splitlines():
+        for item in line.split(','):
+            ch, mnemonic = item.split(maxsplit=1)
+            entries.append((ch, mnemonic.strip()))
+    return name, tuple(entries)
+
+
+@lru_cache(maxsize=2)
+def all_palettes() -> Tuple[Palette, ...]:
+    return (
+        parse_palette('Math', '''
+∀ forall, ∃ exists, ∄ nexists, ∅ emptyset, ∈ in, ∉ notin, ∋ ni, ∖ setminus
+∑ sum, ∏ prod, ∫ int, ∬ iint, ∮ oint, ∂ partial, ∇ nabla, ∆ increment
+± pm, ∓ mp, × times, ÷ div, ⋅ cdot, ∘ circ, √ sqrt, ∛ cbrt, ∝ propto, ∞ infty
+= eq, ≠ neq, ≡ equiv, ≈ approx, ∼ sim, ≃ simeq, ≅ cong, ≔ coloneq, < lt, > gt
+≤ leq, ≥ geq, ≪ ll, ≫ gg, ≺ prec, ≻ succ
+∧ wedge and, ∨ vee or, ¬ neg not, ⊕ oplus xor, ⊗ otimes, ⊤ top, ⊥ bot perp, ⊢ vdash, ⊨ models
+∴ therefore, ∵ because
+∩ cap intersection, ∪ cup union, ⊂ subset, ⊃ supset, ⊆ subseteq, ⊇ supseteq
+ℕ naturals, ℤ integers, ℚ rationals, ℝ reals, ℂ complex, ℵ aleph
+⌈ lceil, ⌉ rceil, ⌊ lfloor, ⌋ rfloor, ⟨ langle, ⟩ rangle
+° degree, ′ prime, ″ dprime, ∠ angle, ∥ parallel, ∣ divides
+α alpha, β beta, γ gamma, δ delta, ε epsilon, θ theta, λ lambda, μ mu, π pi, σ sigma, φ phi, ω omega
+'''),
+        parse_palette('Arrows', '''
+← leftarrow, → rightarrow to, ↑ uparrow, ↓ downarrow, ↔ leftrightarrow, ↕ updownarrow
+↖ nwarrow, ↗ nearrow, ↘ searrow, ↙ swarrow
+⇐ Leftarrow, ⇒ Rightarrow implies, ⇑ Uparrow, ⇓ Downarrow, ⇔ Leftrightarrow iff, ⇕ Updownarrow
+⟵ longleftarrow, ⟶ longrightarrow, ⟷ longleftrightarrow, ⟸ Longleftarrow, ⟹ Longrightarrow, ⟺ Longleftrightarrow
+↦ mapsto, ↤ mapsfrom, ↩ hookleftarrow, ↪ hookrightarrow, ↞ twoheadleftarrow, ↠ twoheadrightarrow
+↺ circlearrowleft ccw, ↻ circlearrowright cw, ⇄ rightleftarrows, ⇆ leftrightarrows, ⇌ rightleftharpoons
+↯ lightning, ⤴ curve up, ⤵ curve down, ➜ heavy right, ⬅ black left, ➡ black right, ⬆ black up, ⬇ black down
+'''),
+        parse_palette('Box drawing', '''
+─ horizontal, │ vertical, ┌ down right corner, ┐ down left corner, └ up right corner, ┘ up left corner
+├ vertical right tee, ┤ vertical left tee, ┬ down horizontal tee, ┴ up horizontal tee, ┼ cross
+━ heavy horizontal, ┃ heavy vertical, ┏ heavy down right, ┓ heavy down left, ┗ heavy up right, ┛ heavy up left
+┣ heavy vertical right, ┫ heavy vertical left, ┳ heavy down horizontal, ┻ heavy up horizontal, ╋ heavy cross
+═ double horizontal, ║ double vertical, ╔ double down right, ╗ double down left, ╚ double up right, ╝ double up left
+╠ double vertical right, ╣ double vertical left, ╦ double down horizontal, ╩ double up horizontal, ╬ double cross
+╭ arc down right, ╮ arc down left, ╯ arc up left, ╰ arc up right, ╱ diagonal rising, ╲ diagonal falling, ╳ diagonal cross
+┄ dashed horizontal, ┆ dashed vertical, ╌ double dash horizontal, ╎ double dash vertical
+█ full block, ▀ upper half block, ▄ lower half block, ▌ left half block, ▐ right half block
+░ light shade, ▒ medium shade, ▓ dark shade, ■ square, □ white square, ▪ small square
+'''),
+        parse_palette('APL', '''
+⍺ alpha left argument, ⍵ omega right argument, ⍳ iota index, ⍴ rho reshape, ⍸ where, ⍷ find
+⌊ floor minimum, ⌈ ceiling maximum, ∊ epsilon member, ≢ tally not match, ≡ match depth, ⍬ zilde empty
+⌽ reverse rotate, ⊖ reverse first, ⍉ transpose, ↑ take mix, ↓ drop split, ⊂ enclose, ⊃ disclose pick, ⊆ partition nest
+⍋ grade up, ⍒ grade down, ⌿ reduce first replicate, ⍀ scan first expand, ¨ each, ⍨ commute selfie
+∘ jot compose, ⍤ rank, ⍥ over, ⍣ power, ⍠ variant, ⌸ key, ⌺ stencil, ⌹ domino matrix divide
+⍎ execute, ⍕ format, ⋄ diamond statement separator, ⍝ lamp comment, ⎕ quad, ⍞ quote quad
+⊢ right tack same, ⊣ left tack, ⊤ encode, ⊥ decode, ∇ del, ⍟ log, ○ circle pi times
+⍲ nand, ⍱ nor, ∧ and lcm, ∨ or gcd, ← assign, → branch, ¯ macron negative, ⍪ table catenate first
+× times signum, ÷ divide reciprocal, ∪ union unique, ∩ intersection, ≠ not equal unique mask, ≤ less equal, ≥ greater equal
+'''),
+        parse_palette('IPA', '''
+ɑ open back unrounded, æ ash near open front, ɐ turned a near open central, ɒ open back rounded
+ə schwa mid central, ɚ rhotic schwa, ɛ open mid front unrounded, ɜ open mid central, ɪ near close front
+ɨ barred i close central, ɔ open mid back rounded, ø close mid front rounded, œ open mid front rounded
+ʊ near close back rounded, ʉ barred u close central rounded, ʌ wedge open mid back unrounded, ɯ close back unrounded, ʏ near close front rounded
+ŋ eng velar nasal, ɲ palatal nasal, ɳ retroflex nasal, ɴ uvular nasal, ɱ labiodental nasal
+θ theta voiceless dental fricative, ð eth voiced dental fricative, ʃ esh voiceless postalveolar fricative, ʒ ezh voiced postalveolar fricative
+ʔ glottal stop, ʕ voiced pharyngeal fricative, ħ voiceless pharyngeal fricative, ɦ voiced glottal fricative
+ɣ voiced velar fricative, χ voiceless uvular fricative, ʁ voiced uvular fricative, ç voiceless palatal fricative, ʝ voiced palatal fricative
+ɸ voiceless bilabial fricative, β voiced bilabial fricative, ʂ voiceless retroflex fricative, ʐ voiced retroflex fricative
+ɕ voiceless alveolo palatal fricative, ʑ voiced alveolo palatal fricative
+ɹ turned r alveolar approximant, ɾ alveolar tap, ɽ retroflex flap, ʀ uvular trill, ʋ labiodental approximant, ɥ labial palatal approximant, ʍ voiceless labial velar fricative
+ɬ belted l voiceless lateral fricative, ɮ voiced lateral fricative, ʎ palatal lateral, ʟ velar lateral, ɫ dark l
+ɡ script g voiced velar stop, ɢ uvular stop, ʈ voiceless retroflex stop, ɖ voiced retroflex stop, ɟ voiced palatal stop
+ˈ primary stress, ˌ secondary stress, ː long, ˑ half long, ʰ aspirated, ʲ palatalized, ʷ labialized, ˀ glottalized
+'''),
+    )
@@ -473,7 +473,7 @@ def get_matches(location: str, query: str, candidates: Set[int]) -> Set[int]:
             return {wid for wid in candidates if self.window_id_map[wid].matches_query(location, query, tab, self_window)}
 
         for wid in search(match, (
-                'id', 'title', 'pid', 'cwd', 'cmdline', 'num', 'env', 'recent', 'state'
+                'id', 'title', 'pid', 'cwd', 'cmdline', 'num', 'env', 'var', 'recent', 'state'
         ), set(self.window_id_map), get_matches):
             yield self.window_id_map[wid]
 
@@ -1639,7 +1639,10 @@ def notify_on_os_window_death(self, address: str) -> None:
                 s.shutdown(socket.SHUT_RDWR)
             s.close()
 
-    def display_scrollback(self, window: Window, data: Union[bytes, str], input_line_number: int = 0, title: str = '', report_cursor: bool = True) -> None:
+    def display_scrollback(
+        self, window: Window, data: Union[bytes, str], input_line_number: int = 0, title: str = '', report_cursor: bool = True,
+        search: str = '', search_ignores_case: bool = False
+    ) -> None:
 
         def prepare_arg(x: str) -> str:
             x = x.replace('INPUT_LINE_NUMBER', str(input_line_number))
@@ -1653,6 +1656,12 @@ def prepare_arg(x: str) -> str:
 
         if os.path.basename(cmd[0]) == 'less':
             cmd.append('-+F')  # reset --quit-if-one-screen
+            if search:
+                # start at the first match rather than at INPUT_LINE_NUMBER
+                cmd = [x for x in cmd if not x.startswith('+')]
+                if search_ignores_case:
+                    cmd.append('-I')
+                cmd.append('-p' + search)
         tab = self.active_tab
         if tab is not None:
             bdata = data.encode('utf-8') if isinstance(data, str) else data
@@ -1280,7 +1280,7 @@ read_bytes(int fd, Screen *screen) {
     if (UNLIKELY(len == 0)) return false;
 
     screen_mutex(lock, read);
-    if (screen->new_input_at == 0) screen->new_input_at = monotonic();
+    if (screen->new_input_at == 0) screen->new_input_at = screen->last_output_at = monotonic();
     if (orig_sz != screen->read_buf_sz) {
         // The other thread consumed some of the screen read buffer
         memmove(screen->read_buf + screen->read_buf_sz, screen->read_buf + orig_sz, len);
@@ -101,6 +101,7 @@ class OptionDict(TypedDict):
     default: Optional[str]
     condition: bool
     completion: CompletionSpec
+    validator: str
 
 
 def serialize_as_go_string(x: str) -> str:
@@ -152,9 +153,11 @@ def as_option(self, cmd_name: str = 'cmd', depth: int = 0, group: str = '') -> s
             c = ', '.join(self.sorted_choices)
             cx = ', '.join(f'"{serialize_as_go_string(x)}"' for x in self.sorted_choices)
             ans += f'\nChoices: "{serialize_as_go_string(c)}",\n'
-            ans += f'\nCompleter: cli.NamesCompleter("Choices for {self.long}", {cx}),'
+            ans += f'\nCompleter: cli.ChoicesCompleter("Choices for {self.long}", {cx}),'
         elif self.obj_dict['completion'].type is not CompletionType.none:
             ans += ''.join(self.obj_dict['completion'].as_go_code('Completer', ': ')) + ','
+        if self.obj_dict['validator']:
+            ans += f'\nValidator: {self.obj_dict["validator"]},\n'
         if depth > 0:
             ans += f'\nDepth: {depth},\n'
         if self.default:
@@ -384,7 +387,7 @@ def parse_option_spec(spec: Optional[str] = None) -> Tuple[OptionSpecSeq, Option
     mpat = re.compile('([a-z]+)=(.+)')
     current_cmd: OptionDict = {
         'dest': '', 'aliases': frozenset(), 'help': '', 'choices': frozenset(),
-        'type': '', 'condition': False, 'default': None, 'completion': CompletionSpec(), 'name': ''
+        'type': '', 'condition': False, 'default': None, 'completion': CompletionSpec(), 'name': '', 'validator': ''
     }
     empty_cmd = current_cmd
 
@@ -405,7 +408,7 @@ def indent_of_line(x: str) -> int:
                 current_cmd = {
                     'dest': defdest, 'aliases': frozenset(parts), 'help': '',
                     'choices': frozenset(), 'type': '', 'name': defdest,
-                    'default': None, 'condition': True, 'completion': CompletionSpec(),
+                    'default': None, 'condition': True, 'completion': CompletionSpec(), 'validator': '',
                 }
                 state = METADATA
                 continue
@@ -435,6 +438,8 @@ def indent_of_line(x: str) -> int:
                         current_cmd['condition'] = bool(eval(v))
                     elif k == 'completion':
                         current_cmd['completion'] = CompletionSpec.from_string(v)
+                    elif k == 'validator':
+                        current_cmd['validator'] = v
         elif state is HELP:
             if line:
                 current_indent = indent_of_line(line)
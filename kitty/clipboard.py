@@ -510,6 +510,9 @@ def ask_to_read_clipboard(self, rr: ReadRequest) -> None:
         w = get_boss().window_id_map.get(self.window_id)
         if w is not None:
             self.currently_asking_permission_for = rr
+            if rr.protocol_type is not ProtocolType.osc_52:
+                # let the client know it is waiting for the user, not a hung terminal
+                w.screen.send_escape_code_to_child(OSC, rr.encode_response(status='ASK'))
             get_boss().confirm(_(
                 'A program running in this window wants to read from the system clipboard.'
                 ' Allow it to do so, once?'),
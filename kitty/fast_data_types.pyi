@@ -870,7 +870,7 @@ def concat_cells(cell_width: int, cell_height: int, is_32_bit: bool, cells: Tupl
     pass
 
 
-def current_fonts() -> Dict[str, Any]:
+def current_fonts(os_window_id: int = 0) -> Dict[str, Any]:
     pass
 
 
@@ -1005,12 +1005,21 @@ def get_fallback_font(text: str, bold: bool, italic: bool) -> Any:
     pass
 
 
+def font_for_text(text: str, bold: bool, italic: bool, os_window_id: int = 0) -> Tuple[str, Any]:
+    pass
+
+
 def create_test_font_group(sz: float, dpix: float,
                            dpiy: float) -> Tuple[int, int]:
     pass
 
 
 class HistoryBuf:
+    ynum: int
+    count: int
+
+    def memory_usage(self) -> Tuple[int, int]:
+        pass
 
     def pagerhist_as_text(self, upto_output_start: bool = False) -> str:
         pass
@@ -1039,6 +1048,11 @@ class Cursor:
     shape: int
 
 
+class GraphicsManager:
+    image_count: int
+    used_storage: int
+
+
 class Screen:
 
     color_profile: ColorProfile
@@ -1047,14 +1061,17 @@ class Screen:
     focus_tracking_enabled: bool
     historybuf: HistoryBuf
     linebuf: LineBuf
+    grman: GraphicsManager
     in_bracketed_paste_mode: bool
     cursor_visible: bool
     scrolled_by: int
+    lines_added_to_history: int
     cursor: Cursor
     disable_ligatures: int
     cursor_key_mode: bool
     auto_repeat_enabled: bool
     render_unfocused_cursor: int
+    render_frozen: int
     last_reported_cwd: Optional[str]
 
     def __init__(
@@ -1188,6 +1205,9 @@ class Screen:
     def has_activity_since_last_focus(self) -> bool:
         pass
 
+    def seconds_since_last_output(self) -> Optional[float]:
+        pass
+
     def insert_characters(self, num: int) -> None:
         pass
 
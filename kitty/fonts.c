@@ -1595,12 +1595,29 @@ concat_cells(PyObject UNUSED *self, PyObject *args) {
     return ans;
 }
 
-static PyObject*
-current_fonts(PYNOARG) {
+static FontGroup*
+font_group_for_os_window(id_type os_window_id) {
     if (!num_font_groups) { PyErr_SetString(PyExc_RuntimeError, "must create font group first"); return NULL; }
+    if (!os_window_id) return font_groups;
+    for (size_t i = 0; i < global_state.num_os_windows; i++) {
+        OSWindow *w = global_state.os_windows + i;
+        if (w->id == os_window_id) {
+            if (w->fonts_data) return (FontGroup*)w->fonts_data;
+            break;
+        }
+    }
+    PyErr_Format(PyExc_KeyError, "No OS window with id: %llu has fonts loaded", os_window_id);
+    return NULL;
+}
+
+static PyObject*
+current_fonts(PyObject UNUSED *self, PyObject *args) {
+    unsigned long long os_window_id = 0;
+    if (!PyArg_ParseTuple(args, "|K", &os_window_id)) return NULL;
+    FontGroup *fg = font_group_for_os_window(os_window_id);
+    if (!fg) return NULL;
     PyObject *ans = PyDict_New();
     if (!ans) return NULL;
-    FontGroup *fg = font_groups;
 #define SET(key, val) {if (PyDict_SetItemString(ans, #key, fg->fonts[val].face) != 0) { goto error; }}
     SET(medium, fg->medium_font_idx);
     if (fg->bold_font_idx > 0) SET(bold, fg->bold_font_idx);
@@ -1614,12 +1631,31 @@ current_fonts(PYNOARG) {
     }
     PyDict_SetItemString(ans, "fallback", ff);
     Py_CLEAR(ff);
+    ff = PyTuple_New(fg->first_fallback_font_idx - fg->first_symbol_font_idx);
+    if (!ff) goto error;
+    for (ssize_t i = fg->first_symbol_font_idx; i < fg->first_fallback_font_idx; i++) {
+        Py_INCREF(fg->fonts[i].face);
+        PyTuple_SET_ITEM(ff, i - fg->first_symbol_font_idx, fg->fonts[i].face);
+    }
+    PyDict_SetItemString(ans, "symbol", ff);
+    Py_CLEAR(ff);
     return ans;
 error:
     Py_CLEAR(ans); return NULL;
 #undef SET
 }
 
+static bool
+cell_for_text(PyObject *text, bool bold, bool italic, CPUCell *cpu_cell, GPUCell *gpu_cell) {
+    static Py_UCS4 char_buf[2 + arraysz(cpu_cell->cc_idx)];
+    if (!PyUnicode_AsUCS4(text, char_buf, arraysz(char_buf), 1)) return false;
+    cpu_cell->ch = char_buf[0];
+    for (unsigned i = 0; i + 1 < (unsigned) PyUnicode_GetLength(text) && i < arraysz(cpu_cell->cc_idx); i++) cpu_cell->cc_idx[i] = mark_for_codepoint(char_buf[i + 1]);
+    if (bold) gpu_cell->attrs.bold = true;
+    if (italic) gpu_cell->attrs.italic = true;
+    return true;
+}
+
 static PyObject*
 get_fallback_font(PyObject UNUSED *self, PyObject *args) {
     if (!num_font_groups) { PyErr_SetString(PyExc_RuntimeError, "must create font group first"); return NULL; }
@@ -1628,12 +1664,7 @@ get_fallback_font(PyObject UNUSED *self, PyObject *args) {
     if (!PyArg_ParseTuple(args, "Upp", &text, &bold, &italic)) return NULL;
     CPUCell cpu_cell = {0};
     GPUCell gpu_cell = {0};
-    static Py_UCS4 char_buf[2 + arraysz(cpu_cell.cc_idx)];
-    if (!PyUnicode_AsUCS4(text, char_buf, arraysz(char_buf), 1)) return NULL;
-    cpu_cell.ch = char_buf[0];
-    for (unsigned i = 0; i + 1 < (unsigned) PyUnicode_GetLength(text) && i < arraysz(cpu_cell.cc_idx); i++) cpu_cell.cc_idx[i] = mark_for_codepoint(char_buf[i + 1]);
-    if (bold) gpu_cell.attrs.bold = true;
-    if (italic) gpu_cell.attrs.italic = true;
+    if (!cell_for_text(text, bold, italic, &cpu_cell, &gpu_cell)) return NULL;
     FontGroup *fg = font_groups;
     ssize_t ans = fallback_font(fg, &cpu_cell, &gpu_cell);
     if (ans == MISSING_FONT) { PyErr_SetString(PyExc_ValueError, "No fallback font found"); return NULL; }
@@ -1641,6 +1672,33 @@ get_fallback_font(PyObject UNUSED *self, PyObject *args) {
     return fg->fonts[ans].face;
 }
 
+static PyObject*
+font_for_text(PyObject UNUSED *self, PyObject *args) {
+    PyObject *text;
+    int bold, italic;
+    unsigned long long os_window_id = 0;
+    if (!PyArg_ParseTuple(args, "Upp|K", &text, &bold, &italic, &os_window_id)) return NULL;
+    FontGroup *fg = font_group_for_os_window(os_window_id);
+    if (!fg) return NULL;
+    CPUCell cpu_cell = {0};
+    GPUCell gpu_cell = {0};
+    if (!cell_for_text(text, bold, italic, &cpu_cell, &gpu_cell)) return NULL;
+    bool is_main_font, is_emoji_presentation;
+    ssize_t ans = font_for_cell(fg, &cpu_cell, &gpu_cell, &is_main_font, &is_emoji_presentation);
+    switch (ans) {
+        case NO_FONT: case MISSING_FONT:
+            return Py_BuildValue("sO", "missing", Py_None);
+        case BLANK_FONT:
+            return Py_BuildValue("sO", "blank", Py_None);
+        case BOX_FONT:
+            return Py_BuildValue("sO", "box", Py_None);
+    }
+    const char *kind = "fallback";
+    if (is_main_font) kind = "main";
+    else if (fg->first_symbol_font_idx <= ans && ans < fg->first_fallback_font_idx) kind = "symbol";
+    return Py_BuildValue("sO", kind, fg->fonts[ans].face);
+}
+
 static PyObject*
 create_test_font_group(PyObject *self UNUSED, PyObject *args) {
     double sz, dpix, dpiy;
@@ -1682,9 +1740,10 @@ static PyMethodDef module_methods[] = {
     METHODB(concat_cells, METH_VARARGS),
     METHODB(set_send_sprite_to_gpu, METH_O),
     METHODB(test_shape, METH_VARARGS),
-    METHODB(current_fonts, METH_NOARGS),
+    METHODB(current_fonts, METH_VARARGS),
     METHODB(test_render_line, METH_VARARGS),
     METHODB(get_fallback_font, METH_VARARGS),
+    METHODB(font_for_text, METH_VARARGS),
     {NULL, NULL, 0, NULL}        /* Sentinel */
 };
 
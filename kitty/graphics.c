@@ -1796,6 +1796,7 @@ static PyMethodDef methods[] = {
 
 static PyMemberDef members[] = {
     {"image_count", T_PYSSIZET, offsetof(GraphicsManager, image_count), READONLY, "image_count"},
+    {"used_storage", T_PYSSIZET, offsetof(GraphicsManager, used_storage), READONLY, "used_storage"},
     {"storage_limit", T_PYSSIZET, offsetof(GraphicsManager, storage_limit), 0, "storage_limit"},
     {"disk_cache", T_OBJECT_EX, offsetof(GraphicsManager, disk_cache), READONLY, "disk_cache"},
     {NULL},
@@ -521,6 +521,15 @@ dirty_lines(HistoryBuf *self, PyObject *a UNUSED) {
     return ans;
 }
 
+static PyObject*
+memory_usage(HistoryBuf *self, PyObject *a UNUSED) {
+#define memory_usage_doc "memory_usage() -> The number of bytes allocated for lines and the number of bytes used by the pager history"
+    const size_t line_sz = self->xnum * (sizeof(CPUCell) + sizeof(GPUCell)) + sizeof(LineAttrs);
+    size_t pager_sz = 0;
+    if (self->pagerhist && self->pagerhist->ringbuf) pager_sz = ringbuf_bytes_used(self->pagerhist->ringbuf);
+    return Py_BuildValue("nn", (Py_ssize_t)(self->num_segments * SEGMENT_SIZE * line_sz), (Py_ssize_t)pager_sz);
+}
+
 static PyObject*
 pagerhist_rewrap(HistoryBuf *self, PyObject *xnum) {
     if (self->pagerhist) {
@@ -542,6 +551,7 @@ static PyMethodDef methods[] = {
     METHODB(pagerhist_as_text, METH_VARARGS),
     METHODB(pagerhist_as_bytes, METH_VARARGS),
     METHOD(dirty_lines, METH_NOARGS)
+    METHOD(memory_usage, METH_NOARGS)
     METHOD(push, METH_VARARGS)
     METHOD(rewrap, METH_VARARGS)
     {NULL, NULL, 0, NULL}  /* Sentinel */
@@ -99,6 +99,16 @@ def options_spec() -> str:
 refers to the process that was originally started when the window was created.
 
 
+--ssh
+type=bool-set
+If the currently active window is running the :doc:`ssh kitten </kittens/ssh>`,
+run the command on the remote computer, over a new connection to the same host,
+instead of on the local computer. The command is run with the login shell of the
+remote user, in the directory specified by :option:`--cwd`, which is a
+directory on the remote computer, in this case. Has no effect when the active
+window is not running the ssh kitten.
+
+
 --env
 type=list
 Environment variables to set in the child process. Can be specified multiple
@@ -272,6 +282,7 @@ def options_spec() -> str:
 
 --color
 type=list
+completion=type:special group:cli.CompleteColorSettings
 Change colors in the newly launched window. You can either specify a path to a
 :file:`.conf` file with the same syntax as :file:`kitty.conf` to read the colors
 from, or specify them individually, for example::
@@ -537,6 +548,15 @@ def launch(
                         elif x == '@last-line-on-screen':
                             x = str(screen.visual_line(screen.lines - 1) or '')
             final_cmd.append(x)
+        if opts.ssh and active:
+            ssh_kitten_cmdline = active.ssh_kitten_cmdline()
+            if ssh_kitten_cmdline:
+                from kittens.ssh.utils import set_cwd_in_cmdline, set_remote_command_in_cmdline
+                if kw['cwd']:
+                    set_cwd_in_cmdline(kw['cwd'], ssh_kitten_cmdline)
+                    kw['cwd'] = None
+                set_remote_command_in_cmdline(final_cmd, ssh_kitten_cmdline)
+                final_cmd = ssh_kitten_cmdline
         exe = which(final_cmd[0])
         if exe:
             final_cmd[0] = exe
@@ -91,9 +91,10 @@ def __call__(self, key: str, opt_name: Optional[str] = None, missing: Any = None
 
 MATCH_WINDOW_OPTION = '''\
 --match -m
+completion=type:special group:complete_window_match
 The window to match. Match specifications are of the form: :italic:`field:query`.
 Where :italic:`field` can be one of: :code:`id`, :code:`title`, :code:`pid`, :code:`cwd`, :code:`cmdline`, :code:`num`,
-:code:`env`, :code:`state` and :code:`recent`.
+:code:`env`, :code:`var`, :code:`state` and :code:`recent`.
 :italic:`query` is the expression to match. Expressions can be either a number or a regular expression, and can be
 :ref:`combined using Boolean operators <search_syntax>`.
 
@@ -114,6 +115,9 @@ def __call__(self, key: str, opt_name: Optional[str] = None, missing: Any = None
 When using the :code:`env` field to match on environment variables, you can specify only the environment variable name
 or a name and value, for example, :code:`env:MY_ENV_VAR=2`.
 
+The field :code:`var` matches on user variables set on windows with :ref:`kitty @ set-user-vars <at-set-user-vars>`,
+in the same way as the :code:`env` field, for example, :code:`var:bookmark=logs`.
+
 The field :code:`state` matches on the state of the window. Supported states
 are: :code:`active`, :code:`focused`, :code:`needs_attention`,
 :code:`parent_active`, :code:`parent_focused`, :code:`self`,
@@ -129,6 +133,7 @@ def __call__(self, key: str, opt_name: Optional[str] = None, missing: Any = None
 '''
 MATCH_TAB_OPTION = '''\
 --match -m
+completion=type:special group:complete_tab_match
 The tab to match. Match specifications are of the form: :italic:`field:query`.
 Where :italic:`field` can be one of: :code:`id`, :code:`index`, :code:`title`, :code:`window_id`, :code:`window_title`,
 :code:`pid`, :code:`cwd`, :code:`cmdline` :code:`env`, :code:`state` and :code:`recent`.
@@ -322,6 +327,10 @@ class RemoteCommand:
     argspec = args_count = args_completion = ArgsHandling()
     field_to_option_map: Optional[Dict[str, str]] = None
     reads_streaming_data: bool = False
+    # Options that are only used by the client and are not sent to kitty
+    client_only_options: FrozenSet[str] = frozenset()
+    # The name of a Go function that processes the response from kitty instead of it being printed
+    go_response_handler: str = ''
 
     def __init__(self) -> None:
         self.desc = self.desc or self.short_desc
@@ -0,0 +1,107 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2023, Kovid Goyal <kovid at kovidgoyal.net>
+
+import shlex
+from typing import TYPE_CHECKING, Iterator, List, Optional
+
+from kitty.constants import appname
+
+from .base import MATCH_TAB_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import ExportSessionRCOptions as CLIOptions
+    from kitty.tabs import Tab
+
+
+def cmdline_for_window(window: Window, use_foreground_process: bool) -> List[str]:
+    from kitty.fast_data_types import get_options
+    from kitty.utils import resolved_shell
+    if use_foreground_process and not window.root_in_foreground_processes:
+        for p in window.child.foreground_processes:
+            if p['cmdline']:
+                return list(p['cmdline'])
+    cmd = window.child.cmdline
+    if cmd == resolved_shell(get_options()):
+        # the default shell is what launch runs when no command is specified
+        return []
+    return cmd
+
+
+def session_lines_for_tab(tab: 'Tab', use_foreground_process: bool, focus: bool) -> Iterator[str]:
+    yield f'new_tab {tab.name}'.rstrip()
+    yield f'enabled_layouts {",".join(tab.enabled_layouts)}'
+    yield f'layout {tab.current_layout.full_name}'
+    active_group = tab.windows.active_group
+    for group in tab.windows.iter_all_layoutable_groups():
+        window = tab.windows.id_map.get(group.main_window_id)
+        if window is None:
+            continue
+        cmd = ['launch']
+        cwd = window.cwd_of_child
+        if cwd:
+            cmd.append(f'--cwd={cwd}')
+        if window.override_title:
+            cmd.append(f'--title={window.override_title}')
+        cmdline = cmdline_for_window(window, use_foreground_process)
+        if cmdline:
+            cmd.append('--')
+            cmd.extend(cmdline)
+        yield shlex.join(cmd)
+        if focus and group is active_group:
+            yield 'focus'
+
+
+class ExportSession(RemoteCommand):
+    protocol_spec = __doc__ = '''
+    match/str: Which tabs to export, all tabs if not specified
+    use_foreground_processes/bool: Boolean, if True use the programs running in the foreground in windows as their commands
+    '''
+
+    short_desc = 'Export the current tabs and windows as a session file'
+    desc = (
+        'Print a :ref:`session file <sessions>` describing the current OS windows, tabs, their layouts'
+        ' and windows with their working directories and commands. Use it with :option:`kitty --session`'
+        ' to re-create the current setup later. For example:'
+        ' :code:`kitty @ export-session > ~/.config/kitty/saved.session`. Note that only the working directories'
+        ' and commands of windows are saved, not their contents. Windows running the default shell'
+        ' are re-created running the default shell.'
+    )
+    options_spec = '''\
+--use-foreground-processes
+type=bool-set
+By default, windows are re-created with the command they were started with. With this option,
+the program running in the foreground in a window is used instead, for example, the editor
+run from the shell in that window.
+''' + '\n\n\n' + MATCH_TAB_OPTION
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        return {'match': opts.match, 'use_foreground_processes': opts.use_foreground_processes}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        match = payload_get('match')
+        selected = {tab.id for tab in self.tabs_for_match_payload(boss, window, payload_get)} if match else None
+        use_foreground_processes = bool(payload_get('use_foreground_processes'))
+        active_tab_manager = boss.active_tab_manager
+        lines: List[str] = []
+        for tm in boss.all_tab_managers:
+            tabs = [t for t in tm if selected is None or t.id in selected]
+            if not tabs:
+                continue
+            if lines:
+                lines.extend(('', 'new_os_window'))
+            if tm.wm_class and tm.wm_class != appname:
+                lines.append(f'os_window_class {tm.wm_class}')
+            active_tab = tm.active_tab
+            # focus also makes the tab active, so it can only be used for
+            # tabs up to and including the active tab
+            focus = active_tab in tabs
+            for tab in tabs:
+                lines.extend(session_lines_for_tab(tab, use_foreground_processes, focus))
+                if tab is active_tab:
+                    focus = False
+            if tm is active_tab_manager:
+                lines.append('focus_os_window')
+        return '\n'.join(lines)
+
+
+export_session = ExportSession()
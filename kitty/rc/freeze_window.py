@@ -0,0 +1,55 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+
+from typing import TYPE_CHECKING, Optional
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import FreezeWindowRCOptions as CLIOptions
+
+
+class FreezeWindow(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    action+/choices.freeze.resume.toggle: Whether to freeze, resume or toggle rendering
+    match/str: Which windows to freeze
+    '''
+
+    short_desc = 'Pause or resume rendering of the specified windows'
+    desc = (
+        'Pause (freeze) or resume rendering of the specified windows. While a window is frozen its display is not'
+        ' updated, but the program running in it keeps running and its output is still processed, so that when'
+        ' rendering is resumed the window shows its current contents. By default, the window this command is run in'
+        ' is affected. :italic:`ACTION` can be one of :code:`freeze`, :code:`resume` or :code:`toggle`, defaulting'
+        ' to :code:`toggle`.'
+    )
+    options_spec = MATCH_WINDOW_OPTION + '''\n
+--no-response
+type=bool-set
+default=false
+Don't wait for a response indicating the success of the action. Note that
+using this option means that you will not be notified of failures.
+'''
+    args = RemoteCommand.Args(
+        spec='[ACTION]', json_field='action', value_if_unspecified=('toggle',),
+        completion=RemoteCommand.CompletionSpec.from_string('type:keyword group:"Action" kwds:freeze,resume,toggle'))
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        action = args[0] if args else 'toggle'
+        if len(args) > 1 or action not in ('freeze', 'resume', 'toggle'):
+            self.fatal('ACTION must be one of freeze, resume or toggle')
+        # defaults to the window this command is run in
+        return {'match': opts.match, 'action': action, 'self': True}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        action = payload_get('action') or 'toggle'
+        for window in self.windows_for_match_payload(boss, window, payload_get):
+            if window:
+                freeze = not window.rendering_frozen if action == 'toggle' else action == 'freeze'
+                window.set_rendering_frozen(freeze)
+        return None
+
+
+freeze_window = FreezeWindow()
@@ -0,0 +1,62 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+import json
+from time import time
+from typing import TYPE_CHECKING, Any, Dict, Optional
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import GetScrollbackStatsRCOptions as CLIOptions
+
+
+def stats_for_window(w: Window) -> Dict[str, Any]:
+    screen = w.screen
+    hb = screen.historybuf
+    line_bytes, pager_bytes = hb.memory_usage()
+    since = screen.seconds_since_last_output()
+    return {
+        'id': w.id,
+        'title': w.title,
+        'scrollback_lines': hb.count,
+        'scrollback_capacity': hb.ynum,
+        'scrollback_bytes': line_bytes,
+        'pager_history_bytes': pager_bytes,
+        'images': screen.grman.image_count,
+        'image_bytes': screen.grman.used_storage,
+        'total_bytes': line_bytes + pager_bytes + screen.grman.used_storage,
+        'last_output_at': None if since is None else time() - since,
+    }
+
+
+class GetScrollbackStats(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    match/str: The windows to get statistics for
+    '''
+
+    short_desc = 'Get scrollback and memory usage statistics for windows'
+    desc = (
+        'Get statistics about the scrollback and memory used by the specified windows, defaulting to all windows.'
+        ' The statistics are returned as a JSON list with one entry per window, largest first. Each entry has the window'
+        ' :italic:`id` and :italic:`title`, the number of lines of scrollback used and the maximum number of lines,'
+        ' the bytes used by the scrollback, the scrollback pager history and images, the number of images and the time'
+        ' the program running in the window last produced output, as seconds since the epoch. Useful to find which'
+        ' windows are using a lot of memory.'
+    )
+    options_spec = MATCH_WINDOW_OPTION
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        return {'match': opts.match}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        if payload_get('match'):
+            windows = self.windows_for_match_payload(boss, window, payload_get)
+        else:
+            windows = list(boss.all_windows)
+        ans = sorted((stats_for_window(w) for w in windows if w is not None), key=lambda x: x['total_bytes'], reverse=True)
+        return json.dumps(ans, indent=2, sort_keys=True)
+
+
+get_scrollback_stats = GetScrollbackStats()
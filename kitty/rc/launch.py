@@ -22,6 +22,7 @@ class Launch(RemoteCommand):
     match/str: The tab to open the new window in
     window_title/str: Title for the new window
     cwd/str: Working directory for the new window
+    ssh/bool: Boolean indicating whether to run the command on the remote computer when the current window is running the ssh kitten
     env/list.str: List of environment variables of the form NAME=VALUE
     tab_title/str: Title for the new tab
     type/choices.window.tab.os-window.overlay.overlay-main.background.clipboard.primary: The type of window to open
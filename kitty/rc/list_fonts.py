@@ -0,0 +1,106 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2023, Kovid Goyal <kovid at kovidgoyal.net>
+
+import unicodedata
+from typing import TYPE_CHECKING, Any, Iterator, List, Optional
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import ListFontsRCOptions as CLIOptions
+
+
+def describe_face(face: Any) -> str:
+    ans = face.display_name()
+    path = getattr(face, 'path', '')
+    if path:
+        ans += f' ({path})'
+    return ans
+
+
+def split_into_cells(text: str) -> Iterator[str]:
+    # combining characters and variation selectors are rendered in the same cell
+    # as the character before them
+    current = ''
+    for ch in text:
+        if current and unicodedata.category(ch).startswith('M'):
+            current += ch
+            continue
+        if current:
+            yield current
+        current = ch
+    if current:
+        yield current
+
+
+font_kind_descriptions = {
+    'main': 'main font', 'symbol': 'symbol map', 'fallback': 'fallback font',
+    'box': 'drawn by kitty', 'blank': 'blank', 'missing': 'no font has this character',
+}
+
+
+class ListFonts(RemoteCommand):
+    protocol_spec = __doc__ = '''
+    match/str: The window whose fonts are listed
+    characters/list.str: Characters to report the font used to render
+    bold/bool: Boolean indicating whether to report the font for bold text
+    italic/bool: Boolean indicating whether to report the font for italic text
+    '''
+
+    short_desc = 'List the fonts loaded in a window and the fonts used for characters'
+    desc = (
+        'List the fonts loaded for the OS window containing the specified window (defaults to the active window).'
+        ' This includes the main fonts, the fonts used for :opt:`symbol_map` and the fallback fonts, in the order in'
+        ' which they were loaded when kitty encountered characters not present in the main fonts.'
+        ' If any characters are specified, the font used to render each of them is reported as well, which is useful'
+        ' when a character is rendered using an unexpected font. Note that looking up a character can cause a new'
+        ' fallback font to be loaded, exactly as displaying it would.'
+    )
+    args = RemoteCommand.Args(spec='[CHARACTERS ...]', json_field='characters')
+    options_spec = '''\
+--bold
+type=bool-set
+Report the fonts used for the specified characters when they are bold.
+
+
+--italic
+type=bool-set
+Report the fonts used for the specified characters when they are italic.
+
+''' + '\n\n' + MATCH_WINDOW_OPTION
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        return {'match': opts.match, 'characters': args, 'bold': opts.bold, 'italic': opts.italic}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        from kitty.fast_data_types import current_fonts, font_for_text
+        windows = self.windows_for_match_payload(boss, window, payload_get)
+        if not windows or not windows[0]:
+            return None
+        os_window_id = windows[0].os_window_id
+        cf = current_fonts(os_window_id)
+        lines: List[str] = [f'Fonts for OS window {os_window_id}:']
+        for key, label in (('medium', 'Regular'), ('bold', 'Bold'), ('italic', 'Italic'), ('bi', 'Bold-Italic')):
+            if key in cf:
+                lines.append(f'  {label + ":":12} {describe_face(cf[key])}')
+        if cf['symbol']:
+            lines.append('\nSymbol map fonts:')
+            lines.extend(f'  {describe_face(f)}' for f in cf['symbol'])
+        if cf['fallback']:
+            lines.append('\nFallback fonts, in the order in which they were loaded:')
+            lines.extend(f'  {describe_face(f)}' for f in cf['fallback'])
+        cells = [c for text in payload_get('characters') or () for c in split_into_cells(text)]
+        if cells:
+            lines.append('\nFonts used for characters:')
+            bold, italic = bool(payload_get('bold')), bool(payload_get('italic'))
+            for cell in cells:
+                kind, face = font_for_text(cell, bold, italic, os_window_id)
+                codepoints = ' '.join(f'U+{ord(ch):04X}' for ch in cell)
+                desc = font_kind_descriptions[kind]
+                if face is not None:
+                    desc = f'{describe_face(face)} [{desc}]'
+                lines.append(f'  {cell} {codepoints}: {desc}')
+        return '\n'.join(lines)
+
+
+list_fonts = ListFonts()
@@ -31,7 +31,24 @@ class LS(RemoteCommand):
 --all-env-vars
 type=bool-set
 Show all environment variables in output, not just differing ones.
+
+
+--snapshot-to
+completion=type:file ext:json group:"JSON files"
+Save the output to the specified file instead of printing it, for later
+comparison using :option:`--diff`.
+
+
+--diff
+completion=type:file ext:json group:"JSON files"
+Instead of printing the output, compare it with a snapshot previously saved
+with :option:`--snapshot-to` and report the OS windows, tabs and windows that
+have been created, closed, retitled or moved since the snapshot was taken. Can
+be combined with :option:`--snapshot-to` to save the current state after the
+comparison.
 '''
+    client_only_options = frozenset({'snapshot_to', 'diff'})
+    go_response_handler = 'handle_ls_response'
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
         return {'all_env_vars': opts.all_env_vars}
@@ -111,11 +111,36 @@ class SendText(RemoteCommand):
 --from-file
 Path to a file whose contents you wish to send. Note that in this case the file contents
 are sent as is, not interpreted for escapes.
+
+
+--chunk-size
+type=int
+default=2048
+validator=cli.IntRange(1, 1048576)
+The maximum number of bytes to send to kitty in a single remote control message.
+Large amounts of text, from :option:`--from-file` or :option:`--stdin`, are split
+into chunks of this size. The default is the size :program:`kitten @` has always
+used, previously, :program:`kitty @` used chunks of 1024 bytes.
+
+
+--chunk-delay
+type=float
+default=0
+The number of seconds to wait between sending consecutive chunks of text. Useful
+to avoid flooding programs that process their input slowly, for example, when
+pasting a large SQL script into a remote database client.
+
+
+--bracketed-paste
+type=bool-set
+Wrap the sent text in bracketed paste escape codes, so that programs that support
+bracketed paste treat it as pasted text rather than typed input. Has no effect when
+reading key presses from a terminal via :option:`--stdin`.
 '''
     args = RemoteCommand.Args(spec='[TEXT TO SEND]', json_field='data', special_parse='+session_id:parse_send_text(io_data, args)')
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
-        limit = 1024
+        limit = max(1, opts.chunk_size)
         ret = {'match': opts.match, 'data': '', 'match_tab': opts.match_tab, 'all': opts.all, 'exclude_active': opts.exclude_active}
 
         def pipe() -> CmdGenerator:
@@ -161,7 +186,14 @@ def file_pipe(path: str) -> CmdGenerator:
                     ret['data'] = f'base64:{base64.standard_b64encode(data).decode("ascii")}'
                     yield ret
 
+        def raw(data: str) -> CmdGenerator:
+            ret['data'] = f'text:{data}'
+            yield ret
+
         sources = []
+        bracketed_paste = opts.bracketed_paste and not (opts.stdin and sys.stdin.isatty())
+        if bracketed_paste:
+            sources.append(raw('\x1b[200~'))
         if opts.stdin:
             sources.append(pipe())
 
@@ -170,10 +202,18 @@ def file_pipe(path: str) -> CmdGenerator:
 
         text = ' '.join(args)
         sources.append(chunks(text))
+        if bracketed_paste:
+            sources.append(raw('\x1b[201~'))
 
         def chain() -> CmdGenerator:
+            import time
+            first = True
             for src in sources:
-                yield from src
+                for x in src:
+                    if not first and opts.chunk_delay > 0:
+                        time.sleep(opts.chunk_delay)
+                    first = False
+                    yield x
         return chain()
 
     def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
@@ -54,7 +54,8 @@ class SetTabColor(RemoteCommand):
 type=bool-set
 Close the tab this command is run in, rather than the active tab.
 '''
-    args = RemoteCommand.Args(spec='COLORS', json_field='colors', minimum_count=1, special_parse='parse_tab_colors(args)')
+    args = RemoteCommand.Args(spec='COLORS', json_field='colors', minimum_count=1, special_parse='parse_tab_colors(args)',
+                              completion=RemoteCommand.CompletionSpec.from_string('type:special group:complete_tab_colors'))
 
     def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
         try:
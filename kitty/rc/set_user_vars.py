@@ -0,0 +1,54 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+from typing import TYPE_CHECKING, Optional
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import SetUserVarsRCOptions as CLIOptions
+
+
+class SetUserVars(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    var+/dict.str: Dictionary of user variables to values. When a variable ends with = it is removed.
+    match/str: Which windows to set the variables on
+    '''
+
+    short_desc = 'Set user variables on windows'
+    desc = (
+        'Set user variables on the specified windows. User variables are arbitrary name=value pairs'
+        ' that can be used to match windows with the :code:`var` field of the :option:`--match` option'
+        ' of other commands, for example: :code:`kitten @ focus-window --match var:bookmark=logs`.'
+        ' If no = is present, the variable is removed. By default, only the window in which the'
+        ' command is run is affected.'
+    )
+    options_spec = MATCH_WINDOW_OPTION
+    args = RemoteCommand.Args(spec='name1=val name2=val ...', minimum_count=1, json_field='var')
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        if len(args) < 1:
+            self.fatal('Must specify at least one variable to set')
+        var = {}
+        for x in args:
+            if '=' in x:
+                key, val = x.split('=', 1)
+                var[key] = val
+            else:
+                var[x + '='] = ''
+        return {'var': var, 'match': opts.match}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        var = payload_get('var') or {}
+        for w in self.windows_for_match_payload(boss, window, payload_get):
+            if w:
+                for k, v in var.items():
+                    if k.endswith('='):
+                        w.user_vars.pop(k[:-1], None)
+                    else:
+                        w.user_vars[k] = v
+        return None
+
+
+set_user_vars = SetUserVars()
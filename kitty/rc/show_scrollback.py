@@ -0,0 +1,87 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+import re
+from typing import TYPE_CHECKING, Optional
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import ShowScrollbackRCOptions as CLIOptions
+
+
+class NoMatches(ValueError):
+
+    hide_traceback = True
+
+    def __init__(self, pattern: str):
+        ValueError.__init__(self, f'No matches found in the scrollback for: {pattern}')
+
+
+class ShowScrollback(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    match/str: The window whose scrollback is shown
+    self/bool: Boolean indicating whether to use the window the command is run in
+    pattern/str: A regular expression to search for in the scrollback
+    ignore_case/bool: Boolean indicating whether the search ignores case
+    no_pager/bool: Boolean indicating that the matches should only be counted, without showing the pager
+    '''
+
+    short_desc = 'Show the scrollback of a window in the pager, searching for a pattern'
+    desc = (
+        'Show the scrollback of the specified window in the pager from :opt:`scrollback_pager`, the same as'
+        ' the :ac:`show_scrollback` action. If a :italic:`SEARCH_PATTERN` is specified, the lines of the'
+        ' scrollback matching the pattern are counted and the count is printed. When the pager is :program:`less`'
+        ' it starts at the first match. If there are no matches, the pager is not shown and this command fails,'
+        ' which is useful in scripts that look for problems in the output of programs. The pattern is a regular'
+        ' expression, use only syntax that both Python and the pager understand.'
+    )
+    options_spec = MATCH_WINDOW_OPTION + '''\n
+--self
+type=bool-set
+Show the scrollback of the window this command is run in, rather than the active window.
+
+
+--ignore-case -i
+type=bool-set
+Ignore case when searching for the pattern.
+
+
+--no-pager
+type=bool-set
+Only count the lines matching the pattern, do not show the pager.
+'''
+    args = RemoteCommand.Args(spec='[SEARCH_PATTERN]', json_field='pattern')
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        pattern = ' '.join(args)
+        if pattern:
+            try:
+                re.compile(pattern)
+            except re.error as err:
+                self.fatal(f'The search pattern {pattern} is invalid: {err}')
+        elif opts.no_pager:
+            self.fatal('Must specify a search pattern when using --no-pager')
+        return {'match': opts.match, 'self': opts.self, 'pattern': pattern, 'ignore_case': opts.ignore_case, 'no_pager': opts.no_pager}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        windows = self.windows_for_match_payload(boss, window, payload_get)
+        if not windows or windows[0] is None:
+            return None
+        w = windows[0]
+        pattern = payload_get('pattern') or ''
+        ignore_case = bool(payload_get('ignore_case'))
+        if not pattern:
+            w.show_scrollback()
+            return None
+        pat = re.compile(pattern, re.IGNORECASE if ignore_case else 0)
+        num_matches = sum(1 for line in w.as_text(add_history=True).splitlines() if pat.search(line) is not None)
+        if not num_matches:
+            raise NoMatches(pattern)
+        if not payload_get('no_pager'):
+            w.show_scrollback(search=pattern, ignore_case=ignore_case)
+        return str(num_matches)
+
+
+show_scrollback = ShowScrollback()
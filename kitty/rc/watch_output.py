@@ -0,0 +1,221 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+import re
+from time import monotonic
+from typing import TYPE_CHECKING, Any, Dict, List, Optional, Tuple
+
+from kitty.fast_data_types import Line, Screen, add_timer, get_boss, remove_timer
+from kitty.types import AsyncResponse
+
+from .base import MATCH_WINDOW_OPTION, ArgsType, AsyncResponder, Boss, PayloadGetType, PayloadType, RCOptions, RemoteCommand, ResponseType, Window
+
+if TYPE_CHECKING:
+    from kitty.cli_stub import WatchOutputRCOptions as CLIOptions
+
+
+CHECK_INTERVAL = 0.5  # seconds
+# Respond with an empty list of matches after this long so that the client does not time out
+HEARTBEAT_INTERVAL = 5  # seconds
+# Forget watchers whose client has not asked for matches for this long
+ABANDONED_AFTER = 30  # seconds
+
+
+class OutputTracker:
+
+    '''
+    Find the lines of output in a screen that are new or changed since the
+    last check. Lines are identified by their position counted from the first
+    line ever added to the scrollback, so only the lines added to the
+    scrollback since the last check and the lines on the screen are examined.
+    A line that is the same as the last line seen at its position, for
+    example, when a program redraws the screen, is not new. The line the
+    cursor is on is not reported until the cursor leaves it, as it may not
+    be complete.
+    '''
+
+    def __init__(self, screen: Screen):
+        self.baseline(screen)
+
+    def baseline(self, screen: Screen) -> None:
+        self.size = screen.lines, screen.columns
+        self.total = screen.lines_added_to_history
+        # the seen lines for the main and alternate screens
+        self.seen: Dict[bool, Dict[int, str]] = {screen.is_main_linebuf(): {
+            self.total + y: str(screen.line(y)) for y in range(screen.lines)}}
+
+    def line_at(self, screen: Screen, pos: int) -> Line:
+        total = screen.lines_added_to_history
+        return screen.historybuf.line(total - 1 - pos) if pos < total else screen.line(pos - total)
+
+    def new_lines(self, screen: Screen) -> List[str]:
+        if self.size != (screen.lines, screen.columns):
+            # the lines have been re-wrapped so their positions are meaningless
+            self.baseline(screen)
+            return []
+        total = screen.lines_added_to_history
+        oldest = total - screen.historybuf.count
+        first = max(self.total, oldest)
+        # include the start of a line that was wrapped into the first line
+        while first > oldest and self.line_at(screen, first - 1).last_char_has_wrapped_flag():
+            first -= 1
+        cursor_pos = total + screen.cursor.y
+        seen = self.seen.setdefault(screen.is_main_linebuf(), {})
+        now_seen: Dict[int, str] = {}
+        ans: List[str] = []
+        group: List[Tuple[int, str]] = []
+        for pos in range(first, total + screen.lines):
+            line = self.line_at(screen, pos)
+            group.append((pos, str(line)))
+            if line.last_char_has_wrapped_flag() and pos < total + screen.lines - 1:
+                continue
+            if group[0][0] <= cursor_pos <= pos:
+                for gpos, text in group:
+                    if gpos in seen:
+                        now_seen[gpos] = seen[gpos]
+            else:
+                if any(gpos >= self.total and seen.get(gpos) != text for gpos, text in group):
+                    text = ''.join(text for gpos, text in group).rstrip()
+                    if text:
+                        ans.append(text)
+                for gpos, text in group:
+                    # a cleared line keeps its text so that redrawing it is not new
+                    now_seen[gpos] = text if text.strip() or gpos not in seen else seen[gpos]
+            group = []
+        self.total = total
+        self.seen[screen.is_main_linebuf()] = {pos: text for pos, text in now_seen.items() if pos >= total}
+        return ans
+
+
+class Watcher:
+
+    def __init__(self, windows: List[Window], pat: 're.Pattern[str]', notify: bool):
+        self.pat = pat
+        self.notify = notify
+        self.num_windows = len(windows)
+        self.trackers: Dict[int, OutputTracker] = {w.id: OutputTracker(w.screen) for w in windows}
+        self.pending: List[Dict[str, Any]] = []
+        self.responder: Optional[AsyncResponder] = None
+        self.last_request_at = self.waiting_since = monotonic()
+
+    def wait_for_matches(self, responder: AsyncResponder) -> None:
+        self.responder = responder
+        self.last_request_at = self.waiting_since = monotonic()
+        if self.pending:
+            self.send_matches()
+
+    def send_matches(self) -> None:
+        if self.responder is not None:
+            self.responder.send_data({'num_windows': self.num_windows, 'matches': self.pending})
+            self.responder = None
+            self.pending = []
+
+    def check(self, boss: Boss, now: float) -> bool:
+        ' Return False when there is nothing left to watch '
+        if self.responder is None:
+            return now - self.last_request_at < ABANDONED_AFTER
+        for wid, tracker in tuple(self.trackers.items()):
+            w = boss.window_id_map.get(wid)
+            if w is None:
+                del self.trackers[wid]
+                continue
+            for line in tracker.new_lines(w.screen):
+                if self.pat.search(line) is None:
+                    continue
+                self.pending.append({'window_id': wid, 'line': line})
+                if self.notify:
+                    from kitty.notify import notify
+                    notify(f'Output matched in: {w.title}', line)
+        if not self.trackers:
+            if self.pending:
+                self.send_matches()
+            if self.responder is not None:
+                self.responder.send_error('The watched windows have been closed')
+            return False
+        if self.pending or now - self.waiting_since >= HEARTBEAT_INTERVAL:
+            self.send_matches()
+        return True
+
+
+watchers: Dict[str, Watcher] = {}
+timer_id = 0
+
+
+def check_watchers(timer_id_: Optional[int] = None) -> None:
+    global timer_id
+    boss = get_boss()
+    now = monotonic()
+    for async_id, watcher in tuple(watchers.items()):
+        if not watcher.check(boss, now):
+            del watchers[async_id]
+    if not watchers and timer_id:
+        remove_timer(timer_id)
+        timer_id = 0
+
+
+class WatchOutput(RemoteCommand):
+
+    protocol_spec = __doc__ = '''
+    match/str: The windows to watch
+    pattern/str: The regular expression to search for in lines of output
+    notify/bool: Boolean, if True show a desktop notification for every match
+    '''
+
+    short_desc = 'Report output from windows that matches a pattern'
+    desc = (
+        'Watch the output from the specified windows and print every line of output that matches the specified regular expression,'
+        ' until interrupted. Lines already present in the windows when this command is run are ignored. If more than one window is watched,'
+        ' lines are prefixed with the id of the window they came from. Useful for lightweight monitoring of logs, for example:'
+        ' :code:`kitten @ watch-output --match id:3 --pattern "ERROR|panic" --notify`'
+    )
+    options_spec = MATCH_WINDOW_OPTION + '''\n
+--pattern -p
+The regular expression to search for in every line of output. Required.
+
+
+--notify
+type=bool-set
+Show a desktop notification for every matching line.
+
+
+--exec
+Run the specified command for every matching line, with the line as its STDIN
+and the id of the window the line came from in the environment variable
+:code:`KITTY_MATCHED_WINDOW_ID`. The command is run by the client, not by kitty.
+'''
+    client_only_options = frozenset({'exec'})
+    is_asynchronous = True
+    go_response_handler = 'handle_watch_output_response'
+
+    def message_to_kitty(self, global_opts: RCOptions, opts: 'CLIOptions', args: ArgsType) -> PayloadType:
+        if not opts.pattern:
+            self.fatal('Must specify a --pattern to search for')
+        return {'match': opts.match, 'pattern': opts.pattern, 'notify': opts.notify}
+
+    def response_from_kitty(self, boss: Boss, window: Optional[Window], payload_get: PayloadGetType) -> ResponseType:
+        global timer_id
+        async_id = payload_get('async_id')
+        if not async_id:
+            raise ValueError('watch-output must be run asynchronously')
+        responder = self.create_async_responder(payload_get, window)
+        watcher = watchers.get(async_id)
+        if watcher is None:
+            # the same async_id is re-used by the client to get subsequent matches
+            try:
+                pat = re.compile(payload_get('pattern') or '')
+            except re.error as e:
+                raise ValueError(f'Invalid regular expression: {e}')
+            if not pat.pattern:
+                raise ValueError('Must specify a pattern to search for')
+            windows = self.windows_for_match_payload(boss, window, payload_get)
+            watcher = watchers[async_id] = Watcher(windows, pat, bool(payload_get('notify')))
+        watcher.wait_for_matches(responder)
+        if not timer_id:
+            timer_id = add_timer(check_watchers, CHECK_INTERVAL, True)
+        return AsyncResponse()
+
+    def cancel_async_request(self, boss: 'Boss', window: Optional['Window'], payload_get: PayloadGetType) -> None:
+        watchers.pop(payload_get('async_id'), None)
+
+
+watch_output = WatchOutput()
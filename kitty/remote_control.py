@@ -274,6 +274,13 @@ def handle_cmd(
 If no password is available, kitty will usually just send the remote control command
 without a password. This option can be used to force it to :code:`always` or :code:`never` use
 the supplied password.
+
+
+--trace
+type=bool-set
+Print the JSON payload of every remote control command sent to kitty and the
+raw response received from kitty to :file:`STDERR`. Useful for debugging
+scripts and learning the remote control protocol.
 '''.format, appname=appname)
 
 
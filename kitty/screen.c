@@ -1301,6 +1301,7 @@ screen_cursor_to_column(Screen *self, unsigned int column) {
         linebuf_init_line(self->linebuf, bottom); \
         historybuf_add_line(self->historybuf, self->linebuf->line, &self->as_ansi_buf); \
         self->history_line_added_count++; \
+        self->lines_added_to_history++; \
         if (self->last_visited_prompt.is_set) { \
             if (self->last_visited_prompt.scrolled_by < self->historybuf->count) self->last_visited_prompt.scrolled_by++; \
             else self->last_visited_prompt.is_set = false; \
@@ -3914,6 +3915,12 @@ has_activity_since_last_focus(Screen *self, PyObject *args UNUSED) {
     Py_RETURN_FALSE;
 }
 
+static PyObject*
+seconds_since_last_output(Screen *self, PyObject *args UNUSED) {
+    if (!self->last_output_at) Py_RETURN_NONE;
+    return PyFloat_FromDouble(monotonic_t_to_s_double(monotonic() - self->last_output_at));
+}
+
 WRAP2(cursor_position, 1, 1)
 
 #define COUNT_WRAP(name) WRAP1(name, 1)
@@ -4114,6 +4121,7 @@ static PyMethodDef methods[] = {
     MND(focus_changed, METH_O)
     MND(has_focus, METH_NOARGS)
     MND(has_activity_since_last_focus, METH_NOARGS)
+    MND(seconds_since_last_output, METH_NOARGS)
     MND(copy_colors_from, METH_O)
     MND(set_marker, METH_VARARGS)
     MND(marked_cells, METH_NOARGS)
@@ -4156,7 +4164,9 @@ static PyMemberDef members[] = {
     {"margin_top", T_UINT, offsetof(Screen, margin_top), READONLY, "margin_top"},
     {"margin_bottom", T_UINT, offsetof(Screen, margin_bottom), READONLY, "margin_bottom"},
     {"history_line_added_count", T_UINT, offsetof(Screen, history_line_added_count), 0, "history_line_added_count"},
+    {"lines_added_to_history", T_ULONGLONG, offsetof(Screen, lines_added_to_history), READONLY, "lines_added_to_history"},
     {"render_unfocused_cursor", T_UINT, offsetof(Screen, render_unfocused_cursor), 0, "render_unfocused_cursor"},
+    {"render_frozen", T_UINT, offsetof(Screen, render_frozen), 0, "render_frozen"},
     {NULL}
 };
 
@@ -103,6 +103,8 @@ typedef struct {
     GraphicsManager *grman, *main_grman, *alt_grman;
     HistoryBuf *historybuf;
     unsigned int history_line_added_count;
+    // never reset, used to find the lines added to the history since some earlier time
+    unsigned long long lines_added_to_history;
     bool *tabstops, *main_tabstops, *alt_tabstops;
     ScreenModes modes, saved_modes;
     ColorProfile *color_profile;
@@ -112,12 +114,12 @@ typedef struct {
     unsigned int parser_state, parser_text_start, parser_buf_pos;
     bool parser_has_pending_text;
     uint8_t read_buf[READ_BUF_SZ], *write_buf;
-    monotonic_t new_input_at;
+    monotonic_t new_input_at, last_output_at;
     size_t read_buf_sz, write_buf_sz, write_buf_used;
     pthread_mutex_t read_buf_lock, write_buf_lock;
 
     CursorRenderInfo cursor_render_info;
-    unsigned int render_unfocused_cursor;
+    unsigned int render_unfocused_cursor, render_frozen;
 
     struct {
         size_t capacity, used;
@@ -215,6 +215,7 @@ class WindowDict(TypedDict):
     cwd: str
     cmdline: List[str]
     env: Dict[str, str]
+    user_vars: Dict[str, str]
     foreground_processes: List[ProcessDesc]
     is_self: bool
     lines: int
@@ -569,6 +570,7 @@ def __init__(
         self.pty_resized_once = False
         self.last_reported_pty_size = (-1, -1, -1, -1)
         self.needs_attention = False
+        self.user_vars: Dict[str, str] = {}
         self.override_title = override_title
         self.default_title = os.path.basename(child.argv[0] or appname)
         self.child_title = self.default_title
@@ -695,6 +697,7 @@ def as_dict(self, is_focused: bool = False, is_self: bool = False, is_active: bo
             cwd=self.child.current_cwd or self.child.cwd,
             cmdline=self.child.cmdline,
             env=self.child.environ,
+            user_vars=self.user_vars,
             foreground_processes=self.child.foreground_processes,
             is_self=is_self,
             lines=self.screen.lines,
@@ -745,10 +748,10 @@ def has_running_program(self) -> bool:
     def matches(self, field: str, pat: MatchPatternType) -> bool:
         if not pat:
             return False
-        if field == 'env':
+        if field in ('env', 'var'):
             assert isinstance(pat, tuple)
             key_pat, val_pat = pat
-            for key, val in self.child.environ.items():
+            for key, val in (self.child.environ if field == 'env' else self.user_vars).items():
                 if key_pat.search(key) is not None and (
                         val_pat is None or val_pat.search(val) is not None):
                     return True
@@ -803,7 +806,7 @@ def matches_query(self, field: str, query: str, active_tab: Optional[TabType] =
             if query == 'overlay_parent':
                 return self_window is not None and self is self_window.overlay_parent
             return False
-        pat = compile_match_query(query, field != 'env')
+        pat = compile_match_query(query, field not in ('env', 'var'))
         return self.matches(field, pat)
 
     def set_visible_in_layout(self, val: bool) -> None:
@@ -819,6 +822,18 @@ def refresh(self) -> None:
         wakeup_io_loop()
         wakeup_main_loop()
 
+    @property
+    def rendering_frozen(self) -> bool:
+        return bool(self.screen.render_frozen)
+
+    def set_rendering_frozen(self, freeze: bool) -> None:
+        # the screen continues to process output from the child while frozen,
+        # only updates to what is displayed are paused
+        if freeze != self.rendering_frozen:
+            self.screen.render_frozen = int(freeze)
+            if not freeze:
+                self.refresh()
+
     def set_geometry(self, new_geometry: WindowGeometry) -> None:
         if self.destroyed:
             return
@@ -1507,11 +1522,12 @@ def clear_screen(self, reset: bool = False, scrollback: bool = False) -> None:
     # actions {{{
 
     @ac('cp', 'Show scrollback in a pager like less')
-    def show_scrollback(self) -> None:
+    def show_scrollback(self, search: str = '', ignore_case: bool = False) -> None:
         text = self.as_text(as_ansi=True, add_history=True, add_wrap_markers=True)
         data = self.pipe_data(text, has_wrap_markers=True)
         cursor_on_screen = self.screen.scrolled_by < self.screen.lines - self.screen.cursor.y
-        get_boss().display_scrollback(self, data['text'], data['input_line_number'], report_cursor=cursor_on_screen)
+        get_boss().display_scrollback(
+            self, data['text'], data['input_line_number'], report_cursor=cursor_on_screen, search=search, search_ignores_case=ignore_case)
 
     def show_cmd_output(self, which: CommandOutput, title: str = 'Command output', as_ansi: bool = True, add_wrap_markers: bool = True) -> None:
         text = self.cmd_output(which, as_ansi=as_ansi, add_wrap_markers=add_wrap_markers)
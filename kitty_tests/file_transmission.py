@@ -2,16 +2,22 @@
 # License: GPLv3 Copyright: 2021, Kovid Goyal <kovid at kovidgoyal.net>
 
 
+import io
 import os
 import shutil
 import stat
 import tempfile
+import time
 import zlib
+from contextlib import redirect_stdout
 from pathlib import Path
+from types import SimpleNamespace
 
+from kittens.transfer import main as transfer_main
+from kittens.transfer import status as transfer_status
 from kittens.transfer.librsync import LoadSignature, PatchFile, delta_for_file, signature_of_file
 from kittens.transfer.main import parse_transfer_args
-from kittens.transfer.receive import File, files_for_receive
+from kittens.transfer.receive import File, PathOutsideSandbox, ensure_inside_sandbox, files_for_receive
 from kittens.transfer.rsync import decode_utf8_buffer, parse_ftc
 from kittens.transfer.send import files_for_send
 from kittens.transfer.utils import cwd_path, expand_home, home_path, set_paths
@@ -428,6 +434,22 @@ def tf(args, expected, different_home=''):
             self.assertEqual(files[3].ftype, FileType.link)
             self.assertEqual(files[3].remote_target, files[2].remote_id)
 
+    def test_receive_sandbox(self):
+        root = os.path.realpath(os.path.join(self.tdir, 'root'))
+        outside = os.path.realpath(os.path.join(self.tdir, 'outside'))
+        os.makedirs(os.path.join(root, 'd'))
+        os.mkdir(outside)
+        os.symlink(outside, os.path.join(root, 'escape'))
+        os.symlink('d', os.path.join(root, 'inside'))
+        os.symlink(os.path.join(outside, 'x'), os.path.join(root, 'link-out'))
+        self.ae(ensure_inside_sandbox(root, os.path.join(root, 'd', 'f')), os.path.join(root, 'd', 'f'))
+        self.ae(ensure_inside_sandbox(root, os.path.join(root, 'inside', 'f')), os.path.join(root, 'd', 'f'))
+        # replacing a symlink is fine, writing through it is not
+        ensure_inside_sandbox(root, os.path.join(root, 'link-out'), follow_final_symlink=False)
+        for path in ('escape/f', 'link-out', '../outside/f', '..', 'escape/d/f'):
+            with self.assertRaises(PathOutsideSandbox, msg=path):
+                ensure_inside_sandbox(root, os.path.join(root, path))
+
     def test_path_mapping_send(self):
         opts = parse_transfer_args([])[0]
         b = Path(os.path.join(self.tdir, 'b'))
@@ -475,3 +497,70 @@ def tf(args, expected):
             files = gm(b / 'h', b / 'r', 'dest')
             self.ae(files[1].file_type, FileType.link)
             self.ae(files[1].hard_link_target, '1')
+
+    def test_transfer_history(self):
+        orig = os.environ.get('KITTY_CACHE_DIRECTORY'), transfer_status.max_history_size, os.getcwd()
+        orig_main = transfer_main.send_main, transfer_main.receive_main
+        os.environ['KITTY_CACHE_DIRECTORY'] = self.tdir
+        try:
+            self._test_transfer_history()
+        finally:
+            if orig[0] is None:
+                os.environ.pop('KITTY_CACHE_DIRECTORY', None)
+            else:
+                os.environ['KITTY_CACHE_DIRECTORY'] = orig[0]
+            transfer_status.max_history_size = orig[1]
+            os.chdir(orig[2])
+            transfer_main.send_main, transfer_main.receive_main = orig_main
+
+    def _test_transfer_history(self):
+        cwd = os.path.join(self.tdir, 'cwd')
+        os.mkdir(cwd)
+
+        def record(*args, ok=True):
+            cli_opts, items = parse_transfer_args(['transfer', *args])
+            status = SimpleNamespace(data={
+                'direction': cli_opts.direction, 'cwd': cwd, 'started_at': time.time(),
+                'files_done': 1, 'total_files': 2, 'bytes_so_far': 10, 'total_bytes': 20})
+            transfer_status.record_transfer(cli_opts, items, status, ok)
+
+        def log(*filters):
+            buf = io.StringIO()
+            with redirect_stdout(buf):
+                transfer_status.print_history(filters)
+            return buf.getvalue()
+
+        self.ae(transfer_status.load_history(), [])
+        self.assertIn('No transfers', log())
+        record('a', 'b')
+        record('--direction=receive', '--mode=mirror', '--transmit-deltas', 'c', 'd', ok=False)
+        h = transfer_status.load_history()
+        self.ae([t['id'] for t in h], [1, 2])
+        self.ae([(t['direction'], t['mode'], t['transmit_deltas'], t['status'], t['args'], t['cwd']) for t in h], [
+            ('send', 'normal', False, 'ok', ['a', 'b'], cwd), ('receive', 'mirror', True, 'failed', ['c', 'd'], cwd)])
+        self.ae([(t['files_done'], t['total_files'], t['bytes_so_far'], t['total_bytes']) for t in h], [(1, 2, 10, 20)] * 2)
+        q = log()
+        self.assertIn('a b', q)
+        self.assertIn('c d', q)
+        q = log('failed')
+        self.assertNotIn('a b', q)
+        self.assertIn('c d', q)
+        self.assertIn('No transfers match', log('nothing'))
+
+        # repeating runs the transfer again, with the same options, files and working directory
+        calls = []
+        transfer_main.send_main = lambda cli_opts, items: calls.append(('send', cli_opts, items, os.getcwd()))
+        transfer_main.receive_main = lambda cli_opts, items: calls.append(('receive', cli_opts, items, os.getcwd()))
+        transfer_main.main(['transfer', '--repeat', '2'])
+        self.ae(len(calls), 1)
+        direction, cli_opts, items, repeat_cwd = calls[0]
+        self.ae((direction, cli_opts.mode, cli_opts.transmit_deltas, items), ('receive', 'mirror', True, ['c', 'd']))
+        self.assertPathEqual(repeat_cwd, cwd)
+        self.assertRaises(SystemExit, transfer_main.main, ['transfer', '--repeat', '3'])
+        self.ae(len(calls), 1)
+
+        # only the most recent transfers are remembered
+        transfer_status.max_history_size = 3
+        for i in range(3):
+            record(f'x{i}', 'dest')
+        self.ae([t['id'] for t in transfer_status.load_history()], [3, 4, 5])
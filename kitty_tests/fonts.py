@@ -9,7 +9,7 @@
 from functools import partial
 
 from kitty.constants import is_macos, read_kitty_resource
-from kitty.fast_data_types import DECAWM, get_fallback_font, sprite_map_set_layout, sprite_map_set_limits, test_render_line, test_sprite_position_for, wcwidth
+from kitty.fast_data_types import DECAWM, current_fonts, font_for_text, get_fallback_font, sprite_map_set_layout, sprite_map_set_limits, test_render_line, test_sprite_position_for, wcwidth
 from kitty.fonts.box_drawing import box_chars
 from kitty.fonts.render import coalesce_symbol_maps, render_string, setup_for_testing, shape_string
 
@@ -146,6 +146,28 @@ def test_emoji_presentation(self):
         self.ae((s.cursor.x, s.cursor.y), (2, 4))
         self.ae(str(s.line(s.cursor.y)), '\u2716\ufe0f')
 
+    def test_font_for_text(self):
+        from types import SimpleNamespace
+
+        from kitty.rc.list_fonts import list_fonts, split_into_cells
+        cf = current_fonts()
+        self.ae(font_for_text('a', False, False), ('main', cf['medium']))
+        self.ae(font_for_text(' ', False, False), ('blank', None))
+        self.ae(font_for_text('\u2500', False, False), ('box', None))
+        self.assertRaises(KeyError, font_for_text, 'a', False, False, 12345)
+        self.ae(list(split_into_cells('ae\u0301\u2716\ufe0f')), ['a', 'e\u0301', '\u2716\ufe0f'])
+
+        self.ae(list_fonts.message_to_kitty(None, SimpleNamespace(match='', bold=True, italic=False), ['ab']), {
+            'match': '', 'characters': ['ab'], 'bold': True, 'italic': False})
+        w = SimpleNamespace(os_window_id=0)
+        payload = {'characters': ['a \u2500']}
+        lines = list_fonts.response_from_kitty(SimpleNamespace(active_window=w), w, payload.get).splitlines()
+        self.ae(lines[0], 'Fonts for OS window 0:')
+        self.assertTrue(lines[1].startswith('  Regular:'), lines[1])
+        idx = lines.index('Fonts used for characters:')
+        self.assertTrue(lines[idx + 1].startswith('  a U+0061: ') and lines[idx + 1].endswith('[main font]'), lines[idx + 1])
+        self.ae(lines[idx + 2:], ['    U+0020: blank', '  \u2500 U+2500: drawn by kitty'])
+
     @unittest.skipUnless(is_macos, 'Only macOS has a Last Resort font')
     def test_fallback_font_not_last_resort(self):
         # Ensure that the LastResort font is not reported as a fallback font on
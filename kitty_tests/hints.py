@@ -78,3 +78,12 @@ def create_marks(text, cols=60):
                 marks = create_marks(testcase)
                 ips = [m.text for m in marks]
                 self.ae(ips, expected)
+
+    def test_filter_hints(self):
+        from kittens.hints.main import DEFAULT_HINT_ALPHABET, Mark, filter_marks
+        marks = [Mark(i, 0, 0, text, {}) for i, text in enumerate(('http://one.com', 'http://Two.com', 'http://three.org', 'http://twofold.net'))]
+        a = DEFAULT_HINT_ALPHABET
+        self.ae(filter_marks(marks, 'two', set(), a, 1), {1: '1', 3: '2'})
+        self.ae(filter_marks(marks, 'Two', set(), a, 1), {1: '1'})
+        self.ae(filter_marks(marks, 'two', {1}, a, 0), {3: '0'})
+        self.ae(filter_marks(marks, 'nosuch', set(), a, 1), {})
@@ -0,0 +1,143 @@
+#!/usr/bin/env python
+# License: GPLv3 Copyright: 2022, Kovid Goyal <kovid at kovidgoyal.net>
+
+from types import SimpleNamespace
+
+from kitty.fast_data_types import parse_bytes
+
+from . import BaseTest
+
+
+def payload(**kw):
+    return kw.get
+
+
+class TestRemoteControl(BaseTest):
+
+    def test_get_scrollback_stats(self):
+        import json
+
+        from kitty.rc.get_scrollback_stats import get_scrollback_stats
+        self.ae(get_scrollback_stats.message_to_kitty(None, SimpleNamespace(match='id:1'), []), {'match': 'id:1'})
+        small, big = self.create_screen(cols=10, lines=5, scrollback=20), self.create_screen(cols=20, lines=5, scrollback=2000)
+        parse_bytes(big, b'line\r\n' * 100)
+        windows = [SimpleNamespace(id=1, title='small', screen=small), SimpleNamespace(id=2, title='big', screen=big)]
+        boss = SimpleNamespace(all_windows=windows)
+        stats = json.loads(get_scrollback_stats.response_from_kitty(boss, None, payload()))
+        self.ae([s['id'] for s in stats], [2, 1])
+        self.ae(stats[0]['title'], 'big')
+        self.ae(stats[0]['scrollback_lines'], 96)
+        self.ae(stats[0]['scrollback_capacity'], 2000)
+        self.ae(stats[0]['images'], 0)
+        self.assertIsNone(stats[0]['last_output_at'])
+        self.ae(stats[1]['scrollback_lines'], 0)
+        for s in stats:
+            self.ae(s['total_bytes'], s['scrollback_bytes'] + s['pager_history_bytes'] + s['image_bytes'])
+
+    def test_export_session(self):
+        from kitty.fast_data_types import get_options
+        from kitty.rc.export_session import export_session
+        from kitty.utils import resolved_shell
+        self.ae(export_session.message_to_kitty(None, SimpleNamespace(match='', use_foreground_processes=True), []), {
+            'match': '', 'use_foreground_processes': True})
+
+        def window(cwd, cmdline, foreground=(), title=None):
+            return SimpleNamespace(
+                cwd_of_child=cwd, override_title=title, root_in_foreground_processes=not foreground,
+                child=SimpleNamespace(cmdline=cmdline, foreground_processes=[{'cmdline': list(foreground)}]))
+
+        def tab(name, *windows, active=0):
+            groups = [SimpleNamespace(main_window_id=i) for i in range(len(windows))]
+            return SimpleNamespace(name=name, enabled_layouts=['tall', 'stack'], current_layout=SimpleNamespace(full_name='tall'), windows=SimpleNamespace(
+                active_group=groups[active], iter_all_layoutable_groups=lambda: groups, id_map=dict(enumerate(windows))))
+
+        shell = resolved_shell(get_options())
+        t1 = tab('one', window('/a', shell, foreground=['vim', 'x y']), window('/b b', ['htop'], title='top'), active=1)
+        t2 = tab('', window('', shell))
+
+        class TabManager:
+            wm_class, active_tab = 'work', t1
+
+            def __iter__(self):
+                return iter((t1, t2))
+
+        tm = TabManager()
+        boss = SimpleNamespace(all_tab_managers=[tm], active_tab_manager=tm)
+        expected = [
+            'os_window_class work',
+            'new_tab one', 'enabled_layouts tall,stack', 'layout tall', 'launch --cwd=/a', "launch '--cwd=/b b' --title=top -- htop", 'focus',
+            'new_tab', 'enabled_layouts tall,stack', 'layout tall', 'launch',
+            'focus_os_window',
+        ]
+        self.ae(export_session.response_from_kitty(boss, None, payload()).splitlines(), expected)
+        # the foreground program is used instead of the shell
+        expected[4] = "launch --cwd=/a -- vim 'x y'"
+        self.ae(export_session.response_from_kitty(boss, None, payload(use_foreground_processes=True)).splitlines(), expected)
+
+    def test_show_scrollback(self):
+        from kitty.rc.show_scrollback import NoMatches, show_scrollback
+
+        def opts(**kw):
+            ans = SimpleNamespace(match='', self=False, ignore_case=False, no_pager=False)
+            ans.__dict__.update(kw)
+            return ans
+        self.ae(show_scrollback.message_to_kitty(None, opts(ignore_case=True), ['err', 'or']), {
+            'match': '', 'self': False, 'pattern': 'err or', 'ignore_case': True, 'no_pager': False})
+        self.assertRaises(SystemExit, show_scrollback.message_to_kitty, None, opts(), ['a('])
+        self.assertRaises(SystemExit, show_scrollback.message_to_kitty, None, opts(no_pager=True), [])
+
+        shown = []
+        w = SimpleNamespace(
+            as_text=lambda add_history=False: 'ok\nERROR one\nfine\nerror two\n',
+            show_scrollback=lambda search='', ignore_case=False: shown.append((search, ignore_case)))
+        boss = SimpleNamespace(active_window=w)
+        self.assertIsNone(show_scrollback.response_from_kitty(boss, w, payload()))
+        self.ae(shown, [('', False)])
+        del shown[:]
+        self.ae(show_scrollback.response_from_kitty(boss, w, payload(pattern='ERROR')), '1')
+        self.ae(show_scrollback.response_from_kitty(boss, w, payload(pattern='error', ignore_case=True)), '2')
+        self.ae(shown, [('ERROR', False), ('error', True)])
+        del shown[:]
+        self.ae(show_scrollback.response_from_kitty(boss, w, payload(pattern='o', no_pager=True)), '3')
+        self.assertRaises(NoMatches, show_scrollback.response_from_kitty, boss, w, payload(pattern='missing'))
+        self.ae(shown, [])
+
+    def test_watch_output(self):
+        from kitty.rc.watch_output import OutputTracker
+        s = self.create_screen(cols=10, lines=5, scrollback=20)
+
+        def w(text):
+            parse_bytes(s, text.replace('\n', '\r\n').encode('utf-8'))
+
+        w('old\nold\n')
+        t = OutputTracker(s)
+        self.ae(t.new_lines(s), [])
+        w('a\nb\n')
+        self.ae(t.new_lines(s), ['a', 'b'])
+        # repeated lines are reported even when identical lines scroll out
+        w('a\na\na\na\na\n')
+        self.ae(t.new_lines(s), ['a'] * 5)
+        # the line the cursor is on is not reported till it is complete
+        w('x')
+        self.ae(t.new_lines(s), [])
+        w('yz\nlong line that wraps\n')
+        self.ae(t.new_lines(s), ['xyz', 'long line that wraps'])
+        # redrawing the screen does not report the old lines again
+        w('e1\ne2\n')
+        self.ae(t.new_lines(s), ['e1', 'e2'])
+        w('\x1b[H\x1b[2J')
+        self.ae(t.new_lines(s), [])
+        w('\x1b[3;1He1\ne2\n')
+        self.ae(t.new_lines(s), [])
+        # neither does switching to the alternate screen and back
+        w('\x1b[?1049hfull screen\n')
+        self.ae(t.new_lines(s), ['full screen'])
+        w('\x1b[?1049l')
+        self.ae(t.new_lines(s), [])
+        w('new\n')
+        self.ae(t.new_lines(s), ['new'])
+        # resizing re-wraps the lines, so nothing is reported
+        s.resize(5, 8)
+        self.ae(t.new_lines(s), [])
+        w('after\n')
+        self.ae(t.new_lines(s), ['after'])
@@ -3,6 +3,7 @@
 
 
 import glob
+import json
 import os
 import shutil
 import tempfile
@@ -10,9 +11,10 @@
 from functools import lru_cache
 
 from kittens.ssh.config import load_config
-from kittens.ssh.main import bootstrap_script, get_connection_data, wrap_bootstrap_script
+from kittens.ssh.main import bootstrap_script, get_connection_data, make_tarfile, run_in_container, wrap_bootstrap_script
 from kittens.ssh.options.types import Options as SSHOptions
 from kittens.ssh.options.utils import DELETE_ENV_VAR
+from kittens.ssh.utils import set_remote_command_in_cmdline
 from kittens.transfer.utils import set_paths
 from kitty.constants import is_macos, runtime_dir
 from kitty.fast_data_types import CURSOR_BEAM, shm_unlink
@@ -56,8 +58,31 @@ def t(cmdline, binary='ssh', host='main', port=None, identity_file='', extra_arg
         t('ssh -p 33 main', port=33)
         t('ssh -p 34 ssh://un@ip:33/', host='un@ip', port=34)
         t('ssh --kitten=one -p 12 --kitten two -ix main', identity_file='x', port=12, extra_args=(('--kitten', 'one'), ('--kitten', 'two')))
+        t('ssh --container=web main', extra_args=(('--container', 'web'),))
         self.assertTrue(runtime_dir())
 
+    def test_ssh_container(self):
+        rcmd = ['exec', 'sh', '-c', 'unwrap', 'script']
+        self.ae(run_in_container(rcmd, 'web'), ['exec', 'docker', 'exec', '-it', 'web', 'sh', '-c', 'unwrap', 'script'])
+        self.ae(run_in_container(rcmd, 'podman:web', interactive=False), ['exec', 'podman', 'exec', '-i', 'web', 'sh', '-c', 'unwrap', 'script'])
+        self.ae(run_in_container(rcmd, 'kubectl:pod')[:6], ['exec', 'kubectl', 'exec', '-it', 'pod', '--'])
+        self.assertRaises(ValueError, run_in_container, rcmd, 'lxc:web')
+        self.assertRaises(ValueError, run_in_container, rcmd, 'docker:')
+        self.ae(run_in_container(rcmd, 'my_web-1.2')[4], 'my_web-1.2')
+        for name in ('web;rm -rf ~', 'web $(id)', '-web', 'we b', "web'", 'wéb'):
+            self.assertRaises(ValueError, run_in_container, rcmd, name)
+
+    def test_ssh_remote_command(self):
+        cmd = ['kitty', '+kitten', 'transfer', 'a b']
+        for argv, expected in (
+            (['--kitten', 'cwd=/x', 'host'], ['-t', '--kitten', 'cwd=/x', 'host']),
+            (['-p', '22', 'host', 'tmux', 'a'], ['-t', '-p', '22', 'host']),
+            (['host', '--', 'ls'], ['-t', 'host', '--']),
+        ):
+            argv = ['kitty', '+kitten', 'ssh'] + argv
+            set_remote_command_in_cmdline(cmd, argv)
+            self.ae(argv, ['kitty', '+kitten', 'ssh'] + expected + ["kitty +kitten transfer 'a b'"])
+
     def test_ssh_config_parsing(self):
         def parse(conf, hostname='unmatched_host', username=''):
             return load_config(overrides=conf.splitlines(), hostname=hostname, username=username)
@@ -84,6 +109,34 @@ def test_ssh_bootstrap_sh_cmd_limit(self):
         rcmd = wrap_bootstrap_script(sh_script, 'sh')
         self.assertLessEqual(sum(len(x) for x in rcmd), 9000)
 
+    def test_ssh_high_latency(self):
+        import io
+        import tarfile
+
+        def contents(shell_integration, high_latency):
+            opts = SSHOptions({'shell_integration': shell_integration})
+            data = make_tarfile(opts, {}, 'bz2', high_latency=high_latency)
+            with tarfile.open(fileobj=io.BytesIO(data), mode='r:bz2') as tf:
+                names = tf.getnames()
+                env = tf.extractfile('data.sh').read().decode('utf-8')
+            ksi = set()
+            for line in env.splitlines():
+                k, v = (json.loads(line.partition(' ')[2]) + [''])[:2]
+                if k == 'KITTY_SHELL_INTEGRATION':
+                    ksi = set(v.split())
+            return ksi, [x for x in names if '/completions/' in x or '/vendor_completions.d/' in x]
+
+        ksi, completions = contents('enabled', False)
+        self.ae(ksi, {'enabled'})
+        self.assertTrue(completions)
+        ksi, completions = contents('enabled', True)
+        self.ae(ksi, {'enabled', 'no-complete', 'no-cwd'})
+        self.ae(completions, [])
+        ksi, completions = contents('enabled no-cwd', True)
+        self.ae(ksi, {'enabled', 'no-cwd', 'no-complete'})
+        ksi, completions = contents('disabled', True)
+        self.ae(ksi, set())
+
     @property
     @lru_cache()
     def all_possible_sh(self):
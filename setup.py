@@ -1043,6 +1043,10 @@ def copy_man_pages(ddir: str) -> None:
         os.makedirs(os.path.join(mandir, f'man{x}'))
         for y in glob.glob(os.path.join(src, f'*.{x}')):
             shutil.copy2(y, os.path.join(mandir, f'man{x}'))
+    kitten_exe = os.path.join(ddir, 'bin', 'kitten')
+    if os.path.exists(kitten_exe):
+        # man pages for kitten and its sub-commands are generated from their command line definitions
+        subprocess.check_call([kitten_exe, '__generate_man__', os.path.join(mandir, 'man1')])
 
 
 def copy_html_docs(ddir: str) -> None:
@@ -54,4 +54,60 @@ func init() {
 	input_parsers["bash"] = shell_input_parser
 	output_serializers["bash"] = bash_output_serializer
 	init_completions["bash"] = bash_init_completions
+	static_completion_generators["bash"] = bash_static_completion_script
+}
+
+func bash_static_completion_script(exe *static_exe) string {
+	output := strings.Builder{}
+	f := func(format string, args ...any) { fmt.Fprintf(&output, format+"\n", args...) }
+	fname := "_ksi_static_" + exe.identifier()
+	f("%s() {", fname)
+	f(`    local cur="${COMP_WORDS[COMP_CWORD]}" prev="" cmd_path="" w i`)
+	f(`    (( COMP_CWORD > 0 )) && prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	if paths := exe.sub_command_paths(); len(paths) > 0 {
+		f(`    for ((i=1; i < COMP_CWORD; i++)); do`)
+		f(`        w="${cmd_path:+$cmd_path }${COMP_WORDS[i]}"`)
+		f(`        case "$w" in %s) cmd_path="$w";; esac`, sh_case_pattern(paths...))
+		f(`    done`)
+	}
+	f(`    local words=""`)
+	f(`    case "$cmd_path:$prev" in`)
+	for _, c := range exe.commands {
+		for _, o := range c.options {
+			if o.takes_value {
+				// with no matches, bash falls back to completing file names
+				f(`        %s) COMPREPLY=($(compgen -W %s -- "$cur")); return;;`, sh_case_pattern(c.value_option_keys(o)...), utils.QuoteStringForSH(strings.Join(o.choices, " ")))
+			}
+		}
+	}
+	f(`    esac`)
+	f(`    if [[ "$cur" == -* ]]; then`)
+	f(`        case "$cmd_path" in`)
+	for _, c := range exe.commands {
+		words := make([]string, 0, len(c.options))
+		for _, o := range c.options {
+			words = append(words, o.all_aliases()...)
+		}
+		if len(words) > 0 {
+			f(`            %s) words=%s;;`, sh_case_pattern(c.path), utils.QuoteStringForSH(strings.Join(words, " ")))
+		}
+	}
+	f(`        esac`)
+	f(`    else`)
+	f(`        case "$cmd_path" in`)
+	for _, c := range exe.commands {
+		words := make([]string, len(c.sub_commands))
+		for i, sc := range c.sub_commands {
+			words[i] = sc.name()
+		}
+		if len(words) > 0 {
+			f(`            %s) words=%s;;`, sh_case_pattern(c.path), utils.QuoteStringForSH(strings.Join(words, " ")))
+		}
+	}
+	f(`        esac`)
+	f(`    fi`)
+	f(`    COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	f("}")
+	f("complete -o default -F %s %s", fname, utils.QuoteStringForSH(exe.name))
+	return output.String()
 }
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"kitty/tools/utils"
 )
@@ -20,6 +21,11 @@ type Command struct {
 	Name, Group                       string
 	Usage, ShortDescription, HelpText string
 	Hidden                            bool
+	// Alternate names for this command, such as abbreviations, that can be
+	// used in place of its name when it is a sub-command
+	Aliases []string
+	// Examples of how to run this command, shown in its help and man page
+	Examples []Example
 
 	// Number of non-option arguments after which to stop parsing options. 0 means no options after the first non-option arg.
 	AllowOptionsAfterArgs int
@@ -111,10 +117,12 @@ func (self *Command) Validate() error {
 	seen_sc := make(map[string]bool)
 	for _, g := range self.SubCommandGroups {
 		for _, sc := range g.SubCommands {
-			if seen_sc[sc.Name] {
-				return &ParseError{Message: fmt.Sprintf("The sub-command :yellow:`%s` occurs twice inside %s", sc.Name, self.Name)}
+			for _, name := range append([]string{sc.Name}, sc.Aliases...) {
+				if seen_sc[name] {
+					return &ParseError{Message: fmt.Sprintf("The sub-command :yellow:`%s` occurs twice inside %s", name, self.Name)}
+				}
+				seen_sc[name] = true
 			}
-			seen_sc[sc.Name] = true
 			err := sc.Validate()
 			if err != nil {
 				return err
@@ -319,8 +327,10 @@ func (self *Command) SuggestionsForCommand(name string, max_distance int /* good
 	q := strings.ToLower(name)
 	for _, g := range self.SubCommandGroups {
 		for _, sc := range g.SubCommands {
-			if utils.LevenshteinDistance(sc.Name, q, true) <= max_distance {
-				ans = append(ans, sc.Name)
+			for _, name := range append([]string{sc.Name}, sc.Aliases...) {
+				if utils.LevenshteinDistance(name, q, true) <= max_distance {
+					ans = append(ans, name)
+				}
 			}
 		}
 	}
@@ -495,6 +505,11 @@ func (self *Command) GetOptionValues(pointer_to_options_struct any) error {
 				return fmt.Errorf("The value: %f is too large for the integer type used for the option: %s", v, field_name)
 			}
 			f.SetFloat(v)
+		case DurationOption:
+			if f.Type() != reflect.TypeOf(time.Duration(0)) {
+				return fmt.Errorf("The field: %s must be a time.Duration", field_name)
+			}
+			f.SetInt(int64(opt.parsed_value().(time.Duration)))
 		case BoolOption:
 			if f.Kind() != reflect.Bool {
 				return fmt.Errorf("The field: %s must be a boolean", field_name)
@@ -531,7 +546,7 @@ func (self *Command) Exec(args ...string) {
 	cmd, err := root.ParseArgs(args)
 	if err != nil {
 		ShowError(err)
-		os.Exit(1)
+		os.Exit(ExitCodeForError(err))
 	}
 	help_opt := cmd.option_map["Help"]
 	version_opt := root.option_map["Version"]
@@ -547,7 +562,7 @@ func (self *Command) Exec(args ...string) {
 		if err != nil {
 			ShowError(err)
 			if exit_code == 0 {
-				exit_code = 1
+				exit_code = ExitCodeForError(err)
 			}
 		}
 	}
@@ -577,5 +592,6 @@ func (self *Command) GetCompletions(argv []string, init_completions func(*Comple
 		}
 	}
 	ans.Groups = non_empty_groups
+	ans.sort_matches()
 	return &ans
 }
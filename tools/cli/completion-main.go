@@ -83,19 +83,18 @@ func GenerateCompletions(args []string) error {
 	if err != nil {
 		return err
 	}
-	var root = NewRootCommand()
-	for _, re := range registered_exes {
-		re(root)
-	}
-
-	err = root.Validate()
-	if err != nil {
-		return err
-	}
+	model := NewCompletionModel(func(root *Command) {
+		for _, re := range registered_exes {
+			re(root)
+		}
+	})
 	all_completions := make([]*Completions, 0, 1)
 	for _, argv := range all_argv {
-		all_completions = append(all_completions, root.GetCompletions(argv, init_completions[output_type]))
-		root.ResetAfterParseArgs()
+		c, err := model.GetCompletions(argv, init_completions[output_type])
+		if err != nil {
+			return err
+		}
+		all_completions = append(all_completions, c)
 	}
 	output, err := output_serializer(all_completions, shell_state)
 	if err == nil {
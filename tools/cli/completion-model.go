@@ -0,0 +1,45 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// A tree of commands used for completion that is built the first time it is
+// needed and re-used thereafter, so that repeated completions, for example in
+// an interactive shell, do not rebuild all the commands and options each time
+type CompletionModel struct {
+	build func(root *Command)
+	root  *Command
+	err   error
+}
+
+// The build function is called with the root command to add sub-commands to
+// it, the first time completions are needed
+func NewCompletionModel(build func(root *Command)) *CompletionModel {
+	return &CompletionModel{build: build}
+}
+
+// The root command of the tree, built and validated if needed
+func (self *CompletionModel) Root() (*Command, error) {
+	if self.root == nil && self.err == nil {
+		root := NewRootCommand()
+		self.build(root)
+		if self.err = root.Validate(); self.err == nil {
+			self.root = root
+		}
+	}
+	return self.root, self.err
+}
+
+func (self *CompletionModel) GetCompletions(argv []string, init_completions func(*Completions)) (*Completions, error) {
+	root, err := self.Root()
+	if err != nil {
+		return nil, err
+	}
+	defer root.ResetAfterParseArgs()
+	return root.GetCompletions(argv, init_completions), nil
+}
@@ -25,8 +25,7 @@ func (self *Completions) add_options_group(options []*Option, word string) {
 		}
 		for _, opt := range options {
 			for _, q := range opt.Aliases {
-				if strings.HasPrefix(q.String(), word) {
-					group.Matches = append(group.Matches, &Match{Word: q.String(), Description: opt.Help})
+				if self.AddMatchIfMatches(group, word, q.String(), opt.Help) != nil {
 					break
 				}
 			}
@@ -104,13 +103,11 @@ func complete_word(word string, completions *Completions, only_args_allowed bool
 				group.Title = "Sub-commands"
 			}
 			for _, sc := range cg.SubCommands {
-				if strings.HasPrefix(sc.Name, word) {
-					t := sc.ShortDescription
-					if t == "" {
-						t = sc.HelpText
-					}
-					group.AddMatch(sc.Name, t)
+				t := sc.ShortDescription
+				if t == "" {
+					t = sc.HelpText
 				}
+				completions.AddMatchIfMatches(group, word, sc.Name, t)
 			}
 		}
 		if cmd.SubCommandIsOptional && cmd.ArgCompleter != nil {
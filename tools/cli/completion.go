@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
+	"kitty/tools/cli/markup"
 	"kitty/tools/utils"
+	"kitty/tools/utils/style"
 	"kitty/tools/wcswidth"
+
+	"golang.org/x/exp/slices"
 )
 
 var _ = fmt.Print
@@ -16,12 +21,51 @@ var _ = fmt.Print
 type Match struct {
 	Word        string `json:"word,omitempty"`
 	Description string `json:"description,omitempty"`
+	// The positions, in runes, of the characters in Word that matched the
+	// word being completed, used to highlight them
+	MatchedPositions []int `json:"-"`
+
+	score int
+}
+
+// The word with the characters that matched the word being completed
+// highlighted
+func (self *Match) HighlightedWord(f *markup.Context) string {
+	if len(self.MatchedPositions) == 0 || !f.EscapeCodesAllowed() {
+		return self.Word
+	}
+	runes := []rune(self.Word)
+	ans := strings.Builder{}
+	prev := 0
+	for _, pos := range self.MatchedPositions {
+		if pos < prev || pos >= len(runes) {
+			continue
+		}
+		ans.WriteString(string(runes[prev:pos]))
+		ans.WriteString(f.Emph(string(runes[pos])))
+		prev = pos + 1
+	}
+	ans.WriteString(string(runes[prev:]))
+	return ans.String()
+}
+
+func (self *Match) shift_matched_positions(amt int) {
+	if len(self.MatchedPositions) > 0 {
+		positions := make([]int, 0, len(self.MatchedPositions))
+		for _, pos := range self.MatchedPositions {
+			if pos+amt >= 0 {
+				positions = append(positions, pos+amt)
+			}
+		}
+		self.MatchedPositions = positions
+	}
 }
 
 type MatchGroup struct {
 	Title           string   `json:"title,omitempty"`
 	NoTrailingSpace bool     `json:"no_trailing_space,omitempty"`
 	IsFiles         bool     `json:"is_files,omitempty"`
+	IsChoices       bool     `json:"is_choices,omitempty"`
 	Matches         []*Match `json:"matches,omitempty"`
 }
 
@@ -53,14 +97,18 @@ func (self *MatchGroup) AddMatch(word string, description ...string) *Match {
 }
 
 func (self *MatchGroup) AddPrefixToAllMatches(prefix string) {
+	n := utf8.RuneCountInString(prefix)
 	for _, m := range self.Matches {
 		m.Word = prefix + m.Word
+		m.shift_matched_positions(n)
 	}
 }
 
 func (self *MatchGroup) remove_prefix_from_all_matches(prefix string) {
+	n := utf8.RuneCountInString(prefix)
 	for _, m := range self.Matches {
 		m.Word = m.Word[len(prefix):]
+		m.shift_matched_positions(-n)
 	}
 }
 
@@ -103,9 +151,18 @@ type Delegate struct {
 	Command     string `json:"command,omitempty"`
 }
 
+// Controls how candidates are matched against the word being completed
+type CompletionOptions struct {
+	// Match candidates that contain the characters of the word in order, for
+	// example, focus-window for fw, instead of only candidates that start
+	// with the word. Such matches are sorted with the best matches first.
+	Fuzzy bool
+}
+
 type Completions struct {
-	Groups   []*MatchGroup `json:"groups,omitempty"`
-	Delegate Delegate      `json:"delegate,omitempty"`
+	Groups   []*MatchGroup     `json:"groups,omitempty"`
+	Delegate Delegate          `json:"delegate,omitempty"`
+	Options  CompletionOptions `json:"-"`
 
 	CurrentCmd             *Command `json:"-"`
 	AllWords               []string `json:"-"` // all words passed to parse_args()
@@ -132,17 +189,107 @@ func (self *Completions) AddMatchGroup(title string) *MatchGroup {
 	return &ans
 }
 
+// Add candidate to the group if it matches word, as specified by the
+// completion options, returning the match or nil if candidate does not match
+func (self *Completions) AddMatchIfMatches(group *MatchGroup, word, candidate string, description ...string) *Match {
+	var m *Match
+	if self.Options.Fuzzy {
+		score, positions := utils.FuzzyMatch(word, candidate)
+		if score < 0 {
+			return nil
+		}
+		m = group.AddMatch(candidate, description...)
+		m.score, m.MatchedPositions = score, positions
+	} else {
+		if !strings.HasPrefix(candidate, word) {
+			return nil
+		}
+		m = group.AddMatch(candidate, description...)
+	}
+	return m
+}
+
+// Sort fuzzy matches so that the best matches are first
+func (self *Completions) sort_matches() {
+	if self.Options.Fuzzy {
+		for _, g := range self.Groups {
+			slices.SortStableFunc(g.Matches, func(a, b *Match) bool { return a.score > b.score })
+		}
+	}
+}
+
 type CompletionFunc = func(completions *Completions, word string, arg_num int)
 
 func NamesCompleter(title string, names ...string) CompletionFunc {
 	return func(completions *Completions, word string, arg_num int) {
 		mg := completions.AddMatchGroup(title)
 		for _, q := range names {
-			if strings.HasPrefix(q, word) {
-				mg.AddMatch(q)
+			completions.AddMatchIfMatches(mg, word, q)
+		}
+	}
+}
+
+// Same as NamesCompleter() except that the names are marked as being the
+// only values the option accepts, so interactive completion can cycle through
+// them in place
+func ChoicesCompleter(title string, names ...string) CompletionFunc {
+	return func(completions *Completions, word string, arg_num int) {
+		mg := completions.AddMatchGroup(title)
+		mg.IsChoices = true
+		for _, q := range names {
+			completions.AddMatchIfMatches(mg, word, q)
+		}
+	}
+}
+
+var _color_names_for_completion []string
+
+func color_names_for_completion() []string {
+	if _color_names_for_completion == nil {
+		_color_names_for_completion = make([]string, 0, len(style.ColorNames))
+		for name := range style.ColorNames {
+			if !strings.Contains(name, " ") {
+				_color_names_for_completion = append(_color_names_for_completion, name)
 			}
 		}
+		slices.Sort(_color_names_for_completion)
 	}
+	return _color_names_for_completion
+}
+
+// Complete settings of the form key=color, such as background=red. Keys are
+// completed from names and values from the known color names.
+func ColorSettingsCompleter(title string, names ...string) CompletionFunc {
+	return func(completions *Completions, word string, arg_num int) {
+		key, val, found := utils.Cut(word, "=")
+		if !found {
+			mg := completions.AddMatchGroup(title)
+			mg.NoTrailingSpace = true
+			for _, q := range names {
+				completions.AddMatchIfMatches(mg, word, q+"=")
+			}
+			return
+		}
+		mg := completions.AddMatchGroup("Color names")
+		for _, q := range color_names_for_completion() {
+			completions.AddMatchIfMatches(mg, val, q)
+		}
+		mg.AddPrefixToAllMatches(key + "=")
+	}
+}
+
+// The colors most commonly changed, used for completion
+var color_settings_for_completion = []string{
+	"foreground", "background", "selection_foreground", "selection_background", "cursor", "cursor_text_color",
+	"url_color", "active_border_color", "inactive_border_color", "bell_border_color",
+	"color0", "color1", "color2", "color3", "color4", "color5", "color6", "color7",
+	"color8", "color9", "color10", "color11", "color12", "color13", "color14", "color15",
+}
+
+// Complete either paths to .conf files or settings of the form key=color
+func CompleteColorSettings(completions *Completions, word string, arg_num int) {
+	FnmatchCompleter("CONF files", CWD, "*.conf")(completions, word, arg_num)
+	ColorSettingsCompleter("Colors", color_settings_for_completion...)(completions, word, arg_num)
 }
 
 func ChainCompleters(completers ...CompletionFunc) CompletionFunc {
@@ -0,0 +1,135 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestFuzzyCompletion(t *testing.T) {
+	root := NewRootCommand()
+	c := root.AddSubCommand(&Command{Name: "test"})
+	for _, name := range []string{"focus-tab", "focus-window", "new-window", "set-font-size"} {
+		c.AddSubCommand(&Command{Name: name})
+	}
+
+	words := func(fuzzy bool, argv ...string) (ans []string) {
+		completions := root.GetCompletions(argv, func(c *Completions) { c.Options.Fuzzy = fuzzy })
+		root.ResetAfterParseArgs()
+		for _, g := range completions.Groups {
+			for _, m := range g.Matches {
+				ans = append(ans, m.Word)
+			}
+		}
+		return
+	}
+	tw := func(fuzzy bool, word string, expected ...string) {
+		actual := words(fuzzy, "test", word)
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Completions for %#v not as expected (fuzzy: %v):\n%s", word, fuzzy, diff)
+		}
+	}
+	tw(false, "fo", "focus-tab", "focus-window")
+	tw(false, "fw")
+	tw(true, "fw", "focus-window")
+	tw(true, "win", "focus-window", "new-window")
+	tw(true, "fs", "set-font-size", "focus-tab", "focus-window")
+
+	completions := root.GetCompletions([]string{"test", "fw"}, func(c *Completions) { c.Options.Fuzzy = true })
+	root.ResetAfterParseArgs()
+	if diff := cmp.Diff([]int{0, 6}, completions.Groups[0].Matches[0].MatchedPositions); diff != "" {
+		t.Fatalf("Matched positions not as expected:\n%s", diff)
+	}
+}
+
+func TestCompletionModel(t *testing.T) {
+	num_builds := 0
+	model := NewCompletionModel(func(root *Command) {
+		num_builds++
+		c := root.AddSubCommand(&Command{Name: "test"})
+		c.AddSubCommand(&Command{Name: "one"})
+		c.AddSubCommand(&Command{Name: "two"})
+		c.Add(OptionSpec{Name: "--verbose", Type: "bool-set"})
+	})
+	for i := 0; i < 3; i++ {
+		for _, argv := range [][]string{{"test", "o"}, {"test", "--verbose", "t"}} {
+			completions, err := model.GetCompletions(argv, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(completions.Groups) != 1 || len(completions.Groups[0].Matches) != 1 {
+				t.Fatalf("Unexpected completions for %#v: %#v", argv, completions.Groups)
+			}
+		}
+	}
+	if num_builds != 1 {
+		t.Fatalf("The command tree was built %d times", num_builds)
+	}
+}
+
+func TestOptionValueCompletion(t *testing.T) {
+	root := NewRootCommand()
+	c := root.AddSubCommand(&Command{Name: "test"})
+	c.Add(OptionSpec{Name: "--color", Completer: ColorSettingsCompleter("Colors", "foreground", "background")})
+
+	tw := func(word string, expected ...string) {
+		completions := root.GetCompletions([]string{"test", "--color", word}, nil)
+		root.ResetAfterParseArgs()
+		var actual []string
+		for _, g := range completions.Groups {
+			for _, m := range g.Matches {
+				actual = append(actual, m.Word)
+			}
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Completions for %#v not as expected:\n%s", word, diff)
+		}
+	}
+	tw("f", "foreground=")
+	tw("background=cornsilk", "background=cornsilk", "background=cornsilk1", "background=cornsilk2", "background=cornsilk3", "background=cornsilk4")
+	tw("background=xyz")
+}
+
+func TestStaticCompletionScripts(t *testing.T) {
+	root := NewRootCommand()
+	exe := root.AddSubCommand(&Command{Name: "kitten"})
+	at := exe.AddSubCommand(&Command{Name: "@", ShortDescription: "Control kitty"})
+	ls := at.AddSubCommand(&Command{Name: "ls", ShortDescription: "List windows. And more"})
+	ls.Add(OptionSpec{Name: "--format -f", Choices: "json, text", Help: "The output format"})
+	ls.Add(OptionSpec{Name: "--all", Type: "bool-set", Help: "Show all"})
+	if err := root.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for shell, gen := range static_completion_generators {
+		script := generate_static_completion_script(root, gen)
+		for _, q := range []string{"kitten", "ls", "all", "json"} {
+			if !strings.Contains(script, q) {
+				t.Fatalf("The %s completion script does not contain %#v:\n%s", shell, q, script)
+			}
+		}
+		// bash has no descriptions for completion candidates
+		if shell != "bash" && !strings.Contains(script, "List windows.") {
+			t.Fatalf("The %s completion script does not contain the description:\n%s", shell, script)
+		}
+		if strings.Contains(script, "And more") {
+			t.Fatalf("The %s completion script contains more than the first sentence of help:\n%s", shell, script)
+		}
+	}
+	script := generate_static_completion_script(root, bash_static_completion_script)
+	for _, q := range []string{
+		`case "$w" in '@'|'@ ls') cmd_path="$w";; esac`,
+		`'@ ls:--format'|'@ ls:-f') COMPREPLY=($(compgen -W 'json text' -- "$cur")); return;;`,
+		`'@') words='ls';;`,
+		"complete -o default -F _ksi_static_kitten 'kitten'",
+	} {
+		if !strings.Contains(script, q) {
+			t.Fatalf("The bash completion script does not contain %#v:\n%s", q, script)
+		}
+	}
+}
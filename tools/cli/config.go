@@ -0,0 +1,241 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The name of an option as used in config files, the long option name without
+// leading hyphens and with underscores instead of hyphens
+func config_key(option_name string) string {
+	return strings.ReplaceAll(strings.TrimLeft(option_name, "-"), "-", "_")
+}
+
+// Make the specified value the default for this option, so that values
+// specified on the command line override it. For list options the value is
+// added to the defaults. alias is the alias of the option that key refers to,
+// needed as the values of aliases such as --no-something are inverted.
+func (self *Option) set_default_from_config(key string, alias Alias, val string) (err error) {
+	self.seen_option = key
+	defer func() { self.seen_option = "" }()
+	switch self.OptionType {
+	case BoolOption:
+		yes, no := "true", "false"
+		if alias.IsUnset {
+			yes, no = no, yes
+		}
+		switch strings.ToLower(val) {
+		case "y", "yes", "true":
+			val = yes
+		case "n", "no", "false":
+			val = no
+		}
+	case StringOption:
+		if self.Choices != nil && !utils.Contains(self.Choices, val) {
+			return &ParseError{Option: self, Message: fmt.Sprintf(":yellow:`%s` is not a valid value for :bold:`%s`. Valid values: %s",
+				val, key, strings.Join(self.Choices, ", "),
+			)}
+		}
+	}
+	pval, err := self.parse_value(val)
+	if err != nil {
+		return err
+	}
+	if err = self.validate(val, pval); err != nil {
+		return err
+	}
+	if self.IsList {
+		self.parsed_default = append(append([]string{}, self.parsed_default.([]string)...), val)
+	} else {
+		self.parsed_default = pval
+		self.Default = val
+	}
+	return nil
+}
+
+func (self *Command) find_command_for_config_section(section string) (*Command, error) {
+	cmd := self
+	for _, name := range strings.Fields(section) {
+		sc := cmd.FindSubCommand(name)
+		if sc == nil {
+			possibles := cmd.SuggestionsForCommand(name, 2)
+			if len(possibles) > 0 {
+				return nil, fmt.Errorf("Unknown command: :yellow:`%s`. Did you mean:\n\t%s", section, strings.Join(possibles, "\n\t"))
+			}
+			return nil, fmt.Errorf("Unknown command: :yellow:`%s`", section)
+		}
+		cmd = sc
+	}
+	return cmd, nil
+}
+
+func (self *Command) set_default_from_config(key, val string) error {
+	name := strings.ReplaceAll(key, "_", "-")
+	opt := self.FindOption("--" + name)
+	if opt == nil {
+		possibles := self.SuggestionsForOption("--"+name, 2)
+		seen := make(map[string]bool, len(possibles))
+		keys := make([]string, 0, len(possibles))
+		for _, x := range possibles {
+			if k := config_key(x); !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) > 0 {
+			return fmt.Errorf("Unknown option: :yellow:`%s`. Did you mean:\n\t%s", key, strings.Join(keys, "\n\t"))
+		}
+		return fmt.Errorf("Unknown option: :yellow:`%s`", key)
+	}
+	for _, a := range opt.Aliases {
+		if !a.IsShort && a.NameWithoutHyphens == name {
+			return opt.set_default_from_config(key, a, val)
+		}
+	}
+	return nil
+}
+
+// Set the default values of options from config data. The data consists of
+// lines of the form: key value, where key is the name of a long option
+// without leading hyphens, with underscores allowed in place of hyphens. Lines
+// starting with # are ignored. Values apply to the options of this command,
+// until a line of the form [sub-command] is encountered, after which they
+// apply to the options of the specified sub-command. For nested sub-commands
+// use their names separated by spaces, for example: [@ ls]. Values specified
+// on the command line override the values from config data.
+func (self *Command) ParseConfig(src io.Reader, src_name string) error {
+	scanner := bufio.NewScanner(src)
+	cmd := self
+	lnum := 0
+	var errs []string
+	report := func(err error) {
+		errs = append(errs, fmt.Sprintf("%s:%d: %s", src_name, lnum, err))
+	}
+	for scanner.Scan() {
+		lnum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			sc, err := self.find_command_for_config_section(line[1 : len(line)-1])
+			if err != nil {
+				report(err)
+				// ignore lines until the next valid section
+				sc = nil
+			}
+			cmd = sc
+			continue
+		}
+		if cmd == nil {
+			continue
+		}
+		key, val := line, ""
+		if idx := strings.IndexAny(line, " \t"); idx > -1 {
+			key, val = line[:idx], strings.TrimSpace(line[idx+1:])
+		}
+		if err := cmd.set_default_from_config(key, val); err != nil {
+			report(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &ParseError{Message: strings.Join(errs, "\n")}
+	}
+	return nil
+}
+
+// Set the default values of options from the config file at path, see
+// ParseConfig for the format. A missing file is not an error.
+func (self *Command) LoadConfig(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	return self.ParseConfig(f, path)
+}
+
+func (self *Option) long_alias() (Alias, bool) {
+	for _, a := range self.Aliases {
+		if !a.IsShort {
+			return a, true
+		}
+	}
+	return Alias{}, false
+}
+
+// The name of the environment variable that sets the default value of this
+// option, for example: KITTY_ICAT_ALIGN for the --align option of kitten icat.
+// Empty for options of the top level command.
+func (self *Option) EnvVarName() string {
+	alias, found := self.long_alias()
+	if !found || self.Parent == nil || self.Parent.Parent == nil {
+		return ""
+	}
+	parts := []string{config_key(alias.NameWithoutHyphens)}
+	for cmd := self.Parent; cmd.Parent != nil; cmd = cmd.Parent {
+		name := cmd.Name
+		if name == "@" {
+			name = "at"
+		}
+		parts = append(parts, non_identifier_chars.ReplaceAllString(name, "_"))
+	}
+	parts = append(parts, "kitty")
+	return strings.ToUpper(strings.Join(utils.Reverse(parts), "_"))
+}
+
+// Set the default values of options from environment variables, see
+// EnvVarName for how variables are named. Values from the environment
+// override values from config files and values specified on the command line
+// override values from the environment.
+func (self *Command) LoadEnv() error {
+	var errs []string
+	var visit func(*Command)
+	visit = func(cmd *Command) {
+		for _, g := range cmd.OptionGroups {
+			for _, opt := range g.Options {
+				name := opt.EnvVarName()
+				if name == "" {
+					continue
+				}
+				if val, found := os.LookupEnv(name); found {
+					if opt.IsList {
+						// replace rather than add to any values from config files
+						opt.parsed_default = []string{}
+					}
+					alias, _ := opt.long_alias()
+					if err := opt.set_default_from_config(name, alias, val); err != nil {
+						errs = append(errs, err.Error())
+					}
+				}
+			}
+		}
+		for _, g := range cmd.SubCommandGroups {
+			for _, sc := range g.SubCommands {
+				visit(sc)
+			}
+		}
+	}
+	visit(self)
+	if len(errs) > 0 {
+		return &ParseError{Message: strings.Join(errs, "\n")}
+	}
+	return nil
+}
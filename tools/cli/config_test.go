@@ -0,0 +1,137 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestConfigFile(t *testing.T) {
+	root := NewRootCommand()
+	root.Name = "kitten"
+	icat := root.AddSubCommand(&Command{Name: "icat"})
+	icat.Add(OptionSpec{Name: "--align", Choices: "center, left, right", Default: "center"})
+	icat.Add(OptionSpec{Name: "--z-index -z", Type: "int"})
+	icat.Add(OptionSpec{Name: "--silent", Type: "bool-set"})
+	icat.Add(OptionSpec{Name: "--env", Type: "list"})
+
+	conf := `
+# a comment
+[icat]
+align left
+z_index   3
+silent yes
+env a=1
+env b=2
+`
+	if err := root.ParseConfig(strings.NewReader(conf), "kittens.conf"); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := root.ParseArgs([]string{"kitten", "icat", "--align", "right", "--env", "c=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	align, _ := GetOptionValue[string](cmd, "Align")
+	z, _ := GetOptionValue[int](cmd, "ZIndex")
+	silent, _ := GetOptionValue[bool](cmd, "Silent")
+	env, _ := GetOptionValue[[]string](cmd, "Env")
+	if diff := cmp.Diff([]any{"right", 3, true, []string{"c=3"}}, []any{align, z, silent, env}); diff != "" {
+		t.Fatalf("Unexpected option values:\n%s", diff)
+	}
+	root.ResetAfterParseArgs()
+	if cmd, err = root.ParseArgs([]string{"kitten", "icat"}); err != nil {
+		t.Fatal(err)
+	}
+	align, _ = GetOptionValue[string](cmd, "Align")
+	env, _ = GetOptionValue[[]string](cmd, "Env")
+	if diff := cmp.Diff([]any{"left", []string{"a=1", "b=2"}}, []any{align, env}); diff != "" {
+		t.Fatalf("Unexpected option values:\n%s", diff)
+	}
+
+	err = root.ParseConfig(strings.NewReader("[icat]\nalgin left\nalign top\n[icta]\nsilent no\n[icat]\nz_index x"), "kittens.conf")
+	if err == nil {
+		t.Fatalf("No error for invalid config")
+	}
+	for _, q := range []string{"kittens.conf:2: Unknown option: :yellow:`algin`. Did you mean:\n\talign", "kittens.conf:3: :yellow:`top` is not a valid value", "kittens.conf:4: Unknown command: :yellow:`icta`. Did you mean:\n\ticat", "kittens.conf:7: :yellow:`x` is not a valid number for :bold:`z_index`"} {
+		if !strings.Contains(err.Error(), q) {
+			t.Fatalf("%#v not found in error:\n%s", q, err)
+		}
+	}
+	if strings.Contains(err.Error(), "kittens.conf:5:") {
+		t.Fatalf("Lines in an unknown section were not ignored:\n%s", err)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	root := NewRootCommand()
+	root.Name = "kitten"
+	root.Add(OptionSpec{Name: "--version", Type: "bool-set"})
+	at := root.AddSubCommand(&Command{Name: "@"})
+	ls := at.AddSubCommand(&Command{Name: "ls"})
+	ls.Add(OptionSpec{Name: "--all-env-vars", Type: "bool-set"})
+	ls.Add(OptionSpec{Name: "--match -m", Type: "list"})
+	ls.Add(OptionSpec{Name: "--no-response", Type: "bool-reset"})
+	ls.Add(OptionSpec{Name: "--self"})
+
+	names := []string{}
+	_ = ls.VisitAllOptions(func(o *Option) error {
+		names = append(names, o.EnvVarName())
+		return nil
+	})
+	if diff := cmp.Diff([]string{"KITTY_AT_LS_ALL_ENV_VARS", "KITTY_AT_LS_MATCH", "KITTY_AT_LS_NO_RESPONSE", "KITTY_AT_LS_SELF"}, names); diff != "" {
+		t.Fatalf("Unexpected environment variable names:\n%s", diff)
+	}
+
+	if err := root.ParseConfig(strings.NewReader("[@ ls]\nmatch id:1\nself a\n"), "kittens.conf"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KITTY_AT_LS_ALL_ENV_VARS", "yes")
+	t.Setenv("KITTY_AT_LS_MATCH", "id:2")
+	t.Setenv("KITTY_AT_LS_NO_RESPONSE", "y")
+	t.Setenv("KITTY_AT_LS_SELF", "b")
+	if err := root.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := root.ParseArgs([]string{"kitten", "@", "ls", "--self", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := GetOptionValue[bool](cmd, "AllEnvVars")
+	match, _ := GetOptionValue[[]string](cmd, "Match")
+	no_response, _ := GetOptionValue[bool](cmd, "NoResponse")
+	self, _ := GetOptionValue[string](cmd, "Self")
+	if diff := cmp.Diff([]any{true, []string{"id:2"}, false, "c"}, []any{all, match, no_response, self}); diff != "" {
+		t.Fatalf("Unexpected option values:\n%s", diff)
+	}
+
+	t.Setenv("KITTY_AT_LS_ALL_ENV_VARS", "maybe")
+	if err = root.LoadEnv(); err == nil || !strings.Contains(err.Error(), ":yellow:`maybe` is not a valid value for :bold:`KITTY_AT_LS_ALL_ENV_VARS`") {
+		t.Fatalf("Unexpected error for invalid environment variable: %v", err)
+	}
+}
+
+func TestPeekOptionValues(t *testing.T) {
+	root := NewRootCommand()
+	root.Add(OptionSpec{Name: "--version", Type: "bool-set"})
+	root.Add(OptionSpec{Name: "--config-dir"})
+	root.Add(OptionSpec{Name: "--cache-dir"})
+	sc := root.AddSubCommand(&Command{Name: "icat"})
+	sc.Add(OptionSpec{Name: "--config-dir"})
+	test := func(expected map[string]string, args ...string) {
+		actual := root.PeekOptionValues(append([]string{"kitten"}, args...))
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected option values for %#v:\n%s", args, diff)
+		}
+	}
+	test(map[string]string{})
+	test(map[string]string{"ConfigDir": "/a", "CacheDir": "/c"}, "--version", "--config-dir", "/b", "--cache-dir=/c", "--config-dir=/a", "icat", "--config-dir", "/x")
+	test(map[string]string{}, "icat", "--config-dir", "/x")
+	test(map[string]string{}, "--unknown", "--", "--config-dir", "/x")
+	test(map[string]string{}, "--config-dir")
+}
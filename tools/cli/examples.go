@@ -0,0 +1,91 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"kitty/tools/cli/markup"
+	"kitty/tools/utils/shlex"
+)
+
+var _ = fmt.Print
+
+// An example of how to run a command, shown in its help and man page
+type Example struct {
+	// The options and arguments for the command, as they would be typed in a
+	// shell, without the command itself
+	CommandLine string
+	Description string
+}
+
+func (self *Example) full_command_line(cmd *Command) string {
+	return strings.TrimSpace(cmd.CommandStringForUsage() + " " + self.CommandLine)
+}
+
+// The argv that runs this example, suitable for passing to ParseArgs on the
+// root command
+func (self *Example) argv(cmd *Command) ([]string, error) {
+	args, err := shlex.Split(self.CommandLine)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, 8)
+	for p := cmd; p.Parent != nil; p = p.Parent {
+		names = append(names, p.Name)
+	}
+	ans := make([]string, 0, len(names)+len(args)+1)
+	ans = append(ans, cmd.Root().Name)
+	for i := len(names) - 1; i >= 0; i-- {
+		ans = append(ans, names[i])
+	}
+	return append(ans, args...), nil
+}
+
+func (self *Command) format_examples(output io.Writer, formatter *markup.Context, screen_width int) {
+	fmt.Fprintln(output, formatter.Title("Examples")+":")
+	for _, ex := range self.Examples {
+		fmt.Fprintln(output, "  "+formatter.Exe(ex.full_command_line(self)))
+		if ex.Description != "" {
+			format_with_indent(output, formatter.Prettify(prepare_help_text_for_display(ex.Description)), "    ", screen_width)
+		}
+		fmt.Fprintln(output)
+	}
+}
+
+// Parse the examples of this command and all its sub-commands, returning an
+// error describing every example that fails to parse or that runs some other
+// command. Used to ensure examples stay in sync with the options they use.
+func (self *Command) VerifyExamples() error {
+	root := self.Root()
+	var errs []string
+	var verify func(*Command)
+	verify = func(cmd *Command) {
+		for _, ex := range cmd.Examples {
+			argv, err := ex.argv(cmd)
+			if err == nil {
+				var pc *Command
+				root.ResetAfterParseArgs()
+				if pc, err = root.ParseArgs(argv); err == nil && pc != cmd {
+					err = fmt.Errorf("it runs the command: %s instead", pc.CommandStringForUsage())
+				}
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("The example: %s is invalid: %s", ex.full_command_line(cmd), err))
+			}
+		}
+		for _, g := range cmd.SubCommandGroups {
+			for _, sc := range g.SubCommands {
+				verify(sc)
+			}
+		}
+	}
+	verify(self)
+	root.ResetAfterParseArgs()
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
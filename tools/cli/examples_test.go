@@ -0,0 +1,50 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestExamples(t *testing.T) {
+	root := NewRootCommand()
+	root.Name = "kitten"
+	at := root.AddSubCommand(&Command{Name: "@"})
+	ls := at.AddSubCommand(&Command{Name: "ls", Examples: []Example{
+		{CommandLine: "--all-env-vars", Description: "Show all environment variables"},
+		{CommandLine: "--self 'a b'"},
+	}})
+	ls.Add(OptionSpec{Name: "--all-env-vars", Type: "bool-set"})
+	ls.Add(OptionSpec{Name: "--self"})
+
+	if err := root.VerifyExamples(); err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	ls.WriteManPage(&output, time.Now())
+	for _, q := range []string{".SH EXAMPLES\n.TP\n\\fBkitten @ ls \\-\\-all\\-env\\-vars\\fR\nShow all environment variables\n", "\\fBkitten @ ls \\-\\-self 'a b'\\fR\n"} {
+		if !strings.Contains(output.String(), q) {
+			t.Fatalf("%#v not found in man page:\n%s", q, output.String())
+		}
+	}
+
+	ls.Examples = append(ls.Examples, Example{CommandLine: "--all-env-var=no"}, Example{CommandLine: "--selfie"})
+	at.Examples = []Example{{CommandLine: "ls"}}
+	err := root.VerifyExamples()
+	if err == nil {
+		t.Fatal("Invalid examples not detected")
+	}
+	for _, q := range []string{"kitten @ ls --all-env-var=no", "kitten @ ls --selfie", "kitten @ ls instead"} {
+		if !strings.Contains(err.Error(), q) {
+			t.Fatalf("%#v not found in error:\n%s", q, err)
+		}
+	}
+	if ls.FindOption("--all-env-vars").parsed_value().(bool) {
+		t.Fatal("Option values not reset after verifying examples")
+	}
+}
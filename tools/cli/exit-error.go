@@ -0,0 +1,67 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// The exit codes used by kittens for the different kinds of failures, so that
+// callers, such as scripts, can distinguish between them
+const (
+	// A failure not covered by any of the other exit codes
+	ExitCodeFailure = 1
+	// Invalid command line arguments or options
+	ExitCodeUsage = 2
+	// Failed to connect to or communicate with kitty
+	ExitCodeConnection = 3
+	// kitty reported an error when running the requested action
+	ExitCodeRemote = 4
+	// The user or the terminal refused permission for the requested action
+	ExitCodePermissionDenied = 5
+)
+
+// An error that causes the kitten to exit with the specified exit code. The
+// optional hint is shown to the user after the error message, and should
+// suggest how to fix the problem.
+type ExitError struct {
+	Code    int
+	Message string
+	Hint    string
+	// The underlying error, if any
+	Err error
+}
+
+func (self *ExitError) Error() string { return self.Message }
+func (self *ExitError) Unwrap() error { return self.Err }
+
+// Create an ExitError from err with the specified exit code, using the
+// message from err
+func NewExitError(code int, err error) *ExitError {
+	return &ExitError{Code: code, Message: err.Error(), Err: err}
+}
+
+func (self *ExitError) WithHint(hint string) *ExitError {
+	self.Hint = hint
+	return self
+}
+
+// The exit code a kitten should use for the specified error, zero for a nil
+// error
+func ExitCodeForError(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *ExitError
+	if errors.As(err, &ee) && ee.Code != 0 {
+		return ee.Code
+	}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return ExitCodeUsage
+	}
+	return ExitCodeFailure
+}
@@ -39,7 +39,62 @@ func fish_output_serializer(completions []*Completions, shell_state map[string]s
 	return []byte(output.String()), nil
 }
 
+func fish_static_completion_script(exe *static_exe) string {
+	output := strings.Builder{}
+	f := func(format string, args ...any) { fmt.Fprintf(&output, format+"\n", args...) }
+	q := utils.QuoteStringForFish
+	fname := "__ksi_static_" + exe.identifier()
+	f("function %s_path", fname)
+	f("    set -l cmd_path ''")
+	if paths := exe.sub_command_paths(); len(paths) > 0 {
+		qpaths := make([]string, len(paths))
+		for i, p := range paths {
+			qpaths[i] = q(p)
+		}
+		f("    for w in (commandline -opc)[2..-1]")
+		f(`        set -l candidate "$cmd_path $w"`)
+		f("        test -z \"$cmd_path\"; and set candidate $w")
+		f("        if contains -- $candidate %s", strings.Join(qpaths, " "))
+		f("            set cmd_path $candidate")
+		f("        end")
+		f("    end")
+	}
+	f("    echo $cmd_path")
+	f("end")
+	f("function %s_at", fname)
+	f("    set -l p (%s_path)", fname)
+	f(`    test "$p" = "$argv[1]"`)
+	f("end")
+	for _, c := range exe.commands {
+		cond := q(fname + "_at " + q(c.path))
+		for _, sc := range c.sub_commands {
+			f("complete -c %s -n %s -a %s -d %s", q(exe.name), cond, q(sc.name()), q(sc.help))
+		}
+		for _, o := range c.options {
+			spec := make([]string, 0, 4)
+			for _, a := range o.long_aliases {
+				spec = append(spec, "-l "+q(a[2:]))
+			}
+			for _, a := range o.short_aliases {
+				if len(a) == 2 {
+					spec = append(spec, "-s "+q(a[1:]))
+				} else {
+					spec = append(spec, "-o "+q(a[1:]))
+				}
+			}
+			if len(o.choices) > 0 {
+				spec = append(spec, "-x -a "+q(strings.Join(o.choices, " ")))
+			} else if o.takes_value {
+				spec = append(spec, "-r")
+			}
+			f("complete -c %s -n %s %s -d %s", q(exe.name), cond, strings.Join(spec, " "), q(o.help))
+		}
+	}
+	return output.String()
+}
+
 func init() {
+	static_completion_generators["fish"] = fish_static_completion_script
 	input_parsers["fish"] = shell_input_parser
 	output_serializers["fish"] = fish_output_serializer
 }
@@ -45,6 +45,13 @@ func (self *CommandGroup) FindSubCommand(name string) *Command {
 			return c
 		}
 	}
+	for _, c := range self.SubCommands {
+		for _, a := range c.Aliases {
+			if a == name {
+				return c
+			}
+		}
+	}
 	return nil
 }
 
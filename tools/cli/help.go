@@ -3,6 +3,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -23,6 +24,10 @@ func ShowError(err error) {
 	formatter := markup.New(tty.IsTerminal(os.Stderr.Fd()))
 	msg := formatter.Prettify(err.Error())
 	fmt.Fprintln(os.Stderr, formatter.Err("Error")+":", msg)
+	var ee *ExitError
+	if errors.As(err, &ee) && ee.Hint != "" {
+		fmt.Fprintln(os.Stderr, formatter.Title("Hint")+":", formatter.Prettify(ee.Hint))
+	}
 }
 
 func (self *Command) version_string(formatter *markup.Context) string {
@@ -54,7 +59,11 @@ func (self *Command) FormatSubCommands(output io.Writer, formatter *markup.Conte
 			if c.Hidden {
 				continue
 			}
-			fmt.Fprintln(output, "  ", formatter.Opt(c.Name))
+			names := make([]string, 0, len(c.Aliases)+1)
+			for _, name := range append([]string{c.Name}, c.Aliases...) {
+				names = append(names, formatter.Opt(name))
+			}
+			fmt.Fprintln(output, "  ", strings.Join(names, ", "))
 			format_with_indent(output, formatter.Prettify(c.ShortDescription), "    ", screen_width)
 		}
 	}
@@ -86,6 +95,9 @@ func (self *Option) FormatOption(output io.Writer, formatter *markup.Context, sc
 	if self.Choices != nil {
 		format_with_indent(output, "Choices: "+strings.Join(self.Choices, ", "), "    ", screen_width)
 	}
+	if self.Validator != nil && self.Validator.Description != "" {
+		format_with_indent(output, self.Validator.Description, "    ", screen_width)
+	}
 }
 
 func (self *Command) ShowHelp() {
@@ -148,6 +160,10 @@ func (self *Command) ShowHelpWithCommandString(cs string) {
 			}
 		}
 	}
+	if len(self.Examples) > 0 {
+		fmt.Fprintln(&output)
+		self.format_examples(&output, formatter, screen_width)
+	}
 	output.WriteString(self.version_string(formatter))
 	output_text := output.String()
 	// fmt.Printf("%#v\n", output_text)
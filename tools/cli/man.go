@@ -0,0 +1,202 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty"
+	"kitty/tools/cli/markup"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The name of the man page for this command, for example, kitten-at-ls for
+// kitten @ ls
+func (self *Command) ManPageName() string {
+	return strings.ReplaceAll(strings.ReplaceAll(self.CommandStringForUsage(), "@", "at"), " ", "-")
+}
+
+// Escape text so that it is rendered literally by roff
+func roff_escape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+var plain_formatter = markup.New(false)
+
+func write_roff_paragraphs(output io.Writer, raw string) {
+	in_paragraph := false
+	for _, line := range utils.Splitlines(plain_formatter.Prettify(prepare_help_text_for_display(raw))) {
+		if strings.TrimSpace(line) == "" {
+			in_paragraph = false
+			continue
+		}
+		if !in_paragraph {
+			fmt.Fprintln(output, ".PP")
+			in_paragraph = true
+		}
+		fmt.Fprintln(output, roff_escape(strings.TrimSpace(line)))
+	}
+}
+
+func (self *Option) write_man_page_entry(output io.Writer) {
+	fmt.Fprintln(output, ".TP")
+	aliases := make([]string, len(self.Aliases))
+	for i, a := range self.Aliases {
+		aliases[i] = `\fB` + roff_escape(a.String()) + `\fR`
+	}
+	header := strings.Join(aliases, ", ")
+	switch self.OptionType {
+	case BoolOption, CountOption:
+	default:
+		if self.Default != "" && !self.IsList {
+			header += ` [=\fI` + roff_escape(self.Default) + `\fR]`
+		}
+	}
+	fmt.Fprintln(output, header)
+	for i, line := range utils.Splitlines(plain_formatter.Prettify(prepare_help_text_for_display(self.Help))) {
+		if strings.TrimSpace(line) == "" {
+			if i > 0 {
+				fmt.Fprintln(output, ".IP")
+			}
+			continue
+		}
+		fmt.Fprintln(output, roff_escape(strings.TrimSpace(line)))
+	}
+	if self.Choices != nil {
+		fmt.Fprintln(output, ".IP")
+		fmt.Fprintln(output, "Choices: "+roff_escape(strings.Join(self.Choices, ", ")))
+	}
+	if self.Validator != nil && self.Validator.Description != "" {
+		fmt.Fprintln(output, ".IP")
+		fmt.Fprintln(output, roff_escape(self.Validator.Description))
+	}
+}
+
+// Write the man page for this command in roff format. date is the date shown
+// in the footer of the page.
+func (self *Command) WriteManPage(output io.Writer, date time.Time) {
+	name := self.ManPageName()
+	fmt.Fprintf(output, ".TH %s 1 %s \"kitty %s\" \"kitten Manual\"\n", strings.ToUpper(roff_escape(name)), date.Format("2006-01-02"), kitty.VersionString)
+	fmt.Fprintln(output, ".SH NAME")
+	desc := self.ShortDescription
+	if desc == "" && self.Parent == nil {
+		desc = "Command line tools for use with kitty"
+	}
+	fmt.Fprintf(output, "%s \\- %s\n", roff_escape(name), roff_escape(plain_formatter.Prettify(desc)))
+
+	fmt.Fprintln(output, ".SH SYNOPSIS")
+	fmt.Fprintf(output, "\\fB%s\\fR %s\n", roff_escape(self.CommandStringForUsage()), roff_escape(strings.TrimSpace(plain_formatter.Prettify(self.Usage))))
+
+	if self.HelpText != "" {
+		fmt.Fprintln(output, ".SH DESCRIPTION")
+		write_roff_paragraphs(output, self.HelpText)
+	}
+
+	for _, g := range self.SubCommandGroups {
+		if !g.HasVisibleSubCommands() {
+			continue
+		}
+		title := g.Title
+		if title == "" {
+			title = "Commands"
+		}
+		fmt.Fprintln(output, ".SH", strings.ToUpper(roff_escape(title)))
+		for _, c := range g.SubCommands {
+			if c.Hidden {
+				continue
+			}
+			fmt.Fprintln(output, ".TP")
+			names := make([]string, 0, len(c.Aliases)+1)
+			for _, name := range append([]string{c.Name}, c.Aliases...) {
+				names = append(names, `\fB`+roff_escape(name)+`\fR`)
+			}
+			fmt.Fprintln(output, strings.Join(names, ", "))
+			fmt.Fprintln(output, roff_escape(plain_formatter.Prettify(c.ShortDescription)))
+			fmt.Fprintf(output, "See \\fB%s\\fR(1)\n", roff_escape(c.ManPageName()))
+		}
+	}
+
+	group_titles, gmap := self.GetVisibleOptions()
+	if len(group_titles) > 0 {
+		fmt.Fprintln(output, ".SH OPTIONS")
+		for _, title := range group_titles {
+			if title != "" {
+				fmt.Fprintln(output, ".SS", roff_escape(title))
+			}
+			for _, opt := range gmap[title] {
+				opt.write_man_page_entry(output)
+			}
+		}
+	}
+
+	if len(self.Examples) > 0 {
+		fmt.Fprintln(output, ".SH EXAMPLES")
+		for _, ex := range self.Examples {
+			fmt.Fprintln(output, ".TP")
+			fmt.Fprintf(output, "\\fB%s\\fR\n", roff_escape(ex.full_command_line(self)))
+			if ex.Description != "" {
+				fmt.Fprintln(output, roff_escape(strings.TrimSpace(plain_formatter.Prettify(prepare_help_text_for_display(ex.Description)))))
+			}
+		}
+	}
+
+	if self.Parent != nil {
+		fmt.Fprintln(output, ".SH SEE ALSO")
+		fmt.Fprintf(output, "\\fB%s\\fR(1)\n", roff_escape(self.Parent.ManPageName()))
+	}
+}
+
+// The date to use for generated man pages, from $SOURCE_DATE_EPOCH, if set,
+// for reproducible builds
+func man_page_date() time.Time {
+	if q := os.Getenv("SOURCE_DATE_EPOCH"); q != "" {
+		if secs, err := strconv.ParseInt(q, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
+		}
+	}
+	return time.Now()
+}
+
+// Write man pages for this command and all its visible sub-commands into
+// the directory output_dir, returning the paths of the written files
+func (self *Command) GenerateManPages(output_dir string) (ans []string, err error) {
+	date := man_page_date()
+	var write func(*Command) error
+	write = func(c *Command) error {
+		path := filepath.Join(output_dir, c.ManPageName()+".1")
+		var output strings.Builder
+		c.WriteManPage(&output, date)
+		if err := os.WriteFile(path, []byte(output.String()), 0o644); err != nil {
+			return err
+		}
+		ans = append(ans, path)
+		for _, g := range c.SubCommandGroups {
+			for _, sc := range g.SubCommands {
+				if !sc.Hidden {
+					if err := write(sc); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if err = os.MkdirAll(output_dir, 0o755); err != nil {
+		return
+	}
+	err = write(self)
+	return
+}
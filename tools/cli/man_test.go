@@ -0,0 +1,56 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestManPages(t *testing.T) {
+	root := NewRootCommand()
+	root.Name = "kitten"
+	at := root.AddSubCommand(&Command{Name: "@", ShortDescription: "Control kitty"})
+	ls := at.AddSubCommand(&Command{Name: "ls", ShortDescription: "List windows", Usage: "[options]", HelpText: "List all windows.\n\n.hidden dots"})
+	ls.Add(OptionSpec{Name: "--all-env-vars", Type: "bool-set", Help: "Show :code:`all` vars"})
+	root.AddSubCommand(&Command{Name: "secret", Hidden: true})
+
+	if ls.ManPageName() != "kitten-at-ls" {
+		t.Fatalf("Unexpected man page name: %s", ls.ManPageName())
+	}
+	var output strings.Builder
+	ls.WriteManPage(&output, time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC))
+	page := output.String()
+	for _, q := range []string{
+		".TH KITTEN\\-AT\\-LS 1 2022-12-01", "kitten\\-at\\-ls \\- List windows", "\\fBkitten @ ls\\fR [options]",
+		".PP\nList all windows.\n.PP\n\\&.hidden dots\n", "\\fB\\-\\-all\\-env\\-vars\\fR\nShow all vars\n", "\\fBkitten\\-at\\fR(1)",
+	} {
+		if !strings.Contains(page, q) {
+			t.Fatalf("%#v not found in man page:\n%s", q, page)
+		}
+	}
+
+	tdir := t.TempDir()
+	paths, err := root.GenerateManPages(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+		if _, err := os.Stat(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff([]string{"kitten.1", "kitten-at.1", "kitten-at-ls.1"}, names); diff != "" {
+		t.Fatalf("Unexpected man pages:\n%s", diff)
+	}
+}
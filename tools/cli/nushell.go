@@ -0,0 +1,68 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+var _ = fmt.Print
+
+func quote_string_for_nushell(x string) string {
+	x = strings.ReplaceAll(x, `\`, `\\`)
+	x = strings.ReplaceAll(x, `"`, `\"`)
+	return `"` + x + `"`
+}
+
+// Completions for nushell are extern definitions, one for every command,
+// nushell itself handles sub-commands and flags
+func nushell_static_completion_script(exe *static_exe) string {
+	output := strings.Builder{}
+	f := func(format string, args ...any) { fmt.Fprintf(&output, format+"\n", args...) }
+	q := quote_string_for_nushell
+	num_completers := 0
+	for _, c := range exe.commands {
+		name := strings.TrimSpace(exe.name + " " + c.path)
+		flags := make([]string, 0, len(c.options))
+		for _, o := range c.options {
+			// nushell requires a long name for every flag and only allows
+			// single letter short names
+			if len(o.long_aliases) == 0 {
+				continue
+			}
+			for i, a := range o.long_aliases {
+				flag := a
+				if i == 0 && len(o.short_aliases) > 0 && len(o.short_aliases[0]) == 2 {
+					flag += "(" + o.short_aliases[0] + ")"
+				}
+				if o.takes_value {
+					flag += ": string"
+					if len(o.choices) > 0 {
+						num_completers++
+						completer := fmt.Sprintf("nu-complete %s %d", exe.name, num_completers)
+						qc := make([]string, len(o.choices))
+						for i, x := range o.choices {
+							qc[i] = q(x)
+						}
+						f("def %s [] { [%s] }", q(completer), strings.Join(qc, " "))
+						flag += "@" + q(completer)
+					}
+				}
+				flags = append(flags, fmt.Sprintf("    %s  # %s", flag, o.help))
+			}
+		}
+		f("# %s", c.help)
+		f("export extern %s [", q(name))
+		for _, flag := range flags {
+			f("%s", flag)
+		}
+		f("    ...args: string")
+		f("]")
+	}
+	return output.String()
+}
+
+func init() {
+	static_completion_generators["nushell"] = nushell_static_completion_script
+}
@@ -143,6 +143,9 @@ func option_from_spec(spec OptionSpec) (*Option, error) {
 		case "float":
 			ans.OptionType = FloatOption
 			ans.Default = "0"
+		case "duration":
+			ans.OptionType = DurationOption
+			ans.Default = "0"
 		case "count":
 			ans.OptionType = CountOption
 			ans.Default = "0"
@@ -152,8 +155,8 @@ func option_from_spec(spec OptionSpec) (*Option, error) {
 		case "bool-reset":
 			ans.OptionType = BoolOption
 			ans.Default = "true"
-			for _, a := range ans.Aliases {
-				a.IsUnset = true
+			for i := range ans.Aliases {
+				ans.Aliases[i].IsUnset = true
 			}
 		case "list":
 			ans.IsList = true
@@ -178,12 +181,19 @@ func option_from_spec(spec OptionSpec) (*Option, error) {
 		ans.parsed_default = []string{}
 	}
 	ans.Completer = spec.Completer
+	ans.Validator = spec.Validator
+	if ans.Completer == nil && ans.Validator != nil {
+		ans.Completer = ans.Validator.Completer
+	}
 	if ans.Aliases == nil || len(ans.Aliases) == 0 {
 		return nil, fmt.Errorf("No --aliases specified for option")
 	}
 	if ans.Name == "" {
 		return nil, fmt.Errorf("No dest specified for option")
 	}
+	if ans.Completer == nil && ans.Choices != nil {
+		ans.Completer = ChoicesCompleter("Choices for "+ans.Aliases[0].String(), ans.Choices...)
+	}
 	return &ans, nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
@@ -20,6 +21,7 @@ const (
 	FloatOption
 	BoolOption
 	CountOption
+	DurationOption
 )
 
 type Alias struct {
@@ -44,6 +46,7 @@ type OptionSpec struct {
 	Default   string
 	Help      string
 	Completer CompletionFunc
+	Validator *Validator
 }
 
 type Option struct {
@@ -58,6 +61,7 @@ type Option struct {
 	IsList     bool
 	Parent     *Command
 	Completer  CompletionFunc
+	Validator  *Validator
 
 	values_from_cmdline        []string
 	parsed_values_from_cmdline []any
@@ -152,6 +156,16 @@ func (self *Option) parse_value(val string) (any, error) {
 				":yellow:`%s` is not a valid number for :bold:`%s`. Only floats in decimal and hexadecimal notation are accepted.", val, self.seen_option)}
 		}
 		return pval, nil
+	case DurationOption:
+		if secs, err := strconv.ParseFloat(val, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), nil
+		}
+		pval, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, &ParseError{Option: self, Message: fmt.Sprintf(
+				":yellow:`%s` is not a valid duration for :bold:`%s`. Durations are either a number of seconds or a number with a unit, such as: 300ms, 5s, 2m or 1h.", val, self.seen_option)}
+		}
+		return pval, nil
 	default:
 		return nil, &ParseError{Option: self, Message: fmt.Sprintf("Unknown option type for %s", self.Name)}
 	}
@@ -179,13 +193,19 @@ func (self *Option) add_value(val string) error {
 				val, self.seen_option, strings.Join(self.Choices, ", "),
 			)}
 		}
+		if err := self.validate(val, val); err != nil {
+			return err
+		}
 		self.values_from_cmdline = append(self.values_from_cmdline, val)
 		self.parsed_values_from_cmdline = append(self.parsed_values_from_cmdline, val)
-	case IntegerOption, FloatOption:
+	case IntegerOption, FloatOption, DurationOption:
 		pval, err := self.parse_value(val)
 		if err != nil {
 			return err
 		}
+		if err = self.validate(val, pval); err != nil {
+			return err
+		}
 		self.values_from_cmdline = append(self.values_from_cmdline, val)
 		self.parsed_values_from_cmdline = append(self.parsed_values_from_cmdline, pval)
 	case CountOption:
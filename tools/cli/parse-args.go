@@ -134,3 +134,35 @@ func (self *Command) parse_args(ctx *Context, args []string) error {
 	}
 	return nil
 }
+
+// The values of the options of this command that occur before the first
+// sub-command or argument in args, without parsing the command line. For
+// options that must be used before the command line is parsed, for example,
+// to find configuration files. Only long options that take values are
+// returned, keyed by the option name, with the last occurrence winning.
+func (self *Command) PeekOptionValues(args []string) map[string]string {
+	ans := make(map[string]string)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, val, has_val := strings.Cut(arg, "=")
+		opt := self.FindOption(name)
+		if opt == nil || !opt.needs_argument() {
+			continue
+		}
+		if !has_val {
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			val = args[i]
+		}
+		ans[opt.Name] = val
+	}
+	return ans
+}
@@ -0,0 +1,90 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+var _ = fmt.Print
+
+func quote_string_for_powershell(x string) string {
+	return "'" + strings.ReplaceAll(x, "'", "''") + "'"
+}
+
+func powershell_static_completion_script(exe *static_exe) string {
+	output := strings.Builder{}
+	f := func(format string, args ...any) { fmt.Fprintf(&output, format+"\n", args...) }
+	q := quote_string_for_powershell
+	// candidates are keyed by c:path for sub-commands, o:path for options and
+	// v:path:option for option values
+	candidates := func(key string, names, descriptions []string) {
+		if len(names) > 0 {
+			qn, qd := make([]string, len(names)), make([]string, len(names))
+			for i := range names {
+				qn[i], qd[i] = q(names[i]), q(descriptions[i])
+			}
+			f("    $names[%s] = @(%s)", q(key), strings.Join(qn, ", "))
+			f("    $descriptions[%s] = @(%s)", q(key), strings.Join(qd, ", "))
+		}
+	}
+	f("Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {", q(exe.name))
+	f("    param($wordToComplete, $commandAst, $cursorPosition)")
+	paths := exe.sub_command_paths()
+	qpaths := make([]string, len(paths))
+	for i, p := range paths {
+		qpaths[i] = q(p)
+	}
+	f("    $paths = @(%s)", strings.Join(qpaths, ", "))
+	f("    $names = @{}")
+	f("    $descriptions = @{}")
+	for _, c := range exe.commands {
+		var names, descriptions []string
+		for _, sc := range c.sub_commands {
+			names, descriptions = append(names, sc.name()), append(descriptions, sc.help)
+		}
+		candidates("c:"+c.path, names, descriptions)
+		names, descriptions = nil, nil
+		for _, o := range c.options {
+			for _, a := range o.all_aliases() {
+				names, descriptions = append(names, a), append(descriptions, o.help)
+			}
+			for _, key := range c.value_option_keys(o) {
+				if len(o.choices) > 0 {
+					candidates("v:"+key, o.choices, o.choices)
+				} else if o.takes_value {
+					// no candidates means PowerShell completes file names
+					f("    $names[%s] = @()", q("v:"+key))
+				}
+			}
+		}
+		candidates("o:"+c.path, names, descriptions)
+	}
+	f(`    $words = @($commandAst.CommandElements | Where-Object { $_.Extent.EndOffset -lt $cursorPosition } | ForEach-Object { $_.ToString() })`)
+	f(`    $cmd_path = ''`)
+	f(`    $prev = ''`)
+	f(`    foreach ($w in ($words | Select-Object -Skip 1)) {`)
+	f(`        $candidate = "$cmd_path $w".Trim()`)
+	f(`        if ($paths -contains $candidate) { $cmd_path = $candidate }`)
+	f(`        $prev = $w`)
+	f(`    }`)
+	f(`    $key = "c:$cmd_path"`)
+	f(`    if ($names.ContainsKey("v:${cmd_path}:$prev")) { $key = "v:${cmd_path}:$prev" }`)
+	f(`    elseif ($wordToComplete.StartsWith('-')) { $key = "o:$cmd_path" }`)
+	f(`    if (-not $names.ContainsKey($key)) { return }`)
+	f(`    $n = $names[$key]`)
+	f(`    $d = $descriptions[$key]`)
+	f(`    for ($i = 0; $i -lt $n.Count; $i++) {`)
+	f(`        if ($n[$i].StartsWith($wordToComplete)) {`)
+	f(`            $desc = if ($d[$i]) { $d[$i] } else { $n[$i] }`)
+	f(`            [System.Management.Automation.CompletionResult]::new($n[$i], $n[$i], 'ParameterValue', $desc)`)
+	f(`        }`)
+	f(`    }`)
+	f("}")
+	return output.String()
+}
+
+func init() {
+	static_completion_generators["powershell"] = powershell_static_completion_script
+}
@@ -0,0 +1,168 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type static_option struct {
+	long_aliases, short_aliases []string
+	help                        string
+	takes_value                 bool
+	choices                     []string
+}
+
+func (self *static_option) all_aliases() []string {
+	return append(append([]string{}, self.long_aliases...), self.short_aliases...)
+}
+
+type static_command struct {
+	// The names of the sub-commands leading to this command, space separated,
+	// empty for the executable itself
+	path         string
+	help         string
+	sub_commands []*static_command
+	options      []*static_option
+}
+
+// The executable and all its visible sub-commands, recursively, flattened into
+// a single list, so that completion scripts can complete them without
+// running the executable
+type static_exe struct {
+	name     string
+	commands []*static_command
+}
+
+func (self *static_exe) sub_command_paths() []string {
+	ans := make([]string, 0, len(self.commands))
+	for _, c := range self.commands {
+		if c.path != "" {
+			ans = append(ans, c.path)
+		}
+	}
+	return ans
+}
+
+var non_identifier_chars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// A safe identifier for use in the names of shell functions
+func (self *static_exe) identifier() string {
+	return non_identifier_chars.ReplaceAllString(self.name, "_")
+}
+
+// The first sentence of the help text, as plain text
+func short_help(raw string) string {
+	text := plain_formatter.Prettify(prepare_help_text_for_display(raw))
+	text, _, _ = utils.Cut(strings.TrimSpace(text), "\n")
+	if idx := strings.Index(text, ". "); idx > -1 {
+		text = text[:idx+1]
+	}
+	return strings.TrimSpace(text)
+}
+
+func new_static_command(cmd *Command, path string) *static_command {
+	ans := static_command{path: path, help: short_help(cmd.ShortDescription)}
+	group_titles, gmap := cmd.GetVisibleOptions()
+	for _, title := range group_titles {
+		for _, opt := range gmap[title] {
+			so := static_option{help: short_help(opt.Help), takes_value: opt.needs_argument(), choices: opt.Choices}
+			for _, a := range opt.Aliases {
+				if a.IsShort {
+					so.short_aliases = append(so.short_aliases, a.String())
+				} else {
+					so.long_aliases = append(so.long_aliases, a.String())
+				}
+			}
+			ans.options = append(ans.options, &so)
+		}
+	}
+	return &ans
+}
+
+func new_static_exe(exe *Command) *static_exe {
+	ans := static_exe{name: exe.Name}
+	var process func(cmd *Command, path string) *static_command
+	process = func(cmd *Command, path string) *static_command {
+		sc := new_static_command(cmd, path)
+		ans.commands = append(ans.commands, sc)
+		for _, g := range cmd.SubCommandGroups {
+			for _, c := range g.SubCommands {
+				if !c.Hidden {
+					sc.sub_commands = append(sc.sub_commands, process(c, strings.TrimSpace(path+" "+c.Name)))
+				}
+			}
+		}
+		return sc
+	}
+	process(exe, "")
+	return &ans
+}
+
+func (self *static_command) name() string {
+	if idx := strings.LastIndex(self.path, " "); idx > -1 {
+		return self.path[idx+1:]
+	}
+	return self.path
+}
+
+type static_completion_generator func(exe *static_exe) string
+
+var static_completion_generators = make(map[string]static_completion_generator, 8)
+
+// Generate a completion script for the specified shell that completes the
+// sub-commands and options of all executables registered for completion,
+// without needing to run kitten every time completion is requested. Option
+// values other than fixed choices are completed as file names.
+func GenerateStaticCompletionScript(shell string) (string, error) {
+	gen := static_completion_generators[shell]
+	if gen == nil {
+		return "", fmt.Errorf("Unknown shell: %s", shell)
+	}
+	model := NewCompletionModel(func(root *Command) {
+		for _, re := range registered_exes {
+			re(root)
+		}
+	})
+	root, err := model.Root()
+	if err != nil {
+		return "", err
+	}
+	return generate_static_completion_script(root, gen), nil
+}
+
+func generate_static_completion_script(root *Command, gen static_completion_generator) string {
+	output := strings.Builder{}
+	for _, g := range root.SubCommandGroups {
+		for _, exe := range g.SubCommands {
+			output.WriteString(gen(new_static_exe(exe)))
+			output.WriteString("\n")
+		}
+	}
+	return output.String()
+}
+
+// A pattern for a case statement in POSIX shells matching any of the values
+func sh_case_pattern(values ...string) string {
+	q := make([]string, len(values))
+	for i, v := range values {
+		q[i] = utils.QuoteStringForSH(v)
+	}
+	return strings.Join(q, "|")
+}
+
+// The keys of the form path:option for all aliases of options that take values
+func (self *static_command) value_option_keys(o *static_option) []string {
+	aliases := o.all_aliases()
+	ans := make([]string, len(aliases))
+	for i, a := range aliases {
+		ans[i] = self.path + ":" + a
+	}
+	return ans
+}
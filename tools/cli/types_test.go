@@ -3,10 +3,14 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"kitty/tools/utils/shlex"
 )
@@ -102,3 +106,108 @@ func TestCLIParsing(t *testing.T) {
 		t.Fatalf("Invalid choice not caught")
 	}
 }
+
+type validated_options struct {
+	Level    int
+	Interval time.Duration
+	Mode     string
+	Input    string
+	Memory   string
+}
+
+func TestValidators(t *testing.T) {
+	tdir := t.TempDir()
+	existing := filepath.Join(tdir, "f")
+	os.WriteFile(existing, nil, 0o600)
+
+	root := NewRootCommand()
+	child := root.AddSubCommand(&Command{Name: "child"})
+	child.Add(OptionSpec{Name: "--level", Type: "int", Validator: IntRange(1, 3)})
+	child.Add(OptionSpec{Name: "--interval", Type: "duration", Default: "1s", Validator: DurationRange(time.Second, time.Hour)})
+	child.Add(OptionSpec{Name: "--mode", Validator: OneOf("fast", "slow")})
+	child.Add(OptionSpec{Name: "--input", Validator: ExistingFile()})
+	child.Add(OptionSpec{Name: "--memory", Validator: ByteSize()})
+
+	parse := func(cmdline string) (*validated_options, error) {
+		defer root.ResetAfterParseArgs()
+		cmd, err := child.ParseArgs(strings.Split("test child "+cmdline, " "))
+		if err != nil {
+			return nil, err
+		}
+		ans := validated_options{}
+		return &ans, cmd.GetOptionValues(&ans)
+	}
+	ok := func(cmdline string, expected validated_options) {
+		actual, err := parse(cmdline)
+		if err != nil {
+			t.Fatalf("Failed to parse: %#v with error: %s", cmdline, err)
+		}
+		if !reflect.DeepEqual(&expected, actual) {
+			t.Fatalf("Option values incorrect for: %#v\n%#v != %#v", cmdline, expected, *actual)
+		}
+	}
+	fails := func(cmdline string) {
+		if _, err := parse(cmdline); err == nil {
+			t.Fatalf("Invalid value not caught for: %#v", cmdline)
+		}
+	}
+	ok("--level 2", validated_options{Level: 2, Interval: time.Second})
+	ok("--interval 5m --mode slow", validated_options{Interval: 5 * time.Minute, Mode: "slow"})
+	ok("--interval 2.5", validated_options{Interval: 2500 * time.Millisecond})
+	ok("--input "+existing, validated_options{Interval: time.Second, Input: existing})
+	ok("--memory 512M", validated_options{Interval: time.Second, Memory: "512M"})
+	fails("--level 4")
+	fails("--interval 2h")
+	fails("--interval xyz")
+	fails("--mode medium")
+	fails("--input " + tdir)
+	fails("--input " + filepath.Join(tdir, "missing"))
+	fails("--memory 2X")
+	fails("--memory lots")
+
+	c := root.GetCompletions([]string{"child", "--mode", "f"}, nil)
+	if len(c.Groups) != 1 || len(c.Groups[0].Matches) != 1 || c.Groups[0].Matches[0].Word != "fast" {
+		t.Fatalf("Unexpected completions for --mode: %#v", c.Groups)
+	}
+}
+
+func TestSubCommandAliases(t *testing.T) {
+	root := NewRootCommand()
+	root.Name = "kitten"
+	fw := root.AddSubCommand(&Command{Name: "focus-window", Aliases: []string{"fw"}})
+	root.AddSubCommand(&Command{Name: "focus-tab"})
+
+	cmd, err := root.ParseArgs([]string{"kitten", "fw", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != fw || !reflect.DeepEqual([]string{"x"}, cmd.Args) {
+		t.Fatalf("Alias did not resolve to its command: %s %#v", cmd.Name, cmd.Args)
+	}
+	root.ResetAfterParseArgs()
+	if _, err = root.ParseArgs([]string{"kitten", "focus-widnow"}); err == nil || !strings.Contains(err.Error(), "Did you mean:\n\tfocus-window") {
+		t.Fatalf("Unexpected error for misspelled command: %v", err)
+	}
+	if s := root.SuggestionsForCommand("fx", 1); !reflect.DeepEqual([]string{"fw"}, s) {
+		t.Fatalf("Unexpected suggestions: %#v", s)
+	}
+	root.AddSubCommand(&Command{Name: "fw"})
+	if err = root.Validate(); err == nil {
+		t.Fatalf("Clash between a command name and an alias not detected")
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	conn_err := NewExitError(ExitCodeConnection, os.ErrDeadlineExceeded).WithHint("Is kitty running?")
+	for err, expected := range map[error]int{
+		nil: 0, fmt.Errorf("x"): ExitCodeFailure, &ParseError{Message: "x"}: ExitCodeUsage,
+		conn_err: ExitCodeConnection, fmt.Errorf("wrapped: %w", conn_err): ExitCodeConnection,
+	} {
+		if actual := ExitCodeForError(err); actual != expected {
+			t.Fatalf("Unexpected exit code for %v: %d != %d", err, expected, actual)
+		}
+	}
+	if !errors.Is(conn_err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ExitError does not wrap its underlying error")
+	}
+}
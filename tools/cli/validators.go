@@ -0,0 +1,155 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/utils/humanize"
+
+	"golang.org/x/exp/slices"
+)
+
+var _ = fmt.Print
+
+// A Validator performs additional checks on the value of an option after it
+// has been parsed
+type Validator struct {
+	// Return a description of the problem if the value is not valid, the
+	// value is of the type produced by the option, for example, int for
+	// integer options
+	Check func(val any) string
+	// A description of the acceptable values, shown in the help for the option
+	Description string
+	// Used to complete values for the option if it has no completer of its own
+	Completer CompletionFunc
+}
+
+func (self *Option) validate(raw string, val any) error {
+	if self.Validator == nil || self.Validator.Check == nil {
+		return nil
+	}
+	if msg := self.Validator.Check(val); msg != "" {
+		return &ParseError{Option: self, Message: fmt.Sprintf(":yellow:`%s` is not a valid value for :bold:`%s`. %s", raw, self.seen_option, msg)}
+	}
+	return nil
+}
+
+// The maximum number of values an integer range can have to be completed
+const max_range_completions = 64
+
+// Integer values between min and max, inclusive
+func IntRange(min, max int) *Validator {
+	ans := Validator{
+		Description: fmt.Sprintf("Must be between %d and %d.", min, max),
+		Check: func(val any) string {
+			if q := val.(int); q < min || q > max {
+				return fmt.Sprintf("Must be between %d and %d.", min, max)
+			}
+			return ""
+		},
+	}
+	if max-min < max_range_completions {
+		names := make([]string, 0, max-min+1)
+		for i := min; i <= max; i++ {
+			names = append(names, strconv.Itoa(i))
+		}
+		ans.Completer = NamesCompleter("Values", names...)
+	}
+	return &ans
+}
+
+// Float values between min and max, inclusive
+func FloatRange(min, max float64) *Validator {
+	return &Validator{
+		Description: fmt.Sprintf("Must be between %g and %g.", min, max),
+		Check: func(val any) string {
+			if q := val.(float64); q < min || q > max {
+				return fmt.Sprintf("Must be between %g and %g.", min, max)
+			}
+			return ""
+		},
+	}
+}
+
+// Sizes in bytes, such as 512K or 2G
+func ByteSize() *Validator {
+	return &Validator{
+		Description: "Must be a number of bytes optionally followed by one of K, M or G.",
+		Check: func(val any) string {
+			if _, err := humanize.ParseBytes(val.(string)); err != nil {
+				return "Must be a number of bytes optionally followed by one of K, M or G."
+			}
+			return ""
+		},
+	}
+}
+
+// Durations between min and max, inclusive, for options of type duration
+func DurationRange(min, max time.Duration) *Validator {
+	return &Validator{
+		Description: fmt.Sprintf("Must be between %s and %s.", min, max),
+		Check: func(val any) string {
+			if q := val.(time.Duration); q < min || q > max {
+				return fmt.Sprintf("Must be between %s and %s.", min, max)
+			}
+			return ""
+		},
+		Completer: NamesCompleter("Durations", min.String(), max.String()),
+	}
+}
+
+// One of the specified values. Unlike the Choices of an option, the first
+// value is not used as the default.
+func OneOf(values ...string) *Validator {
+	return &Validator{
+		Description: "Must be one of: " + strings.Join(values, ", "),
+		Check: func(val any) string {
+			if !slices.Contains(values, val.(string)) {
+				return "Must be one of: " + strings.Join(values, ", ")
+			}
+			return ""
+		},
+		Completer: NamesCompleter("Values", values...),
+	}
+}
+
+// The path to an existing file that is not a directory
+func ExistingFile() *Validator {
+	return &Validator{
+		Description: "Must be the path to an existing file.",
+		Check: func(val any) string {
+			s, err := os.Stat(val.(string))
+			if err != nil {
+				return "No file exists at this path."
+			}
+			if s.IsDir() {
+				return "This path is a directory, not a file."
+			}
+			return ""
+		},
+		Completer: FnmatchCompleter("Files", CWD, "*"),
+	}
+}
+
+// The path to an existing directory
+func ExistingDirectory() *Validator {
+	return &Validator{
+		Description: "Must be the path to an existing directory.",
+		Check: func(val any) string {
+			s, err := os.Stat(val.(string))
+			if err != nil {
+				return "No directory exists at this path."
+			}
+			if !s.IsDir() {
+				return "This path is a file, not a directory."
+			}
+			return ""
+		},
+		Completer: DirectoryCompleter("Directories", CWD),
+	}
+}
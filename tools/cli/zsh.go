@@ -51,7 +51,7 @@ func zsh_input_parser(data []byte, shell_state map[string]string) ([][]string, e
 }
 
 func (self *Match) FormatForCompletionList(max_word_len int, f *markup.Context, screen_width int) string {
-	word := self.Word
+	word := self.HighlightedWord(f)
 	desc := self.Description
 	if desc == "" {
 		return word
@@ -149,7 +149,74 @@ func zsh_output_serializer(completions []*Completions, shell_state map[string]st
 	return serialize(completions[0], f, screen_width)
 }
 
+func zsh_static_completion_script(exe *static_exe) string {
+	output := strings.Builder{}
+	f := func(format string, args ...any) { fmt.Fprintf(&output, format+"\n", args...) }
+	describe := func(name, help string) string {
+		return utils.QuoteStringForSH(strings.ReplaceAll(name, ":", "\\:") + ":" + help)
+	}
+	fname := "_ksi_static_" + exe.identifier()
+	f("%s() {", fname)
+	f(`    local cur="${words[CURRENT]}" prev="${words[CURRENT-1]}" cmd_path="" w i`)
+	f(`    local -a descs`)
+	if paths := exe.sub_command_paths(); len(paths) > 0 {
+		f(`    for (( i = 2; i < CURRENT; i++ )); do`)
+		f(`        w="${cmd_path:+$cmd_path }${words[i]}"`)
+		f(`        case "$w" in %s) cmd_path="$w";; esac`, sh_case_pattern(paths...))
+		f(`    done`)
+	}
+	f(`    case "$cmd_path:$prev" in`)
+	for _, c := range exe.commands {
+		for _, o := range c.options {
+			if o.takes_value {
+				if len(o.choices) > 0 {
+					q := make([]string, len(o.choices))
+					for i, x := range o.choices {
+						q[i] = utils.QuoteStringForSH(x)
+					}
+					f(`        %s) descs=(%s); compadd -a descs; return;;`, sh_case_pattern(c.value_option_keys(o)...), strings.Join(q, " "))
+				} else {
+					f(`        %s) _files; return;;`, sh_case_pattern(c.value_option_keys(o)...))
+				}
+			}
+		}
+	}
+	f(`    esac`)
+	f(`    if [[ "$cur" == -* ]]; then`)
+	f(`        case "$cmd_path" in`)
+	for _, c := range exe.commands {
+		q := make([]string, 0, len(c.options))
+		for _, o := range c.options {
+			for _, a := range o.all_aliases() {
+				q = append(q, describe(a, o.help))
+			}
+		}
+		if len(q) > 0 {
+			f(`            %s) descs=(%s);;`, sh_case_pattern(c.path), strings.Join(q, " "))
+		}
+	}
+	f(`        esac`)
+	f(`    else`)
+	f(`        case "$cmd_path" in`)
+	for _, c := range exe.commands {
+		q := make([]string, len(c.sub_commands))
+		for i, sc := range c.sub_commands {
+			q[i] = describe(sc.name(), sc.help)
+		}
+		if len(q) > 0 {
+			f(`            %s) descs=(%s);;`, sh_case_pattern(c.path), strings.Join(q, " "))
+		}
+	}
+	f(`        esac`)
+	f(`    fi`)
+	f(`    if (( ${#descs} )); then _describe -t commands %s descs; else _files; fi`, utils.QuoteStringForSH(exe.name))
+	f("}")
+	f("compdef %s %s", fname, utils.QuoteStringForSH(exe.name))
+	return output.String()
+}
+
 func init() {
 	input_parsers["zsh"] = zsh_input_parser
 	output_serializers["zsh"] = zsh_output_serializer
+	static_completion_generators["zsh"] = zsh_static_completion_script
 }
@@ -0,0 +1,238 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"kitty"
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
+	"kitty/tools/tty"
+	"kitty/tools/utils"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+type doctor_report struct {
+	formatter *markup.Context
+	output    io.Writer
+	problems  int
+}
+
+func (self *doctor_report) ok(msg string) {
+	fmt.Fprintln(self.output, self.formatter.Green("✔"), self.formatter.Prettify(msg))
+}
+
+func (self *doctor_report) note(msg string) {
+	fmt.Fprintln(self.output, self.formatter.Dim("•"), self.formatter.Prettify(msg))
+}
+
+func (self *doctor_report) problem(msg string, fixes ...string) {
+	self.problems++
+	fmt.Fprintln(self.output, self.formatter.BrightRed("✘"), self.formatter.Prettify(msg))
+	for _, fix := range fixes {
+		fmt.Fprintln(self.output, "   ", self.formatter.Title("Fix:"), self.formatter.Prettify(fix))
+	}
+}
+
+// Check that the socket kitty is supposed to be listening on exists and can
+// be used, returns false if there is no point trying to connect to it
+func (self *doctor_report) check_socket(address string, from_env bool) bool {
+	source := "the --to option"
+	if from_env {
+		source = "the KITTY_LISTEN_ON environment variable"
+	}
+	network, addr, err := utils.ParseSocketAddress(address)
+	if err != nil {
+		self.problem(fmt.Sprintf("The address %s from %s is invalid: %s", address, source, err),
+			"Use an address of the form unix:/path/to/socket or tcp:localhost:12345")
+		return false
+	}
+	self.ok(fmt.Sprintf("Using the address %s from %s", address, source))
+	if network == "unix" && !strings.HasPrefix(addr, "@") {
+		st, err := os.Stat(addr)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				self.problem(fmt.Sprintf("The socket %s does not exist", addr),
+					"Make sure kitty is running and was started with :option:`kitty --listen-on` or has :opt:`listen_on` set to this address in :file:`kitty.conf`. Note that unless it contains :code:`{kitty_pid}`, kitty appends its process id to the socket name from :opt:`listen_on`, use the :code:`connect` command in the shell to find the running instances.")
+			} else {
+				self.problem(fmt.Sprintf("Cannot access the socket %s: %s", addr, err), "Check the permissions of the directory the socket is in")
+			}
+			return false
+		}
+		if st.Mode()&os.ModeSocket == 0 {
+			self.problem(fmt.Sprintf("%s is not a socket", addr), "Point :option:`kitty @ --to` at the socket kitty is listening on")
+			return false
+		}
+		if unix.Access(addr, unix.R_OK|unix.W_OK) != nil {
+			self.problem(fmt.Sprintf("You do not have permission to connect to the socket %s", addr),
+				"The socket is probably owned by another user, run kitty as the same user you are running this command as")
+			return false
+		}
+		self.ok(fmt.Sprintf("The socket %s exists and is accessible", addr))
+	}
+	if !is_kitty_instance_alive(address) {
+		self.problem(fmt.Sprintf("Nothing is accepting connections at %s", address),
+			"Make sure kitty is running and listening on this address, if kitty was restarted, the address might have changed, use the :code:`connect` command in the shell to find the running instances")
+		return false
+	}
+	self.ok("kitty is accepting connections at " + address)
+	return true
+}
+
+// Check that the controlling terminal can be used to send commands, returns
+// false if there is no point trying
+func (self *doctor_report) check_tty() bool {
+	self.note("No address to connect to has been specified, commands are sent via the terminal")
+	f, err := os.Open(tty.Ctermid())
+	if err != nil {
+		self.problem("There is no terminal to send commands to",
+			"Use :option:`kitty @ --to` to connect to a kitty instance that is listening on a socket, see :opt:`listen_on`")
+		return false
+	}
+	f.Close()
+	if os.Getenv("KITTY_WINDOW_ID") == "" {
+		self.problem("This does not seem to be running inside a kitty window, the terminal must be kitty to send commands to it",
+			"Run this inside kitty, or use :option:`kitty @ --to` to connect to a kitty instance that is listening on a socket")
+		return false
+	}
+	self.ok("Running inside the kitty window: " + os.Getenv("KITTY_WINDOW_ID"))
+	return true
+}
+
+func (self *doctor_report) check_password() {
+	password, err := get_password(rc_global_opts.Password, rc_global_opts.PasswordFile, rc_global_opts.PasswordEnv, rc_global_opts.UsePassword)
+	if err != nil {
+		self.problem("Could not read the remote control password: "+err.Error(),
+			"Check the :option:`kitty @ --password`, :option:`kitty @ --password-file` and :option:`kitty @ --password-env` options")
+		return
+	}
+	if password == "" {
+		self.note("No password is being used, so kitty must have :opt:`allow_remote_control` set to :code:`yes`, :code:`socket` or :code:`socket-only`, or the window must have been launched with :option:`launch --allow-remote-control`")
+		return
+	}
+	if _, _, err = get_pubkey(""); err != nil {
+		self.problem("A password is specified but cannot be used: "+err.Error(),
+			"Passwords are encrypted with the public key kitty puts in the environment of the programs it runs, run this in a kitty window, or in a program started from one")
+		return
+	}
+	self.ok("A password is specified and can be encrypted for sending to kitty")
+}
+
+func (self *doctor_report) check_kitty_version() {
+	exe, err := exec.LookPath("kitty")
+	if err != nil {
+		self.note("The kitty executable was not found in PATH, cannot check if its version matches this kitten")
+		return
+	}
+	out, err := exec.Command(exe, "--version").Output()
+	if err != nil {
+		self.note(fmt.Sprintf("Could not get the version of %s: %s", exe, err))
+		return
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return
+	}
+	if fields[1] != kitty.VersionString {
+		self.problem(fmt.Sprintf("The kitty in PATH (%s) has version %s but this kitten has version %s", exe, fields[1], kitty.VersionString),
+			"Use the kitten that comes with your kitty, or update the older of the two, a kitten that is newer than kitty cannot control it")
+		return
+	}
+	self.ok(fmt.Sprintf("The kitty in PATH (%s) has the same version as this kitten: %s", exe, kitty.VersionString))
+}
+
+// Send a harmless command to kitty, reporting the reason for any failure
+func (self *doctor_report) check_command(cmd *cli.Command) {
+	rc, err := create_rc_ls(nil)
+	if err != nil {
+		self.problem(err.Error())
+		return
+	}
+	io_data := rc_io_data{
+		cmd: cmd, rc: rc, timeout: 5 * time.Second,
+		response_handler: func(string) error { return nil },
+	}
+	err = send_rc_command(&io_data)
+	if err == nil {
+		self.ok("kitty responded to a test command")
+		return
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Remote control is disabled"):
+		self.problem("kitty refused the test command as remote control is disabled",
+			"Add :code:`allow_remote_control yes` to :file:`kitty.conf` and restart kitty, or use :code:`allow_remote_control password` together with :opt:`remote_control_password`")
+	case strings.Contains(msg, "allowed over a socket only"):
+		self.problem("kitty only allows remote control over a socket, but the command was sent via the terminal",
+			"Use :option:`kitty @ --to` with the address from :opt:`listen_on`, or set :opt:`allow_remote_control` to :code:`yes`")
+	case strings.Contains(msg, "newer than this kitty instance"):
+		self.problem("This kitten, version "+kitty.VersionString+", is newer than the kitty it is talking to",
+			"Update kitty, or use the kitten that comes with it")
+	case strings.Contains(msg, "Timed out"):
+		self.problem("kitty did not respond to the test command",
+			"If a password is required, kitty might be waiting for you to allow the command. Otherwise make sure :opt:`allow_remote_control` is set in :file:`kitty.conf`, kitty ignores remote control commands sent via the terminal when it is not.")
+	default:
+		self.problem("The test command failed: " + msg)
+	}
+}
+
+// Run all the checks, returns the number of problems found
+func (self *doctor_report) run(cmd *cli.Command) int {
+	address, from_env := rc_global_opts.To, false
+	if address == "" {
+		address, from_env = os.Getenv("KITTY_LISTEN_ON"), true
+	}
+	var can_connect bool
+	if address == "" {
+		can_connect = self.check_tty()
+	} else {
+		can_connect = self.check_socket(address, from_env)
+	}
+	self.check_password()
+	self.check_kitty_version()
+	if can_connect {
+		self.check_command(cmd)
+	}
+	fmt.Fprintln(self.output)
+	if self.problems > 0 {
+		fmt.Fprintln(self.output, self.formatter.BrightRed(fmt.Sprintf("Found %d problem(s)", self.problems)))
+	} else {
+		fmt.Fprintln(self.output, self.formatter.Green("No problems found"))
+	}
+	return self.problems
+}
+
+func run_doctor(cmd *cli.Command, args []string) (int, error) {
+	if err := cmd.GetOptionValues(&rc_global_opts); err != nil {
+		return 1, err
+	}
+	r := doctor_report{formatter: markup.New(true), output: os.Stdout}
+	if r.run(cmd) > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func setup_doctor(parent *cli.Command) *cli.Command {
+	return parent.AddSubCommand(&cli.Command{
+		Name:             "doctor",
+		Usage:            "",
+		ShortDescription: "Diagnose problems with remote control",
+		HelpText:         "Check that commands can be sent to kitty, reporting how to fix any problems found. Checks the socket or terminal used to connect to kitty, the :opt:`allow_remote_control` setting, the password, if any, and that the versions of this kitten and kitty match. Exits with a non-zero code if any problems are found.",
+		Run:              run_doctor,
+	})
+}
+
+func init() {
+	register_at_cmd(setup_doctor)
+}
@@ -0,0 +1,129 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
+)
+
+var _ = fmt.Print
+
+// A fake kitty instance that replies to every remote control command with
+// the specified response
+func fake_kitty(t *testing.T, path string, response string) net.Listener {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Cannot listen on UNIX sockets: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var received []byte
+				buf := make([]byte, 4096)
+				for !bytes.Contains(received, []byte(cmd_escape_code_suffix)) {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					received = append(received, buf[:n]...)
+				}
+				conn.Write([]byte(cmd_escape_code_prefix + response + cmd_escape_code_suffix))
+			}()
+		}
+	}()
+	return l
+}
+
+func TestDoctorSocket(t *testing.T) {
+	tdir := t.TempDir()
+	output := bytes.Buffer{}
+	r := doctor_report{formatter: markup.New(false), output: &output}
+	test := func(address string, expected_ok bool, expected_output string) {
+		output.Reset()
+		r.problems = 0
+		if ok := r.check_socket(address, false); ok != expected_ok {
+			t.Fatalf("Unexpected result checking %s: %v\n%s", address, ok, output.String())
+		}
+		if expected_ok != (r.problems == 0) {
+			t.Fatalf("Unexpected number of problems checking %s: %d\n%s", address, r.problems, output.String())
+		}
+		if !strings.Contains(output.String(), expected_output) {
+			t.Fatalf("The report for %s does not contain: %#v\n%s", address, expected_output, output.String())
+		}
+	}
+
+	test("bogus:address", false, "is invalid")
+	test("unix:"+filepath.Join(tdir, "missing"), false, "does not exist")
+	regular := filepath.Join(tdir, "regular")
+	os.WriteFile(regular, nil, 0o600)
+	test("unix:"+regular, false, "is not a socket")
+
+	stale := filepath.Join(tdir, "stale")
+	l := fake_kitty(t, stale, `{"ok": true}`)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	test("unix:"+stale, false, "Nothing is accepting connections")
+
+	live := filepath.Join(tdir, "live")
+	l = fake_kitty(t, live, `{"ok": true}`)
+	defer l.Close()
+	test("unix:"+live, true, "kitty is accepting connections")
+}
+
+func TestDoctorCommand(t *testing.T) {
+	t.Setenv("KITTY_LISTEN_ON", "")
+	t.Setenv("KITTY_WINDOW_ID", "")
+	t.Setenv("KITTY_PUBLIC_KEY", "")
+	// so that the version of kitty is not checked
+	t.Setenv("PATH", t.TempDir())
+	tdir := t.TempDir()
+	root := cli.NewRootCommand()
+	EntryPoint(root)
+	defer func() { rc_global_opts = rc_global_options{}; global_options = GlobalOptions{} }()
+
+	for i, x := range []struct {
+		response, expected string
+		problems           int
+	}{
+		{`{"ok": true}`, "kitty responded to a test command", 0},
+		{`{"ok": false, "error": "Remote control is disabled. Add allow_remote_control to kitty.conf"}`, "as remote control is disabled", 1},
+		{`{"ok": false, "error": "Remote control is allowed over a socket only"}`, "only allows remote control over a socket", 1},
+		{`{"ok": false, "error": "Something else went wrong"}`, "The test command failed: Something else went wrong", 1},
+		{`not json`, "The test command failed: Invalid response", 1},
+	} {
+		path := filepath.Join(tdir, fmt.Sprintf("kitty-%d", i))
+		l := fake_kitty(t, path, x.response)
+		cmd, err := root.ParseArgs([]string{"kitten", "@", "--to", "unix:" + path, "doctor"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cmd.GetOptionValues(&rc_global_opts); err != nil {
+			t.Fatal(err)
+		}
+		output := bytes.Buffer{}
+		r := doctor_report{formatter: markup.New(false), output: &output}
+		problems := r.run(cmd)
+		l.Close()
+		root.ResetAfterParseArgs()
+		if problems != x.problems {
+			t.Fatalf("Unexpected number of problems with the response %s: %d\n%s", x.response, problems, output.String())
+		}
+		if !strings.Contains(output.String(), x.expected) {
+			t.Fatalf("The report for the response %s does not contain: %#v\n%s", x.response, x.expected, output.String())
+		}
+	}
+}
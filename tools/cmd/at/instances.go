@@ -0,0 +1,229 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// The address of the kitty instance commands run from the shell are sent to,
+// empty means use the default, see the --to option
+var shell_target string
+
+func kitty_socket_dirs() []string {
+	ans := []string{utils.RuntimeDir()}
+	if q := os.TempDir(); !utils.Contains(ans, q) {
+		ans = append(ans, q)
+	}
+	if runtime.GOOS != "windows" && !utils.Contains(ans, "/tmp") {
+		ans = append(ans, "/tmp")
+	}
+	return ans
+}
+
+func abstract_kitty_sockets() (ans []string) {
+	f, err := os.Open("/proc/net/unix")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		path := fields[len(fields)-1]
+		if strings.HasPrefix(path, "@") && strings.Contains(path, "kitty") {
+			ans = append(ans, "unix:"+path)
+		}
+	}
+	return
+}
+
+func is_kitty_instance_alive(address string) bool {
+	network, addr, err := utils.ParseSocketAddress(address)
+	if err != nil {
+		return false
+	}
+	conn, err := net.DialTimeout(network, addr, 250*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Find the addresses of running kitty instances that are listening for
+// remote control connections on UNIX sockets
+func discover_kitty_instances() []string {
+	seen := make(map[string]bool)
+	ans := []string{}
+	add := func(address string) {
+		if !seen[address] {
+			seen[address] = true
+			if is_kitty_instance_alive(address) {
+				ans = append(ans, address)
+			}
+		}
+	}
+	if q := os.Getenv("KITTY_LISTEN_ON"); strings.HasPrefix(q, "unix:") {
+		add(q)
+	}
+	for _, dir := range kitty_socket_dirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.Type()&os.ModeSocket != 0 && strings.Contains(e.Name(), "kitty") {
+				add("unix:" + filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	if runtime.GOOS == "linux" {
+		for _, x := range abstract_kitty_sockets() {
+			add(x)
+		}
+	}
+	return ans
+}
+
+const connect_help = "Choose the kitty instance to send commands to. Usage: connect [number|address|default]"
+
+func connect_command(args []string) int {
+	instances := discover_kitty_instances()
+	if len(args) == 0 {
+		if len(instances) == 0 {
+			fmt.Println("No kitty instances listening on UNIX sockets were found")
+		}
+		for i, x := range instances {
+			marker := " "
+			if x == shell_target {
+				marker = formatter.Green("*")
+			}
+			fmt.Printf("%s %d: %s\n", marker, i+1, x)
+		}
+		if shell_target == "" {
+			fmt.Println("Commands are currently sent to the default kitty instance")
+		}
+		return 0
+	}
+	q := args[0]
+	switch q {
+	case "default", "-":
+		shell_target = ""
+		fmt.Println("Commands will now be sent to the default kitty instance")
+		return 0
+	}
+	if n, err := strconv.Atoi(q); err == nil {
+		if n < 1 || n > len(instances) {
+			fmt.Fprintln(os.Stderr, "No kitty instance with number:", formatter.BrightRed(q)+". Use connect with no arguments to list instances")
+			return 1
+		}
+		q = instances[n-1]
+	}
+	if _, _, err := utils.ParseSocketAddress(q); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !is_kitty_instance_alive(q) {
+		fmt.Fprintln(os.Stderr, "Could not connect to kitty at:", formatter.BrightRed(q))
+		return 1
+	}
+	shell_target = q
+	fmt.Println("Commands will now be sent to:", formatter.Green(q))
+	return 0
+}
+
+// Remove the --all-instances flag from the command line, returning whether it was present
+func extract_all_instances_flag(parsed_cmdline []string) ([]string, bool) {
+	for i, x := range parsed_cmdline {
+		if x == "--" {
+			break
+		}
+		if x == "--all-instances" {
+			ans := append([]string{}, parsed_cmdline[:i]...)
+			return append(ans, parsed_cmdline[i+1:]...), true
+		}
+	}
+	return parsed_cmdline, false
+}
+
+// Merge the JSON arrays output by the instances into a single array, adding
+// the address of the instance to each entry. Entries that are not JSON objects
+// are wrapped in one. Returns false if the output from any instance is not a
+// JSON array.
+func merge_json_outputs(instances []string, outputs [][]byte) ([]byte, bool) {
+	merged := []any{}
+	for i, address := range instances {
+		var items []any
+		if json.Unmarshal(outputs[i], &items) != nil {
+			return nil, false
+		}
+		for _, item := range items {
+			if obj, ok := item.(map[string]any); ok {
+				obj["instance"] = address
+			} else {
+				item = map[string]any{"instance": address, "value": item}
+			}
+			merged = append(merged, item)
+		}
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Run the command against every discovered kitty instance. If the output from
+// all instances is a JSON array, the arrays are merged, with each entry tagged
+// with the address of its instance, otherwise the output from each instance is
+// printed under a header.
+func run_in_all_instances(parsed_cmdline []string, stdout, stderr io.Writer) (exit_code int, err error) {
+	instances := discover_kitty_instances()
+	if len(instances) == 0 {
+		return 1, fmt.Errorf("No kitty instances listening on UNIX sockets were found")
+	}
+	outputs := make([][]byte, len(instances))
+	for i, address := range instances {
+		buf := bytes.Buffer{}
+		rc, rerr := run_at_command_with_target(address, parsed_cmdline, &buf, stderr)
+		if rerr == ErrNoKittenExe {
+			return rc, rerr
+		}
+		if rc != 0 && exit_code == 0 {
+			exit_code = rc
+		}
+		outputs[i] = buf.Bytes()
+	}
+	if data, ok := merge_json_outputs(instances, outputs); ok {
+		stdout.Write(data)
+		fmt.Fprintln(stdout)
+		return
+	}
+	for i, address := range instances {
+		fmt.Fprintln(stdout, formatter.Title(address)+":")
+		stdout.Write(outputs[i])
+		if len(outputs[i]) > 0 && !bytes.HasSuffix(outputs[i], []byte("\n")) {
+			fmt.Fprintln(stdout)
+		}
+	}
+	return
+}
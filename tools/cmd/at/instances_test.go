@@ -0,0 +1,61 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestExtractAllInstancesFlag(t *testing.T) {
+	for _, x := range []struct {
+		cmdline  []string
+		expected []string
+		found    bool
+	}{
+		{[]string{"ls"}, []string{"ls"}, false},
+		{[]string{"ls", "--all-instances"}, []string{"ls"}, true},
+		{[]string{"--all-instances", "set-colors", "-a"}, []string{"set-colors", "-a"}, true},
+		{[]string{"launch", "--", "prog", "--all-instances"}, []string{"launch", "--", "prog", "--all-instances"}, false},
+	} {
+		original := append([]string{}, x.cmdline...)
+		actual, found := extract_all_instances_flag(x.cmdline)
+		if found != x.found {
+			t.Fatalf("Presence of --all-instances in %#v not detected correctly", x.cmdline)
+		}
+		if diff := cmp.Diff(x.expected, actual); diff != "" {
+			t.Fatalf("Failed to extract --all-instances from %#v:\n%s", x.cmdline, diff)
+		}
+		if diff := cmp.Diff(original, x.cmdline); diff != "" {
+			t.Fatalf("The command line was modified:\n%s", diff)
+		}
+	}
+}
+
+func TestMergeJSONOutputs(t *testing.T) {
+	instances := []string{"unix:/a", "unix:/b"}
+	data, ok := merge_json_outputs(instances, [][]byte{[]byte(`[{"id": 1}, 2]`), []byte(`[{"id": 3}]`)})
+	if !ok {
+		t.Fatalf("Failed to merge JSON arrays")
+	}
+	var actual []any
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := []any{
+		map[string]any{"id": 1.0, "instance": "unix:/a"},
+		map[string]any{"value": 2.0, "instance": "unix:/a"},
+		map[string]any{"id": 3.0, "instance": "unix:/b"},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("Incorrectly merged output:\n%s", diff)
+	}
+	if _, ok := merge_json_outputs(instances, [][]byte{[]byte(`[]`), []byte("not json\n")}); ok {
+		t.Fatalf("Output that is not a JSON array was merged")
+	}
+}
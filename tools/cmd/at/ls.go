@@ -0,0 +1,129 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type ls_node struct {
+	id, parent int
+	title      string
+}
+
+func flatten_ls_snapshot(s []ls_os_window) (os_windows []int, tabs, windows []ls_node) {
+	for _, osw := range s {
+		os_windows = append(os_windows, osw.Id)
+		for _, tab := range osw.Tabs {
+			tabs = append(tabs, ls_node{id: tab.Id, parent: osw.Id, title: tab.Title})
+			for _, w := range tab.Windows {
+				windows = append(windows, ls_node{id: w.Id, parent: tab.Id, title: w.Title})
+			}
+		}
+	}
+	return
+}
+
+// The position of every node that is also present in other, relative to the
+// other such nodes with the same parent, so that creating or closing a node
+// does not cause its siblings to be reported as moved
+func relative_positions(nodes []ls_node, other map[int]ls_node) map[int]int {
+	counts := make(map[int]int)
+	ans := make(map[int]int, len(nodes))
+	for _, n := range nodes {
+		if _, found := other[n.id]; found {
+			ans[n.id] = counts[n.parent]
+			counts[n.parent]++
+		}
+	}
+	return ans
+}
+
+func diff_ls_nodes(kind, parent_kind string, old, current []ls_node) (ans []string) {
+	old_map := make(map[int]ls_node, len(old))
+	for _, n := range old {
+		old_map[n.id] = n
+	}
+	current_map := make(map[int]ls_node, len(current))
+	for _, n := range current {
+		current_map[n.id] = n
+	}
+	for _, n := range old {
+		if _, found := current_map[n.id]; !found {
+			ans = append(ans, fmt.Sprintf("closed %s %d: %s", kind, n.id, n.title))
+		}
+	}
+	old_positions, current_positions := relative_positions(old, current_map), relative_positions(current, old_map)
+	for _, n := range current {
+		o, found := old_map[n.id]
+		if !found {
+			ans = append(ans, fmt.Sprintf("created %s %d in %s %d: %s", kind, n.id, parent_kind, n.parent, n.title))
+			continue
+		}
+		if o.title != n.title {
+			ans = append(ans, fmt.Sprintf("retitled %s %d: %#v -> %#v", kind, n.id, o.title, n.title))
+		}
+		if o.parent != n.parent {
+			ans = append(ans, fmt.Sprintf("moved %s %d from %s %d to %s %d", kind, n.id, parent_kind, o.parent, parent_kind, n.parent))
+		} else if old_positions[n.id] != current_positions[n.id] {
+			ans = append(ans, fmt.Sprintf("moved %s %d in %s %d from position %d to %d", kind, n.id, parent_kind, n.parent, old_positions[n.id]+1, current_positions[n.id]+1))
+		}
+	}
+	return
+}
+
+// Describe the changes between two outputs of ls, one change per line
+func diff_ls_snapshots(old, current []ls_os_window) (ans []string) {
+	old_os_windows, old_tabs, old_windows := flatten_ls_snapshot(old)
+	current_os_windows, current_tabs, current_windows := flatten_ls_snapshot(current)
+	for _, id := range old_os_windows {
+		if !utils.Contains(current_os_windows, id) {
+			ans = append(ans, fmt.Sprintf("closed OS window %d", id))
+		}
+	}
+	for _, id := range current_os_windows {
+		if !utils.Contains(old_os_windows, id) {
+			ans = append(ans, fmt.Sprintf("created OS window %d", id))
+		}
+	}
+	ans = append(ans, diff_ls_nodes("tab", "OS window", old_tabs, current_tabs)...)
+	ans = append(ans, diff_ls_nodes("window", "tab", old_windows, current_windows)...)
+	return
+}
+
+func handle_ls_response(response string) error {
+	if options_ls.Diff != "" {
+		data, err := os.ReadFile(options_ls.Diff)
+		if err != nil {
+			return err
+		}
+		old, current := []ls_os_window{}, []ls_os_window{}
+		if err = json.Unmarshal(data, &old); err != nil {
+			return fmt.Errorf("The file %s is not a snapshot created by ls with error: %w", options_ls.Diff, err)
+		}
+		if err = json.Unmarshal([]byte(response), &current); err != nil {
+			return fmt.Errorf("Invalid response from kitty with error: %w", err)
+		}
+		changes := diff_ls_snapshots(old, current)
+		if len(changes) == 0 {
+			fmt.Println("No changes")
+		}
+		for _, line := range changes {
+			fmt.Println(line)
+		}
+	}
+	if options_ls.SnapshotTo != "" {
+		return utils.AtomicWriteFile(options_ls.SnapshotTo, []byte(response), 0o644)
+	}
+	if options_ls.Diff == "" {
+		fmt.Println(strings.TrimRight(response, "\n \t"))
+	}
+	return nil
+}
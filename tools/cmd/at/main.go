@@ -174,6 +174,31 @@ func create_serializer(password string, encoded_pubkey string, io_data *rc_io_da
 	return nil
 }
 
+func trace_rc_data(direction string, data []byte) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", direction, data)
+}
+
+// Wrap the serializer so that every serialized command is printed to STDERR.
+// Encrypted commands are also printed before encryption, with the password
+// omitted.
+func tracing_serializer(s serializer_func, is_encrypted bool) serializer_func {
+	return func(rc *utils.RemoteControlCmd) (ans []byte, err error) {
+		ans, err = s(rc)
+		if err != nil {
+			return
+		}
+		if is_encrypted {
+			plain := *rc
+			plain.Password = ""
+			if q, merr := json.Marshal(&plain); merr == nil {
+				trace_rc_data("--> (before encryption)", q)
+			}
+		}
+		trace_rc_data("-->", ans)
+		return
+	}
+}
+
 type ResponseData struct {
 	as_str    string
 	is_string bool
@@ -201,6 +226,9 @@ type Response struct {
 	Traceback string       `json:"tb,omitempty"`
 }
 
+// Returned by a response handler to have the command sent to kitty again
+var repeat_command = errors.New("repeat command")
+
 type rc_io_data struct {
 	cmd                        *cli.Command
 	rc                         *utils.RemoteControlCmd
@@ -209,6 +237,8 @@ type rc_io_data struct {
 	string_response_is_err     bool
 	timeout                    time.Duration
 	multiple_payload_generator func(io_data *rc_io_data) (bool, error)
+	// when set, used to process the response instead of printing it
+	response_handler func(response string) error
 
 	chunks_done bool
 }
@@ -220,7 +250,7 @@ func (self *rc_io_data) next_chunk() (chunk []byte, err error) {
 	if self.multiple_payload_generator != nil {
 		is_last, err := self.multiple_payload_generator(self)
 		if err != nil {
-			return nil, err
+			return nil, as_exit_error(cli.ExitCodeFailure, err)
 		}
 		if is_last {
 			self.chunks_done = true
@@ -231,8 +261,26 @@ func (self *rc_io_data) next_chunk() (chunk []byte, err error) {
 	return self.serializer(self.rc)
 }
 
+// Wrap err in an ExitError with the specified code, unless it already is one
+func as_exit_error(code int, err error) error {
+	var ee *cli.ExitError
+	if err == nil || errors.As(err, &ee) {
+		return err
+	}
+	return cli.NewExitError(code, err)
+}
+
+// Errors when creating the payload to send to kitty are caused by invalid
+// arguments
+func usage_error(err error) error {
+	return as_exit_error(cli.ExitCodeUsage, err)
+}
+
 func get_response(do_io func(io_data *rc_io_data) ([]byte, error), io_data *rc_io_data) (ans *Response, err error) {
 	serialized_response, err := do_io(io_data)
+	if rc_global_opts.Trace && len(serialized_response) > 0 {
+		trace_rc_data("<--", serialized_response)
+	}
 	if err != nil {
 		if errors.Is(err, os.ErrDeadlineExceeded) && io_data.rc.Async != "" {
 			io_data.rc.Payload = nil
@@ -243,6 +291,7 @@ func get_response(do_io func(io_data *rc_io_data) ([]byte, error), io_data *rc_i
 			do_io(io_data)
 			err = fmt.Errorf("Timed out waiting for a response from kitty")
 		}
+		err = as_exit_error(cli.ExitCodeConnection, err)
 		return
 	}
 	if len(serialized_response) == 0 {
@@ -251,13 +300,13 @@ func get_response(do_io func(io_data *rc_io_data) ([]byte, error), io_data *rc_i
 			ans = &res
 			return
 		}
-		err = fmt.Errorf("Received empty response from kitty")
+		err = &cli.ExitError{Code: cli.ExitCodeConnection, Message: "Received empty response from kitty"}
 		return
 	}
 	var response Response
 	err = json.Unmarshal(serialized_response, &response)
 	if err != nil {
-		err = fmt.Errorf("Invalid response received from kitty, unmarshalling error: %w", err)
+		err = cli.NewExitError(cli.ExitCodeConnection, fmt.Errorf("Invalid response received from kitty, unmarshalling error: %w", err))
 		return
 	}
 	ans = &response
@@ -277,34 +326,48 @@ func send_rc_command(io_data *rc_io_data) (err error) {
 	if err != nil {
 		return
 	}
-	var response *Response
-	if global_options.to_network == "" {
-		response, err = get_response(do_tty_io, io_data)
-		if err != nil {
-			return
+	if rc_global_opts.Trace {
+		io_data.serializer = tracing_serializer(io_data.serializer, global_options.password != "")
+	}
+	for {
+		var response *Response
+		if global_options.to_network == "" {
+			response, err = get_response(do_tty_io, io_data)
+			if err != nil {
+				return
+			}
+		} else {
+			response, err = get_response(do_socket_io, io_data)
+			if err != nil {
+				return
+			}
 		}
-	} else {
-		response, err = get_response(do_socket_io, io_data)
-		if err != nil {
-			return
+		if err != nil || response == nil {
+			return err
 		}
-	}
-	if err != nil || response == nil {
-		return err
-	}
-	if !response.Ok {
-		if response.Traceback != "" {
-			fmt.Fprintln(os.Stderr, response.Traceback)
+		if !response.Ok {
+			if response.Traceback != "" {
+				fmt.Fprintln(os.Stderr, response.Traceback)
+			}
+			return &cli.ExitError{Code: cli.ExitCodeRemote, Message: response.Error}
 		}
-		return fmt.Errorf("%s", response.Error)
-	}
-	if response.Data.is_string && io_data.string_response_is_err {
-		return fmt.Errorf("%s", response.Data.as_str)
-	}
-	if response.Data.as_str != "" {
-		fmt.Println(strings.TrimRight(response.Data.as_str, "\n \t"))
+		if response.Data.is_string && io_data.string_response_is_err {
+			return &cli.ExitError{Code: cli.ExitCodeRemote, Message: response.Data.as_str}
+		}
+		if io_data.response_handler != nil {
+			err = io_data.response_handler(response.Data.as_str)
+			if err == repeat_command {
+				// send the same command again, with the same async id
+				io_data.chunks_done = false
+				continue
+			}
+			return err
+		}
+		if response.Data.as_str != "" {
+			fmt.Println(strings.TrimRight(response.Data.as_str, "\n \t"))
+		}
+		return
 	}
-	return
 }
 
 func get_password(password string, password_file string, password_env string, use_password string) (ans string, err error) {
@@ -400,6 +463,7 @@ func EntryPoint(tool_root *cli.Command) *cli.Command {
 	add_rc_global_opts(at_root_command)
 
 	global_options_group := at_root_command.OptionGroups[0]
+	add_shell_options(at_root_command)
 
 	for _, reg_func := range all_commands {
 		c := reg_func(at_root_command)
@@ -5,9 +5,16 @@ package at
 import (
 	"encoding/json"
 	"fmt"
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
 	"kitty/tools/crypto"
 	"kitty/tools/utils"
+	"kitty/tools/utils/shlex"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestEncodeJSON(t *testing.T) {
@@ -102,3 +109,323 @@ func TestRCSerialization(t *testing.T) {
 		t.Fatal("Incorrect version in encrypted command: ", ec.Version)
 	}
 }
+
+func TestParseCommandChain(t *testing.T) {
+	test := func(cmdline string, expected ...string) {
+		chain, err := parse_command_chain(cmdline)
+		if err != nil {
+			t.Fatalf("Failed to parse %#v with error: %s", cmdline, err)
+		}
+		var actual []string
+		for _, c := range chain {
+			actual = append(actual, c.operator+"|"+strings.Join(c.argv, " "))
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected chain for %#v:\n%s", cmdline, diff)
+		}
+	}
+	test("  ")
+	test("ls", "|ls")
+	test("a b && c || d", "|a b", "&&|c", "|||d")
+	test("a; b;", "|a", ";|b")
+	test(`send-text "x && y" ';' && c`, "|send-text x && y ;", "&&|c")
+	test(`ls $(a; b || c) && d`, "|ls $(a; b || c)", "&&|d")
+	test(`ls "it's $(a ';' b)"; c`, "|ls it's $(a ';' b)", ";|c")
+	test("ls;ls", "|ls", ";|ls")
+	test("ls&&ls||ls", "|ls", "&&|ls", "|||ls")
+	test(`ls 'x';ls`, "|ls x", ";|ls")
+	test(`ls 'x;y'\;z "a&&b"`, "|ls x;y;z a&&b")
+	test("a & b | c", "|a & b | c")
+	chain, _ := parse_command_chain(`set a=1;  ls $a "$(x; y)" ; z`)
+	if diff := cmp.Diff([]string{"set a=1", `ls $a "$(x; y)"`, "z"}, []string{chain[0].raw, chain[1].raw, chain[2].raw}); diff != "" {
+		t.Fatalf("Unexpected raw commands:\n%s", diff)
+	}
+	for _, bad := range []string{"&& a", "a &&", "a && || b", "ls;; ls", "; ls", "a&&;b", "a ||"} {
+		if _, err := parse_command_chain(bad); err == nil {
+			t.Fatalf("No error for invalid command line: %#v", bad)
+		}
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	shell_vars = map[string]string{"a": "1", "b": "x y", "q": `it's "q"`}
+	defer func() { shell_vars = make(map[string]string) }()
+	subst := func(cmd string) (string, error) { return "<" + cmd + ">", nil }
+	test := func(cmdline, expected string) {
+		actual, err := expand_variables(cmdline, subst)
+		if err != nil {
+			t.Fatalf("Failed to expand: %#v with error: %s", cmdline, err)
+		}
+		if actual != expected {
+			t.Fatalf("Failed to expand: %#v\n%#v != %#v", cmdline, expected, actual)
+		}
+	}
+	test("ls $a", "ls '1'")
+	test("ls ${a}x", "ls '1'x")
+	test("ls $b", "ls 'x y'")
+	test(`ls "$b $q"`, `ls "x y it's \"q\""`)
+	test("ls '$a' \\$a $unknown $", "ls '$a' \\$a $unknown $")
+	test("ls $(ls --match id:$a) x", "ls '<ls --match id:'\"'\"'1'\"'\"'>' x")
+	test("ls $(a (b) ')') x", "ls '<a (b) '\"'\"')'\"'\"'>' x")
+	if _, err := expand_variables("ls $(ls", subst); err == nil {
+		t.Fatalf("No error for unterminated command substitution")
+	}
+	expanded, _ := expand_variables("ls $q", subst)
+	argv, _ := shlex.Split(expanded)
+	if diff := cmp.Diff([]string{"ls", `it's "q"`}, argv); diff != "" {
+		t.Fatalf("Expanded value was not quoted correctly:\n%s", diff)
+	}
+}
+
+func TestRunCommandChain(t *testing.T) {
+	formatter = markup.New(false)
+	defer func() { shell_vars = make(map[string]string); formatter = nil }()
+	var substitutions []string
+	subst := func(cmd string) (string, error) {
+		substitutions = append(substitutions, cmd)
+		return "<" + cmd + ">", nil
+	}
+	test := func(cmdline string, expected_exit_code int, expected_vars map[string]string, expected_substitutions ...string) {
+		shell_vars = make(map[string]string)
+		substitutions = nil
+		exit_code, keep_going := run_command_chain(nil, cmdline, subst)
+		if exit_code != expected_exit_code || !keep_going {
+			t.Fatalf("Unexpected result for %#v: exit code: %d keep going: %v", cmdline, exit_code, keep_going)
+		}
+		if diff := cmp.Diff(expected_vars, shell_vars); diff != "" {
+			t.Fatalf("Unexpected variables after %#v:\n%s", cmdline, diff)
+		}
+		if diff := cmp.Diff(expected_substitutions, substitutions); diff != "" {
+			t.Fatalf("Unexpected substitutions for %#v:\n%s", cmdline, diff)
+		}
+	}
+	test("set a=1; set b=$a", 0, map[string]string{"a": "1", "b": "1"})
+	test("set a=1 && set b=$(x $a)", 0, map[string]string{"a": "1", "b": "<x '1'>"}, "x '1'")
+	test("set a=1 || set b=$(x)", 0, map[string]string{"a": "1"})
+	test("set a=1 && set -a && set b=$(x)", 1, map[string]string{"a": "1"})
+	test("set a=$(x; y) && set 'b=$a'", 0, map[string]string{"a": "<x; y>", "b": "$a"}, "x; y")
+	test("set a=$(x || set b=1", 1, map[string]string{})
+}
+
+func TestMatchCompletion(t *testing.T) {
+	ls_cache.mutex.Lock()
+	ls_cache.snapshot = []ls_os_window{{Id: 1, Tabs: []ls_tab{{Id: 2, Title: "tab one", Windows: []ls_window{
+		{Id: 3, Title: "vim (x)", Pid: 10, Cwd: "/tmp"}, {Id: 4, Title: "zsh", Pid: 11, Cwd: "/tmp", UserVars: map[string]string{"bookmark": "logs"}}}}}}}
+	ls_cache.fetched_at, ls_cache.target = time.Now().Add(time.Hour), shell_target
+	ls_cache.mutex.Unlock()
+	defer ls_cache.invalidate()
+
+	complete := func(for_tabs bool, word string) []string {
+		c := cli.Completions{}
+		match_expression_completer(for_tabs)(&c, word, 0)
+		ans := []string{}
+		for _, g := range c.Groups {
+			for _, m := range g.Matches {
+				ans = append(ans, m.Word)
+			}
+		}
+		return ans
+	}
+	check := func(for_tabs bool, word string, expected ...string) {
+		if diff := cmp.Diff(expected, complete(for_tabs, word)); diff != "" {
+			t.Fatalf("Unexpected completions for %#v:\n%s", word, diff)
+		}
+	}
+	check(false, "ti", "title:")
+	check(false, "id:", "id:3", "id:4")
+	check(false, "title:v", `title:vim\ \\\(x\\\)`)
+	check(false, "cwd:", "cwd:/tmp")
+	check(false, "state:f", "state:focused")
+	check(true, "id:", "id:2")
+	check(true, "window_id:4", "window_id:4")
+	check(false, "nosuch:", []string{}...)
+	check(false, "var:", "var:bookmark=logs")
+
+	bookmarks := func(args ...string) []string {
+		ans := []string{}
+		for _, g := range bookmark_completions(args).Groups {
+			for _, m := range g.Matches {
+				ans = append(ans, m.Word)
+			}
+		}
+		return ans
+	}
+	if diff := cmp.Diff([]string{"delete"}, bookmarks("del")); diff != "" {
+		t.Fatalf("Unexpected bookmark action completions:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"logs"}, bookmarks("delete", "l")); diff != "" {
+		t.Fatalf("Unexpected bookmark name completions:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"id:3", "id:4"}, bookmarks("add", "x", "--match", "id:")); diff != "" {
+		t.Fatalf("Unexpected bookmark match completions:\n%s", diff)
+	}
+
+	// the options that match windows and tabs use the right completers
+	for cmdline, expected := range map[string][]string{
+		"focus-window --match id:":   {"id:3", "id:4"},
+		"focus-tab --match id:":      {"id:2"},
+		"send-text --match-tab id:":  {"id:2"},
+		"detach-window -t index:":    {"index:0"},
+		"detach-window --match pid:": {"pid:10", "pid:11"},
+	} {
+		actual := []string{}
+		for _, g := range completions(cmdline, "").Groups {
+			for _, m := range g.Matches {
+				actual = append(actual, m.Word)
+			}
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected completions for %#v:\n%s", cmdline, diff)
+		}
+	}
+}
+
+func TestHelpSearch(t *testing.T) {
+	entries := []*help_entry{
+		{title: "launch", summary: "Run an arbitrary process in a new window/tab"},
+		{title: "launch --type", summary: "Where to launch the child process", is_option: true},
+		{title: "ls", summary: "List tabs/windows"},
+		{title: "set-tab-title", summary: "Set the tab title"},
+	}
+	titles := func(query string) []string {
+		ans := []string{}
+		for _, m := range filter_help_entries(entries, query) {
+			ans = append(ans, m.entry.title)
+		}
+		return ans
+	}
+	for query, expected := range map[string][]string{
+		"":     {"launch", "launch --type", "ls", "set-tab-title"},
+		"ls":   {"ls", "launch --type"},
+		"stt":  {"set-tab-title", "ls"},
+		"lnch": {"launch", "launch --type"},
+		"xyz":  {},
+	} {
+		if diff := cmp.Diff(expected, titles(query)); diff != "" {
+			t.Fatalf("Unexpected matches for %#v:\n%s", query, diff)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	entries := pick_entries([]ls_os_window{{Id: 1, Tabs: []ls_tab{
+		{Id: 2, Title: "editing", Windows: []ls_window{{Id: 3, Title: "vim", Cwd: "/src"}, {Id: 4, Title: "zsh", Cwd: "/tmp"}}},
+		{Id: 5, Title: "logs", Windows: []ls_window{{Id: 6, Title: "tail", Cwd: "/var/log"}}}}}})
+	ids := func(query string) []int {
+		ans := []int{}
+		for _, e := range filter_pick_entries(entries, query) {
+			ans = append(ans, e.window.Id)
+		}
+		return ans
+	}
+	for query, expected := range map[string][]int{
+		"":     {3, 4, 6},
+		"tmp":  {4},
+		"logs": {6},
+		"6":    {6},
+		"xyz":  {},
+	} {
+		if diff := cmp.Diff(expected, ids(query)); diff != "" {
+			t.Fatalf("Unexpected windows for %#v:\n%s", query, diff)
+		}
+	}
+	if q := match_option_for_windows([]int{3}); q != "--match id:3" {
+		t.Fatalf("Unexpected match option: %#v", q)
+	}
+	q := match_option_for_windows([]int{3, 6})
+	argv, _ := shlex.Split("close-window " + q)
+	if diff := cmp.Diff([]string{"close-window", "--match", "id:3 or id:6"}, argv); diff != "" {
+		t.Fatalf("Unexpected match option for multiple windows:\n%s", diff)
+	}
+}
+
+func TestLsDiff(t *testing.T) {
+	w := func(id int, title string) ls_window { return ls_window{Id: id, Title: title} }
+	old := []ls_os_window{{Id: 1, Tabs: []ls_tab{
+		{Id: 1, Title: "a", Windows: []ls_window{w(1, "vim"), w(2, "zsh")}},
+		{Id: 2, Title: "b", Windows: []ls_window{w(3, "htop")}},
+		{Id: 3, Title: "c", Windows: []ls_window{w(4, "less")}},
+	}}}
+	current := []ls_os_window{
+		{Id: 1, Tabs: []ls_tab{
+			{Id: 1, Title: "a", Windows: []ls_window{w(2, "zsh"), w(1, "nvim")}},
+			{Id: 3, Title: "c", Windows: []ls_window{w(4, "less"), w(5, "man")}},
+		}},
+		{Id: 2, Tabs: []ls_tab{{Id: 4, Title: "d", Windows: []ls_window{w(3, "htop")}}}},
+	}
+	expected := []string{
+		"created OS window 2",
+		"closed tab 2: b",
+		"created tab 4 in OS window 2: d",
+		"moved window 2 in tab 1 from position 2 to 1",
+		`retitled window 1: "vim" -> "nvim"`,
+		"moved window 1 in tab 1 from position 1 to 2",
+		"created window 5 in tab 3: man",
+		"moved window 3 from tab 2 to tab 4",
+	}
+	if diff := cmp.Diff(expected, diff_ls_snapshots(old, current)); diff != "" {
+		t.Fatalf("Unexpected ls diff:\n%s", diff)
+	}
+	if diff := diff_ls_snapshots(current, current); len(diff) != 0 {
+		t.Fatalf("Unexpected differences for identical snapshots: %#v", diff)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	f := func(format, input, expected string) {
+		t.Helper()
+		buf := strings.Builder{}
+		if err := write_formatted_output([]byte(input), format, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, buf.String()); diff != "" {
+			t.Fatalf("Unexpected %s output for: %s\n%s", format, input, diff)
+		}
+	}
+	input := `[{"id": 1, "title": "zsh", "tabs": [{"id": 2, "env": {}}], "focused": true}, {"id": 10, "title": "a: b"}]`
+	f("yaml", input, `- id: 1
+  title: zsh
+  tabs:
+    - id: 2
+      env: {}
+  focused: true
+- id: 10
+  title: "a: b"
+`)
+	f("table", input, `id  title  tabs      focused
+──  ─────  ────────  ───────
+1   zsh    [1 item]  true
+10  a: b
+`)
+	f("table", `{"b": "x", "a": [1, 2]}`, `key  value
+───  ─────────
+b    x
+a    [2 items]
+`)
+	f("table", "not json\n", "not json\n")
+	f("json", input, input)
+
+	argv, format := extract_format_suffix([]string{"ls", "::table"})
+	if diff := cmp.Diff([]string{"ls"}, argv); diff != "" || format != "table" {
+		t.Fatalf("Format suffix not extracted: %#v %#v", argv, format)
+	}
+	if argv, format = extract_format_suffix([]string{"send-text", "::x"}); len(argv) != 2 || format != shell_output_format {
+		t.Fatalf("Unknown format suffix extracted: %#v %#v", argv, format)
+	}
+}
+
+func TestParseSetSpacing(t *testing.T) {
+	ans, err := parse_set_spacing([]string{"padding-left=10", "margin-V=default", "padding-horizontal=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"padding-left": 3.0, "padding-right": 3.0, "margin-top": nil, "margin-bottom": nil}
+	if diff := cmp.Diff(expected, ans); diff != "" {
+		t.Fatalf("Unexpected spacing settings:\n%s", diff)
+	}
+	for _, bad := range []string{"padding", "paddingleft=1", "margin=x"} {
+		if _, err := parse_set_spacing([]string{bad}); err == nil {
+			t.Fatalf("No error for invalid setting: %#v", bad)
+		}
+	}
+}
@@ -12,13 +12,21 @@ import (
 	"kitty/tools/utils/shlex"
 	"os"
 	"strings"
+	"time"
 )
 
 var end_reading_from_stdin = errors.New("end reading from STDIN")
 var waiting_on_stdin = errors.New("wait for key events from STDIN")
 
+func send_text_chunk_size() int {
+	if options_send_text.ChunkSize < 1 {
+		return 1
+	}
+	return options_send_text.ChunkSize
+}
+
 func make_file_gen(f *os.File) func(*rc_io_data) (bool, error) {
-	chunk := make([]byte, 2048)
+	chunk := make([]byte, send_text_chunk_size())
 	file_gen := func(io_data *rc_io_data) (bool, error) {
 		n, err := f.Read(chunk)
 		if err != nil && !errors.Is(err, io.EOF) {
@@ -40,10 +48,7 @@ func parse_send_text(io_data *rc_io_data, args []string) error {
 		}
 		text := strings.Join(args, " ")
 		text_gen := func(io_data *rc_io_data) (bool, error) {
-			limit := len(text)
-			if limit > 2048 {
-				limit = 2048
-			}
+			limit := utils.Min(len(text), send_text_chunk_size())
 			set_payload_data(io_data, "base64:"+base64.StdEncoding.EncodeToString(utils.UnsafeStringToBytes(text[:limit])))
 			text = text[limit:]
 			return len(text) == 0, nil
@@ -95,11 +100,29 @@ func parse_send_text(io_data *rc_io_data, args []string) error {
 		}
 	}
 
+	if options_send_text.BracketedPaste && io_data.on_key_event == nil && len(generators) > 0 {
+		raw_gen := func(text string) func(*rc_io_data) (bool, error) {
+			return func(io_data *rc_io_data) (bool, error) {
+				set_payload_data(io_data, "text:"+text)
+				return true, nil
+			}
+		}
+		generators = append([]func(io_data *rc_io_data) (bool, error){raw_gen("\x1b[200~")}, generators...)
+		generators = append(generators, raw_gen("\x1b[201~"))
+	}
+
+	delay := time.Duration(options_send_text.ChunkDelay * float64(time.Second))
+	sent_first_chunk := false
+
 	io_data.multiple_payload_generator = func(io_data *rc_io_data) (bool, error) {
 		if len(generators) == 0 {
 			set_payload_data(io_data, "text:")
 			return true, nil
 		}
+		if delay > 0 && sent_first_chunk && io_data.on_key_event == nil {
+			time.Sleep(delay)
+		}
+		sent_first_chunk = true
 		finished, err := generators[0](io_data)
 		if finished {
 			generators = generators[1:]
@@ -0,0 +1,95 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kitty/tools/utils"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestSendTextPayloads(t *testing.T) {
+	defer func() { options_send_text = options_send_text_type{} }()
+	payloads := func(args ...string) (ans []string) {
+		io_data := &rc_io_data{rc: &utils.RemoteControlCmd{Payload: send_text_json_type{}}}
+		if err := parse_send_text(io_data, args); err != nil {
+			t.Fatal(err)
+		}
+		if !io_data.rc.NoResponse {
+			t.Fatalf("send-text waits for a response")
+		}
+		for finished := false; !finished; {
+			var err error
+			if finished, err = io_data.multiple_payload_generator(io_data); err != nil {
+				t.Fatal(err)
+			}
+			data := string(io_data.rc.Payload.(send_text_json_type).Data)
+			if strings.HasPrefix(data, "base64:") {
+				decoded, derr := base64.StdEncoding.DecodeString(data[len("base64:"):])
+				if derr != nil {
+					t.Fatal(derr)
+				}
+				data = "b:" + string(decoded)
+			}
+			ans = append(ans, data)
+			if len(ans) > 100 {
+				t.Fatalf("Too many payloads generated: %#v", ans)
+			}
+		}
+		return
+	}
+	test := func(expected []string, args ...string) {
+		if diff := cmp.Diff(expected, payloads(args...)); diff != "" {
+			t.Fatalf("Unexpected payloads for: %#v with options: %#v\n%s", args, options_send_text, diff)
+		}
+	}
+
+	options_send_text = options_send_text_type{ChunkSize: 2}
+	test([]string{"b:ab", "b:cd", "b:e"}, "abcde")
+	test([]string{"b:a ", "b:b\t"}, "a", `b\t`)
+	test([]string{"text:"})
+	options_send_text.ChunkSize = 0
+	if send_text_chunk_size() != 1 {
+		t.Fatalf("Invalid chunk size not clamped")
+	}
+	test([]string{"b:a", "b:b"}, "ab")
+
+	options_send_text = options_send_text_type{ChunkSize: 4, BracketedPaste: true}
+	test([]string{"text:\x1b[200~", "b:past", "b:e", "text:\x1b[201~"}, "paste")
+	// nothing to send, so nothing to wrap
+	test([]string{"text:"})
+
+	path := filepath.Join(t.TempDir(), "text")
+	if err := os.WriteFile(path, []byte("hello world"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	options_send_text = options_send_text_type{ChunkSize: 4, FromFile: path}
+	test([]string{"b:x", "b:hell", "b:o wo", "b:rld", "b:"}, "x")
+	options_send_text.FromFile = filepath.Join(t.TempDir(), "missing")
+	if err := parse_send_text(&rc_io_data{rc: &utils.RemoteControlCmd{}}, nil); err == nil {
+		t.Fatalf("Sending a file that does not exist did not fail")
+	}
+
+	options_send_text = options_send_text_type{ChunkSize: 1, ChunkDelay: 0.05}
+	start := time.Now()
+	test([]string{"b:a", "b:b", "b:c"}, "abc")
+	// the delay is only between chunks
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("Unexpected time taken to send chunks with a delay: %s", elapsed)
+	}
+	start = time.Now()
+	test([]string{"b:a"}, "a")
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Fatalf("Delay before sending the first chunk: %s", elapsed)
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"strings"
 
+	"kitty/tools/cli"
 	"kitty/tools/utils"
 )
 
 var valid_color_names = map[string]bool{"active_fg": true, "active_bg": true, "inactive_fg": true, "inactive_bg": true}
 
+var complete_tab_colors = cli.ColorSettingsCompleter("Tab colors", "active_fg", "active_bg", "inactive_fg", "inactive_bg")
+
 func parse_tab_colors(args []string) (map[string]any, error) {
 	ans := make(map[string]any, len(args))
 	for _, arg := range args {
@@ -3,6 +3,7 @@
 package at
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 
 	"kitty/tools/cli"
 	"kitty/tools/cli/markup"
+	"kitty/tools/tty"
 	"kitty/tools/tui/loop"
 	"kitty/tools/tui/readline"
 	"kitty/tools/utils"
@@ -25,17 +27,32 @@ var _ = fmt.Print
 var formatter *markup.Context
 
 const prompt = "🐱 "
+const right_prompt = "{exit_code} {duration}"
 
 var ErrExec = errors.New("Execute command")
 
+const trace_help = "Show the raw remote control payloads sent to kitty and the responses received. Usage: trace [on|off]"
+
+// When true, commands run from the shell print the raw remote control messages, see --trace
+var shell_trace bool
+
+// The loop reading input in the shell, nil when no input is being read
+var shell_lp *loop.Loop
+
 func shell_loop(rl *readline.Readline, kill_if_signaled bool) (int, error) {
-	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors)
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.InterpretComposeSequences)
 	if err != nil {
 		return 1, err
 	}
 	rl.ChangeLoopAndResetText(lp)
+	shell_lp = lp
+	defer func() { shell_lp = nil }()
 
 	lp.OnInitialize = func() (string, error) {
+		if shell_pending_input != "" || shell_pending_input_after_cursor != "" {
+			rl.SetText(shell_pending_input, shell_pending_input_after_cursor)
+			shell_pending_input, shell_pending_input_after_cursor = "", ""
+		}
 		rl.Start()
 		return "", nil
 	}
@@ -110,80 +127,273 @@ func show_basic_help() {
 			fmt.Fprintln(&output, "   ", sc.ShortDescription)
 		}
 	}
+	fmt.Fprintln(&output, " ", formatter.Green("connect"))
+	fmt.Fprintln(&output, "   ", connect_help)
+	fmt.Fprintln(&output, " ", formatter.Green("watch"))
+	fmt.Fprintln(&output, "   ", watch_help)
+	fmt.Fprintln(&output, " ", formatter.Green("set"))
+	fmt.Fprintln(&output, "   ", set_help)
+	fmt.Fprintln(&output, " ", formatter.Green("unset"))
+	fmt.Fprintln(&output, "   ", unset_help)
+	fmt.Fprintln(&output, " ", formatter.Green("vars"))
+	fmt.Fprintln(&output, "   ", vars_help)
+	fmt.Fprintln(&output, " ", formatter.Green("bookmark"))
+	fmt.Fprintln(&output, "   ", bookmark_help)
+	fmt.Fprintln(&output, " ", formatter.Green("trace"))
+	fmt.Fprintln(&output, "   ", trace_help)
+	fmt.Fprintln(&output, " ", formatter.Green("format"))
+	fmt.Fprintln(&output, "   ", format_help)
+	fmt.Fprintln(&output, " ", formatter.Green("pick"))
+	fmt.Fprintln(&output, "   ", pick_help)
+	fmt.Fprintln(&output, " ", formatter.Green("help"))
+	fmt.Fprintln(&output, "   ", help_help)
 	fmt.Fprintln(&output, " ", formatter.Green("exit"))
 	fmt.Fprintln(&output, "   ", "Exit this shell")
+	fmt.Fprintln(&output)
+	fmt.Fprintln(&output, "Add", formatter.Green("--all-instances"), "to any command to run it in all running kitty instances")
+	fmt.Fprintln(&output, "Commands can be chained with", formatter.Green(";")+",", formatter.Green("&&"), "and", formatter.Green("||"), "which work as in POSIX shells")
+	fmt.Fprintln(&output, "History references are expanded, showing the expanded command to be run with another", formatter.Green("Enter")+":", formatter.Green("!!"), "is the last command,", formatter.Green("!n"), "command number n,", formatter.Green("!-n"), "the nth last command and", formatter.Green("!focus"), "the last command starting with focus")
+	fmt.Fprintln(&output, "Variables are expanded with", formatter.Green("$name")+". Use", formatter.Green("$(command)"), "to substitute the output of a command, which can be piped through a program, for example:", formatter.Green("set wid=$(ls | jq '.[0].id')"))
 	cli.ShowHelpInPager(output.String())
 }
 
-func exec_command(at_root_command *cli.Command, rl *readline.Readline, cmdline string) bool {
-	parsed_cmdline, err := shlex.Split(cmdline)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Could not parse cmdline:", err)
-		return true
+func help_command(at_root_command *cli.Command, args []string) int {
+	if len(args) == 0 {
+		if shell_recorder != nil || !tty.IsTerminal(os.Stdout.Fd()) {
+			show_basic_help()
+			return 0
+		}
+		chosen, err := browse_help(at_root_command)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		shell_pending_input = chosen
+		return 0
+	}
+	if args[0] == "--list" {
+		show_basic_help()
+		return 0
+	}
+	switch args[0] {
+	case "exit":
+		fmt.Println("Exit this shell")
+	case "help":
+		fmt.Println(help_help)
+	case "watch":
+		fmt.Println(watch_help)
+	case "connect":
+		fmt.Println(connect_help)
+	case "set":
+		fmt.Println(set_help)
+	case "unset":
+		fmt.Println(unset_help)
+	case "vars":
+		fmt.Println(vars_help)
+	case "bookmark":
+		fmt.Println(bookmark_help)
+	case "trace":
+		fmt.Println(trace_help)
+	case "format":
+		fmt.Println(format_help)
+	case "pick":
+		fmt.Println(pick_help)
+	default:
+		sc := at_root_command.FindSubCommand(args[0])
+		if sc == nil {
+			report_unknown_command(at_root_command, args[0])
+			return 1
+		}
+		sc.ShowHelpWithCommandString(sc.Name)
+	}
+	return 0
+}
+
+func report_unknown_command(at_root_command *cli.Command, name string) {
+	if possibles := at_root_command.SuggestionsForCommand(name, 2); len(possibles) > 0 {
+		fmt.Fprintln(os.Stderr, "No command named", formatter.BrightRed(name)+". Did you mean:", formatter.Green(strings.Join(possibles, ", "))+"?")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "No command named", formatter.BrightRed(name)+". Type help for a list of commands")
+}
+
+// Run a single command returning its exit code and whether the shell should keep going
+func exec_single_command(at_root_command *cli.Command, parsed_cmdline []string) (int, bool) {
+	run := run_at_command
+	if q, all_instances := extract_all_instances_flag(parsed_cmdline); all_instances {
+		parsed_cmdline, run = q, run_in_all_instances
 	}
+	parsed_cmdline, output_format := extract_format_suffix(parsed_cmdline)
 	if len(parsed_cmdline) == 0 {
-		return true
+		return 0, true
 	}
-	cwd, _ := os.Getwd()
-	hi := readline.HistoryItem{Timestamp: time.Now(), Cmd: rl.AllText(), ExitCode: -1, Cwd: cwd}
 	switch parsed_cmdline[0] {
 	case "exit":
-		hi.ExitCode = 0
-		rl.AddHistoryItem(hi)
-		return false
+		return 0, false
 	case "help":
-		hi.ExitCode = 0
-		defer rl.AddHistoryItem(hi)
-		if len(parsed_cmdline) == 1 {
-			show_basic_help()
-			return true
-		}
-		switch parsed_cmdline[1] {
-		case "exit":
-			fmt.Println("Exit this shell")
-		case "help":
-			fmt.Println("Show help")
-		default:
-			sc := at_root_command.FindSubCommand(parsed_cmdline[1])
-			if sc == nil {
-				hi.ExitCode = 1
-				fmt.Fprintln(os.Stderr, "No command named", formatter.BrightRed(parsed_cmdline[1])+". Type help for a list of commands")
-			} else {
-				sc.ShowHelpWithCommandString(sc.Name)
-			}
+		return help_command(at_root_command, parsed_cmdline[1:]), true
+	case "connect":
+		return connect_command(parsed_cmdline[1:]), true
+	case "watch":
+		return watch_command(at_root_command, parsed_cmdline[1:]), true
+	case "set":
+		return set_command(parsed_cmdline[1:]), true
+	case "unset":
+		return unset_command(parsed_cmdline[1:]), true
+	case "vars":
+		return vars_command(), true
+	case "bookmark":
+		return bookmark_command(parsed_cmdline[1:]), true
+	case "trace":
+		return trace_command(parsed_cmdline[1:]), true
+	case "format":
+		return format_command(parsed_cmdline[1:]), true
+	case "pick":
+		return pick_command(at_root_command, parsed_cmdline[1:]), true
+	}
+	if at_root_command.FindSubCommand(parsed_cmdline[0]) == nil {
+		report_unknown_command(at_root_command, parsed_cmdline[0])
+		return 1, true
+	}
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	if shell_recorder != nil {
+		stdout, stderr = shell_recorder.output_writer(stdout), shell_recorder.output_writer(stderr)
+	}
+	var output bytes.Buffer
+	cmd_stdout := stdout
+	if output_format != "json" {
+		cmd_stdout = &output
+	}
+	exit_code, err := run(parsed_cmdline, cmd_stdout, stderr)
+	if output_format != "json" {
+		if werr := write_formatted_output(output.Bytes(), output_format, stdout); werr != nil && err == nil {
+			err = werr
 		}
-		return true
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if err == ErrNoKittenExe {
+			return 1, false
+		}
+	}
+	if exit_code == cli.ExitCodeConnection && parsed_cmdline[0] != "doctor" {
+		fmt.Fprintln(os.Stderr, "Use", formatter.Green("doctor"), "to diagnose problems with connecting to kitty")
+	}
+	return exit_code, true
+}
+
+func trace_command(args []string) int {
+	if len(args) == 0 {
+		if shell_trace {
+			fmt.Println("Tracing is on")
+		} else {
+			fmt.Println("Tracing is off")
+		}
+		return 0
+	}
+	switch args[0] {
+	case "on":
+		shell_trace = true
+	case "off":
+		shell_trace = false
 	default:
-		if at_root_command.FindSubCommand(parsed_cmdline[0]) == nil {
-			hi.ExitCode = 1
-			fmt.Fprintln(os.Stderr, "No command named", formatter.BrightRed(parsed_cmdline[0])+". Type help for a list of commands")
-			return true
+		fmt.Fprintln(os.Stderr, trace_help)
+		return 1
+	}
+	return 0
+}
+
+// Run a command line, returning the exit code of the last command that was
+// run and whether the shell should keep going
+func run_command_line(at_root_command *cli.Command, cmdline string) (exit_code int, keep_going bool) {
+	return run_command_chain(at_root_command, cmdline, run_command_substitution)
+}
+
+// Run the commands in the command line one after the other. The variables
+// and command substitutions in each command are expanded just before it
+// runs, so that they see the effects of the previous commands and are not
+// evaluated at all for commands that are skipped.
+func run_command_chain(at_root_command *cli.Command, cmdline string, run_substitution func(string) (string, error)) (exit_code int, keep_going bool) {
+	chain, err := parse_command_chain(cmdline)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not parse cmdline:", err)
+		return 1, true
+	}
+	keep_going = true
+	for i, c := range chain {
+		if i > 0 && ((c.operator == "&&" && exit_code != 0) || (c.operator == "||" && exit_code == 0)) {
+			continue
 		}
-		exe, err := os.Executable()
-		if err != nil {
-			exe, err = exec.LookPath("kitten")
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "Could not find the kitten executable")
-				return false
-			}
+		expanded, err := expand_variables(c.raw, run_substitution)
+		if err == nil {
+			c.argv, err = shlex.Split(expanded)
 		}
-		cmdline := []string{"kitten", "@"}
-		cmdline = append(cmdline, parsed_cmdline...)
-		cmd := exec.Cmd{Path: exe, Args: cmdline, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
-		err = cmd.Run()
-		hi.Duration = time.Now().Sub(hi.Timestamp)
-		hi.ExitCode = 0
 		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				hi.ExitCode = exitError.ExitCode()
-			}
 			fmt.Fprintln(os.Stderr, err)
+			exit_code = 1
+			continue
+		}
+		exit_code, keep_going = exec_single_command(at_root_command, c.argv)
+		if !keep_going {
+			break
 		}
-		rl.AddHistoryItem(hi)
 	}
-	return true
+	return
 }
 
+func exec_command(at_root_command *cli.Command, rl *readline.Readline, cmdline string) bool {
+	if strings.TrimSpace(cmdline) == "" {
+		return true
+	}
+	cwd, _ := os.Getwd()
+	hi := readline.HistoryItem{Timestamp: time.Now(), Cmd: rl.AllText(), Cwd: cwd}
+	var keep_going bool
+	hi.ExitCode, keep_going = run_command_line(at_root_command, cmdline)
+	hi.Duration = time.Since(hi.Timestamp)
+	rl.AddHistoryItem(hi)
+	if shell_recorder != nil {
+		if err := shell_recorder.record(cmdline, hi.ExitCode, hi.Timestamp, hi.Duration); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to record command with error:", err)
+		}
+	}
+	return keep_going
+}
+
+var ErrNoKittenExe = errors.New("Could not find the kitten executable")
+
+func run_at_command(parsed_cmdline []string, stdout, stderr io.Writer) (exit_code int, err error) {
+	return run_at_command_with_target(shell_target, parsed_cmdline, stdout, stderr)
+}
+
+func run_at_command_with_target(target string, parsed_cmdline []string, stdout, stderr io.Writer) (exit_code int, err error) {
+	exe, err := os.Executable()
+	if err != nil {
+		exe, err = exec.LookPath("kitten")
+		if err != nil {
+			return 1, ErrNoKittenExe
+		}
+	}
+	cmdline := []string{"kitten", "@"}
+	if target != "" {
+		cmdline = append(cmdline, "--to", target)
+	}
+	if shell_trace {
+		cmdline = append(cmdline, "--trace")
+	}
+	cmdline = append(cmdline, parsed_cmdline...)
+	cmd := exec.Cmd{Path: exe, Args: cmdline, Stdin: os.Stdin, Stdout: stdout, Stderr: stderr}
+	err = cmd.Run()
+	if err != nil {
+		exit_code = 1
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exit_code = exitError.ExitCode()
+		}
+	}
+	return
+}
+
+var completion_model *cli.CompletionModel
+
 func completions(before_cursor, after_cursor string) (ans *cli.Completions) {
 	const prefix = "kitten @ "
 	text := prefix + before_cursor
@@ -191,17 +401,46 @@ func completions(before_cursor, after_cursor string) (ans *cli.Completions) {
 	if len(argv) == 0 || position_of_last_arg < len(prefix) {
 		return
 	}
-	root := cli.NewRootCommand()
-	c := root.AddSubCommand(&cli.Command{Name: "kitten"})
-	EntryPoint(c)
-	root.Validate()
-	ans = root.GetCompletions(argv, nil)
+	if len(argv) > 3 && argv[2] == "bookmark" {
+		ans = bookmark_completions(argv[3:])
+		ans.CurrentWordIdx = position_of_last_arg - len(prefix)
+		return
+	}
+	if completion_model == nil {
+		completion_model = cli.NewCompletionModel(func(root *cli.Command) {
+			c := root.AddSubCommand(&cli.Command{Name: "kitten"})
+			EntryPoint(c)
+		})
+	}
+	ans, err := completion_model.GetCompletions(argv, func(c *cli.Completions) { c.Options.Fuzzy = true })
+	if err != nil {
+		return nil
+	}
 	ans.CurrentWordIdx = position_of_last_arg - len(prefix)
 	return
 }
 
 func shell_main(cmd *cli.Command, args []string) (int, error) {
 	formatter = markup.New(true)
+	if err := cmd.GetOptionValues(&rc_global_opts); err != nil {
+		return 1, err
+	}
+	shell_trace = rc_global_opts.Trace
+	sopts := shell_options{}
+	if err := cmd.GetOptionValues(&sopts); err != nil {
+		return 1, err
+	}
+	if sopts.Replay != "" {
+		return replay_transcript(cmd, sopts.Replay, sopts.ReplayMode, os.Stdout)
+	}
+	if sopts.Record != "" {
+		r, err := new_session_recorder(sopts.Record)
+		if err != nil {
+			return 1, err
+		}
+		shell_recorder = r
+		defer func() { shell_recorder = nil }()
+	}
 	fmt.Println("Welcome to the kitty shell!")
 	fmt.Println("Use", formatter.Green("help"), "for assistance or", formatter.Green("exit"), "to quit.")
 	if atwid := os.Getenv("KITTY_SHELL_ACTIVE_WINDOW_ID"); atwid != "" {
@@ -213,7 +452,9 @@ func shell_main(cmd *cli.Command, args []string) (int, error) {
 		}
 		fmt.Println(amsg)
 	}
-	rl := readline.New(nil, readline.RlInit{Prompt: prompt, Completer: completions, HistoryPath: filepath.Join(utils.CacheDir(), "shell.history.json")})
+	rl := readline.New(nil, readline.RlInit{Prompt: prompt, RightPrompt: right_prompt, Completer: completions, Suggester: readline.SuggestFromHistory, HistoryPath: filepath.Join(utils.CacheDir(), "shell.history.json"), HistoryPolicy: readline.HistoryPolicy{IgnoreSpace: true}, WrapMarker: formatter.Dim("↪") + " ", HistoryExpansion: true,
+		Keymap: readline.Keymap{pick_shortcut: {Callback: func(*readline.Readline, uint) error { return ErrPick }}},
+	})
 	defer func() {
 		rl.Shutdown()
 	}()
@@ -226,6 +467,11 @@ func shell_main(cmd *cli.Command, args []string) (int, error) {
 				if !exec_command(cmd, rl, cmdline) {
 					return 0, nil
 				}
+				ls_cache.invalidate()
+				continue
+			}
+			if err == ErrPick {
+				pick_into_command_line(rl.TextBeforeCursor(), rl.TextAfterCursor())
 				continue
 			}
 		}
@@ -0,0 +1,142 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"kitty/tools/cli"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+var _ = fmt.Print
+
+const bookmark_help = "Give windows names that can be used to match them with --match var:bookmark=name. Usage: bookmark add name --match id:5 | bookmark list | bookmark delete name [name...]"
+
+// The user variable on windows that stores the name of the bookmark
+const bookmark_var = "bookmark"
+
+var valid_bookmark_name = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func bookmark_match(name string) string {
+	return "var:" + bookmark_var + "=^" + name + "$"
+}
+
+// Map of bookmark names to the ids of the windows they refer to
+func bookmarks_from_snapshot(snapshot []ls_os_window) map[string][]int {
+	ans := make(map[string][]int)
+	for _, osw := range snapshot {
+		for _, tab := range osw.Tabs {
+			for _, w := range tab.Windows {
+				if name := w.UserVars[bookmark_var]; name != "" {
+					ans[name] = append(ans[name], w.Id)
+				}
+			}
+		}
+	}
+	return ans
+}
+
+func bookmark_add(name string, match_args []string) int {
+	if !valid_bookmark_name.MatchString(name) {
+		fmt.Fprintln(os.Stderr, "Invalid bookmark name:", formatter.BrightRed(name)+". Names can contain only letters, numbers, hyphens and underscores")
+		return 1
+	}
+	// a bookmark refers to a single window, so remove it from any window it was previously added to
+	run_at_command([]string{"set-user-vars", "--match", bookmark_match(name), bookmark_var}, io.Discard, io.Discard)
+	exit_code, err := run_at_command(append(append([]string{"set-user-vars"}, match_args...), bookmark_var+"="+name), os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return exit_code
+}
+
+func bookmark_delete(names []string) int {
+	for _, name := range names {
+		exit_code, err := run_at_command([]string{"set-user-vars", "--match", bookmark_match(name), bookmark_var}, os.Stdout, io.Discard)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if exit_code != 0 {
+			fmt.Fprintln(os.Stderr, "No bookmark named:", formatter.BrightRed(name))
+			return exit_code
+		}
+	}
+	return 0
+}
+
+func bookmark_list() int {
+	snapshot, err := fetch_ls_snapshot(shell_target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to get the list of windows with error:", err)
+		return 1
+	}
+	bookmarks := bookmarks_from_snapshot(snapshot)
+	names := maps.Keys(bookmarks)
+	slices.Sort(names)
+	for _, name := range names {
+		ids := make([]string, len(bookmarks[name]))
+		for i, wid := range bookmarks[name] {
+			ids[i] = fmt.Sprint(wid)
+		}
+		fmt.Printf("%s: window %s\n", formatter.Green(name), strings.Join(ids, ", "))
+	}
+	return 0
+}
+
+func bookmark_command(args []string) int {
+	if len(args) == 0 {
+		return bookmark_list()
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			break
+		}
+		return bookmark_add(args[1], args[2:])
+	case "list":
+		return bookmark_list()
+	case "delete":
+		if len(args) < 2 {
+			break
+		}
+		return bookmark_delete(args[1:])
+	}
+	fmt.Fprintln(os.Stderr, bookmark_help)
+	return 1
+}
+
+// Complete the arguments of the bookmark command, the last of which is the
+// word being completed
+func bookmark_completions(args []string) *cli.Completions {
+	ans := &cli.Completions{Options: cli.CompletionOptions{Fuzzy: true}}
+	word := args[len(args)-1]
+	switch {
+	case len(args) == 1:
+		mg := ans.AddMatchGroup("Actions")
+		for _, action := range []string{"add", "list", "delete"} {
+			ans.AddMatchIfMatches(mg, word, action)
+		}
+	case args[0] == "delete":
+		mg := ans.AddMatchGroup("Bookmarks")
+		for name := range bookmarks_from_snapshot(ls_cache.get()) {
+			ans.AddMatchIfMatches(mg, word, name)
+		}
+	case args[0] == "add" && len(args) > 2:
+		switch prev := args[len(args)-2]; {
+		case prev == "--match" || prev == "-m":
+			match_expression_completer(false)(ans, word, 0)
+		case strings.HasPrefix(word, "-"):
+			ans.AddMatchIfMatches(ans.AddMatchGroup("Options"), word, "--match")
+		}
+	}
+	return ans
+}
@@ -0,0 +1,323 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+
+	"golang.org/x/exp/slices"
+)
+
+var _ = fmt.Print
+
+const format_help = "Set how JSON output from commands is shown, for all commands, or for a single command by adding, for example, ::table at the end of it. Usage: format [json|yaml|table]"
+
+var output_formats = []string{"json", "yaml", "table"}
+
+// The format used for JSON output from commands run in the shell
+var shell_output_format = "json"
+
+func format_command(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Output format:", shell_output_format)
+		return 0
+	}
+	if len(args) > 1 || !slices.Contains(output_formats, args[0]) {
+		fmt.Fprintln(os.Stderr, format_help)
+		return 1
+	}
+	shell_output_format = args[0]
+	return 0
+}
+
+// Remove a trailing ::format argument from the command line, returning the
+// format to use for this command
+func extract_format_suffix(parsed_cmdline []string) ([]string, string) {
+	if n := len(parsed_cmdline); n > 0 {
+		last := parsed_cmdline[n-1]
+		if strings.HasPrefix(last, "::") && slices.Contains(output_formats, last[2:]) {
+			return parsed_cmdline[:n-1], last[2:]
+		}
+	}
+	return parsed_cmdline, shell_output_format
+}
+
+// A JSON object that remembers the order of its keys
+type json_object struct {
+	keys   []string
+	values map[string]any
+}
+
+func decode_json_value(dec *json.Decoder) (any, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch d := t.(type) {
+	case json.Delim:
+		switch d {
+		case '{':
+			ans := &json_object{values: make(map[string]any)}
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := kt.(string)
+				if ans.values[key], err = decode_json_value(dec); err != nil {
+					return nil, err
+				}
+				ans.keys = append(ans.keys, key)
+			}
+			_, err = dec.Token()
+			return ans, err
+		case '[':
+			ans := []any{}
+			for dec.More() {
+				v, err := decode_json_value(dec)
+				if err != nil {
+					return nil, err
+				}
+				ans = append(ans, v)
+			}
+			_, err = dec.Token()
+			return ans, err
+		}
+	}
+	return t, nil
+}
+
+// Parse JSON preserving the order of keys in objects, returns an error if
+// data is not a single valid JSON object or array
+func parse_ordered_json(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	ans, err := decode_json_value(dec)
+	if err != nil {
+		return nil, err
+	}
+	switch ans.(type) {
+	case *json_object, []any:
+	default:
+		return nil, fmt.Errorf("Not a JSON object or array")
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Trailing data after JSON")
+	}
+	return ans, nil
+}
+
+func yaml_scalar(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(x)
+	case json.Number:
+		return x.String()
+	case string:
+		needs_quoting := x == "" || strings.TrimSpace(x) != x || strings.ContainsAny(x, ":#{}[],&*!|>'\"%@`\n\t\\") || strings.HasPrefix(x, "-") || strings.HasPrefix(x, "?")
+		if !needs_quoting {
+			switch strings.ToLower(x) {
+			case "true", "false", "null", "yes", "no", "on", "off", "~":
+				needs_quoting = true
+			default:
+				_, err := strconv.ParseFloat(x, 64)
+				needs_quoting = err == nil
+			}
+		}
+		if needs_quoting {
+			return strconv.Quote(x)
+		}
+		return x
+	}
+	return fmt.Sprint(v)
+}
+
+func write_yaml(w *strings.Builder, v any, indent string) {
+	switch x := v.(type) {
+	case *json_object:
+		for i, k := range x.keys {
+			if i > 0 {
+				w.WriteString(indent)
+			}
+			w.WriteString(yaml_scalar(k) + ":")
+			write_yaml_child(w, x.values[k], indent)
+		}
+	case []any:
+		for i, item := range x {
+			if i > 0 {
+				w.WriteString(indent)
+			}
+			w.WriteString("-")
+			switch c := item.(type) {
+			case *json_object:
+				if len(c.keys) > 0 {
+					w.WriteString(" ")
+					write_yaml(w, c, indent+"  ")
+					continue
+				}
+			case []any:
+				if len(c) > 0 {
+					w.WriteString(" ")
+					write_yaml(w, c, indent+"  ")
+					continue
+				}
+			}
+			write_yaml_child(w, item, indent)
+		}
+	default:
+		w.WriteString(yaml_scalar(v) + "\n")
+	}
+}
+
+func write_yaml_child(w *strings.Builder, v any, indent string) {
+	switch c := v.(type) {
+	case *json_object:
+		if len(c.keys) == 0 {
+			w.WriteString(" {}\n")
+			return
+		}
+		w.WriteString("\n" + indent + "  ")
+		write_yaml(w, c, indent+"  ")
+	case []any:
+		if len(c) == 0 {
+			w.WriteString(" []\n")
+			return
+		}
+		w.WriteString("\n" + indent + "  ")
+		write_yaml(w, c, indent+"  ")
+	default:
+		w.WriteString(" " + yaml_scalar(v) + "\n")
+	}
+}
+
+func format_as_yaml(v any) string {
+	w := strings.Builder{}
+	write_yaml(&w, v, "")
+	return w.String()
+}
+
+// The text for a value in a table cell, nested values are summarized
+func table_cell(v any) string {
+	switch x := v.(type) {
+	case *json_object:
+		return fmt.Sprintf("{%d keys}", len(x.keys))
+	case []any:
+		if len(x) == 1 {
+			return "[1 item]"
+		}
+		return fmt.Sprintf("[%d items]", len(x))
+	case string:
+		return strings.ReplaceAll(x, "\n", "↵")
+	}
+	return yaml_scalar(v)
+}
+
+func render_table(headers []string, rows [][]string) string {
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = wcswidth.Stringwidth(h)
+	}
+	for _, row := range rows {
+		for i, c := range row {
+			widths[i] = utils.Max(widths[i], wcswidth.Stringwidth(c))
+		}
+	}
+	w := strings.Builder{}
+	write_row := func(row []string) {
+		line := strings.Builder{}
+		for i, c := range row {
+			line.WriteString(c + strings.Repeat(" ", widths[i]-wcswidth.Stringwidth(c)+2))
+		}
+		w.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	write_row(headers)
+	seps := make([]string, len(headers))
+	for i, width := range widths {
+		seps[i] = strings.Repeat("─", width)
+	}
+	write_row(seps)
+	for _, row := range rows {
+		write_row(row)
+	}
+	return w.String()
+}
+
+// Arrays of objects are shown with one row per object and one column per key,
+// objects are shown with one row per key
+func format_as_table(v any) string {
+	switch x := v.(type) {
+	case *json_object:
+		rows := make([][]string, len(x.keys))
+		for i, k := range x.keys {
+			rows[i] = []string{k, table_cell(x.values[k])}
+		}
+		return render_table([]string{"key", "value"}, rows)
+	case []any:
+		columns := []string{}
+		for _, item := range x {
+			if obj, ok := item.(*json_object); ok {
+				for _, k := range obj.keys {
+					if !slices.Contains(columns, k) {
+						columns = append(columns, k)
+					}
+				}
+			}
+		}
+		if len(columns) == 0 {
+			rows := make([][]string, len(x))
+			for i, item := range x {
+				rows[i] = []string{table_cell(item)}
+			}
+			return render_table([]string{"value"}, rows)
+		}
+		rows := make([][]string, 0, len(x))
+		for _, item := range x {
+			row := make([]string, len(columns))
+			if obj, ok := item.(*json_object); ok {
+				for i, k := range columns {
+					if val, found := obj.values[k]; found {
+						row[i] = table_cell(val)
+					}
+				}
+			} else {
+				row[0] = table_cell(item)
+			}
+			rows = append(rows, row)
+		}
+		return render_table(columns, rows)
+	}
+	return ""
+}
+
+// Write output from a command in the specified format, output that is not
+// JSON is written unchanged
+func write_formatted_output(output []byte, format string, w io.Writer) error {
+	v, err := parse_ordered_json(output)
+	if err != nil {
+		_, err = w.Write(output)
+		return err
+	}
+	text := ""
+	switch format {
+	case "yaml":
+		text = format_as_yaml(v)
+	case "table":
+		text = format_as_table(v)
+	default:
+		_, err = w.Write(output)
+		return err
+	}
+	_, err = io.WriteString(w, text)
+	return err
+}
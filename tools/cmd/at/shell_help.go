@@ -0,0 +1,220 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/utils/style"
+	"kitty/tools/wcswidth"
+
+	"golang.org/x/exp/slices"
+)
+
+var _ = fmt.Print
+
+const help_help = "Browse and search the available commands. Choosing a command inserts it into the prompt. Usage: help [--list|command]"
+
+// Text to be placed into the readline buffer the next time the prompt is shown
+var shell_pending_input string
+
+type help_entry struct {
+	title, summary string
+	insert         string
+	is_option      bool
+}
+
+type scored_help_entry struct {
+	entry *help_entry
+	score int
+}
+
+var builtin_help = [][2]string{
+	{"connect", connect_help}, {"watch", watch_help}, {"set", set_help}, {"unset", unset_help},
+	{"vars", vars_help}, {"bookmark", bookmark_help}, {"trace", trace_help}, {"format", format_help}, {"pick", pick_help}, {"help", help_help}, {"exit", "Exit this shell"},
+}
+
+func first_sentence(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if idx := strings.Index(text, ". "); idx > -1 {
+		text = text[:idx+1]
+	}
+	return text
+}
+
+func help_entries(at_root_command *cli.Command) []*help_entry {
+	plain := markup.New(false)
+	ans := make([]*help_entry, 0, 1024)
+	for _, g := range at_root_command.SubCommandGroups {
+		for _, sc := range g.SubCommands {
+			ans = append(ans, &help_entry{title: sc.Name, summary: sc.ShortDescription, insert: sc.Name + " "})
+			for _, og := range sc.OptionGroups {
+				for _, opt := range og.Options {
+					if opt.Hidden || len(opt.Aliases) == 0 {
+						continue
+					}
+					name := opt.Aliases[0].String()
+					ans = append(ans, &help_entry{
+						title: sc.Name + " " + name, summary: first_sentence(plain.Prettify(opt.Help)),
+						insert: sc.Name + " " + name + " ", is_option: true})
+				}
+			}
+		}
+	}
+	for _, b := range builtin_help {
+		ans = append(ans, &help_entry{title: b[0], summary: b[1], insert: b[0] + " "})
+	}
+	return ans
+}
+
+func filter_help_entries(entries []*help_entry, query string) []scored_help_entry {
+	ans := make([]scored_help_entry, 0, len(entries))
+	for _, e := range entries {
+		score := utils.FuzzyScore(query, e.title)
+		if score < 0 {
+			if score = utils.FuzzyScore(query, e.summary); score < 0 {
+				continue
+			}
+			// matches in the title are more relevant than in the summary
+			score -= 10000
+		}
+		ans = append(ans, scored_help_entry{e, score})
+	}
+	if query != "" {
+		slices.SortStableFunc(ans, func(a, b scored_help_entry) bool { return a.score > b.score })
+	}
+	return ans
+}
+
+// Run an interactive browser for the available commands and their options.
+// Returns the text to insert for the chosen entry or the empty string if the
+// browser was closed without choosing anything.
+func browse_help(at_root_command *cli.Command) (string, error) {
+	lp, err := loop.New(loop.NoRestoreColors)
+	if err != nil {
+		return "", err
+	}
+	entries := help_entries(at_root_command)
+	query, chosen := "", ""
+	matches := filter_help_entries(entries, query)
+	current, scroll := 0, 0
+	fmt_ctx := style.Context{AllowEscapeCodes: true}
+	selected := fmt_ctx.SprintFunc("reverse")
+
+	draw_screen := func() error {
+		sz, err := lp.ScreenSize()
+		if err != nil {
+			return err
+		}
+		width, height := int(sz.WidthCells), int(sz.HeightCells)
+		// search line, blank line, list, blank line, footer
+		list_height := utils.Max(1, height-4)
+		if current < scroll {
+			scroll = current
+		} else if current >= scroll+list_height {
+			scroll = current - list_height + 1
+		}
+		lp.StartAtomicUpdate()
+		defer lp.EndAtomicUpdate()
+		lp.ClearScreen()
+		for i := scroll; i < len(matches) && i < scroll+list_height; i++ {
+			e := matches[i].entry
+			lp.MoveCursorTo(1, 3+i-scroll)
+			title := e.title
+			if e.is_option {
+				title = "  " + title
+			}
+			line := wcswidth.TruncateToVisualLength(title+"  "+e.summary, width-2)
+			title_part := utils.Min(len(title), len(line))
+			text := formatter.Green(line[:title_part]) + formatter.Dim(line[title_part:])
+			if i == current {
+				text = selected(" " + line + strings.Repeat(" ", utils.Max(0, width-2-wcswidth.Stringwidth(line))))
+			} else {
+				text = " " + text
+			}
+			lp.QueueWriteString(text)
+		}
+		lp.MoveCursorTo(1, height)
+		lp.QueueWriteString(formatter.Dim(wcswidth.TruncateToVisualLength(fmt.Sprintf(
+			"%d of %d  Enter: insert into prompt  Up/Down: select  Esc: close", len(matches), len(entries)), width-1)))
+		lp.MoveCursorTo(1, 1)
+		lp.QueueWriteString(formatter.Title("Search: ") + query)
+		return nil
+	}
+
+	update_matches := func() {
+		matches = filter_help_entries(entries, query)
+		current, scroll = 0, 0
+	}
+
+	lp.OnInitialize = func() (string, error) {
+		lp.SetCursorShape(loop.BAR_CURSOR, true)
+		return "", draw_screen()
+	}
+	lp.OnFinalize = func() string {
+		lp.SetCursorShape(loop.BLOCK_CURSOR, true)
+		return ""
+	}
+	lp.OnResize = func(old_size, new_size loop.ScreenSize) error { return draw_screen() }
+
+	lp.OnText = func(text string, from_key_event, in_bracketed_paste bool) error {
+		query += text
+		update_matches()
+		return draw_screen()
+	}
+
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		move := 0
+		switch {
+		case event.MatchesPressOrRepeat("esc") || event.MatchesPressOrRepeat("ctrl+c"):
+			lp.Quit(1)
+		case event.MatchesPressOrRepeat("enter"):
+			if current < len(matches) {
+				chosen = matches[current].entry.insert
+				lp.Quit(0)
+			} else {
+				lp.Beep()
+			}
+		case event.MatchesPressOrRepeat("backspace"):
+			if query == "" {
+				lp.Beep()
+			} else {
+				r := []rune(query)
+				query = string(r[:len(r)-1])
+				update_matches()
+			}
+		case event.MatchesPressOrRepeat("ctrl+u"):
+			query = ""
+			update_matches()
+		case event.MatchesPressOrRepeat("up") || event.MatchesPressOrRepeat("ctrl+p"):
+			move = -1
+		case event.MatchesPressOrRepeat("down") || event.MatchesPressOrRepeat("ctrl+n"):
+			move = 1
+		case event.MatchesPressOrRepeat("page_up"):
+			move = -10
+		case event.MatchesPressOrRepeat("page_down"):
+			move = 10
+		default:
+			return nil
+		}
+		event.Handled = true
+		if move != 0 {
+			current = utils.Max(0, utils.Min(current+move, len(matches)-1))
+		}
+		return draw_screen()
+	}
+
+	if err = lp.Run(); err != nil {
+		return "", err
+	}
+	if ds := lp.DeathSignalName(); ds != "" {
+		lp.KillIfSignalled()
+		return "", nil
+	}
+	return chosen, nil
+}
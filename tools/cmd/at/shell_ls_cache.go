@@ -0,0 +1,132 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var _ = fmt.Print
+
+// How long a snapshot of the output of ls is used for completions before it
+// is refreshed
+const ls_snapshot_ttl = 2 * time.Second
+
+type ls_window struct {
+	Id       int               `json:"id"`
+	Title    string            `json:"title"`
+	Pid      int               `json:"pid"`
+	Cwd      string            `json:"cwd"`
+	Cmdline  []string          `json:"cmdline"`
+	UserVars map[string]string `json:"user_vars"`
+}
+
+type ls_tab struct {
+	Id      int         `json:"id"`
+	Title   string      `json:"title"`
+	Windows []ls_window `json:"windows"`
+}
+
+type ls_os_window struct {
+	Id   int      `json:"id"`
+	Tabs []ls_tab `json:"tabs"`
+}
+
+type ls_snapshot_cache struct {
+	mutex      sync.Mutex
+	snapshot   []ls_os_window
+	fetched_at time.Time
+	target     string
+	refreshing bool
+}
+
+var ls_cache ls_snapshot_cache
+
+// When talking to kitty over a socket, ls can be run in the background while
+// the shell is reading input. Over the tty it cannot, as the child process
+// needs exclusive access to the tty, so the loop reading input is suspended
+// while it runs.
+func can_refresh_ls_in_background() bool {
+	return shell_target != "" || os.Getenv("KITTY_LISTEN_ON") != ""
+}
+
+func fetch_ls_snapshot(target string) ([]ls_os_window, error) {
+	buf := bytes.Buffer{}
+	exit_code, err := run_at_command_with_target(target, []string{"ls"}, &buf, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	if exit_code != 0 {
+		return nil, fmt.Errorf("ls failed with exit code: %d", exit_code)
+	}
+	ans := []ls_os_window{}
+	if err = json.Unmarshal(buf.Bytes(), &ans); err != nil {
+		return nil, err
+	}
+	return ans, nil
+}
+
+func (self *ls_snapshot_cache) is_stale_locked() bool {
+	return self.target != shell_target || time.Since(self.fetched_at) > ls_snapshot_ttl
+}
+
+func (self *ls_snapshot_cache) refresh(target string) {
+	snapshot, err := fetch_ls_snapshot(target)
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.refreshing = false
+	if err == nil {
+		self.snapshot, self.fetched_at, self.target = snapshot, time.Now(), target
+	}
+}
+
+// Return the current snapshot. If the snapshot is stale, a refresh is started
+// in the background when possible, without waiting for it. Otherwise, the
+// snapshot is refreshed over the tty, with the shell loop suspended, which
+// blocks, so it is done only when values from it are actually needed, for
+// completions.
+func (self *ls_snapshot_cache) get() []ls_os_window {
+	self.mutex.Lock()
+	needs_refresh := self.is_stale_locked() && !self.refreshing
+	if needs_refresh {
+		self.refreshing = true
+	}
+	self.mutex.Unlock()
+	if needs_refresh {
+		if can_refresh_ls_in_background() {
+			go self.refresh(shell_target)
+		} else if shell_lp != nil {
+			target := shell_target
+			if err := shell_lp.SuspendAndRun(func() error { self.refresh(target); return nil }); err != nil {
+				self.refresh_failed()
+			}
+		} else {
+			self.refresh_failed()
+		}
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.target != shell_target {
+		return nil
+	}
+	return self.snapshot
+}
+
+func (self *ls_snapshot_cache) refresh_failed() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.refreshing = false
+}
+
+// Called after commands are run, since they can change the state of kitty
+func (self *ls_snapshot_cache) invalidate() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.fetched_at = time.Time{}
+}
@@ -0,0 +1,121 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"kitty/tools/cli"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+var window_match_fields = []string{"id", "title", "pid", "cwd", "cmdline", "num", "env", "var", "state", "recent"}
+var tab_match_fields = []string{"id", "index", "title", "window_id", "window_title", "pid", "cwd", "cmdline", "env", "state", "recent"}
+var window_match_states = []string{"active", "focused", "needs_attention", "parent_active", "parent_focused", "self", "overlay_parent"}
+var tab_match_states = []string{"active", "focused", "needs_attention", "parent_active", "parent_focused"}
+
+type match_candidate struct {
+	value, description string
+}
+
+// Values for the specified match field from the current ls snapshot
+func live_match_candidates(field string, for_tabs bool) (ans []match_candidate) {
+	seen := make(map[string]bool)
+	add := func(value, description string) {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			ans = append(ans, match_candidate{value, description})
+		}
+	}
+	add_window := func(w ls_window) {
+		switch field {
+		case "id", "window_id":
+			add(strconv.Itoa(w.Id), w.Title)
+		case "title", "window_title":
+			add(regexp.QuoteMeta(w.Title), "")
+		case "pid":
+			add(strconv.Itoa(w.Pid), w.Title)
+		case "cwd":
+			add(regexp.QuoteMeta(w.Cwd), "")
+		case "cmdline":
+			if len(w.Cmdline) > 0 {
+				add(regexp.QuoteMeta(w.Cmdline[0]), "")
+			}
+		case "var":
+			for k, v := range w.UserVars {
+				add(k+"="+regexp.QuoteMeta(v), w.Title)
+			}
+		}
+	}
+	for _, osw := range ls_cache.get() {
+		for i, tab := range osw.Tabs {
+			if for_tabs {
+				switch field {
+				case "id":
+					add(strconv.Itoa(tab.Id), fmt.Sprintf("%s (OS window: %d)", tab.Title, osw.Id))
+					continue
+				case "index":
+					add(strconv.Itoa(i), "")
+					continue
+				case "title":
+					add(regexp.QuoteMeta(tab.Title), fmt.Sprintf("OS window: %d", osw.Id))
+					continue
+				}
+			}
+			for _, w := range tab.Windows {
+				add_window(w)
+			}
+		}
+	}
+	return
+}
+
+// Complete match expressions of the form field:query, using the state of
+// the running kitty instance for the values of fields
+func match_expression_completer(for_tabs bool) cli.CompletionFunc {
+	fields, states := window_match_fields, window_match_states
+	if for_tabs {
+		fields, states = tab_match_fields, tab_match_states
+	}
+	return func(completions *cli.Completions, word string, arg_num int) {
+		field, query, found := utils.Cut(word, ":")
+		if !found {
+			mg := completions.AddMatchGroup("Match fields")
+			mg.NoTrailingSpace = true
+			for _, f := range fields {
+				if m := completions.AddMatchIfMatches(mg, word, f); m != nil {
+					m.Word += ":"
+				}
+			}
+			completions.AddMatchIfMatches(completions.AddMatchGroup("Special values"), word, "all")
+			return
+		}
+		if !utils.Contains(fields, field) {
+			return
+		}
+		candidates := []match_candidate{}
+		if field == "state" {
+			for _, s := range states {
+				candidates = append(candidates, match_candidate{value: s})
+			}
+		} else {
+			candidates = live_match_candidates(field, for_tabs)
+		}
+		mg := completions.AddMatchGroup("Values for " + field)
+		for _, c := range candidates {
+			if m := completions.AddMatchIfMatches(mg, query, c.value, c.description); m != nil {
+				m.Word = utils.EscapeSHMetaCharacters(m.Word)
+			}
+		}
+		mg.AddPrefixToAllMatches(field + ":")
+	}
+}
+
+// Used for the options that match windows and tabs, see MATCH_WINDOW_OPTION
+// and MATCH_TAB_OPTION in kitty/rc/base.py
+var complete_window_match = match_expression_completer(false)
+var complete_tab_match = match_expression_completer(true)
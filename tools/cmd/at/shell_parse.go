@@ -0,0 +1,109 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/utils/shlex"
+)
+
+var _ = fmt.Print
+
+type chained_command struct {
+	// The operator joining this command to the previous one, one of: ;, && or ||
+	// Empty for the first command
+	operator string
+	argv     []string
+	// The text of the command in the command line, variables and command
+	// substitutions in it are expanded just before the command is run
+	raw string
+}
+
+func is_chain_operator(x string) bool {
+	return x == ";" || x == "&&" || x == "||"
+}
+
+// Replace the text inside command substitutions with x so that operators in
+// them do not split the command line. The length of the text is unchanged.
+func mask_command_substitutions(cmdline string) (string, error) {
+	ans := []byte(cmdline)
+	in_single, in_double := false, false
+	for i := 0; i < len(cmdline); i++ {
+		switch ch := cmdline[i]; {
+		case ch == '\\' && !in_single:
+			i++
+		case ch == '\'' && !in_double:
+			in_single = !in_single
+		case ch == '"' && !in_single:
+			in_double = !in_double
+		case ch == '$' && !in_single && i+1 < len(cmdline) && cmdline[i+1] == '(':
+			end := find_closing_paren(cmdline, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated command substitution: %s", cmdline[i:])
+			}
+			for j := i + 2; j < end; j++ {
+				ans[j] = 'x'
+			}
+			i = end
+		}
+	}
+	return string(ans), nil
+}
+
+// Split a command line into a list of commands separated by ;, && or ||, with
+// or without whitespace around them. Operators inside quotes, command
+// substitutions or escaped are treated as ordinary text.
+func parse_command_chain(cmdline string) (ans []chained_command, err error) {
+	masked, err := mask_command_substitutions(cmdline)
+	if err != nil {
+		return nil, err
+	}
+	operator, start := "", 0
+	finish := func(end int, next_operator string) error {
+		raw := strings.TrimSpace(cmdline[start:end])
+		if raw == "" {
+			switch {
+			case next_operator != "":
+				return fmt.Errorf("Syntax error near unexpected token: %s", next_operator)
+			case operator == "&&" || operator == "||":
+				return fmt.Errorf("Syntax error: command line ends with: %s", operator)
+			}
+			return nil
+		}
+		argv, err := shlex.Split(raw)
+		if err != nil {
+			return err
+		}
+		ans = append(ans, chained_command{operator: operator, argv: argv, raw: raw})
+		operator = next_operator
+		return nil
+	}
+	in_single, in_double := false, false
+	for i := 0; i < len(masked); i++ {
+		switch ch := masked[i]; {
+		case ch == '\\' && !in_single:
+			i++
+		case ch == '\'' && !in_double:
+			in_single = !in_single
+		case ch == '"' && !in_single:
+			in_double = !in_double
+		case in_single || in_double:
+		case ch == ';' || (i+1 < len(masked) && is_chain_operator(masked[i:i+2])):
+			op := masked[i : i+1]
+			if ch != ';' {
+				op = masked[i : i+2]
+			}
+			if err = finish(i, op); err != nil {
+				return nil, err
+			}
+			i += len(op) - 1
+			start = i + 1
+		}
+	}
+	if err = finish(len(masked), ""); err != nil {
+		return nil, err
+	}
+	return
+}
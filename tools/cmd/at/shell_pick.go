@@ -0,0 +1,298 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/utils/style"
+	"kitty/tools/wcswidth"
+
+	"golang.org/x/exp/slices"
+)
+
+var _ = fmt.Print
+
+const pick_help = "Choose windows interactively and insert the --match expression for them into the prompt. Usage: pick [command]. The command, if any, is inserted before the expression. Press ctrl+x m while editing a command to insert the expression at the cursor instead."
+
+// The keys that open the window picker while editing a command
+const pick_shortcut = "ctrl+x m"
+
+var ErrPick = errors.New("Pick windows")
+
+// Text to be placed after the cursor the next time the prompt is shown
+var shell_pending_input_after_cursor string
+
+type pick_entry struct {
+	window       ls_window
+	tab_title    string
+	os_window_id int
+	marked       bool
+}
+
+func (self *pick_entry) search_text() string {
+	return strings.Join([]string{strconv.Itoa(self.window.Id), self.window.Title, self.window.Cwd, self.tab_title}, " ")
+}
+
+func pick_entries(snapshot []ls_os_window) []*pick_entry {
+	ans := []*pick_entry{}
+	for _, osw := range snapshot {
+		for _, tab := range osw.Tabs {
+			for _, w := range tab.Windows {
+				ans = append(ans, &pick_entry{window: w, tab_title: tab.Title, os_window_id: osw.Id})
+			}
+		}
+	}
+	return ans
+}
+
+func filter_pick_entries(entries []*pick_entry, query string) []*pick_entry {
+	type scored struct {
+		entry *pick_entry
+		score int
+	}
+	matches := make([]scored, 0, len(entries))
+	for _, e := range entries {
+		if score := utils.FuzzyScore(query, e.search_text()); score > -1 {
+			matches = append(matches, scored{e, score})
+		}
+	}
+	if query != "" {
+		slices.SortStableFunc(matches, func(a, b scored) bool { return a.score > b.score })
+	}
+	ans := make([]*pick_entry, len(matches))
+	for i, m := range matches {
+		ans[i] = m.entry
+	}
+	return ans
+}
+
+// The --match option selecting exactly the windows with the specified ids,
+// quoted for the shell
+func match_option_for_windows(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = "id:" + strconv.Itoa(id)
+	}
+	expr := strings.Join(parts, " or ")
+	if len(ids) > 1 {
+		expr = "'" + expr + "'"
+	}
+	return "--match " + expr
+}
+
+// Run an interactive picker for the windows in snapshot. Returns the ids of
+// the chosen windows, empty if the picker was closed without choosing any.
+func pick_windows(snapshot []ls_os_window) ([]int, error) {
+	lp, err := loop.New(loop.NoRestoreColors)
+	if err != nil {
+		return nil, err
+	}
+	entries := pick_entries(snapshot)
+	query := ""
+	var chosen []int
+	matches := filter_pick_entries(entries, query)
+	current, scroll := 0, 0
+	fmt_ctx := style.Context{AllowEscapeCodes: true}
+	selected := fmt_ctx.SprintFunc("reverse")
+
+	num_marked := func() (ans int) {
+		for _, e := range entries {
+			if e.marked {
+				ans++
+			}
+		}
+		return
+	}
+
+	draw_screen := func() error {
+		sz, err := lp.ScreenSize()
+		if err != nil {
+			return err
+		}
+		width, height := int(sz.WidthCells), int(sz.HeightCells)
+		// search line, blank line, list, blank line, footer
+		list_height := utils.Max(1, height-4)
+		if current < scroll {
+			scroll = current
+		} else if current >= scroll+list_height {
+			scroll = current - list_height + 1
+		}
+		lp.BeginFrame()
+		defer lp.EndFrame()
+		lp.ClearScreen()
+		for i := scroll; i < len(matches) && i < scroll+list_height; i++ {
+			e := matches[i]
+			lp.MoveCursorTo(1, 3+i-scroll)
+			mark := "  "
+			if e.marked {
+				mark = "✓ "
+			}
+			title := fmt.Sprintf("%s%d %s", mark, e.window.Id, e.window.Title)
+			line := wcswidth.TruncateToVisualLength(fmt.Sprintf("%s  %s  [%s]", title, e.window.Cwd, e.tab_title), width-2)
+			title_part := utils.Min(len(title), len(line))
+			text := formatter.Green(line[:title_part]) + formatter.Dim(line[title_part:])
+			if i == current {
+				text = selected(" " + line + strings.Repeat(" ", utils.Max(0, width-2-wcswidth.Stringwidth(line))))
+			} else {
+				text = " " + text
+			}
+			lp.QueueWriteString(text)
+		}
+		lp.MoveCursorTo(1, height)
+		lp.QueueWriteString(formatter.Dim(wcswidth.TruncateToVisualLength(fmt.Sprintf(
+			"%d of %d, %d selected  Enter: insert --match  Tab: select multiple  Esc: close", len(matches), len(entries), num_marked()), width-1)))
+		lp.MoveCursorTo(1, 1)
+		lp.QueueWriteString(formatter.Title("Search: ") + query)
+		return nil
+	}
+
+	update_matches := func() {
+		matches = filter_pick_entries(entries, query)
+		current, scroll = 0, 0
+	}
+
+	lp.OnInitialize = func() (string, error) {
+		lp.SetCursorShape(loop.BAR_CURSOR, true)
+		return "", draw_screen()
+	}
+	lp.OnFinalize = func() string {
+		lp.SetCursorShape(loop.BLOCK_CURSOR, true)
+		return ""
+	}
+	lp.OnResize = func(old_size, new_size loop.ScreenSize) error { return draw_screen() }
+
+	lp.OnText = func(text string, from_key_event, in_bracketed_paste bool) error {
+		query += text
+		update_matches()
+		return draw_screen()
+	}
+
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		move := 0
+		switch {
+		case event.MatchesPressOrRepeat("esc") || event.MatchesPressOrRepeat("ctrl+c"):
+			lp.Quit(1)
+		case event.MatchesPressOrRepeat("enter"):
+			for _, e := range entries {
+				if e.marked {
+					chosen = append(chosen, e.window.Id)
+				}
+			}
+			if len(chosen) == 0 && current < len(matches) {
+				chosen = append(chosen, matches[current].window.Id)
+			}
+			if len(chosen) > 0 {
+				lp.Quit(0)
+			} else {
+				lp.Beep()
+			}
+		case event.MatchesPressOrRepeat("tab") || event.MatchesPressOrRepeat("shift+tab"):
+			if current < len(matches) {
+				matches[current].marked = !matches[current].marked
+				move = 1
+				if event.MatchesPressOrRepeat("shift+tab") {
+					move = -1
+				}
+			}
+		case event.MatchesPressOrRepeat("backspace"):
+			if query == "" {
+				lp.Beep()
+			} else {
+				r := []rune(query)
+				query = string(r[:len(r)-1])
+				update_matches()
+			}
+		case event.MatchesPressOrRepeat("ctrl+u"):
+			query = ""
+			update_matches()
+		case event.MatchesPressOrRepeat("up") || event.MatchesPressOrRepeat("ctrl+p"):
+			move = -1
+		case event.MatchesPressOrRepeat("down") || event.MatchesPressOrRepeat("ctrl+n"):
+			move = 1
+		case event.MatchesPressOrRepeat("page_up"):
+			move = -10
+		case event.MatchesPressOrRepeat("page_down"):
+			move = 10
+		default:
+			return nil
+		}
+		event.Handled = true
+		if move != 0 {
+			current = utils.Max(0, utils.Min(current+move, len(matches)-1))
+		}
+		return draw_screen()
+	}
+
+	if err = lp.Run(); err != nil {
+		return nil, err
+	}
+	if ds := lp.DeathSignalName(); ds != "" {
+		lp.KillIfSignalled()
+		return nil, nil
+	}
+	return chosen, nil
+}
+
+// Pick windows and return the --match option for them, empty if no windows
+// were picked
+func pick_match_option() (string, error) {
+	if shell_recorder != nil || !tty.IsTerminal(os.Stdout.Fd()) {
+		return "", fmt.Errorf("Windows can only be picked interactively in a terminal")
+	}
+	snapshot, err := fetch_ls_snapshot(shell_target)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get the list of windows from kitty: %w", err)
+	}
+	ids, err := pick_windows(snapshot)
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return match_option_for_windows(ids), nil
+}
+
+func pick_command(at_root_command *cli.Command, args []string) int {
+	if len(args) > 0 && at_root_command.FindSubCommand(args[0]) == nil {
+		report_unknown_command(at_root_command, args[0])
+		return 1
+	}
+	opt, err := pick_match_option()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if opt == "" {
+		return 1
+	}
+	shell_pending_input = strings.Join(append(args, opt), " ") + " "
+	return 0
+}
+
+// Insert the --match option for windows picked interactively at the cursor
+// of the command being edited, which is split into before and after
+func pick_into_command_line(before, after string) {
+	shell_pending_input, shell_pending_input_after_cursor = before, after
+	opt, err := pick_match_option()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if opt == "" {
+		return
+	}
+	if before != "" && !strings.HasSuffix(before, " ") {
+		opt = " " + opt
+	}
+	if !strings.HasPrefix(after, " ") {
+		opt += " "
+	}
+	shell_pending_input += opt
+}
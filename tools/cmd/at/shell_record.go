@@ -0,0 +1,176 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/utils"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+type shell_options struct {
+	Record     string
+	Replay     string
+	ReplayMode string
+}
+
+func add_shell_options(at_root_command *cli.Command) {
+	g := "Interactive shell options"
+	at_root_command.AddToGroup(g, cli.OptionSpec{
+		Name: "--record",
+		Help: "Record every command run in the interactive shell, with its output, exit code and timing to the specified file." +
+			" If the file name ends with :code:`.cast` an asciinema compatible recording is created, otherwise a JSON transcript is created.",
+	})
+	at_root_command.AddToGroup(g, cli.OptionSpec{
+		Name: "--replay",
+		Help: "Replay a JSON transcript created with :option:`--record` and exit.",
+	})
+	at_root_command.AddToGroup(g, cli.OptionSpec{
+		Name:    "--replay-mode",
+		Choices: "execute,print",
+		Help: "How to replay a transcript. :code:`execute` runs the recorded commands again," +
+			" :code:`print` shows the recorded output with the original timing.",
+	})
+}
+
+type transcript_entry struct {
+	Cmdline   string        `json:"cmdline"`
+	Output    string        `json:"output"`
+	ExitCode  int           `json:"exit_code"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+type transcript struct {
+	Version   int                `json:"version"`
+	StartedAt time.Time          `json:"started_at"`
+	Commands  []transcript_entry `json:"commands"`
+}
+
+type session_recorder struct {
+	path       string
+	is_cast    bool
+	transcript transcript
+	output     bytes.Buffer
+}
+
+// The recorder for the current session, nil when not recording
+var shell_recorder *session_recorder
+
+func new_session_recorder(path string) (*session_recorder, error) {
+	ans := session_recorder{path: path, is_cast: strings.HasSuffix(path, ".cast")}
+	ans.transcript = transcript{Version: 1, StartedAt: time.Now(), Commands: []transcript_entry{}}
+	if ans.is_cast {
+		width, height := 80, 24
+		if sz, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ); err == nil {
+			width, height = int(sz.Col), int(sz.Row)
+		}
+		header, _ := json.Marshal(map[string]any{"version": 2, "width": width, "height": height, "timestamp": ans.transcript.StartedAt.Unix()})
+		header = append(header, '\n')
+		if err := os.WriteFile(path, header, 0o600); err != nil {
+			return nil, err
+		}
+	} else {
+		// AtomicWriteFile only replaces files that exist
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			return nil, err
+		}
+		if err := ans.save(); err != nil {
+			return nil, err
+		}
+	}
+	return &ans, nil
+}
+
+func (self *session_recorder) save() error {
+	data, err := json.MarshalIndent(&self.transcript, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(self.path, data, 0o600)
+}
+
+// Return a writer that writes to w while also recording what is written
+func (self *session_recorder) output_writer(w io.Writer) io.Writer {
+	return io.MultiWriter(w, &self.output)
+}
+
+func (self *session_recorder) cast_event(at time.Time, text string) []byte {
+	offset := at.Sub(self.transcript.StartedAt).Seconds()
+	data, _ := json.Marshal([]any{offset, "o", text})
+	return append(data, '\n')
+}
+
+func (self *session_recorder) record(cmdline string, exit_code int, started_at time.Time, duration time.Duration) error {
+	entry := transcript_entry{Cmdline: cmdline, Output: self.output.String(), ExitCode: exit_code, StartedAt: started_at, Duration: duration}
+	self.output.Reset()
+	if !self.is_cast {
+		self.transcript.Commands = append(self.transcript.Commands, entry)
+		return self.save()
+	}
+	f, err := os.OpenFile(self.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	events := self.cast_event(started_at, prompt+cmdline+"\r\n")
+	if entry.Output != "" {
+		events = append(events, self.cast_event(started_at.Add(duration), strings.ReplaceAll(entry.Output, "\n", "\r\n"))...)
+	}
+	_, err = f.Write(events)
+	return err
+}
+
+func read_transcript(path string) (*transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ans := transcript{}
+	if err = json.Unmarshal(data, &ans); err != nil {
+		return nil, fmt.Errorf("%s is not a valid JSON transcript, asciinema recordings cannot be replayed. Error: %w", path, err)
+	}
+	return &ans, nil
+}
+
+// The maximum time to wait between commands when printing a transcript
+const max_replay_pause = 2 * time.Second
+
+// Replay the transcript, writing the commands and, in print mode, their
+// recorded output to w
+func replay_transcript(at_root_command *cli.Command, path, mode string, w io.Writer) (int, error) {
+	t, err := read_transcript(path)
+	if err != nil {
+		return 1, err
+	}
+	exit_code := 0
+	for i, entry := range t.Commands {
+		if mode == "print" && i > 0 {
+			prev := t.Commands[i-1]
+			pause := entry.StartedAt.Sub(prev.StartedAt.Add(prev.Duration))
+			time.Sleep(utils.Max(0, utils.Min(pause, max_replay_pause)))
+		}
+		fmt.Fprintln(w, prompt+entry.Cmdline)
+		if mode == "print" {
+			io.WriteString(w, entry.Output)
+			exit_code = entry.ExitCode
+			continue
+		}
+		var keep_going bool
+		if exit_code, keep_going = run_command_line(at_root_command, entry.Cmdline); !keep_going {
+			break
+		}
+	}
+	return exit_code, nil
+}
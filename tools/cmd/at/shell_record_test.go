@@ -0,0 +1,157 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+type recorded_command struct {
+	cmdline, output string
+	exit_code       int
+}
+
+// Record the commands as the shell does, the commands are not actually run
+func record_commands(t *testing.T, path string, commands ...recorded_command) *session_recorder {
+	r, err := new_session_recorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started_at := r.transcript.StartedAt
+	for _, c := range commands {
+		started_at = started_at.Add(200 * time.Millisecond)
+		io.WriteString(r.output_writer(io.Discard), c.output)
+		if err = r.record(c.cmdline, c.exit_code, started_at, 100*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestShellRecordAndReplay(t *testing.T) {
+	formatter = markup.New(false)
+	defer func() { shell_vars = make(map[string]string) }()
+	path := filepath.Join(t.TempDir(), "session.json")
+	commands := []recorded_command{
+		{"set a=1", "one\n", 0},
+		{"set b=2 || set c=3", "", 0},
+		{"exit", "", 0},
+		{"set d=4", "four\n", 1},
+	}
+	r := record_commands(t, path, commands...)
+
+	tr, err := read_transcript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Version != 1 || !tr.StartedAt.Equal(r.transcript.StartedAt) {
+		t.Fatalf("Unexpected transcript header: version: %d started at: %s", tr.Version, tr.StartedAt)
+	}
+	var actual []recorded_command
+	for i, e := range tr.Commands {
+		actual = append(actual, recorded_command{e.Cmdline, e.Output, e.ExitCode})
+		if expected := r.transcript.StartedAt.Add(time.Duration(i+1) * 200 * time.Millisecond); !e.StartedAt.Equal(expected) || e.Duration != 100*time.Millisecond {
+			t.Fatalf("Unexpected timing for %#v: %s %s", e.Cmdline, e.StartedAt, e.Duration)
+		}
+	}
+	if diff := cmp.Diff(commands, actual, cmp.AllowUnexported(recorded_command{})); diff != "" {
+		t.Fatalf("Commands not recorded correctly:\n%s", diff)
+	}
+
+	// commands are run again, stopping at exit
+	output := bytes.Buffer{}
+	shell_vars = make(map[string]string)
+	exit_code, err := replay_transcript(cli.NewRootCommand(), path, "execute", &output)
+	if err != nil || exit_code != 0 {
+		t.Fatalf("Replaying failed with exit code: %d and error: %v", exit_code, err)
+	}
+	if diff := cmp.Diff(map[string]string{"a": "1", "b": "2"}, shell_vars); diff != "" {
+		t.Fatalf("Replayed commands did not set the expected variables:\n%s", diff)
+	}
+	if diff := cmp.Diff(prompt+"set a=1\n"+prompt+"set b=2 || set c=3\n"+prompt+"exit\n", output.String()); diff != "" {
+		t.Fatalf("Unexpected output from replaying:\n%s", diff)
+	}
+
+	// the recorded output is printed with the recorded pauses between
+	// commands, without running the commands
+	output.Reset()
+	shell_vars = make(map[string]string)
+	start := time.Now()
+	if exit_code, err = replay_transcript(cli.NewRootCommand(), path, "print", &output); err != nil || exit_code != 1 {
+		t.Fatalf("Printing failed with exit code: %d and error: %v", exit_code, err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("The pauses between commands were not preserved: %s", elapsed)
+	}
+	expected := ""
+	for _, c := range commands {
+		expected += prompt + c.cmdline + "\n" + c.output
+	}
+	if diff := cmp.Diff(expected, output.String()); diff != "" {
+		t.Fatalf("Unexpected output from printing:\n%s", diff)
+	}
+	if len(shell_vars) != 0 {
+		t.Fatalf("Printing a transcript ran commands: %#v", shell_vars)
+	}
+
+	if _, err = replay_transcript(cli.NewRootCommand(), filepath.Join(t.TempDir(), "missing.json"), "print", &output); err == nil {
+		t.Fatalf("Replaying a missing transcript did not fail")
+	}
+}
+
+func TestShellRecordCast(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	r := record_commands(t, path, recorded_command{"ls", "a\nb\n", 0}, recorded_command{"set a=1", "", 0})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Unexpected number of lines in the recording: %#v", lines)
+	}
+	var header map[string]any
+	if err = json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header["version"] != 2.0 || header["timestamp"] != float64(r.transcript.StartedAt.Unix()) {
+		t.Fatalf("Unexpected header: %#v", header)
+	}
+	if w, h := header["width"].(float64), header["height"].(float64); w < 1 || h < 1 {
+		t.Fatalf("Invalid size in header: %#v", header)
+	}
+	var events [][]any
+	for _, line := range lines[1:] {
+		var ev []any
+		if err = json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	expected := [][]any{
+		{0.2, "o", prompt + "ls\r\n"},
+		{0.3, "o", "a\r\nb\r\n"},
+		{0.4, "o", prompt + "set a=1\r\n"},
+	}
+	if diff := cmp.Diff(expected, events); diff != "" {
+		t.Fatalf("Unexpected events:\n%s", diff)
+	}
+	if _, err = read_transcript(path); err == nil {
+		t.Fatalf("Reading an asciinema recording as a transcript did not fail")
+	}
+}
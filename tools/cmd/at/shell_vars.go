@@ -0,0 +1,212 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"kitty/tools/utils"
+	"kitty/tools/utils/shlex"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+var _ = fmt.Print
+
+const set_help = "Set session variables that can be used in commands as $name. Usage: set name=value [name=value...]"
+const unset_help = "Remove session variables. Usage: unset name [name...]"
+const vars_help = "List all session variables"
+
+var shell_vars = make(map[string]string)
+
+var valid_var_name = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func is_var_name_char(ch byte, first bool) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (!first && ch >= '0' && ch <= '9')
+}
+
+// Return the index of the ) that closes the ( just before start, taking
+// quoting and nesting into account
+func find_closing_paren(text string, start int) int {
+	depth := 1
+	in_single, in_double := false, false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '\\' && !in_single:
+			i++
+		case ch == '\'' && !in_double:
+			in_single = !in_single
+		case ch == '"' && !in_single:
+			in_double = !in_double
+		case in_single || in_double:
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Run a command substitution. The text before the first unquoted | is a
+// kitty shell command, the rest, if any, is a filter run by the system shell
+// that receives the output of the command on its STDIN.
+func run_command_substitution(text string) (string, error) {
+	kitty_cmd, filter := text, ""
+	in_single, in_double := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if ch == '\\' && !in_single {
+			i++
+		} else if ch == '\'' && !in_double {
+			in_single = !in_single
+		} else if ch == '"' && !in_single {
+			in_double = !in_double
+		} else if ch == '|' && !in_single && !in_double {
+			kitty_cmd, filter = text[:i], text[i+1:]
+			break
+		}
+	}
+	argv, err := shlex.Split(kitty_cmd)
+	if err != nil {
+		return "", err
+	}
+	if len(argv) == 0 {
+		return "", nil
+	}
+	buf := bytes.Buffer{}
+	exit_code, err := run_at_command(argv, &buf, os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if exit_code != 0 {
+		return "", fmt.Errorf("The command: %s failed with exit code: %d", strings.TrimSpace(kitty_cmd), exit_code)
+	}
+	output := buf.Bytes()
+	if strings.TrimSpace(filter) != "" {
+		sh := exec.Command("/bin/sh", "-c", filter)
+		sh.Stdin = bytes.NewReader(output)
+		sh.Stderr = os.Stderr
+		if output, err = sh.Output(); err != nil {
+			return "", fmt.Errorf("The filter: %s failed with error: %w", strings.TrimSpace(filter), err)
+		}
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// Expand $name, ${name} and $(command) in the command line. Nothing is
+// expanded inside single quotes and references to variables that are not set
+// are left unchanged. Expanded values are quoted so that they are not split
+// into multiple words or interpreted as operators.
+func expand_variables(cmdline string, run_substitution func(string) (string, error)) (string, error) {
+	ans := strings.Builder{}
+	ans.Grow(len(cmdline))
+	in_single, in_double := false, false
+	add_value := func(val string) {
+		if in_double {
+			val = strings.ReplaceAll(val, `\`, `\\`)
+			ans.WriteString(strings.ReplaceAll(val, `"`, `\"`))
+		} else {
+			ans.WriteString(utils.QuoteStringForSH(val))
+		}
+	}
+	for i := 0; i < len(cmdline); i++ {
+		ch := cmdline[i]
+		switch {
+		case ch == '\\' && !in_single:
+			ans.WriteByte(ch)
+			if i+1 < len(cmdline) {
+				i++
+				ans.WriteByte(cmdline[i])
+			}
+			continue
+		case ch == '\'' && !in_double:
+			in_single = !in_single
+		case ch == '"' && !in_single:
+			in_double = !in_double
+		case ch == '$' && !in_single && i+1 < len(cmdline):
+			rest := cmdline[i+1:]
+			switch {
+			case rest[0] == '(':
+				end := find_closing_paren(cmdline, i+2)
+				if end < 0 {
+					return "", fmt.Errorf("Unterminated command substitution: %s", cmdline[i:])
+				}
+				inner, err := expand_variables(cmdline[i+2:end], run_substitution)
+				if err != nil {
+					return "", err
+				}
+				val, err := run_substitution(inner)
+				if err != nil {
+					return "", err
+				}
+				add_value(val)
+				i = end
+				continue
+			case rest[0] == '{':
+				end := strings.IndexByte(rest, '}')
+				if end < 0 {
+					return "", fmt.Errorf("Unterminated variable reference: %s", cmdline[i:])
+				}
+				if val, found := shell_vars[rest[1:end]]; found {
+					add_value(val)
+					i += end + 1
+					continue
+				}
+			case is_var_name_char(rest[0], true):
+				end := 1
+				for end < len(rest) && is_var_name_char(rest[end], false) {
+					end++
+				}
+				if val, found := shell_vars[rest[:end]]; found {
+					add_value(val)
+					i += end
+					continue
+				}
+			}
+		}
+		ans.WriteByte(ch)
+	}
+	return ans.String(), nil
+}
+
+func set_command(args []string) int {
+	if len(args) == 0 {
+		return vars_command()
+	}
+	for _, arg := range args {
+		name, val, found := utils.Cut(arg, "=")
+		if !found || !valid_var_name.MatchString(name) {
+			fmt.Fprintln(os.Stderr, "Invalid variable assignment:", formatter.BrightRed(arg)+".", set_help)
+			return 1
+		}
+		shell_vars[name] = val
+	}
+	return 0
+}
+
+func unset_command(args []string) int {
+	for _, name := range args {
+		delete(shell_vars, name)
+	}
+	return 0
+}
+
+func vars_command() int {
+	names := maps.Keys(shell_vars)
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Printf("%s=%s\n", formatter.Green(name), shell_vars[name])
+	}
+	return 0
+}
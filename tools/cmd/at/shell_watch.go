@@ -0,0 +1,163 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+const watch_help = "Run a command repeatedly, showing its output. Usage: watch interval command [args...]"
+
+type watch_result struct {
+	output    string
+	exit_code int
+	ran_at    time.Time
+}
+
+func run_watched_command(parsed_cmdline []string) watch_result {
+	buf := bytes.Buffer{}
+	ans := watch_result{ran_at: time.Now()}
+	exit_code, err := run_at_command(parsed_cmdline, &buf, &buf)
+	if err != nil && buf.Len() == 0 {
+		buf.WriteString(err.Error())
+	}
+	ans.exit_code = exit_code
+	ans.output = buf.String()
+	return ans
+}
+
+// The lines of the watch screen, a header with the command and, at its right
+// edge, the time the command was run, followed by as much of the output as
+// fits. The last line of the screen is left empty so that it does not scroll.
+func watch_screen_lines(width, height int, interval time.Duration, cmdline string, res watch_result) []string {
+	width, height = utils.Max(1, width), utils.Max(2, height)
+	header := fmt.Sprintf("Every %s: %s", interval, cmdline)
+	status := res.ran_at.Format("15:04:05")
+	if res.exit_code != 0 {
+		status = formatter.BrightRed(fmt.Sprintf("[%d]", res.exit_code)) + " " + status
+	}
+	header_line := status
+	if available := width - wcswidth.Stringwidth(status) - 1; available > 0 {
+		header = wcswidth.TruncateToVisualLength(header, available)
+		header_line = formatter.Title(header) + strings.Repeat(" ", width-wcswidth.Stringwidth(header)-wcswidth.Stringwidth(status)) + status
+	}
+	lines := utils.Splitlines(strings.TrimRight(res.output, "\n"))
+	if len(lines) > height-2 {
+		lines = lines[:height-2]
+	}
+	ans := []string{header_line}
+	for _, line := range lines {
+		ans = append(ans, wcswidth.TruncateToVisualLength(line, width))
+	}
+	return ans
+}
+
+func draw_watch_screen(lp *loop.Loop, interval time.Duration, cmdline string, res watch_result) {
+	sz, err := lp.ScreenSize()
+	if err != nil {
+		sz.WidthCells, sz.HeightCells = 80, 24
+	}
+	lp.StartAtomicUpdate()
+	defer lp.EndAtomicUpdate()
+	lp.ClearScreen()
+	lp.QueueWriteString(strings.Join(watch_screen_lines(int(sz.WidthCells), int(sz.HeightCells), interval, cmdline, res), "\r\n"))
+}
+
+// Show the output and wait for either the interval to expire or the user to
+// quit. Returns false if the user asked to stop watching.
+func watch_loop(interval time.Duration, cmdline string, res watch_result) (bool, error) {
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors)
+	if err != nil {
+		return false, err
+	}
+	lp.OnInitialize = func() (string, error) {
+		lp.SetCursorVisible(false)
+		draw_watch_screen(lp, interval, cmdline, res)
+		_, err := lp.AddTimer(interval, false, func(loop.IdType) error {
+			lp.Quit(0)
+			return nil
+		})
+		return "", err
+	}
+	lp.OnFinalize = func() string {
+		lp.SetCursorVisible(true)
+		return ""
+	}
+	lp.OnResize = func(old_size, new_size loop.ScreenSize) error {
+		draw_watch_screen(lp, interval, cmdline, res)
+		return nil
+	}
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		if event.MatchesPressOrRepeat("q") || event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
+			event.Handled = true
+			lp.Quit(1)
+		}
+		return nil
+	}
+	err = lp.Run()
+	if err != nil {
+		return false, err
+	}
+	if ds := lp.DeathSignalName(); ds != "" {
+		return false, fmt.Errorf("Killed by signal: %s", ds)
+	}
+	return lp.ExitCode() == 0, nil
+}
+
+// Convert the interval, in seconds, to a duration, rejecting intervals that
+// are not positive, such as NaN, or too large for a duration
+func parse_watch_interval(arg string) (time.Duration, error) {
+	secs, err := strconv.ParseFloat(arg, 64)
+	nanosecs := secs * float64(time.Second)
+	if err != nil || !(nanosecs >= 1) || nanosecs > math.MaxInt64 {
+		return 0, fmt.Errorf("The interval for watch must be a positive number of seconds, not: %s", formatter.BrightRed(arg))
+	}
+	return time.Duration(nanosecs), nil
+}
+
+func watch_command(at_root_command *cli.Command, args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, watch_help)
+		return 1
+	}
+	interval, err := parse_watch_interval(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if at_root_command.FindSubCommand(args[1]) == nil {
+		report_unknown_command(at_root_command, args[1])
+		return 1
+	}
+	cmdline := strings.Join(args[1:], " ")
+	os.Stdout.WriteString(loop.ALTERNATE_SCREEN.EscapeCodeToSet())
+	var res watch_result
+	for {
+		// the command is run outside the loop as it needs exclusive access to the tty to talk to kitty
+		res = run_watched_command(args[1:])
+		keep_going, err := watch_loop(interval, cmdline, res)
+		if err != nil {
+			os.Stdout.WriteString(loop.ALTERNATE_SCREEN.EscapeCodeToReset())
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !keep_going {
+			break
+		}
+	}
+	os.Stdout.WriteString(loop.ALTERNATE_SCREEN.EscapeCodeToReset())
+	return res.exit_code
+}
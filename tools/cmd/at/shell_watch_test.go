@@ -0,0 +1,64 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestWatchArgs(t *testing.T) {
+	formatter = markup.New(false)
+	for arg, expected := range map[string]time.Duration{"1": time.Second, "0.5": 500 * time.Millisecond, "2.25": 2250 * time.Millisecond} {
+		if actual, err := parse_watch_interval(arg); err != nil || actual != expected {
+			t.Fatalf("Unexpected interval for %#v: %s %v", arg, actual, err)
+		}
+	}
+	for _, arg := range []string{"", "x", "0", "-1", "1e-12", "NaN", "inf", "1e300"} {
+		if _, err := parse_watch_interval(arg); err == nil {
+			t.Fatalf("Invalid interval not rejected: %#v", arg)
+		}
+	}
+
+	root := cli.NewRootCommand()
+	EntryPoint(root)
+	at_root_command := root.FindSubCommand("@")
+	for _, args := range [][]string{nil, {"1"}, {"ls"}, {"x", "ls"}, {"0", "ls"}, {"1", "no-such-command"}} {
+		if watch_command(at_root_command, args) != 1 {
+			t.Fatalf("Invalid arguments not rejected: %#v", args)
+		}
+	}
+}
+
+func TestWatchScreen(t *testing.T) {
+	formatter = markup.New(false)
+	ran_at := time.Date(2022, 1, 2, 13, 14, 15, 0, time.Local)
+	test := func(width, height int, res watch_result, expected ...string) {
+		t.Helper()
+		res.ran_at = ran_at
+		if diff := cmp.Diff(expected, watch_screen_lines(width, height, 2*time.Second, "ls --self", res)); diff != "" {
+			t.Fatalf("Unexpected screen for a %dx%d screen:\n%s", width, height, diff)
+		}
+	}
+
+	test(30, 5, watch_result{output: "one\ntwo\n"},
+		"Every 2s: ls --self   13:14:15", "one", "two")
+	// the header is truncated to leave room for the time, which is always shown
+	test(20, 5, watch_result{output: "one"}, "Every 2s: l 13:14:15", "one")
+	test(8, 5, watch_result{}, "13:14:15")
+	test(35, 5, watch_result{output: "failed", exit_code: 3},
+		"Every 2s: ls --self    [3] 13:14:15", "failed")
+	// output is truncated to fit the screen, leaving the last line empty
+	test(30, 4, watch_result{output: "1\n2\n3\n4\n5\n"}, "Every 2s: ls --self   13:14:15", "1", "2")
+	test(30, 5, watch_result{output: strings.Repeat("x", 40) + "\n世界世界世界世界世界世界世界世界"},
+		"Every 2s: ls --self   13:14:15", strings.Repeat("x", 30), "世界世界世界世界世界世界世界世界"[:15*3])
+}
@@ -10,6 +10,7 @@ import (
 	"net"
 	"time"
 
+	"kitty/tools/cli"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
 	"kitty/tools/wcswidth"
@@ -153,6 +154,8 @@ func simple_socket_io(conn *net.Conn, io_data *rc_io_data) (serialized_response
 func do_socket_io(io_data *rc_io_data) (serialized_response []byte, err error) {
 	conn, err := net.Dial(global_options.to_network, global_options.to_address)
 	if err != nil {
+		err = cli.NewExitError(cli.ExitCodeConnection, err).WithHint(
+			"Make sure kitty is running with remote control enabled and listening on the address specified with --to, see :opt:`listen_on`")
 		return
 	}
 	defer conn.Close()
@@ -83,9 +83,11 @@ func run_CMD_NAME(cmd *cli.Command, args []string) (return_code int, err error)
 		rc:                     rc,
 		timeout:                time.Duration(timeout * float64(time.Second)),
 		string_response_is_err: STRING_RESPONSE_IS_ERROR,
+		response_handler:       RESPONSE_HANDLER,
 	}
 	err = create_payload_CMD_NAME(&io_data, cmd, args)
 	if err != nil {
+		err = usage_error(err)
 		return
 	}
 
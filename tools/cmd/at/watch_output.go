@@ -0,0 +1,68 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package at
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils/shlex"
+)
+
+var _ = fmt.Print
+
+type watch_output_match struct {
+	WindowId int    `json:"window_id"`
+	Line     string `json:"line"`
+}
+
+type watch_output_response struct {
+	NumWindows int                  `json:"num_windows"`
+	Matches    []watch_output_match `json:"matches"`
+}
+
+func run_for_watch_output_match(argv []string, m watch_output_match) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(m.Line + "\n")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "KITTY_MATCHED_WINDOW_ID="+strconv.Itoa(m.WindowId))
+	if err := cmd.Run(); err != nil {
+		if _, is_exit_err := err.(*exec.ExitError); !is_exit_err {
+			return fmt.Errorf("Failed to run the command: %s with error: %w", argv[0], err)
+		}
+	}
+	return nil
+}
+
+// Prints the matches and asks for the command to be repeated, so that
+// matches are reported until the user interrupts
+func handle_watch_output_response(response string) error {
+	var r watch_output_response
+	if err := json.Unmarshal([]byte(response), &r); err != nil {
+		return fmt.Errorf("Invalid response from kitty with error: %w", err)
+	}
+	var argv []string
+	if options_watch_output.Exec != "" {
+		var err error
+		if argv, err = shlex.Split(options_watch_output.Exec); err != nil || len(argv) == 0 {
+			return fmt.Errorf("The command to execute is invalid: %#v", options_watch_output.Exec)
+		}
+	}
+	for _, m := range r.Matches {
+		if r.NumWindows > 1 {
+			fmt.Printf("%d: %s\n", m.WindowId, m.Line)
+		} else {
+			fmt.Println(m.Line)
+		}
+		if argv != nil {
+			if err := run_for_watch_output_match(argv, m); err != nil {
+				return err
+			}
+		}
+	}
+	return repeat_command
+}
@@ -0,0 +1,76 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+// Set in the environment of the background process that clears the clipboard
+const clear_helper_env_var = "KITTY_CLIPBOARD_CLEAR_HELPER"
+
+// The command line for the background process that clears the clipboard
+func clear_helper_args(opts *Options) []string {
+	args := []string{"kitten", "clipboard", "--clear-after", strconv.Itoa(opts.ClearAfter), "--passthrough", opts.Passthrough}
+	if opts.UsePrimary {
+		args = append(args, "--use-primary")
+	}
+	return args
+}
+
+// Start a background process that clears the clipboard after opts.ClearAfter
+// seconds. It is placed in its own process group so that it is not killed
+// when the user presses Ctrl+C in the shell.
+func schedule_clear(opts *Options) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Failed to find the kitten executable to clear the clipboard with error: %w", err)
+	}
+	cmd := exec.Cmd{Path: exe, Args: clear_helper_args(opts), Env: append(os.Environ(), clear_helper_env_var+"=1"), SysProcAttr: &syscall.SysProcAttr{Setpgid: true}}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("Failed to start the process to clear the clipboard with error: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// Runs in the background process, waits and then overwrites the clipboard
+// with empty data. The clipboard is cleared even if something else has been
+// copied to it in the meantime, since reading it to check would need the
+// terminal, and possibly the user, to allow it.
+func run_clear_helper(opts *Options) error {
+	time.Sleep(time.Duration(opts.ClearAfter) * time.Second)
+	term, err := tty.OpenControllingTerm()
+	if err != nil {
+		// the terminal has been closed
+		return nil
+	}
+	defer term.Close()
+	return term.WriteAll([]byte(encode_clear_clipboard(opts)))
+}
+
+// Overwriting the clipboard with no data empties it
+func encode_clear_clipboard(opts *Options) string {
+	dest := "c"
+	if opts.UsePrimary {
+		dest = "p"
+	}
+	return multiplexer_for_passthrough(opts).wrap(fmt.Sprintf("\x1b]52;%s;\x1b\\", dest))
+}
+
+func run_clear(opts *Options) error {
+	term, err := tty.OpenControllingTerm()
+	if err != nil {
+		return fmt.Errorf("Failed to open the terminal to clear the clipboard with error: %w", err)
+	}
+	defer term.Close()
+	return term.WriteAll([]byte(encode_clear_clipboard(opts)))
+}
@@ -0,0 +1,46 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestClearClipboard(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("STY", "")
+	for _, x := range []struct {
+		passthrough string
+		use_primary bool
+		expected    string
+	}{
+		{"auto", false, "\x1b]52;c;\x1b\\"},
+		{"none", true, "\x1b]52;p;\x1b\\"},
+		{"tmux", false, "\x1bPtmux;\x1b\x1b]52;c;\x1b\x1b\\\x1b\\"},
+		{"screen", true, "\x1bP\x1b]52;p;\a\x1b\\"},
+	} {
+		opts := &Options{Passthrough: x.passthrough, UsePrimary: x.use_primary}
+		if actual := encode_clear_clipboard(opts); actual != x.expected {
+			t.Fatalf("Incorrect escape code to clear the clipboard with passthrough: %s and use_primary: %v\n%#v != %#v", x.passthrough, x.use_primary, x.expected, actual)
+		}
+	}
+
+	opts := &Options{ClearAfter: 30, Passthrough: "tmux"}
+	if diff := cmp.Diff([]string{"kitten", "clipboard", "--clear-after", "30", "--passthrough", "tmux"}, clear_helper_args(opts)); diff != "" {
+		t.Fatalf("Unexpected arguments for the background process:\n%s", diff)
+	}
+	opts.UsePrimary = true
+	if diff := cmp.Diff([]string{"kitten", "clipboard", "--clear-after", "30", "--passthrough", "tmux", "--use-primary"}, clear_helper_args(opts)); diff != "" {
+		t.Fatalf("Unexpected arguments for the background process:\n%s", diff)
+	}
+
+	rc, err := clipboard_main(nil, &Options{ClearAfter: -1}, nil)
+	if err == nil || rc == 0 {
+		t.Fatalf("A negative value for --clear-after was not rejected")
+	}
+}
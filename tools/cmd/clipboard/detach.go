@@ -0,0 +1,147 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Set in the environment of the background process that copies the text
+const detach_helper_env_var = "KITTY_CLIPBOARD_DETACH_HELPER"
+
+// --detach is only supported when copying text from STDIN in filter mode, as
+// the other modes need a response from the terminal
+func check_detach_usage(opts *Options, args []string, stdin_is_tty bool) error {
+	usage_error := func(msg string) error {
+		return &cli.ExitError{Code: cli.ExitCodeUsage, Message: msg}
+	}
+	switch {
+	case opts.Sensitive:
+		return usage_error("Only one of --detach and --sensitive can be used")
+	case len(args) > 0 || opts.GetClipboard || opts.GetImage != "" || opts.SetImage != "" || opts.HistoryGet > -1 || opts.HistoryList ||
+		opts.Watch || opts.ListTypes || opts.Clear || opts.SyncToPrimary || opts.SyncFromPrimary:
+		return usage_error("--detach can only be used when copying text from STDIN in filter mode")
+	case len(opts.Mime) > 0 && base_mime_type(opts.Mime[0]) != "text/plain":
+		return usage_error(fmt.Sprintf("--detach can only be used to copy text, not data of type %s", opts.Mime[0]))
+	case stdin_is_tty:
+		return usage_error("--detach cannot be used when STDIN is a terminal, pipe in the text to copy instead")
+	}
+	return nil
+}
+
+// The command line for the background process that copies the text
+func detach_helper_args(opts *Options) []string {
+	args := []string{"kitten", "clipboard", "--detach", "--passthrough", opts.Passthrough}
+	if opts.UsePrimary {
+		args = append(args, "--use-primary")
+	}
+	if opts.TmuxBuffer {
+		args = append(args, "--tmux-buffer")
+	}
+	if opts.AddToHistory {
+		args = append(args, "--add-to-history")
+	}
+	if opts.ClearAfter > 0 {
+		// the clipboard is cleared by this process, as usual, the helper
+		// only needs to know not to add the text to the history
+		args = append(args, "--clear-after", strconv.Itoa(opts.ClearAfter))
+	}
+	return args
+}
+
+// Process the text on STDIN and hand it to a background process that sends it
+// to the terminal, so that the kitten returns without waiting for the
+// terminal, the history file or tmux. The text is passed in an unlinked
+// temporary file, so the kitten never blocks on a pipe.
+func run_detached(opts *Options, src io.Reader) (err error) {
+	if transforms_requested(opts) {
+		if src, err = transform_input(opts, src); err != nil {
+			return err
+		}
+	}
+	if opts.MaxSize != "" {
+		if src, err = limit_size(opts, src); err != nil {
+			return err
+		}
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("Failed to read from STDIN with error: %w", err)
+	}
+	if opts.ClearAfter == 0 {
+		warn_if_secret(opts, utils.UnsafeBytesToString(data))
+	}
+	f, err := os.CreateTemp("", "kitty-clipboard-*")
+	if err != nil {
+		return fmt.Errorf("Failed to create a temporary file for the text to copy with error: %w", err)
+	}
+	defer f.Close()
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		return fmt.Errorf("Failed to write the text to copy to a temporary file with error: %w", err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Failed to find the kitten executable to copy in the background with error: %w", err)
+	}
+	// in its own process group so that it is not killed when the user
+	// presses Ctrl+C in the shell
+	cmd := exec.Cmd{Path: exe, Args: detach_helper_args(opts), Stdin: f, Env: append(os.Environ(), detach_helper_env_var+"=1"), SysProcAttr: &syscall.SysProcAttr{Setpgid: true}}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("Failed to start the process to copy in the background with error: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// Runs in the background process. It is not in the foreground process group
+// of the terminal, so it only writes to the terminal, without changing its
+// mode or waiting for a response.
+func run_detach_helper(opts *Options) error {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	text := utils.UnsafeBytesToString(data)
+	term, err := tty.OpenControllingTerm()
+	if err != nil {
+		// the terminal has been closed
+		return nil
+	}
+	dest := "c"
+	if opts.UsePrimary {
+		dest = "p"
+	}
+	err = term.WriteAll([]byte(multiplexer_for_passthrough(opts).wrap(fmt.Sprintf("\x1b]52;%s;%s\x1b\\", dest, base64.StdEncoding.EncodeToString(data)))))
+	term.Close()
+	if err != nil {
+		return err
+	}
+	after_detached_copy(opts, text)
+	return nil
+}
+
+// Update the history and tmux buffer once the text has been sent to the
+// terminal. AddToHistory never records secrets.
+func after_detached_copy(opts *Options, text string) {
+	if opts.AddToHistory && !opts.UsePrimary && opts.ClearAfter == 0 {
+		AddToHistory(text, "text/plain")
+	}
+	if opts.TmuxBuffer && in_tmux() && !opts.UsePrimary {
+		copy_to_tmux_buffer(text)
+	}
+}
@@ -0,0 +1,128 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"kitty/tools/cli"
+	"kitty/tools/tty"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// How long to wait for the terminal to respond to the query for support for
+// the MIME aware clipboard protocol
+const detection_timeout = 5 * time.Second
+
+// Detect whether the terminal supports the MIME aware clipboard protocol, by
+// querying its name with XTGETTCAP, followed by a primary device attributes
+// query that all terminals respond to
+func detect_mime_support() (supported bool, err error) {
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return
+	}
+	lp.OnInitialize = func() (string, error) {
+		lp.AddTimer(detection_timeout, false, func(loop.IdType) error {
+			lp.Quit(0)
+			return nil
+		})
+		lp.QueueWriteString("\x1bP+q544e\x1b\\\x1b[c")
+		return "", nil
+	}
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, data []byte) error {
+		switch etype {
+		case loop.DCS:
+			if _, val, found := utils.Cut(utils.UnsafeBytesToString(data), "1+r544e="); found {
+				if name, err := hex.DecodeString(val); err == nil {
+					supported = strings.HasPrefix(string(name), "xterm-kitty")
+				}
+			}
+		case loop.CSI:
+			if len(data) > 3 && data[0] == '?' && data[len(data)-1] == 'c' {
+				lp.Quit(0)
+			}
+		}
+		return nil
+	}
+	err = lp.Run()
+	if err == nil {
+		if ds := lp.DeathSignalName(); ds != "" {
+			fmt.Println("Killed by signal: ", ds)
+			lp.KillIfSignalled()
+		}
+	}
+	return
+}
+
+func base_mime_type(mime string) string {
+	mime, _, _ = utils.Cut(mime, ";")
+	return strings.ToLower(strings.TrimSpace(mime))
+}
+
+// The MIME type to use for data on STDIN in filter mode, either specified
+// with --mime or detected from its contents
+func mime_type_for_stdin(opts *Options, src *bufio.Reader) string {
+	if len(opts.Mime) > 0 {
+		return opts.Mime[0]
+	}
+	head, _ := src.Peek(512)
+	return base_mime_type(http.DetectContentType(head))
+}
+
+// Copy data from STDIN to the clipboard, or from the clipboard to STDOUT. Plain
+// text is transferred using OSC 52, which is widely supported, other types of
+// data use the MIME aware clipboard protocol when the terminal supports it.
+func run_filter_mode(opts *Options) error {
+	if opts.GetClipboard {
+		if len(opts.Mime) == 0 || base_mime_type(opts.Mime[0]) == "text/plain" {
+			return run_plain_text_loop(opts)
+		}
+		supported, err := detect_mime_support()
+		if err != nil {
+			return err
+		}
+		if !supported {
+			return fmt.Errorf("This terminal does not support reading data of type %s from the clipboard, only plain text", opts.Mime[0])
+		}
+		return run_get_loop(opts, []string{"/dev/stdout"})
+	}
+	if tty.IsTerminal(os.Stdin.Fd()) {
+		return run_plain_text_loop(opts)
+	}
+	src := bufio.NewReader(os.Stdin)
+	if opts.Sensitive {
+		return run_sensitive_copy(opts, src)
+	}
+	mime := mime_type_for_stdin(opts, src)
+	if opts.Detach {
+		// other types of text are copied as plain text
+		if !strings.HasPrefix(mime, "text/") {
+			return &cli.ExitError{Code: cli.ExitCodeUsage, Message: fmt.Sprintf("--detach can only be used to copy text, STDIN contains data of type %s", mime)}
+		}
+		return run_detached(opts, src)
+	}
+	if mime != "text/plain" {
+		supported, err := detect_mime_support()
+		if err != nil {
+			return err
+		}
+		if supported {
+			return write_loop([]*Input{{src: src, arg: "/dev/stdin", is_stream: true, mime_type: mime}}, opts)
+		}
+		if !strings.HasPrefix(mime, "text/") {
+			fmt.Fprintf(os.Stderr, "This terminal does not support copying data of type %s to the clipboard, copying it as plain text instead\n", mime)
+		}
+	}
+	return plain_text_loop(opts, io.Reader(src), false)
+}
@@ -0,0 +1,149 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const max_history_items = 128
+const max_history_item_size = 1024 * 1024
+
+type HistoryItem struct {
+	Text      string    `json:"text"`
+	Mime      string    `json:"mime"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type history_list_entry struct {
+	Index int `json:"index"`
+	HistoryItem
+}
+
+// The history is opt-in, see --add-to-history, and is stored in the kitty
+// cache directory
+func history_path() string {
+	return filepath.Join(utils.CacheDir(), "clipboard-history.json")
+}
+
+// Run the specified function with the history items, holding an exclusive lock
+// on the history file. If the function returns true the modified items are written
+// back to the file.
+func with_history(callback func(items []HistoryItem) ([]HistoryItem, bool)) (err error) {
+	path := history_path()
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("Failed to open clipboard history file at: %s with error: %w", path, err)
+	}
+	defer f.Close()
+	if err = utils.LockFileExclusive(f); err != nil {
+		return
+	}
+	defer utils.UnlockFile(f)
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return
+	}
+	items := []HistoryItem{}
+	if len(data) > 0 {
+		if jerr := json.Unmarshal(data, &items); jerr != nil {
+			items = []HistoryItem{}
+		}
+	}
+	items, changed := callback(items)
+	if !changed {
+		return
+	}
+	ndata, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = f.Truncate(0); err != nil {
+		return
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	_, err = f.Write(ndata)
+	return
+}
+
+// Add text to the history, moving it to the top if it is already present.
+// The history is stored most recent item first. Text that looks like a secret
+// is never added.
+func AddToHistory(text, mime string) error {
+	if text == "" || len(text) > max_history_item_size || looks_like_secret(text) {
+		return nil
+	}
+	return with_history(func(items []HistoryItem) ([]HistoryItem, bool) {
+		items = utils.Filter(items, func(x HistoryItem) bool { return x.Text != text || x.Mime != mime })
+		items = append([]HistoryItem{{Text: text, Mime: mime, Timestamp: time.Now()}}, items...)
+		if len(items) > max_history_items {
+			items = items[:max_history_items]
+		}
+		return items, true
+	})
+}
+
+func ReadHistory() (ans []HistoryItem, err error) {
+	err = with_history(func(items []HistoryItem) ([]HistoryItem, bool) {
+		ans = items
+		return items, false
+	})
+	return
+}
+
+func history_item(idx int) (ans HistoryItem, err error) {
+	items, err := ReadHistory()
+	if err != nil {
+		return
+	}
+	if idx < 0 || idx >= len(items) {
+		if len(items) == 0 {
+			return ans, fmt.Errorf("The clipboard history is empty")
+		}
+		return ans, fmt.Errorf("%d is not a valid clipboard history index, must be between 0 and %d", idx, len(items)-1)
+	}
+	return items[idx], nil
+}
+
+func print_history_list() error {
+	items, err := ReadHistory()
+	if err != nil {
+		return err
+	}
+	entries := make([]history_list_entry, len(items))
+	for i, x := range items {
+		entries[i] = history_list_entry{Index: i, HistoryItem: x}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	if err == nil {
+		fmt.Println()
+	}
+	return err
+}
+
+func copy_history_item(opts *Options, idx int) error {
+	item, err := history_item(idx)
+	if err != nil {
+		return err
+	}
+	return plain_text_loop(opts, strings.NewReader(item.Text), false)
+}
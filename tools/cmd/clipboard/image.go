@@ -0,0 +1,70 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kitty/tools/cli"
+	"kitty/tools/utils"
+	"kitty/tools/utils/images"
+)
+
+var _ = fmt.Print
+
+// Save an image from the clipboard to path, in the format specified by its
+// extension, PNG by default. Images of other types on the clipboard are
+// converted as needed.
+func run_get_image(opts *Options, path string) (err error) {
+	if cwd, err = os.Getwd(); err != nil {
+		return err
+	}
+	mime := "image/png"
+	if path != "/dev/stdout" && path != "-" {
+		if q := utils.GuessMimeType(path); q != "" {
+			mime = q
+		}
+	} else {
+		path = "/dev/stdout"
+	}
+	if !images.EncodableImageTypes[mime] {
+		return &cli.ExitError{Code: cli.ExitCodeUsage, Message: fmt.Sprintf("Cannot save clipboard images as %s, use a file name with an extension such as .png or .jpg", mime)}
+	}
+	opts.Mime = []string{mime}
+	return run_get_loop(opts, []string{path})
+}
+
+// Copy the image in path to the clipboard, detecting its type from its
+// contents if the file name does not identify it
+func run_set_image(opts *Options, path string) error {
+	var src io.Reader = os.Stdin
+	is_stream := path == "-" || path == "/dev/stdin"
+	if !is_stream {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Failed to open %s with error: %w", path, err)
+		}
+		defer f.Close()
+		src = f
+	}
+	r := bufio.NewReader(src)
+	mime := ""
+	if len(opts.Mime) > 0 {
+		mime = opts.Mime[0]
+	} else if q := utils.GuessMimeType(path); strings.HasPrefix(q, "image/") {
+		mime = q
+	} else {
+		head, _ := r.Peek(512)
+		mime = base_mime_type(http.DetectContentType(head))
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return fmt.Errorf("%s is not a recognized image, use --mime to specify its type", path)
+	}
+	return write_loop([]*Input{{src: r, arg: path, ext: filepath.Ext(path), is_stream: is_stream, mime_type: mime}}, opts)
+}
@@ -3,22 +3,24 @@
 package clipboard
 
 import (
+	"bytes"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 
+	"kitty/tools/cli"
 	"kitty/tools/tty"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
+	"kitty/tools/utils/humanize"
 )
 
 var _ = fmt.Print
 
-var _ = fmt.Print
-
 func encode_read_from_clipboard(use_primary bool) string {
 	dest := "c"
 	if use_primary {
@@ -27,6 +29,17 @@ func encode_read_from_clipboard(use_primary bool) string {
 	return fmt.Sprintf("\x1b]52;%s;?\x1b\\", dest)
 }
 
+// The escape code to set the clipboard to the specified text, passed through
+// to the terminal if running inside a terminal multiplexer
+func EncodeSetClipboard(text string, use_primary bool) string {
+	dest := "c"
+	if use_primary {
+		dest = "p"
+	}
+	mux := multiplexer_for_passthrough(&Options{Passthrough: "auto"})
+	return mux.wrap(fmt.Sprintf("\x1b]52;%s;%s\x1b\\", dest, base64.StdEncoding.EncodeToString(utils.UnsafeStringToBytes(text))))
+}
+
 type base64_streaming_enc struct {
 	output func(string)
 }
@@ -39,6 +52,51 @@ func (self *base64_streaming_enc) Write(p []byte) (int, error) {
 }
 
 func run_plain_text_loop(opts *Options) (err error) {
+	return plain_text_loop(opts, os.Stdin, tty.IsTerminal(os.Stdin.Fd()))
+}
+
+// Read all the data from src if it is no larger than --max-size, so that data
+// that is too large is never sent to the terminal
+func limit_size(opts *Options, src io.Reader) (io.Reader, error) {
+	limit, err := humanize.ParseBytes(opts.MaxSize)
+	if err != nil {
+		return nil, cli.NewExitError(cli.ExitCodeUsage, fmt.Errorf("Invalid value for --max-size: %#v with error: %w", opts.MaxSize, err))
+	}
+	if limit == 0 {
+		return src, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(src, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from STDIN with error: %w", err)
+	}
+	if uint64(len(data)) > limit {
+		if !opts.Truncate {
+			return nil, fmt.Errorf("The data to copy is larger than the maximum size of %s, use --truncate to copy only part of it", humanize.IBytes(limit))
+		}
+		data = data[:limit]
+		// dont split multi-byte UTF-8 characters
+		for len(data) > 0 && !utf8.RuneStart(data[len(data)-1]) {
+			data = data[:len(data)-1]
+		}
+		if len(data) > 0 && !utf8.FullRune(data[len(data)-1:]) {
+			data = data[:len(data)-1]
+		}
+		fmt.Fprintf(os.Stderr, "Warning: the data to copy was truncated to the maximum size of %s\n", humanize.IBytes(limit))
+	}
+	return bytes.NewReader(data), nil
+}
+
+func plain_text_loop(opts *Options, src io.Reader, stdin_is_tty bool) (err error) {
+	if !stdin_is_tty && !opts.GetClipboard && transforms_requested(opts) {
+		if src, err = transform_input(opts, src); err != nil {
+			return err
+		}
+	}
+	if !stdin_is_tty && !opts.GetClipboard && opts.MaxSize != "" {
+		if src, err = limit_size(opts, src); err != nil {
+			return err
+		}
+	}
 	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
 	if err != nil {
 		return
@@ -47,38 +105,55 @@ func run_plain_text_loop(opts *Options) (err error) {
 	if opts.UsePrimary {
 		dest = "p"
 	}
-	stdin_is_tty := tty.IsTerminal(os.Stdin.Fd())
 	var buf [8192]byte
+	var copied_text strings.Builder
+	// the full text, not limited to the maximum size of history items
+	var tmux_text *strings.Builder
+	if opts.TmuxBuffer && in_tmux() && !opts.GetClipboard && !opts.UsePrimary && !stdin_is_tty {
+		tmux_text = &strings.Builder{}
+	}
 
+	mux := multiplexer_for_passthrough(opts)
 	send_to_loop := func(data string) {
-		lp.QueueWriteString(data)
+		lp.QueueWriteString(mux.wrap(data))
 	}
 	enc := base64.NewEncoder(base64.StdEncoding, &base64_streaming_enc{send_to_loop})
 	transmitting := true
 
-	after_read_from_stdin := func() {
+	waiter := new_response_waiter(lp, opts)
+
+	after_read_from_stdin := func() error {
 		transmitting = false
 		if opts.GetClipboard {
-			lp.QueueWriteString(encode_read_from_clipboard(opts.UsePrimary))
+			send_to_loop(encode_read_from_clipboard(opts.UsePrimary))
 		} else if opts.WaitForCompletion {
-			lp.QueueWriteString("\x1bP+q544e\x1b\\")
+			send_to_loop("\x1bP+q544e\x1b\\")
 		} else {
 			lp.Quit(0)
+			return nil
 		}
+		return waiter.start()
 	}
 
 	read_from_stdin := func() error {
-		n, err := os.Stdin.Read(buf[:])
+		n, err := src.Read(buf[:])
 		if n > 0 {
 			enc.Write(buf[:n])
+			if copied_text.Len() <= max_history_item_size {
+				copied_text.Write(buf[:n])
+			}
+			if tmux_text != nil {
+				tmux_text.Write(buf[:n])
+			}
 		}
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				enc.Close()
 				send_to_loop("\x1b\\")
-				os.Stdin.Close()
-				after_read_from_stdin()
-				return nil
+				if c, ok := src.(io.Closer); ok {
+					c.Close()
+				}
+				return after_read_from_stdin()
 			}
 			return fmt.Errorf("Failed to read from STDIN with error: %w", err)
 		}
@@ -93,11 +168,9 @@ func run_plain_text_loop(opts *Options) (err error) {
 	lp.OnInitialize = func() (string, error) {
 		if !stdin_is_tty {
 			send_to_loop(fmt.Sprintf("\x1b]52;%s;", dest))
-			read_from_stdin()
-		} else {
-			after_read_from_stdin()
+			return "", read_from_stdin()
 		}
-		return "", nil
+		return "", after_read_from_stdin()
 	}
 
 	var clipboard_contents []byte
@@ -127,25 +200,11 @@ func run_plain_text_loop(opts *Options) (err error) {
 		return
 	}
 
-	esc_count := 0
 	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
-		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
-			if transmitting {
-				return nil
-			}
-			event.Handled = true
-			esc_count++
-			if esc_count < 2 {
-				key := "Esc"
-				if event.MatchesPressOrRepeat("ctrl+c") {
-					key = "Ctrl+C"
-				}
-				lp.QueueWriteString(fmt.Sprintf("Waiting for response from terminal, press %s again to abort. This could cause garbage to be spewed to the screen.\r\n", key))
-			} else {
-				return fmt.Errorf("Aborted by user!")
-			}
+		if transmitting {
+			return nil
 		}
-		return nil
+		return waiter.on_key_event(event)
 	}
 
 	err = lp.Run()
@@ -158,7 +217,25 @@ func run_plain_text_loop(opts *Options) (err error) {
 		lp.KillIfSignalled()
 		return
 	}
+	if !stdin_is_tty && !opts.GetClipboard && opts.ClearAfter == 0 {
+		warn_if_secret(opts, copied_text.String())
+	}
+	// text that will be cleared from the clipboard is likely a secret, so it
+	// is not remembered
+	if opts.AddToHistory && !stdin_is_tty && !opts.GetClipboard && !opts.UsePrimary && opts.ClearAfter == 0 && !opts.Sensitive {
+		if herr := AddToHistory(copied_text.String(), "text/plain"); herr != nil {
+			fmt.Fprintln(os.Stderr, "Failed to add copied text to the clipboard history with error:", herr)
+		}
+	}
+	if tmux_text != nil && tmux_text.Len() > 0 {
+		if terr := copy_to_tmux_buffer(tmux_text.String()); terr != nil {
+			fmt.Fprintln(os.Stderr, terr)
+		}
+	}
 	if len(clipboard_contents) > 0 {
+		if transforms_requested(opts) {
+			clipboard_contents = []byte(transform_text(opts, utils.UnsafeBytesToString(clipboard_contents)))
+		}
 		_, err = os.Stdout.Write(clipboard_contents)
 		if err != nil {
 			err = fmt.Errorf("Failed to write to STDOUT with error: %w", err)
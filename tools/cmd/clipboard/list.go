@@ -0,0 +1,90 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Query the terminal for the MIME types currently available on the clipboard
+func available_mime_types(opts *Options) (ans []string, err error) {
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{"type": "read"}
+	if opts.UsePrimary {
+		metadata["loc"] = "primary"
+	}
+
+	waiter := new_response_waiter(lp, opts)
+
+	lp.OnInitialize = func() (string, error) {
+		lp.QueueWriteString(encode(metadata, "."))
+		return "", waiter.start()
+	}
+
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, data []byte) error {
+		metadata, payload, err := parse_escape_code(etype, data)
+		if err != nil || metadata == nil {
+			return err
+		}
+		switch metadata["status"] {
+		case "DATA":
+			ans = append(ans, strings.Fields(utils.UnsafeBytesToString(payload))...)
+		case "OK":
+		case "DONE":
+			lp.Quit(0)
+		default:
+			return fmt.Errorf("Failed to read list of available data types in the clipboard with error: %w", error_from_status(metadata["status"]))
+		}
+		return nil
+	}
+
+	lp.OnKeyEvent = waiter.on_key_event
+
+	err = lp.Run()
+	if err != nil {
+		return nil, err
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return nil, fmt.Errorf("Killed by signal: %s", ds)
+	}
+	return ans, nil
+}
+
+func print_available_mime_types(opts *Options) error {
+	types, err := available_mime_types(opts)
+	if err != nil {
+		return err
+	}
+	if !opts.Json {
+		for _, t := range types {
+			fmt.Println(t)
+		}
+		return nil
+	}
+	if types == nil {
+		types = []string{}
+	}
+	data, err := json.MarshalIndent(types, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	if err == nil {
+		fmt.Println()
+	}
+	return err
+}
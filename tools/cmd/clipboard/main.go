@@ -3,11 +3,15 @@
 package clipboard
 
 import (
+	"fmt"
 	"os"
 
 	"kitty/tools/cli"
+	"kitty/tools/tty"
 )
 
+var _ = fmt.Print
+
 func run_mime_loop(opts *Options, args []string) (err error) {
 	cwd, err = os.Getwd()
 	if err != nil {
@@ -19,12 +23,62 @@ func run_mime_loop(opts *Options, args []string) (err error) {
 	return run_set_loop(opts, args)
 }
 
-func clipboard_main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+func run_copy(opts *Options, args []string) error {
+	if opts.HistoryGet > -1 {
+		return copy_history_item(opts, opts.HistoryGet)
+	}
+	if opts.GetImage != "" {
+		return run_get_image(opts, opts.GetImage)
+	}
+	if opts.SetImage != "" {
+		return run_set_image(opts, opts.SetImage)
+	}
 	if len(args) > 0 {
-		return 0, run_mime_loop(opts, args)
+		return run_mime_loop(opts, args)
 	}
+	return run_filter_mode(opts)
+}
 
-	return 0, run_plain_text_loop(opts)
+func clipboard_main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if opts.ClearAfter < 0 {
+		return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "The number of seconds for --clear-after must not be negative"}
+	}
+	if opts.ClearAfter > 0 && os.Getenv(clear_helper_env_var) != "" {
+		return 0, run_clear_helper(opts)
+	}
+	if opts.Detach && os.Getenv(detach_helper_env_var) != "" {
+		return 0, run_detach_helper(opts)
+	}
+	if opts.Detach {
+		if opts.WaitForCompletion {
+			return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "Only one of --detach and --wait-for-completion can be used"}
+		}
+		if err = check_detach_usage(opts, args, tty.IsTerminal(os.Stdin.Fd())); err != nil {
+			return cli.ExitCodeUsage, err
+		}
+	}
+	if opts.HistoryList {
+		return 0, print_history_list()
+	}
+	if opts.Watch {
+		return 0, run_watch_loop(opts)
+	}
+	if opts.ListTypes {
+		return 0, print_available_mime_types(opts)
+	}
+	if opts.Clear {
+		return 0, run_clear(opts)
+	}
+	if opts.SyncToPrimary || opts.SyncFromPrimary {
+		if opts.SyncToPrimary && opts.SyncFromPrimary {
+			return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "Only one of --sync-to-primary and --sync-from-primary can be used"}
+		}
+		return 0, run_sync_loop(opts, opts.SyncToPrimary)
+	}
+	if err = run_copy(opts, args); err == nil && opts.ClearAfter > 0 && !opts.GetClipboard && opts.GetImage == "" {
+		err = schedule_clear(opts)
+	}
+	return 0, err
 }
 
 func EntryPoint(parent *cli.Command) {
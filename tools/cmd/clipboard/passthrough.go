@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type multiplexer int
+
+const (
+	no_multiplexer multiplexer = iota
+	tmux_multiplexer
+	screen_multiplexer
+)
+
+// screen drops DCS escape codes longer than 768 bytes, tmux has no such limit,
+// but it buffers each escape code fully, so keep them reasonably small
+const screen_chunk_size = 760
+const tmux_chunk_size = 4096
+
+func multiplexer_for_passthrough(opts *Options) multiplexer {
+	switch opts.Passthrough {
+	case "tmux":
+		return tmux_multiplexer
+	case "screen":
+		return screen_multiplexer
+	case "auto":
+		if in_tmux() {
+			return tmux_multiplexer
+		}
+		if os.Getenv("STY") != "" {
+			return screen_multiplexer
+		}
+	}
+	return no_multiplexer
+}
+
+func split_into_chunks(data string, size int) []string {
+	ans := make([]string, 0, len(data)/size+1)
+	for len(data) > size {
+		ans = append(ans, data[:size])
+		data = data[size:]
+	}
+	return append(ans, data)
+}
+
+// Wrap escape codes so that the multiplexer passes them through unchanged to
+// the terminal it is running in
+func (self multiplexer) wrap(data string) string {
+	if data == "" {
+		return data
+	}
+	var ans strings.Builder
+	switch self {
+	case tmux_multiplexer:
+		for _, chunk := range split_into_chunks(data, tmux_chunk_size) {
+			ans.WriteString("\x1bPtmux;")
+			ans.WriteString(strings.ReplaceAll(chunk, "\x1b", "\x1b\x1b"))
+			ans.WriteString("\x1b\\")
+		}
+	case screen_multiplexer:
+		// an ST would terminate the DCS escape code, so use BEL instead
+		data = strings.ReplaceAll(data, "\x1b\\", "\a")
+		for _, chunk := range split_into_chunks(data, screen_chunk_size) {
+			ans.WriteString("\x1bP")
+			ans.WriteString(chunk)
+			ans.WriteString("\x1b\\")
+		}
+	default:
+		return data
+	}
+	return ans.String()
+}
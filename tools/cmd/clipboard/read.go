@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 
+	"kitty/tools/cli"
 	"kitty/tools/tty"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
@@ -222,7 +223,7 @@ func error_from_status(status string) error {
 	case "ENOSYS":
 		return fmt.Errorf("no primary selection available on this system")
 	case "EPERM":
-		return fmt.Errorf("permission denied")
+		return &cli.ExitError{Code: cli.ExitCodePermissionDenied, Message: "permission denied"}
 	case "EBUSY":
 		return fmt.Errorf("a temporary error occurred, try again later.")
 	default:
@@ -316,9 +317,11 @@ func run_get_loop(opts *Options, args []string) (err error) {
 		basic_metadata["loc"] = "primary"
 	}
 
+	waiter := new_response_waiter(lp, opts)
+
 	lp.OnInitialize = func() (string, error) {
 		lp.QueueWriteString(encode(basic_metadata, "."))
-		return "", nil
+		return "", waiter.start()
 	}
 
 	lp.OnEscapeCode = func(etype loop.EscapeCodeType, data []byte) (err error) {
@@ -329,6 +332,9 @@ func run_get_loop(opts *Options, args []string) (err error) {
 		if metadata == nil {
 			return nil
 		}
+		if is_prompt, err := waiter.on_status(metadata["status"]); is_prompt || err != nil {
+			return err
+		}
 		if reading_available_mimes {
 			switch metadata["status"] {
 			case "DATA":
@@ -401,23 +407,7 @@ func run_get_loop(opts *Options, args []string) (err error) {
 		return
 	}
 
-	esc_count := 0
-	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
-		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
-			event.Handled = true
-			esc_count++
-			if esc_count < 2 {
-				key := "Esc"
-				if event.MatchesPressOrRepeat("ctrl+c") {
-					key = "Ctrl+C"
-				}
-				lp.QueueWriteString(fmt.Sprintf("Waiting for response from terminal, press %s again to abort. This could cause garbage to be spewed to the screen.\r\n", key))
-			} else {
-				return fmt.Errorf("Aborted by user!")
-			}
-		}
-		return nil
-	}
+	lp.OnKeyEvent = waiter.on_key_event
 
 	err = lp.Run()
 	wg.Wait()
@@ -0,0 +1,101 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"fmt"
+	"time"
+
+	"kitty/tools/tui"
+	"kitty/tools/tui/loop"
+)
+
+var _ = fmt.Print
+
+// Waits for a response from the terminal, failing if none arrives within
+// --response-timeout and letting the user abort the wait with Esc or Ctrl+C
+type response_waiter struct {
+	lp        *loop.Loop
+	opts      *Options
+	esc_count int
+	started   bool
+	timer_id  loop.IdType
+
+	// set while the terminal is asking the user for permission
+	spinner       *tui.Spinner
+	spinner_timer loop.IdType
+}
+
+func new_response_waiter(lp *loop.Loop, opts *Options) *response_waiter {
+	return &response_waiter{lp: lp, opts: opts}
+}
+
+// Start the timeout, call once the request has been completely sent
+func (self *response_waiter) start() error {
+	if self.started || self.opts.ResponseTimeout <= 0 {
+		return nil
+	}
+	self.started = true
+	var err error
+	self.timer_id, err = self.lp.AddTimer(time.Duration(self.opts.ResponseTimeout*float64(time.Second)), false, func(loop.IdType) error {
+		return fmt.Errorf("Timed out waiting for a response from the terminal after %v seconds, it probably does not support the clipboard protocol or clipboard access is disabled", self.opts.ResponseTimeout)
+	})
+	return err
+}
+
+func (self *response_waiter) draw_spinner() {
+	self.lp.QueueWriteString("\r" + self.spinner.Tick() + " Waiting for confirmation in terminal")
+	self.lp.ClearToEndOfLine()
+}
+
+// Call with the status of every response from the terminal. Terminals send
+// the ASK status when they ask the user for permission, the time taken to
+// answer does not count towards the timeout. Returns true for ASK, which is
+// not an actual response.
+func (self *response_waiter) on_status(status string) (is_permission_prompt bool, err error) {
+	if status == "ASK" {
+		if self.spinner != nil {
+			return true, nil
+		}
+		if self.started {
+			self.lp.RemoveTimer(self.timer_id)
+			self.started = false
+		}
+		self.spinner = tui.NewSpinner("dots")
+		if !self.opts.Quiet {
+			self.draw_spinner()
+			self.spinner_timer, err = self.lp.AddTimer(100*time.Millisecond, true, func(loop.IdType) error {
+				self.draw_spinner()
+				return nil
+			})
+		}
+		return true, err
+	}
+	if self.spinner != nil {
+		self.spinner = nil
+		if !self.opts.Quiet {
+			self.lp.RemoveTimer(self.spinner_timer)
+			self.lp.QueueWriteString("\r")
+			self.lp.ClearToEndOfLine()
+		}
+		return false, self.start()
+	}
+	return false, nil
+}
+
+func (self *response_waiter) on_key_event(event *loop.KeyEvent) error {
+	if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
+		event.Handled = true
+		self.esc_count++
+		if self.esc_count < 2 && !self.opts.Quiet {
+			key := "Esc"
+			if event.MatchesPressOrRepeat("ctrl+c") {
+				key = "Ctrl+C"
+			}
+			self.lp.QueueWriteString(fmt.Sprintf("Waiting for response from terminal, press %s again to abort. This could cause garbage to be spewed to the screen.\r\n", key))
+		} else {
+			return fmt.Errorf("Aborted by user!")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// Clipboard managers such as Klipper do not remember data that is accompanied
+// by this MIME type with the value secret
+const password_manager_hint_mime = "x-kde-passwordManagerHint"
+
+var secret_patterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`),
+	regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}\b`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+	regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`),
+	// JSON Web Tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]+`),
+}
+
+func looks_like_secret(text string) bool {
+	for _, pat := range secret_patterns {
+		if pat.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func warn_if_secret(opts *Options, text string) {
+	if !opts.Sensitive && looks_like_secret(text) {
+		fmt.Fprintln(os.Stderr, "Warning: the copied text looks like a private key or access token, use --sensitive to prevent clipboard managers from remembering it")
+	}
+}
+
+// Copy text to the clipboard along with the hint that tells clipboard managers
+// not to remember it
+func run_sensitive_copy(opts *Options, src io.Reader) (err error) {
+	supported, err := detect_mime_support()
+	if err != nil {
+		return err
+	}
+	if !supported {
+		fmt.Fprintln(os.Stderr, "Warning: this terminal does not support marking the copied text as sensitive, clipboard managers might remember it")
+		return plain_text_loop(opts, src, false)
+	}
+	if transforms_requested(opts) {
+		if src, err = transform_input(opts, src); err != nil {
+			return err
+		}
+	}
+	if opts.MaxSize != "" {
+		if src, err = limit_size(opts, src); err != nil {
+			return err
+		}
+	}
+	return write_loop([]*Input{
+		{src: src, arg: "/dev/stdin", is_stream: true, mime_type: "text/plain"},
+		{src: strings.NewReader("secret"), arg: password_manager_hint_mime, mime_type: password_manager_hint_mime},
+	}, opts)
+}
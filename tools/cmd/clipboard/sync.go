@@ -0,0 +1,66 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Copy the text in the clipboard to the primary selection or vice versa. The
+// base64 encoded text received from the terminal is sent back unchanged.
+func run_sync_loop(opts *Options, to_primary bool) (err error) {
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return
+	}
+	mux := multiplexer_for_passthrough(opts)
+	waiter := new_response_waiter(lp, opts)
+	dest := "p"
+	if !to_primary {
+		dest = "c"
+	}
+
+	lp.OnInitialize = func() (string, error) {
+		lp.QueueWriteString(mux.wrap(encode_read_from_clipboard(!to_primary)))
+		return "", waiter.start()
+	}
+
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, data []byte) error {
+		if etype != loop.OSC {
+			return nil
+		}
+		q := utils.UnsafeBytesToString(data)
+		if !strings.HasPrefix(q, "52;") {
+			return nil
+		}
+		parts := strings.SplitN(q, ";", 3)
+		if len(parts) < 3 || parts[2] == "" {
+			if to_primary {
+				return fmt.Errorf("The clipboard is empty")
+			}
+			return fmt.Errorf("The primary selection is empty")
+		}
+		lp.QueueWriteString(mux.wrap(fmt.Sprintf("\x1b]52;%s;%s\x1b\\", dest, parts[2])))
+		lp.Quit(0)
+		return nil
+	}
+
+	lp.OnKeyEvent = waiter.on_key_event
+
+	err = lp.Run()
+	if err != nil {
+		return
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+	}
+	return
+}
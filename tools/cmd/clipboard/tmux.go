@@ -0,0 +1,31 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var _ = fmt.Print
+
+func in_tmux() bool {
+	return os.Getenv("TMUX") != ""
+}
+
+// Copy text into a new tmux paste buffer
+func copy_to_tmux_buffer(text string) error {
+	cmd := exec.Command("tmux", "load-buffer", "-")
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		var exit_err *exec.ExitError
+		if errors.As(err, &exit_err) {
+			return fmt.Errorf("Failed to copy to tmux paste buffer with error: %s", strings.TrimSpace(string(output)))
+		}
+		return fmt.Errorf("Failed to run tmux to copy to its paste buffer with error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+func transforms_requested(opts *Options) bool {
+	return opts.StripTrailingNewline || opts.Trim || opts.ConvertCrlf || opts.ExpandTabs > 0
+}
+
+func expand_tabs(text string, tab_width int) string {
+	var ans strings.Builder
+	ans.Grow(len(text))
+	col := 0
+	for _, ch := range text {
+		switch ch {
+		case '\t':
+			n := tab_width - col%tab_width
+			ans.WriteString(strings.Repeat(" ", n))
+			col += n
+		case '\n', '\r':
+			ans.WriteRune(ch)
+			col = 0
+		default:
+			ans.WriteRune(ch)
+			col += utils.Max(0, wcswidth.Runewidth(ch))
+		}
+	}
+	return ans.String()
+}
+
+// Apply the text transformations specified on the command line
+func transform_text(opts *Options, text string) string {
+	if opts.ConvertCrlf {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	if opts.ExpandTabs > 0 {
+		text = expand_tabs(text, opts.ExpandTabs)
+	}
+	if opts.Trim {
+		text = strings.TrimSpace(text)
+	}
+	if opts.StripTrailingNewline {
+		if strings.HasSuffix(text, "\r\n") {
+			text = text[:len(text)-2]
+		} else {
+			text = strings.TrimSuffix(text, "\n")
+		}
+	}
+	return text
+}
+
+// The transformations need all the text, so read it before anything is sent
+// to the terminal
+func transform_input(opts *Options, src io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from STDIN with error: %w", err)
+	}
+	if c, ok := src.(io.Closer); ok {
+		c.Close()
+	}
+	return bytes.NewReader(utils.UnsafeStringToBytes(transform_text(opts, utils.UnsafeBytesToString(data)))), nil
+}
@@ -0,0 +1,131 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"kitty/tools/tty"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/utils/shlex"
+)
+
+var _ = fmt.Print
+
+// Report the contents of the clipboard every time they change, by polling the
+// terminal using OSC 52. The contents at startup are not reported.
+func run_watch_loop(opts *Options) (err error) {
+	if opts.WatchInterval <= 0 {
+		return fmt.Errorf("The interval for --watch-interval must be a positive number of seconds")
+	}
+	var argv []string
+	if opts.WatchCommand != "" {
+		if argv, err = shlex.Split(opts.WatchCommand); err != nil || len(argv) == 0 {
+			return fmt.Errorf("The command to run is invalid: %#v", opts.WatchCommand)
+		}
+	}
+	lp, err := loop.New(loop.NoAlternateScreen, loop.NoRestoreColors, loop.NoMouseTracking)
+	if err != nil {
+		return
+	}
+	interval := time.Duration(opts.WatchInterval * float64(time.Second))
+	stdout_is_tty := tty.IsTerminal(os.Stdout.Fd())
+	var previous []byte
+	have_previous := false
+	num_changes := 0
+
+	mux := multiplexer_for_passthrough(opts)
+	poll := func(loop.IdType) error {
+		lp.QueueWriteString(mux.wrap(encode_read_from_clipboard(opts.UsePrimary)))
+		return nil
+	}
+
+	report := func(data []byte) error {
+		num_changes++
+		if argv != nil {
+			cmd := exec.Command(argv[0], argv[1:]...)
+			cmd.Stdin = bytes.NewReader(data)
+			cmd.Env = append(os.Environ(), "KITTY_CLIPBOARD_CHANGE_NUMBER="+strconv.Itoa(num_changes))
+			if err := lp.SuspendAndRunCommand(cmd); err != nil {
+				if _, is_exit_err := err.(*exec.ExitError); !is_exit_err {
+					return fmt.Errorf("Failed to run the command: %s with error: %w", argv[0], err)
+				}
+			}
+			return nil
+		}
+		if stdout_is_tty {
+			lp.Println(strings.ReplaceAll(utils.UnsafeBytesToString(data), "\n", "\r\n"))
+			return nil
+		}
+		sep := byte('\n')
+		if opts.WatchNull {
+			sep = 0
+		}
+		if _, err := os.Stdout.Write(append(data, sep)); err != nil {
+			return fmt.Errorf("Failed to write to STDOUT with error: %w", err)
+		}
+		return nil
+	}
+
+	lp.OnInitialize = func() (string, error) {
+		poll(0)
+		return "", nil
+	}
+
+	lp.OnEscapeCode = func(etype loop.EscapeCodeType, data []byte) error {
+		if etype != loop.OSC {
+			return nil
+		}
+		q := utils.UnsafeBytesToString(data)
+		if !strings.HasPrefix(q, "52;") {
+			return nil
+		}
+		var contents []byte
+		if parts := strings.SplitN(q, ";", 3); len(parts) == 3 {
+			var err error
+			if contents, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+				return fmt.Errorf("Invalid base64 encoded data from terminal with error: %w", err)
+			}
+		}
+		if _, err := lp.AddTimer(interval, false, poll); err != nil {
+			return err
+		}
+		if !have_previous {
+			previous, have_previous = contents, true
+			return nil
+		}
+		if bytes.Equal(contents, previous) {
+			return nil
+		}
+		previous = contents
+		return report(contents)
+	}
+
+	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
+		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
+			event.Handled = true
+			lp.Quit(0)
+		}
+		return nil
+	}
+
+	err = lp.Run()
+	if err != nil {
+		return
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return
+	}
+	return
+}
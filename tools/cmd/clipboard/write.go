@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"kitty/tools/cli"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
 )
@@ -17,7 +18,7 @@ import (
 var _ = fmt.Print
 
 type Input struct {
-	src       *os.File
+	src       io.Reader
 	arg       string
 	ext       string
 	is_stream bool
@@ -47,6 +48,8 @@ func write_loop(inputs []*Input, opts *Options) (err error) {
 		return ans
 	}
 
+	waiter := new_response_waiter(lp, opts)
+
 	lp.OnInitialize = func() (string, error) {
 		waiting_for_write = lp.QueueWriteString(encode(make_metadata("write", ""), ""))
 		return "", nil
@@ -70,6 +73,9 @@ func write_loop(inputs []*Input, opts *Options) (err error) {
 				if len(inputs) == 0 {
 					lp.QueueWriteString(encode(make_metadata("wdata", ""), ""))
 					waiting_for_write = 0
+					if err := waiter.start(); err != nil {
+						return err
+					}
 				}
 				return lp.OnWriteComplete(waiting_for_write)
 			}
@@ -101,7 +107,7 @@ func write_loop(inputs []*Input, opts *Options) (err error) {
 			case "ENOSYS":
 				return fmt.Errorf("Could not write to primary selection as the system does not support it")
 			case "EPERM":
-				return fmt.Errorf("Could not write to clipboard as permission was denied")
+				return &cli.ExitError{Code: cli.ExitCodePermissionDenied, Message: "Could not write to clipboard as permission was denied"}
 			case "EBUSY":
 				return fmt.Errorf("Could not write to clipboard, a temporary error occurred, try again later.")
 			default:
@@ -111,23 +117,7 @@ func write_loop(inputs []*Input, opts *Options) (err error) {
 		return
 	}
 
-	esc_count := 0
-	lp.OnKeyEvent = func(event *loop.KeyEvent) error {
-		if event.MatchesPressOrRepeat("ctrl+c") || event.MatchesPressOrRepeat("esc") {
-			event.Handled = true
-			esc_count++
-			if esc_count < 2 {
-				key := "Esc"
-				if event.MatchesPressOrRepeat("ctrl+c") {
-					key = "Ctrl+C"
-				}
-				lp.QueueWriteString(fmt.Sprintf("Waiting for response from terminal, press %s again to abort. This could cause garbage to be spewed to the screen.\r\n", key))
-			} else {
-				return fmt.Errorf("Aborted by user!")
-			}
-		}
-		return nil
-	}
+	lp.OnKeyEvent = waiter.on_key_event
 
 	err = lp.Run()
 	if err != nil {
@@ -148,8 +138,8 @@ func run_set_loop(opts *Options, args []string) (err error) {
 	to_process := make([]*Input, len(args))
 	defer func() {
 		for _, i := range inputs {
-			if i.src != nil {
-				i.src.Close()
+			if c, ok := i.src.(io.Closer); ok {
+				c.Close()
 			}
 		}
 	}()
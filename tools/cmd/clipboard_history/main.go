@@ -0,0 +1,297 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package clipboard_history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"kitty/tools/cli"
+	"kitty/tools/cli/markup"
+	"kitty/tools/cmd/clipboard"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/utils/humanize"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type match struct {
+	item      clipboard.HistoryItem
+	positions []int
+	score     int
+}
+
+type handler struct {
+	lp       *loop.Loop
+	opts     *Options
+	fmt_ctx  *markup.Context
+	items    []clipboard.HistoryItem
+	query    string
+	matches  []match
+	current  int
+	scroll   int
+	selected *clipboard.HistoryItem
+}
+
+func (self *handler) update_matches() {
+	self.matches = self.matches[:0]
+	for _, item := range self.items {
+		score, positions := utils.FuzzyMatch(self.query, item.Text)
+		if score >= 0 {
+			self.matches = append(self.matches, match{item: item, positions: positions, score: score})
+		}
+	}
+	if self.query != "" {
+		// stable so that equally good matches remain most recent first
+		sort.SliceStable(self.matches, func(i, j int) bool { return self.matches[i].score > self.matches[j].score })
+	}
+	self.current, self.scroll = 0, 0
+}
+
+// Characters that must not be sent to the terminal as is, newlines and tabs
+// are replaced separately
+func is_control(ch rune) bool {
+	return ch < 0x20 || (ch >= 0x7f && ch < 0xa0)
+}
+
+// Render text as a single line at most width cells wide, highlighting the
+// characters at the specified rune positions
+func (self *handler) one_line(text string, positions []int, width int) string {
+	var ans, run strings.Builder
+	in_match := false
+	flush := func() {
+		if run.Len() > 0 {
+			if in_match {
+				ans.WriteString(self.fmt_ctx.Green(run.String()))
+			} else {
+				ans.WriteString(run.String())
+			}
+			run.Reset()
+		}
+	}
+	x, i := 0, 0
+	for _, ch := range text {
+		switch {
+		case ch == '\n':
+			ch = '⏎'
+		case ch == '\t' || is_control(ch):
+			ch = ' '
+		}
+		w := utils.Max(0, wcswidth.Runewidth(ch))
+		if x+w > width {
+			break
+		}
+		matched := len(positions) > 0 && positions[0] == i
+		if matched {
+			positions = positions[1:]
+		}
+		if matched != in_match {
+			flush()
+			in_match = matched
+		}
+		run.WriteRune(ch)
+		x += w
+		i++
+	}
+	flush()
+	return ans.String()
+}
+
+func (self *handler) list_height(sz loop.ScreenSize) int {
+	return utils.Max(1, int(sz.HeightCells)-2-self.preview_height(sz))
+}
+
+func (self *handler) preview_height(sz loop.ScreenSize) int {
+	if sz.HeightCells < 12 {
+		return 0
+	}
+	return int(sz.HeightCells) / 3
+}
+
+func (self *handler) draw_preview(text string, width, height int) {
+	lines := strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n")
+	for i, line := range lines {
+		if i == height-1 && len(lines) > height {
+			self.lp.QueueWriteString(self.fmt_ctx.Dim(fmt.Sprintf("… %d more lines", len(lines)-i)))
+			break
+		}
+		line = strings.Map(func(ch rune) rune {
+			if is_control(ch) {
+				return utf8.RuneError
+			}
+			return ch
+		}, line)
+		self.lp.QueueWriteString(wcswidth.TruncateToVisualLength(line, width))
+		self.lp.QueueWriteString("\r\n")
+	}
+}
+
+func (self *handler) draw_screen() {
+	self.lp.StartAtomicUpdate()
+	defer self.lp.EndAtomicUpdate()
+	self.lp.ClearScreen()
+	sz, err := self.lp.ScreenSize()
+	if err != nil {
+		return
+	}
+	width := int(sz.WidthCells)
+	list_height := self.list_height(sz)
+	if self.current < self.scroll {
+		self.scroll = self.current
+	} else if self.current >= self.scroll+list_height {
+		self.scroll = self.current - list_height + 1
+	}
+	self.lp.MoveCursorTo(1, 2)
+	if len(self.matches) == 0 {
+		self.lp.QueueWriteString(self.fmt_ctx.Dim("No matching entries"))
+	}
+	for i := self.scroll; i < len(self.matches) && i < self.scroll+list_height; i++ {
+		m := &self.matches[i]
+		when := fmt.Sprintf("%-16s ", humanize.Time(m.item.Timestamp))
+		text := self.one_line(m.item.Text, m.positions, width-2-wcswidth.Stringwidth(when))
+		if i == self.current {
+			self.lp.QueueWriteString(self.fmt_ctx.Yellow("❯ ") + self.fmt_ctx.Dim(when) + self.fmt_ctx.Bold(text))
+		} else {
+			self.lp.QueueWriteString("  " + self.fmt_ctx.Dim(when) + text)
+		}
+		self.lp.QueueWriteString("\r\n")
+	}
+	if ph := self.preview_height(sz); ph > 0 && len(self.matches) > 0 {
+		self.lp.MoveCursorTo(1, int(sz.HeightCells)-ph+1)
+		self.lp.QueueWriteString(self.fmt_ctx.Dim(strings.Repeat("─", width)))
+		self.lp.QueueWriteString("\r\n")
+		self.draw_preview(self.matches[self.current].item.Text, width, ph-1)
+	}
+	self.lp.MoveCursorTo(1, 1)
+	self.lp.QueueWriteString(self.fmt_ctx.Title("Search: ") + self.query)
+}
+
+func (self *handler) move_current(delta int) {
+	if len(self.matches) == 0 {
+		self.lp.Beep()
+		return
+	}
+	self.current = utils.Max(0, utils.Min(self.current+delta, len(self.matches)-1))
+}
+
+func (self *handler) on_key_event(event *loop.KeyEvent) error {
+	sz, _ := self.lp.ScreenSize()
+	page := utils.Max(1, self.list_height(sz)-1)
+	switch {
+	case event.MatchesPressOrRepeat("esc") || event.MatchesPressOrRepeat("ctrl+c"):
+		self.lp.Quit(1)
+	case event.MatchesPressOrRepeat("enter"):
+		if len(self.matches) == 0 {
+			self.lp.Beep()
+			break
+		}
+		self.selected = &self.matches[self.current].item
+		self.lp.QueueWriteString(clipboard.EncodeSetClipboard(self.selected.Text, self.opts.UsePrimary))
+		self.lp.Quit(0)
+	case event.MatchesPressOrRepeat("up") || event.MatchesPressOrRepeat("ctrl+p") || event.MatchesPressOrRepeat("ctrl+k"):
+		self.move_current(-1)
+	case event.MatchesPressOrRepeat("down") || event.MatchesPressOrRepeat("ctrl+n") || event.MatchesPressOrRepeat("ctrl+j"):
+		self.move_current(1)
+	case event.MatchesPressOrRepeat("page_up"):
+		self.move_current(-page)
+	case event.MatchesPressOrRepeat("page_down"):
+		self.move_current(page)
+	case event.MatchesPressOrRepeat("home"):
+		self.move_current(-len(self.matches))
+	case event.MatchesPressOrRepeat("end"):
+		self.move_current(len(self.matches))
+	case event.MatchesPressOrRepeat("backspace"):
+		if self.query == "" {
+			self.lp.Beep()
+			break
+		}
+		_, n := utf8.DecodeLastRuneInString(self.query)
+		self.query = self.query[:len(self.query)-n]
+		self.update_matches()
+	case event.MatchesPressOrRepeat("ctrl+u"):
+		self.query = ""
+		self.update_matches()
+	default:
+		return nil
+	}
+	event.Handled = true
+	self.draw_screen()
+	return nil
+}
+
+func run_loop(opts *Options, items []clipboard.HistoryItem) (selected *clipboard.HistoryItem, err error) {
+	lp, err := loop.New(loop.NoMouseTracking)
+	if err != nil {
+		return
+	}
+	h := handler{lp: lp, opts: opts, items: items, query: opts.Query, fmt_ctx: markup.New(true)}
+	h.update_matches()
+
+	lp.OnInitialize = func() (string, error) {
+		h.draw_screen()
+		return "", nil
+	}
+	lp.OnKeyEvent = h.on_key_event
+	lp.OnText = func(text string, from_key_event, in_bracketed_paste bool) error {
+		h.query += strings.Map(func(ch rune) rune {
+			if is_control(ch) {
+				return -1
+			}
+			return ch
+		}, text)
+		h.update_matches()
+		h.draw_screen()
+		return nil
+	}
+	lp.OnResize = func(old_size, new_size loop.ScreenSize) error {
+		h.draw_screen()
+		return nil
+	}
+
+	err = lp.Run()
+	if err != nil {
+		return
+	}
+	ds := lp.DeathSignalName()
+	if ds != "" {
+		fmt.Println("Killed by signal: ", ds)
+		lp.KillIfSignalled()
+		return
+	}
+	return h.selected, nil
+}
+
+func main(cmd *cli.Command, opts *Options, args []string) (rc int, err error) {
+	if len(args) > 0 {
+		return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "This kitten does not accept any arguments"}
+	}
+	items, err := clipboard.ReadHistory()
+	if err != nil {
+		return 1, err
+	}
+	if len(items) == 0 {
+		return 1, errors.New("The clipboard history is empty, text copied with the clipboard kitten is added to it")
+	}
+	selected, err := run_loop(opts, items)
+	if err != nil {
+		return 1, err
+	}
+	if selected == nil {
+		return 1, nil
+	}
+	// move the entry to the top of the history
+	if herr := clipboard.AddToHistory(selected.Text, selected.Mime); herr != nil {
+		fmt.Fprintln(os.Stderr, "Failed to update the clipboard history with error:", herr)
+	}
+	return 0, nil
+}
+
+func EntryPoint(parent *cli.Command) {
+	create_cmd(parent, main)
+}
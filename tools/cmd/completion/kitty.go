@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 
@@ -83,14 +84,33 @@ func complete_themes(completions *cli.Completions, word string, arg_num int) {
 }
 
 func EntryPoint(tool_root *cli.Command) {
-	tool_root.AddSubCommand(&cli.Command{
+	c := tool_root.AddSubCommand(&cli.Command{
 		Name: "__complete__", Hidden: true,
 		Usage:            "output_type [shell state...]",
 		ShortDescription: "Generate completions for kitty commands",
 		HelpText:         "Generate completion candidates for kitty commands. The command line is read from STDIN. output_type can be one of the supported  shells or 'json' for JSON output.",
 		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
+			format, err := cli.GetOptionValue[string](cmd, "Format")
+			if err != nil {
+				return 1, err
+			}
+			if format != "" {
+				script, err := cli.GenerateStaticCompletionScript(format)
+				if err != nil {
+					return 1, err
+				}
+				_, err = os.Stdout.WriteString(script)
+				return ret, err
+			}
 			return ret, cli.GenerateCompletions(args)
 		},
 	})
+	c.Add(cli.OptionSpec{
+		Name:    "--format",
+		Choices: "bash, zsh, fish, nushell, powershell",
+		Default: "",
+		Help: "Instead of generating completions for a command line, output a completion script for the specified shell" +
+			" that completes the sub-commands and options of all kitty commands without needing to run kitten.",
+	})
 
 }
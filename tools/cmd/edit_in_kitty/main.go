@@ -242,11 +242,11 @@ func EntryPoint(parent *cli.Command) *cli.Command {
 		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
 			if len(args) == 0 {
 				fmt.Fprintln(os.Stderr, "Usage:", cmd.Usage)
-				return 1, fmt.Errorf("No file to edit specified.")
+				return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "No file to edit specified."}
 			}
 			if len(args) != 1 {
 				fmt.Fprintln(os.Stderr, "Usage:", cmd.Usage)
-				return 1, fmt.Errorf("Only one file to edit must be specified")
+				return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "Only one file to edit must be specified"}
 			}
 			var opts Options
 			err = cmd.GetOptionValues(&opts)
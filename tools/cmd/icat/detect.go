@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"kitty/tools/tui/graphics"
@@ -16,7 +17,7 @@ import (
 
 var _ = fmt.Print
 
-func DetectSupport(timeout time.Duration) (memory, files, direct bool, err error) {
+func DetectSupport(timeout time.Duration) (memory, files, direct, sixel bool, err error) {
 	temp_files_to_delete := make([]string, 0, 8)
 	shm_files_to_delete := make([]shm.MMap, 0, 8)
 	var direct_query_id, file_query_id, memory_query_id uint32
@@ -88,6 +89,8 @@ func DetectSupport(timeout time.Duration) (memory, files, direct bool, err error
 		switch etype {
 		case loop.CSI:
 			if len(payload) > 3 && payload[0] == '?' && payload[len(payload)-1] == 'c' {
+				// the primary device attributes include 4 when sixel graphics are supported
+				sixel = utils.Contains(strings.Split(string(payload[1:len(payload)-1]), ";"), "4")
 				lp.Quit(0)
 				return nil
 			}
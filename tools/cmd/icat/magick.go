@@ -31,6 +31,21 @@ func find_magick_exe() {
 	magick_exe = utils.Which("magick")
 }
 
+func magick_available() bool {
+	find_exe_lock.Do(find_magick_exe)
+	return magick_exe != "" || utils.Which("convert") != ""
+}
+
+// Limit the memory ImageMagick uses for pixel data, beyond which it caches
+// pixels on disk
+func magick_limits() []string {
+	if max_memory == 0 {
+		return nil
+	}
+	limit := strconv.FormatUint(max_memory, 10)
+	return []string{"-limit", "memory", limit, "-limit", "map", limit}
+}
+
 func run_magick(path string, cmd []string) ([]byte, error) {
 	c := exec.Command(cmd[0], cmd[1:]...)
 	output, err := c.Output()
@@ -161,6 +176,7 @@ func Identify(path string) (ans []IdentifyRecord, err error) {
 	}
 	q := `{"fmt":"%m","canvas":"%g","transparency":"%A","gap":"%T","index":"%p","size":"%wx%h",` +
 		`"dpi":"%xx%y","dispose":"%D","orientation":"%[EXIF:Orientation]"},`
+	cmd = append(cmd, magick_limits()...)
 	cmd = append(cmd, "-format", q, "--", path)
 	output, err := run_magick(path, cmd)
 	if err != nil {
@@ -255,6 +271,12 @@ func Render(path string, ro *RenderOptions, frames []IdentifyRecord) (ans []*ima
 	if ro.Flop {
 		cmd = append(cmd, "-flop")
 	}
+	cmd = append(cmd, magick_limits()...)
+	if ro.ResizeTo.X > 0 {
+		// allows decoders that support it, such as the JPEG decoder, to
+		// scale the image down while decoding it
+		cmd = append(cmd, "-define", fmt.Sprintf("jpeg:size=%dx%d", ro.ResizeTo.X, ro.ResizeTo.Y))
+	}
 	cpath := path
 	if ro.OnlyFirstFrame {
 		cpath += "[0]"
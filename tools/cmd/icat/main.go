@@ -16,6 +16,7 @@ import (
 	"kitty/tools/tui"
 	"kitty/tools/tui/graphics"
 	"kitty/tools/utils"
+	"kitty/tools/utils/humanize"
 	"kitty/tools/utils/images"
 	"kitty/tools/utils/style"
 
@@ -33,6 +34,7 @@ var place *Place
 var z_index int32
 var remove_alpha *images.NRGBColor
 var flip, flop bool
+var max_memory uint64
 
 type transfer_mode int
 
@@ -42,7 +44,7 @@ const (
 	supported
 )
 
-var transfer_by_file, transfer_by_memory, transfer_by_stream transfer_mode
+var transfer_by_file, transfer_by_memory, transfer_by_stream, transfer_by_sixel transfer_mode
 
 var files_channel chan input_arg
 var output_channel chan *image_data
@@ -87,6 +89,13 @@ func parse_z_index() (err error) {
 	return
 }
 
+func parse_max_memory() (err error) {
+	if max_memory, err = humanize.ParseBytes(opts.MaxMemory); err != nil {
+		return fmt.Errorf("Invalid value for --max-memory: %#v. It must be a number of bytes optionally followed by one of K, M or G", opts.MaxMemory)
+	}
+	return
+}
+
 func parse_place() (err error) {
 	if opts.Place == "" {
 		return nil
@@ -123,6 +132,10 @@ func parse_place() (err error) {
 	return nil
 }
 
+func usage_error(err error) error {
+	return cli.NewExitError(cli.ExitCodeUsage, err)
+}
+
 func print_error(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, format, args...)
 	fmt.Fprintln(os.Stderr)
@@ -132,19 +145,23 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 	opts = o
 	err = parse_place()
 	if err != nil {
-		return 1, err
+		return cli.ExitCodeUsage, usage_error(err)
 	}
 	err = parse_z_index()
 	if err != nil {
-		return 1, err
+		return cli.ExitCodeUsage, usage_error(err)
 	}
 	err = parse_background()
 	if err != nil {
-		return 1, err
+		return cli.ExitCodeUsage, usage_error(err)
 	}
 	err = parse_mirror()
 	if err != nil {
-		return 1, err
+		return cli.ExitCodeUsage, usage_error(err)
+	}
+	err = parse_max_memory()
+	if err != nil {
+		return cli.ExitCodeUsage, usage_error(err)
 	}
 	t, err := tty.OpenControllingTerm()
 	if err != nil {
@@ -173,7 +190,7 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 		return 1, err
 	}
 	if opts.Place != "" && len(items) > 1 {
-		return 1, fmt.Errorf("The --place option can only be used with a single image, not %d", len(items))
+		return cli.ExitCodeUsage, usage_error(fmt.Errorf("The --place option can only be used with a single image, not %d", len(items)))
 	}
 	files_channel = make(chan input_arg, len(items))
 	for _, ia := range items {
@@ -191,14 +208,18 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 	}
 
 	if opts.TransferMode == "detect" || opts.DetectSupport {
-		memory, files, direct, err := DetectSupport(time.Duration(opts.DetectionTimeout * float64(time.Second)))
+		memory, files, direct, sixel, err := DetectSupport(time.Duration(opts.DetectionTimeout * float64(time.Second)))
 		if err != nil {
 			return 1, err
 		}
-		if !direct {
+		if !direct && !sixel {
 			keep_going.Store(false)
 			return 1, fmt.Errorf("This terminal does not support the graphics protocol use a terminal such as kitty, WezTerm or Konsole that does. If you are running inside a terminal multiplexer such as tmux or screen that might be interfering as well.")
 		}
+		if !direct {
+			// images are drawn with lower quality sixel graphics instead
+			transfer_by_sixel = supported
+		}
 		if memory {
 			transfer_by_memory = supported
 		} else {
@@ -211,7 +232,9 @@ func main(cmd *cli.Command, o *Options, args []string) (rc int, err error) {
 		}
 	}
 	if opts.DetectSupport {
-		if transfer_by_memory == supported {
+		if transfer_by_sixel == supported {
+			print_error("sixel")
+		} else if transfer_by_memory == supported {
 			print_error("memory")
 		} else if transfer_by_file == supported {
 			print_error("files")
@@ -0,0 +1,93 @@
+// License: GPLv3 Copyright: 2023, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package icat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+var find_pdf_renderer_lock sync.Once
+var pdftoppm_exe, mutool_exe string
+
+func find_pdf_renderer() {
+	pdftoppm_exe = utils.Which("pdftoppm")
+	mutool_exe = utils.Which("mutool")
+}
+
+func is_pdf(f *opened_input) bool {
+	header := make([]byte, 5)
+	n, _ := io.ReadFull(f.file, header)
+	f.Rewind()
+	return n == len(header) && bytes.Equal(header, []byte("%PDF-"))
+}
+
+// The width in pixels to render PDF pages at, so that they fit in the area
+// the image will be displayed in without needing to be scaled
+func pdf_render_width() int {
+	if place != nil {
+		return place.width * int(screen_size.Xpixel) / int(screen_size.Col)
+	}
+	return int(screen_size.Xpixel)
+}
+
+// Render the specified page of the PDF document in f to a PNG image, replacing
+// the contents of f with the image
+func render_pdf_page(f *opened_input, page int) error {
+	find_pdf_renderer_lock.Do(find_pdf_renderer)
+	if pdftoppm_exe == "" && mutool_exe == "" {
+		return fmt.Errorf("Displaying PDF files requires either pdftoppm (from poppler) or mutool (from MuPDF) to be installed and in your PATH")
+	}
+	if page < 1 {
+		return fmt.Errorf("Invalid page number: %d", page)
+	}
+	input := ""
+	if q, ok := f.file.(*os.File); ok && f.name_to_unlink == "" {
+		input = q.Name()
+	} else {
+		if err := f.PutOnFilesystem(); err != nil {
+			return err
+		}
+		input = f.FileSystemName()
+	}
+	tdir, err := os.MkdirTemp("", "icat-pdf-*")
+	if err != nil {
+		return fmt.Errorf("Failed to create a temporary directory to render PDF into with error: %w", err)
+	}
+	defer os.RemoveAll(tdir)
+	output := filepath.Join(tdir, "page.png")
+	width, pagenum := strconv.Itoa(pdf_render_width()), strconv.Itoa(page)
+	var cmd []string
+	if pdftoppm_exe != "" {
+		cmd = []string{pdftoppm_exe, "-png", "-singlefile", "-f", pagenum, "-l", pagenum, "-scale-to-x", width, "-scale-to-y", "-1", input, strings.TrimSuffix(output, ".png")}
+	} else {
+		cmd = []string{mutool_exe, "draw", "-q", "-F", "png", "-w", width, "-o", output, input, pagenum}
+	}
+	c := exec.Command(cmd[0], cmd[1:]...)
+	if _, err = c.Output(); err != nil {
+		var exit_err *exec.ExitError
+		if errors.As(err, &exit_err) {
+			return fmt.Errorf("Running the command: %s\nFailed with error:\n%s", strings.Join(cmd, " "), string(exit_err.Stderr))
+		}
+		return err
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return fmt.Errorf("Failed to render page %d, does the document have that many pages? Error: %w", page, err)
+	}
+	f.Release()
+	f.file = &BytesBuf{data: data}
+	return nil
+}
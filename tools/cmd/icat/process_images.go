@@ -18,6 +18,7 @@ import (
 	"kitty/tools/tty"
 	"kitty/tools/tui/graphics"
 	"kitty/tools/utils"
+	"kitty/tools/utils/humanize"
 	"kitty/tools/utils/shm"
 )
 
@@ -204,6 +205,12 @@ func set_basic_metadata(imgd *image_data) {
 	imgd.needs_conversion = imgd.needs_scaling || remove_alpha != nil || flip || flop || imgd.format_uppercase != "PNG"
 }
 
+// The builtin engine decodes the full image into memory and then converts and
+// scales it, so it needs several bytes per pixel of the full size image
+func too_large_for_native_decode(c image.Config) bool {
+	return max_memory > 0 && uint64(c.Width)*uint64(c.Height)*8 > max_memory
+}
+
 func report_error(source_name, msg string, err error) {
 	imgd := image_data{source_name: source_name, err: fmt.Errorf("%s: %w", msg, err)}
 	send_output(&imgd)
@@ -267,6 +274,12 @@ func process_arg(arg input_arg) {
 		f.file = q
 	}
 	defer f.Release()
+	if is_pdf(&f) {
+		if err := render_pdf_page(&f, opts.Page); err != nil {
+			report_error(arg.value, "Could not render PDF", err)
+			return
+		}
+	}
 	can_use_go := false
 	var c image.Config
 	var format string
@@ -290,6 +303,16 @@ func process_arg(arg input_arg) {
 			send_output(&imgd)
 			return
 		}
+		if opts.Engine == "auto" && too_large_for_native_decode(c) {
+			if !magick_available() {
+				report_error(arg.value, "Could not render image", fmt.Errorf(
+					"decoding it needs more than %s of memory. Either install ImageMagick or use a larger --max-memory", humanize.IBytes(max_memory)))
+				return
+			}
+			can_use_go = false
+		}
+	}
+	if can_use_go {
 		err = render_image_with_go(&imgd, &f)
 		if err != nil {
 			report_error(arg.value, "Could not render image to RGB", err)
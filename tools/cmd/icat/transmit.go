@@ -6,6 +6,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"math"
 	"math/rand"
@@ -15,6 +17,7 @@ import (
 	"kitty/tools/tui/graphics"
 	"kitty/tools/tui/loop"
 	"kitty/tools/utils"
+	"kitty/tools/utils/images"
 	"kitty/tools/utils/shm"
 )
 
@@ -146,24 +149,62 @@ func transmit_file(imgd *image_data, frame_num int, frame *image_frame) (err err
 	return nil
 }
 
-func transmit_stream(imgd *image_data, frame_num int, frame *image_frame) (err error) {
-	data := frame.in_memory_bytes
+func frame_data(frame *image_frame) (data []byte, err error) {
+	data = frame.in_memory_bytes
 	if data == nil {
 		f, err := os.Open(frame.filename)
 		if err != nil {
-			return fmt.Errorf("Failed to open image data output file: %s with error: %w", frame.filename, err)
+			return nil, fmt.Errorf("Failed to open image data output file: %s with error: %w", frame.filename, err)
 		}
 		data, err = io.ReadAll(f)
 		f.Close()
 		if err != nil {
-			return fmt.Errorf("Failed to read data from image output data file: %w", err)
+			return nil, fmt.Errorf("Failed to read data from image output data file: %w", err)
 		}
 	}
+	return
+}
+
+func transmit_stream(imgd *image_data, frame_num int, frame *image_frame) (err error) {
+	data, err := frame_data(frame)
+	if err != nil {
+		return err
+	}
 	gc := gc_for_image(imgd, frame_num, frame)
 	gc.WriteWithPayloadTo(os.Stdout, data)
 	return nil
 }
 
+// Draw the image as sixel graphics, for terminals that do not support the
+// graphics protocol. Only the first frame of animations is drawn.
+func transmit_sixel(imgd *image_data, frame_num int, frame *image_frame) (err error) {
+	if frame_num > 0 {
+		return nil
+	}
+	data, err := frame_data(frame)
+	if err != nil {
+		return err
+	}
+	var img image.Image
+	r := image.Rect(0, 0, frame.width, frame.height)
+	switch frame.transmission_format {
+	case graphics.GRT_format_rgba:
+		img = &image.NRGBA{Pix: data, Stride: 4 * frame.width, Rect: r}
+	case graphics.GRT_format_rgb:
+		img = &images.NRGB{Pix: data, Stride: 3 * frame.width, Rect: r}
+	default:
+		if img, err = png.Decode(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("Failed to decode PNG image data: %w", err)
+		}
+	}
+	sixel := graphics.EncodeSixel(img)
+	if place != nil {
+		sixel = loop.SAVE_CURSOR + sixel + loop.RESTORE_CURSOR
+	}
+	_, err = os.Stdout.WriteString(sixel)
+	return
+}
+
 func calculate_in_cell_x_offset(width, cell_width int) int {
 	extra_pixels := width % cell_width
 	if extra_pixels == 0 {
@@ -218,6 +259,7 @@ func transmit_image(imgd *image_data) {
 		}
 	}()
 	var f func(*image_data, int, *image_frame) error
+	is_sixel := opts.TransferMode == "sixel" || (opts.TransferMode == "detect" && transfer_by_sixel == supported)
 	if opts.TransferMode != "detect" {
 		switch opts.TransferMode {
 		case "file":
@@ -226,8 +268,13 @@ func transmit_image(imgd *image_data) {
 			f = transmit_shm
 		case "stream":
 			f = transmit_stream
+		case "sixel":
+			f = transmit_sixel
 		}
 	}
+	if f == nil && transfer_by_sixel == supported {
+		f = transmit_sixel
+	}
 	if f == nil && transfer_by_memory == supported && imgd.frames[0].in_memory_bytes != nil {
 		f = transmit_shm
 	}
@@ -237,7 +284,7 @@ func transmit_image(imgd *image_data) {
 	if f == nil {
 		f = transmit_stream
 	}
-	if len(imgd.frames) > 1 {
+	if len(imgd.frames) > 1 && !is_sixel {
 		for imgd.image_number == 0 {
 			imgd.image_number = rand.Uint32()
 		}
@@ -252,7 +299,7 @@ func transmit_image(imgd *image_data) {
 	}
 	frame_control_cmd := graphics.GraphicsCommand{}
 	frame_control_cmd.SetAction(graphics.GRT_action_animate).SetImageNumber(imgd.image_number)
-	is_animated := len(imgd.frames) > 1
+	is_animated := len(imgd.frames) > 1 && !is_sixel
 
 	for frame_num, frame := range imgd.frames {
 		err := f(imgd, frame_num, frame)
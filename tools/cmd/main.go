@@ -3,9 +3,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+
 	"kitty/tools/cli"
 	"kitty/tools/cmd/completion"
 	"kitty/tools/cmd/tool"
+	"kitty/tools/utils"
 )
 
 func main() {
@@ -20,5 +24,26 @@ func main() {
 	tool.KittyToolEntryPoints(root)
 	completion.EntryPoint(root)
 
+	// the directories are needed to load the config, before the command
+	// line is parsed
+	overrides := root.PeekOptionValues(os.Args)
+	if q := overrides["ConfigDir"]; q != "" {
+		utils.SetConfigDir(q)
+	}
+	if q := overrides["CacheDir"]; q != "" {
+		utils.SetCacheDir(q)
+	}
+	if q := overrides["RuntimeDir"]; q != "" {
+		utils.SetRuntimeDir(q)
+	}
+	if err := root.LoadConfig(filepath.Join(utils.ConfigDir(), "kittens.conf")); err != nil {
+		cli.ShowError(err)
+		os.Exit(1)
+	}
+	if err := root.LoadEnv(); err != nil {
+		cli.ShowError(err)
+		os.Exit(1)
+	}
+
 	root.Exec()
 }
@@ -8,6 +8,7 @@ import (
 	"kitty/tools/cli"
 	"kitty/tools/cmd/at"
 	"kitty/tools/cmd/clipboard"
+	"kitty/tools/cmd/clipboard_history"
 	"kitty/tools/cmd/edit_in_kitty"
 	"kitty/tools/cmd/icat"
 	"kitty/tools/cmd/update_self"
@@ -19,6 +20,12 @@ var _ = fmt.Print
 func KittyToolEntryPoints(root *cli.Command) {
 	root.Add(cli.OptionSpec{
 		Name: "--version", Type: "bool-set", Help: "The current kitty version."})
+	root.Add(cli.OptionSpec{
+		Name: "--config-dir", Help: "The directory to read configuration from instead of the kitty config directory. Must be specified before the name of the kitten. Can also be set with the :envvar:`KITTY_CONFIG_DIRECTORY` environment variable."})
+	root.Add(cli.OptionSpec{
+		Name: "--cache-dir", Help: "The directory to store cached data, such as histories, in instead of the kitty cache directory. Must be specified before the name of the kitten. Can also be set with the :envvar:`KITTY_CACHE_DIRECTORY` environment variable."})
+	root.Add(cli.OptionSpec{
+		Name: "--runtime-dir", Help: "The directory to use for sockets and other temporary files instead of the kitty runtime directory. Must be specified before the name of the kitten. Can also be set with the :envvar:`KITTY_RUNTIME_DIRECTORY` environment variable."})
 	// @
 	at.EntryPoint(root)
 	// update-self
@@ -27,6 +34,8 @@ func KittyToolEntryPoints(root *cli.Command) {
 	edit_in_kitty.EntryPoint(root)
 	// clipboard
 	clipboard.EntryPoint(root)
+	// clipboard-history
+	clipboard_history.EntryPoint(root)
 	// icat
 	icat.EntryPoint(root)
 	// __hold_till_enter__
@@ -39,4 +48,26 @@ func KittyToolEntryPoints(root *cli.Command) {
 			return
 		},
 	})
+	// __generate_man__
+	root.AddSubCommand(&cli.Command{
+		Name:   "__generate_man__",
+		Hidden: true,
+		Usage:  "[output directory]",
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			output_dir := "."
+			if len(args) > 0 {
+				output_dir = args[0]
+			}
+			_, err = cmd.Root().GenerateManPages(output_dir)
+			return
+		},
+	})
+	// __verify_examples__
+	root.AddSubCommand(&cli.Command{
+		Name:   "__verify_examples__",
+		Hidden: true,
+		Run: func(cmd *cli.Command, args []string) (rc int, err error) {
+			return 0, cmd.Root().VerifyExamples()
+		},
+	})
 }
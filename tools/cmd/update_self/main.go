@@ -74,9 +74,13 @@ func EntryPoint(root *cli.Command) *cli.Command {
 		Usage:            "update-self [options ...]",
 		ShortDescription: "Update this kitten binary",
 		HelpText:         "Update this kitten binary in place to the latest available version.",
+		Examples: []cli.Example{
+			{CommandLine: "--fetch-version nightly", Description: "Update to the latest nightly build."},
+			{CommandLine: "--fetch-version 0.27.1", Description: "Switch to a specific version."},
+		},
 		Run: func(cmd *cli.Command, args []string) (ret int, err error) {
 			if len(args) != 0 {
-				return 1, fmt.Errorf("No command line arguments are allowed")
+				return cli.ExitCodeUsage, &cli.ExitError{Code: cli.ExitCodeUsage, Message: "No command line arguments are allowed"}
 			}
 			opts := &Options{}
 			err = cmd.GetOptionValues(opts)
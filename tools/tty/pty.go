@@ -0,0 +1,48 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tty
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// Open a new pseudo-terminal, returning its master side and the path to its
+// slave side, which should be opened by the child process
+func OpenPty() (master *os.File, slave_name string, err error) {
+	fd, err := open_ptmx()
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to open a pseudo-terminal with error: %w", err)
+	}
+	if slave_name, err = unlock_and_get_slave_name(fd); err != nil {
+		unix.Close(fd)
+		return nil, "", fmt.Errorf("Failed to setup a pseudo-terminal with error: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "/dev/ptmx"), slave_name, nil
+}
+
+// Open both sides of a new pseudo-terminal, with the slave side having the
+// specified size
+func OpenPtyPair(rows, cols uint16) (master, slave *os.File, err error) {
+	master, slave_name, err := OpenPty()
+	if err != nil {
+		return
+	}
+	if err = SetPtySize(master, rows, cols); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	if slave, err = os.OpenFile(slave_name, os.O_RDWR|unix.O_NOCTTY, 0); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return
+}
+
+func SetPtySize(master *os.File, rows, cols uint16) error {
+	return unix.IoctlSetWinsize(int(master.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+}
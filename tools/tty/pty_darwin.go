@@ -0,0 +1,26 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tty
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func open_ptmx() (int, error) {
+	return unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+}
+
+func unlock_and_get_slave_name(fd int) (string, error) {
+	for _, req := range []uint{unix.TIOCPTYGRANT, unix.TIOCPTYUNLK} {
+		if err := unix.IoctlSetInt(fd, req, 0); err != nil {
+			return "", err
+		}
+	}
+	var buf [128]byte
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+		return "", errno
+	}
+	return unix.ByteSliceToString(buf[:]), nil
+}
@@ -0,0 +1,18 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+//go:build openbsd || netbsd || dragonfly
+// +build openbsd netbsd dragonfly
+
+package tty
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func open_ptmx() (int, error) {
+	return -1, fmt.Errorf("Creating pseudo-terminals is not supported on %s", runtime.GOOS)
+}
+
+func unlock_and_get_slave_name(fd int) (string, error) {
+	return "", fmt.Errorf("Creating pseudo-terminals is not supported on %s", runtime.GOOS)
+}
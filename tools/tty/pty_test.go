@@ -0,0 +1,46 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tty
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func TestPty(t *testing.T) {
+	master, slave, err := OpenPtyPair(24, 80)
+	if err != nil {
+		t.Skipf("Pseudo-terminals are not available: %s", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+	check_size := func(rows, cols uint16) {
+		ws, err := unix.IoctlGetWinsize(int(slave.Fd()), unix.TIOCGWINSZ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ws.Row != rows || ws.Col != cols {
+			t.Fatalf("Incorrect size: %dx%d != %dx%d", ws.Row, ws.Col, rows, cols)
+		}
+	}
+	check_size(24, 80)
+	if err = SetPtySize(master, 10, 20); err != nil {
+		t.Fatal(err)
+	}
+	check_size(10, 20)
+	if _, err = slave.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := master.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Fatalf("Unexpected output from pseudo-terminal: %#v", string(buf[:n]))
+	}
+}
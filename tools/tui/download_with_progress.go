@@ -115,11 +115,11 @@ func DownloadFileWithProgress(destpath, url string, kill_if_signaled bool) (err
 	}
 
 	redraw := func() {
-		lp.StartAtomicUpdate()
+		lp.BeginFrame()
 		lp.AllowLineWrapping(false)
 		defer func() {
 			lp.AllowLineWrapping(true)
-			lp.EndAtomicUpdate()
+			lp.EndFrame()
 		}()
 		lp.QueueWriteString("\r")
 		lp.ClearToEndOfLine()
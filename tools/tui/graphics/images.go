@@ -0,0 +1,358 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package graphics
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/utils/shm"
+
+	"github.com/disintegration/imaging"
+)
+
+var _ = fmt.Print
+
+type shm_support int
+
+const (
+	shm_support_unknown shm_support = iota
+	shm_supported
+	shm_unsupported
+)
+
+// How the terminal can display images
+type GraphicsSupport int
+
+const (
+	// Support has not been detected, images are sent using the kitty
+	// graphics protocol
+	GRAPHICS_SUPPORT_UNKNOWN GraphicsSupport = iota
+	KITTY_GRAPHICS_PROTOCOL
+	SIXEL_GRAPHICS
+	NO_GRAPHICS
+)
+
+func (self GraphicsSupport) String() string {
+	switch self {
+	case KITTY_GRAPHICS_PROTOCOL:
+		return "kitty"
+	case SIXEL_GRAPHICS:
+		return "sixel"
+	case NO_GRAPHICS:
+		return "none"
+	}
+	return "unknown"
+}
+
+var ErrGraphicsNotSupported = errors.New("The terminal does not support displaying images")
+
+// Where and how large to show an image, in cells. The position is zero
+// based and relative to the top left corner of the screen. When Columns or
+// Rows are zero, the image is shown at its natural size.
+type Placement struct {
+	X, Y, Columns, Rows int
+	ZIndex              int32
+}
+
+// Transmit images to the terminal and show them, for use in kittens built on
+// loop.Loop. Image data is sent in shared memory when the terminal can read
+// it, which means it is running on the same computer, otherwise it is sent
+// as chunked, compressed, base64 encoded escape codes. In terminals that do
+// not support the kitty graphics protocol, but support sixel graphics, images
+// are drawn as sixels instead, see DetectSupport. Call HandleEscapeCode from
+// the OnEscapeCode handler of the loop to process the responses from the
+// terminal and Cleanup in OnFinalize to free the images.
+type ImageManager struct {
+	// Called with the error message from the terminal when transmitting or
+	// showing an image fails
+	OnError func(image_id uint32, msg string) error
+
+	// Called once DetectSupport has found out how the terminal can display
+	// images, for example, to redraw the screen
+	OnSupportDetected func(support GraphicsSupport) error
+
+	w                  io.StringWriter
+	screen_size        func() (loop.ScreenSize, error)
+	shm                shm_support
+	shm_query_id       uint32
+	shm_query_data     shm.MMap
+	support            GraphicsSupport
+	support_query_id   uint32
+	detecting_support  bool
+	image_id_counter   uint32
+	images             map[uint32]bool
+	sixel_image_pixels map[uint32]*image.NRGBA
+}
+
+func NewImageManager(lp *loop.Loop) *ImageManager {
+	return new_image_manager(&loop_io_writer{lp}, lp.ScreenSize)
+}
+
+func new_image_manager(w io.StringWriter, screen_size func() (loop.ScreenSize, error)) *ImageManager {
+	return &ImageManager{w: w, screen_size: screen_size, images: make(map[uint32]bool), sixel_image_pixels: make(map[uint32]*image.NRGBA)}
+}
+
+// How the terminal can display images, GRAPHICS_SUPPORT_UNKNOWN until
+// detection is complete
+func (self *ImageManager) Support() GraphicsSupport {
+	return self.support
+}
+
+// Query the terminal for support for the kitty graphics protocol, falling
+// back to sixel graphics and then to not showing images at all when it is not
+// supported. Also detects support for shared memory, see
+// DetectSharedMemory. Until detection is complete, images are sent using the
+// kitty graphics protocol. Must be called when the loop is running, for
+// example in OnInitialize.
+func (self *ImageManager) DetectSupport() {
+	if self.detecting_support || self.support != GRAPHICS_SUPPORT_UNKNOWN {
+		return
+	}
+	self.detecting_support = true
+	self.support_query_id = self.next_image_id()
+	gc := GraphicsCommand{}
+	gc.SetAction(GRT_action_query).SetImageId(self.support_query_id).SetFormat(GRT_format_rgb).SetDataWidth(1).SetDataHeight(1)
+	gc.WriteWithPayloadTo(self.w, []byte{1, 2, 3})
+	self.DetectSharedMemory()
+	// terminals respond to the primary device attributes query after
+	// responding to the queries before it, so the response means detection
+	// is complete. It includes 4 when sixel graphics are supported.
+	self.w.WriteString("\x1b[c")
+}
+
+func (self *ImageManager) handle_device_attributes(csi string) (bool, error) {
+	if !self.detecting_support || len(csi) < 2 || csi[0] != '?' || csi[len(csi)-1] != 'c' {
+		return false, nil
+	}
+	self.detecting_support = false
+	if self.support == GRAPHICS_SUPPORT_UNKNOWN {
+		self.support = NO_GRAPHICS
+		if utils.Contains(strings.Split(csi[1:len(csi)-1], ";"), "4") {
+			self.support = SIXEL_GRAPHICS
+		}
+	}
+	if self.OnSupportDetected != nil {
+		return true, self.OnSupportDetected(self.support)
+	}
+	return true, nil
+}
+
+func (self *ImageManager) next_image_id() uint32 {
+	// ids used by other programs are usually small, start far from them
+	self.image_id_counter++
+	return self.image_id_counter + (1 << 24)
+}
+
+// Query the terminal for support for transmitting images in shared memory.
+// Until the response arrives, images are sent as escape codes. Must be called
+// when the loop is running, for example in OnInitialize.
+func (self *ImageManager) DetectSharedMemory() {
+	if self.shm != shm_support_unknown || self.shm_query_id != 0 {
+		return
+	}
+	payload := []byte{1, 2, 3}
+	m, err := shm.CreateTemp("tty-graphics-*", uint64(len(payload)))
+	if err != nil {
+		self.shm = shm_unsupported
+		return
+	}
+	copy(m.Slice(), payload)
+	self.shm_query_id, self.shm_query_data = self.next_image_id(), m
+	gc := GraphicsCommand{}
+	gc.SetAction(GRT_action_query).SetTransmission(GRT_transmission_sharedmem).SetImageId(self.shm_query_id)
+	gc.SetFormat(GRT_format_rgb).SetDataWidth(1).SetDataHeight(1).SetDataSize(uint64(len(payload)))
+	gc.WriteWithPayloadTo(self.w, utils.UnsafeStringToBytes(m.Name()))
+}
+
+// Process responses from the terminal, returns true if data was a response
+// to a command sent by this manager
+func (self *ImageManager) HandleEscapeCode(etype loop.EscapeCodeType, data []byte) (bool, error) {
+	if etype == loop.CSI {
+		return self.handle_device_attributes(string(data))
+	}
+	if etype != loop.APC {
+		return false, nil
+	}
+	gc := GraphicsCommandFromAPC(data)
+	if gc == nil {
+		return false, nil
+	}
+	id := gc.ImageId()
+	if self.support_query_id != 0 && id == self.support_query_id {
+		self.support_query_id = 0
+		if gc.ResponseMessage() == "OK" {
+			self.support = KITTY_GRAPHICS_PROTOCOL
+		}
+		return true, nil
+	}
+	if self.shm_query_id != 0 && id == self.shm_query_id {
+		if gc.ResponseMessage() == "OK" {
+			self.shm = shm_supported
+		} else {
+			// the terminal did not read the data, so it is not unlinked
+			self.shm = shm_unsupported
+			self.shm_query_data.Unlink()
+		}
+		self.shm_query_data.Close()
+		self.shm_query_id, self.shm_query_data = 0, nil
+		return true, nil
+	}
+	if !self.images[id] {
+		return false, nil
+	}
+	if msg := gc.ResponseMessage(); msg != "OK" && self.OnError != nil {
+		return true, self.OnError(id, msg)
+	}
+	return true, nil
+}
+
+// Send the image to the terminal without showing it, returning the id to
+// use with Place and Delete
+func (self *ImageManager) Transmit(img image.Image) (image_id uint32, err error) {
+	rgba := as_nrgba(img)
+	return self.TransmitPixels(rgba.Pix, rgba.Rect.Dx(), rgba.Rect.Dy())
+}
+
+// Send image data in 32-bit RGBA format, with the rows stored contiguously,
+// to the terminal without showing it
+func (self *ImageManager) TransmitPixels(pix []byte, width, height int) (image_id uint32, err error) {
+	if width <= 0 || height <= 0 || len(pix) != 4*width*height {
+		return 0, fmt.Errorf("Invalid image data of size: %d for an image of %dx%d pixels", len(pix), width, height)
+	}
+	switch self.support {
+	case NO_GRAPHICS:
+		return 0, ErrGraphicsNotSupported
+	case SIXEL_GRAPHICS:
+		// sixel images are sent when they are placed
+		image_id = self.next_image_id()
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		copy(img.Pix, pix)
+		self.sixel_image_pixels[image_id] = img
+		self.images[image_id] = true
+		return
+	}
+	image_id = self.next_image_id()
+	gc := GraphicsCommand{}
+	gc.SetAction(GRT_action_transmit).SetQuiet(GRT_quiet_only_errors).SetImageId(image_id)
+	gc.SetFormat(GRT_format_rgba).SetDataWidth(uint64(width)).SetDataHeight(uint64(height))
+	if self.shm == shm_supported {
+		m, serr := shm.CreateTemp("tty-graphics-*", uint64(len(pix)))
+		if serr == nil {
+			copy(m.Slice(), pix)
+			gc.SetTransmission(GRT_transmission_sharedmem).SetDataSize(uint64(len(pix)))
+			if err = gc.WriteWithPayloadTo(self.w, utils.UnsafeStringToBytes(m.Name())); err != nil {
+				// the terminal never got the name, so it will not unlink it
+				m.Unlink()
+				m.Close()
+				return 0, err
+			}
+			// the terminal unlinks the shared memory once it has read it
+			m.Close()
+			self.images[image_id] = true
+			return
+		}
+		var ens *shm.ErrNotSupported
+		if errors.As(serr, &ens) {
+			self.shm = shm_unsupported
+		}
+	}
+	if err = gc.WriteWithPayloadTo(self.w, pix); err != nil {
+		return 0, err
+	}
+	self.images[image_id] = true
+	return
+}
+
+// Show a previously transmitted image. Every placement of an image needs a
+// distinct non-zero placement_id, placing an image again with the same
+// placement_id moves it. The cursor is moved to the top left corner of the
+// placement and is not moved by the image. Sixel images are drawn into the
+// cells they cover, so they cannot be moved or deleted, instead redraw
+// the text in those cells. They are clipped to the bottom of the screen and
+// ZIndex is ignored for them.
+func (self *ImageManager) Place(image_id, placement_id uint32, p Placement) error {
+	if !self.images[image_id] {
+		return fmt.Errorf("No image with id: %d has been transmitted", image_id)
+	}
+	if _, err := self.w.WriteString(fmt.Sprintf(loop.MoveCursorToTemplate, p.Y+1, p.X+1)); err != nil {
+		return err
+	}
+	if img := self.sixel_image_pixels[image_id]; img != nil {
+		return self.place_sixel(img, p)
+	}
+	gc := GraphicsCommand{}
+	gc.SetAction(GRT_action_display).SetQuiet(GRT_quiet_only_errors).SetImageId(image_id).SetPlacementId(placement_id)
+	gc.SetCursorMovement(GRT_cursor_static).SetZIndex(p.ZIndex)
+	if p.Columns > 0 {
+		gc.SetColumns(uint64(p.Columns))
+	}
+	if p.Rows > 0 {
+		gc.SetRows(uint64(p.Rows))
+	}
+	return gc.WriteWithPayloadTo(self.w, nil)
+}
+
+func (self *ImageManager) place_sixel(img *image.NRGBA, p Placement) error {
+	sz, err := self.screen_size()
+	if err != nil {
+		return err
+	}
+	cw, ch := int(sz.CellWidth), int(sz.CellHeight)
+	var scaled image.Image = img
+	if (p.Columns > 0 || p.Rows > 0) && cw > 0 && ch > 0 {
+		scaled = imaging.Resize(img, p.Columns*cw, p.Rows*ch, imaging.Lanczos)
+	}
+	if max_height := (int(sz.HeightCells) - p.Y) * ch; ch > 0 && scaled.Bounds().Dy() > max_height {
+		if max_height <= 0 {
+			return nil
+		}
+		// drawing past the bottom of the screen would scroll it
+		scaled = imaging.Crop(scaled, image.Rect(0, 0, scaled.Bounds().Dx(), max_height))
+	}
+	_, err = self.w.WriteString(loop.SAVE_CURSOR + EncodeSixel(scaled) + loop.RESTORE_CURSOR)
+	return err
+}
+
+// Remove a placement of an image from the screen, keeping the image data in
+// the terminal so that it can be placed again
+func (self *ImageManager) DeletePlacement(image_id, placement_id uint32) error {
+	if self.sixel_image_pixels[image_id] != nil {
+		return nil
+	}
+	gc := GraphicsCommand{}
+	gc.SetAction(GRT_action_delete).SetQuiet(GRT_quiet_silent).SetDelete(GRT_delete_by_id).SetImageId(image_id).SetPlacementId(placement_id)
+	return gc.WriteWithPayloadTo(self.w, nil)
+}
+
+// Remove all placements of an image and free its data in the terminal
+func (self *ImageManager) Delete(image_id uint32) error {
+	if !self.images[image_id] {
+		return nil
+	}
+	delete(self.images, image_id)
+	if self.sixel_image_pixels[image_id] != nil {
+		delete(self.sixel_image_pixels, image_id)
+		return nil
+	}
+	gc := GraphicsCommand{}
+	gc.SetAction(GRT_action_delete).SetQuiet(GRT_quiet_silent).SetDelete(GRT_free_by_id).SetImageId(image_id)
+	return gc.WriteWithPayloadTo(self.w, nil)
+}
+
+// Delete all images transmitted by this manager
+func (self *ImageManager) Cleanup() {
+	for image_id := range self.images {
+		self.Delete(image_id)
+	}
+	if self.shm_query_data != nil {
+		self.shm_query_data.Unlink()
+		self.shm_query_data.Close()
+		self.shm_query_data = nil
+	}
+}
@@ -0,0 +1,251 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package graphics
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"strings"
+	"testing"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils/shm"
+)
+
+var _ = fmt.Print
+
+func TestImageManager(t *testing.T) {
+	var out strings.Builder
+	m := new_image_manager(&out, func() (loop.ScreenSize, error) { return loop.ScreenSize{}, nil })
+	var payload string
+	commands := func() (ans []*GraphicsCommand) {
+		for _, x := range strings.Split(out.String(), "\x1b\\") {
+			if idx := strings.Index(x, "\x1b_G"); idx > -1 {
+				ans = append(ans, GraphicsCommandFromAPC([]byte(x[idx+2:])))
+				if _, p, found := strings.Cut(x, ";"); found {
+					data, _ := base64.StdEncoding.DecodeString(p)
+					payload = string(data)
+				}
+			}
+		}
+		out.Reset()
+		return
+	}
+	// unlink shared memory, as the terminal does after reading it
+	unlink := func() {
+		if mmap, err := shm.Open(payload, 0); err == nil {
+			mmap.Unlink()
+			mmap.Close()
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(1, 0, color.NRGBA{1, 2, 3, 4})
+	// sub-images are copied before transmission
+	id, err := m.Transmit(img.SubImage(image.Rect(1, 0, 2, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := commands()
+	if len(cmds) != 1 {
+		t.Fatalf("Unexpected output for transmit: %#v", cmds)
+	}
+	gc := cmds[0]
+	if gc.ImageId() != id || gc.Action() != GRT_action_transmit || gc.Transmission() != GRT_transmission_direct || gc.DataWidth() != 1 || gc.DataHeight() != 1 {
+		t.Fatalf("Unexpected transmit command: %s", gc)
+	}
+	if _, err = m.TransmitPixels([]byte{1, 2, 3}, 1, 1); err == nil {
+		t.Fatalf("Transmitting pixel data of the wrong size did not fail")
+	}
+
+	if err = m.Place(id, 7, Placement{X: 2, Y: 3, Columns: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "\x1b[4;3H") {
+		t.Fatalf("The cursor was not moved before placing the image: %#v", out.String())
+	}
+	cmds = commands()
+	gc = cmds[0]
+	if gc.Action() != GRT_action_display || gc.ImageId() != id || gc.PlacementId() != 7 || gc.Columns() != 4 || gc.Rows() != 0 || gc.CursorMovement() != GRT_cursor_static {
+		t.Fatalf("Unexpected place command: %s", gc)
+	}
+	if err = m.Place(id+1, 1, Placement{}); err == nil {
+		t.Fatalf("Placing an unknown image did not fail")
+	}
+
+	var errors []string
+	m.OnError = func(image_id uint32, msg string) error {
+		errors = append(errors, fmt.Sprintf("%d:%s", image_id, msg))
+		return nil
+	}
+	response := func(image_id uint32, msg string) bool {
+		handled, err := m.HandleEscapeCode(loop.APC, []byte(fmt.Sprintf("Gi=%d;%s", image_id, msg)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handled
+	}
+	if !response(id, "ENOSPC:out of space") || len(errors) != 1 || errors[0] != fmt.Sprintf("%d:ENOSPC:out of space", id) {
+		t.Fatalf("Error response not handled: %#v", errors)
+	}
+	if response(1, "OK") {
+		t.Fatalf("Response for an image not transmitted by the manager was handled")
+	}
+
+	m.Delete(id)
+	cmds = commands()
+	if len(cmds) != 1 || cmds[0].Delete() != GRT_free_by_id || cmds[0].ImageId() != id {
+		t.Fatalf("Unexpected delete command: %#v", cmds)
+	}
+	m.Cleanup()
+	if out.Len() > 0 {
+		t.Fatalf("Deleted image was deleted again by Cleanup: %#v", out.String())
+	}
+
+	m.DetectSharedMemory()
+	cmds = commands()
+	if m.shm == shm_unsupported {
+		return
+	}
+	if len(cmds) != 1 || cmds[0].Action() != GRT_action_query || cmds[0].Transmission() != GRT_transmission_sharedmem {
+		t.Fatalf("Unexpected shared memory query: %#v", cmds)
+	}
+	unlink()
+	if !response(cmds[0].ImageId(), "OK") || m.shm != shm_supported {
+		t.Fatalf("Response to shared memory query not handled")
+	}
+	if id, err = m.TransmitPixels([]byte{1, 2, 3, 4}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	cmds = commands()
+	unlink()
+	if cmds[0].Transmission() != GRT_transmission_sharedmem || cmds[0].DataSize() != 4 {
+		t.Fatalf("Image not transmitted in shared memory: %s", cmds[0])
+	}
+}
+
+// Fails to write the end of escape codes, as when the terminal goes away in
+// the middle of a write
+type failing_writer struct {
+	strings.Builder
+}
+
+func (self *failing_writer) WriteString(s string) (int, error) {
+	if s == "\x1b\\" {
+		return 0, errors.New("write failed")
+	}
+	return self.Builder.WriteString(s)
+}
+
+func TestTransmitFailure(t *testing.T) {
+	var out failing_writer
+	m := new_image_manager(&out, func() (loop.ScreenSize, error) { return loop.ScreenSize{}, nil })
+	if _, err := m.TransmitPixels([]byte{1, 2, 3, 4}, 1, 1); err == nil {
+		t.Fatalf("Failure to write the image was not reported")
+	}
+	if len(m.images) != 0 {
+		t.Fatalf("Image recorded even though it was not transmitted: %#v", m.images)
+	}
+
+	probe, err := shm.CreateTemp("tty-graphics-*", 4)
+	if err != nil {
+		return
+	}
+	probe.Unlink()
+	probe.Close()
+	m.shm = shm_supported
+	out.Reset()
+	if _, err = m.TransmitPixels([]byte{1, 2, 3, 4}, 1, 1); err == nil {
+		t.Fatalf("Failure to write the image was not reported")
+	}
+	if len(m.images) != 0 {
+		t.Fatalf("Image recorded even though it was not transmitted: %#v", m.images)
+	}
+	_, payload, _ := strings.Cut(out.String(), ";")
+	name, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(name) == 0 {
+		t.Fatalf("Name of the shared memory not written: %#v", out.String())
+	}
+	if mmap, err := shm.Open(string(name), 0); err == nil {
+		mmap.Unlink()
+		mmap.Close()
+		t.Fatalf("The shared memory %s was not unlinked after failing to transmit it", string(name))
+	}
+}
+
+func TestSixelFallback(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	idx := color.Palette(palette.Plan9).Index(red)
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 7))
+	for y := 0; y < 7; y++ {
+		img.Set(0, y, red)
+	}
+	expected := fmt.Sprintf("\x1bP0;1;0q\"1;1;2;7#%d;2;100;0;0#%d~-#%d@\x1b\\", idx, idx, idx)
+	if actual := EncodeSixel(img); actual != expected {
+		t.Fatalf("Unexpected sixel encoding:\n%#v !=\n%#v", actual, expected)
+	}
+	img = image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	for x := 0; x < 5; x++ {
+		img.Set(x, 0, red)
+	}
+	if actual := EncodeSixel(img); !strings.HasSuffix(actual, fmt.Sprintf("#%d!5@\x1b\\", idx)) {
+		t.Fatalf("Repeated sixels not run length encoded: %#v", actual)
+	}
+
+	var out strings.Builder
+	m := new_image_manager(&out, func() (loop.ScreenSize, error) {
+		return loop.ScreenSize{WidthCells: 80, HeightCells: 24, CellWidth: 2, CellHeight: 3}, nil
+	})
+	var detected []GraphicsSupport
+	m.OnSupportDetected = func(s GraphicsSupport) error {
+		detected = append(detected, s)
+		return nil
+	}
+	m.DetectSupport()
+	if !strings.HasSuffix(out.String(), "\x1b[c") {
+		t.Fatalf("Device attributes not queried: %#v", out.String())
+	}
+	m.Cleanup()
+	out.Reset()
+	if handled, _ := m.HandleEscapeCode(loop.APC, []byte(fmt.Sprintf("Gi=%d;EINVAL:unknown", m.support_query_id))); !handled {
+		t.Fatal("Response to support query not handled")
+	}
+	if handled, _ := m.HandleEscapeCode(loop.CSI, []byte("?62;4;22c")); !handled || m.Support() != SIXEL_GRAPHICS || fmt.Sprint(detected) != "[sixel]" {
+		t.Fatalf("Sixel support not detected: %s", m.Support())
+	}
+	id, err := m.Transmit(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() > 0 {
+		t.Fatalf("Sixel image sent before being placed: %#v", out.String())
+	}
+	if err = m.Place(id, 1, Placement{X: 1, Y: 23, Columns: 1, Rows: 2}); err != nil {
+		t.Fatal(err)
+	}
+	// scaled to 2x6 pixels and clipped to the last line of the screen
+	if !strings.HasPrefix(out.String(), "\x1b[24;2H\x1b7\x1bP0;1;0q\"1;1;2;3") || !strings.HasSuffix(out.String(), "\x1b\\\x1b8") {
+		t.Fatalf("Unexpected sixel placement: %#v", out.String())
+	}
+	out.Reset()
+	m.DeletePlacement(id, 1)
+	m.Delete(id)
+	if out.Len() > 0 || len(m.sixel_image_pixels) > 0 {
+		t.Fatalf("Deleting sixel image sent data to the terminal: %#v", out.String())
+	}
+
+	m = new_image_manager(&out, nil)
+	m.DetectSupport()
+	m.Cleanup()
+	m.HandleEscapeCode(loop.CSI, []byte("?62;22c"))
+	if m.Support() != NO_GRAPHICS {
+		t.Fatalf("Lack of graphics support not detected: %s", m.Support())
+	}
+	if _, err = m.Transmit(img); err != ErrGraphicsNotSupported {
+		t.Fatalf("Transmitting image without graphics support did not fail: %v", err)
+	}
+}
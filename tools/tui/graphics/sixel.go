@@ -0,0 +1,125 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package graphics
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"strings"
+)
+
+var _ = fmt.Print
+
+// Copy img into an NRGBA image whose top left corner is the origin, unless it
+// already is one
+func as_nrgba(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	rgba, ok := img.(*image.NRGBA)
+	if !ok || rgba.Stride != 4*b.Dx() || b.Min != (image.Point{}) {
+		rgba = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	}
+	return rgba
+}
+
+func write_sixel_run(buf *strings.Builder, ch byte, count int) {
+	switch {
+	case count > 3:
+		fmt.Fprintf(buf, "!%d%c", count, ch)
+	default:
+		for ; count > 0; count-- {
+			buf.WriteByte(ch)
+		}
+	}
+}
+
+// Encode an image as sixel graphics, for terminals that do not support the
+// kitty graphics protocol. The colors are reduced to a fixed palette of 256
+// colors with dithering. Pixels that are more than half transparent are not
+// drawn, leaving the existing contents of the screen visible.
+func EncodeSixel(img image.Image) string {
+	src := as_nrgba(img)
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	pal := image.NewPaletted(src.Rect, palette.Plan9)
+	draw.FloydSteinberg.Draw(pal, pal.Rect, src, image.Point{})
+	is_opaque := func(x, y int) bool { return src.Pix[y*src.Stride+4*x+3] >= 0x80 }
+
+	buf := strings.Builder{}
+	// P2=1 means pixels with no color set are left unchanged
+	fmt.Fprintf(&buf, "\x1bP0;1;0q\"1;1;%d;%d", width, height)
+	used := make([]bool, len(palette.Plan9))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if is_opaque(x, y) {
+				used[pal.Pix[y*pal.Stride+x]] = true
+			}
+		}
+	}
+	for idx, is_used := range used {
+		if is_used {
+			r, g, b, _ := palette.Plan9[idx].RGBA()
+			// sixel color components are percentages
+			fmt.Fprintf(&buf, "#%d;2;%d;%d;%d", idx, (r*100+0x7fff)/0xffff, (g*100+0x7fff)/0xffff, (b*100+0x7fff)/0xffff)
+		}
+	}
+	// the bits for each color in the current band of six rows
+	var band [256][]byte
+	var in_band [256]bool
+	colors_in_band := make([]uint8, 0, 64)
+	for top := 0; top < height; top += 6 {
+		if top > 0 {
+			// move to the next band
+			buf.WriteByte('-')
+		}
+		for _, idx := range colors_in_band {
+			in_band[idx] = false
+		}
+		colors_in_band = colors_in_band[:0]
+		for y := top; y < top+6 && y < height; y++ {
+			bit := byte(1) << (y - top)
+			for x := 0; x < width; x++ {
+				if !is_opaque(x, y) {
+					continue
+				}
+				idx := pal.Pix[y*pal.Stride+x]
+				row := band[idx]
+				if row == nil {
+					row = make([]byte, width)
+					band[idx] = row
+				}
+				if !in_band[idx] {
+					in_band[idx] = true
+					colors_in_band = append(colors_in_band, idx)
+				}
+				row[x] |= bit
+			}
+		}
+		for i, idx := range colors_in_band {
+			if i > 0 {
+				// return to the start of the band for the next color
+				buf.WriteByte('$')
+			}
+			fmt.Fprintf(&buf, "#%d", idx)
+			row := band[idx]
+			end := width
+			for end > 0 && row[end-1] == 0 {
+				end--
+			}
+			for x := 0; x < end; {
+				run := 1
+				for x+run < end && row[x+run] == row[x] {
+					run++
+				}
+				write_sixel_run(&buf, '?'+row[x], run)
+				x += run
+			}
+			for x := range row {
+				row[x] = 0
+			}
+		}
+	}
+	buf.WriteString("\x1b\\")
+	return buf.String()
+}
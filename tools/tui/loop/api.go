@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"kitty/tools/tty"
+	"os"
+	"os/exec"
 	"strings"
 	"time"
 
@@ -20,6 +22,9 @@ type ScreenSize struct {
 }
 
 type IdType uint64
+
+// Called on the goroutine running the loop when a timer fires. Returning an
+// error stops the loop, with Run() returning the error.
 type TimerCallback func(timer_id IdType) error
 type EscapeCodeType int
 
@@ -36,6 +41,7 @@ type timer struct {
 	interval time.Duration
 	deadline time.Time
 	repeats  bool
+	removed  bool
 	id       IdType
 	callback TimerCallback
 }
@@ -44,10 +50,21 @@ func (self *timer) update_deadline(now time.Time) {
 	self.deadline = now.Add(self.interval)
 }
 
+// Schedule the next firing of a repeating timer relative to its previous
+// deadline so that it does not drift, skipping any firings that were missed
+func (self *timer) reschedule(now time.Time) {
+	self.deadline = self.deadline.Add(self.interval)
+	if self.deadline.Before(now) {
+		self.update_deadline(now)
+	}
+}
+
 type Loop struct {
 	controlling_term                       *tty.Term
 	terminal_options                       TerminalStateOptions
 	screen_size                            ScreenSize
+	virtual_screen_size                    *ScreenSize
+	screen_clipper                         *screen_clipper
 	escape_code_parser                     wcswidth.EscapeCodeParser
 	keep_going                             bool
 	death_signal                           unix.Signal
@@ -57,6 +74,26 @@ type Loop struct {
 	wakeup_channel                         chan byte
 	pending_writes                         []*write_msg
 	on_SIGTSTP                             func() error
+	suspend_and_run                        func(func() error) error
+	composer                               *Composer
+	color_scheme                           ColorScheme
+	subprocesses                           map[IdType]*subprocess
+	subprocess_id_counter                  IdType
+	subprocess_channel                     chan subprocess_event
+	subprocess_done_channel                chan struct{}
+	screen_buffer                          *ScreenBuffer
+	mouse_coordinates_in_pixels            bool
+	has_focus                              bool
+	max_paste_size, paste_size             int
+	paste_truncated                        bool
+	allow_control_chars_in_paste           bool
+	escape_code_handlers                   escape_code_handlers
+	capabilities_query                     *capabilities_query
+	frame_depth                            int
+	signal_channel                         chan os.Signal
+	signal_subscriptions                   []*signal_subscription
+	signal_subscription_id_counter         IdType
+	no_synchronized_output                 bool
 
 	// Send strings to this channel to queue writes in a thread safe way
 
@@ -74,9 +111,22 @@ type Loop struct {
 	OnKeyEvent func(event *KeyEvent) error
 
 	// Called when text is received either from a key event or directly from the terminal
-	// Called with an empty string when bracketed paste ends
+	// Called with an empty string when bracketed paste ends. Control characters
+	// other than tab and newlines are removed from pasted text, unless
+	// AllowControlCharsInPaste() is used, so that pastes cannot inject escape
+	// codes.
 	OnText func(text string, from_key_event bool, in_bracketed_paste bool) error
 
+	// Called when a bracketed paste starts, before any of the pasted text
+	// is sent to OnText
+	OnPasteStart func() error
+
+	// Called when a bracketed paste ends, before OnText is called with an
+	// empty string. size is the number of bytes that were pasted, truncated
+	// is true if text was dropped because the paste was larger than the limit
+	// set with MaxPasteSize()
+	OnPasteEnd func(size int, truncated bool) error
+
 	// Called when the terminal is resized
 	OnResize func(old_size ScreenSize, new_size ScreenSize) error
 
@@ -89,7 +139,9 @@ type Loop struct {
 	// Called when any input from tty is received
 	OnReceivedData func(data []byte) error
 
-	// Called when an escape code is received that is not handled by any other handler
+	// Called when an escape code is received that is not handled by any other
+	// handler, including those registered with RegisterOSCHandler(),
+	// RegisterDCSHandler() and RegisterAPCHandler()
 	OnEscapeCode func(EscapeCodeType, []byte) error
 
 	// Called when resuming from a SIGTSTP or Ctrl-z
@@ -97,24 +149,122 @@ type Loop struct {
 
 	// Called when main loop is woken up
 	OnWakeup func() error
+
+	// Called with the color scheme of the terminal when the loop starts and
+	// whenever it changes, for example, when the OS switches to dark mode.
+	// Notifications are only requested from the terminal if this is set
+	// before the loop is run.
+	OnColorSchemeChange func(scheme ColorScheme) error
+
+	// Called when a mouse event is received, needs mouse tracking to be
+	// turned on with MouseTrackingMode(). When not set, mouse events are
+	// delivered to OnEscapeCode.
+	OnMouseEvent func(event *MouseEvent) error
+
+	// Called when the terminal window gains or loses keyboard focus. Focus
+	// tracking is only turned on in the terminal if this is set before the
+	// loop is run.
+	OnFocusChange func(has_focus bool) error
+
+	// Called with output from a subprocess started with RunSubprocess()
+	OnSubprocessData func(id IdType, data []byte) error
+
+	// Called when a subprocess started with RunSubprocess() exits. err is nil
+	// if it exited with a zero exit status, otherwise it is usually an
+	// *exec.ExitError
+	OnSubprocessExit func(id IdType, err error) error
 }
 
 func New(options ...func(self *Loop)) (*Loop, error) {
 	l := new_loop()
+	l.apply_virtual_screen_size_from_env()
 	for _, f := range options {
 		f(l)
 	}
 	return l, nil
 }
 
+// Call callback after interval and, if repeats is true, every interval after
+// that, until the timer is removed. Callbacks are called on the goroutine
+// running the loop, so they can safely use the loop and any state shared with
+// other event handlers. Deadlines are measured with the monotonic clock, so
+// changes to the system time do not affect them. Timers can only be added
+// once the loop is running, for example, in OnInitialize. Returns an id that
+// can be used to remove the timer.
 func (self *Loop) AddTimer(interval time.Duration, repeats bool, callback TimerCallback) (IdType, error) {
 	return self.add_timer(interval, repeats, callback)
 }
 
+// Remove the specified timer, so that its callback is not called again. Safe
+// to call from timer callbacks, including for the timer being dispatched.
+// Returns false if no such timer exists, for example, because it was not a
+// repeating timer and has already fired.
 func (self *Loop) RemoveTimer(id IdType) bool {
 	return self.remove_timer(id)
 }
 
+// Run cmd in a new pseudo-terminal, as its controlling terminal, so that
+// interactive programs can be embedded in the UI without suspending the loop.
+// The output of cmd is delivered to OnSubprocessData and its exit status to
+// OnSubprocessExit, on the goroutine running the loop. The size of the
+// pseudo-terminal follows the size of the screen, unless it is set with
+// ResizeSubprocess(). cmd must not have been started and its standard input
+// and output are replaced. Subprocesses that are still running when the loop
+// exits are sent SIGHUP. Can only be called once the loop is running, for
+// example, in OnInitialize.
+func (self *Loop) RunSubprocess(cmd *exec.Cmd) (IdType, error) {
+	return self.run_subprocess(cmd)
+}
+
+// Send data to the subprocess, as if it was typed into its terminal. The data
+// is queued and written in the background, so this never blocks, even if the
+// subprocess is not reading its input. Data that has not been written when the
+// subprocess exits is discarded.
+func (self *Loop) WriteToSubprocess(id IdType, data []byte) error {
+	p, err := self.subprocess_for_id(id)
+	if err != nil {
+		return err
+	}
+	p.queue_input(data)
+	return nil
+}
+
+// Set the size of the terminal of the subprocess, for subprocesses shown in
+// only part of the screen. Once set, the size no longer follows the size of
+// the screen.
+func (self *Loop) ResizeSubprocess(id IdType, rows, cols uint) error {
+	p, err := self.subprocess_for_id(id)
+	if err != nil {
+		return err
+	}
+	p.fixed_size = true
+	return tty.SetPtySize(p.pty, uint16(rows), uint16(cols))
+}
+
+// The buffer to draw the user interface into, with the size of the screen.
+// It is resized and cleared automatically when the screen is resized, so
+// redraw it in OnResize. Use RenderScreenBuffer() to show its contents.
+func (self *Loop) ScreenBuffer() *ScreenBuffer {
+	if self.screen_buffer == nil {
+		sz, _ := self.ScreenSize()
+		self.screen_buffer = NewScreenBuffer(int(sz.WidthCells), int(sz.HeightCells))
+	}
+	return self.screen_buffer
+}
+
+// Send the changes to the contents of the screen buffer since it was last
+// rendered to the terminal, as a single atomic update
+func (self *Loop) RenderScreenBuffer() {
+	if self.screen_buffer == nil {
+		return
+	}
+	if changes := self.screen_buffer.Render(); changes != "" {
+		self.BeginFrame()
+		self.QueueWriteString(changes)
+		self.EndFrame()
+	}
+}
+
 func (self *Loop) NoAlternateScreen() *Loop {
 	self.terminal_options.alternate_screen = false
 	return self
@@ -151,6 +301,46 @@ func NoRestoreColors(self *Loop) {
 	self.terminal_options.restore_colors = false
 }
 
+// Drop the text of bracketed pastes after max_bytes bytes, a value <= 0 means
+// no limit. OnPasteEnd is told when a paste is truncated.
+func (self *Loop) MaxPasteSize(max_bytes int) *Loop {
+	self.max_paste_size = max_bytes
+	return self
+}
+
+// Send control characters in pasted text to OnText instead of removing them.
+// Only use this if the text is never written to the terminal as is.
+func (self *Loop) AllowControlCharsInPaste() *Loop {
+	self.allow_control_chars_in_paste = true
+	return self
+}
+
+func AllowControlCharsInPaste(self *Loop) {
+	self.allow_control_chars_in_paste = true
+}
+
+// Turn dead keys and compose key sequences into text, see Composer. Use an
+// empty compose_key to only interpret dead keys.
+func (self *Loop) InterpretComposeSequences(compose_key string) *Loop {
+	self.composer = &Composer{ComposeKey: compose_key}
+	return self
+}
+
+func InterpretComposeSequences(self *Loop) {
+	self.composer = &Composer{}
+}
+
+// The color scheme of the terminal, as last reported to OnColorSchemeChange
+func (self *Loop) ColorScheme() ColorScheme {
+	return self.color_scheme
+}
+
+// Whether the terminal window has keyboard focus, as last reported to
+// OnFocusChange. Assumed to be true until the terminal reports otherwise.
+func (self *Loop) HasFocus() bool {
+	return self.has_focus
+}
+
 func (self *Loop) DeathSignalName() string {
 	if self.death_signal != SIGNULL {
 		return self.death_signal.String()
@@ -212,6 +402,9 @@ func (self *Loop) WakeupMainThread() bool {
 }
 
 func (self *Loop) QueueWriteString(data string) IdType {
+	if self.screen_clipper != nil {
+		data = self.screen_clipper.clip_string(data)
+	}
 	self.write_msg_id_counter++
 	msg := write_msg{str: data, bytes: nil, id: self.write_msg_id_counter}
 	self.add_write_to_pending_queue(&msg)
@@ -221,6 +414,9 @@ func (self *Loop) QueueWriteString(data string) IdType {
 // This is dangerous as it is upto the calling code
 // to ensure the data in the underlying array does not change
 func (self *Loop) UnsafeQueueWriteBytes(data []byte) IdType {
+	if self.screen_clipper != nil {
+		data = self.screen_clipper.clip(data)
+	}
 	self.write_msg_id_counter++
 	msg := write_msg{bytes: data, id: self.write_msg_id_counter}
 	self.add_write_to_pending_queue(&msg)
@@ -241,12 +437,45 @@ func (self *Loop) Beep() {
 	self.QueueWriteString("\a")
 }
 
+// Start drawing a frame. The terminal shows the output queued until the
+// matching EndFrame() all at once, using synchronized output, preventing
+// tearing when redrawing. Frames can be nested, only the outermost frame is
+// synchronized. Synchronized output is not used once QueryCapabilities() has
+// found that the terminal does not support it.
+func (self *Loop) BeginFrame() {
+	self.frame_depth++
+	if self.frame_depth == 1 && !self.no_synchronized_output {
+		self.QueueWriteString(PENDING_UPDATE.EscapeCodeToSet())
+	}
+}
+
+// Finish drawing the frame started by BeginFrame()
+func (self *Loop) EndFrame() {
+	if self.frame_depth < 1 {
+		return
+	}
+	self.frame_depth--
+	if self.frame_depth == 0 && !self.no_synchronized_output {
+		self.QueueWriteString(PENDING_UPDATE.EscapeCodeToReset())
+	}
+}
+
+// Draw a frame by calling draw between BeginFrame() and EndFrame(), the
+// frame is ended even if draw fails
+func (self *Loop) DrawFrame(draw func() error) error {
+	self.BeginFrame()
+	defer self.EndFrame()
+	return draw()
+}
+
+// Same as BeginFrame()
 func (self *Loop) StartAtomicUpdate() {
-	self.QueueWriteString(PENDING_UPDATE.EscapeCodeToSet())
+	self.BeginFrame()
 }
 
+// Same as EndFrame()
 func (self *Loop) EndAtomicUpdate() {
-	self.QueueWriteString(PENDING_UPDATE.EscapeCodeToReset())
+	self.EndFrame()
 }
 
 func (self *Loop) SetCursorShape(shape CursorShapes, blink bool) {
@@ -323,3 +552,32 @@ func (self *Loop) Quit(exit_code int) {
 	self.exit_code = exit_code
 	self.keep_going = false
 }
+
+// Suspend the loop, restoring the terminal to the state it was in before the
+// loop started, and run the specified function, for example, to run an editor
+// in the terminal. The loop resumes when the function returns. Must be called
+// from one of the loop's callbacks.
+func (self *Loop) SuspendAndRun(callback func() error) error {
+	if self.suspend_and_run == nil {
+		return fmt.Errorf("Cannot suspend a loop that is not running")
+	}
+	return self.suspend_and_run(callback)
+}
+
+// Suspend the loop as SuspendAndRun() does and run cmd in the terminal,
+// waiting for it to exit. The standard input, output and error of cmd, when
+// not set, are those of this process.
+func (self *Loop) SuspendAndRunCommand(cmd *exec.Cmd) error {
+	return self.SuspendAndRun(func() error {
+		if cmd.Stdin == nil {
+			cmd.Stdin = os.Stdin
+		}
+		if cmd.Stdout == nil {
+			cmd.Stdout = os.Stdout
+		}
+		if cmd.Stderr == nil {
+			cmd.Stderr = os.Stderr
+		}
+		return cmd.Run()
+	})
+}
@@ -0,0 +1,156 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Features of the terminal, as reported by it in response to
+// QueryCapabilities()
+type Capabilities struct {
+	// True if the terminal responded to all the queries before the timeout,
+	// otherwise features whose queries were not answered are reported as
+	// unsupported
+	Complete bool
+	// 24-bit color, from the RGB or Tc terminfo capabilities or the
+	// COLORTERM environment variable
+	TrueColor bool
+	// The kitty keyboard protocol
+	KittyKeyboard bool
+	// The kitty graphics protocol
+	KittyGraphics bool
+	// Sixel graphics
+	Sixel bool
+	// Synchronized output, used by BeginFrame()
+	SynchronizedOutput bool
+}
+
+// An id for the graphics protocol query that is unlikely to be used by
+// images of kittens
+const capabilities_query_image_id = 0x7fff_fff3
+
+var truecolor_capabilities = []string{"RGB", "Tc"}
+
+type capabilities_query struct {
+	caps     Capabilities
+	callback func(Capabilities) error
+	timer_id IdType
+}
+
+func capabilities_query_escape_codes() string {
+	buf := strings.Builder{}
+	for _, name := range truecolor_capabilities {
+		// XTGETTCAP
+		buf.WriteString("\x1bP+q" + hex.EncodeToString([]byte(name)) + "\x1b\\")
+	}
+	// the flags of the kitty keyboard protocol
+	buf.WriteString("\x1b[?u")
+	// DECRQM for synchronized output
+	buf.WriteString("\x1b[?2026$p")
+	fmt.Fprintf(&buf, "\x1b_Gi=%d,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\", capabilities_query_image_id)
+	// DA1 is answered by all terminals and so is sent last to detect when all
+	// responses have been received
+	buf.WriteString("\x1b[c")
+	return buf.String()
+}
+
+// Process a response to one of the queries, returns whether the response was
+// consumed and whether it is the last response
+func (self *capabilities_query) handle(etype EscapeCodeType, raw []byte) (consumed, done bool) {
+	q := utils.UnsafeBytesToString(raw)
+	switch etype {
+	case CSI:
+		if !strings.HasPrefix(q, "?") {
+			return false, false
+		}
+		switch {
+		case strings.HasSuffix(q, "c"):
+			for _, x := range strings.Split(q[1:len(q)-1], ";") {
+				if x == "4" {
+					self.caps.Sixel = true
+				}
+			}
+			return true, true
+		case strings.HasPrefix(q, "?2026;") && strings.HasSuffix(q, "$y"):
+			// 0 is not recognized and 4 is permanently reset
+			status := q[len("?2026;") : len(q)-len("$y")]
+			self.caps.SynchronizedOutput = status == "1" || status == "2" || status == "3"
+			return true, false
+		case strings.HasSuffix(q, "u") && len(q) > 2 && strings.Trim(q[1:len(q)-1], "0123456789") == "":
+			self.caps.KittyKeyboard = true
+			return true, false
+		}
+	case DCS:
+		if len(q) >= 3 && q[1:3] == "+r" && (q[0] == '0' || q[0] == '1') {
+			name, _, _ := utils.Cut(q[3:], "=")
+			if b, err := hex.DecodeString(name); err == nil && q[0] == '1' && utils.Contains(truecolor_capabilities, string(b)) {
+				self.caps.TrueColor = true
+			}
+			return true, false
+		}
+	case APC:
+		if prefix := fmt.Sprintf("Gi=%d;", capabilities_query_image_id); strings.HasPrefix(q, prefix) {
+			self.caps.KittyGraphics = q[len(prefix):] == "OK"
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// Query the terminal for the features it supports, calling callback with
+// them once all the responses have been received or after the timeout. The
+// responses are not sent to any other handlers. Must be called while the loop
+// is running, for example, in OnInitialize. Only one query can be in
+// progress at a time.
+func (self *Loop) QueryCapabilities(timeout time.Duration, callback func(Capabilities) error) (err error) {
+	if self.capabilities_query != nil {
+		return fmt.Errorf("A query for terminal capabilities is already in progress")
+	}
+	q := &capabilities_query{callback: callback}
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		q.caps.TrueColor = true
+	}
+	if q.timer_id, err = self.AddTimer(timeout, false, func(IdType) error { return self.finish_capabilities_query(false) }); err != nil {
+		return err
+	}
+	self.capabilities_query = q
+	self.QueueWriteString(capabilities_query_escape_codes())
+	return nil
+}
+
+func (self *Loop) finish_capabilities_query(complete bool) error {
+	q := self.capabilities_query
+	if q == nil {
+		return nil
+	}
+	self.capabilities_query = nil
+	self.RemoveTimer(q.timer_id)
+	q.caps.Complete = complete
+	if complete {
+		self.no_synchronized_output = !q.caps.SynchronizedOutput
+	}
+	return q.callback(q.caps)
+}
+
+// Returns true if raw is a response to a pending capabilities query and
+// should not be processed further
+func (self *Loop) handle_capabilities_response(etype EscapeCodeType, raw []byte) (bool, error) {
+	if self.capabilities_query == nil {
+		return false, nil
+	}
+	consumed, done := self.capabilities_query.handle(etype, raw)
+	if done {
+		return true, self.finish_capabilities_query(true)
+	}
+	return consumed, nil
+}
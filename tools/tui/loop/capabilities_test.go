@@ -0,0 +1,120 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestQueryCapabilities(t *testing.T) {
+	t.Setenv("COLORTERM", "")
+	lp, _ := New()
+	lp.timers = make([]*timer, 0, 1)
+	var unhandled []string
+	lp.OnEscapeCode = func(etype EscapeCodeType, raw []byte) error {
+		unhandled = append(unhandled, string(raw))
+		return nil
+	}
+	var caps *Capabilities
+	query := func() {
+		caps = nil
+		if err := lp.QueryCapabilities(time.Second, func(c Capabilities) error { caps = &c; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if lp.QueryCapabilities(time.Second, nil) == nil {
+			t.Fatalf("Starting a second query did not fail")
+		}
+	}
+	test := func(input string, expected Capabilities) {
+		query()
+		if err := lp.escape_code_parser.ParseString(input); err != nil {
+			t.Fatal(err)
+		}
+		if caps == nil {
+			t.Fatalf("The query was not completed by: %#v", input)
+		}
+		if diff := cmp.Diff(expected, *caps); diff != "" {
+			t.Fatalf("Unexpected capabilities for %#v:\n%s", input, diff)
+		}
+		if len(lp.timers) != 0 || lp.capabilities_query != nil {
+			t.Fatalf("The query was not cleaned up")
+		}
+	}
+	test("\x1b[?62;22c", Capabilities{Complete: true})
+	test(fmt.Sprintf("\x1bP1+r524742\x1b\\\x1bP0+r\x1b\\\x1b[?1u\x1b[?2026;2$y\x1b_Gi=%d;OK\x1b\\\x1b[?62;4;22c", capabilities_query_image_id),
+		Capabilities{Complete: true, TrueColor: true, KittyKeyboard: true, KittyGraphics: true, Sixel: true, SynchronizedOutput: true})
+	test(fmt.Sprintf("\x1bP0+r524742\x1b\\\x1bP1+r5463\x1b\\\x1b[?2026;0$y\x1b_Gi=%d;ENOTSUPPORTED\x1b\\\x1b[?1c", capabilities_query_image_id),
+		Capabilities{Complete: true, TrueColor: true})
+	if len(unhandled) != 0 {
+		t.Fatalf("Responses were passed on to OnEscapeCode: %#v", unhandled)
+	}
+	// unrelated escape codes are processed as usual while the query is pending
+	query()
+	if err := lp.escape_code_parser.ParseString("\x1bP>|kitty\x1b\\\x1b_Gi=1;OK\x1b\\"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{">|kitty", "Gi=1;OK"}, unhandled); diff != "" {
+		t.Fatalf("Unrelated escape codes were not passed on:\n%s", diff)
+	}
+	if caps != nil {
+		t.Fatalf("The query was completed prematurely")
+	}
+	// a timeout reports the capabilities found so far
+	if err := lp.escape_code_parser.ParseString("\x1b[?15u"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.finish_capabilities_query(false); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(Capabilities{KittyKeyboard: true}, *caps); diff != "" {
+		t.Fatalf("Unexpected capabilities after timeout:\n%s", diff)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	lp, _ := New()
+	lp.timers = make([]*timer, 0, 1)
+	written := func() string {
+		ans := ""
+		for _, w := range lp.pending_writes {
+			ans += w.str
+		}
+		lp.pending_writes = nil
+		return ans
+	}
+	begin, end := PENDING_UPDATE.EscapeCodeToSet(), PENDING_UPDATE.EscapeCodeToReset()
+	lp.BeginFrame()
+	lp.QueueWriteString("a")
+	lp.BeginFrame()
+	lp.QueueWriteString("b")
+	lp.EndFrame()
+	lp.EndFrame()
+	lp.EndFrame()
+	if q := written(); q != begin+"ab"+end {
+		t.Fatalf("Nested frames not synchronized once: %#v", q)
+	}
+	err := lp.DrawFrame(func() error { lp.QueueWriteString("c"); return fmt.Errorf("failed") })
+	if q := written(); err == nil || q != begin+"c"+end || lp.frame_depth != 0 {
+		t.Fatalf("DrawFrame did not end the frame on error: %#v %v", q, err)
+	}
+	// synchronized output is not used when the terminal does not support it
+	if err := lp.QueryCapabilities(time.Second, func(Capabilities) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.escape_code_parser.ParseString("\x1b[?2026;0$y\x1b[?62c"); err != nil {
+		t.Fatal(err)
+	}
+	written()
+	lp.BeginFrame()
+	lp.QueueWriteString("d")
+	lp.EndFrame()
+	if q := written(); q != "d" {
+		t.Fatalf("Synchronized output used even though it is not supported: %#v", q)
+	}
+}
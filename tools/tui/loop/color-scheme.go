@@ -0,0 +1,40 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+type ColorScheme uint8
+
+const (
+	UNKNOWN_COLOR_SCHEME ColorScheme = iota
+	DARK_COLOR_SCHEME
+	LIGHT_COLOR_SCHEME
+)
+
+func (self ColorScheme) String() string {
+	switch self {
+	case DARK_COLOR_SCHEME:
+		return "dark"
+	case LIGHT_COLOR_SCHEME:
+		return "light"
+	}
+	return "unknown"
+}
+
+// Parse the reports terminals send in response to a query for the color
+// scheme and when it changes, of the form: ?997;1n for dark and ?997;2n for
+// light
+func color_scheme_from_csi(csi string) ColorScheme {
+	switch csi {
+	case "?997;1n":
+		return DARK_COLOR_SCHEME
+	case "?997;2n":
+		return LIGHT_COLOR_SCHEME
+	}
+	return UNKNOWN_COLOR_SCHEME
+}
@@ -0,0 +1,41 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestColorScheme(t *testing.T) {
+	for csi, expected := range map[string]ColorScheme{
+		"?997;1n": DARK_COLOR_SCHEME, "?997;2n": LIGHT_COLOR_SCHEME, "?997;3n": UNKNOWN_COLOR_SCHEME, "?996n": UNKNOWN_COLOR_SCHEME, "A": UNKNOWN_COLOR_SCHEME,
+	} {
+		if actual := color_scheme_from_csi(csi); actual != expected {
+			t.Fatalf("Parsing %#v gave %s instead of %s", csi, actual, expected)
+		}
+	}
+
+	var received []ColorScheme
+	lp, _ := New()
+	lp.OnColorSchemeChange = func(scheme ColorScheme) error {
+		received = append(received, scheme)
+		return nil
+	}
+	for _, csi := range []string{"?997;2n", "?997;1n", "5~"} {
+		if err := lp.handle_csi([]byte(csi)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(received) != "[light dark]" || lp.ColorScheme() != DARK_COLOR_SCHEME {
+		t.Fatalf("Unexpected color scheme notifications: %v current: %s", received, lp.ColorScheme())
+	}
+
+	opts := TerminalStateOptions{color_scheme_updates: true}
+	if !strings.Contains(opts.SetStateEscapeCodes(), "\033[?2031h\033[?996n") || !strings.Contains(opts.ResetStateEscapeCodes(), "\033[?2031l") {
+		t.Fatal("Color scheme updates not requested from the terminal")
+	}
+}
@@ -0,0 +1,205 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// Letters that can be combined with an accent, as the base letters and the
+// accented letters in the same order, keyed by the combining accent
+var accented_letters = map[rune][2]string{
+	0x0300: {"AEIOUaeiouNnWwYy", "ÀÈÌÒÙàèìòùǸǹẀẁỲỳ"},
+	0x0301: {"AEIOUYaeiouyCcLlNnRrSsZzGgKkMmPpWw", "ÁÉÍÓÚÝáéíóúýĆćĹĺŃńŔŕŚśŹźǴǵḰḱḾḿṔṕẂẃ"},
+	0x0302: {"AEIOUaeiouCcGgHhJjSsWwYyZz", "ÂÊÎÔÛâêîôûĈĉĜĝĤĥĴĵŜŝŴŵŶŷẐẑ"},
+	0x0303: {"ANOanoIiUuVvEeYy", "ÃÑÕãñõĨĩŨũṼṽẼẽỸỹ"},
+	0x0304: {"AaEeIiOoUuYyGg", "ĀāĒēĪīŌōŪūȲȳḠḡ"},
+	0x0306: {"AaEeGgIiOoUu", "ĂăĔĕĞğĬĭŎŏŬŭ"},
+	0x0307: {"CcEeGgIZzAaOoBbDdFfHhMmNnPpRrSsTtWwXxYy", "ĊċĖėĠġİŻżȦȧȮȯḂḃḊḋḞḟḢḣṀṁṄṅṖṗṘṙṠṡṪṫẆẇẊẋẎẏ"},
+	0x0308: {"AEIOUaeiouyYHhWwXxt", "ÄËÏÖÜäëïöüÿŸḦḧẄẅẌẍẗ"},
+	0x030a: {"AaUuwy", "ÅåŮůẘẙ"},
+	0x030b: {"OoUu", "ŐőŰű"},
+	0x030c: {"CcDdEeLlNnRrSsTtZzAaIiOoUuGgKkjHh", "ČčĎďĚěĽľŇňŘřŠšŤťŽžǍǎǏǐǑǒǓǔǦǧǨǩǰȞȟ"},
+	0x0327: {"CcGgKkLlNnRrSsTtEeDdHh", "ÇçĢģĶķĻļŅņŖŗŞşŢţȨȩḐḑḨḩ"},
+	0x0328: {"AaEeIiUuOo", "ĄąĘęĮįŲųǪǫ"},
+}
+
+// Dead keys and the combining accents they produce. The ASCII characters are
+// the dead keys of layouts such as US International and are also used as
+// accents in compose key sequences.
+var dead_key_accents = map[rune]rune{
+	'`': 0x300, '\'': 0x301, '´': 0x301, '^': 0x302, '~': 0x303, '¯': 0x304, '˘': 0x306, '˙': 0x307,
+	'"': 0x308, '¨': 0x308, '˚': 0x30a, '˝': 0x30b, 'ˇ': 0x30c, ',': 0x327, '¸': 0x327, '˛': 0x328,
+}
+
+// Compose key sequences other than an accent and a letter, either order of the
+// two characters works
+var compose_pairs = map[string]string{
+	"ss": "ß", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "o/": "ø", "O/": "Ø", "oa": "å", "OA": "Å",
+	"th": "þ", "TH": "Þ", "d-": "đ", "D-": "Đ", "<<": "«", ">>": "»", "!!": "¡", "??": "¿",
+	"=e": "€", "L-": "£", "Y=": "¥", "c/": "¢", "oc": "©", "or": "®", "so": "§", "p!": "¶", "oo": "°",
+	"mu": "µ", "+-": "±", "xx": "×", ":-": "÷", "12": "½", "14": "¼", "34": "¾",
+	"^1": "¹", "^2": "²", "^3": "³",
+}
+
+var _accent_map map[[2]rune]rune
+
+func combine_with_accent(accent, base rune) (rune, bool) {
+	if _accent_map == nil {
+		_accent_map = make(map[[2]rune]rune, 256)
+		for mark, q := range accented_letters {
+			accented := []rune(q[1])
+			for i, b := range []rune(q[0]) {
+				_accent_map[[2]rune{mark, b}] = accented[i]
+			}
+		}
+	}
+	ans, found := _accent_map[[2]rune{accent, base}]
+	return ans, found
+}
+
+// Interprets dead keys and compose key sequences in key events, turning them
+// into text. Useful when the terminal reports all keys as escape codes and so
+// does not do this itself. A dead key, such as ´, followed by a letter
+// produces the accented letter, é. The compose key followed by two
+// characters produces the character they describe, for example, compose
+// followed by ' and e also produces é and compose followed by s and s
+// produces ß.
+type Composer struct {
+	// The key that starts a compose sequence, in the format used by
+	// KeyEvent.Matches, such as "right_alt" or "menu". Empty means no
+	// compose key.
+	ComposeKey string
+
+	pending        []rune
+	in_compose     bool
+	pending_accent rune
+}
+
+// Whether a dead key or compose sequence has been started but not finished
+func (self *Composer) InProgress() bool {
+	return self.in_compose || self.pending_accent != 0
+}
+
+// Cancel any sequence in progress
+func (self *Composer) Reset() {
+	self.pending = self.pending[:0]
+	self.in_compose = false
+	self.pending_accent = 0
+}
+
+func is_modifier_key(key string) bool {
+	switch key {
+	case "LEFT_SHIFT", "LEFT_CONTROL", "LEFT_ALT", "LEFT_SUPER", "LEFT_HYPER", "LEFT_META",
+		"RIGHT_SHIFT", "RIGHT_CONTROL", "RIGHT_ALT", "RIGHT_SUPER", "RIGHT_HYPER", "RIGHT_META",
+		"ISO_LEVEL3_SHIFT", "ISO_LEVEL5_SHIFT", "CAPS_LOCK", "NUM_LOCK":
+		return true
+	}
+	return false
+}
+
+// The character for a key that produces no text by itself and the accent it
+// produces if it is a dead key, or zero
+func dead_key_accent(ev *KeyEvent) (rune, rune) {
+	if ev.Text != "" || ev.Mods.WithoutLocks()&^SHIFT != 0 {
+		return 0, 0
+	}
+	key := ev.Key
+	if ev.Mods&SHIFT != 0 && ev.ShiftedKey != "" {
+		key = ev.ShiftedKey
+	}
+	runes := []rune(key)
+	if len(runes) != 1 {
+		return 0, 0
+	}
+	return runes[0], dead_key_accents[runes[0]]
+}
+
+func (self *Composer) finish_compose() string {
+	q := string(self.pending)
+	self.Reset()
+	if ans, found := compose_pairs[q]; found {
+		return ans
+	}
+	runes := []rune(q)
+	if ans, found := compose_pairs[string([]rune{runes[1], runes[0]})]; found {
+		return ans
+	}
+	for _, x := range [][2]rune{{runes[0], runes[1]}, {runes[1], runes[0]}} {
+		if accent, found := dead_key_accents[x[0]]; found {
+			if ans, found := combine_with_accent(accent, x[1]); found {
+				return string(ans)
+			}
+		}
+	}
+	return ""
+}
+
+func (self *Composer) is_compose_key(ev *KeyEvent) bool {
+	if self.ComposeKey == "" {
+		return false
+	}
+	// the modifiers reported when a modifier key is pressed vary, so ignore them
+	if is_modifier_key(ev.Key) {
+		return ev.Key == ParseShortcut(self.ComposeKey).KeyName
+	}
+	return ev.MatchesPressOrRepeat(self.ComposeKey)
+}
+
+// Process a key event, returning the text it produces, if any, and whether
+// the event was consumed as part of a sequence. Events that are not consumed
+// should be handled normally.
+func (self *Composer) HandleKeyEvent(ev *KeyEvent) (text string, consumed bool) {
+	if ev.Type == RELEASE {
+		return "", false
+	}
+	if self.is_compose_key(ev) {
+		self.Reset()
+		self.in_compose = true
+		return "", true
+	}
+	if is_modifier_key(ev.Key) {
+		return "", false
+	}
+	if self.in_compose {
+		if ev.Text == "" {
+			key, _ := dead_key_accent(ev)
+			if key == 0 {
+				self.Reset()
+				return "", ev.MatchesPressOrRepeat("escape")
+			}
+			self.pending = append(self.pending, key)
+		} else {
+			self.pending = append(self.pending, []rune(ev.Text)...)
+		}
+		if len(self.pending) < 2 {
+			return "", true
+		}
+		return self.finish_compose(), true
+	}
+	key, accent := dead_key_accent(ev)
+	if self.pending_accent != 0 {
+		pending_key, pending_accent := self.pending[0], self.pending_accent
+		self.Reset()
+		if accent != 0 {
+			// pressing a dead key twice produces the accent itself
+			return string(pending_key), true
+		}
+		if ev.Text == "" {
+			return "", ev.MatchesPressOrRepeat("escape")
+		}
+		runes := []rune(ev.Text)
+		if ans, found := combine_with_accent(pending_accent, runes[0]); found {
+			return string(ans) + string(runes[1:]), true
+		}
+		return string(pending_key) + ev.Text, true
+	}
+	if accent != 0 {
+		self.pending = append(self.pending[:0], key)
+		self.pending_accent = accent
+		return "", true
+	}
+	return "", false
+}
@@ -0,0 +1,42 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestComposer(t *testing.T) {
+	c := Composer{ComposeKey: "right_alt"}
+	key := func(key, text string) *KeyEvent {
+		return &KeyEvent{Type: PRESS, Key: key, Text: text}
+	}
+	test := func(expected string, events ...*KeyEvent) {
+		c.Reset()
+		actual := ""
+		for _, ev := range events {
+			text, consumed := c.HandleKeyEvent(ev)
+			if !consumed {
+				text = ev.Text
+			}
+			actual += text
+		}
+		if actual != expected {
+			t.Fatalf("Composing %v produced %#v instead of %#v", events, actual, expected)
+		}
+	}
+	test("é", key("´", ""), key("e", "e"))
+	test("ñ", key("~", ""), key("n", "n"))
+	test("´", key("´", ""), key("´", ""))
+	test("´q", key("´", ""), key("q", "q"))
+	test("Ü", key("\"", ""), &KeyEvent{Type: PRESS, Key: "LEFT_SHIFT", Mods: SHIFT}, key("u", "U"))
+	test("é", key("RIGHT_ALT", ""), key("'", "'"), key("e", "e"))
+	test("é", key("RIGHT_ALT", ""), key("e", "e"), key("'", "'"))
+	test("ß", key("RIGHT_ALT", ""), key("s", "s"), key("s", "s"))
+	test("«", key("RIGHT_ALT", ""), key("<", "<"), key("<", "<"))
+	test("", key("RIGHT_ALT", ""), key("q", "q"), key("z", "z"))
+	test("a", key("RIGHT_ALT", ""), key("ESCAPE", ""), key("a", "a"))
+}
@@ -0,0 +1,98 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Called with the contents of an escape code, without the leading and
+// trailing bytes that delimit it
+type EscapeCodeHandler func(raw []byte) error
+
+type prefix_handler struct {
+	prefix  []byte
+	handler EscapeCodeHandler
+}
+
+type escape_code_handlers struct {
+	osc      map[int]EscapeCodeHandler
+	dcs, apc []prefix_handler
+}
+
+func set_prefix_handler(handlers []prefix_handler, prefix string, handler EscapeCodeHandler) []prefix_handler {
+	handlers = utils.Filter(handlers, func(h prefix_handler) bool { return string(h.prefix) != prefix })
+	if handler != nil {
+		handlers = append(handlers, prefix_handler{[]byte(prefix), handler})
+	}
+	return handlers
+}
+
+// The handler for the longest prefix that matches raw
+func find_prefix_handler(handlers []prefix_handler, raw []byte) (ans EscapeCodeHandler) {
+	matched := -1
+	for _, h := range handlers {
+		if len(h.prefix) > matched && bytes.HasPrefix(raw, h.prefix) {
+			ans, matched = h.handler, len(h.prefix)
+		}
+	}
+	return
+}
+
+func osc_number(raw []byte) (int, bool) {
+	idx := bytes.IndexByte(raw, ';')
+	if idx < 0 {
+		idx = len(raw)
+	}
+	num, err := strconv.Atoi(utils.UnsafeBytesToString(raw[:idx]))
+	return num, err == nil
+}
+
+// Handle OSC escape codes with the specified number, such as 52 for the
+// clipboard, instead of sending them to OnEscapeCode. The handler is called
+// with the complete contents of the escape code, including the number. Only
+// one handler can be registered per number, a nil handler removes the
+// existing one.
+func (self *Loop) RegisterOSCHandler(number int, handler EscapeCodeHandler) {
+	if handler == nil {
+		delete(self.escape_code_handlers.osc, number)
+		return
+	}
+	if self.escape_code_handlers.osc == nil {
+		self.escape_code_handlers.osc = make(map[int]EscapeCodeHandler)
+	}
+	self.escape_code_handlers.osc[number] = handler
+}
+
+// Handle DCS escape codes whose contents start with prefix instead of sending
+// them to OnEscapeCode. When several prefixes match, the handler for the
+// longest one is used. A nil handler removes the existing one.
+func (self *Loop) RegisterDCSHandler(prefix string, handler EscapeCodeHandler) {
+	self.escape_code_handlers.dcs = set_prefix_handler(self.escape_code_handlers.dcs, prefix, handler)
+}
+
+// Handle APC escape codes whose contents start with prefix, such as G for
+// responses to graphics commands, in the same way as RegisterDCSHandler()
+func (self *Loop) RegisterAPCHandler(prefix string, handler EscapeCodeHandler) {
+	self.escape_code_handlers.apc = set_prefix_handler(self.escape_code_handlers.apc, prefix, handler)
+}
+
+func (self *escape_code_handlers) handler_for(etype EscapeCodeType, raw []byte) EscapeCodeHandler {
+	switch etype {
+	case OSC:
+		if num, ok := osc_number(raw); ok {
+			return self.osc[num]
+		}
+	case DCS:
+		return find_prefix_handler(self.dcs, raw)
+	case APC:
+		return find_prefix_handler(self.apc, raw)
+	}
+	return nil
+}
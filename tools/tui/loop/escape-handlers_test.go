@@ -0,0 +1,49 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestEscapeCodeHandlers(t *testing.T) {
+	lp, _ := New()
+	var received []string
+	handler := func(name string) EscapeCodeHandler {
+		return func(raw []byte) error {
+			received = append(received, name+":"+string(raw))
+			return nil
+		}
+	}
+	lp.OnEscapeCode = func(etype EscapeCodeType, raw []byte) error {
+		received = append(received, fmt.Sprintf("unhandled:%d:%s", etype, raw))
+		return nil
+	}
+	lp.RegisterOSCHandler(52, handler("clipboard"))
+	lp.RegisterOSCHandler(5522, handler("clipboard2"))
+	lp.RegisterDCSHandler("1+r", handler("xtgettcap"))
+	lp.RegisterDCSHandler("1", handler("dcs"))
+	lp.RegisterAPCHandler("G", handler("graphics"))
+	test := func(input string, expected ...string) {
+		received = nil
+		if err := lp.escape_code_parser.ParseString(input); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, received); diff != "" {
+			t.Fatalf("Unexpected handling of %#v:\n%s", input, diff)
+		}
+	}
+	test("\x1b]52;c;xxx\x1b\\\x1b]5522;type=read\x07\x1b]7;file\x1b\\",
+		"clipboard:52;c;xxx", "clipboard2:5522;type=read", fmt.Sprintf("unhandled:%d:7;file", OSC))
+	test("\x1bP1+r6b\x1b\\\x1bP1$r0m\x1b\\\x1bP>|kitty\x1b\\",
+		"xtgettcap:1+r6b", "dcs:1$r0m", fmt.Sprintf("unhandled:%d:>|kitty", DCS))
+	test("\x1b_Gi=1;OK\x1b\\", "graphics:Gi=1;OK")
+	lp.RegisterOSCHandler(52, nil)
+	lp.RegisterAPCHandler("G", nil)
+	test("\x1b]52;c;xxx\x1b\\\x1b_Gi=1;OK\x1b\\", fmt.Sprintf("unhandled:%d:52;c;xxx", OSC), fmt.Sprintf("unhandled:%d:Gi=1;OK", APC))
+}
@@ -0,0 +1,253 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// An area of the screen, in cells
+type Rect struct {
+	X, Y, Width, Height int
+}
+
+func (self Rect) Contains(x, y int) bool {
+	return x >= self.X && y >= self.Y && x < self.X+self.Width && y < self.Y+self.Height
+}
+
+type LayoutDirection uint8
+
+const (
+	// The children of the pane are placed side by side
+	LAYOUT_ROW LayoutDirection = iota
+	// The children of the pane are placed one below the other
+	LAYOUT_COLUMN
+)
+
+// A rectangular area of the screen, either split into child panes or drawn
+// by its Draw callback. Build a tree of panes and use it with a Layout.
+type Pane struct {
+	// The size in cells along the direction of the parent, zero means the
+	// pane is flexible and shares the space left over by the fixed size
+	// panes with the other flexible panes
+	Size int
+	// The share of the left over space a flexible pane gets, relative to
+	// the other flexible panes, treated as 1 when not positive
+	Flex      int
+	Direction LayoutDirection
+	Children  []*Pane
+
+	// Called to draw panes without children
+	Draw func(area Rect, has_focus bool) error
+	// Panes without children that are focusable can be given the keyboard
+	// focus, the events below are sent to the pane with the focus
+	Focusable  bool
+	OnKeyEvent func(event *KeyEvent) error
+	OnText     func(text string, from_key_event bool, in_bracketed_paste bool) error
+	// Mouse events are sent to the pane under the mouse, with screen
+	// coordinates. Pressing a button focuses a focusable pane.
+	OnMouseEvent func(event *MouseEvent) error
+
+	area Rect
+}
+
+// The area of the screen the pane occupies after the last Layout.Resize()
+func (self *Pane) Area() Rect {
+	return self.area
+}
+
+func (self *Pane) flex() int {
+	if self.Flex > 0 {
+		return self.Flex
+	}
+	return 1
+}
+
+func (self *Pane) layout(area Rect) {
+	self.area = area
+	if len(self.Children) == 0 {
+		return
+	}
+	total, origin := area.Width, area.X
+	if self.Direction == LAYOUT_COLUMN {
+		total, origin = area.Height, area.Y
+	}
+	sizes := make([]int, len(self.Children))
+	left, total_flex := total, 0
+	for i, c := range self.Children {
+		if c.Size > 0 {
+			// fixed size panes that do not fit are shrunk, in order
+			sizes[i] = utils.Min(c.Size, left)
+			left -= sizes[i]
+		} else {
+			total_flex += c.flex()
+		}
+	}
+	if total_flex > 0 {
+		remaining := left
+		for i, c := range self.Children {
+			if c.Size <= 0 {
+				sizes[i] = left * c.flex() / total_flex
+				remaining -= sizes[i]
+			}
+		}
+		// distribute the cells lost to rounding, one to each flexible pane
+		for i, c := range self.Children {
+			if remaining <= 0 {
+				break
+			}
+			if c.Size <= 0 {
+				sizes[i]++
+				remaining--
+			}
+		}
+	}
+	for i, c := range self.Children {
+		r := area
+		if self.Direction == LAYOUT_COLUMN {
+			r.Y, r.Height = origin, sizes[i]
+		} else {
+			r.X, r.Width = origin, sizes[i]
+		}
+		origin += sizes[i]
+		c.layout(r)
+	}
+}
+
+func (self *Pane) leaves(ans []*Pane) []*Pane {
+	if len(self.Children) == 0 {
+		return append(ans, self)
+	}
+	for _, c := range self.Children {
+		ans = c.leaves(ans)
+	}
+	return ans
+}
+
+// Lays out a tree of panes on the screen, draws them and routes input
+// events to them. Its OnKeyEvent, OnText and OnMouseEvent methods can be used
+// as the callbacks of the same name in a Loop.
+type Layout struct {
+	root    *Pane
+	focused *Pane
+}
+
+// Create a layout for the tree of panes rooted at root. The first focusable
+// pane is given the focus.
+func NewLayout(root *Pane) *Layout {
+	ans := Layout{root: root}
+	for _, p := range ans.focusable_panes() {
+		ans.focused = p
+		break
+	}
+	return &ans
+}
+
+func (self *Layout) focusable_panes() []*Pane {
+	ans := make([]*Pane, 0, 4)
+	for _, p := range self.root.leaves(nil) {
+		if p.Focusable {
+			ans = append(ans, p)
+		}
+	}
+	return ans
+}
+
+// Calculate the areas of all panes for a screen of the specified size, call
+// it from OnResize
+func (self *Layout) Resize(width, height int) {
+	self.root.layout(Rect{Width: width, Height: height})
+}
+
+// Draw all the panes that are not empty
+func (self *Layout) Draw() error {
+	for _, p := range self.root.leaves(nil) {
+		if p.Draw != nil && p.area.Width > 0 && p.area.Height > 0 {
+			if err := p.Draw(p.area, p == self.focused); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// The pane with the keyboard focus, nil if there are no focusable panes
+func (self *Layout) Focused() *Pane {
+	return self.focused
+}
+
+// Give the keyboard focus to the specified pane, returns false if it is not
+// a focusable pane in this layout
+func (self *Layout) Focus(pane *Pane) bool {
+	for _, p := range self.focusable_panes() {
+		if p == pane {
+			self.focused = p
+			return true
+		}
+	}
+	return false
+}
+
+// Move the focus by delta focusable panes, in the order the panes are drawn,
+// wrapping around
+func (self *Layout) MoveFocus(delta int) {
+	panes := self.focusable_panes()
+	if len(panes) == 0 {
+		return
+	}
+	idx := 0
+	for i, p := range panes {
+		if p == self.focused {
+			idx = i
+			break
+		}
+	}
+	idx = (idx + delta) % len(panes)
+	if idx < 0 {
+		idx += len(panes)
+	}
+	self.focused = panes[idx]
+}
+
+// The pane without children containing the specified cell, nil if there is
+// none
+func (self *Layout) PaneAt(x, y int) *Pane {
+	for _, p := range self.root.leaves(nil) {
+		if p.area.Contains(x, y) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (self *Layout) OnKeyEvent(event *KeyEvent) error {
+	if self.focused != nil && self.focused.OnKeyEvent != nil {
+		return self.focused.OnKeyEvent(event)
+	}
+	return nil
+}
+
+func (self *Layout) OnText(text string, from_key_event bool, in_bracketed_paste bool) error {
+	if self.focused != nil && self.focused.OnText != nil {
+		return self.focused.OnText(text, from_key_event, in_bracketed_paste)
+	}
+	return nil
+}
+
+func (self *Layout) OnMouseEvent(event *MouseEvent) error {
+	p := self.PaneAt(event.CellX, event.CellY)
+	if p == nil {
+		return nil
+	}
+	if event.Type == MOUSE_PRESS && p.Focusable {
+		self.focused = p
+	}
+	if p.OnMouseEvent != nil {
+		return p.OnMouseEvent(event)
+	}
+	return nil
+}
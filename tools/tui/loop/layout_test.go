@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestLayout(t *testing.T) {
+	var drawn []string
+	var keys []string
+	pane := func(name string, size, flex int, focusable bool) *Pane {
+		return &Pane{
+			Size: size, Flex: flex, Focusable: focusable,
+			Draw: func(r Rect, has_focus bool) error {
+				drawn = append(drawn, fmt.Sprintf("%s:%d,%d,%d,%d:%v", name, r.X, r.Y, r.Width, r.Height, has_focus))
+				return nil
+			},
+			OnKeyEvent: func(ev *KeyEvent) error {
+				keys = append(keys, name)
+				return nil
+			},
+		}
+	}
+	left, right := pane("left", 0, 1, true), pane("right", 0, 2, true)
+	status := pane("status", 1, 0, false)
+	root := &Pane{Direction: LAYOUT_COLUMN, Children: []*Pane{
+		pane("title", 1, 0, false),
+		{Direction: LAYOUT_ROW, Children: []*Pane{left, pane("sep", 1, 0, false), right}},
+		status,
+	}}
+	l := NewLayout(root)
+	draw := func(width, height int, expected ...string) {
+		drawn = nil
+		l.Resize(width, height)
+		if err := l.Draw(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, drawn); diff != "" {
+			t.Fatalf("Unexpected layout for %dx%d:\n%s", width, height, diff)
+		}
+	}
+	draw(81, 10, "title:0,0,81,1:false", "left:0,1,27,8:true", "sep:27,1,1,8:false", "right:28,1,53,8:false", "status:0,9,81,1:false")
+	draw(5, 1, "title:0,0,5,1:false")
+	draw(5, 2, "title:0,0,5,1:false", "status:0,1,5,1:false")
+	draw(5, 3, "title:0,0,5,1:false", "left:0,1,2,1:true", "sep:2,1,1,1:false", "right:3,1,2,1:false", "status:0,2,5,1:false")
+
+	l.Resize(81, 10)
+	l.OnKeyEvent(&KeyEvent{})
+	l.MoveFocus(1)
+	l.OnKeyEvent(&KeyEvent{})
+	l.MoveFocus(1)
+	l.OnKeyEvent(&KeyEvent{})
+	l.MoveFocus(-1)
+	l.OnKeyEvent(&KeyEvent{})
+	if diff := cmp.Diff([]string{"left", "right", "left", "right"}, keys); diff != "" {
+		t.Fatalf("Key events not routed to the focused pane:\n%s", diff)
+	}
+	if l.Focus(status) || l.Focused() != right {
+		t.Fatalf("Focused a pane that is not focusable")
+	}
+	l.OnMouseEvent(&MouseEvent{Type: MOUSE_MOVE, CellX: 3, CellY: 3})
+	if l.Focused() != right {
+		t.Fatalf("Mouse movement changed the focus")
+	}
+	l.OnMouseEvent(&MouseEvent{Type: MOUSE_PRESS, CellX: 3, CellY: 3})
+	if l.Focused() != left {
+		t.Fatalf("Clicking on a pane did not focus it")
+	}
+	if l.PaneAt(40, 9) != status || l.PaneAt(81, 0) != nil {
+		t.Fatalf("PaneAt() returned the wrong pane")
+	}
+}
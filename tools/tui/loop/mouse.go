@@ -0,0 +1,198 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var _ = fmt.Print
+
+type MouseEventType uint8
+
+const (
+	MOUSE_PRESS MouseEventType = iota + 1
+	MOUSE_RELEASE
+	// The mouse moved with no button pressed, only reported with FULL_MOUSE_TRACKING
+	MOUSE_MOVE
+	// The mouse moved with a button pressed
+	MOUSE_DRAG
+	MOUSE_WHEEL
+)
+
+func (self MouseEventType) String() string {
+	switch self {
+	case MOUSE_PRESS:
+		return "PRESS"
+	case MOUSE_RELEASE:
+		return "RELEASE"
+	case MOUSE_MOVE:
+		return "MOVE"
+	case MOUSE_DRAG:
+		return "DRAG"
+	case MOUSE_WHEEL:
+		return "WHEEL"
+	default:
+		return fmt.Sprintf("MouseEventType:%d", int(self))
+	}
+}
+
+type MouseButton uint16
+
+const (
+	NO_MOUSE_BUTTON   MouseButton = 0
+	LEFT_MOUSE_BUTTON MouseButton = 1 << (iota - 1)
+	MIDDLE_MOUSE_BUTTON
+	RIGHT_MOUSE_BUTTON
+	FOURTH_MOUSE_BUTTON
+	FIFTH_MOUSE_BUTTON
+	SIXTH_MOUSE_BUTTON
+	SEVENTH_MOUSE_BUTTON
+	WHEEL_UP
+	WHEEL_DOWN
+	WHEEL_LEFT
+	WHEEL_RIGHT
+)
+
+var mouse_button_names = []string{"left", "middle", "right", "fourth", "fifth", "sixth", "seventh", "wheel_up", "wheel_down", "wheel_left", "wheel_right"}
+
+func (self MouseButton) String() string {
+	ans := make([]string, 0, 1)
+	for i, name := range mouse_button_names {
+		if self&(1<<i) != 0 {
+			ans = append(ans, name)
+		}
+	}
+	if len(ans) == 0 {
+		return "none"
+	}
+	return strings.Join(ans, "+")
+}
+
+type MouseEvent struct {
+	Type MouseEventType
+	// The button that was pressed, released or is held down while dragging,
+	// for wheel events, the direction of scrolling
+	Button MouseButton
+	Mods   KeyModifiers
+	// Zero based position of the mouse in cells
+	CellX, CellY int
+	// Zero based position of the mouse in pixels, relative to the top left
+	// corner of the screen. When the terminal does not support reporting
+	// pixel positions, this is the top left corner of the cell.
+	PixelX, PixelY int
+}
+
+func (self *MouseEvent) String() string {
+	button := self.Button.String()
+	if self.Mods > 0 {
+		button = self.Mods.String() + "+" + button
+	}
+	return fmt.Sprintf("%s{ %s cell: (%d, %d) pixel: (%d, %d) }", self.Type, button, self.CellX, self.CellY, self.PixelX, self.PixelY)
+}
+
+const (
+	mouse_shift_indicator  = 1 << 2
+	mouse_alt_indicator    = 1 << 3
+	mouse_ctrl_indicator   = 1 << 4
+	mouse_motion_indicator = 1 << 5
+)
+
+func pixel_to_cell(px, length, cell_length int) int {
+	if cell_length < 1 {
+		return 0
+	}
+	if px >= length {
+		px = length - 1
+	}
+	if px < 0 {
+		px = 0
+	}
+	return px / cell_length
+}
+
+// Decode an SGR mouse event of the form: <button;x;y followed by M for
+// presses and motion or m for releases. When coordinates_in_pixels is true,
+// x and y are zero based pixel positions, as sent by terminals that support
+// the SGR-pixel protocol, otherwise they are one based cell positions.
+// Returns nil if csi is not a mouse event.
+func MouseEventFromCSI(csi string, screen_size ScreenSize, coordinates_in_pixels bool) *MouseEvent {
+	if len(csi) < 2 || csi[0] != '<' {
+		return nil
+	}
+	trailer := csi[len(csi)-1]
+	if trailer != 'm' && trailer != 'M' {
+		return nil
+	}
+	parts := strings.Split(csi[1:len(csi)-1], ";")
+	if len(parts) != 3 {
+		return nil
+	}
+	var nums [3]int
+	for i, x := range parts {
+		q, err := strconv.Atoi(x)
+		// positions can be negative when dragging outside the window
+		if err != nil || (i == 0 && q < 0) {
+			return nil
+		}
+		nums[i] = q
+	}
+	cb, x, y := nums[0], nums[1], nums[2]
+	ans := MouseEvent{}
+	if cb&mouse_shift_indicator != 0 {
+		ans.Mods |= SHIFT
+	}
+	if cb&mouse_alt_indicator != 0 {
+		ans.Mods |= ALT
+	}
+	if cb&mouse_ctrl_indicator != 0 {
+		ans.Mods |= CTRL
+	}
+	button := cb & 3
+	switch {
+	case cb >= 128:
+		ans.Button = FOURTH_MOUSE_BUTTON << button
+	case cb >= 64:
+		ans.Button = WHEEL_UP << button
+	case button < 3:
+		ans.Button = LEFT_MOUSE_BUTTON << button
+	}
+	switch {
+	case cb >= 64 && cb < 128:
+		ans.Type = MOUSE_WHEEL
+	case trailer == 'm':
+		ans.Type = MOUSE_RELEASE
+	case cb&mouse_motion_indicator != 0:
+		if ans.Button == NO_MOUSE_BUTTON {
+			ans.Type = MOUSE_MOVE
+		} else {
+			ans.Type = MOUSE_DRAG
+		}
+	default:
+		ans.Type = MOUSE_PRESS
+	}
+	cw, ch := int(screen_size.CellWidth), int(screen_size.CellHeight)
+	if coordinates_in_pixels {
+		ans.PixelX, ans.PixelY = x, y
+		ans.CellX = pixel_to_cell(x, int(screen_size.WidthPx), cw)
+		ans.CellY = pixel_to_cell(y, int(screen_size.HeightPx), ch)
+	} else {
+		ans.CellX = pixel_to_cell(x-1, int(screen_size.WidthCells), 1)
+		ans.CellY = pixel_to_cell(y-1, int(screen_size.HeightCells), 1)
+		ans.PixelX, ans.PixelY = ans.CellX*cw, ans.CellY*ch
+	}
+	return &ans
+}
+
+// Parse the response to a query for support for the SGR-pixel protocol, of
+// the form: ?1016;N$y
+func sgr_pixel_mode_from_csi(csi string) (is_report, supported bool) {
+	if !strings.HasPrefix(csi, "?1016;") || !strings.HasSuffix(csi, "$y") {
+		return false, false
+	}
+	// 1 is set and 3 is permanently set
+	status := csi[len("?1016;") : len(csi)-len("$y")]
+	return true, status == "1" || status == "3"
+}
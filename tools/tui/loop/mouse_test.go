@@ -0,0 +1,84 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestMouseEvents(t *testing.T) {
+	sz := ScreenSize{WidthCells: 80, HeightCells: 24, CellWidth: 10, CellHeight: 20, WidthPx: 800, HeightPx: 480}
+	for csi, expected := range map[string]string{
+		"<0;15;45M":   "PRESS{ left cell: (1, 2) pixel: (15, 45) }",
+		"<2;15;45m":   "RELEASE{ right cell: (1, 2) pixel: (15, 45) }",
+		"<32;95;5M":   "DRAG{ left cell: (9, 0) pixel: (95, 5) }",
+		"<35;95;5M":   "MOVE{ none cell: (9, 0) pixel: (95, 5) }",
+		"<65;10;10M":  "WHEEL{ wheel_down cell: (1, 0) pixel: (10, 10) }",
+		"<20;10;10M":  "PRESS{ shift+ctrl+left cell: (1, 0) pixel: (10, 10) }",
+		"<129;10;10M": "PRESS{ fifth cell: (1, 0) pixel: (10, 10) }",
+		"<33;-5;900M": "DRAG{ middle cell: (0, 23) pixel: (-5, 900) }",
+	} {
+		ev := MouseEventFromCSI(csi, sz, true)
+		if ev == nil || ev.String() != expected {
+			t.Fatalf("Decoding %#v gave %s instead of %s", csi, ev, expected)
+		}
+	}
+	ev := MouseEventFromCSI("<0;2;3M", sz, false)
+	if ev == nil || ev.String() != "PRESS{ left cell: (1, 2) pixel: (10, 40) }" {
+		t.Fatalf("Decoding cell coordinates gave: %s", ev)
+	}
+	for _, csi := range []string{"<0;1M", "0;1;1M", "<a;1;1M", "<0;1;1u", "A"} {
+		if ev := MouseEventFromCSI(csi, sz, true); ev != nil {
+			t.Fatalf("%#v incorrectly decoded as a mouse event: %s", csi, ev)
+		}
+	}
+
+	lp, _ := New()
+	lp.screen_size = sz
+	var received []string
+	lp.OnMouseEvent = func(ev *MouseEvent) error {
+		received = append(received, ev.String())
+		return nil
+	}
+	for _, csi := range []string{"<0;15;45M", "?1016;1$y", "<0;15;45M"} {
+		if err := lp.handle_csi([]byte(csi)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(received, " ") != "PRESS{ left cell: (14, 23) pixel: (140, 460) } PRESS{ left cell: (1, 2) pixel: (15, 45) }" {
+		t.Fatalf("Unexpected mouse events: %v", received)
+	}
+
+	opts := TerminalStateOptions{mouse_tracking: BUTTONS_AND_DRAG_MOUSE_TRACKING}
+	if !strings.Contains(opts.SetStateEscapeCodes(), "\033[?1006h\033[?1016h\033[?1016$p\033[?1002h") {
+		t.Fatal("Mouse tracking not requested from the terminal")
+	}
+}
+
+func TestFocusChange(t *testing.T) {
+	lp, _ := New()
+	var received []bool
+	lp.OnFocusChange = func(has_focus bool) error {
+		received = append(received, has_focus)
+		return nil
+	}
+	if !lp.HasFocus() {
+		t.Fatal("Loop does not start out focused")
+	}
+	for _, csi := range []string{"O", "I", "O"} {
+		if err := lp.handle_csi([]byte(csi)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(received) != "[false true false]" || lp.HasFocus() {
+		t.Fatalf("Unexpected focus changes: %v", received)
+	}
+	opts := TerminalStateOptions{focus_tracking: true}
+	if !strings.Contains(opts.SetStateEscapeCodes(), "\033[?1004h") || !strings.Contains(opts.ResetStateEscapeCodes(), "\033[?1004l") {
+		t.Fatal("Focus tracking not requested from the terminal")
+	}
+}
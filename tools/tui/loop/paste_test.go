@@ -0,0 +1,60 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestBracketedPaste(t *testing.T) {
+	lp, _ := New()
+	var events []string
+	text := ""
+	lp.OnText = func(t string, from_key_event, in_bracketed_paste bool) error {
+		if in_bracketed_paste {
+			text += t
+		} else if t == "" {
+			events = append(events, "text-end")
+		}
+		return nil
+	}
+	lp.OnPasteStart = func() error {
+		events = append(events, "start")
+		return nil
+	}
+	lp.OnPasteEnd = func(size int, truncated bool) error {
+		events = append(events, fmt.Sprintf("end:%d:%v", size, truncated))
+		return nil
+	}
+	paste := func(raw string) {
+		text, events = "", nil
+		if err := lp.escape_code_parser.ParseString("\x1b[200~" + raw + "\x1b[201~"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paste("a\x1b[31mb\tc\r\nd\x07\u009be")
+	if text != "a[31mb\tc\r\nde" {
+		t.Fatalf("Control characters not removed from paste: %#v", text)
+	}
+	if fmt.Sprint(events) != "[start end:12:false text-end]" {
+		t.Fatalf("Unexpected paste events: %v", events)
+	}
+	lp.AllowControlCharsInPaste()
+	paste("a\x1bb")
+	if text != "a\x1bb" {
+		t.Fatalf("Control characters removed from paste: %#v", text)
+	}
+	lp.MaxPasteSize(4)
+	paste("abécd")
+	if text != "abé" || fmt.Sprint(events) != "[start end:4:true text-end]" {
+		t.Fatalf("Paste not truncated: %#v %v", text, events)
+	}
+	paste("abc")
+	if text != "abc" || fmt.Sprint(events) != "[start end:3:false text-end]" {
+		t.Fatalf("Paste incorrectly truncated: %#v %v", text, events)
+	}
+}
@@ -38,7 +38,10 @@ func read_ignoring_temporary_errors(f *tty.Term, buf []byte) (int, error) {
 	return n, err
 }
 
-func read_from_tty(pipe_r *os.File, term *tty.Term, results_channel chan<- []byte, err_channel chan<- error, quit_channel <-chan byte) {
+// Writing a byte to the pipe pauses reading, closing it stops reading. When
+// paused, the reader sends on paused_channel and then waits to receive on
+// resume_channel.
+func read_from_tty(pipe_r *os.File, term *tty.Term, results_channel chan<- []byte, err_channel chan<- error, quit_channel <-chan byte, paused_channel chan<- byte, resume_channel <-chan byte) {
 	keep_going := true
 	pipe_fd := int(pipe_r.Fd())
 	tty_fd := term.Fd()
@@ -53,20 +56,25 @@ func read_from_tty(pipe_r *os.File, term *tty.Term, results_channel chan<- []byt
 
 	const bufsize = 2 * utils.DEFAULT_IO_BUFFER_SIZE
 
-	wait_for_read_available := func() {
+	// returns true when the tty has data to be read
+	wait_for_read_available := func() bool {
 		_, err := selector.WaitForever()
 		if err != nil {
 			err_channel <- err
 			keep_going = false
-			return
+			return false
 		}
 		if selector.IsReadyToRead(pipe_fd) {
-			keep_going = false
-			return
-		}
-		if selector.IsReadyToRead(tty_fd) {
-			return
+			var b [1]byte
+			if n, _ := pipe_r.Read(b[:]); n == 0 {
+				keep_going = false
+				return false
+			}
+			paused_channel <- 1
+			<-resume_channel
+			return false
 		}
+		return selector.IsReadyToRead(tty_fd)
 	}
 
 	buf := make([]byte, bufsize)
@@ -74,8 +82,8 @@ func read_from_tty(pipe_r *os.File, term *tty.Term, results_channel chan<- []byt
 		if len(buf) == 0 {
 			buf = make([]byte, bufsize)
 		}
-		if wait_for_read_available(); !keep_going {
-			break
+		if !wait_for_read_available() {
+			continue
 		}
 		n, err := read_ignoring_temporary_errors(term, buf)
 		if err != nil {
@@ -95,3 +103,22 @@ func read_from_tty(pipe_r *os.File, term *tty.Term, results_channel chan<- []byt
 		}
 	}
 }
+
+// Pause the tty reader, returning any input it had already read but not yet
+// sent on results_channel
+func pause_reading_from_tty(pipe_w *os.File, results_channel <-chan []byte, paused_channel <-chan byte) (pending_input []byte, err error) {
+	if _, err = pipe_w.Write([]byte{1}); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case <-paused_channel:
+			return pending_input, nil
+		case data, more := <-results_channel:
+			if !more {
+				return nil, fmt.Errorf("Failed to read from terminal: %w", io.EOF)
+			}
+			pending_input = append(pending_input, data...)
+		}
+	}
+}
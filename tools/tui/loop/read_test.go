@@ -0,0 +1,99 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+func TestReadFromTTYPauseAndResume(t *testing.T) {
+	master, slave_name, err := tty.OpenPty()
+	if err != nil {
+		t.Skipf("Pseudo-terminals are not available: %s", err)
+	}
+	defer master.Close()
+	term, err := tty.OpenTerm(slave_name, tty.SetRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer term.Close()
+	pipe_r, pipe_w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results_channel, err_channel := make(chan []byte), make(chan error, 8)
+	quit_channel := make(chan byte)
+	paused_channel, resume_channel := make(chan byte), make(chan byte)
+	go read_from_tty(pipe_r, term, results_channel, err_channel, quit_channel, paused_channel, resume_channel)
+	defer func() {
+		pipe_w.Close()
+		close(quit_channel)
+		for more := true; more; _, more = <-results_channel {
+		}
+	}()
+
+	type_text := func(text string) {
+		if _, err := master.WriteString(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read_text := func(expected string, timeout time.Duration) string {
+		ans := ""
+		deadline := time.After(timeout)
+		for len(ans) < len(expected) {
+			select {
+			case data := <-results_channel:
+				ans += string(data)
+			case err := <-err_channel:
+				t.Fatal(err)
+			case <-deadline:
+				return ans
+			}
+		}
+		return ans
+	}
+
+	type_text("abc")
+	if actual := read_text("abc", 2*time.Second); actual != "abc" {
+		t.Fatalf("Did not read input from the terminal: %#v", actual)
+	}
+
+	// input already read when pausing is returned as pending
+	type_text("def")
+	time.Sleep(50 * time.Millisecond)
+	pending, err := pause_reading_from_tty(pipe_w, results_channel, paused_channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// input typed while paused is left in the terminal
+	type_text("ghi")
+	if actual := read_text("x", 100*time.Millisecond); actual != "" {
+		t.Fatalf("Input was read while paused: %#v", actual)
+	}
+	resume_channel <- 1
+	expected := "defghi"[len(pending):]
+	if actual := string(pending) + read_text(expected, 2*time.Second); actual != "defghi" {
+		t.Fatalf("Input lost when pausing and resuming: %#v", actual)
+	}
+
+	// pausing when there is no pending input
+	pending, err = pause_reading_from_tty(pipe_w, results_channel, paused_channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) > 0 {
+		t.Fatalf("Unexpected pending input: %#v", string(pending))
+	}
+	resume_channel <- 1
+	type_text("jkl")
+	if actual := read_text("jkl", 2*time.Second); actual != "jkl" {
+		t.Fatalf("Did not read input from the terminal after resuming: %#v", actual)
+	}
+}
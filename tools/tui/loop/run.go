@@ -11,6 +11,7 @@ import (
 	"os/signal"
 	"runtime/debug"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/sys/unix"
 
@@ -20,7 +21,7 @@ import (
 var SIGNULL unix.Signal
 
 func new_loop() *Loop {
-	l := Loop{controlling_term: nil, timers_temp: make([]*timer, 4)}
+	l := Loop{controlling_term: nil, timers_temp: make([]*timer, 4), has_focus: true}
 	l.terminal_options.alternate_screen = true
 	l.terminal_options.restore_colors = true
 	l.terminal_options.kitty_keyboard_mode = true
@@ -31,6 +32,7 @@ func new_loop() *Loop {
 	l.escape_code_parser.HandleSOS = l.handle_sos
 	l.escape_code_parser.HandlePM = l.handle_pm
 	l.escape_code_parser.HandleRune = l.handle_rune
+	l.escape_code_parser.HandleStartOfBracketedPaste = l.handle_start_of_bracketed_paste
 	l.escape_code_parser.HandleEndOfBracketedPaste = l.handle_end_of_bracketed_paste
 	return &l
 }
@@ -50,6 +52,10 @@ func (self *Loop) print_stack() {
 }
 
 func (self *Loop) update_screen_size() error {
+	if self.virtual_screen_size != nil {
+		self.update_virtual_screen_size()
+		return nil
+	}
 	if self.controlling_term == nil {
 		return fmt.Errorf("No controlling terminal cannot update screen size")
 	}
@@ -67,11 +73,33 @@ func (self *Loop) update_screen_size() error {
 }
 
 func (self *Loop) handle_csi(raw []byte) error {
+	if consumed, err := self.handle_capabilities_response(CSI, raw); consumed {
+		return err
+	}
 	csi := string(raw)
 	ke := KeyEventFromCSI(csi)
 	if ke != nil {
 		return self.handle_key_event(ke)
 	}
+	if self.OnMouseEvent != nil {
+		if ev := MouseEventFromCSI(csi, self.screen_size, self.mouse_coordinates_in_pixels); ev != nil {
+			return self.OnMouseEvent(ev)
+		}
+	}
+	if self.OnFocusChange != nil && (csi == "I" || csi == "O") {
+		self.has_focus = csi == "I"
+		return self.OnFocusChange(self.has_focus)
+	}
+	if is_report, supported := sgr_pixel_mode_from_csi(csi); is_report {
+		self.mouse_coordinates_in_pixels = supported
+		return nil
+	}
+	if self.OnColorSchemeChange != nil {
+		if scheme := color_scheme_from_csi(csi); scheme != UNKNOWN_COLOR_SCHEME {
+			self.color_scheme = scheme
+			return self.OnColorSchemeChange(scheme)
+		}
+	}
 	if self.OnEscapeCode != nil {
 		return self.OnEscapeCode(CSI, raw)
 	}
@@ -79,6 +107,14 @@ func (self *Loop) handle_csi(raw []byte) error {
 }
 
 func (self *Loop) handle_key_event(ev *KeyEvent) error {
+	if self.composer != nil {
+		if text, consumed := self.composer.HandleKeyEvent(ev); consumed {
+			if text != "" && self.OnText != nil {
+				return self.OnText(text, true, false)
+			}
+			return nil
+		}
+	}
 	if self.OnKeyEvent != nil {
 		err := self.OnKeyEvent(ev)
 		if err != nil {
@@ -103,6 +139,9 @@ func (self *Loop) handle_key_event(ev *KeyEvent) error {
 }
 
 func (self *Loop) handle_osc(raw []byte) error {
+	if h := self.escape_code_handlers.handler_for(OSC, raw); h != nil {
+		return h(raw)
+	}
 	if self.OnEscapeCode != nil {
 		return self.OnEscapeCode(OSC, raw)
 	}
@@ -113,6 +152,12 @@ func (self *Loop) handle_dcs(raw []byte) error {
 	if self.OnRCResponse != nil && bytes.HasPrefix(raw, []byte("@kitty-cmd")) {
 		return self.OnRCResponse(raw[len("@kitty-cmd"):])
 	}
+	if consumed, err := self.handle_capabilities_response(DCS, raw); consumed {
+		return err
+	}
+	if h := self.escape_code_handlers.handler_for(DCS, raw); h != nil {
+		return h(raw)
+	}
 	if self.OnEscapeCode != nil {
 		return self.OnEscapeCode(DCS, raw)
 	}
@@ -120,6 +165,12 @@ func (self *Loop) handle_dcs(raw []byte) error {
 }
 
 func (self *Loop) handle_apc(raw []byte) error {
+	if consumed, err := self.handle_capabilities_response(APC, raw); consumed {
+		return err
+	}
+	if h := self.escape_code_handlers.handler_for(APC, raw); h != nil {
+		return h(raw)
+	}
 	if self.OnEscapeCode != nil {
 		return self.OnEscapeCode(APC, raw)
 	}
@@ -140,20 +191,63 @@ func (self *Loop) handle_pm(raw []byte) error {
 	return nil
 }
 
+// Control characters that could be used to inject escape codes or otherwise
+// mess with the terminal when pasted text is echoed
+func is_unsafe_in_paste(ch rune) bool {
+	switch {
+	case ch == '\t' || ch == '\n' || ch == '\r':
+		return false
+	case ch < 0x20 || ch == 0x7f:
+		return true
+	case ch >= 0x80 && ch < 0xa0:
+		return true
+	}
+	return false
+}
+
 func (self *Loop) handle_rune(raw rune) error {
+	in_bracketed_paste := self.escape_code_parser.InBracketedPaste()
+	if in_bracketed_paste {
+		if !self.allow_control_chars_in_paste && is_unsafe_in_paste(raw) {
+			return nil
+		}
+		sz := utf8.RuneLen(raw)
+		if self.paste_truncated || (self.max_paste_size > 0 && self.paste_size+sz > self.max_paste_size) {
+			self.paste_truncated = true
+			return nil
+		}
+		self.paste_size += sz
+	}
 	if self.OnText != nil {
-		return self.OnText(string(raw), false, self.escape_code_parser.InBracketedPaste())
+		return self.OnText(string(raw), false, in_bracketed_paste)
+	}
+	return nil
+}
+
+func (self *Loop) handle_start_of_bracketed_paste() error {
+	self.paste_size, self.paste_truncated = 0, false
+	if self.OnPasteStart != nil {
+		return self.OnPasteStart()
 	}
 	return nil
 }
 
-func (self *Loop) handle_end_of_bracketed_paste() {
+func (self *Loop) handle_end_of_bracketed_paste() error {
+	if self.OnPasteEnd != nil {
+		if err := self.OnPasteEnd(self.paste_size, self.paste_truncated); err != nil {
+			return err
+		}
+	}
 	if self.OnText != nil {
-		self.OnText("", false, false)
+		return self.OnText("", false, false)
 	}
+	return nil
 }
 
 func (self *Loop) on_signal(s unix.Signal) error {
+	if handled, err := self.dispatch_signal_to_subscribers(s); handled {
+		return err
+	}
 	switch s {
 	case unix.SIGINT:
 		return self.on_SIGINT()
@@ -183,14 +277,23 @@ func (self *Loop) on_SIGPIPE() error {
 }
 
 func (self *Loop) on_SIGWINCH() error {
+	if self.virtual_screen_size != nil {
+		return nil
+	}
 	self.screen_size.updated = false
-	if self.OnResize != nil {
+	if self.OnResize != nil || len(self.subprocesses) > 0 || self.screen_buffer != nil {
 		old_size := self.screen_size
 		err := self.update_screen_size()
 		if err != nil {
 			return err
 		}
-		return self.OnResize(old_size, self.screen_size)
+		self.resize_subprocesses()
+		if self.screen_buffer != nil {
+			self.screen_buffer.Resize(int(self.screen_size.WidthCells), int(self.screen_size.HeightCells))
+		}
+		if self.OnResize != nil {
+			return self.OnResize(old_size, self.screen_size)
+		}
 	}
 	return nil
 }
@@ -209,9 +312,12 @@ func (self *Loop) on_SIGHUP() error {
 
 func (self *Loop) run() (err error) {
 	signal_channel := make(chan os.Signal, 256)
-	handled_signals := []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGTSTP, unix.SIGHUP, unix.SIGWINCH, unix.SIGPIPE}
-	signal.Notify(signal_channel, handled_signals...)
-	defer signal.Reset(handled_signals...)
+	self.signal_channel = signal_channel
+	signal.Notify(signal_channel, self.signals_to_handle()...)
+	defer func() {
+		signal.Reset(self.signals_to_handle()...)
+		self.signal_channel = nil
+	}()
 
 	controlling_term, err := tty.OpenControllingTerm()
 	if err != nil {
@@ -232,6 +338,7 @@ func (self *Loop) run() (err error) {
 	tty_write_channel := make(chan *write_msg, 1) // buffered so there is no race between initial queueing and startup of writer thread
 	write_done_channel := make(chan IdType)
 	tty_reading_done_channel := make(chan byte)
+	tty_reading_paused_channel, tty_reading_resume_channel := make(chan byte), make(chan byte)
 	self.wakeup_channel = make(chan byte, 256)
 	self.pending_writes = make([]*write_msg, 0, 256)
 	err_channel := make(chan error, 8)
@@ -239,6 +346,10 @@ func (self *Loop) run() (err error) {
 	self.escape_code_parser.Reset()
 	self.exit_code = 0
 	self.timers = make([]*timer, 0, 1)
+	self.subprocesses = make(map[IdType]*subprocess)
+	self.subprocess_channel = make(chan subprocess_event, 64)
+	self.subprocess_done_channel = make(chan struct{})
+	defer self.close_subprocesses()
 	no_timeout_channel := make(<-chan time.Time)
 	finalizer := ""
 
@@ -254,6 +365,8 @@ func (self *Loop) run() (err error) {
 	} else {
 		return err
 	}
+	self.terminal_options.color_scheme_updates = self.OnColorSchemeChange != nil
+	self.terminal_options.focus_tracking = self.OnFocusChange != nil
 	self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
 	needs_reset_escape_codes := true
 
@@ -262,6 +375,11 @@ func (self *Loop) run() (err error) {
 		r_w.Close()
 		close(tty_reading_done_channel)
 
+		if self.frame_depth > 0 {
+			// dont leave the terminal waiting for the end of an unfinished frame
+			self.frame_depth = 1
+			self.EndFrame()
+		}
 		if self.OnFinalize != nil {
 			finalizer += self.OnFinalize()
 		}
@@ -280,7 +398,7 @@ func (self *Loop) run() (err error) {
 	}()
 
 	go write_to_tty(w_r, controlling_term, tty_write_channel, err_channel, write_done_channel)
-	go read_from_tty(r_r, controlling_term, tty_read_channel, err_channel, tty_reading_done_channel)
+	go read_from_tty(r_r, controlling_term, tty_read_channel, err_channel, tty_reading_done_channel, tty_reading_paused_channel, tty_reading_resume_channel)
 
 	if self.OnInitialize != nil {
 		finalizer, err = self.OnInitialize()
@@ -306,6 +424,9 @@ func (self *Loop) run() (err error) {
 		}
 		write_id = self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
 		needs_reset_escape_codes = true
+		if self.screen_buffer != nil {
+			self.screen_buffer.Invalidate()
+		}
 		err = self.wait_for_write_to_complete(write_id, tty_write_channel, write_done_channel, 2*time.Second)
 		if err != nil {
 			return err
@@ -316,6 +437,32 @@ func (self *Loop) run() (err error) {
 		return nil
 	}
 
+	self.suspend_and_run = func(callback func() error) (err error) {
+		pending_input, err := pause_reading_from_tty(r_w, tty_read_channel, tty_reading_paused_channel)
+		if err != nil {
+			return err
+		}
+		write_id := self.QueueWriteString(self.terminal_options.ResetStateEscapeCodes())
+		needs_reset_escape_codes = false
+		if err = self.wait_for_write_to_complete(write_id, tty_write_channel, write_done_channel, 2*time.Second); err == nil {
+			err = controlling_term.SuspendAndRun(callback)
+		}
+		write_id = self.QueueWriteString(self.terminal_options.SetStateEscapeCodes())
+		needs_reset_escape_codes = true
+		if self.screen_buffer != nil {
+			self.screen_buffer.Invalidate()
+		}
+		tty_reading_resume_channel <- 1
+		if werr := self.wait_for_write_to_complete(write_id, tty_write_channel, write_done_channel, 2*time.Second); err == nil {
+			err = werr
+		}
+		if err == nil && len(pending_input) > 0 {
+			err = self.dispatch_input_data(pending_input)
+		}
+		return
+	}
+	defer func() { self.suspend_and_run = nil }()
+
 	for self.keep_going {
 		self.flush_pending_writes(tty_write_channel)
 		timeout_chan := no_timeout_channel
@@ -356,6 +503,11 @@ func (self *Loop) run() (err error) {
 			}
 		case rwerr := <-err_channel:
 			return fmt.Errorf("Failed doing I/O with terminal: %w", rwerr)
+		case ev := <-self.subprocess_channel:
+			err = self.dispatch_subprocess_event(ev)
+			if err != nil {
+				return err
+			}
 		case s := <-signal_channel:
 			err = self.on_signal(s.(unix.Signal))
 			if err != nil {
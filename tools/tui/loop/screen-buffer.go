@@ -0,0 +1,299 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+// The color of the text or background of a cell. The zero value is the
+// default color of the terminal.
+type CellColor uint32
+
+const (
+	cell_color_indexed CellColor = 1 << 24
+	cell_color_rgb     CellColor = 1 << 25
+)
+
+// One of the 256 colors in the color table of the terminal
+func IndexedCellColor(idx uint8) CellColor {
+	return cell_color_indexed | CellColor(idx)
+}
+
+func RGBCellColor(r, g, b uint8) CellColor {
+	return cell_color_rgb | CellColor(r)<<16 | CellColor(g)<<8 | CellColor(b)
+}
+
+func (self CellColor) as_sgr(base int, buf *strings.Builder) {
+	switch {
+	case self&cell_color_rgb != 0:
+		fmt.Fprintf(buf, ";%d;2;%d;%d;%d", base+8, (self>>16)&0xff, (self>>8)&0xff, self&0xff)
+	case self&cell_color_indexed != 0:
+		fmt.Fprintf(buf, ";%d;5;%d", base+8, self&0xff)
+	}
+}
+
+type CellStyle struct {
+	Fg, Bg                                                      CellColor
+	Bold, Dim, Italic, Underline, Reverse, Strikethrough, Blink bool
+}
+
+// The SGR escape code that sets exactly this style, whatever the current style is
+func (self CellStyle) as_sgr() string {
+	buf := strings.Builder{}
+	buf.WriteString("\x1b[0")
+	flag := func(val bool, code string) {
+		if val {
+			buf.WriteString(";")
+			buf.WriteString(code)
+		}
+	}
+	flag(self.Bold, "1")
+	flag(self.Dim, "2")
+	flag(self.Italic, "3")
+	flag(self.Underline, "4")
+	flag(self.Blink, "5")
+	flag(self.Reverse, "7")
+	flag(self.Strikethrough, "9")
+	self.Fg.as_sgr(30, &buf)
+	self.Bg.as_sgr(40, &buf)
+	buf.WriteString("m")
+	return buf.String()
+}
+
+type Cell struct {
+	// Zero for the second cell of a double width character
+	Ch    rune
+	Style CellStyle
+}
+
+var blank_cell = Cell{Ch: ' '}
+
+// A grid of cells the size of the screen, for kittens with complex user
+// interfaces. Instead of writing escape codes, draw the complete user interface
+// into the buffer for every frame and render it. Rendering sends only the
+// cells that changed since the previous frame to the terminal, which prevents
+// flicker and reduces the amount of data sent, which matters over slow
+// connections. The cursor is left at CursorX, CursorY after rendering.
+type ScreenBuffer struct {
+	CursorX, CursorY int
+
+	width, height int
+	cells         []Cell
+	// the cells on the screen, nil when the screen has to be redrawn
+	rendered                             []Cell
+	rendered_cursor_x, rendered_cursor_y int
+}
+
+func NewScreenBuffer(width, height int) *ScreenBuffer {
+	ans := ScreenBuffer{}
+	ans.Resize(width, height)
+	return &ans
+}
+
+func (self *ScreenBuffer) Size() (width, height int) {
+	return self.width, self.height
+}
+
+// Change the size of the buffer, clearing it. The next render redraws the
+// whole screen.
+func (self *ScreenBuffer) Resize(width, height int) {
+	self.width, self.height = width, height
+	self.cells = make([]Cell, width*height)
+	self.rendered = nil
+	self.Clear()
+}
+
+// Make the next render redraw the whole screen, needed when something other
+// than the buffer has changed the contents of the screen
+func (self *ScreenBuffer) Invalidate() {
+	self.rendered = nil
+}
+
+// Fill the buffer with blank cells in the default style
+func (self *ScreenBuffer) Clear() {
+	for i := range self.cells {
+		self.cells[i] = blank_cell
+	}
+}
+
+func (self *ScreenBuffer) Cell(x, y int) Cell {
+	if x < 0 || y < 0 || x >= self.width || y >= self.height {
+		return blank_cell
+	}
+	return self.cells[y*self.width+x]
+}
+
+func (self *ScreenBuffer) is_wide(row []Cell, x int) bool {
+	return x+1 < len(row) && row[x+1].Ch == 0
+}
+
+func (self *ScreenBuffer) put(x, y int, ch rune, width int, style CellStyle) {
+	row := self.cells[y*self.width : (y+1)*self.width]
+	// dont leave halves of double width characters behind
+	if row[x].Ch == 0 && x > 0 {
+		row[x-1] = Cell{Ch: ' ', Style: row[x-1].Style}
+	}
+	if self.is_wide(row, x) {
+		row[x+1] = Cell{Ch: ' ', Style: row[x].Style}
+	}
+	row[x] = Cell{Ch: ch, Style: style}
+	if width == 2 {
+		if self.is_wide(row, x+1) {
+			row[x+2] = Cell{Ch: ' ', Style: row[x+1].Style}
+		}
+		row[x+1] = Cell{Style: style}
+	}
+}
+
+// Set the character in a single cell. Double width characters also use the
+// next cell, they are not drawn if they do not fit.
+func (self *ScreenBuffer) SetCell(x, y int, ch rune, style CellStyle) {
+	if x < 0 || y < 0 || x >= self.width || y >= self.height {
+		return
+	}
+	w := wcswidth.Runewidth(ch)
+	switch {
+	case w < 1 || ch < ' ' || ch == 0x7f:
+		return
+	case w > 1:
+		if x+1 >= self.width {
+			return
+		}
+		w = 2
+	}
+	self.put(x, y, ch, w, style)
+}
+
+// Draw text starting at the specified cell, clipped to the right edge of the
+// buffer. Returns the x position after the text. The text must not contain
+// escape codes, control characters are ignored.
+func (self *ScreenBuffer) DrawString(x, y int, text string, style CellStyle) int {
+	if y < 0 || y >= self.height {
+		return x
+	}
+	for _, ch := range text {
+		if x >= self.width {
+			break
+		}
+		w := wcswidth.Runewidth(ch)
+		if w < 1 || ch < ' ' || ch == 0x7f {
+			continue
+		}
+		if w > 1 {
+			w = 2
+		}
+		if x+w > self.width {
+			break
+		}
+		if x >= 0 {
+			self.put(x, y, ch, w, style)
+		}
+		x += w
+	}
+	return x
+}
+
+// Fill a rectangle of cells with a character, for example, a space to set
+// the background color of an area
+func (self *ScreenBuffer) Fill(x, y, width, height int, ch rune, style CellStyle) {
+	for r := y; r < y+height; r++ {
+		for c := x; c < x+width; c++ {
+			self.SetCell(c, r, ch, style)
+		}
+	}
+}
+
+type render_state struct {
+	buf              strings.Builder
+	x, y             int
+	style            CellStyle
+	style_is_unknown bool
+}
+
+func (self *render_state) move_to(x, y int) {
+	if self.x != x || self.y != y {
+		self.buf.WriteString(fmt.Sprintf(MoveCursorToTemplate, y+1, x+1))
+		self.x, self.y = x, y
+	}
+}
+
+func (self *render_state) write(c Cell, width int) {
+	if self.style_is_unknown || c.Style != self.style {
+		self.buf.WriteString(c.Style.as_sgr())
+		self.style, self.style_is_unknown = c.Style, false
+	}
+	self.buf.WriteRune(c.Ch)
+	self.x += width
+}
+
+// The escape codes to update the screen to the contents of the buffer, empty
+// if nothing has changed. Call it once per frame, after drawing all the
+// changes, since the returned changes are considered to be on the screen.
+func (self *ScreenBuffer) Render() string {
+	r := render_state{style_is_unknown: true, x: -1, y: -1}
+	full := self.rendered == nil
+	if full {
+		// clearing is cheaper than sending blank cells
+		r.buf.WriteString("\x1b[m\x1b[H\x1b[2J")
+		r.x, r.y, r.style_is_unknown = 0, 0, false
+		self.rendered = make([]Cell, len(self.cells))
+		for i := range self.rendered {
+			self.rendered[i] = blank_cell
+		}
+	}
+	// rewriting a few unchanged cells is cheaper than moving the cursor
+	const max_gap = 4
+	for y := 0; y < self.height; y++ {
+		row := self.cells[y*self.width : (y+1)*self.width]
+		prev := self.rendered[y*self.width : (y+1)*self.width]
+		for x := 0; x < self.width; x++ {
+			c := row[x]
+			// the second cells of double width characters are drawn with
+			// the first cells, which change whenever they do
+			if c.Ch == 0 || c == prev[x] {
+				continue
+			}
+			if r.y == y && r.x < x && x-r.x <= max_gap && r.x >= 0 {
+				for gx := r.x; gx < x; {
+					if row[gx].Ch == 0 {
+						// cannot start in the middle of a double width character
+						break
+					}
+					w := 1
+					if self.is_wide(row, gx) {
+						w = 2
+					}
+					r.write(row[gx], w)
+					gx += w
+				}
+			}
+			r.move_to(x, y)
+			w := 1
+			if self.is_wide(row, x) {
+				w = 2
+			}
+			r.write(c, w)
+			if r.x >= self.width {
+				// the cursor position after writing to the last column
+				// depends on the terminal
+				r.x = -1
+			}
+		}
+		copy(prev, row)
+	}
+	if r.buf.Len() == 0 && self.rendered_cursor_x == self.CursorX && self.rendered_cursor_y == self.CursorY {
+		return ""
+	}
+	if !r.style_is_unknown {
+		r.buf.WriteString("\x1b[m")
+	}
+	r.move_to(self.CursorX, self.CursorY)
+	self.rendered_cursor_x, self.rendered_cursor_y = self.CursorX, self.CursorY
+	return r.buf.String()
+}
@@ -0,0 +1,71 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestScreenBuffer(t *testing.T) {
+	s := NewScreenBuffer(10, 3)
+	row := func(y int) string {
+		ans := strings.Builder{}
+		for x := 0; x < 10; x++ {
+			if c := s.Cell(x, y); c.Ch != 0 {
+				ans.WriteRune(c.Ch)
+			}
+		}
+		return ans.String()
+	}
+	render := func(expected string) {
+		t.Helper()
+		if actual := s.Render(); actual != expected {
+			t.Fatalf("Unexpected rendering:\n%#v !=\n%#v", actual, expected)
+		}
+	}
+	bold := CellStyle{Bold: true, Fg: IndexedCellColor(1), Bg: RGBCellColor(1, 2, 3)}
+
+	if x := s.DrawString(1, 0, "ab", CellStyle{}); x != 3 {
+		t.Fatalf("Unexpected position after text: %d", x)
+	}
+	s.DrawString(0, 1, "x", bold)
+	render("\x1b[m\x1b[H\x1b[2J ab\x1b[2;1H\x1b[0;1;38;5;1;48;2;1;2;3mx\x1b[m\x1b[1;1H")
+	render("")
+
+	// only the changes are sent, small gaps are filled in rather than moving the cursor
+	s.DrawString(1, 0, "Ab", CellStyle{})
+	s.DrawString(4, 0, "c", CellStyle{})
+	s.DrawString(9, 0, "d", CellStyle{})
+	s.DrawString(7, 1, "e", CellStyle{})
+	render("\x1b[1;2H\x1b[0mAb c    d\x1b[2;8He\x1b[m\x1b[1;1H")
+	s.CursorX, s.CursorY = 2, 2
+	render("\x1b[3;3H")
+
+	// double width characters
+	s.DrawString(0, 2, "中文x", CellStyle{})
+	if row(2) != "中文x     " {
+		t.Fatalf("Unexpected row: %#v", row(2))
+	}
+	render("\x1b[3;1H\x1b[0m中文x\x1b[m\x1b[3;3H")
+	s.SetCell(1, 2, 'y', CellStyle{})
+	if row(2) != " y文x     " {
+		t.Fatalf("Overwriting half of a double width character left the other half: %#v", row(2))
+	}
+	render("\x1b[3;1H\x1b[0m y\x1b[m")
+	s.SetCell(9, 2, '中', CellStyle{})
+	if s.DrawString(8, 1, "中文", CellStyle{}) != 10 || row(1) != "x      e中" {
+		t.Fatalf("Double width characters that dont fit were drawn: %#v", row(1))
+	}
+
+	s.Invalidate()
+	if r := s.Render(); !strings.HasPrefix(r, "\x1b[m\x1b[H\x1b[2J") {
+		t.Fatalf("Invalidating did not redraw the screen: %#v", r)
+	}
+	s.Resize(5, 1)
+	s.Fill(0, 0, 2, 1, ' ', bold)
+	render("\x1b[m\x1b[H\x1b[2J\x1b[0;1;38;5;1;48;2;1;2;3m  \x1b[m\x1b[3;3H")
+}
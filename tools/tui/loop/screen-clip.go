@@ -0,0 +1,239 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+type clip_state uint8
+
+const (
+	clip_normal clip_state = iota
+	clip_esc
+	clip_csi
+	clip_string
+)
+
+// Removes the characters that would be drawn outside a virtual screen from
+// the output sent to the terminal, so that a terminal larger than the virtual
+// screen shows only what a terminal of that size would. Escape codes are sent
+// unchanged, the position of the cursor is tracked through the escape codes
+// that move it. Lines are not wrapped at the edge of the virtual screen, the
+// rest of the line is clipped instead.
+type screen_clipper struct {
+	width, height    int
+	x, y             int
+	saved_x, saved_y int
+
+	state                  clip_state
+	utf8_state, utf8_codep utils.UTF8State
+	// the bytes of the current character or escape code
+	pending []byte
+
+	last_width        int
+	last_char_visible bool
+}
+
+func new_screen_clipper(width, height int) *screen_clipper {
+	return &screen_clipper{width: width, height: height}
+}
+
+func (self *screen_clipper) clip_string(data string) string {
+	return string(self.clip(utils.UnsafeStringToBytes(data)))
+}
+
+func (self *screen_clipper) clip(data []byte) []byte {
+	ans := make([]byte, 0, len(data))
+	for _, b := range data {
+		ans = self.feed(ans, b)
+	}
+	return ans
+}
+
+func (self *screen_clipper) flush(ans []byte) []byte {
+	ans = append(ans, self.pending...)
+	self.pending = self.pending[:0]
+	self.state = clip_normal
+	return ans
+}
+
+func (self *screen_clipper) feed(ans []byte, b byte) []byte {
+	self.pending = append(self.pending, b)
+	switch self.state {
+	case clip_normal:
+		switch utils.DecodeUtf8(&self.utf8_state, &self.utf8_codep, b) {
+		case utils.UTF8_ACCEPT:
+			if self.utf8_codep == 0x1b {
+				self.state = clip_esc
+				return ans
+			}
+			if self.draw(rune(self.utf8_codep)) {
+				return self.flush(ans)
+			}
+			self.pending = self.pending[:0]
+		case utils.UTF8_REJECT:
+			// invalid UTF-8 is sent as is, for the terminal to deal with
+			self.utf8_state, self.utf8_codep = utils.UTF8_ACCEPT, utils.UTF8_ACCEPT
+			return self.flush(ans)
+		}
+	case clip_esc:
+		switch b {
+		case '[':
+			self.state = clip_csi
+		case ']', 'P', '_', '^', 'X':
+			self.state = clip_string
+		default:
+			self.handle_esc(b)
+			return self.flush(ans)
+		}
+	case clip_csi:
+		if 0x40 <= b && b <= 0x7e {
+			return self.handle_csi(ans, b)
+		}
+	case clip_string:
+		n := len(self.pending)
+		if b == 0x07 || (b == '\\' && self.pending[n-2] == 0x1b) {
+			return self.flush(ans)
+		}
+	}
+	return ans
+}
+
+func (self *screen_clipper) line_feed() {
+	// the scroll region ends at the bottom of the virtual screen, so the
+	// cursor stays on the last line, scrolling the lines above it
+	if self.y != self.height-1 {
+		self.y++
+	}
+}
+
+func (self *screen_clipper) move_down(amt int) {
+	if self.y < self.height {
+		self.y = utils.Min(self.y+amt, self.height-1)
+	} else {
+		self.y += amt
+	}
+}
+
+// Update the cursor position for a character, returning whether the
+// character is visible
+func (self *screen_clipper) draw(ch rune) bool {
+	switch ch {
+	case '\r':
+		self.x = 0
+	case '\n', '\v', '\f':
+		self.line_feed()
+	case '\b':
+		self.x = utils.Max(0, self.x-1)
+	case '\t':
+		self.x = (self.x/8 + 1) * 8
+	default:
+		if ch < 0x20 || (0x7f <= ch && ch < 0xa0) {
+			return true
+		}
+		w := wcswidth.Runewidth(ch)
+		if w <= 0 {
+			// combining characters are drawn in the cell of the previous character
+			return self.last_char_visible
+		}
+		self.last_width = w
+		self.last_char_visible = self.x+w <= self.width && self.y < self.height
+		self.x += w
+		return self.last_char_visible
+	}
+	return true
+}
+
+func (self *screen_clipper) handle_esc(b byte) {
+	switch b {
+	case '7':
+		self.saved_x, self.saved_y = self.x, self.y
+	case '8':
+		self.x, self.y = self.saved_x, self.saved_y
+	case 'D':
+		self.line_feed()
+	case 'E':
+		self.x = 0
+		self.line_feed()
+	case 'M':
+		self.y = utils.Max(0, self.y-1)
+	case 'c':
+		self.x, self.y = 0, 0
+	}
+}
+
+func (self *screen_clipper) handle_csi(ans []byte, final byte) []byte {
+	params := string(self.pending[2 : len(self.pending)-1])
+	if strings.Trim(params, "0123456789;") != "" {
+		// private and intermediate bytes, as in CSI ? 25 h, are only
+		// used by escape codes that do not move the cursor
+		return self.flush(ans)
+	}
+	var nums []int
+	if params != "" {
+		for _, x := range strings.Split(params, ";") {
+			n, _ := strconv.Atoi(x)
+			nums = append(nums, n)
+		}
+	}
+	arg := func(i int) int {
+		if i < len(nums) && nums[i] > 0 {
+			return nums[i]
+		}
+		return 1
+	}
+	switch final {
+	case 'A':
+		self.y = utils.Max(0, self.y-arg(0))
+	case 'B', 'e':
+		self.move_down(arg(0))
+	case 'C', 'a':
+		self.x += arg(0)
+	case 'D':
+		self.x = utils.Max(0, self.x-arg(0))
+	case 'E':
+		self.x = 0
+		self.move_down(arg(0))
+	case 'F':
+		self.x = 0
+		self.y = utils.Max(0, self.y-arg(0))
+	case 'G', '`':
+		self.x = arg(0) - 1
+	case 'd':
+		self.y = arg(0) - 1
+	case 'H', 'f':
+		self.y, self.x = arg(0)-1, arg(1)-1
+	case 's':
+		if params == "" {
+			self.saved_x, self.saved_y = self.x, self.y
+		}
+	case 'u':
+		if params == "" {
+			self.x, self.y = self.saved_x, self.saved_y
+		}
+	case 'b':
+		// repeat the previous character only as many times as fit
+		if self.last_width > 0 {
+			n, visible := arg(0), 0
+			if self.last_char_visible {
+				visible = utils.Min(n, utils.Max(0, self.width-self.x)/self.last_width)
+			}
+			self.x += n * self.last_width
+			self.pending = self.pending[:0]
+			self.state = clip_normal
+			if visible > 0 {
+				ans = append(ans, fmt.Sprintf("\x1b[%db", visible)...)
+			}
+			return ans
+		}
+	}
+	return self.flush(ans)
+}
@@ -0,0 +1,80 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestScreenClipper(t *testing.T) {
+	test := func(input, expected string) {
+		t.Helper()
+		c := new_screen_clipper(4, 2)
+		if diff := cmp.Diff(expected, c.clip_string(input)); diff != "" {
+			t.Fatalf("Incorrectly clipped: %#v\n%s", input, diff)
+		}
+		// escape codes and characters split across writes
+		c = new_screen_clipper(4, 2)
+		actual := ""
+		for i := 0; i < len(input); i++ {
+			actual += string(c.clip([]byte{input[i]}))
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Incorrectly clipped when written a byte at a time: %#v\n%s", input, diff)
+		}
+	}
+
+	test("abc", "abc")
+	test("abcdef", "abcd")
+	test("abcdef\r\nxy", "abcd\r\nxy")
+	test("a\x1b[31mbcdef\x1b[m", "a\x1b[31mbcd\x1b[m")
+	test("ab\x1b]2;a long title\x07cdef", "ab\x1b]2;a long title\x07cd")
+	test("\x1b_Gabcdef\x1b\\abcdef", "\x1b_Gabcdef\x1b\\abcd")
+	test("\x1b[?25labcde", "\x1b[?25labcd")
+	// wide and combining characters
+	test("abc世", "abc")
+	test("a世b世", "a世b")
+	test("abcéfé", "abcé")
+	// cursor movement
+	test("\x1b[1;3Habcd", "\x1b[1;3Hab")
+	test("\x1b[3;1Habc\x1b[2;1Hx", "\x1b[3;1H\x1b[2;1Hx")
+	test("\x1b[5Gx\x1b[4Gy", "\x1b[5G\x1b[4Gy")
+	test("abcd\x1b[2Dxyz", "abcd\x1b[2Dxy")
+	test("ab\x1b[3Cx\rx", "ab\x1b[3C\rx")
+	test("ab\x1b7cdef\x1b8xyz", "ab\x1b7cd\x1b8xy")
+	test("ab\x1b[scdef\x1b[uxyz", "ab\x1b[scd\x1b[uxy")
+	test("abcd\bx\ty", "abcd\bx\t")
+	// lines past the bottom scroll the screen rather than being clipped
+	test("a\nb\nc\nd", "a\nb\nc\nd")
+	test("a\x1b[9Bb", "a\x1b[9Bb")
+	test("\x1b[2;1H\x1b[Bx\x1bMy", "\x1b[2;1H\x1b[Bx\x1bMy")
+	// repeated characters
+	test("ab\x1b[5b", "ab\x1b[2b")
+	test("abcd\x1b[5bx", "abcd")
+	test("\x1b[3;1Ha\x1b[2b", "\x1b[3;1H")
+	// invalid UTF-8 is passed through
+	test("a\xffbcdef", "a\xffbcd")
+}
+
+func TestVirtualScreenSizeClipsOutput(t *testing.T) {
+	lp, _ := New()
+	lp.VirtualScreenSize(ScreenSize{WidthCells: 3, HeightCells: 2})
+	lp.QueueWriteString("abcdef")
+	lp.QueueWriteBytesCopy([]byte("\r\nxyzw"))
+	var actual []string
+	for _, w := range lp.pending_writes {
+		if w.bytes != nil {
+			actual = append(actual, string(w.bytes))
+		} else {
+			actual = append(actual, w.str)
+		}
+	}
+	if diff := cmp.Diff([]string{"abc", "\r\nxyz"}, actual); diff != "" {
+		t.Fatalf("Output not clipped to the virtual screen size:\n%s", diff)
+	}
+}
@@ -0,0 +1,98 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Set this to a size of the form COLSxROWS or COLSxROWS:WIDTHxHEIGHT with
+// the width and height in pixels to have kittens behave as though the
+// terminal has that size
+const VirtualScreenSizeEnvVar = "KITTY_VIRTUAL_SCREEN_SIZE"
+
+// The cell size used for virtual screens when neither the pixel size nor the
+// size of the real terminal is known
+const default_cell_width, default_cell_height = 8, 16
+
+func parse_dimensions(x string) (a, b uint, err error) {
+	w, h, found := utils.Cut(x, "x")
+	if !found {
+		return 0, 0, fmt.Errorf("%#v is not of the form AxB", x)
+	}
+	aa, err := strconv.ParseUint(w, 10, 0)
+	if err != nil {
+		return
+	}
+	bb, err := strconv.ParseUint(h, 10, 0)
+	if err != nil {
+		return
+	}
+	if aa == 0 || bb == 0 {
+		return 0, 0, fmt.Errorf("%#v has a zero dimension", x)
+	}
+	return uint(aa), uint(bb), nil
+}
+
+// Parse a size of the form used by VirtualScreenSizeEnvVar. The pixel size
+// and cell size are zero if not specified.
+func ParseVirtualScreenSize(spec string) (ans ScreenSize, err error) {
+	cells, pixels, has_pixels := utils.Cut(strings.TrimSpace(spec), ":")
+	if ans.WidthCells, ans.HeightCells, err = parse_dimensions(cells); err != nil {
+		return ans, fmt.Errorf("Invalid virtual screen size: %s", err)
+	}
+	if has_pixels {
+		if ans.WidthPx, ans.HeightPx, err = parse_dimensions(pixels); err != nil {
+			return ans, fmt.Errorf("Invalid virtual screen size: %s", err)
+		}
+		ans.CellWidth, ans.CellHeight = ans.WidthPx/ans.WidthCells, ans.HeightPx/ans.HeightCells
+	}
+	return
+}
+
+// Pretend the terminal has the specified size regardless of the size of the
+// actual terminal, so that the output of kittens is reproducible, for
+// screenshots and tests. Resizing the terminal has no effect and output is
+// clipped to the specified size, lines are not wrapped at its right edge.
+// When using the alternate screen, the scroll region is restricted to the
+// specified number of lines. If the pixel size is zero, the cell size of the
+// real terminal is used.
+func (self *Loop) VirtualScreenSize(sz ScreenSize) *Loop {
+	sz.updated = false
+	self.virtual_screen_size = &sz
+	self.screen_clipper = new_screen_clipper(int(sz.WidthCells), int(sz.HeightCells))
+	self.terminal_options.scroll_region_height = sz.HeightCells
+	self.screen_size.updated = false
+	return self
+}
+
+func (self *Loop) apply_virtual_screen_size_from_env() {
+	if spec := os.Getenv(VirtualScreenSizeEnvVar); spec != "" {
+		if sz, err := ParseVirtualScreenSize(spec); err == nil {
+			self.VirtualScreenSize(sz)
+		}
+	}
+}
+
+func (self *Loop) update_virtual_screen_size() {
+	s := &self.screen_size
+	*s = *self.virtual_screen_size
+	s.updated = true
+	if s.WidthPx > 0 {
+		return
+	}
+	s.CellWidth, s.CellHeight = default_cell_width, default_cell_height
+	if self.controlling_term != nil {
+		if ws, err := self.controlling_term.GetSize(); err == nil && ws.Col > 0 && ws.Row > 0 && ws.Xpixel > 0 && ws.Ypixel > 0 {
+			s.CellWidth, s.CellHeight = uint(ws.Xpixel)/uint(ws.Col), uint(ws.Ypixel)/uint(ws.Row)
+		}
+	}
+	s.WidthPx, s.HeightPx = s.CellWidth*s.WidthCells, s.CellHeight*s.HeightCells
+}
@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ = fmt.Print
+
+func TestParseVirtualScreenSize(t *testing.T) {
+	ok := func(spec string, expected ScreenSize) {
+		actual, err := ParseVirtualScreenSize(spec)
+		if err != nil {
+			t.Fatalf("Failed to parse %#v with error: %s", spec, err)
+		}
+		if diff := cmp.Diff(expected, actual, cmp.AllowUnexported(ScreenSize{})); diff != "" {
+			t.Fatalf("Incorrect size for %#v:\n%s", spec, diff)
+		}
+	}
+	ok("80x24", ScreenSize{WidthCells: 80, HeightCells: 24})
+	ok(" 100x30 ", ScreenSize{WidthCells: 100, HeightCells: 30})
+	ok("80x24:800x480", ScreenSize{WidthCells: 80, HeightCells: 24, WidthPx: 800, HeightPx: 480, CellWidth: 10, CellHeight: 20})
+	for _, spec := range []string{"", "80", "80x", "x24", "0x24", "80x0", "-1x24", "80x24:", "80x24:800", "80x24:0x480", "axb"} {
+		if _, err := ParseVirtualScreenSize(spec); err == nil {
+			t.Fatalf("Invalid size not rejected: %#v", spec)
+		}
+	}
+}
+
+func TestVirtualScreenSize(t *testing.T) {
+	t.Setenv(VirtualScreenSizeEnvVar, "40x10")
+	lp, _ := New()
+	if err := lp.update_screen_size(); err != nil {
+		t.Fatal(err)
+	}
+	expected := ScreenSize{WidthCells: 40, HeightCells: 10, CellWidth: default_cell_width, CellHeight: default_cell_height,
+		WidthPx: 40 * default_cell_width, HeightPx: 10 * default_cell_height, updated: true}
+	if diff := cmp.Diff(expected, lp.screen_size, cmp.AllowUnexported(ScreenSize{})); diff != "" {
+		t.Fatalf("Incorrect size from the environment:\n%s", diff)
+	}
+	// the size of the terminal is ignored on resize
+	lp.screen_size.WidthCells = 1
+	if err := lp.on_SIGWINCH(); err != nil || lp.screen_size.WidthCells != 1 {
+		t.Fatalf("Resizing the terminal changed the virtual size: %v %d", err, lp.screen_size.WidthCells)
+	}
+
+	sz, _ := ParseVirtualScreenSize("20x5:200x100")
+	lp.VirtualScreenSize(sz)
+	if err := lp.update_screen_size(); err != nil {
+		t.Fatal(err)
+	}
+	sz.updated = true
+	if diff := cmp.Diff(sz, lp.screen_size, cmp.AllowUnexported(ScreenSize{})); diff != "" {
+		t.Fatalf("Incorrect size with pixel size:\n%s", diff)
+	}
+
+	lp.terminal_options.alternate_screen = true
+	if q := lp.terminal_options.SetStateEscapeCodes(); !strings.Contains(q, "\x1b[1;5r") {
+		t.Fatalf("Scroll region not set: %#v", q)
+	}
+	if q := lp.terminal_options.ResetStateEscapeCodes(); !strings.Contains(q, "\x1b[r") {
+		t.Fatalf("Scroll region not reset: %#v", q)
+	}
+	lp.terminal_options.alternate_screen = false
+	if q := lp.terminal_options.SetStateEscapeCodes(); strings.Contains(q, "\x1b[1;5r") {
+		t.Fatalf("Scroll region set without the alternate screen: %#v", q)
+	}
+
+	t.Setenv(VirtualScreenSizeEnvVar, "garbage")
+	if lp, _ = New(); lp.virtual_screen_size != nil {
+		t.Fatalf("Invalid size from the environment was used")
+	}
+}
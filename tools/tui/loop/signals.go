@@ -0,0 +1,124 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+// The signals the loop always handles, see SubscribeToSignal() to change how
+// they are handled
+var default_handled_signals = []unix.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGTSTP, unix.SIGHUP, unix.SIGWINCH, unix.SIGPIPE}
+
+// Called on the goroutine running the loop when a signal is received. Return
+// true to prevent the default handling of the signal by the loop, for
+// example, to not quit on SIGHUP.
+type SignalHandler func(sig unix.Signal) (handled bool, err error)
+
+type signal_subscription struct {
+	id      IdType
+	sig     unix.Signal
+	handler SignalHandler
+}
+
+func is_default_handled_signal(sig unix.Signal) bool {
+	for _, q := range default_handled_signals {
+		if q == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// Call handler whenever sig is received while the loop is running. Unlike
+// installing a signal handler with the signal package, handler runs on the
+// goroutine running the loop, so it does not race with the other callbacks.
+// Can be called before or while the loop is running. Multiple handlers for
+// the same signal are called in the order they were subscribed. Note that
+// signals that arrive in quick succession can be delivered only once, so a
+// handler for SIGCHLD must check all the children it is interested in, see
+// ReapChild(). Returns an id for UnsubscribeFromSignal().
+func (self *Loop) SubscribeToSignal(sig unix.Signal, handler SignalHandler) IdType {
+	self.signal_subscription_id_counter++
+	self.signal_subscriptions = append(self.signal_subscriptions, &signal_subscription{id: self.signal_subscription_id_counter, sig: sig, handler: handler})
+	if self.signal_channel != nil {
+		signal.Notify(self.signal_channel, sig)
+	}
+	return self.signal_subscription_id_counter
+}
+
+// Remove a handler added by SubscribeToSignal(). Once the last handler for a
+// signal that the loop does not handle itself is removed, the signal has its
+// default effect again. Returns false if no such handler exists.
+func (self *Loop) UnsubscribeFromSignal(id IdType) bool {
+	for i, s := range self.signal_subscriptions {
+		if s.id == id {
+			self.signal_subscriptions = append(self.signal_subscriptions[:i:i], self.signal_subscriptions[i+1:]...)
+			if self.signal_channel != nil && !is_default_handled_signal(s.sig) && !self.has_signal_subscription(s.sig) {
+				signal.Reset(s.sig)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Loop) has_signal_subscription(sig unix.Signal) bool {
+	for _, s := range self.signal_subscriptions {
+		if s.sig == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// All signals that have to be delivered to the loop
+func (self *Loop) signals_to_handle() []os.Signal {
+	ans := make([]os.Signal, 0, len(default_handled_signals)+len(self.signal_subscriptions))
+	for _, sig := range default_handled_signals {
+		ans = append(ans, sig)
+	}
+	for _, s := range self.signal_subscriptions {
+		if !is_default_handled_signal(s.sig) {
+			ans = append(ans, s.sig)
+		}
+	}
+	return ans
+}
+
+func (self *Loop) dispatch_signal_to_subscribers(sig unix.Signal) (handled bool, err error) {
+	// handlers can change the subscriptions
+	subs := make([]*signal_subscription, 0, len(self.signal_subscriptions))
+	for _, s := range self.signal_subscriptions {
+		if s.sig == sig {
+			subs = append(subs, s)
+		}
+	}
+	for _, s := range subs {
+		h, err := s.handler(sig)
+		if err != nil {
+			return true, err
+		}
+		handled = handled || h
+	}
+	return
+}
+
+// Reap the child process with the specified pid if it has exited, without
+// waiting. For use in a SIGCHLD handler, for children started with
+// os.StartProcess() or exec.Cmd.Start() that are not waited for otherwise.
+// Children that are waited for with exec.Cmd.Wait(), including the ones
+// started by the loop, must not be reaped with it.
+func ReapChild(pid int) (exited bool, status unix.WaitStatus, err error) {
+	wpid, err := unix.Wait4(pid, &status, unix.WNOHANG, nil)
+	if err != nil {
+		return false, status, err
+	}
+	return wpid == pid, status, nil
+}
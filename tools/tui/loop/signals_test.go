@@ -0,0 +1,94 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+var _ = fmt.Print
+
+func TestSignalSubscriptions(t *testing.T) {
+	lp, _ := New()
+	var received []string
+	subscribe := func(name string, sig unix.Signal, handled bool) IdType {
+		return lp.SubscribeToSignal(sig, func(s unix.Signal) (bool, error) {
+			received = append(received, name+":"+s.String())
+			return handled, nil
+		})
+	}
+	test := func(sig unix.Signal, expected ...string) {
+		received = nil
+		if err := lp.on_signal(sig); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(expected, received); diff != "" {
+			t.Fatalf("Unexpected handlers called for %s:\n%s", sig, diff)
+		}
+	}
+	a := subscribe("a", unix.SIGHUP, false)
+	subscribe("b", unix.SIGHUP, false)
+	lp.keep_going = true
+	test(unix.SIGHUP, "a:hangup", "b:hangup")
+	if lp.keep_going {
+		t.Fatalf("Default handling of SIGHUP was skipped")
+	}
+	c := subscribe("c", unix.SIGHUP, true)
+	lp.keep_going = true
+	test(unix.SIGHUP, "a:hangup", "b:hangup", "c:hangup")
+	if !lp.keep_going {
+		t.Fatalf("Default handling of handled SIGHUP was not skipped")
+	}
+	if !lp.UnsubscribeFromSignal(a) || !lp.UnsubscribeFromSignal(c) || lp.UnsubscribeFromSignal(c) {
+		t.Fatalf("Unsubscribing did not work")
+	}
+	test(unix.SIGHUP, "b:hangup")
+	test(unix.SIGUSR1)
+
+	// signals subscribed to while the loop is running are delivered to it
+	lp.signal_channel = make(chan os.Signal, 16)
+	defer func() { lp.signal_channel = nil }()
+	usr1 := subscribe("usr1", unix.SIGUSR1, false)
+	chld := subscribe("chld", unix.SIGCHLD, false)
+	wait_for := func(sig unix.Signal) {
+		select {
+		case s := <-lp.signal_channel:
+			if s != sig {
+				t.Fatalf("Received %s instead of %s", s, sig)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s", sig)
+		}
+	}
+	if err := unix.Kill(os.Getpid(), unix.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(unix.SIGUSR1)
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	wait_for(unix.SIGCHLD)
+	for {
+		exited, status, err := ReapChild(cmd.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exited {
+			if status.ExitStatus() != 0 {
+				t.Fatalf("Unexpected exit status of child: %d", status.ExitStatus())
+			}
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	lp.UnsubscribeFromSignal(usr1)
+	lp.UnsubscribeFromSignal(chld)
+}
@@ -0,0 +1,181 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"kitty/tools/tty"
+)
+
+var _ = fmt.Print
+
+type subprocess struct {
+	id  IdType
+	cmd *exec.Cmd
+	pty *os.File
+	// set when the size of the pseudo-terminal is managed by the caller
+	fixed_size bool
+
+	write_mutex   sync.Mutex
+	pending_input [][]byte
+	input_queued  chan struct{}
+	closed        chan struct{}
+}
+
+// Queue data to be written to the pseudo-terminal, so that the loop is never
+// blocked by a subprocess that is not reading its input
+func (self *subprocess) queue_input(data []byte) {
+	self.write_mutex.Lock()
+	self.pending_input = append(self.pending_input, append([]byte{}, data...))
+	self.write_mutex.Unlock()
+	select {
+	case self.input_queued <- struct{}{}:
+	default:
+	}
+}
+
+// Runs in its own goroutine, writing queued input to the pseudo-terminal until
+// the subprocess is closed. Write errors mean the subprocess is gone, which is
+// reported by read_from_subprocess, so the remaining input is discarded.
+func write_to_subprocess(p *subprocess) {
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-p.input_queued:
+		}
+		p.write_mutex.Lock()
+		pending := p.pending_input
+		p.pending_input = nil
+		p.write_mutex.Unlock()
+		for _, data := range pending {
+			if _, err := p.pty.Write(data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (self *subprocess) close() {
+	close(self.closed)
+	self.pty.Close()
+}
+
+type subprocess_event struct {
+	id     IdType
+	data   []byte
+	exited bool
+	err    error
+}
+
+func (self *Loop) run_subprocess(cmd *exec.Cmd) (IdType, error) {
+	if self.subprocesses == nil {
+		return 0, fmt.Errorf("Cannot run subprocesses before starting the run loop, run them in OnInitialize instead")
+	}
+	sz, err := self.ScreenSize()
+	if err != nil {
+		return 0, err
+	}
+	master, slave, err := tty.OpenPtyPair(uint16(sz.HeightCells), uint16(sz.WidthCells))
+	if err != nil {
+		return 0, err
+	}
+	defer slave.Close()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// make the pseudo-terminal the controlling terminal of the child, which
+	// is fd 0, in a new session
+	cmd.SysProcAttr.Setsid, cmd.SysProcAttr.Setctty, cmd.SysProcAttr.Ctty = true, true, 0
+	if err = cmd.Start(); err != nil {
+		master.Close()
+		return 0, fmt.Errorf("Failed to run %s with error: %w", cmd.Path, err)
+	}
+	self.subprocess_id_counter++
+	p := subprocess{id: self.subprocess_id_counter, cmd: cmd, pty: master, input_queued: make(chan struct{}, 1), closed: make(chan struct{})}
+	self.subprocesses[p.id] = &p
+	go read_from_subprocess(&p, self.subprocess_channel, self.subprocess_done_channel)
+	go write_to_subprocess(&p)
+	return p.id, nil
+}
+
+// Runs in its own goroutine, sending the output of the subprocess and its exit
+// status to the main loop
+func read_from_subprocess(p *subprocess, events chan<- subprocess_event, done <-chan struct{}) {
+	send := func(ev subprocess_event) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	buf := make([]byte, 8192)
+	for {
+		n, err := p.pty.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			if !send(subprocess_event{id: p.id, data: data}) {
+				break
+			}
+		}
+		if err != nil {
+			// reading fails with EIO once all processes have closed the
+			// slave side of the pseudo-terminal
+			break
+		}
+	}
+	err := p.cmd.Wait()
+	send(subprocess_event{id: p.id, exited: true, err: err})
+}
+
+func (self *Loop) dispatch_subprocess_event(ev subprocess_event) error {
+	p := self.subprocesses[ev.id]
+	if p == nil {
+		return nil
+	}
+	if ev.exited {
+		delete(self.subprocesses, ev.id)
+		p.close()
+		if self.OnSubprocessExit != nil {
+			return self.OnSubprocessExit(ev.id, ev.err)
+		}
+		return nil
+	}
+	if self.OnSubprocessData != nil {
+		return self.OnSubprocessData(ev.id, ev.data)
+	}
+	return nil
+}
+
+func (self *Loop) resize_subprocesses() {
+	for _, p := range self.subprocesses {
+		if !p.fixed_size {
+			tty.SetPtySize(p.pty, uint16(self.screen_size.HeightCells), uint16(self.screen_size.WidthCells))
+		}
+	}
+}
+
+// Closing the pseudo-terminals causes the kernel to send SIGHUP to the
+// subprocesses
+func (self *Loop) close_subprocesses() {
+	close(self.subprocess_done_channel)
+	for _, p := range self.subprocesses {
+		p.close()
+	}
+	self.subprocesses = nil
+}
+
+func (self *Loop) subprocess_for_id(id IdType) (*subprocess, error) {
+	if p := self.subprocesses[id]; p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("No subprocess with id: %d is running", id)
+}
@@ -0,0 +1,113 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func loop_for_subprocess_test(t *testing.T) *Loop {
+	lp := &Loop{}
+	lp.screen_size = ScreenSize{WidthCells: 80, HeightCells: 24, updated: true}
+	lp.subprocesses = make(map[IdType]*subprocess)
+	lp.subprocess_channel = make(chan subprocess_event, 64)
+	lp.subprocess_done_channel = make(chan struct{})
+	t.Cleanup(lp.close_subprocesses)
+	return lp
+}
+
+// Dispatch events from the subprocesses until the subprocess with the
+// specified id exits
+func dispatch_until_exit(t *testing.T, lp *Loop, id IdType) {
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-lp.subprocess_channel:
+			if err := lp.dispatch_subprocess_event(ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.exited && ev.id == id {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("Timed out waiting for the subprocess to exit")
+		}
+	}
+}
+
+func TestSubprocessOutputAndExit(t *testing.T) {
+	lp := loop_for_subprocess_test(t)
+	var output bytes.Buffer
+	exit_errors := map[IdType]error{}
+	lp.OnSubprocessData = func(id IdType, data []byte) error {
+		output.Write(data)
+		return nil
+	}
+	lp.OnSubprocessExit = func(id IdType, err error) error {
+		exit_errors[id] = err
+		return nil
+	}
+
+	id, err := lp.RunSubprocess(exec.Command("sh", "-c", "stty size; read line; echo got:$line; exit 3"))
+	if err != nil {
+		t.Skipf("Could not run a subprocess: %s", err)
+	}
+	if err = lp.WriteToSubprocess(id, []byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	dispatch_until_exit(t, lp, id)
+	if actual := output.String(); !strings.Contains(actual, "24 80") || !strings.Contains(actual, "got:hello") {
+		t.Fatalf("Unexpected output from the subprocess: %#v", actual)
+	}
+	var ee *exec.ExitError
+	if err, found := exit_errors[id]; !found || !errors.As(err, &ee) || ee.ExitCode() != 3 {
+		t.Fatalf("Exit status of the subprocess not reported: %v", err)
+	}
+	if len(lp.subprocesses) != 0 {
+		t.Fatalf("The subprocess was not removed after exiting")
+	}
+	if err = lp.WriteToSubprocess(id, []byte("x")); err == nil {
+		t.Fatalf("Writing to a subprocess that has exited did not fail")
+	}
+	// events for subprocesses that are gone are ignored
+	if err = lp.dispatch_subprocess_event(subprocess_event{id: id, data: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubprocessWritesDoNotBlock(t *testing.T) {
+	lp := loop_for_subprocess_test(t)
+	id, err := lp.RunSubprocess(exec.Command("sleep", "10"))
+	if err != nil {
+		t.Skipf("Could not run a subprocess: %s", err)
+	}
+	// far more than fits in the buffer of the pseudo-terminal, and the
+	// subprocess never reads it
+	data := bytes.Repeat([]byte("a"), 4096)
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 256; i++ {
+			if err := lp.WriteToSubprocess(id, data); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Writing to a subprocess that is not reading its input blocked")
+	}
+}
@@ -53,6 +53,7 @@ const (
 	ALTERNATE_SCREEN       Mode = 1049 | private
 	BRACKETED_PASTE        Mode = 2004 | private
 	PENDING_UPDATE         Mode = 2026 | private
+	COLOR_SCHEME_UPDATES   Mode = 2031 | private
 	HANDLE_TERMIOS_SIGNALS Mode = kitty.HandleTermiosSignals | private
 )
 
@@ -86,6 +87,10 @@ const (
 type TerminalStateOptions struct {
 	alternate_screen, kitty_keyboard_mode, restore_colors bool
 	mouse_tracking                                        MouseTracking
+	color_scheme_updates                                  bool
+	focus_tracking                                        bool
+	// When non-zero, output is confined to this many lines at the top of the screen
+	scroll_region_height uint
 }
 
 func set_modes(sb *strings.Builder, modes ...Mode) {
@@ -114,19 +119,33 @@ func (self *TerminalStateOptions) SetStateEscapeCodes() string {
 	sb.WriteString(DECSACE_DEFAULT_REGION_SELECT)
 	reset_modes(&sb,
 		IRM, DECKM, DECSCNM, BRACKETED_PASTE, FOCUS_TRACKING,
-		MOUSE_BUTTON_TRACKING, MOUSE_MOTION_TRACKING, MOUSE_MOVE_TRACKING, MOUSE_UTF8_MODE, MOUSE_SGR_MODE)
+		MOUSE_BUTTON_TRACKING, MOUSE_MOTION_TRACKING, MOUSE_MOVE_TRACKING, MOUSE_UTF8_MODE, MOUSE_SGR_MODE, MOUSE_SGR_PIXEL_MODE)
 	set_modes(&sb, DECARM, DECAWM, DECTCEM)
 	if self.alternate_screen {
 		set_modes(&sb, ALTERNATE_SCREEN)
 		sb.WriteString(CLEAR_SCREEN)
+		if self.scroll_region_height > 0 {
+			sb.WriteString(fmt.Sprintf("\033[1;%dr", self.scroll_region_height))
+		}
 	}
 	if self.kitty_keyboard_mode {
 		sb.WriteString("\033[>31u")
 	} else {
 		sb.WriteString("\033[>u")
 	}
+	if self.color_scheme_updates {
+		// subscribe to changes and query the current color scheme
+		sb.WriteString(COLOR_SCHEME_UPDATES.EscapeCodeToSet())
+		sb.WriteString("\033[?996n")
+	}
+	if self.focus_tracking {
+		sb.WriteString(FOCUS_TRACKING.EscapeCodeToSet())
+	}
 	if self.mouse_tracking != NO_MOUSE_TRACKING {
-		sb.WriteString(MOUSE_SGR_PIXEL_MODE.EscapeCodeToSet())
+		// terminals that do not support the SGR-pixel protocol fall back to
+		// SGR, query support to know how to interpret coordinates
+		set_modes(&sb, MOUSE_SGR_MODE, MOUSE_SGR_PIXEL_MODE)
+		sb.WriteString("\033[?1016$p")
 		switch self.mouse_tracking {
 		case BUTTONS_ONLY_MOUSE_TRACKING:
 			sb.WriteString(MOUSE_BUTTON_TRACKING.EscapeCodeToSet())
@@ -143,7 +162,16 @@ func (self *TerminalStateOptions) ResetStateEscapeCodes() string {
 	var sb strings.Builder
 	sb.Grow(64)
 	sb.WriteString("\033[<u")
+	if self.color_scheme_updates {
+		sb.WriteString(COLOR_SCHEME_UPDATES.EscapeCodeToReset())
+	}
+	if self.focus_tracking {
+		sb.WriteString(FOCUS_TRACKING.EscapeCodeToReset())
+	}
 	if self.alternate_screen {
+		if self.scroll_region_height > 0 {
+			sb.WriteString("\033[r")
+		}
 		sb.WriteString(ALTERNATE_SCREEN.EscapeCodeToReset())
 	} else {
 		sb.WriteString(SAVE_CURSOR)
@@ -12,6 +12,9 @@ func (self *Loop) add_timer(interval time.Duration, repeats bool, callback Timer
 	if self.timers == nil {
 		return 0, fmt.Errorf("Cannot add timers before starting the run loop, add them in OnInitialize instead")
 	}
+	if repeats && interval <= 0 {
+		return 0, fmt.Errorf("The interval for a repeating timer must be positive, not: %v", interval)
+	}
 	self.timer_id_counter++
 	t := timer{interval: interval, repeats: repeats, callback: callback, id: self.timer_id_counter}
 	t.update_deadline(time.Now())
@@ -26,6 +29,7 @@ func (self *Loop) remove_timer(id IdType) bool {
 	}
 	for i := 0; i < len(self.timers); i++ {
 		if self.timers[i].id == id {
+			self.timers[i].removed = true
 			self.timers = append(self.timers[:i], self.timers[i+1:]...)
 			return true
 		}
@@ -34,24 +38,27 @@ func (self *Loop) remove_timer(id IdType) bool {
 }
 
 func (self *Loop) dispatch_timers(now time.Time) error {
-	updated := false
+	// callbacks can add and remove timers, so iterate over a copy
 	self.timers_temp = self.timers_temp[:0]
 	self.timers_temp = append(self.timers_temp, self.timers...)
-	for i, t := range self.timers_temp {
-		if now.After(t.deadline) {
-			err := t.callback(t.id)
-			if err != nil {
-				return err
-			}
-			if t.repeats {
-				t.update_deadline(now)
-				updated = true
-			} else {
-				self.timers = append(self.timers[:i], self.timers[i+1:]...)
-			}
+	dispatched := false
+	for _, t := range self.timers_temp {
+		if t.removed || now.Before(t.deadline) {
+			continue
+		}
+		dispatched = true
+		if !t.repeats {
+			self.remove_timer(t.id)
+		}
+		err := t.callback(t.id)
+		if err != nil {
+			return err
+		}
+		if t.repeats && !t.removed {
+			t.reschedule(now)
 		}
 	}
-	if updated {
+	if dispatched {
 		self.sort_timers()
 	}
 	return nil
@@ -0,0 +1,72 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package loop
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var _ = fmt.Print
+
+func TestTimers(t *testing.T) {
+	lp, _ := New()
+	if _, err := lp.AddTimer(time.Second, false, nil); err == nil {
+		t.Fatalf("Adding a timer before the loop is running did not fail")
+	}
+	lp.timers = make([]*timer, 0, 1)
+	if _, err := lp.AddTimer(0, true, nil); err == nil {
+		t.Fatalf("Adding a repeating timer with no interval did not fail")
+	}
+	fired := []IdType{}
+	record := func(id IdType) error {
+		fired = append(fired, id)
+		return nil
+	}
+	start := time.Now()
+	once, _ := lp.AddTimer(time.Second, false, record)
+	repeating, _ := lp.AddTimer(3*time.Second, true, record)
+	removes_once, _ := lp.AddTimer(500*time.Millisecond, false, func(id IdType) error {
+		if lp.RemoveTimer(id) {
+			t.Fatalf("A timer that has fired was removed")
+		}
+		return record(id)
+	})
+	// make the deadlines independent of how long adding the timers took
+	for _, x := range lp.timers {
+		x.update_deadline(start)
+	}
+	lp.sort_timers()
+	dispatch := func(after time.Duration, expected ...IdType) {
+		fired = fired[:0]
+		if err := lp.dispatch_timers(start.Add(after)); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(fired) != fmt.Sprint(expected) {
+			t.Fatalf("Timers fired after %v: %v != %v", after, fired, expected)
+		}
+	}
+	dispatch(100 * time.Millisecond)
+	dispatch(time.Second, removes_once, once)
+	dispatch(2 * time.Second)
+	dispatch(3500*time.Millisecond, repeating)
+	// repeating timers do not drift
+	if d := lp.timers[0].deadline.Sub(start); d != 6*time.Second {
+		t.Fatalf("Repeating timer rescheduled to: %v", d)
+	}
+	// missed firings are skipped
+	dispatch(20*time.Second, repeating)
+	if d := lp.timers[0].deadline.Sub(start); d != 23*time.Second {
+		t.Fatalf("Repeating timer rescheduled to: %v", d)
+	}
+	lp.AddTimer(0, false, func(IdType) error {
+		lp.RemoveTimer(repeating)
+		return nil
+	})
+	fired = fired[:0]
+	lp.dispatch_timers(start.Add(time.Minute))
+	if len(fired) > 0 || len(lp.timers) > 0 {
+		t.Fatalf("Removing a timer from a callback did not stop it from firing: %v", fired)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"strings"
-	"unicode"
 
 	"kitty/tools/utils"
 	"kitty/tools/wcswidth"
@@ -164,6 +163,19 @@ func (self *Readline) move_cursor_vertically(amt int) (ans int) {
 	return ans
 }
 
+// Move the cursor by amt lines of text rather than lines on the screen,
+// keeping its horizontal position in the line, when possible
+func (self *Readline) move_cursor_by_logical_lines(amt int) (ans int) {
+	y := utils.Min(utils.Max(0, self.input_state.cursor.Y+amt), len(self.input_state.lines)-1)
+	ans = y - self.input_state.cursor.Y
+	if ans != 0 {
+		line := self.input_state.lines[self.input_state.cursor.Y]
+		w := wcswidth.Stringwidth(line[:self.input_state.cursor.X])
+		self.input_state.cursor = Position{Y: y, X: len(wcswidth.TruncateToVisualLength(self.input_state.lines[y], w))}
+	}
+	return ans
+}
+
 func (self *Readline) move_cursor_down(amt uint) uint {
 	ans := uint(0)
 	if self.screen_width == 0 {
@@ -279,15 +291,6 @@ func (self *Readline) erase_chars_after_cursor(amt uint, traverse_line_breaks bo
 	return num
 }
 
-func has_word_chars(text string) bool {
-	for _, ch := range text {
-		if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
-			return true
-		}
-	}
-	return false
-}
-
 func (self *Readline) move_to_end_of_word(amt uint, traverse_line_breaks bool, is_part_of_word func(string) bool) (num_of_words_moved uint) {
 	if amt == 0 {
 		return 0
@@ -360,15 +363,15 @@ func (self *Readline) move_to_start_of_word(amt uint, traverse_line_breaks bool,
 		if traverse_line_breaks && self.input_state.cursor.Y > 0 {
 			self.input_state.cursor.Y--
 			self.input_state.cursor.X = len(self.input_state.lines[self.input_state.cursor.Y])
-			num_of_words_moved += self.move_to_start_of_word(amt, traverse_line_breaks, has_word_chars)
+			num_of_words_moved += self.move_to_start_of_word(amt, traverse_line_breaks, is_part_of_word)
 		}
 	}
 	return
 }
 
-func (self *Readline) kill_text(text string) {
+func (self *Readline) kill_text(text string, backwards bool) {
 	if ActionStartKillActions < self.last_action && self.last_action < ActionEndKillActions {
-		self.kill_ring.append_to_existing_item(text)
+		self.kill_ring.append_to_existing_item(text, backwards)
 	} else {
 		self.kill_ring.add_new_item(text)
 	}
@@ -380,7 +383,7 @@ func (self *Readline) kill_to_end_of_line() bool {
 		return false
 	}
 	self.input_state.lines[self.input_state.cursor.Y] = line[:self.input_state.cursor.X]
-	self.kill_text(line[self.input_state.cursor.X:])
+	self.kill_text(line[self.input_state.cursor.X:], false)
 	return true
 }
 
@@ -390,43 +393,34 @@ func (self *Readline) kill_to_start_of_line() bool {
 		return false
 	}
 	self.input_state.lines[self.input_state.cursor.Y] = line[self.input_state.cursor.X:]
-	self.kill_text(line[:self.input_state.cursor.X])
+	self.kill_text(line[:self.input_state.cursor.X], true)
 	self.input_state.cursor.X = 0
 	return true
 }
 
 func (self *Readline) kill_next_word(amt uint, traverse_line_breaks bool) (num_killed uint) {
 	before := self.input_state.cursor
-	num_killed = self.move_to_end_of_word(amt, traverse_line_breaks, has_word_chars)
+	num_killed = self.move_to_end_of_word(amt, traverse_line_breaks, self.word_style.is_part_of_word())
 	if num_killed > 0 {
-		self.kill_text(self.erase_between(before, self.input_state.cursor))
+		self.kill_text(self.erase_between(before, self.input_state.cursor), false)
 	}
 	return num_killed
 }
 
 func (self *Readline) kill_previous_word(amt uint, traverse_line_breaks bool) (num_killed uint) {
 	before := self.input_state.cursor
-	num_killed = self.move_to_start_of_word(amt, traverse_line_breaks, has_word_chars)
+	num_killed = self.move_to_start_of_word(amt, traverse_line_breaks, self.word_style.is_part_of_word())
 	if num_killed > 0 {
-		self.kill_text(self.erase_between(self.input_state.cursor, before))
+		self.kill_text(self.erase_between(self.input_state.cursor, before), true)
 	}
 	return num_killed
 }
 
-func has_no_space_chars(text string) bool {
-	for _, r := range text {
-		if unicode.IsSpace(r) {
-			return false
-		}
-	}
-	return true
-}
-
 func (self *Readline) kill_previous_space_delimited_word(amt uint, traverse_line_breaks bool) (num_killed uint) {
 	before := self.input_state.cursor
 	num_killed = self.move_to_start_of_word(amt, traverse_line_breaks, has_no_space_chars)
 	if num_killed > 0 {
-		self.kill_text(self.erase_between(self.input_state.cursor, before))
+		self.kill_text(self.erase_between(self.input_state.cursor, before), true)
 	}
 	return num_killed
 }
@@ -492,7 +486,14 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 			if self.remove_text_from_history_search(repeat_count) > 0 {
 				return
 			}
+		} else if self.completions.current.in_menu {
+			if self.remove_text_from_completion_filter(repeat_count) > 0 {
+				return
+			}
 		} else {
+			if repeat_count == 1 && self.erase_empty_pair() {
+				return
+			}
 			if self.erase_chars_before_cursor(repeat_count, true) > 0 {
 				return
 			}
@@ -506,15 +507,15 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 			return
 		}
 	case ActionMoveToEndOfLine:
-		if self.move_to_end_of_line() {
+		if self.move_to_end_of_line() || self.accept_suggestion() {
 			return
 		}
 	case ActionMoveToEndOfWord:
-		if self.move_to_end_of_word(repeat_count, true, has_word_chars) > 0 {
+		if self.move_to_end_of_word(repeat_count, true, self.word_style.is_part_of_word()) > 0 {
 			return
 		}
 	case ActionMoveToStartOfWord:
-		if self.move_to_start_of_word(repeat_count, true, has_word_chars) > 0 {
+		if self.move_to_start_of_word(repeat_count, true, self.word_style.is_part_of_word()) > 0 {
 			return
 		}
 	case ActionMoveToStartOfDocument:
@@ -522,7 +523,7 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 			return
 		}
 	case ActionMoveToEndOfDocument:
-		if self.move_to_end() {
+		if self.move_to_end() || self.accept_suggestion() {
 			return
 		}
 	case ActionCursorLeft:
@@ -530,7 +531,7 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 			return
 		}
 	case ActionCursorRight:
-		if self.move_cursor_right(repeat_count, true) > 0 {
+		if self.move_cursor_right(repeat_count, true) > 0 || self.accept_suggestion() {
 			return
 		}
 	case ActionEndInput:
@@ -543,6 +544,9 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 		}
 		return
 	case ActionAcceptInput:
+		if self.history_expansion && self.history_search == nil && self.expand_history_references() {
+			return
+		}
 		err = ErrAcceptInput
 		return
 	case ActionCursorUp:
@@ -553,6 +557,14 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 		if self.move_cursor_vertically(int(repeat_count)) != 0 {
 			return
 		}
+	case ActionCursorUpLogicalLine:
+		if self.move_cursor_by_logical_lines(-int(repeat_count)) != 0 {
+			return
+		}
+	case ActionCursorDownLogicalLine:
+		if self.move_cursor_by_logical_lines(int(repeat_count)) != 0 {
+			return
+		}
 	case ActionHistoryPreviousOrCursorUp:
 		dont_set_last_action = true
 		if self.perform_action(ActionCursorUp, repeat_count) == ErrCouldNotPerformAction {
@@ -640,7 +652,9 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 		self.text_to_be_added = ""
 		if self.history_search != nil {
 			self.add_text_to_history_search(text)
-		} else {
+		} else if self.completions.current.in_menu {
+			self.add_text_to_completion_filter(text)
+		} else if repeat_count > 1 || !self.add_paired_text(text) {
 			self.add_text(text)
 		}
 		return
@@ -662,16 +676,85 @@ func (self *Readline) _perform_action(ac Action, repeat_count uint) (err error,
 		if self.complete(false, repeat_count) {
 			return
 		}
+	case ActionTerminateCompletionMenuAndRestore:
+		if self.completions.current.in_menu {
+			self.end_completion_menu(false)
+			return
+		}
+	case ActionTerminateCompletionMenuAndApply:
+		if self.completions.current.in_menu {
+			self.end_completion_menu(true)
+			return
+		}
+	case ActionUndo:
+		if self.history_search == nil && self.undo(repeat_count) {
+			return
+		}
+	case ActionRedo:
+		if self.history_search == nil && self.redo(repeat_count) {
+			return
+		}
+	case ActionEditInExternalEditor:
+		if self.history_search == nil && self.edit_in_external_editor() {
+			return
+		}
+	case ActionPushLine:
+		if self.history_search == nil && self.push_line() {
+			return
+		}
+	case ActionSetMark:
+		if self.set_mark() {
+			return
+		}
+	case ActionCycleWordStyle:
+		self.word_style = self.word_style.next()
+		return
 	}
 	err = ErrCouldNotPerformAction
 	return
 }
 
-func (self *Readline) perform_action(ac Action, repeat_count uint) error {
-	err, dont_set_last_action := self._perform_action(ac, repeat_count)
+// Stash the current text, clearing the line, the stashed text is restored
+// the next time the text is reset, that is, at the next prompt
+func (self *Readline) push_line() bool {
+	text := self.all_text()
+	if text == "" {
+		return false
+	}
+	self.line_stash = append(self.line_stash, text)
+	self.input_state = InputState{lines: []string{""}}
+	return true
+}
+
+func (self *Readline) pop_line() {
+	if n := len(self.line_stash); n > 0 {
+		text := self.line_stash[n-1]
+		self.line_stash = self.line_stash[:n-1]
+		self.add_text(text)
+	}
+}
+
+func (self *Readline) perform_action(ac Action, repeat_count uint) (err error) {
+	var dont_set_last_action bool
+	switch ac {
+	case ActionUndo, ActionRedo:
+		err, dont_set_last_action = self._perform_action(ac, repeat_count)
+	default:
+		kind := undo_edit
+		if ac == ActionAddText && repeat_count == 1 {
+			kind = undo_kind_for_text(self.text_to_be_added)
+		}
+		self.with_undo(kind, func() error {
+			err, dont_set_last_action = self._perform_action(ac, repeat_count)
+			return err
+		})
+	}
+	if ac != ActionSetMark && !is_cursor_movement_action(ac) {
+		self.selection.active = false
+	}
 	if err == nil && !dont_set_last_action {
 		self.last_action = ac
-		if self.completions.current.results != nil && ac != ActionCompleteForward && ac != ActionCompleteBackward {
+		if self.completions.current.results != nil && !self.completions.current.in_menu && ac != ActionCompleteForward && ac != ActionCompleteBackward {
 			self.completions.current = completion{}
 		}
 	}
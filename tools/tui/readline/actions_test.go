@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"kitty/tools/cli"
 	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
 	"kitty/tools/utils/shlex"
+	"kitty/tools/wcswidth"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -68,6 +72,9 @@ func TestAddText(t *testing.T) {
 		rl.input_state.cursor.X = 2
 		rl.add_text("12\n34")
 	}, "abcd\nxy12\n34", "z", "abcd\nxy12\n34z")
+	dt("abcd", func(rl *Readline) {
+		rl.SetText("ls --match id:1", " --self\nx")
+	}, "ls --match id:1", " --self\nx", "ls --match id:1 --self\nx")
 }
 
 func TestGetScreenLines(t *testing.T) {
@@ -137,6 +144,20 @@ func TestGetScreenLines(t *testing.T) {
 		ScreenLine{Prompt: p(true), CursorCell: -1, Text: "1234567", CursorTextPos: -1, TextLengthInCells: 7, AfterLineBreak: true},
 		ScreenLine{ParentLineNumber: 1, Prompt: p(false), Text: "abc", CursorCell: 2, TextLengthInCells: 3, CursorTextPos: 0, AfterLineBreak: true},
 	)
+	m := Prompt{Text: "+", Length: 1}
+	rl.wrap_marker = m
+	rl.ResetText()
+	rl.add_text("123456789")
+	tsl(
+		ScreenLine{Prompt: p(true), CursorCell: -1, Text: "1234567", CursorTextPos: -1, TextLengthInCells: 7, AfterLineBreak: true},
+		ScreenLine{OffsetInParentLine: 7, Prompt: m, Text: "89", CursorCell: 3, TextLengthInCells: 2, CursorTextPos: 2},
+	)
+	rl.ResetText()
+	rl.add_text("1234567")
+	tsl(
+		ScreenLine{Prompt: p(true), CursorCell: -1, Text: "1234567", CursorTextPos: -1, TextLengthInCells: 7, AfterLineBreak: true},
+		ScreenLine{OffsetInParentLine: 7, Prompt: m, CursorCell: 1},
+	)
 }
 
 func TestCursorMovement(t *testing.T) {
@@ -217,6 +238,21 @@ func TestCursorMovement(t *testing.T) {
 	vert(-2, -2, "1234567xy", Position{X: 3, Y: 2})
 	vert(-30, -3, "123", Position{X: 3, Y: 2})
 
+	logical := func(amt int, moved_amt int, text_upto_cursor_pos string, initial Position) {
+		rl.input_state.cursor = initial
+		actual := rl.move_cursor_by_logical_lines(amt)
+		if actual != moved_amt {
+			t.Fatalf("Failed to move cursor by %#v for: %#v \nactual != expected: %#v != %#v", amt, rl.AllText(), actual, moved_amt)
+		}
+		if diff := cmp.Diff(text_upto_cursor_pos, rl.text_upto_cursor_pos()); diff != "" {
+			t.Fatalf("Did not get expected text upto cursor for: %#v and cursor: %+v\n%s", rl.AllText(), initial, diff)
+		}
+	}
+	logical(-1, -1, "1234567xy\nabc", Position{X: 3, Y: 2})
+	logical(-2, -2, "123", Position{X: 3, Y: 2})
+	logical(1, 1, "1234567xy\nabcd", Position{X: 8})
+	logical(5, 0, "1234567xy\nabcd\n1", Position{X: 1, Y: 2})
+
 	rl.ResetText()
 	rl.add_text("o\u0300ne  two three\nfour five")
 
@@ -319,6 +355,72 @@ func TestYanking(t *testing.T) {
 	rl.perform_action(ActionKillNextWord, 1)
 	assert_items("three", "one two")
 	assert_text(" ")
+
+	rl.ResetText()
+	rl.kill_ring.clear()
+	rl.add_text("one two three")
+	rl.perform_action(ActionKillPreviousWord, 1)
+	rl.perform_action(ActionKillPreviousWord, 1)
+	assert_items("two three")
+	assert_text("one ")
+	rl.perform_action(ActionKillToStartOfLine, 1)
+	assert_items("one two three")
+	assert_text("")
+	rl.perform_action(ActionYank, 1)
+	assert_text("one two three")
+
+	rl.kill_ring.clear()
+	for i := 0; i < max_kill_ring_items+10; i++ {
+		rl.kill_ring.add_new_item(fmt.Sprint(i))
+	}
+	if rl.kill_ring.items.Len() != max_kill_ring_items || rl.kill_ring.items.Back().Value.(string) != "10" {
+		t.Fatalf("kill ring not limited in size, has %d items", rl.kill_ring.items.Len())
+	}
+}
+
+func TestUndo(t *testing.T) {
+	rl := new_rl()
+	type_text := func(text string) {
+		for _, ch := range text {
+			rl.text_to_be_added = string(ch)
+			rl.perform_action(ActionAddText, 1)
+		}
+	}
+	at := func(ac Action, expected string) {
+		t.Helper()
+		rl.perform_action(ac, 1)
+		if diff := cmp.Diff(expected, rl.all_text()); diff != "" {
+			t.Fatalf("text not as expected after: %s\n%s", ac, diff)
+		}
+	}
+
+	type_text("one two")
+	at(ActionUndo, "one ")
+	at(ActionUndo, "")
+	if rl.perform_action(ActionUndo, 1) == nil {
+		t.Fatalf("Undo with nothing to undo did not fail")
+	}
+	at(ActionRedo, "one ")
+	at(ActionRedo, "one two")
+	at(ActionMoveToStartOfLine, "one two")
+	at(ActionKillToEndOfLine, "")
+	at(ActionUndo, "one two")
+	if rl.input_state.cursor.X != 0 {
+		t.Fatalf("Cursor not restored by undo: %+v", rl.input_state.cursor)
+	}
+	at(ActionRedo, "")
+	at(ActionUndo, "one two")
+	rl.text_to_be_added = "\nthree"
+	at(ActionAddText, "\nthreeone two")
+	at(ActionUndo, "one two")
+	type_text("x")
+	if rl.perform_action(ActionRedo, 1) == nil {
+		t.Fatalf("Redo after a change did not fail")
+	}
+	rl.ResetText()
+	if rl.perform_action(ActionUndo, 1) == nil {
+		t.Fatalf("Undo after reset did not fail")
+	}
 }
 
 func TestEraseChars(t *testing.T) {
@@ -515,6 +617,64 @@ func TestHistory(t *testing.T) {
 	ah("xy", "z2")
 	rl.perform_action(ActionTerminateHistorySearchAndRestore, 1)
 	ah("a", "")
+
+	// multiple matches in a single item
+	add_item("echo one\necho two")
+	rl.perform_action(ActionHistoryIncrementalSearchBackwards, 1)
+	rl.text_to_be_added = "echo"
+	rl.perform_action(ActionAddText, 1)
+	ah("echo one\n", "echo two")
+	if diff := cmp.Diff("history ↑ 2/2: ", wcswidth.StripEscapeCodes(rl.history_search_prompt())); diff != "" {
+		t.Fatalf("Search prompt not as expected:\n%s", diff)
+	}
+	rl.perform_action(ActionHistoryIncrementalSearchBackwards, 1)
+	ah("", "echo one\necho two")
+	rl.perform_action(ActionHistoryIncrementalSearchForwards, 1)
+	ah("echo one\n", "echo two")
+	hl := rl.history_search_highlighter("echo one\necho two", 0, 0)
+	if strings.Count(hl, rl.fmt_ctx.Green("echo")) != 2 {
+		t.Fatalf("Not all matches were highlighted: %#v", hl)
+	}
+	rl.perform_action(ActionTerminateHistorySearchAndApply, 1)
+	ah("echo one\n", "echo two")
+}
+
+func TestSuggestions(t *testing.T) {
+	lp, _ := loop.New()
+	rl := New(lp, RlInit{Prompt: "$ ", Suggester: SuggestFromHistory})
+	rl.screen_width, rl.screen_height = 80, 100
+	rl.history.AddItem("echo one", 0)
+	rl.history.AddItem("echo two\nthree", 0)
+	rl.history.AddItem("ls", 0)
+
+	shown := func(expected string) {
+		t.Helper()
+		sl := rl.get_screen_lines()
+		text := wcswidth.StripEscapeCodes(sl[len(sl)-1].Text)
+		if diff := cmp.Diff(expected, text); diff != "" {
+			t.Fatalf("Suggestion not shown as expected for: %#v\n%s", rl.AllText(), diff)
+		}
+	}
+	rl.add_text("ec")
+	if rl.current_suggestion() != "ho two\nthree" {
+		t.Fatalf("Most recent matching history item not suggested: %#v", rl.current_suggestion())
+	}
+	shown("echo two…")
+	rl.perform_action(ActionCursorLeft, 1)
+	shown("ec")
+	rl.perform_action(ActionMoveToEndOfLine, 1)
+	rl.add_text("ho o")
+	shown("echo one")
+	rl.perform_action(ActionCursorRight, 1)
+	shown("echo one")
+	if rl.AllText() != "echo one" {
+		t.Fatalf("Suggestion not accepted: %#v", rl.AllText())
+	}
+	rl.add_text("x")
+	shown("echo onex")
+	if rl.perform_action(ActionCursorRight, 1) == nil {
+		t.Fatalf("Moving right with no suggestion did not fail")
+	}
 }
 
 func TestReadlineCompletion(t *testing.T) {
@@ -538,7 +698,7 @@ func TestReadlineCompletion(t *testing.T) {
 	rl := new_rl()
 	rl.completions.completer = completer
 
-	ah := func(before_cursor, after_cursor string) {
+	ah := func(before_cursor, after_cursor string, expected ...string) {
 		ab := rl.text_upto_cursor_pos()
 		aa := rl.text_after_cursor_pos()
 		if diff := cmp.Diff(before_cursor, ab); diff != "" {
@@ -548,20 +708,526 @@ func TestReadlineCompletion(t *testing.T) {
 			t.Fatalf("Text after cursor not as expected:\n%s", diff)
 		}
 		actual, _ := rl.completion_screen_lines()
-		expected := []string{"a1 a11 a2 "}
-		if diff := cmp.Diff(expected, actual[1:]); diff != "" {
+		if diff := cmp.Diff(expected, actual[len(actual)-len(expected):]); diff != "" {
 			t.Fatalf("Completion screen lines not as expected:\n%s", diff)
 		}
 	}
+	h := rl.fmt_ctx.Reverse
 	rl.add_text("a")
 	rl.perform_action(ActionCompleteForward, 1)
-	ah("a", "")
+	ah("a", "", "a1 a11 a2")
+	rl.perform_action(ActionCompleteForward, 1)
+	ah("a1 ", "", h("a1")+" a11 a2")
+	rl.perform_action(ActionCompleteForward, 1)
+	ah("a11 ", "", "a1 "+h("a11")+" a2")
+	rl.perform_action(ActionCompleteForward, 1)
+	ah("a2 ", "", "a1 a11 "+h("a2"))
+	rl.perform_action(ActionCompleteBackward, 1)
+	ah("a11 ", "", "a1 "+h("a11")+" a2")
+
+	// filtering the menu
+	rl.text_to_be_added = "11"
+	rl.perform_action(ActionAddText, 1)
+	title := rl.fmt_ctx.Title("Sub-commands")
+	ah("a11 ", "", "Filter: "+rl.fmt_ctx.Green("11"), title, h("a11"))
+	rl.perform_action(ActionBackspace, 1)
+	ah("a", "", "Filter: "+rl.fmt_ctx.Green("1"), title, "a1 a11")
+	rl.perform_action(ActionCompleteForward, 1)
+	rl.perform_action(ActionTerminateCompletionMenuAndApply, 1)
+	if diff := cmp.Diff("a1 ", rl.AllText()); diff != "" {
+		t.Fatalf("Text after accepting match not as expected:\n%s", diff)
+	}
+	if rl.completions.current.results != nil || len(rl.keyboard_state.active_shortcut_maps) != 0 {
+		t.Fatalf("Completion menu not closed after accepting match")
+	}
+	rl.ResetText()
+	rl.add_text("a")
+	rl.perform_action(ActionCompleteForward, 2)
+	rl.perform_action(ActionTerminateCompletionMenuAndRestore, 1)
+	if diff := cmp.Diff("a", rl.AllText()); diff != "" {
+		t.Fatalf("Text after cancelling completion menu not as expected:\n%s", diff)
+	}
+}
+
+func TestReadlineChoiceCompletion(t *testing.T) {
+	completer := func(before_cursor, after_cursor string) (ans *cli.Completions) {
+		root := cli.NewRootCommand()
+		c := root.AddSubCommand(&cli.Command{Name: "test-completion"})
+		c.Add(cli.OptionSpec{Name: "--type", Choices: "one, two, three"})
+		prefix := c.Name + " "
+		argv, position_of_last_arg := shlex.SplitForCompletion(prefix + before_cursor)
+		ans = root.GetCompletions(argv, nil)
+		ans.CurrentWordIdx = position_of_last_arg - len(prefix)
+		return
+	}
+	rl := new_rl()
+	rl.completions.completer = completer
+	h := rl.fmt_ctx.Reverse
+
+	ah := func(expected_text, expected_line string) {
+		t.Helper()
+		if diff := cmp.Diff(expected_text, rl.AllText()); diff != "" {
+			t.Fatalf("Text not as expected:\n%s", diff)
+		}
+		if lines, _ := rl.completion_screen_lines(); len(lines) > 0 {
+			t.Fatalf("Choices were listed instead of being cycled through: %#v", lines)
+		}
+		lines, _ := rl.apply_syntax_highlighting()
+		if diff := cmp.Diff(expected_line, lines[0]); diff != "" {
+			t.Fatalf("Highlighted choice not as expected:\n%s", diff)
+		}
+	}
+	rl.add_text("--type ")
 	rl.perform_action(ActionCompleteForward, 1)
-	ah("a1 ", "")
+	ah("--type one ", "--type "+h("one")+" ")
 	rl.perform_action(ActionCompleteForward, 1)
-	ah("a11 ", "")
+	ah("--type two ", "--type "+h("two")+" ")
 	rl.perform_action(ActionCompleteForward, 1)
-	ah("a2 ", "")
+	ah("--type three ", "--type "+h("three")+" ")
+	rl.perform_action(ActionCompleteForward, 1)
+	ah("--type one ", "--type "+h("one")+" ")
 	rl.perform_action(ActionCompleteBackward, 1)
-	ah("a11 ", "")
+	ah("--type three ", "--type "+h("three")+" ")
+	rl.text_to_be_added = "x"
+	rl.perform_action(ActionAddText, 1)
+	ah("--type three x", "--type three x")
+}
+
+func TestPromptTemplate(t *testing.T) {
+	lp, _ := loop.New()
+	rl := New(lp, RlInit{Prompt: "{exit_code}$ ", RightPrompt: "{exit_code} {duration}", DontMarkPrompts: true})
+	rl.fmt_ctx.SetAllowEscapeCodes(false)
+	rl.update_prompts()
+	if rl.prompt.Text != "$ " || rl.right_prompt.Text != "" {
+		t.Fatalf("Unexpected prompts before any command: %#v %#v", rl.prompt.Text, rl.right_prompt.Text)
+	}
+	rl.AddHistoryItem(HistoryItem{Cmd: "xyz", ExitCode: 3, Duration: 1500 * time.Millisecond})
+	if rl.prompt.Text != "3$ " || rl.right_prompt.Text != "3 1.5s" || rl.right_prompt.Length != 6 {
+		t.Fatalf("Unexpected prompts after a command: %#v %#v", rl.prompt.Text, rl.right_prompt.Text)
+	}
+}
+
+func TestViMode(t *testing.T) {
+	lp, _ := loop.New()
+	rl := New(lp, RlInit{Prompt: "$$ ", EditingMode: ViEditingMode})
+	rl.screen_width, rl.screen_height = 80, 100
+
+	ah := func(keys, before_cursor, after_cursor string) {
+		t.Helper()
+		if err := rl.OnText(keys, false, false); err != nil {
+			t.Fatalf("Failed to handle keys: %#v with error: %s", keys, err)
+		}
+		if diff := cmp.Diff(before_cursor, rl.text_upto_cursor_pos()); diff != "" {
+			t.Fatalf("Text before cursor not as expected after: %#v\n%s", keys, diff)
+		}
+		if diff := cmp.Diff(after_cursor, rl.text_after_cursor_pos()); diff != "" {
+			t.Fatalf("Text after cursor not as expected after: %#v\n%s", keys, diff)
+		}
+	}
+	esc := func() {
+		rl.handle_key_event(&loop.KeyEvent{Type: loop.PRESS, Key: "ESCAPE"})
+	}
+
+	ah("one two three", "one two three", "")
+	esc()
+	ah("", "one two thre", "e")
+	ah("0", "", "one two three")
+	ah("2w", "one two ", "three")
+	ah("b", "one ", "two three")
+	ah("b", "", "one two three")
+	ah("e", "on", "e two three")
+	ah("$", "one two thre", "e")
+	ah("^", "", "one two three")
+	ah("fw", "one t", "wo three")
+	ah("Fo", "", "one two three")
+	ah("tt", "one", " two three")
+	ah("l", "one ", "two three")
+	ah("dw", "one ", "three")
+	ah("P", "one two", " three")
+	ah("0", "", "one two three")
+	ah("cwthe", "the", " two three")
+	esc()
+	ah("x", "th", " two three")
+	ah("$", "th two thre", "e")
+	ah("db", "th two ", "e")
+	ah("u", "th two thre", "e")
+	rl.handle_key_event(&loop.KeyEvent{Type: loop.PRESS, Key: "r", Mods: loop.CTRL})
+	ah("", "th two ", "e")
+	ah("z", "th two ", "e") // unknown commands are ignored
+	ah("D", "th two", " ")
+	ah("0\"ayw", "", "th two ")
+	ah("$\"ap", "th two th", " ")
+	ah("dd", "", "")
+	ah("iabc def", "abc def", "")
+	esc()
+	ah("0~~", "AB", "c def")
+	ah("rx", "AB", "x def")
+	ah("A ghi", "ABx def ghi", "")
+	esc()
+	ah("ojkl", "ABx def ghi\njkl", "")
+	esc()
+	ah("kyyjp", "ABx def ghi\njkl\n", "ABx def ghi")
+	ah("kdd", "ABx def ghi\n", "ABx def ghi")
+	ah("Sx", "ABx def ghi\nx", "")
+}
+
+func TestKeymap(t *testing.T) {
+	lp, _ := loop.New()
+	called_with := uint(0)
+	rl := New(lp, RlInit{Prompt: "$ ", Keymap: Keymap{
+		"ctrl+k":        {Action: "move_to_start_of_line"},
+		"Control+a":     {},
+		"ctrl+t ctrl+t": {Callback: func(rl *Readline, repeat_count uint) error { called_with = repeat_count; rl.add_text("!"); return nil }},
+	}})
+	rl.screen_width, rl.screen_height = 80, 100
+	press := func(key string, mods loop.KeyModifiers) {
+		rl.handle_key_event(&loop.KeyEvent{Type: loop.PRESS, Key: key, Mods: mods})
+	}
+	ah := func(before_cursor, after_cursor string) {
+		t.Helper()
+		if diff := cmp.Diff(before_cursor, rl.text_upto_cursor_pos()); diff != "" {
+			t.Fatalf("Text before cursor not as expected:\n%s", diff)
+		}
+		if diff := cmp.Diff(after_cursor, rl.text_after_cursor_pos()); diff != "" {
+			t.Fatalf("Text after cursor not as expected:\n%s", diff)
+		}
+	}
+
+	rl.add_text("one two")
+	press("k", loop.CTRL)
+	ah("", "one two")
+	press("e", loop.CTRL)
+	ah("one two", "")
+	press("a", loop.CTRL)
+	ah("one two", "")
+	press("2", loop.ALT)
+	press("t", loop.CTRL)
+	press("t", loop.CTRL)
+	ah("one two!", "")
+	if called_with != 2 {
+		t.Fatalf("Callback not called with the numeric argument: %d", called_with)
+	}
+	rl.perform_action(ActionUndo, 1)
+	ah("one two", "")
+
+	// keys that do not continue a multi-key shortcut end it
+	type_key := func(key string) {
+		ev := &loop.KeyEvent{Type: loop.PRESS, Key: key, Text: key}
+		rl.handle_key_event(ev)
+		if !ev.Handled {
+			rl.OnText(ev.Text, true, false)
+		}
+	}
+	press("t", loop.CTRL)
+	type_key("a")
+	ah("one twoa", "")
+	press("k", loop.CTRL)
+	ah("", "one twoa")
+	press("e", loop.CTRL)
+	press("t", loop.CTRL)
+	press("b", loop.CTRL)
+	press("k", loop.CTRL)
+	ah("", "one twoa")
+	if len(rl.keyboard_state.current_pending_keys) != 0 {
+		t.Fatalf("Pending keys not cleared: %#v", rl.keyboard_state.current_pending_keys)
+	}
+
+	if err := rl.BindKey("ctrl+b", KeyBinding{Action: "no_such_action"}); err == nil {
+		t.Fatalf("Binding to an unknown action did not fail")
+	}
+	if ac, err := ActionFromName("kill_to_end_of_line"); err != nil || ac != ActionKillToEndOfLine {
+		t.Fatalf("Action not found from name: %s %s", ac, err)
+	}
+	if new_rl().base_shortcuts() != default_shortcuts() {
+		t.Fatalf("Readline without a keymap does not use the default shortcuts")
+	}
+	if !utils.Contains(ActionNames(), "history_incremental_search_backwards") {
+		t.Fatalf("Action names missing expected name: %#v", ActionNames())
+	}
+}
+
+func TestExternalEditor(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", `sh -c 'printf "three\n" >> "$0"'`)
+	edited, err := run_editor("one\ntwo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited != "one\ntwo\nthree" {
+		t.Fatalf("Unexpected edited text: %#v", edited)
+	}
+	t.Setenv("EDITOR", "")
+	if argv, _ := editor_command(); cmp.Diff([]string{"vi"}, argv) != "" {
+		t.Fatalf("Unexpected default editor: %#v", argv)
+	}
+}
+
+func TestPushLine(t *testing.T) {
+	rl := new_rl()
+	if rl.perform_action(ActionPushLine, 1) != ErrCouldNotPerformAction {
+		t.Fatalf("Pushing an empty line did not fail")
+	}
+	rl.add_text("git commit\n-m")
+	if err := rl.perform_action(ActionPushLine, 1); err != nil {
+		t.Fatal(err)
+	}
+	if rl.all_text() != "" {
+		t.Fatalf("Text not cleared after push: %#v", rl.all_text())
+	}
+	rl.add_text("ls")
+	rl.perform_action(ActionPushLine, 1)
+	rl.add_text("pwd")
+	rl.ResetText()
+	if rl.all_text() != "ls" || rl.text_after_cursor_pos() != "" {
+		t.Fatalf("Stashed text not restored: %#v", rl.all_text())
+	}
+	rl.ResetText()
+	if rl.all_text() != "git commit\n-m" {
+		t.Fatalf("Stashed text not restored: %#v", rl.all_text())
+	}
+	rl.ResetText()
+	if rl.all_text() != "" {
+		t.Fatalf("Text restored with an empty stash: %#v", rl.all_text())
+	}
+}
+
+func TestWordStyles(t *testing.T) {
+	rl := new_rl()
+	text := "ls --long-flag /usr/local;cd x"
+	stops := func(ws WordStyle) (ans []string) {
+		rl.SetWordStyle(ws)
+		rl.ResetText()
+		rl.add_text(text)
+		for rl.perform_action(ActionMoveToStartOfWord, 1) == nil {
+			ans = append(ans, rl.text_after_cursor_pos())
+		}
+		return
+	}
+	for ws, expected := range map[WordStyle][]string{
+		ALPHANUMERIC_WORDS:   {"x", "cd x", "local;cd x", "usr/local;cd x", "flag /usr/local;cd x", "long-flag /usr/local;cd x", text},
+		SHELL_WORDS:          {"x", "cd x", "/usr/local;cd x", "--long-flag /usr/local;cd x", text},
+		WHITESPACE_WORDS:     {"x", "/usr/local;cd x", "--long-flag /usr/local;cd x", text},
+		PATH_COMPONENT_WORDS: {"x", "local;cd x", "usr/local;cd x", "--long-flag /usr/local;cd x", text},
+	} {
+		if diff := cmp.Diff(expected, stops(ws)); diff != "" {
+			t.Fatalf("Incorrect word starts for the %s word style:\n%s", ws, diff)
+		}
+	}
+	rl.SetWordStyle(ALPHANUMERIC_WORDS)
+	for _, expected := range []WordStyle{SHELL_WORDS, WHITESPACE_WORDS, PATH_COMPONENT_WORDS, ALPHANUMERIC_WORDS} {
+		if err := rl.perform_action(ActionCycleWordStyle, 1); err != nil {
+			t.Fatal(err)
+		}
+		if rl.WordStyle() != expected {
+			t.Fatalf("Cycling word styles gave %s instead of %s", rl.WordStyle(), expected)
+		}
+	}
+
+	// the default shortcut, ctrl+x w, ends with a text key
+	rl.ResetText()
+	ctrl_x := &loop.KeyEvent{Type: loop.PRESS, Key: "x", Mods: loop.CTRL}
+	w := &loop.KeyEvent{Type: loop.PRESS, Key: "w", Text: "w"}
+	if err := rl.handle_key_event(ctrl_x); err != nil || !ctrl_x.Handled {
+		t.Fatalf("ctrl+x not handled as the start of a shortcut: %v", err)
+	}
+	if err := rl.handle_key_event(w); err != nil || !w.Handled {
+		t.Fatalf("ctrl+x w not handled as a shortcut: %v", err)
+	}
+	if rl.WordStyle() != SHELL_WORDS {
+		t.Fatalf("ctrl+x w gave the word style %s instead of %s", rl.WordStyle(), SHELL_WORDS)
+	}
+	w = &loop.KeyEvent{Type: loop.PRESS, Key: "w", Text: "w"}
+	if err := rl.handle_key_event(w); err != nil || w.Handled {
+		t.Fatalf("w on its own handled as a shortcut: %v", err)
+	}
+	if rl.WordStyle() != SHELL_WORDS {
+		t.Fatalf("w on its own changed the word style to %s", rl.WordStyle())
+	}
+}
+
+func TestAutoPairs(t *testing.T) {
+	rl := new_rl()
+	type_text := func(text string) {
+		for _, ch := range text {
+			rl.text_to_be_added = string(ch)
+			rl.perform_action(ActionAddText, 1)
+		}
+	}
+	assert_text := func(before, after string) {
+		t.Helper()
+		if diff := cmp.Diff(before+after, rl.all_text()); diff != "" {
+			t.Fatalf("text not as expected:\n%s", diff)
+		}
+		if diff := cmp.Diff(before, rl.text_upto_cursor_pos()); diff != "" {
+			t.Fatalf("cursor position not as expected:\n%s", diff)
+		}
+	}
+
+	type_text("f(")
+	assert_text("f(", "")
+	rl.auto_pairs = true
+	rl.ResetText()
+	type_text("f(")
+	assert_text("f(", ")")
+	type_text(`"a`)
+	assert_text(`f("a`, `")`)
+	type_text(`")`)
+	assert_text(`f("a")`, "")
+	type_text(" don't")
+	assert_text(`f("a") don't`, "")
+
+	rl.ResetText()
+	type_text("[")
+	rl.perform_action(ActionBackspace, 1)
+	assert_text("", "")
+	rl.add_text("ab")
+	rl.perform_action(ActionCursorLeft, 1)
+	type_text("(")
+	assert_text("a(", "b")
+
+	rl.ResetText()
+	rl.add_text("echo one two")
+	rl.perform_action(ActionMoveToStartOfWord, 1)
+	rl.perform_action(ActionSetMark, 1)
+	rl.perform_action(ActionMoveToEndOfLine, 1)
+	if rl.SelectedText() != "two" {
+		t.Fatalf("selected text not as expected: %#v", rl.SelectedText())
+	}
+	type_text("'")
+	assert_text("echo one 'two'", "")
+	if rl.SelectedText() != "" {
+		t.Fatalf("selection not cleared after wrapping: %#v", rl.SelectedText())
+	}
+	rl.perform_action(ActionUndo, 1)
+	assert_text("echo one two", "")
+}
+
+func TestHistoryPolicy(t *testing.T) {
+	now := time.Now()
+	cmds := func(h *History) []string {
+		ans := make([]string, len(h.items))
+		for i, x := range h.items {
+			ans[i] = x.Cmd
+		}
+		return ans
+	}
+	test := func(h *History, expected ...string) {
+		t.Helper()
+		if diff := cmp.Diff(expected, cmds(h)); diff != "" {
+			t.Fatalf("Unexpected history items:\n%s", diff)
+		}
+	}
+	items := []HistoryItem{
+		{Cmd: "a", Timestamp: now.Add(-3 * time.Hour)},
+		{Cmd: "b", Timestamp: now.Add(-2 * time.Hour)},
+		{Cmd: "a", Timestamp: now.Add(-time.Hour)},
+		{Cmd: " secret", Timestamp: now.Add(-time.Minute)},
+	}
+	h := NewHistory("", 100, HistoryPolicy{})
+	h.merge_items(items...)
+	test(h, "b", "a", " secret")
+
+	h = NewHistory("", 100, HistoryPolicy{KeepDuplicates: true, IgnoreSpace: true})
+	h.merge_items(items...)
+	test(h, "a", "b", "a")
+	h.merge_items(items...)
+	test(h, "a", "b", "a")
+	h.AddItem(" another secret", 0)
+	test(h, "a", "b", "a")
+
+	h = NewHistory("", 2, HistoryPolicy{KeepDuplicates: true})
+	h.merge_items(items...)
+	test(h, "a", " secret")
+
+	h = NewHistory("", 100, HistoryPolicy{MaxAge: 90 * time.Minute})
+	h.merge_items(items...)
+	test(h, "a", " secret")
+	h.merge_items(HistoryItem{Cmd: "c", Timestamp: now.Add(-2 * time.Hour)})
+	test(h, "a", " secret")
+}
+
+func TestSharedHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	now := time.Now()
+	add := func(h *History, cmd string, offset time.Duration) {
+		h.merge_items(HistoryItem{Cmd: cmd, Timestamp: now.Add(offset)})
+		h.Write()
+	}
+	cmds := func(h *History) (ans []string) {
+		for _, x := range h.items {
+			ans = append(ans, x.Cmd)
+		}
+		return
+	}
+	a, b := NewHistory(path, 100, HistoryPolicy{}), NewHistory(path, 100, HistoryPolicy{})
+	defer a.Shutdown()
+	defer b.Shutdown()
+	add(a, "one", 0)
+	add(b, "two", time.Second)
+	add(a, "three", 2*time.Second)
+	add(b, "four", 3*time.Second)
+	if diff := cmp.Diff([]string{"one", "two", "three"}, cmds(a)); diff != "" {
+		t.Fatalf("History from the other session was not merged:\n%s", diff)
+	}
+	c := NewHistory(path, 100, HistoryPolicy{})
+	defer c.Shutdown()
+	if diff := cmp.Diff([]string{"one", "two", "three", "four"}, cmds(c)); diff != "" {
+		t.Fatalf("History from concurrent sessions was lost:\n%s", diff)
+	}
+}
+
+func TestHistoryExpansion(t *testing.T) {
+	lp, _ := loop.New()
+	rl := New(lp, RlInit{Prompt: "$ ", HistoryExpansion: true})
+	for _, x := range []string{"focus-window --match id:1", "ls", "focus-tab --match index:2", "ls --all-env-vars"} {
+		rl.history.AddItem(x, 0)
+	}
+	test := func(text, expected string) {
+		actual, changed, err := rl.history.Expand(text)
+		if err != nil {
+			t.Fatalf("Expanding %#v failed with error: %s", text, err)
+		}
+		if changed != (text != expected) {
+			t.Fatalf("Incorrect changed value for %#v", text)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Fatalf("Unexpected expansion of %#v:\n%s", text, diff)
+		}
+	}
+	test("!!", "ls --all-env-vars")
+	test("!! | jq; !1", "ls --all-env-vars | jq; focus-window --match id:1")
+	test("!-3", "ls")
+	test("!focus", "focus-tab --match index:2")
+	test("!focus-w;x", "focus-window --match id:1;x")
+	test(`"!ls"`, `"ls --all-env-vars"`)
+	test(`'!!' \!! ! != a!`, `'!!' \!! ! != a!`)
+	for _, q := range []string{`set-tab-title "Hi!"`, "a!;b", "x!|y", "a!&&b", "(x!)"} {
+		test(q, q)
+	}
+	for _, q := range []string{"!nosuch", "!99", "!-9"} {
+		if _, _, err := rl.history.Expand(q); err == nil {
+			t.Fatalf("No error for expanding non-existent reference: %#v", q)
+		}
+	}
+
+	accept := func(text, expected string, accepted bool) {
+		rl.ResetText()
+		rl.add_text(text)
+		err := rl.perform_action(ActionAcceptInput, 1)
+		if accepted != (err == ErrAcceptInput) {
+			t.Fatalf("Accepting %#v did not return the expected error, got: %v", text, err)
+		}
+		if diff := cmp.Diff(expected, rl.all_text()); diff != "" {
+			t.Fatalf("Unexpected text after accepting %#v:\n%s", text, diff)
+		}
+	}
+	accept("ls", "ls", true)
+	// the expansion is previewed and accepted with the next accept
+	accept("!l x", "ls --all-env-vars x", false)
+	if err := rl.perform_action(ActionAcceptInput, 1); err != ErrAcceptInput {
+		t.Fatalf("Accepting the expansion failed with: %v", err)
+	}
+	accept("!nosuch", "!nosuch", false)
+	rl.history_expansion = false
+	accept("!!", "!!", true)
 }
@@ -5,7 +5,9 @@ package readline
 import (
 	"container/list"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"kitty/tools/cli"
 	"kitty/tools/cli/markup"
@@ -21,8 +23,15 @@ const PROMPT_MARK = "\x1b]133;"
 type SyntaxHighlightFunction = func(text string, x, y int) string
 type CompleterFunction = func(before_cursor, after_cursor string) *cli.Completions
 
+// Returns the text to suggest adding to the end of text, see SuggestFromHistory()
+type SuggesterFunction = func(text string, history *History) string
+
 type RlInit struct {
+	// The prompt and right prompt can contain the placeholders {exit_code}
+	// and {duration} which are replaced by the exit code and duration of the
+	// last command added to the history via AddHistoryItem()
 	Prompt                  string
+	RightPrompt             string
 	HistoryPath             string
 	HistoryCount            int
 	ContinuationPrompt      string
@@ -30,6 +39,31 @@ type RlInit struct {
 	DontMarkPrompts         bool
 	SyntaxHighlighter       SyntaxHighlightFunction
 	Completer               CompleterFunction
+	// Which commands are stored in the history
+	HistoryPolicy HistoryPolicy
+	// Shown at the start of screen lines that continue a line of text that
+	// is too long to fit on a single screen line
+	WrapMarker string
+	// Used to show a suggestion after the cursor when it is at the end of
+	// the text, accepted with the keys to move right or to the end of the line
+	Suggester SuggesterFunction
+	// Additional key bindings, replacing the default bindings for the same
+	// keys. Panics if a binding is invalid, use BindKey() to handle errors.
+	Keymap Keymap
+	// Either EmacsEditingMode or ViEditingMode, when not set the editing
+	// mode is read from the environment variable EditingModeEnvVar,
+	// defaulting to emacs
+	EditingMode string
+	// Automatically insert the closing quote or bracket when an opening one
+	// is typed and surround the selected text with the pair
+	AutoPairs bool
+	// What is considered to be a word when moving by or killing words,
+	// can be cycled through with the cycle_word_style action
+	WordStyle WordStyle
+	// Expand history references such as !! when accepting input, see
+	// History.Expand(). The expanded text replaces the input and has to be
+	// accepted again, so it can be checked before it is run.
+	HistoryExpansion bool
 }
 
 type Position struct {
@@ -41,21 +75,34 @@ func (self Position) Less(other Position) bool {
 	return self.Y < other.Y || (self.Y == other.Y && self.X < other.X)
 }
 
+// The maximum number of items remembered in the kill ring
+const max_kill_ring_items = 120
+
 type kill_ring struct {
 	items *list.List
 }
 
-func (self *kill_ring) append_to_existing_item(text string) {
+// Add text to the most recent item, used when killing multiple times in a
+// row. Text killed backwards is placed before the existing text.
+func (self *kill_ring) append_to_existing_item(text string, backwards bool) {
 	e := self.items.Front()
 	if e == nil {
 		self.add_new_item(text)
+		return
+	}
+	if backwards {
+		e.Value = text + e.Value.(string)
+	} else {
+		e.Value = e.Value.(string) + text
 	}
-	e.Value = e.Value.(string) + text
 }
 
 func (self *kill_ring) add_new_item(text string) {
 	if text != "" {
 		self.items.PushFront(text)
+		for self.items.Len() > max_kill_ring_items {
+			self.items.Remove(self.items.Back())
+		}
 	}
 }
 
@@ -108,12 +155,16 @@ type syntax_highlighted struct {
 }
 
 type Readline struct {
-	prompt, continuation_prompt Prompt
+	prompt, continuation_prompt, right_prompt Prompt
+	prompt_template, right_prompt_template    string
+	wrap_marker                               Prompt
+	last_history_item                         *HistoryItem
 
 	mark_prompts bool
 	loop         *loop.Loop
 	history      *History
 	kill_ring    kill_ring
+	undo_stack   undo_stack
 
 	input_state InputState
 	// The number of lines after the initial line on the screen
@@ -131,6 +182,21 @@ type Readline struct {
 	text_to_be_added       string
 	syntax_highlighted     syntax_highlighted
 	completions            completions
+	suggester              SuggesterFunction
+	suggestion             suggestion
+	key_callbacks          []KeyCallback
+	// nil unless the default key bindings have been changed
+	shortcuts *ShortcutMap
+	// nil unless the vi editing mode is being used
+	vi *vi_state
+	// Lines stashed by ActionPushLine, restored at the next prompt
+	line_stash        []string
+	auto_pairs        bool
+	selection         selection
+	word_style        WordStyle
+	history_expansion bool
+	// The text of the last history expansion, not expanded again
+	history_expansion_preview string
 }
 
 func (self *Readline) make_prompt(text string, is_secondary bool) Prompt {
@@ -144,6 +210,42 @@ func (self *Readline) make_prompt(text string, is_secondary bool) Prompt {
 	return Prompt{Text: text, Length: wcswidth.Stringwidth(text)}
 }
 
+func format_duration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		d = d.Round(time.Millisecond)
+	case d < time.Minute:
+		d = d.Round(10 * time.Millisecond)
+	default:
+		d = d.Round(time.Second)
+	}
+	return d.String()
+}
+
+func (self *Readline) expand_prompt_template(text string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	exit_code, duration := "", ""
+	if hi := self.last_history_item; hi != nil {
+		if hi.ExitCode == 0 {
+			exit_code = self.fmt_ctx.Green("0")
+		} else {
+			exit_code = self.fmt_ctx.BrightRed(strconv.Itoa(hi.ExitCode))
+		}
+		if hi.Duration > 0 {
+			duration = self.fmt_ctx.Dim(format_duration(hi.Duration))
+		}
+	}
+	return strings.NewReplacer("{exit_code}", exit_code, "{duration}", duration).Replace(text)
+}
+
+func (self *Readline) update_prompts() {
+	self.prompt = self.make_prompt(self.expand_prompt_template(self.prompt_template), false)
+	rp := strings.TrimSpace(self.expand_prompt_template(self.right_prompt_template))
+	self.right_prompt = Prompt{Text: rp, Length: wcswidth.Stringwidth(rp)}
+}
+
 func New(loop *loop.Loop, r RlInit) *Readline {
 	hc := r.HistoryCount
 	if hc == 0 {
@@ -151,12 +253,21 @@ func New(loop *loop.Loop, r RlInit) *Readline {
 	}
 	ans := &Readline{
 		mark_prompts: !r.DontMarkPrompts, fmt_ctx: markup.New(true),
-		loop: loop, input_state: InputState{lines: []string{""}}, history: NewHistory(r.HistoryPath, hc),
+		loop: loop, input_state: InputState{lines: []string{""}}, history: NewHistory(r.HistoryPath, hc, r.HistoryPolicy),
 		syntax_highlighted: syntax_highlighted{highlighter: r.SyntaxHighlighter},
 		completions:        completions{completer: r.Completer},
 		kill_ring:          kill_ring{items: list.New().Init()},
+		prompt_template:    r.Prompt, right_prompt_template: r.RightPrompt,
+		suggester: r.Suggester, auto_pairs: r.AutoPairs, word_style: r.WordStyle % num_of_word_styles,
+		history_expansion: r.HistoryExpansion,
 	}
-	ans.prompt = ans.make_prompt(r.Prompt, false)
+	if err := ans.apply_keymap(r.Keymap); err != nil {
+		panic(err)
+	}
+	if editing_mode(r.EditingMode) == ViEditingMode {
+		ans.vi = &vi_state{registers: make(map[rune]vi_register)}
+	}
+	ans.update_prompts()
 	t := ""
 	if r.ContinuationPrompt != "" || !r.EmptyContinuationPrompt {
 		t = r.ContinuationPrompt
@@ -165,6 +276,7 @@ func New(loop *loop.Loop, r RlInit) *Readline {
 		}
 	}
 	ans.continuation_prompt = ans.make_prompt(t, true)
+	ans.wrap_marker = Prompt{Text: r.WrapMarker, Length: wcswidth.Stringwidth(r.WrapMarker)}
 	return ans
 }
 
@@ -172,8 +284,13 @@ func (self *Readline) Shutdown() {
 	self.history.Shutdown()
 }
 
+// Add an item to the history, writing it to the history file immediately, so
+// that it is available to other sessions using the same file
 func (self *Readline) AddHistoryItem(hi HistoryItem) {
 	self.history.merge_items(hi)
+	self.history.Write()
+	self.last_history_item = &hi
+	self.update_prompts()
 }
 
 func (self *Readline) ResetText() {
@@ -181,8 +298,25 @@ func (self *Readline) ResetText() {
 	self.last_action = ActionNil
 	self.keyboard_state = KeyboardState{}
 	self.history_search = nil
+	self.history_expansion_preview = ""
+	self.selection = selection{}
 	self.completions.current = completion{}
 	self.cursor_y = 0
+	self.undo_stack.clear()
+	if self.vi != nil {
+		self.vi.mode = vi_insert_mode
+		self.vi.pending = nil
+	}
+	self.pop_line()
+}
+
+// Replace the text being edited, placing the cursor between before_cursor
+// and after_cursor
+func (self *Readline) SetText(before_cursor, after_cursor string) {
+	self.ResetText()
+	self.add_text(after_cursor)
+	self.input_state.cursor = Position{}
+	self.add_text(before_cursor)
 }
 
 func (self *Readline) ChangeLoopAndResetText(lp *loop.Loop) {
@@ -191,7 +325,7 @@ func (self *Readline) ChangeLoopAndResetText(lp *loop.Loop) {
 }
 
 func (self *Readline) Start() {
-	self.loop.SetCursorShape(loop.BAR_CURSOR, true)
+	self.update_cursor_shape()
 	self.loop.StartBracketedPaste()
 	self.Redraw()
 }
@@ -233,10 +367,20 @@ func (self *Readline) OnText(text string, from_key_event bool, in_bracketed_past
 		self.bracketed_paste_buffer.WriteString(text)
 		return nil
 	}
+	is_paste := false
 	if self.bracketed_paste_buffer.Len() > 0 {
 		self.bracketed_paste_buffer.WriteString(text)
 		text = self.bracketed_paste_buffer.String()
 		self.bracketed_paste_buffer.Reset()
+		is_paste = true
+	}
+	if self.vi != nil && self.vi.mode == vi_normal_mode && self.history_search == nil && !is_paste {
+		err := self.vi_handle_text(text)
+		if err == ErrCouldNotPerformAction {
+			err = nil
+			self.loop.Beep()
+		}
+		return err
 	}
 	self.text_to_be_added = text
 	return self.dispatch_key_action(ActionAddText)
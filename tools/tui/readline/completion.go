@@ -9,6 +9,8 @@ import (
 	"kitty/tools/cli"
 	"kitty/tools/utils"
 	"kitty/tools/wcswidth"
+
+	"golang.org/x/exp/slices"
 )
 
 var _ = fmt.Print
@@ -19,8 +21,18 @@ type completion struct {
 	results_displayed, forwards   bool
 	num_of_matches, current_match int
 	rendered_at_screen_width      int
+	rendered_for_match            int
 	rendered_lines                []string
 	last_rendered_above           bool
+	// the menu of matches can be navigated and filtered
+	in_menu bool
+	// the matches before filtering and the query used to filter them
+	all_groups []*cli.MatchGroup
+	filter     string
+	// the matches are the choices for the value of an option, which are
+	// cycled through in place, instead of in a menu
+	cycling_choices          bool
+	choice_start, choice_end Position
 }
 
 func (self *completion) initialize() {
@@ -39,23 +51,85 @@ func (self *completion) initialize() {
 	}
 }
 
-func (self *completion) current_match_text() string {
+func (self *completion) current_match_and_group() (*cli.Match, *cli.MatchGroup) {
 	if self.results != nil {
 		i := 0
 		for _, g := range self.results.Groups {
 			for _, m := range g.Matches {
 				if i == self.current_match {
-					t := m.Word
-					if !g.NoTrailingSpace {
-						t += " "
-					}
-					return t
+					return m, g
 				}
 				i++
 			}
 		}
 	}
-	return ""
+	return nil, nil
+}
+
+func (self *completion) current_match_text() string {
+	m, g := self.current_match_and_group()
+	if m == nil {
+		return ""
+	}
+	if g.NoTrailingSpace {
+		return m.Word
+	}
+	return m.Word + " "
+}
+
+func (self *completion) matches_are_choices() bool {
+	if self.results == nil || len(self.results.Groups) == 0 {
+		return false
+	}
+	for _, g := range self.results.Groups {
+		if !g.IsChoices {
+			return false
+		}
+	}
+	return true
+}
+
+// The position of the choice inserted into the text, ok is false if no
+// choice is being cycled through
+func (self *completion) highlighted_choice() (start, end Position, ok bool) {
+	if !self.cycling_choices || self.choice_start == self.choice_end {
+		return
+	}
+	return self.choice_start, self.choice_end, true
+}
+
+// Filter the matches by fuzzy matching them against the filter query,
+// ordering the matches in each group by how well they match
+func (self *completion) apply_filter() {
+	self.results.Groups = make([]*cli.MatchGroup, 0, len(self.all_groups))
+	for _, g := range self.all_groups {
+		if self.filter == "" {
+			self.results.Groups = append(self.results.Groups, g)
+			continue
+		}
+		type scored_match struct {
+			m     *cli.Match
+			score int
+		}
+		scored := make([]scored_match, 0, len(g.Matches))
+		for _, m := range g.Matches {
+			if score := utils.FuzzyScore(self.filter, m.Word); score >= 0 {
+				scored = append(scored, scored_match{m, score})
+			}
+		}
+		if len(scored) == 0 {
+			continue
+		}
+		slices.SortStableFunc(scored, func(a, b scored_match) bool { return a.score > b.score })
+		fg := *g
+		fg.Matches = make([]*cli.Match, len(scored))
+		for i, s := range scored {
+			fg.Matches[i] = s.m
+		}
+		self.results.Groups = append(self.results.Groups, &fg)
+	}
+	self.rendered_lines = nil
+	self.initialize()
 }
 
 type completions struct {
@@ -68,7 +142,7 @@ func (self *Readline) complete(forwards bool, repeat_count uint) bool {
 	if c.completer == nil {
 		return false
 	}
-	if self.last_action == ActionCompleteForward || self.last_action == ActionCompleteBackward {
+	if c.current.in_menu || self.last_action == ActionCompleteForward || self.last_action == ActionCompleteBackward {
 		if c.current.num_of_matches == 0 {
 			return false
 		}
@@ -78,6 +152,13 @@ func (self *Readline) complete(forwards bool, repeat_count uint) bool {
 		}
 		repeat_count %= uint(c.current.num_of_matches)
 		delta *= int(repeat_count)
+		if c.current.current_match < 0 || c.current.current_match >= c.current.num_of_matches {
+			// no match is selected, as happens after filtering, start from the first or last match
+			c.current.current_match = -1
+			if !forwards {
+				c.current.current_match = c.current.num_of_matches
+			}
+		}
 		c.current.current_match = (c.current.current_match + delta + c.current.num_of_matches) % c.current.num_of_matches
 		repeat_count = 0
 	} else {
@@ -87,39 +168,111 @@ func (self *Readline) complete(forwards bool, repeat_count uint) bool {
 		if repeat_count > 0 {
 			repeat_count--
 		}
-		if c.current.current_match != 0 {
+		if c.current.num_of_matches > 1 && c.current.matches_are_choices() {
+			// insert the first (or last) choice right away, repeated
+			// completion then cycles through the rest
+			c.current.cycling_choices = true
+			c.current.current_match = 0
+			if !forwards {
+				c.current.current_match = c.current.num_of_matches - 1
+			}
+		}
+		if c.current.current_match != 0 && !c.current.cycling_choices {
 			if self.loop != nil {
 				self.loop.Beep()
 			}
 		}
+		if c.current.num_of_matches > 1 && !c.current.cycling_choices {
+			c.current.in_menu = true
+			c.current.all_groups = c.current.results.Groups
+			self.push_keyboard_map(completion_menu_shortcuts())
+		}
 	}
 	c.current.forwards = forwards
 	if c.current.results == nil {
 		return false
 	}
-	ct := c.current.current_match_text()
-	if ct != "" {
-		before := c.current.before_cursor[:c.current.results.CurrentWordIdx] + ct
-		after := c.current.after_cursor
-		self.input_state.lines = utils.Splitlines(before)
-		if len(self.input_state.lines) == 0 {
-			self.input_state.lines = []string{""}
-		}
-		self.input_state.cursor.Y = len(self.input_state.lines) - 1
-		self.input_state.cursor.X = len(self.input_state.lines[self.input_state.cursor.Y])
-		al := utils.Splitlines(after)
-		if len(al) > 0 {
-			self.input_state.lines[self.input_state.cursor.Y] += al[0]
-			self.input_state.lines = append(self.input_state.lines, al[1:]...)
-		}
-	}
+	self.apply_current_match()
 	if repeat_count > 0 {
 		self.complete(forwards, repeat_count)
 	}
 	return true
 }
 
-func (self *Readline) screen_lines_for_match_group_with_descriptions(g *cli.MatchGroup, lines []string) []string {
+// Replace the word being completed with the current match, or restore the
+// original text if there is no current match
+func (self *Readline) apply_current_match() {
+	c := &self.completions.current
+	before, after := c.before_cursor, c.after_cursor
+	if ct := c.current_match_text(); ct != "" {
+		before = before[:c.results.CurrentWordIdx] + ct
+	}
+	self.input_state.lines = utils.Splitlines(before)
+	if len(self.input_state.lines) == 0 {
+		self.input_state.lines = []string{""}
+	}
+	self.input_state.cursor.Y = len(self.input_state.lines) - 1
+	self.input_state.cursor.X = len(self.input_state.lines[self.input_state.cursor.Y])
+	if m, _ := c.current_match_and_group(); m != nil && c.cycling_choices {
+		start := self.input_state.cursor.X - len(c.current_match_text())
+		if start >= 0 {
+			c.choice_start = Position{X: start, Y: self.input_state.cursor.Y}
+			c.choice_end = Position{X: start + len(m.Word), Y: self.input_state.cursor.Y}
+		}
+	}
+	al := utils.Splitlines(after)
+	if len(al) > 0 {
+		self.input_state.lines[self.input_state.cursor.Y] += al[0]
+		self.input_state.lines = append(self.input_state.lines, al[1:]...)
+	}
+}
+
+func (self *Readline) add_text_to_completion_filter(text string) {
+	c := &self.completions.current
+	c.filter += text
+	c.apply_filter()
+	self.apply_current_match()
+}
+
+func (self *Readline) remove_text_from_completion_filter(num uint) uint {
+	c := &self.completions.current
+	runes := []rune(c.filter)
+	num = utils.Min(num, uint(len(runes)))
+	if num > 0 {
+		c.filter = string(runes[:len(runes)-int(num)])
+		c.apply_filter()
+		self.apply_current_match()
+	}
+	return num
+}
+
+// Close the menu of matches, either keeping the current match, or the first
+// match if none is selected, or restoring the original text
+func (self *Readline) end_completion_menu(accept bool) {
+	c := &self.completions.current
+	if !accept {
+		c.current_match = -1
+	} else if c.current_match < 0 || c.current_match >= c.num_of_matches {
+		c.current_match = 0
+	}
+	self.apply_current_match()
+	self.completions.current = completion{}
+	self.pop_keyboard_map()
+}
+
+// Highlight the match at index current in the group, if any
+func (self *Readline) highlighted_matches(g *cli.MatchGroup, current int) []*cli.Match {
+	if current < 0 || current >= len(g.Matches) {
+		return g.Matches
+	}
+	ans := append([]*cli.Match{}, g.Matches...)
+	hm := *ans[current]
+	hm.Word, hm.MatchedPositions = self.fmt_ctx.Reverse(hm.HighlightedWord(self.fmt_ctx)), nil
+	ans[current] = &hm
+	return ans
+}
+
+func (self *Readline) screen_lines_for_match_group_with_descriptions(g *cli.MatchGroup, current int, lines []string) []string {
 	maxw := 0
 	for _, m := range g.Matches {
 		l := wcswidth.Stringwidth(m.Word)
@@ -131,7 +284,7 @@ func (self *Readline) screen_lines_for_match_group_with_descriptions(g *cli.Matc
 			maxw = l
 		}
 	}
-	for _, m := range g.Matches {
+	for _, m := range self.highlighted_matches(g, current) {
 		lines = append(lines, utils.Splitlines(m.FormatForCompletionList(maxw, self.fmt_ctx, self.screen_width))...)
 	}
 	return lines
@@ -167,11 +320,9 @@ func (self *column) update_length() int {
 
 func layout_words_in_table(words []string, lengths map[string]int, num_cols int) ([]column, int) {
 	cols := make([]column, num_cols)
-	for i, col := range cols {
-		col.cells = make([]cell, 0, len(words))
-		if i == len(cols)-1 {
-			col.is_last = true
-		}
+	for i := range cols {
+		cols[i].cells = make([]cell, 0, len(words))
+		cols[i].is_last = i == len(cols)-1
 	}
 	c := 0
 	for _, word := range words {
@@ -191,12 +342,12 @@ func layout_words_in_table(words []string, lengths map[string]int, num_cols int)
 	return cols, total_length
 }
 
-func (self *Readline) screen_lines_for_match_group_without_descriptions(g *cli.MatchGroup, lines []string) []string {
+func (self *Readline) screen_lines_for_match_group_without_descriptions(g *cli.MatchGroup, current int, lines []string) []string {
 	words := make([]string, len(g.Matches))
 	lengths := make(map[string]int, len(words))
 	max_length := 0
-	for i, m := range g.Matches {
-		words[i] = m.Word
+	for i, m := range self.highlighted_matches(g, current) {
+		words[i] = m.HighlightedWord(self.fmt_ctx)
 		l := wcswidth.Stringwidth(words[i])
 		lengths[words[i]] = l
 		if l > max_length {
@@ -204,8 +355,8 @@ func (self *Readline) screen_lines_for_match_group_without_descriptions(g *cli.M
 		}
 	}
 	var ans []column
-	ncols := utils.Max(1, self.screen_width/(max_length+1))
-	for {
+	ncols := utils.Max(1, utils.Min(len(words), self.screen_width/(max_length+1)))
+	for ncols <= len(words) {
 		cols, total_length := layout_words_in_table(words, lengths, ncols)
 		if total_length > self.screen_width {
 			break
@@ -239,14 +390,23 @@ func (self *Readline) screen_lines_for_match_group_without_descriptions(g *cli.M
 }
 
 func (self *Readline) completion_screen_lines() ([]string, bool) {
-	if self.completions.current.results == nil || self.completions.current.num_of_matches < 2 {
+	c := &self.completions.current
+	if c.results == nil || c.cycling_choices || (c.num_of_matches < 2 && !c.in_menu) {
 		return []string{}, false
 	}
-	if len(self.completions.current.rendered_lines) > 0 && self.completions.current.rendered_at_screen_width == self.screen_width {
-		return self.completions.current.rendered_lines, true
+	if len(c.rendered_lines) > 0 && c.rendered_at_screen_width == self.screen_width && c.rendered_for_match == c.current_match {
+		return c.rendered_lines, true
+	}
+	lines := make([]string, 0, c.num_of_matches+1)
+	if c.filter != "" {
+		if c.num_of_matches == 0 {
+			lines = append(lines, "No matches for: "+c.filter)
+		} else {
+			lines = append(lines, "Filter: "+self.fmt_ctx.Green(c.filter))
+		}
 	}
-	lines := make([]string, 0, self.completions.current.num_of_matches)
-	for _, g := range self.completions.current.results.Groups {
+	first_match := 0
+	for _, g := range c.results.Groups {
 		if g.Title != "" {
 			lines = append(lines, self.fmt_ctx.Title(g.Title))
 		}
@@ -257,13 +417,16 @@ func (self *Readline) completion_screen_lines() ([]string, bool) {
 				break
 			}
 		}
+		current := c.current_match - first_match
 		if has_descriptions {
-			lines = self.screen_lines_for_match_group_with_descriptions(g, lines)
+			lines = self.screen_lines_for_match_group_with_descriptions(g, current, lines)
 		} else {
-			lines = self.screen_lines_for_match_group_without_descriptions(g, lines)
+			lines = self.screen_lines_for_match_group_without_descriptions(g, current, lines)
 		}
+		first_match += len(g.Matches)
 	}
-	self.completions.current.rendered_lines = lines
-	self.completions.current.rendered_at_screen_width = self.screen_width
+	c.rendered_lines = lines
+	c.rendered_at_screen_width = self.screen_width
+	c.rendered_for_match = c.current_match
 	return lines, false
 }
@@ -62,20 +62,27 @@ func (self *Readline) apply_syntax_highlighting() (lines []string, cursor Positi
 		highlighter = self.history_search_highlighter
 		highlighter_name = "## history ##"
 	}
-	if highlighter == nil {
+	_, _, has_selection := self.selection_bounds()
+	if cstart, cend, ok := self.completions.current.highlighted_choice(); ok && self.history_search == nil {
+		lines = self.lines_with_range_highlighted(cstart, cend)
+	} else if has_selection && self.history_search == nil {
+		// the selection is shown instead of syntax highlighting
+		lines = self.lines_with_selection_highlighted()
+	} else if highlighter == nil {
 		return self.input_state.lines, self.input_state.cursor
-	}
-	src := strings.Join(self.input_state.lines, "\n")
-	if len(self.syntax_highlighted.lines) > 0 && self.syntax_highlighted.last_highlighter_name == highlighter_name && self.syntax_highlighted.src_for_last_highlight == src {
-		lines = self.syntax_highlighted.lines
 	} else {
-		if src == "" {
-			lines = []string{""}
+		src := strings.Join(self.input_state.lines, "\n")
+		if len(self.syntax_highlighted.lines) > 0 && self.syntax_highlighted.last_highlighter_name == highlighter_name && self.syntax_highlighted.src_for_last_highlight == src {
+			lines = self.syntax_highlighted.lines
 		} else {
-			text := highlighter(src, self.input_state.cursor.X, self.input_state.cursor.Y)
-			lines = utils.Splitlines(text)
-			for len(lines) < len(self.input_state.lines) {
-				lines = append(lines, "syntax highlighter malfunctioned")
+			if src == "" {
+				lines = []string{""}
+			} else {
+				text := highlighter(src, self.input_state.cursor.X, self.input_state.cursor.Y)
+				lines = utils.Splitlines(text)
+				for len(lines) < len(self.input_state.lines) {
+					lines = append(lines, "syntax highlighter malfunctioned")
+				}
 			}
 		}
 	}
@@ -90,7 +97,15 @@ func (self *Readline) get_screen_lines() []*ScreenLine {
 		self.update_current_screen_size()
 	}
 	lines, cursor := self.apply_syntax_highlighting()
+	if s := self.formatted_suggestion(); s != "" {
+		lines = append([]string{}, lines...)
+		lines[len(lines)-1] += s
+	}
 	ans := make([]*ScreenLine, 0, len(lines))
+	wrap_marker := self.wrap_marker
+	if 2*wrap_marker.Length > self.screen_width {
+		wrap_marker = Prompt{}
+	}
 	found_cursor := false
 	cursor_at_start_of_next_line := false
 	for i, line := range lines {
@@ -119,14 +134,14 @@ func (self *Readline) get_screen_lines() []*ScreenLine {
 					if offset+len(l) < len(line) || i < len(lines)-1 {
 						cursor_at_start_of_next_line = true
 					} else {
-						ans = append(ans, &ScreenLine{ParentLineNumber: i, OffsetInParentLine: len(line)})
+						ans = append(ans, &ScreenLine{ParentLineNumber: i, OffsetInParentLine: len(line), Prompt: wrap_marker, CursorCell: wrap_marker.Length})
 					}
 				} else {
 					sl.CursorTextPos = ctpos
 					sl.CursorCell = ccell
 				}
 			}
-			prompt = Prompt{}
+			prompt = wrap_marker
 			offset += len(l)
 		}
 	}
@@ -182,18 +197,24 @@ func (self *Readline) redraw() {
 
 	for i, sl := range prompt_lines {
 		cursor_moved_down := false
-		if i > 0 && sl.AfterLineBreak {
+		// lines with a wrap marker cannot rely on the terminal wrapping them
+		if i > 0 && (sl.AfterLineBreak || sl.Prompt.Length > 0) {
 			self.loop.QueueWriteString("\r\n")
 			cursor_moved_down = true
 			text_length = 0
 		}
 		if sl.Prompt.Length > 0 {
-			p := self.prompt_for_line_number(i)
-			self.loop.QueueWriteString(p.Text)
-			text_length += p.Length
+			self.loop.QueueWriteString(sl.Prompt.Text)
+			text_length += sl.Prompt.Length
 		}
 		self.loop.QueueWriteString(sl.Text)
 		text_length += sl.TextLengthInCells
+		if i == 0 && len(prompt_lines) == 1 && self.right_prompt.Length > 0 && self.history_search == nil && text_length+self.right_prompt.Length+2 < self.screen_width {
+			// leave a gap before the right prompt and the last cell empty to avoid triggering a line wrap
+			self.loop.MoveCursorHorizontally(self.screen_width - 1 - self.right_prompt.Length - text_length)
+			self.loop.QueueWriteString(self.right_prompt.Text)
+			self.loop.MoveCursorHorizontally(-(self.screen_width - 1 - text_length))
+		}
 		if text_length == self.screen_width && sl.Text == "" && i == len(prompt_lines)-1 {
 			self.loop.QueueWriteString("\r\n")
 			cursor_moved_down = true
@@ -0,0 +1,78 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"kitty/tools/utils"
+	"kitty/tools/utils/shlex"
+)
+
+var _ = fmt.Print
+
+// The command used to edit text, from $VISUAL or $EDITOR, defaulting to vi
+func editor_command() ([]string, error) {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if val := strings.TrimSpace(os.Getenv(name)); val != "" {
+			ans, err := shlex.Split(val)
+			if err != nil {
+				return nil, fmt.Errorf("The editor command in $%s is invalid: %w", name, err)
+			}
+			return ans, nil
+		}
+	}
+	return []string{"vi"}, nil
+}
+
+// Run the editor on a temporary file containing text, returning the edited
+// text
+func run_editor(text string) (string, error) {
+	argv, err := editor_command()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "kitty-readline-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text + "\n")
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(argv[0], append(argv[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("Running the editor %s failed with error: %w", argv[0], err)
+	}
+	raw, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(raw), "\n"), nil
+}
+
+// Edit the current text in an external editor, replacing it with the
+// edited text and placing the cursor at the end
+func (self *Readline) edit_in_external_editor() bool {
+	var edited string
+	err := self.loop.SuspendAndRun(func() (err error) {
+		edited, err = run_editor(self.all_text())
+		return
+	})
+	if err != nil {
+		self.loop.QueueWriteString("\r\n" + err.Error() + "\r\n")
+		return false
+	}
+	self.input_state.lines = utils.Splitlines(edited)
+	if len(self.input_state.lines) == 0 {
+		self.input_state.lines = []string{""}
+	}
+	self.move_to_end()
+	return true
+}
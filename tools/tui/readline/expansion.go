@@ -0,0 +1,127 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+// Characters that end the prefix in a !prefix history reference
+const history_reference_terminators = " \t\n;&|<>()'\""
+
+func (self *History) find_reference(ref string) *HistoryItem {
+	if len(self.items) == 0 {
+		return nil
+	}
+	if ref == "!" {
+		return &self.items[len(self.items)-1]
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		idx := n - 1
+		if n < 0 {
+			idx = len(self.items) + n
+		}
+		if idx > -1 && idx < len(self.items) {
+			return &self.items[idx]
+		}
+		return nil
+	}
+	for i := len(self.items) - 1; i > -1; i-- {
+		if strings.HasPrefix(self.items[i].Cmd, ref) {
+			return &self.items[i]
+		}
+	}
+	return nil
+}
+
+// Expand bash style history references in text: !! is the last command, !n
+// the command number n in the history, !-n the nth last command and !prefix
+// the last command starting with prefix. A ! in single quotes, after a
+// backslash or followed by whitespace, =, ( or a character that ends a prefix,
+// such as ; or a quote, is not expanded. Returns
+// whether any references were expanded.
+func (self *History) Expand(text string) (string, bool, error) {
+	if !strings.Contains(text, "!") {
+		return text, false, nil
+	}
+	ans := strings.Builder{}
+	ans.Grow(len(text) + 256)
+	expanded := false
+	in_single_quotes, in_double_quotes := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '\\' && !in_single_quotes:
+			ans.WriteString(text[i:utils.Min(i+2, len(text))])
+			i++
+			continue
+		case ch == '\'' && !in_double_quotes:
+			in_single_quotes = !in_single_quotes
+		case ch == '"' && !in_single_quotes:
+			in_double_quotes = !in_double_quotes
+		case ch == '!' && !in_single_quotes && i+1 < len(text) && !strings.ContainsRune(" \t\n=(", rune(text[i+1])):
+			var ref string
+			switch rest := text[i+1:]; {
+			case rest[0] == '!':
+				ref = "!"
+			case rest[0] == '-' || (rest[0] >= '0' && rest[0] <= '9'):
+				end := 1
+				for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+					end++
+				}
+				ref = rest[:end]
+			default:
+				end := strings.IndexAny(rest, history_reference_terminators)
+				if end < 0 {
+					end = len(rest)
+				}
+				ref = rest[:end]
+			}
+			if ref == "" {
+				// as in bash, !" or !; is a literal !
+				break
+			}
+			item := self.find_reference(ref)
+			if item == nil {
+				return text, false, fmt.Errorf("!%s: event not found", ref)
+			}
+			ans.WriteString(item.Cmd)
+			expanded = true
+			i += len(ref)
+			continue
+		}
+		ans.WriteByte(ch)
+	}
+	return ans.String(), expanded, nil
+}
+
+// Replace the text with the result of expanding the history references in
+// it, so that the user can check the expansion before accepting it again.
+// Returns false if the text is ready to be accepted.
+func (self *Readline) expand_history_references() bool {
+	text := self.all_text()
+	if text == self.history_expansion_preview {
+		return false
+	}
+	expanded, changed, err := self.history.Expand(text)
+	if err != nil {
+		self.loop.QueueWriteString("\r\n" + err.Error() + "\r\n")
+		return true
+	}
+	if !changed {
+		return false
+	}
+	self.input_state.lines = utils.Splitlines(expanded)
+	if len(self.input_state.lines) == 0 {
+		self.input_state.lines = []string{""}
+	}
+	self.move_to_end()
+	self.history_expansion_preview = expanded
+	return true
+}
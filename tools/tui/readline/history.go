@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,33 +33,73 @@ type HistoryMatches struct {
 	original_input_state InputState
 }
 
+// A single occurrence of the first search token in a history item
+type HistorySearchMatch struct {
+	item *HistoryItem
+	pos  Position
+}
+
 type HistorySearch struct {
 	query                string
 	tokens               []string
-	items                []*HistoryItem
+	matches              []HistorySearchMatch
 	current_idx          int
 	backwards            bool
 	original_input_state InputState
 }
 
+// Policies that control which commands are stored in the history, applied
+// both when adding commands and when loading the history from its file
+type HistoryPolicy struct {
+	// Store every run of a command, not just the most recent one
+	KeepDuplicates bool
+	// Do not store commands that start with a space
+	IgnoreSpace bool
+	// Remove commands older than this, zero means no limit
+	MaxAge time.Duration
+}
+
 type History struct {
 	file_path string
 	file      *os.File
 	max_items int
+	policy    HistoryPolicy
 	items     []HistoryItem
 	cmd_map   map[string]int
 }
 
-func map_from_items(items []HistoryItem) map[string]int {
-	pmap := make(map[string]int, len(items))
-	for i, hi := range items {
-		pmap[hi.Cmd] = i
+func (self *History) key_for(x *HistoryItem) string {
+	if self.policy.KeepDuplicates {
+		return x.Cmd + "\x00" + strconv.FormatInt(x.Timestamp.UnixNano(), 10)
+	}
+	return x.Cmd
+}
+
+func (self *History) map_from_items() {
+	self.cmd_map = make(map[string]int, len(self.items))
+	for i := range self.items {
+		self.cmd_map[self.key_for(&self.items[i])] = i
+	}
+}
+
+func (self *History) is_acceptable(x *HistoryItem, oldest time.Time) bool {
+	if x.Cmd == "" || (self.policy.IgnoreSpace && strings.HasPrefix(x.Cmd, " ")) {
+		return false
+	}
+	return oldest.IsZero() || !x.Timestamp.Before(oldest)
+}
+
+// The earliest time for items to be kept, zero for no limit
+func (self *History) oldest_allowed() time.Time {
+	if self.policy.MaxAge > 0 {
+		return time.Now().Add(-self.policy.MaxAge)
 	}
-	return pmap
+	return time.Time{}
 }
 
 func (self *History) add_item(x HistoryItem) bool {
-	existing, found := self.cmd_map[x.Cmd]
+	key := self.key_for(&x)
+	existing, found := self.cmd_map[key]
 	if found {
 		if self.items[existing].Timestamp.Before(x.Timestamp) {
 			self.items[existing] = x
@@ -66,44 +107,46 @@ func (self *History) add_item(x HistoryItem) bool {
 		}
 		return false
 	}
-	self.cmd_map[x.Cmd] = len(self.items)
+	self.cmd_map[key] = len(self.items)
 	self.items = append(self.items, x)
 	return true
 }
 
 func (self *History) merge_items(items ...HistoryItem) {
-	if len(self.items) == 0 {
-		self.items = items
-		self.cmd_map = map_from_items(self.items)
-		return
-	}
-	if len(items) == 0 {
-		return
-	}
+	oldest := self.oldest_allowed()
 	changed := false
 	for _, x := range items {
-		if self.add_item(x) {
+		if self.is_acceptable(&x, oldest) && self.add_item(x) {
 			changed = true
 		}
 	}
-	if !changed {
+	if !changed && (oldest.IsZero() || len(self.items) == 0 || !self.items[0].Timestamp.Before(oldest)) {
 		return
 	}
 	self.items = utils.StableSort(self.items, func(a, b HistoryItem) bool {
 		return a.Timestamp.Before(b.Timestamp)
 	})
+	if !oldest.IsZero() {
+		for len(self.items) > 0 && self.items[0].Timestamp.Before(oldest) {
+			self.items = self.items[1:]
+		}
+	}
 	if len(self.items) > self.max_items {
 		self.items = self.items[len(self.items)-self.max_items:]
 	}
-	self.cmd_map = map_from_items(self.items)
+	self.map_from_items()
 }
 
 func (self *History) Write() {
 	if self.file == nil {
 		return
 	}
+	// merge in items written by other sessions since we last read the file,
+	// holding the lock so that they cannot write in the meantime
 	self.file.Seek(0, 0)
-	utils.LockFileExclusive(self.file)
+	if err := utils.LockFileExclusive(self.file); err != nil {
+		return
+	}
 	defer utils.UnlockFile(self.file)
 	data, err := io.ReadAll(self.file)
 	if err != nil {
@@ -111,7 +154,7 @@ func (self *History) Write() {
 	}
 	var items []HistoryItem
 	err = json.Unmarshal(data, &items)
-	if err != nil {
+	if err == nil {
 		self.merge_items(items...)
 	}
 	ndata, err := json.MarshalIndent(self.items, "", "  ")
@@ -153,8 +196,8 @@ func (self *History) Shutdown() {
 	}
 }
 
-func NewHistory(path string, max_items int) *History {
-	ans := History{items: []HistoryItem{}, cmd_map: map[string]int{}, max_items: max_items}
+func NewHistory(path string, max_items int, policy HistoryPolicy) *History {
+	ans := History{items: []HistoryItem{}, cmd_map: map[string]int{}, max_items: max_items, policy: policy}
 	if path != "" {
 		ans.file_path = path
 		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
@@ -253,10 +296,10 @@ func (self *Readline) create_history_search(backwards bool, num uint) {
 }
 
 func (self *Readline) end_history_search(accept bool) {
-	if accept && self.history_search.current_idx < len(self.history_search.items) {
-		self.input_state.lines = utils.Splitlines(self.history_search.items[self.history_search.current_idx].Cmd)
-		self.input_state.cursor.Y = len(self.input_state.lines) - 1
-		self.input_state.cursor.X = len(self.input_state.lines[self.input_state.cursor.Y])
+	if accept && self.history_search.current_idx < len(self.history_search.matches) {
+		m := self.history_search.matches[self.history_search.current_idx]
+		self.input_state.lines = utils.Splitlines(m.item.Cmd)
+		self.input_state.cursor = m.pos
 	} else {
 		self.input_state = self.history_search.original_input_state
 	}
@@ -266,7 +309,7 @@ func (self *Readline) end_history_search(accept bool) {
 }
 
 func (self *Readline) markup_history_search() {
-	if len(self.history_search.items) == 0 {
+	if len(self.history_search.matches) == 0 {
 		if len(self.history_search.tokens) == 0 {
 			self.input_state.lines = []string{""}
 		} else {
@@ -275,21 +318,9 @@ func (self *Readline) markup_history_search() {
 		self.input_state.cursor = Position{X: wcswidth.Stringwidth(self.input_state.lines[0])}
 		return
 	}
-	lines := utils.Splitlines(self.history_search.items[self.history_search.current_idx].Cmd)
-	cursor := Position{Y: len(lines)}
-	for _, tok := range self.history_search.tokens {
-		for i, line := range lines {
-			if idx := strings.Index(line, tok); idx > -1 {
-				q := Position{Y: i, X: idx}
-				if q.Less(cursor) {
-					cursor = q
-				}
-				break
-			}
-		}
-	}
-	self.input_state.lines = lines
-	self.input_state.cursor = *self.ensure_position_in_bounds(&cursor)
+	m := self.history_search.matches[self.history_search.current_idx]
+	self.input_state.lines = utils.Splitlines(m.item.Cmd)
+	self.input_state.cursor = *self.ensure_position_in_bounds(&m.pos)
 }
 
 func (self *Readline) remove_text_from_history_search(num uint) uint {
@@ -301,20 +332,71 @@ func (self *Readline) remove_text_from_history_search(num uint) uint {
 	return num_removed
 }
 
+// Highlight every occurrence of every token in line
+func highlight_tokens(line string, tokens []string, highlight func(...any) string) string {
+	matched := make([]bool, len(line))
+	found := false
+	for _, tok := range tokens {
+		if tok == "" {
+			continue
+		}
+		for start := 0; start < len(line); {
+			idx := strings.Index(line[start:], tok)
+			if idx < 0 {
+				break
+			}
+			idx += start
+			for i := idx; i < idx+len(tok); i++ {
+				matched[i] = true
+			}
+			found = true
+			start = idx + len(tok)
+		}
+	}
+	if !found {
+		return line
+	}
+	buf := strings.Builder{}
+	buf.Grow(2 * len(line))
+	for start := 0; start < len(line); {
+		end := start + 1
+		for end < len(line) && matched[end] == matched[start] {
+			end++
+		}
+		if matched[start] {
+			buf.WriteString(highlight(line[start:end]))
+		} else {
+			buf.WriteString(line[start:end])
+		}
+		start = end
+	}
+	return buf.String()
+}
+
 func (self *Readline) history_search_highlighter(text string, x, y int) string {
-	if len(self.history_search.items) == 0 {
+	if len(self.history_search.matches) == 0 {
 		return text
 	}
 	lines := utils.Splitlines(text)
-	for _, tok := range self.history_search.tokens {
-		for i, line := range lines {
-			if idx := strings.Index(line, tok); idx > -1 {
-				lines[i] = line[:idx] + self.fmt_ctx.Green(tok) + line[idx+len(tok):]
+	for i, line := range lines {
+		lines[i] = highlight_tokens(line, self.history_search.tokens, self.fmt_ctx.Green)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// All occurrences of token in the lines of the specified item
+func history_search_matches(item *HistoryItem, token string) (ans []HistorySearchMatch) {
+	for y, line := range utils.Splitlines(item.Cmd) {
+		for start := 0; start < len(line); {
+			idx := strings.Index(line[start:], token)
+			if idx < 0 {
 				break
 			}
+			ans = append(ans, HistorySearchMatch{item: item, pos: Position{X: start + idx, Y: y}})
+			start += idx + utils.Max(1, len(token))
 		}
 	}
-	return strings.Join(lines, "\n")
+	return
 }
 
 func (self *Readline) add_text_to_history_search(text string) {
@@ -325,12 +407,11 @@ func (self *Readline) add_text_to_history_search(text string) {
 	}
 	self.history_search.tokens = tokens
 	var current_item *HistoryItem
-	if len(self.history_search.items) > 0 {
-		current_item = self.history_search.items[self.history_search.current_idx]
+	if len(self.history_search.matches) > 0 {
+		current_item = self.history_search.matches[self.history_search.current_idx].item
 	}
-	if len(self.history_search.tokens) == 0 {
-		self.history_search.items = []*HistoryItem{}
-	} else {
+	self.history_search.matches = []HistorySearchMatch{}
+	if len(self.history_search.tokens) > 0 {
 		items := make([]*HistoryItem, len(self.history.items))
 		for i := range self.history.items {
 			items[i] = &self.history.items[i]
@@ -344,18 +425,22 @@ func (self *Readline) add_text_to_history_search(text string) {
 			}
 			items = matches
 		}
-		self.history_search.items = items
+		for _, item := range items {
+			self.history_search.matches = append(self.history_search.matches, history_search_matches(item, self.history_search.tokens[0])...)
+		}
 	}
 	idx := -1
-	for i, item := range self.history_search.items {
-		if item == current_item {
+	for i, m := range self.history_search.matches {
+		if m.item == current_item {
 			idx = i
-			break
+			if !self.history_search.backwards {
+				break
+			}
 		}
 	}
 	if idx == -1 {
 		if self.history_search.backwards {
-			idx = len(self.history_search.items) - 1
+			idx = len(self.history_search.matches) - 1
 		} else {
 			idx = 0
 		}
@@ -367,13 +452,13 @@ func (self *Readline) add_text_to_history_search(text string) {
 func (self *Readline) next_history_search(backwards bool, num uint) bool {
 	ni := self.history_search.current_idx
 	self.history_search.backwards = backwards
-	if len(self.history_search.items) == 0 {
+	if len(self.history_search.matches) == 0 {
 		return false
 	}
 	if backwards {
 		ni = utils.Max(0, ni-int(num))
 	} else {
-		ni = utils.Min(ni+int(num), len(self.history_search.items)-1)
+		ni = utils.Min(ni+int(num), len(self.history_search.matches)-1)
 	}
 	if ni == self.history_search.current_idx {
 		return false
@@ -388,11 +473,14 @@ func (self *Readline) history_search_prompt() string {
 	if !self.history_search.backwards {
 		ans = "↓"
 	}
-	failed := len(self.history_search.tokens) > 0 && len(self.history_search.items) == 0
+	failed := len(self.history_search.tokens) > 0 && len(self.history_search.matches) == 0
 	if failed {
 		ans = self.fmt_ctx.BrightRed(ans)
 	} else {
 		ans = self.fmt_ctx.Green(ans)
 	}
+	if n := len(self.history_search.matches); n > 0 {
+		return fmt.Sprintf("history %s %d/%d: ", ans, self.history_search.current_idx+1, n)
+	}
 	return fmt.Sprintf("history %s: ", ans)
 }
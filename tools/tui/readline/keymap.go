@@ -0,0 +1,116 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var _ = fmt.Print
+
+// Called when a key bound to it is pressed, repeat_count is the numeric
+// argument, if any, defaulting to one
+type KeyCallback = func(rl *Readline, repeat_count uint) error
+
+// What happens when a key is pressed, either the action with the specified
+// name, see ActionNames(), or the callback. An empty KeyBinding removes the
+// default binding for the key.
+type KeyBinding struct {
+	Action   string
+	Callback KeyCallback
+}
+
+// Maps keys, such as "ctrl+k" or "ctrl+x ctrl+e" for a sequence of keys, to
+// what happens when they are pressed. Bindings in a Keymap are used in
+// addition to the default bindings, replacing them for the same keys.
+type Keymap = map[string]KeyBinding
+
+// Actions with values at or above this are callbacks from a Keymap
+const first_callback_action Action = 1 << 16
+
+// Convert an action such as ActionKillToEndOfLine to its name, kill_to_end_of_line
+func action_name(ac Action) string {
+	q := strings.TrimPrefix(ac.String(), "Action")
+	ans := strings.Builder{}
+	for i, r := range q {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				ans.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		ans.WriteRune(r)
+	}
+	return ans.String()
+}
+
+var _action_name_map map[string]Action
+
+func action_name_map() map[string]Action {
+	if _action_name_map == nil {
+		_action_name_map = make(map[string]Action)
+		for ac := ActionNil + 1; strings.HasPrefix(ac.String(), "Action"); ac++ {
+			_action_name_map[action_name(ac)] = ac
+		}
+	}
+	return _action_name_map
+}
+
+// The names of the actions that can be used in a KeyBinding
+func ActionNames() []string {
+	ans := make([]string, 0, len(action_name_map()))
+	for ac := ActionNil + 1; strings.HasPrefix(ac.String(), "Action"); ac++ {
+		ans = append(ans, action_name(ac))
+	}
+	return ans
+}
+
+func ActionFromName(name string) (Action, error) {
+	if ac, found := action_name_map()[name]; found {
+		return ac, nil
+	}
+	return ActionNil, fmt.Errorf("%#v is not a known action", name)
+}
+
+// Bind the specified keys, replacing any existing binding for them
+func (self *Readline) BindKey(keys string, b KeyBinding) error {
+	ac := ActionNil
+	switch {
+	case b.Callback != nil:
+		ac = first_callback_action + Action(len(self.key_callbacks))
+		self.key_callbacks = append(self.key_callbacks, b.Callback)
+	case b.Action != "":
+		var err error
+		if ac, err = ActionFromName(b.Action); err != nil {
+			return err
+		}
+	}
+	seq := strings.Fields(keys)
+	if len(seq) == 0 {
+		return fmt.Errorf("No keys specified for binding")
+	}
+	if self.shortcuts == nil {
+		self.shortcuts = new_default_shortcuts()
+	}
+	self.shortcuts.Add(ac, seq...)
+	return nil
+}
+
+func (self *Readline) apply_keymap(km Keymap) error {
+	for keys, b := range km {
+		if err := self.BindKey(keys, b); err != nil {
+			return fmt.Errorf("Invalid key binding for %s: %w", keys, err)
+		}
+	}
+	return nil
+}
+
+// The shortcuts used when no other keyboard map is active
+func (self *Readline) base_shortcuts() *ShortcutMap {
+	if self.shortcuts != nil {
+		return self.shortcuts
+	}
+	return default_shortcuts()
+}
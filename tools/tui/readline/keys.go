@@ -25,78 +25,95 @@ var _default_shortcuts *ShortcutMap
 
 func default_shortcuts() *ShortcutMap {
 	if _default_shortcuts == nil {
-		sm := shortcuts.New[Action]()
-		sm.AddOrPanic(ActionBackspace, "backspace")
-		sm.AddOrPanic(ActionBackspace, "ctrl+h")
-		sm.AddOrPanic(ActionDelete, "delete")
-
-		sm.AddOrPanic(ActionMoveToStartOfLine, "home")
-		sm.AddOrPanic(ActionMoveToStartOfLine, "ctrl+a")
-
-		sm.AddOrPanic(ActionMoveToEndOfLine, "end")
-		sm.AddOrPanic(ActionMoveToEndOfLine, "ctrl+e")
-
-		sm.AddOrPanic(ActionMoveToStartOfDocument, "ctrl+home")
-		sm.AddOrPanic(ActionMoveToEndOfDocument, "ctrl+end")
-
-		sm.AddOrPanic(ActionMoveToEndOfWord, "alt+f")
-		sm.AddOrPanic(ActionMoveToEndOfWord, "ctrl+right")
-		sm.AddOrPanic(ActionMoveToEndOfWord, "alt+right")
-		sm.AddOrPanic(ActionMoveToStartOfWord, "ctrl+left")
-		sm.AddOrPanic(ActionMoveToStartOfWord, "alt+left")
-		sm.AddOrPanic(ActionMoveToStartOfWord, "alt+b")
-
-		sm.AddOrPanic(ActionCursorLeft, "left")
-		sm.AddOrPanic(ActionCursorLeft, "ctrl+b")
-		sm.AddOrPanic(ActionCursorRight, "right")
-		sm.AddOrPanic(ActionCursorRight, "ctrl+f")
-
-		sm.AddOrPanic(ActionClearScreen, "ctrl+l")
-		sm.AddOrPanic(ActionAbortCurrentLine, "ctrl+c")
-		sm.AddOrPanic(ActionAbortCurrentLine, "ctrl+g")
-
-		sm.AddOrPanic(ActionEndInput, "ctrl+d")
-		sm.AddOrPanic(ActionAcceptInput, "enter")
-
-		sm.AddOrPanic(ActionKillToEndOfLine, "ctrl+k")
-		sm.AddOrPanic(ActionKillToStartOfLine, "ctrl+x")
-		sm.AddOrPanic(ActionKillToStartOfLine, "ctrl+u")
-		sm.AddOrPanic(ActionKillNextWord, "alt+d")
-		sm.AddOrPanic(ActionKillPreviousWord, "alt+backspace")
-		sm.AddOrPanic(ActionKillPreviousSpaceDelimitedWord, "ctrl+w")
-		sm.AddOrPanic(ActionYank, "ctrl+y")
-		sm.AddOrPanic(ActionPopYank, "alt+y")
-
-		sm.AddOrPanic(ActionHistoryPreviousOrCursorUp, "up")
-		sm.AddOrPanic(ActionHistoryNextOrCursorDown, "down")
-		sm.AddOrPanic(ActionHistoryPrevious, "ctrl+p")
-		sm.AddOrPanic(ActionHistoryNext, "ctrl+n")
-		sm.AddOrPanic(ActionHistoryFirst, "alt+<")
-		sm.AddOrPanic(ActionHistoryLast, "alt+>")
-		sm.AddOrPanic(ActionHistoryIncrementalSearchBackwards, "ctrl+r")
-		sm.AddOrPanic(ActionHistoryIncrementalSearchBackwards, "ctrl+?")
-		sm.AddOrPanic(ActionHistoryIncrementalSearchForwards, "ctrl+s")
-		sm.AddOrPanic(ActionHistoryIncrementalSearchForwards, "ctrl+/")
-
-		sm.AddOrPanic(ActionNumericArgumentDigit0, "alt+0")
-		sm.AddOrPanic(ActionNumericArgumentDigit1, "alt+1")
-		sm.AddOrPanic(ActionNumericArgumentDigit2, "alt+2")
-		sm.AddOrPanic(ActionNumericArgumentDigit3, "alt+3")
-		sm.AddOrPanic(ActionNumericArgumentDigit4, "alt+4")
-		sm.AddOrPanic(ActionNumericArgumentDigit5, "alt+5")
-		sm.AddOrPanic(ActionNumericArgumentDigit6, "alt+6")
-		sm.AddOrPanic(ActionNumericArgumentDigit7, "alt+7")
-		sm.AddOrPanic(ActionNumericArgumentDigit8, "alt+8")
-		sm.AddOrPanic(ActionNumericArgumentDigit9, "alt+9")
-		sm.AddOrPanic(ActionNumericArgumentDigitMinus, "alt+-")
-
-		sm.AddOrPanic(ActionCompleteForward, "Tab")
-		sm.AddOrPanic(ActionCompleteBackward, "Shift+Tab")
-		_default_shortcuts = sm
+		_default_shortcuts = new_default_shortcuts()
 	}
 	return _default_shortcuts
 }
 
+func new_default_shortcuts() *ShortcutMap {
+	sm := shortcuts.New[Action]()
+	sm.AddOrPanic(ActionBackspace, "backspace")
+	sm.AddOrPanic(ActionBackspace, "ctrl+h")
+	sm.AddOrPanic(ActionDelete, "delete")
+
+	sm.AddOrPanic(ActionMoveToStartOfLine, "home")
+	sm.AddOrPanic(ActionMoveToStartOfLine, "ctrl+a")
+
+	sm.AddOrPanic(ActionMoveToEndOfLine, "end")
+	sm.AddOrPanic(ActionMoveToEndOfLine, "ctrl+e")
+
+	sm.AddOrPanic(ActionMoveToStartOfDocument, "ctrl+home")
+	sm.AddOrPanic(ActionMoveToEndOfDocument, "ctrl+end")
+
+	sm.AddOrPanic(ActionMoveToEndOfWord, "alt+f")
+	sm.AddOrPanic(ActionMoveToEndOfWord, "ctrl+right")
+	sm.AddOrPanic(ActionMoveToEndOfWord, "alt+right")
+	sm.AddOrPanic(ActionMoveToStartOfWord, "ctrl+left")
+	sm.AddOrPanic(ActionMoveToStartOfWord, "alt+left")
+	sm.AddOrPanic(ActionMoveToStartOfWord, "alt+b")
+
+	sm.AddOrPanic(ActionCursorLeft, "left")
+	sm.AddOrPanic(ActionCursorLeft, "ctrl+b")
+	sm.AddOrPanic(ActionCursorRight, "right")
+	sm.AddOrPanic(ActionCursorRight, "ctrl+f")
+
+	sm.AddOrPanic(ActionClearScreen, "ctrl+l")
+	sm.AddOrPanic(ActionAbortCurrentLine, "ctrl+c")
+	sm.AddOrPanic(ActionAbortCurrentLine, "ctrl+g")
+
+	sm.AddOrPanic(ActionEndInput, "ctrl+d")
+	sm.AddOrPanic(ActionAcceptInput, "enter")
+
+	sm.AddOrPanic(ActionKillToEndOfLine, "ctrl+k")
+	sm.AddOrPanic(ActionKillToStartOfLine, "ctrl+u")
+	sm.AddOrPanic(ActionKillNextWord, "alt+d")
+	sm.AddOrPanic(ActionKillPreviousWord, "alt+backspace")
+	sm.AddOrPanic(ActionKillPreviousSpaceDelimitedWord, "ctrl+w")
+	sm.AddOrPanic(ActionYank, "ctrl+y")
+	sm.AddOrPanic(ActionPopYank, "alt+y")
+
+	sm.AddOrPanic(ActionHistoryPreviousOrCursorUp, "up")
+	sm.AddOrPanic(ActionHistoryNextOrCursorDown, "down")
+	sm.AddOrPanic(ActionCursorUpLogicalLine, "alt+up")
+	sm.AddOrPanic(ActionCursorDownLogicalLine, "alt+down")
+	sm.AddOrPanic(ActionHistoryPrevious, "ctrl+p")
+	sm.AddOrPanic(ActionHistoryNext, "ctrl+n")
+	sm.AddOrPanic(ActionHistoryFirst, "alt+<")
+	sm.AddOrPanic(ActionHistoryLast, "alt+>")
+	sm.AddOrPanic(ActionHistoryIncrementalSearchBackwards, "ctrl+r")
+	sm.AddOrPanic(ActionHistoryIncrementalSearchBackwards, "ctrl+?")
+	sm.AddOrPanic(ActionHistoryIncrementalSearchForwards, "ctrl+s")
+	sm.AddOrPanic(ActionHistoryIncrementalSearchForwards, "ctrl+/")
+
+	sm.AddOrPanic(ActionNumericArgumentDigit0, "alt+0")
+	sm.AddOrPanic(ActionNumericArgumentDigit1, "alt+1")
+	sm.AddOrPanic(ActionNumericArgumentDigit2, "alt+2")
+	sm.AddOrPanic(ActionNumericArgumentDigit3, "alt+3")
+	sm.AddOrPanic(ActionNumericArgumentDigit4, "alt+4")
+	sm.AddOrPanic(ActionNumericArgumentDigit5, "alt+5")
+	sm.AddOrPanic(ActionNumericArgumentDigit6, "alt+6")
+	sm.AddOrPanic(ActionNumericArgumentDigit7, "alt+7")
+	sm.AddOrPanic(ActionNumericArgumentDigit8, "alt+8")
+	sm.AddOrPanic(ActionNumericArgumentDigit9, "alt+9")
+	sm.AddOrPanic(ActionNumericArgumentDigitMinus, "alt+-")
+
+	sm.AddOrPanic(ActionCompleteForward, "Tab")
+	sm.AddOrPanic(ActionCompleteBackward, "Shift+Tab")
+
+	sm.AddOrPanic(ActionUndo, "ctrl+_")
+	sm.AddOrPanic(ActionRedo, "alt+_")
+
+	sm.AddOrPanic(ActionEditInExternalEditor, "ctrl+x", "ctrl+e")
+
+	sm.AddOrPanic(ActionPushLine, "ctrl+q")
+	sm.AddOrPanic(ActionPushLine, "alt+q")
+
+	sm.AddOrPanic(ActionSetMark, "ctrl+space")
+
+	sm.AddOrPanic(ActionCycleWordStyle, "ctrl+x", "w")
+	return sm
+}
+
 var _history_search_shortcuts *shortcuts.ShortcutMap[Action]
 
 func history_search_shortcuts() *shortcuts.ShortcutMap[Action] {
@@ -139,6 +156,35 @@ func history_search_shortcuts() *shortcuts.ShortcutMap[Action] {
 	return _history_search_shortcuts
 }
 
+var _completion_menu_shortcuts *shortcuts.ShortcutMap[Action]
+
+func completion_menu_shortcuts() *shortcuts.ShortcutMap[Action] {
+	if _completion_menu_shortcuts == nil {
+		sm := shortcuts.New[Action]()
+		sm.AddOrPanic(ActionBackspace, "backspace")
+		sm.AddOrPanic(ActionBackspace, "ctrl+h")
+
+		sm.AddOrPanic(ActionCompleteForward, "Tab")
+		sm.AddOrPanic(ActionCompleteForward, "down")
+		sm.AddOrPanic(ActionCompleteForward, "right")
+		sm.AddOrPanic(ActionCompleteForward, "ctrl+n")
+		sm.AddOrPanic(ActionCompleteBackward, "Shift+Tab")
+		sm.AddOrPanic(ActionCompleteBackward, "up")
+		sm.AddOrPanic(ActionCompleteBackward, "left")
+		sm.AddOrPanic(ActionCompleteBackward, "ctrl+p")
+
+		sm.AddOrPanic(ActionTerminateCompletionMenuAndRestore, "ctrl+c")
+		sm.AddOrPanic(ActionTerminateCompletionMenuAndRestore, "ctrl+g")
+		sm.AddOrPanic(ActionTerminateCompletionMenuAndRestore, "escape")
+
+		sm.AddOrPanic(ActionTerminateCompletionMenuAndApply, "enter")
+		sm.AddOrPanic(ActionTerminateCompletionMenuAndApply, "ctrl+j")
+
+		_completion_menu_shortcuts = sm
+	}
+	return _completion_menu_shortcuts
+}
+
 var ErrCouldNotPerformAction = errors.New("Could not perform the specified action")
 var ErrAcceptInput = errors.New("Accept input")
 
@@ -195,14 +241,23 @@ func (self *Readline) dispatch_key_action(ac Action) error {
 	if err != nil || repeat_count <= 0 {
 		repeat_count = 1
 	}
+	if ac >= first_callback_action {
+		cb := self.key_callbacks[ac-first_callback_action]
+		return self.with_undo(undo_edit, func() error { return cb(self, uint(repeat_count)) })
+	}
 	return self.perform_action(ac, uint(repeat_count))
 }
 
 func (self *Readline) handle_key_event(event *loop.KeyEvent) error {
-	if event.Text != "" {
+	if handled, err := self.vi_handle_key_event(event); handled {
+		return err
+	}
+	if event.Text != "" && len(self.keyboard_state.current_pending_keys) == 0 {
+		// text keys are inserted as text unless they complete a multi-key
+		// shortcut such as ctrl+x w
 		return nil
 	}
-	sm := default_shortcuts()
+	sm := self.base_shortcuts()
 	if len(self.keyboard_state.active_shortcut_maps) > 0 {
 		sm = self.keyboard_state.active_shortcut_maps[len(self.keyboard_state.active_shortcut_maps)-1]
 	}
@@ -214,6 +269,8 @@ func (self *Readline) handle_key_event(event *loop.KeyEvent) error {
 		}
 		self.keyboard_state.current_pending_keys = append(self.keyboard_state.current_pending_keys, pending)
 	} else {
+		// the sequence is complete, or ended by a key that does not
+		// continue it, either way the next key starts afresh
 		self.keyboard_state.current_pending_keys = nil
 		if ac != ActionNil {
 			event.Handled = true
@@ -0,0 +1,95 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+var _ = fmt.Print
+
+var closing_pair_of = map[string]string{
+	"(": ")", "[": "]", "{": "}", `"`: `"`, "'": "'", "`": "`",
+}
+
+var is_closing_pair = map[string]bool{
+	")": true, "]": true, "}": true, `"`: true, "'": true, "`": true,
+}
+
+func (self *Readline) char_before_cursor() rune {
+	line := self.input_state.lines[self.input_state.cursor.Y]
+	r, _ := utf8.DecodeLastRuneInString(line[:self.input_state.cursor.X])
+	return r
+}
+
+func (self *Readline) char_after_cursor() rune {
+	line := self.input_state.lines[self.input_state.cursor.Y]
+	r, _ := utf8.DecodeRuneInString(line[self.input_state.cursor.X:])
+	return r
+}
+
+func (self *Readline) insert_at(pos Position, text string) {
+	line := self.input_state.lines[pos.Y]
+	self.input_state.lines[pos.Y] = line[:pos.X] + text + line[pos.X:]
+}
+
+// Insert typed text, automatically adding the closing quote or bracket when an
+// opening one is typed, typing over the closing one instead of adding a
+// duplicate and surrounding the selected text with the pair. Returns false if
+// the text must be added normally.
+func (self *Readline) add_paired_text(text string) bool {
+	if !self.auto_pairs {
+		return false
+	}
+	closer, is_opener := closing_pair_of[text]
+	if is_opener {
+		if start, end, ok := self.selection_bounds(); ok {
+			// insert at the end first so that start remains valid
+			self.insert_at(end, closer)
+			self.insert_at(start, text)
+			if end.Y == start.Y {
+				end.X += len(text)
+			}
+			self.input_state.cursor = Position{X: end.X + len(closer), Y: end.Y}
+			return true
+		}
+	}
+	if is_closing_pair[text] && string(self.char_after_cursor()) == text {
+		self.input_state.cursor.X += len(text)
+		return true
+	}
+	if !is_opener {
+		return false
+	}
+	if after := self.char_after_cursor(); after != utf8.RuneError && !unicode.IsSpace(after) && !is_closing_pair[string(after)] {
+		return false
+	}
+	if closer == text {
+		// dont pair apostrophes and quotes that end a word
+		if before := self.char_before_cursor(); before == '\\' || unicode.IsLetter(before) || unicode.IsDigit(before) {
+			return false
+		}
+	}
+	self.insert_at(self.input_state.cursor, text+closer)
+	self.input_state.cursor.X += len(text)
+	return true
+}
+
+// Delete both halves of an empty pair when the opening half is deleted
+func (self *Readline) erase_empty_pair() bool {
+	if !self.auto_pairs {
+		return false
+	}
+	before := string(self.char_before_cursor())
+	closer, found := closing_pair_of[before]
+	if !found || string(self.char_after_cursor()) != closer {
+		return false
+	}
+	c := self.input_state.cursor
+	line := self.input_state.lines[c.Y]
+	self.input_state.lines[c.Y] = line[:c.X-len(before)] + line[c.X+len(closer):]
+	self.input_state.cursor.X -= len(before)
+	return true
+}
@@ -0,0 +1,92 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+)
+
+var _ = fmt.Print
+
+// The selection is the text between the mark, set with ActionSetMark, and the
+// cursor. It remains active while the cursor is moved and is deactivated by
+// any other action.
+type selection struct {
+	mark   Position
+	active bool
+}
+
+func is_cursor_movement_action(ac Action) bool {
+	switch ac {
+	case ActionMoveToStartOfLine, ActionMoveToEndOfLine, ActionMoveToStartOfDocument, ActionMoveToEndOfDocument,
+		ActionMoveToEndOfWord, ActionMoveToStartOfWord, ActionCursorLeft, ActionCursorRight,
+		ActionCursorUp, ActionCursorDown, ActionCursorUpLogicalLine, ActionCursorDownLogicalLine:
+		return true
+	}
+	return ac >= ActionNumericArgumentDigit0 && ac <= ActionNumericArgumentDigitMinus
+}
+
+func (self *Readline) set_mark() bool {
+	if self.history_search != nil {
+		return false
+	}
+	if self.selection.active && self.selection.mark == self.input_state.cursor {
+		self.selection.active = false
+	} else {
+		self.selection = selection{mark: self.input_state.cursor, active: true}
+	}
+	return true
+}
+
+// The start and end of the selected text, ok is false if there is no selection
+func (self *Readline) selection_bounds() (start, end Position, ok bool) {
+	if !self.selection.active || self.selection.mark == self.input_state.cursor {
+		return
+	}
+	start, end = self.selection.mark, self.input_state.cursor
+	if end.Less(start) {
+		start, end = end, start
+	}
+	return start, end, true
+}
+
+func (self *Readline) SelectedText() string {
+	start, end, ok := self.selection_bounds()
+	if !ok {
+		return ""
+	}
+	if start.Y == end.Y {
+		return self.input_state.lines[start.Y][start.X:end.X]
+	}
+	ans := self.input_state.lines[start.Y][start.X:]
+	for y := start.Y + 1; y < end.Y; y++ {
+		ans += "\n" + self.input_state.lines[y]
+	}
+	return ans + "\n" + self.input_state.lines[end.Y][:end.X]
+}
+
+// The input lines with the selected text shown in reverse video
+func (self *Readline) lines_with_selection_highlighted() []string {
+	start, end, ok := self.selection_bounds()
+	if !ok {
+		return self.input_state.lines
+	}
+	return self.lines_with_range_highlighted(start, end)
+}
+
+func (self *Readline) lines_with_range_highlighted(start, end Position) []string {
+	ans := make([]string, len(self.input_state.lines))
+	copy(ans, self.input_state.lines)
+	for y := start.Y; y <= end.Y; y++ {
+		line := ans[y]
+		s, e := 0, len(line)
+		if y == start.Y {
+			s = start.X
+		}
+		if y == end.Y {
+			e = end.X
+		}
+		ans[y] = line[:s] + self.fmt_ctx.Reverse(line[s:e]) + line[e:]
+	}
+	return ans
+}
@@ -0,0 +1,73 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"strings"
+
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+type suggestion struct {
+	// the text the suggestion was computed for
+	for_text string
+	// the text to be added to the end of for_text to accept the suggestion
+	text string
+}
+
+// Suggest the most recent command in the history that starts with text.
+// Returns the text to be added to complete the command or the empty string.
+func SuggestFromHistory(text string, history *History) string {
+	if text == "" || history == nil {
+		return ""
+	}
+	for i := len(history.items) - 1; i >= 0; i-- {
+		if cmd := history.items[i].Cmd; len(cmd) > len(text) && strings.HasPrefix(cmd, text) {
+			return cmd[len(text):]
+		}
+	}
+	return ""
+}
+
+func (self *Readline) cursor_at_end_of_text() bool {
+	last := len(self.input_state.lines) - 1
+	return self.input_state.cursor.Y == last && self.input_state.cursor.X == len(self.input_state.lines[last])
+}
+
+// The text to be shown after the cursor as a suggestion, empty when there is
+// no suggestion. Suggestions are only shown when the cursor is at the end of
+// the text.
+func (self *Readline) current_suggestion() string {
+	if self.suggester == nil || self.history_search != nil || self.completions.current.results != nil || !self.cursor_at_end_of_text() {
+		return ""
+	}
+	text := self.all_text()
+	if self.suggestion.for_text != text {
+		self.suggestion = suggestion{for_text: text, text: self.suggester(text, self.history)}
+	}
+	return self.suggestion.text
+}
+
+// The suggestion as shown on screen, only its first line is shown
+func (self *Readline) formatted_suggestion() string {
+	s := self.current_suggestion()
+	if s == "" {
+		return ""
+	}
+	if first, _, found := utils.Cut(s, "\n"); found {
+		s = first + "…"
+	}
+	return self.fmt_ctx.Dim(s)
+}
+
+func (self *Readline) accept_suggestion() bool {
+	s := self.current_suggestion()
+	if s == "" {
+		return false
+	}
+	self.add_text(s)
+	return true
+}
@@ -0,0 +1,134 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"unicode"
+)
+
+var _ = fmt.Print
+
+// The maximum number of changes that can be undone
+const max_undo_steps = 256
+
+type undo_stack struct {
+	undo, redo []InputState
+	// > 0 while a change is being recorded, used to ignore nested changes
+	depth int
+	// the kind of the last recorded change, consecutive typing of a word
+	// and the whitespace after it is undone as a single step
+	last_kind undo_kind
+	// incremented when the text is reset, changes that span a reset are not
+	// recorded
+	generation int
+}
+
+type undo_kind int
+
+const (
+	undo_edit undo_kind = iota
+	undo_typed_word_char
+	undo_typed_space
+)
+
+func (self *undo_stack) clear() {
+	self.undo, self.redo = nil, nil
+	self.last_kind = undo_edit
+	self.generation++
+}
+
+func (self *undo_stack) push(s InputState) {
+	if len(self.undo) >= max_undo_steps {
+		self.undo = self.undo[len(self.undo)-max_undo_steps+1:]
+	}
+	self.undo = append(self.undo, s)
+	self.redo = nil
+}
+
+func lines_equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, x := range a {
+		if x != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The kind of change made by adding text, multi-character text, such as
+// pastes, is always its own step
+func undo_kind_for_text(text string) undo_kind {
+	r := []rune(text)
+	switch {
+	case len(r) != 1:
+		return undo_edit
+	case unicode.IsSpace(r[0]):
+		return undo_typed_space
+	}
+	return undo_typed_word_char
+}
+
+func (self undo_kind) continues(prev undo_kind) bool {
+	return self != undo_edit && prev != undo_edit && !(prev == undo_typed_space && self == undo_typed_word_char)
+}
+
+// Run f recording any change it makes to the text so that it can be undone
+func (self *Readline) with_undo(kind undo_kind, f func() error) error {
+	u := &self.undo_stack
+	if u.depth > 0 || self.history_search != nil {
+		return f()
+	}
+	before := self.input_state.copy()
+	generation := u.generation
+	u.depth++
+	defer func() { u.depth-- }()
+	err := f()
+	if u.generation != generation {
+		return err
+	}
+	if lines_equal(before.lines, self.input_state.lines) {
+		if before.cursor != self.input_state.cursor {
+			u.last_kind = undo_edit
+		}
+		return err
+	}
+	if kind.continues(u.last_kind) && len(u.undo) > 0 {
+		u.redo = nil
+	} else {
+		u.push(before)
+	}
+	u.last_kind = kind
+	return err
+}
+
+func (self *Readline) swap_undo_state(from, to *[]InputState) bool {
+	if len(*from) == 0 {
+		return false
+	}
+	*to = append(*to, self.input_state.copy())
+	self.input_state = (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	self.undo_stack.last_kind = undo_edit
+	return true
+}
+
+func (self *Readline) undo(repeat_count uint) bool {
+	u := &self.undo_stack
+	done := false
+	for ; repeat_count > 0 && self.swap_undo_state(&u.undo, &u.redo); repeat_count-- {
+		done = true
+	}
+	return done
+}
+
+func (self *Readline) redo(repeat_count uint) bool {
+	u := &self.undo_stack
+	done := false
+	for ; repeat_count > 0 && self.swap_undo_state(&u.redo, &u.undo); repeat_count-- {
+		done = true
+	}
+	return done
+}
@@ -0,0 +1,734 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+)
+
+var _ = fmt.Print
+
+const (
+	EmacsEditingMode = "emacs"
+	ViEditingMode    = "vi"
+)
+
+// The environment variable used to choose the editing mode when it is not
+// specified in RlInit
+const EditingModeEnvVar = "KITTY_READLINE_EDITING_MODE"
+
+func editing_mode(mode string) string {
+	if mode == "" {
+		mode = os.Getenv(EditingModeEnvVar)
+	}
+	if mode == ViEditingMode {
+		return ViEditingMode
+	}
+	return EmacsEditingMode
+}
+
+type vi_mode int
+
+const (
+	vi_insert_mode vi_mode = iota
+	vi_normal_mode
+)
+
+type vi_register struct {
+	text     string
+	linewise bool
+}
+
+type vi_state struct {
+	mode      vi_mode
+	pending   []rune
+	registers map[rune]vi_register
+	last_find struct{ key, ch rune }
+}
+
+type vi_command struct {
+	register     rune
+	count        int
+	operator     rune
+	motion_count int
+	key, arg     rune
+}
+
+const vi_motions = "hl0^$wWbBeEfFtT;,jk"
+const vi_operators = "dcy"
+const vi_commands = "iaIAoOxXsSDCYpPr~uv"
+
+// Parse the keys typed so far in normal mode, which have the form:
+// ["register][count](operator[count]motion|operator operator|motion|command)
+func parse_vi_command(keys []rune) (cmd vi_command, complete, valid bool) {
+	i, n := 0, len(keys)
+	parse_count := func() (ans int) {
+		for i < n && '0' <= keys[i] && keys[i] <= '9' && (ans > 0 || keys[i] != '0') {
+			ans = ans*10 + int(keys[i]-'0')
+			i++
+		}
+		return
+	}
+	if i < n && keys[i] == '"' {
+		if i+1 >= n {
+			return cmd, false, true
+		}
+		cmd.register = keys[i+1]
+		if !unicode.IsLetter(cmd.register) && cmd.register != '"' {
+			return cmd, false, false
+		}
+		i += 2
+	}
+	cmd.count = parse_count()
+	if i >= n {
+		return cmd, false, true
+	}
+	if strings.ContainsRune(vi_operators, keys[i]) {
+		cmd.operator = keys[i]
+		i++
+		cmd.motion_count = parse_count()
+		if i >= n {
+			return cmd, false, true
+		}
+	}
+	cmd.key = keys[i]
+	i++
+	switch {
+	case cmd.operator != 0 && cmd.key == cmd.operator:
+	case strings.ContainsRune(vi_motions, cmd.key):
+	case cmd.operator == 0 && strings.ContainsRune(vi_commands, cmd.key):
+	default:
+		return cmd, false, false
+	}
+	if strings.ContainsRune("fFtTr", cmd.key) {
+		if i >= n {
+			return cmd, false, true
+		}
+		cmd.arg = keys[i]
+		i++
+	}
+	return cmd, i == n, i == n
+}
+
+func (self *vi_command) total_count() int {
+	return utils.Max(1, self.count) * utils.Max(1, self.motion_count)
+}
+
+// Character classes used for word motions
+func vi_char_class(r rune, big_word bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big_word || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+func rune_at(text string, off int) (rune, int) {
+	if off >= len(text) {
+		return 0, 0
+	}
+	return utf8.DecodeRuneInString(text[off:])
+}
+
+func rune_before(text string, off int) (rune, int) {
+	if off <= 0 {
+		return 0, 0
+	}
+	return utf8.DecodeLastRuneInString(text[:off])
+}
+
+func vi_line_start(text string, off int) int {
+	return strings.LastIndexByte(text[:off], '\n') + 1
+}
+
+func vi_line_end(text string, off int) int {
+	if idx := strings.IndexByte(text[off:], '\n'); idx > -1 {
+		return off + idx
+	}
+	return len(text)
+}
+
+func vi_first_non_blank(text string, off int) int {
+	i, end := vi_line_start(text, off), vi_line_end(text, off)
+	for i < end {
+		r, sz := rune_at(text, i)
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += sz
+	}
+	return i
+}
+
+func vi_next_word_start(text string, off int, big_word bool) int {
+	r, sz := rune_at(text, off)
+	if sz == 0 {
+		return off
+	}
+	if c := vi_char_class(r, big_word); c != 0 {
+		for sz > 0 && vi_char_class(r, big_word) == c {
+			off += sz
+			r, sz = rune_at(text, off)
+		}
+	}
+	for sz > 0 && vi_char_class(r, big_word) == 0 {
+		off += sz
+		r, sz = rune_at(text, off)
+	}
+	return off
+}
+
+func vi_next_word_end(text string, off int, big_word bool) int {
+	_, sz := rune_at(text, off)
+	i := off + sz
+	r, sz := rune_at(text, i)
+	for sz > 0 && vi_char_class(r, big_word) == 0 {
+		i += sz
+		r, sz = rune_at(text, i)
+	}
+	if sz == 0 {
+		return off
+	}
+	c := vi_char_class(r, big_word)
+	for {
+		nr, nsz := rune_at(text, i+sz)
+		if nsz == 0 || vi_char_class(nr, big_word) != c {
+			break
+		}
+		i += sz
+		sz = nsz
+	}
+	return i
+}
+
+func vi_prev_word_start(text string, off int, big_word bool) int {
+	r, sz := rune_before(text, off)
+	for sz > 0 && vi_char_class(r, big_word) == 0 {
+		off -= sz
+		r, sz = rune_before(text, off)
+	}
+	if sz == 0 {
+		return off
+	}
+	c := vi_char_class(r, big_word)
+	for sz > 0 && vi_char_class(r, big_word) == c {
+		off -= sz
+		r, sz = rune_before(text, off)
+	}
+	return off
+}
+
+// Find the count'th occurrence of ch on the current line, returns -1 if not found
+func vi_find_in_line(text string, off int, ch rune, count int, forward bool) int {
+	ls, le := vi_line_start(text, off), vi_line_end(text, off)
+	for ; count > 0; count-- {
+		found := -1
+		if forward {
+			_, sz := rune_at(text, off)
+			if off+sz > le {
+				return -1
+			}
+			if idx := strings.IndexRune(text[off+sz:le], ch); idx > -1 {
+				found = off + sz + idx
+			}
+		} else if idx := strings.LastIndex(text[ls:off], string(ch)); idx > -1 {
+			found = ls + idx
+		}
+		if found < 0 {
+			return -1
+		}
+		off = found
+	}
+	return off
+}
+
+func (self *Readline) cursor_offset() (ans int) {
+	for i := 0; i < self.input_state.cursor.Y; i++ {
+		ans += len(self.input_state.lines[i]) + 1
+	}
+	return ans + self.input_state.cursor.X
+}
+
+func (self *Readline) position_for_offset(off int) Position {
+	for y, line := range self.input_state.lines {
+		if off <= len(line) {
+			return Position{X: off, Y: y}
+		}
+		off -= len(line) + 1
+	}
+	last := len(self.input_state.lines) - 1
+	return Position{X: len(self.input_state.lines[last]), Y: last}
+}
+
+func (self *Readline) set_cursor_offset(off int) {
+	self.input_state.cursor = self.position_for_offset(off)
+}
+
+// In normal mode the cursor is always on a character, never after the end of the line
+func (self *Readline) vi_clamp_cursor() {
+	line := self.input_state.lines[self.input_state.cursor.Y]
+	if self.input_state.cursor.X > 0 && self.input_state.cursor.X >= len(line) {
+		_, sz := utf8.DecodeLastRuneInString(line)
+		self.input_state.cursor.X = len(line) - sz
+	}
+}
+
+func (self *Readline) vi_set_mode(mode vi_mode) {
+	if self.vi == nil {
+		return
+	}
+	if mode == vi_normal_mode && self.vi.mode == vi_insert_mode {
+		self.move_cursor_left(1, false)
+	}
+	self.vi.mode = mode
+	self.vi.pending = nil
+	if mode == vi_normal_mode {
+		self.vi_clamp_cursor()
+	}
+	self.update_cursor_shape()
+}
+
+func (self *Readline) update_cursor_shape() {
+	if self.vi != nil && self.vi.mode == vi_normal_mode {
+		self.loop.SetCursorShape(loop.BLOCK_CURSOR, true)
+	} else {
+		self.loop.SetCursorShape(loop.BAR_CURSOR, true)
+	}
+}
+
+func (self *Readline) vi_get_register(name rune) vi_register {
+	if name == 0 {
+		name = '"'
+	}
+	return self.vi.registers[unicode.ToLower(name)]
+}
+
+func (self *Readline) vi_set_register(name rune, r vi_register) {
+	if unicode.IsUpper(name) {
+		name = unicode.ToLower(name)
+		if existing, found := self.vi.registers[name]; found {
+			if existing.linewise || r.linewise {
+				r.text = existing.text + "\n" + r.text
+				r.linewise = true
+			} else {
+				r.text = existing.text + r.text
+			}
+		}
+	}
+	if name != 0 && name != '"' {
+		self.vi.registers[name] = r
+	}
+	self.vi.registers['"'] = r
+}
+
+// Return the target offset of a motion and whether it is inclusive or linewise
+func (self *Readline) vi_motion(text string, off int, cmd *vi_command) (target int, inclusive, linewise, ok bool) {
+	count := cmd.total_count()
+	for_operator := cmd.operator != 0
+	target = off
+	key := cmd.key
+	switch key {
+	case 'h':
+		ls := vi_line_start(text, off)
+		for i := 0; i < count && target > ls; i++ {
+			_, sz := rune_before(text, target)
+			target -= sz
+		}
+	case 'l':
+		le := vi_line_end(text, off)
+		for i := 0; i < count && target < le; i++ {
+			_, sz := rune_at(text, target)
+			target += sz
+		}
+		if !for_operator && target >= le {
+			_, sz := rune_before(text, le)
+			target = utils.Max(vi_line_start(text, off), le-sz)
+		}
+	case '0':
+		target = vi_line_start(text, off)
+	case '^':
+		target = vi_first_non_blank(text, off)
+	case '$':
+		target = vi_line_end(text, off)
+		if !for_operator {
+			_, sz := rune_before(text, target)
+			target = utils.Max(vi_line_start(text, off), target-sz)
+		}
+	case 'w', 'W':
+		if cmd.operator == 'c' {
+			if r, _ := rune_at(text, off); !unicode.IsSpace(r) {
+				// cw behaves like ce
+				cmd.key += 'e' - 'w'
+				return self.vi_motion(text, off, cmd)
+			}
+		}
+		for i := 0; i < count; i++ {
+			target = vi_next_word_start(text, target, key == 'W')
+		}
+		if for_operator {
+			// operators do not extend past the end of the line the last word is on
+			if idx := strings.LastIndexByte(text[off:target], '\n'); idx > -1 {
+				target = off + idx
+			}
+		}
+	case 'e', 'E':
+		for i := 0; i < count; i++ {
+			target = vi_next_word_end(text, target, key == 'E')
+		}
+		inclusive = true
+	case 'b', 'B':
+		for i := 0; i < count; i++ {
+			target = vi_prev_word_start(text, target, key == 'B')
+		}
+	case 'f', 'F', 't', 'T', ';', ',':
+		ch := cmd.arg
+		if key == ';' || key == ',' {
+			if self.vi.last_find.key == 0 {
+				return
+			}
+			key, ch = self.vi.last_find.key, self.vi.last_find.ch
+			if cmd.key == ',' {
+				key = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[key]
+			}
+		} else {
+			self.vi.last_find.key, self.vi.last_find.ch = key, ch
+		}
+		forward := key == 'f' || key == 't'
+		start := off
+		if cmd.key == ';' || cmd.key == ',' {
+			// when repeating t and T skip the character next to the cursor so that the cursor moves
+			if r, sz := rune_at(text, off); key == 't' && sz > 0 && r != '\n' {
+				start += sz
+			} else if _, sz := rune_before(text, off); key == 'T' && off > vi_line_start(text, off) {
+				start -= sz
+			}
+		}
+		if target = vi_find_in_line(text, start, ch, count, forward); target < 0 {
+			return off, false, false, false
+		}
+		switch key {
+		case 't':
+			_, sz := rune_before(text, target)
+			target -= sz
+		case 'T':
+			_, sz := rune_at(text, target)
+			target += sz
+		}
+		inclusive = forward
+	case 'j', 'k':
+		linewise = true
+		for i := 0; i < count; i++ {
+			if key == 'j' {
+				le := vi_line_end(text, target)
+				if le >= len(text) {
+					break
+				}
+				target = le + 1
+			} else {
+				ls := vi_line_start(text, target)
+				if ls == 0 {
+					break
+				}
+				target = ls - 1
+			}
+		}
+	default:
+		return
+	}
+	return target, inclusive, linewise, target != off || linewise || (for_operator && inclusive)
+}
+
+func (self *Readline) vi_apply_operator(op rune, register rune, start, end int, linewise bool) {
+	text := self.all_text()
+	if end < start {
+		start, end = end, start
+	}
+	if linewise {
+		start, end = vi_line_start(text, start), vi_line_end(text, end)
+	}
+	self.vi_set_register(register, vi_register{text: text[start:end], linewise: linewise})
+	if op != 'y' {
+		self.kill_ring.add_new_item(text[start:end])
+	}
+	if linewise && op == 'd' {
+		// remove the line break as well
+		if end < len(text) {
+			end++
+		} else if start > 0 {
+			start--
+		}
+	}
+	switch op {
+	case 'y':
+		if !linewise {
+			self.set_cursor_offset(start)
+		}
+	case 'd', 'c':
+		self.erase_between(self.position_for_offset(start), self.position_for_offset(end))
+		self.set_cursor_offset(start)
+		if linewise && op == 'd' {
+			text = self.all_text()
+			self.set_cursor_offset(vi_first_non_blank(text, utils.Min(start, len(text))))
+		}
+	}
+	if op == 'c' {
+		self.vi_set_mode(vi_insert_mode)
+	} else {
+		self.vi_clamp_cursor()
+	}
+}
+
+func (self *Readline) vi_paste(register rune, count int, after bool) bool {
+	r := self.vi_get_register(register)
+	if r.text == "" {
+		return false
+	}
+	text := strings.Repeat(r.text, count)
+	if r.linewise {
+		text = strings.Repeat(r.text+"\n", count)
+		text = text[:len(text)-1]
+		if after {
+			self.move_to_end_of_line()
+			self.add_text("\n")
+			start := self.input_state.cursor
+			self.add_text(text)
+			self.input_state.cursor = start
+		} else {
+			self.move_to_start_of_line()
+			start := self.input_state.cursor
+			self.add_text(text + "\n")
+			self.input_state.cursor = start
+		}
+		return true
+	}
+	if after && self.input_state.lines[self.input_state.cursor.Y] != "" {
+		self.move_cursor_right(1, false)
+	}
+	self.add_text(text)
+	self.move_cursor_left(1, false)
+	return true
+}
+
+func (self *Readline) vi_replace_chars(ch rune, count int) bool {
+	line := self.input_state.lines[self.input_state.cursor.Y]
+	x := self.input_state.cursor.X
+	end := x
+	for i := 0; i < count; i++ {
+		_, sz := rune_at(line, end)
+		if sz == 0 {
+			return false
+		}
+		end += sz
+	}
+	replacement := strings.Repeat(string(ch), count)
+	self.input_state.lines[self.input_state.cursor.Y] = line[:x] + replacement + line[end:]
+	self.input_state.cursor.X = x + len(replacement) - utf8.RuneLen(ch)
+	return true
+}
+
+func (self *Readline) vi_toggle_case(count int) bool {
+	line := self.input_state.lines[self.input_state.cursor.Y]
+	x := self.input_state.cursor.X
+	if x >= len(line) {
+		return false
+	}
+	buf := strings.Builder{}
+	end := x
+	for i := 0; i < count; i++ {
+		r, sz := rune_at(line, end)
+		if sz == 0 {
+			break
+		}
+		if unicode.IsUpper(r) {
+			r = unicode.ToLower(r)
+		} else {
+			r = unicode.ToUpper(r)
+		}
+		buf.WriteRune(r)
+		end += sz
+	}
+	self.input_state.lines[self.input_state.cursor.Y] = line[:x] + buf.String() + line[end:]
+	self.input_state.cursor.X = x + buf.Len()
+	self.vi_clamp_cursor()
+	return true
+}
+
+func (self *Readline) vi_execute(cmd vi_command) error {
+	count := cmd.total_count()
+	text := self.all_text()
+	off := self.cursor_offset()
+	if cmd.operator != 0 {
+		if cmd.key == cmd.operator {
+			// dd, cc and yy operate on count lines
+			end := off
+			for i := 1; i < count; i++ {
+				le := vi_line_end(text, end)
+				if le >= len(text) {
+					break
+				}
+				end = le + 1
+			}
+			self.vi_apply_operator(cmd.operator, cmd.register, off, end, true)
+			return nil
+		}
+		target, inclusive, linewise, ok := self.vi_motion(text, off, &cmd)
+		if !ok {
+			return ErrCouldNotPerformAction
+		}
+		if inclusive {
+			_, sz := rune_at(text, target)
+			target += sz
+		}
+		self.vi_apply_operator(cmd.operator, cmd.register, off, target, linewise)
+		return nil
+	}
+	switch cmd.key {
+	case 'j':
+		return self.perform_action(ActionHistoryNextOrCursorDown, uint(count))
+	case 'k':
+		return self.perform_action(ActionHistoryPreviousOrCursorUp, uint(count))
+	case 'i':
+		self.vi_set_mode(vi_insert_mode)
+	case 'a':
+		self.vi_set_mode(vi_insert_mode)
+		self.move_cursor_right(1, false)
+	case 'I':
+		self.set_cursor_offset(vi_first_non_blank(text, off))
+		self.vi_set_mode(vi_insert_mode)
+	case 'A':
+		self.move_to_end_of_line()
+		self.vi_set_mode(vi_insert_mode)
+	case 'o':
+		self.move_to_end_of_line()
+		self.add_text("\n")
+		self.vi_set_mode(vi_insert_mode)
+	case 'O':
+		self.move_to_start_of_line()
+		self.add_text("\n")
+		self.input_state.cursor = Position{Y: self.input_state.cursor.Y - 1}
+		self.vi_set_mode(vi_insert_mode)
+	case 'x', 'X', 's':
+		aliases := map[rune]vi_command{'x': {operator: 'd', key: 'l'}, 'X': {operator: 'd', key: 'h'}, 's': {operator: 'c', key: 'l'}}
+		q := aliases[cmd.key]
+		q.register, q.count = cmd.register, count
+		if cmd.key == 's' && vi_line_end(text, off) == off {
+			self.vi_set_mode(vi_insert_mode)
+			return nil
+		}
+		return self.vi_execute(q)
+	case 'S':
+		return self.vi_execute(vi_command{register: cmd.register, count: count, operator: 'c', key: 'c'})
+	case 'D':
+		return self.vi_execute(vi_command{register: cmd.register, operator: 'd', key: '$'})
+	case 'C':
+		return self.vi_execute(vi_command{register: cmd.register, operator: 'c', key: '$'})
+	case 'Y':
+		return self.vi_execute(vi_command{register: cmd.register, count: count, operator: 'y', key: 'y'})
+	case 'p', 'P':
+		if !self.vi_paste(cmd.register, count, cmd.key == 'p') {
+			return ErrCouldNotPerformAction
+		}
+	case 'r':
+		if !self.vi_replace_chars(cmd.arg, count) {
+			return ErrCouldNotPerformAction
+		}
+	case '~':
+		if !self.vi_toggle_case(count) {
+			return ErrCouldNotPerformAction
+		}
+	case 'u':
+		if !self.undo(uint(count)) {
+			return ErrCouldNotPerformAction
+		}
+		self.vi_clamp_cursor()
+	case 'v':
+		if !self.edit_in_external_editor() {
+			return ErrCouldNotPerformAction
+		}
+		self.vi_clamp_cursor()
+	default:
+		target, _, _, ok := self.vi_motion(text, off, &cmd)
+		if !ok {
+			return ErrCouldNotPerformAction
+		}
+		self.set_cursor_offset(target)
+		self.vi_clamp_cursor()
+	}
+	return nil
+}
+
+// Handle text typed in normal mode, where it is interpreted as commands
+func (self *Readline) vi_handle_text(text string) error {
+	var err error
+	for i, r := range text {
+		if self.vi.mode == vi_insert_mode {
+			// a command switched to insert mode, the rest of the text is inserted
+			self.with_undo(undo_edit, func() error { self.add_text(text[i:]); return nil })
+			break
+		}
+		self.vi.pending = append(self.vi.pending, r)
+		cmd, complete, valid := parse_vi_command(self.vi.pending)
+		if !valid {
+			self.vi.pending = nil
+			err = ErrCouldNotPerformAction
+			continue
+		}
+		if complete {
+			self.vi.pending = nil
+			var e error
+			if cmd.key == 'u' && cmd.operator == 0 {
+				// undoing must not itself be recorded as a change
+				e = self.vi_execute(cmd)
+			} else {
+				e = self.with_undo(undo_edit, func() error { return self.vi_execute(cmd) })
+			}
+			if e != nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Handle key events that have a special meaning in vi mode, returns true if
+// the event was handled
+func (self *Readline) vi_handle_key_event(event *loop.KeyEvent) (bool, error) {
+	if self.vi == nil || self.history_search != nil {
+		return false, nil
+	}
+	if event.MatchesPressOrRepeat("escape") {
+		event.Handled = true
+		if self.vi.mode == vi_insert_mode {
+			self.vi_set_mode(vi_normal_mode)
+			return true, nil
+		}
+		if len(self.vi.pending) == 0 {
+			return true, ErrCouldNotPerformAction
+		}
+		self.vi.pending = nil
+		return true, nil
+	}
+	if self.vi.mode == vi_normal_mode && event.MatchesPressOrRepeat("ctrl+r") {
+		event.Handled = true
+		self.vi.pending = nil
+		if !self.redo(1) {
+			return true, ErrCouldNotPerformAction
+		}
+		self.vi_clamp_cursor()
+		return true, nil
+	}
+	if self.vi.mode == vi_normal_mode && event.Text == "" {
+		// keys other than text cancel any partially typed command
+		self.vi.pending = nil
+	}
+	return false, nil
+}
@@ -0,0 +1,94 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package readline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var _ = fmt.Print
+
+// What the actions that move by or kill words consider to be a word
+type WordStyle uint8
+
+const (
+	// Runs of letters and digits, so /long/path/components and
+	// --long-flag-names are multiple words. The default.
+	ALPHANUMERIC_WORDS WordStyle = iota
+	// Separated by whitespace and the shell operators ;&|<>()
+	SHELL_WORDS
+	// Separated by whitespace only
+	WHITESPACE_WORDS
+	// Separated by whitespace and /, so path components are separate words
+	// but --long-flag-names is a single word
+	PATH_COMPONENT_WORDS
+
+	num_of_word_styles
+)
+
+func (self WordStyle) String() string {
+	switch self {
+	case ALPHANUMERIC_WORDS:
+		return "alphanumeric"
+	case SHELL_WORDS:
+		return "shell"
+	case WHITESPACE_WORDS:
+		return "whitespace"
+	case PATH_COMPONENT_WORDS:
+		return "path"
+	default:
+		return fmt.Sprintf("WordStyle:%d", int(self))
+	}
+}
+
+func (self WordStyle) next() WordStyle {
+	return (self + 1) % num_of_word_styles
+}
+
+// Returns a function that reports whether a cell of text is part of a word
+func (self WordStyle) is_part_of_word() func(string) bool {
+	switch self {
+	case SHELL_WORDS:
+		return func(text string) bool {
+			return has_no_space_chars(text) && !strings.ContainsAny(text, ";&|<>()")
+		}
+	case WHITESPACE_WORDS:
+		return has_no_space_chars
+	case PATH_COMPONENT_WORDS:
+		return func(text string) bool {
+			return has_no_space_chars(text) && !strings.ContainsRune(text, '/')
+		}
+	default:
+		return has_word_chars
+	}
+}
+
+func has_word_chars(text string) bool {
+	for _, ch := range text {
+		if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+func has_no_space_chars(text string) bool {
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// The current definition of a word used by the actions that move by or kill
+// words
+func (self *Readline) WordStyle() WordStyle {
+	return self.word_style
+}
+
+func (self *Readline) SetWordStyle(ws WordStyle) {
+	self.word_style = ws % num_of_word_styles
+}
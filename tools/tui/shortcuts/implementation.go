@@ -4,6 +4,8 @@ package shortcuts
 
 import (
 	"fmt"
+
+	"kitty/tools/tui/loop"
 )
 
 var _ = fmt.Print
@@ -38,6 +40,8 @@ func (self *ShortcutMap[T]) add(ac T, keys []string) (conflict T) {
 	sm := self
 	last := len(keys) - 1
 	for i, key := range keys {
+		// normalize so that different spellings of a key replace each other
+		key = loop.ParseShortcut(key).String()
 		if i == last {
 			if c, found := sm.leaves[key]; found {
 				conflict = c
@@ -0,0 +1,121 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"kitty/tools/utils/style"
+)
+
+var _ = fmt.Print
+
+// A list of colors, such as "green" or "#ff0000", used for increasing values.
+// A value that is fraction f of the maximum gets the color at index
+// f * (len - 1), rounded down.
+type ColorRamp []string
+
+// Green for low values, yellow for medium and red for high values, useful for
+// resource usage
+var TrafficLightRamp = ColorRamp{"green", "yellow", "red"}
+
+func (self ColorRamp) color_for(frac float64) string {
+	if len(self) == 0 {
+		return ""
+	}
+	frac = math.Max(0, math.Min(frac, 1))
+	return self[int(frac*float64(len(self)-1))]
+}
+
+// Collects text in runs of the same color, to avoid emitting escape codes
+// for every cell
+type colored_text struct {
+	fmt_ctx       style.Context
+	ans, run      strings.Builder
+	current_color string
+}
+
+func (self *colored_text) flush() {
+	if self.run.Len() == 0 {
+		return
+	}
+	if self.current_color == "" {
+		self.ans.WriteString(self.run.String())
+	} else {
+		self.ans.WriteString(self.fmt_ctx.SprintFunc("fg=" + self.current_color)(self.run.String()))
+	}
+	self.run.Reset()
+}
+
+func (self *colored_text) write(text, color string) {
+	if color != self.current_color {
+		self.flush()
+		self.current_color = color
+	}
+	self.run.WriteString(text)
+}
+
+func (self *colored_text) String() string {
+	self.flush()
+	return self.ans.String()
+}
+
+func new_colored_text() *colored_text {
+	return &colored_text{fmt_ctx: style.Context{AllowEscapeCodes: true}}
+}
+
+var sparkline_chars = []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// Render values as a line of block characters, one cell per value, scaled so
+// that the largest value is a full block and zero is the lowest block.
+// Negative values are treated as zero. If width is greater than zero only the
+// last width values are shown and the line is padded on the left with spaces
+// to width cells. Each cell is colored from ramp based on its value, use an
+// empty ramp for no colors.
+func RenderSparkline(values []float64, width int, ramp ColorRamp) string {
+	if width > 0 && len(values) > width {
+		values = values[len(values)-width:]
+	}
+	maximum := 0.0
+	for _, v := range values {
+		maximum = math.Max(maximum, v)
+	}
+	ans := new_colored_text()
+	if width > len(values) {
+		ans.write(strings.Repeat(" ", width-len(values)), "")
+	}
+	for _, v := range values {
+		frac := 0.0
+		if maximum > 0 {
+			frac = math.Max(0, v) / maximum
+		}
+		idx := int(math.Round(frac * float64(len(sparkline_chars)-1)))
+		ans.write(sparkline_chars[idx], ramp.color_for(frac))
+	}
+	return ans.String()
+}
+
+var bar_chars = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// Render a horizontal bar width cells wide, filled in proportion to frac,
+// with a resolution of one eighth of a cell. The filled part is colored from
+// ramp based on frac and the rest of the bar is blank.
+func RenderBar(frac float64, width int, ramp ColorRamp) string {
+	if width <= 0 {
+		return ""
+	}
+	frac = math.Max(0, math.Min(frac, 1))
+	eighths := int(math.Round(frac * float64(width*8)))
+	full, partial := eighths/8, eighths%8
+	filled := RepeatChar("█", full) + bar_chars[partial]
+	used := full
+	if partial > 0 {
+		used++
+	}
+	ans := new_colored_text()
+	ans.write(filled, ramp.color_for(frac))
+	ans.write(strings.Repeat(" ", width-used), "")
+	return ans.String()
+}
@@ -0,0 +1,46 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"kitty/tools/wcswidth"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestRenderSparkline(t *testing.T) {
+	test := func(values []float64, width int, expected string) {
+		if actual := RenderSparkline(values, width, nil); actual != expected {
+			t.Fatalf("Sparkline for %v with width %d: %#v != %#v", values, width, actual, expected)
+		}
+		colored := RenderSparkline(values, width, TrafficLightRamp)
+		if a, e := wcswidth.Stringwidth(colored), wcswidth.Stringwidth(expected); a != e {
+			t.Fatalf("Colored sparkline for %v has width %d != %d", values, a, e)
+		}
+	}
+	test([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 0, "▁▂▃▄▅▆▇█")
+	test([]float64{0, 0}, 0, "▁▁")
+	test([]float64{-1, 2}, 0, "▁█")
+	test([]float64{1, 2}, 4, "  ▅█")
+	test([]float64{7, 0, 7}, 2, "▁█")
+	test(nil, 0, "")
+}
+
+func TestRenderBar(t *testing.T) {
+	test := func(frac float64, width int, expected string) {
+		if actual := RenderBar(frac, width, nil); actual != expected {
+			t.Fatalf("Bar for %v with width %d: %#v != %#v", frac, width, actual, expected)
+		}
+		colored := RenderBar(frac, width, TrafficLightRamp)
+		if a := wcswidth.Stringwidth(colored); a != width {
+			t.Fatalf("Colored bar for %v has width %d != %d", frac, a, width)
+		}
+	}
+	test(0, 3, "   ")
+	test(0.5, 3, "█▌ ")
+	test(1, 3, "███")
+	test(2, 2, "██")
+	test(0.25, 1, "▎")
+}
@@ -0,0 +1,229 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kitty/tools/cli/markup"
+	"kitty/tools/tui/loop"
+	"kitty/tools/utils"
+	"kitty/tools/wcswidth"
+)
+
+var _ = fmt.Print
+
+const tab_bar_left_overflow = "◀"
+const tab_bar_right_overflow = "▶"
+
+// Used instead of tab indices for the parts of the tab bar that are not tabs
+const (
+	tab_bar_left_marker = -1 - iota
+	tab_bar_right_marker
+	tab_bar_separator
+)
+
+type tab_extent struct {
+	idx        int
+	start, end int
+}
+
+// A single line header showing a list of sections, such as files, categories
+// or the components of a path, one of which is active. Use it as a tab bar or,
+// with a separator such as " › ", as breadcrumbs. When the tabs do not fit,
+// only the ones around the active tab are shown, with markers indicating that
+// there are more on either side.
+type TabBar struct {
+	Tabs   []string
+	Active int
+	// Shown between tabs, the tabs are padded with spaces instead when empty
+	Separator string
+
+	fmt_ctx *markup.Context
+	// the cells occupied by the tabs when last rendered, for mouse clicks
+	extents []tab_extent
+}
+
+func NewTabBar(tabs ...string) *TabBar {
+	return &TabBar{Tabs: tabs, fmt_ctx: markup.New(true)}
+}
+
+func (self *TabBar) label(i int) string {
+	if self.Separator == "" {
+		return " " + self.Tabs[i] + " "
+	}
+	return self.Tabs[i]
+}
+
+// Make the specified tab active, returns false if it is invalid or already active
+func (self *TabBar) SetActive(idx int) bool {
+	if idx < 0 || idx >= len(self.Tabs) || idx == self.Active {
+		return false
+	}
+	self.Active = idx
+	return true
+}
+
+// Activate the tab delta positions from the active tab, wrapping around at
+// the ends. Returns false if the active tab did not change.
+func (self *TabBar) Next(delta int) bool {
+	if len(self.Tabs) == 0 {
+		return false
+	}
+	n := len(self.Tabs)
+	return self.SetActive(((self.Active+delta)%n + n) % n)
+}
+
+// Handle the standard keys for switching tabs: ctrl+tab and ctrl+page_down
+// for the next tab, ctrl+shift+tab and ctrl+page_up for the previous tab
+// and alt+1 to alt+9 for a specific tab. Returns true if the active tab
+// changed.
+func (self *TabBar) HandleKey(event *loop.KeyEvent) bool {
+	switch {
+	case event.MatchesPressOrRepeat("ctrl+tab") || event.MatchesPressOrRepeat("ctrl+page_down"):
+		event.Handled = true
+		return self.Next(1)
+	case event.MatchesPressOrRepeat("ctrl+shift+tab") || event.MatchesPressOrRepeat("ctrl+page_up"):
+		event.Handled = true
+		return self.Next(-1)
+	}
+	for i := 1; i <= 9; i++ {
+		if event.MatchesPressOrRepeat("alt+" + strconv.Itoa(i)) {
+			event.Handled = true
+			return self.SetActive(i - 1)
+		}
+	}
+	return false
+}
+
+// Handle a click at the specified cell on the line the tab bar was rendered
+// in. Clicking an overflow marker activates the nearest hidden tab on that
+// side. Returns true if the active tab changed.
+func (self *TabBar) HandleClick(x int) bool {
+	for _, e := range self.extents {
+		if e.start <= x && x < e.end {
+			switch e.idx {
+			case tab_bar_left_marker:
+				return self.SetActive(self.first_visible() - 1)
+			case tab_bar_right_marker:
+				return self.SetActive(self.last_visible() + 1)
+			default:
+				return self.SetActive(e.idx)
+			}
+		}
+	}
+	return false
+}
+
+func (self *TabBar) first_visible() int {
+	for _, e := range self.extents {
+		if e.idx >= 0 {
+			return e.idx
+		}
+	}
+	return self.Active
+}
+
+func (self *TabBar) last_visible() int {
+	ans := self.Active
+	for _, e := range self.extents {
+		if e.idx >= 0 {
+			ans = e.idx
+		}
+	}
+	return ans
+}
+
+// The number of cells needed to show the tabs [s, e) with their overflow markers
+func total_width(widths []int, sep_width, s, e int) int {
+	ans := (e - s - 1) * sep_width
+	for i := s; i < e; i++ {
+		ans += widths[i]
+	}
+	if s > 0 {
+		ans += wcswidth.Stringwidth(tab_bar_left_overflow)
+	}
+	if e < len(widths) {
+		ans += wcswidth.Stringwidth(tab_bar_right_overflow)
+	}
+	return ans
+}
+
+// The range of tabs [start, end) to show in width cells, always including
+// the active tab
+func (self *TabBar) visible_range(widths []int, sep_width, width int) (start, end int) {
+	total := func(s, e int) int { return total_width(widths, sep_width, s, e) }
+	start, end = self.Active, self.Active+1
+	for {
+		grew := false
+		// prefer showing the tabs after the active tab
+		if end < len(widths) && total(start, end+1) <= width {
+			end++
+			grew = true
+		}
+		if start > 0 && total(start-1, end) <= width {
+			start--
+			grew = true
+		}
+		if !grew {
+			return
+		}
+	}
+}
+
+// Render the tab bar as a single line at most width cells wide
+func (self *TabBar) Render(width int) string {
+	self.extents = self.extents[:0]
+	if len(self.Tabs) == 0 || width < 1 {
+		return ""
+	}
+	self.Active = utils.Max(0, utils.Min(self.Active, len(self.Tabs)-1))
+	widths := make([]int, len(self.Tabs))
+	for i := range self.Tabs {
+		widths[i] = wcswidth.Stringwidth(self.label(i))
+	}
+	sep_width := wcswidth.Stringwidth(self.Separator)
+	start, end := self.visible_range(widths, sep_width, width)
+	// when even the active tab does not fit, show only it, truncated
+	show_markers := total_width(widths, sep_width, start, end) <= width
+	var ans strings.Builder
+	x := 0
+	add := func(idx int, text string, formatter func(...any) string) {
+		w := wcswidth.Stringwidth(text)
+		if x+w > width {
+			// only happens when a single tab is too wide
+			if x >= width {
+				return
+			}
+			text = wcswidth.TruncateToVisualLength(text, width-x-1) + "…"
+			w = wcswidth.Stringwidth(text)
+		}
+		if formatter != nil {
+			text = formatter(text)
+		}
+		ans.WriteString(text)
+		if idx != tab_bar_separator {
+			self.extents = append(self.extents, tab_extent{idx: idx, start: x, end: x + w})
+		}
+		x += w
+	}
+	if start > 0 && show_markers {
+		add(tab_bar_left_marker, tab_bar_left_overflow, self.fmt_ctx.Dim)
+	}
+	for i := start; i < end; i++ {
+		if i > start && self.Separator != "" {
+			add(tab_bar_separator, self.Separator, self.fmt_ctx.Dim)
+		}
+		if i == self.Active {
+			add(i, self.label(i), self.fmt_ctx.Reverse)
+		} else {
+			add(i, self.label(i), nil)
+		}
+	}
+	if end < len(self.Tabs) && show_markers {
+		add(tab_bar_right_marker, tab_bar_right_overflow, self.fmt_ctx.Dim)
+	}
+	return ans.String()
+}
@@ -0,0 +1,58 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package tui
+
+import (
+	"fmt"
+	"kitty/tools/wcswidth"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestTabBar(t *testing.T) {
+	tb := NewTabBar("one", "two", "three", "four", "five")
+	tb.fmt_ctx.SetAllowEscapeCodes(false)
+
+	test := func(width int, expected string) {
+		actual := tb.Render(width)
+		if actual != expected {
+			t.Fatalf("Rendering at width %d with active tab %d failed.\nExpected: %#v\nActual:   %#v", width, tb.Active, expected, actual)
+		}
+		if w := wcswidth.Stringwidth(actual); w > width {
+			t.Fatalf("Rendered tab bar is %d cells wide, wider than %d: %#v", w, width, actual)
+		}
+	}
+	test(100, " one  two  three  four  five ")
+	test(12, " one  two ▶")
+	tb.Active = 4
+	test(13, "◀ four  five ")
+	tb.Active = 2
+	test(12, "◀ three ▶")
+	test(5, " thr…")
+
+	tb.Render(12)
+	if !tb.HandleClick(0) || tb.Active != 1 {
+		t.Fatalf("Clicking the left overflow marker did not activate the previous tab: %d", tb.Active)
+	}
+	tb.Render(12)
+	if !tb.HandleClick(1) || tb.Active != 0 {
+		t.Fatalf("Clicking a tab did not activate it: %d", tb.Active)
+	}
+	if !tb.Next(-1) || tb.Active != 4 {
+		t.Fatalf("Next() did not wrap around backwards: %d", tb.Active)
+	}
+	if !tb.Next(1) || tb.Active != 0 {
+		t.Fatalf("Next() did not wrap around forwards: %d", tb.Active)
+	}
+
+	tb = NewTabBar("a", "b", "c")
+	tb.fmt_ctx.SetAllowEscapeCodes(false)
+	tb.Separator = " › "
+	tb.Active = 2
+	test(100, "a › b › c")
+	if !strings.Contains(tb.Render(6), "c") {
+		t.Fatalf("The active tab is not visible")
+	}
+}
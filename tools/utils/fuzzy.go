@@ -0,0 +1,51 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var _ = fmt.Print
+
+// Score how well query matches text, the characters of query must appear in
+// text in order. Matches at the start of words and runs of consecutive
+// matching characters score higher. Returns -1 if there is no match.
+func FuzzyScore(query, text string) int {
+	score, _ := FuzzyMatch(query, text)
+	return score
+}
+
+// Same as FuzzyScore() but also returns the positions, in runes, of the
+// characters in text that matched
+func FuzzyMatch(query, text string) (score int, positions []int) {
+	if query == "" {
+		return 0, nil
+	}
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(text))
+	positions = make([]int, 0, len(q))
+	qi, prev_match := 0, -2
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev_match+1 {
+			score += 4
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) {
+			score += 3
+		}
+		prev_match = ti
+		positions = append(positions, ti)
+		qi++
+	}
+	if qi < len(q) {
+		return -1, nil
+	}
+	// prefer shorter matches, for example commands over their options
+	return score*100 - Min(len(t), 99), positions
+}
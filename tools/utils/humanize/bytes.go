@@ -3,6 +3,8 @@ package humanize
 import (
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 )
 
 // IEC Sizes.
@@ -59,3 +61,32 @@ func IBytes(s uint64) string {
 	sizes := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
 	return humanize_bytes(s, 1024, sizes, " ")
 }
+
+var byte_size_suffixes = map[string]uint64{
+	"": Byte, "b": Byte,
+	"k": KiByte, "kb": KByte, "kib": KiByte,
+	"m": MiByte, "mb": MByte, "mib": MiByte,
+	"g": GiByte, "gb": GByte, "gib": GiByte,
+	"t": TiByte, "tb": TByte, "tib": TiByte,
+}
+
+// ParseBytes parses a size such as 42, 10K, 10KiB or 10KB into a number of
+// bytes. Single letter suffixes are IEC sizes.
+// ParseBytes("1.5M") -> 1572864
+func ParseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	num, suffix := s, ""
+	if idx > -1 {
+		num, suffix = s[:idx], strings.ToLower(strings.TrimSpace(s[idx:]))
+	}
+	multiplier, found := byte_size_suffixes[suffix]
+	if !found {
+		return 0, fmt.Errorf("Unknown unit for size: %#v", s)
+	}
+	val, err := strconv.ParseFloat(num, 64)
+	if err != nil || val < 0 {
+		return 0, fmt.Errorf("Invalid size: %#v", s)
+	}
+	return uint64(val * float64(multiplier)), nil
+}
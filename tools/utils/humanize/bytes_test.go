@@ -0,0 +1,24 @@
+package humanize
+
+import (
+	"testing"
+)
+
+func TestParseBytes(t *testing.T) {
+	for q, expected := range map[string]uint64{
+		"0": 0, "42": 42, "42B": 42, "10K": 10 * KiByte, "10KiB": 10 * KiByte, "10kb": 10 * KByte, "1.5M": 3 * MiByte / 2, " 2 GB ": 2 * GByte, "1t": TiByte,
+	} {
+		actual, err := ParseBytes(q)
+		if err != nil {
+			t.Fatalf("Parsing %#v failed with error: %s", q, err)
+		}
+		if actual != expected {
+			t.Fatalf("Parsing %#v gave %d instead of %d", q, actual, expected)
+		}
+	}
+	for _, q := range []string{"", "K", "10X", "-1", "1.2.3M"} {
+		if _, err := ParseBytes(q); err == nil {
+			t.Fatalf("Parsing %#v did not fail", q)
+		}
+	}
+}
@@ -111,6 +111,16 @@ func Values[M ~map[K]V, K comparable, V any](m M) []V {
 	return r
 }
 
+func Filter[T any](s []T, f func(x T) bool) []T {
+	ans := make([]T, 0, len(s))
+	for _, x := range s {
+		if f(x) {
+			ans = append(ans, x)
+		}
+	}
+	return ans
+}
+
 func Memset[T any](dest []T, pattern ...T) []T {
 	if len(pattern) == 0 {
 		var zero T
@@ -3,6 +3,7 @@
 package utils
 
 import (
+	"fmt"
 	"io/fs"
 	"os"
 	"os/user"
@@ -56,8 +57,6 @@ func Abspath(path string) string {
 	return path
 }
 
-var config_dir string
-
 func KittyExe() (string, error) {
 	exe, err := os.Executable()
 	if err != nil {
@@ -67,61 +66,112 @@ func KittyExe() (string, error) {
 	return ans, unix.Access(ans, unix.X_OK)
 }
 
+// All code must use ConfigDir(), CacheDir() and RuntimeDir() to find these
+// directories, so that the overrides from the command line and environment
+// are honored everywhere. This is enforced by TestNoHardcodedDirs.
+
+var config_dir, cache_dir, runtime_dir string
+
+func env_dir(name string) string {
+	if q := os.Getenv(name); q != "" {
+		return Abspath(Expanduser(q))
+	}
+	return ""
+}
+
+// Use path as the kitty config directory, for this process and its children
+func SetConfigDir(path string) {
+	config_dir = Abspath(Expanduser(path))
+	os.Setenv("KITTY_CONFIG_DIRECTORY", config_dir)
+}
+
+// Use path as the kitty cache directory, for this process and its children
+func SetCacheDir(path string) {
+	cache_dir = Abspath(Expanduser(path))
+	os.Setenv("KITTY_CACHE_DIRECTORY", cache_dir)
+}
+
+// Use path as the kitty runtime directory, for this process and its children
+func SetRuntimeDir(path string) {
+	runtime_dir = Abspath(Expanduser(path))
+	os.Setenv("KITTY_RUNTIME_DIRECTORY", runtime_dir)
+}
+
+// The directory kitty.conf is in, found the same way as by kitty
 func ConfigDir() string {
 	if config_dir != "" {
 		return config_dir
 	}
-	if os.Getenv("KITTY_CONFIG_DIRECTORY") != "" {
-		config_dir = Abspath(Expanduser(os.Getenv("KITTY_CONFIG_DIRECTORY")))
-	} else {
-		var locations []string
-		if os.Getenv("XDG_CONFIG_HOME") != "" {
-			locations = append(locations, os.Getenv("XDG_CACHE_HOME"))
+	if config_dir = env_dir("KITTY_CONFIG_DIRECTORY"); config_dir != "" {
+		return config_dir
+	}
+	var locations []string
+	if q := env_dir("XDG_CONFIG_HOME"); q != "" {
+		locations = append(locations, q)
+	}
+	locations = append(locations, Expanduser("~/.config"))
+	if runtime.GOOS == "darwin" {
+		locations = append(locations, Expanduser("~/Library/Preferences"))
+	}
+	for _, loc := range filepath.SplitList(os.Getenv("XDG_CONFIG_DIRS")) {
+		if loc != "" {
+			locations = append(locations, Abspath(Expanduser(loc)))
 		}
-		locations = append(locations, Expanduser("~/.config"))
-		if runtime.GOOS == "darwin" {
-			locations = append(locations, Expanduser("~/Library/Preferences"))
+	}
+	for _, loc := range locations {
+		q := filepath.Join(loc, "kitty")
+		if _, err := os.Stat(filepath.Join(q, "kitty.conf")); err == nil && unix.Access(q, unix.W_OK) == nil {
+			config_dir = q
+			return config_dir
 		}
-		for _, loc := range locations {
-			if loc != "" {
-				q := filepath.Join(loc, "kitty")
-				if _, err := os.Stat(filepath.Join(q, "kitty.conf")); err == nil {
-					config_dir = q
-					break
+	}
+	config_dir = filepath.Join(locations[0], "kitty")
+	return config_dir
+}
+
+// The directory kitty stores cached data in, created if it does not exist
+func CacheDir() string {
+	if cache_dir == "" {
+		if cache_dir = env_dir("KITTY_CACHE_DIRECTORY"); cache_dir == "" {
+			if runtime.GOOS == "darwin" {
+				cache_dir = Expanduser("~/Library/Caches/kitty")
+			} else {
+				candidate := os.Getenv("XDG_CACHE_HOME")
+				if candidate == "" {
+					candidate = "~/.cache"
 				}
-			}
-		}
-		for _, loc := range locations {
-			if loc != "" {
-				config_dir = filepath.Join(loc, "kitty")
-				break
+				cache_dir = filepath.Join(Abspath(Expanduser(candidate)), "kitty")
 			}
 		}
 	}
-
-	return config_dir
+	os.MkdirAll(cache_dir, 0o755)
+	return cache_dir
 }
 
-var cache_dir string
-
-func CacheDir() string {
-	if cache_dir != "" {
-		return cache_dir
-	}
-	candidate := ""
-	if edir := os.Getenv("KITTY_CACHE_DIRECTORY"); edir != "" {
-		candidate = Abspath(Expanduser(edir))
-	} else if runtime.GOOS == "darwin" {
-		candidate = Expanduser("~/Library/Caches/kitty")
-	} else {
-		candidate = os.Getenv("XDG_CACHE_HOME")
-		if candidate == "" {
-			candidate = "~/.cache"
+// The directory for sockets and other files that must not outlive the
+// session, private to the user and created if it does not exist
+func RuntimeDir() string {
+	if runtime_dir == "" {
+		runtime_dir = env_dir("KITTY_RUNTIME_DIRECTORY")
+	}
+	if runtime_dir == "" && runtime.GOOS != "darwin" {
+		if runtime_dir = env_dir("XDG_RUNTIME_DIR"); runtime_dir == "" {
+			if q := fmt.Sprintf("/run/user/%d", os.Geteuid()); unix.Access(q, unix.R_OK|unix.W_OK|unix.X_OK) == nil {
+				runtime_dir = q
+			}
+		}
+	}
+	if runtime_dir == "" {
+		// kitty uses the per user temporary directory on macOS, which cannot
+		// be queried without cgo
+		runtime_dir = filepath.Join(CacheDir(), "run")
+	}
+	if err := os.MkdirAll(runtime_dir, 0o700); err == nil {
+		if st, err := os.Stat(runtime_dir); err == nil && st.Mode().Perm() != 0o700 {
+			os.Chmod(runtime_dir, 0o700)
 		}
-		candidate = filepath.Join(Expanduser(candidate), "kitty")
 	}
-	os.MkdirAll(candidate, 0o755)
-	return candidate
+	return runtime_dir
 }
 
 type Walk_callback func(path, abspath string, d fs.DirEntry, err error) error
@@ -0,0 +1,70 @@
+// License: GPLv3 Copyright: 2022, Kovid Goyal, <kovid at kovidgoyal.net>
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var _ = fmt.Print
+
+func TestDirOverrides(t *testing.T) {
+	tdir := t.TempDir()
+	defer func() { config_dir, cache_dir, runtime_dir = "", "", "" }()
+	for _, name := range []string{"KITTY_CONFIG_DIRECTORY", "KITTY_CACHE_DIRECTORY", "KITTY_RUNTIME_DIRECTORY"} {
+		t.Setenv(name, "")
+	}
+	config_dir, cache_dir, runtime_dir = "", "", ""
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tdir, "xdg-config"))
+	t.Setenv("XDG_CONFIG_DIRS", "")
+	t.Setenv("HOME", filepath.Join(tdir, "home"))
+	if q := ConfigDir(); q != filepath.Join(tdir, "xdg-config", "kitty") {
+		t.Fatalf("XDG_CONFIG_HOME not honored, config dir is: %s", q)
+	}
+	SetConfigDir(filepath.Join(tdir, "conf"))
+	SetCacheDir(filepath.Join(tdir, "cache"))
+	SetRuntimeDir(filepath.Join(tdir, "run"))
+	for name, q := range map[string]string{"conf": ConfigDir(), "cache": CacheDir(), "run": RuntimeDir()} {
+		if q != filepath.Join(tdir, name) {
+			t.Fatalf("Override of the %s directory was not honored: %s", name, q)
+		}
+	}
+	if os.Getenv("KITTY_CACHE_DIRECTORY") != CacheDir() {
+		t.Fatalf("Override of the cache directory not set in the environment")
+	}
+	if st, err := os.Stat(RuntimeDir()); err != nil || st.Mode().Perm() != 0o700 {
+		t.Fatalf("Runtime directory not created private: %v %v", st, err)
+	}
+}
+
+// Ensure all code finds the kitty directories via ConfigDir(), CacheDir()
+// and RuntimeDir() so that overrides are honored
+func TestNoHardcodedDirs(t *testing.T) {
+	pat := regexp.MustCompile(`~/\.config|~/\.cache|~/Library/(Caches|Preferences)|"(XDG_(CONFIG|CACHE|RUNTIME)_|KITTY_(CONFIG|CACHE|RUNTIME)_DIRECTORY)|os\.User(Config|Cache)Dir`)
+	allowed, _ := filepath.Abs("paths.go")
+	root, _ := filepath.Abs("..")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_generated.go") || path == allowed {
+			return err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for i, line := range strings.Split(string(raw), "\n") {
+			if m := pat.FindString(line); m != "" {
+				t.Errorf("%s:%d uses %s, use ConfigDir(), CacheDir() or RuntimeDir() instead", path, i+1, m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -50,14 +50,15 @@ type EscapeCodeParser struct {
 	ReplaceInvalidUtf8Bytes bool
 
 	// Callbacks
-	HandleRune                func(rune) error
-	HandleEndOfBracketedPaste func()
-	HandleCSI                 func([]byte) error
-	HandleOSC                 func([]byte) error
-	HandleDCS                 func([]byte) error
-	HandlePM                  func([]byte) error
-	HandleSOS                 func([]byte) error
-	HandleAPC                 func([]byte) error
+	HandleRune                  func(rune) error
+	HandleStartOfBracketedPaste func() error
+	HandleEndOfBracketedPaste   func() error
+	HandleCSI                   func([]byte) error
+	HandleOSC                   func([]byte) error
+	HandleDCS                   func([]byte) error
+	HandlePM                    func([]byte) error
+	HandleSOS                   func([]byte) error
+	HandleAPC                   func([]byte) error
 }
 
 func (self *EscapeCodeParser) InBracketedPaste() bool { return self.state == bracketed_paste }
@@ -145,6 +146,9 @@ func (self *EscapeCodeParser) dispatch_esc_code() error {
 	if self.state == csi && bytes.Equal(self.current_buffer, bracketed_paste_start) {
 		self.reset_state()
 		self.state = bracketed_paste
+		if self.HandleStartOfBracketedPaste != nil {
+			return self.HandleStartOfBracketedPaste()
+		}
 		return nil
 	}
 	var err error
@@ -198,7 +202,7 @@ func (self *EscapeCodeParser) dispatch_char(ch utils.UTF8State) error {
 				if self.bracketed_paste_buffer[len(self.bracketed_paste_buffer)-1] == '~' {
 					self.reset_state()
 					if self.HandleEndOfBracketedPaste != nil {
-						self.HandleEndOfBracketedPaste()
+						return self.HandleEndOfBracketedPaste()
 					}
 				}
 				return nil
@@ -25,6 +25,9 @@ func TestEscapeCodeParsing(t *testing.T) {
 		HandlePM:   func(b []byte) error { return add("PM", b) },
 		HandleAPC:  func(b []byte) error { return add("APC", b) },
 		HandleRune: func(b rune) error { return add("CH", []byte(string(b))) },
+
+		HandleStartOfBracketedPaste: func() error { return add("PASTE", []byte("start")) },
+		HandleEndOfBracketedPaste:   func() error { return add("PASTE", []byte("end")) },
 	}
 
 	reset_test_parser := func() {
@@ -48,7 +51,7 @@ func TestEscapeCodeParsing(t *testing.T) {
 	test("\x1b[31m\xc2\x9bm", "CSI: 31m\nCSI: m")
 	test("ab\nc", "CH: a\nCH: b\nCH: \n\nCH: c")
 	test("a\x1b[200m\x1b[mb\x1b[5:3;2;4~", "CH: a\nCSI: 200m\nCSI: m\nCH: b\nCSI: 5:3;2;4~")
-	test("\x1b[200~a\x1b[201m\x1b[201~\x1b[x", "CH: a\nCH: \x1b\nCH: [\nCH: 2\nCH: 0\nCH: 1\nCH: m\nCSI: x")
+	test("\x1b[200~a\x1b[201m\x1b[201~\x1b[x", "PASTE: start\nCH: a\nCH: \x1b\nCH: [\nCH: 2\nCH: 0\nCH: 1\nCH: m\nPASTE: end\nCSI: x")
 	test("a\x1bPb\x1b\x1bc\x1b\\d", "CH: a\nDCS: b\x1bc\nCH: d")
 	test("a\x1b_b\x1b\x1b\x1bc\x1b\\d", "CH: a\nAPC: b\x1b\x1bc\nCH: d")
 	test("\x1b]X\x07\x1b]X\x1b\x07\x1b\\", "OSC: X\nOSC: X\x1b\x07")